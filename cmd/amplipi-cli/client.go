@@ -0,0 +1,131 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// client talks to the AmpliPi API over TCP or the daemon's Unix socket.
+type client struct {
+	base   string // e.g. "http://localhost"
+	apiKey string // sent as the api-key query parameter; empty for none
+	http   *http.Client
+}
+
+// newClient connects to addr ("host[:port]" or a URL), or to the Unix
+// socket when addr is empty and the socket exists. Requests on the socket
+// need no API key.
+func newClient(addr, socket, apiKey string) *client {
+	c := &client{apiKey: apiKey, http: &http.Client{
+		// A login redirect means the API key is missing or wrong; report
+		// it rather than following it to the HTML login page.
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}}
+	if addr == "" {
+		if _, err := os.Stat(socket); err == nil {
+			c.base = "http://localhost"
+			c.http.Transport = &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			}
+			return c
+		}
+		addr = "localhost"
+	}
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	c.base = strings.TrimSuffix(addr, "/")
+	return c
+}
+
+// url returns the URL of an API path, e.g. "/api/zones".
+func (c *client) url(path string) string {
+	u := c.base + path
+	if c.apiKey != "" {
+		sep := "?"
+		if strings.Contains(path, "?") {
+			sep = "&"
+		}
+		u += sep + "api-key=" + url.QueryEscape(c.apiKey)
+	}
+	return u
+}
+
+// do sends body as JSON and decodes the response into out, if given.
+// Non-2xx responses are returned as errors carrying the API's message.
+func (c *client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decode response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send performs a request and checks its status, leaving the body to the
+// caller.
+func (c *client) send(ctx context.Context, method, path string, body interface{}) (*http.Response, error) {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return nil, err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.url(path), &buf)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusFound {
+		return nil, fmt.Errorf("%s %s: authentication required (use -api-key or the Unix socket)", method, path)
+	}
+	return nil, fmt.Errorf("%s %s: %s", method, path, apiError(resp))
+}
+
+// apiError extracts the message from an error response.
+func apiError(resp *http.Response) string {
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var e struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &e) == nil {
+		switch {
+		case e.Message != "":
+			return e.Message
+		case e.Error != "":
+			return e.Error
+		}
+	}
+	if msg := strings.TrimSpace(string(data)); msg != "" {
+		return msg
+	}
+	return resp.Status
+}
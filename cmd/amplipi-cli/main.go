@@ -0,0 +1,459 @@
+// Command amplipi-cli controls an AmpliPi from the command line, over the
+// daemon's Unix socket when run on the device or over HTTP otherwise. It is
+// meant for scripting and headless troubleshooting.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// requestTimeout bounds every command except announcements and event
+// tails, which run as long as they need to.
+const requestTimeout = 10 * time.Second
+
+const usage = `usage: amplipi-cli [flags] <command> [args]
+
+Commands:
+  zones                        list zones
+  vol <zone> [level|up|down]   show or set a zone's volume; level is 0-1 or a percentage (e.g. 40%)
+  streams                      list streams
+  presets                      list presets
+  load <preset>                load a preset
+  announce [flags] <media>     play an announcement and wait for it to finish
+  backup [-list]               back up the configuration, or list backups
+  events [-state]              print events as they happen until interrupted
+
+Zones and presets may be given by ID or name.
+
+Flags:
+`
+
+// cli holds the global flags shared by all commands.
+type cli struct {
+	c    *client
+	json bool // print raw API responses
+}
+
+func main() {
+	var (
+		addr   = flag.String("addr", "", "AmpliPi address, host[:port] or URL (default: the Unix socket if present, else localhost)")
+		socket = flag.String("socket", "/run/amplipi/api.sock", "AmpliPi API Unix socket")
+		apiKey = flag.String("api-key", os.Getenv("AMPLIPI_API_KEY"), "API key for password-protected units (default $AMPLIPI_API_KEY); not needed on the socket")
+		asJSON = flag.Bool("json", false, "print raw JSON responses")
+	)
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	app := &cli{c: newClient(*addr, *socket, *apiKey), json: *asJSON}
+	if err := app.run(ctx, flag.Arg(0), flag.Args()[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "amplipi-cli:", err)
+		os.Exit(1)
+	}
+}
+
+// run dispatches one command.
+func (a *cli) run(ctx context.Context, cmd string, args []string) error {
+	switch cmd {
+	case "announce":
+		return a.announce(ctx, args)
+	case "events":
+		return a.events(ctx, args)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+	switch cmd {
+	case "zones":
+		return a.zones(ctx)
+	case "vol":
+		return a.vol(ctx, args)
+	case "streams":
+		return a.streams(ctx)
+	case "presets":
+		return a.presets(ctx)
+	case "load":
+		return a.load(ctx, args)
+	case "backup":
+		return a.backup(ctx, args)
+	}
+	return fmt.Errorf("unknown command %q (run with -h for usage)", cmd)
+}
+
+// print writes v as indented JSON.
+func (a *cli) print(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (a *cli) zones(ctx context.Context) error {
+	var resp struct {
+		Zones []models.Zone `json:"zones"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/api/zones", nil, &resp); err != nil {
+		return err
+	}
+	if a.json {
+		return a.print(resp)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tSOURCE\tVOL")
+	for _, z := range resp.Zones {
+		if z.Disabled {
+			continue
+		}
+		src := "-"
+		if z.SourceID >= 0 {
+			src = strconv.Itoa(z.SourceID)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", z.ID, z.Name, src, formatVol(z))
+	}
+	return tw.Flush()
+}
+
+func (a *cli) vol(ctx context.Context, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: vol <zone> [level|up|down]")
+	}
+	id, err := a.findZone(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/zones/%d", id)
+	if len(args) == 2 {
+		switch args[1] {
+		case "up", "down":
+			if err := a.c.do(ctx, http.MethodPost, path+"/vol_"+args[1], nil, nil); err != nil {
+				return err
+			}
+		default:
+			level, err := parseLevel(args[1])
+			if err != nil {
+				return err
+			}
+			if err := a.c.do(ctx, http.MethodPatch, path, models.ZoneUpdate{VolF: &level}, nil); err != nil {
+				return err
+			}
+		}
+	}
+	var z models.Zone
+	if err := a.c.do(ctx, http.MethodGet, path, nil, &z); err != nil {
+		return err
+	}
+	if a.json {
+		return a.print(z)
+	}
+	fmt.Printf("%s: %s\n", z.Name, formatVol(z))
+	return nil
+}
+
+// parseLevel parses a volume as 0-1 or a percentage, "40%".
+func parseLevel(s string) (float64, error) {
+	pct := strings.HasSuffix(s, "%")
+	v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid volume %q: want 0-1 or a percentage", s)
+	}
+	if pct {
+		v /= 100
+	}
+	if v < 0 || v > 1 {
+		return 0, fmt.Errorf("volume %q out of range", s)
+	}
+	return v, nil
+}
+
+// formatVol shows a zone's volume as a percentage and in dB.
+func formatVol(z models.Zone) string {
+	s := fmt.Sprintf("%.0f%% (%d dB)", z.VolF*100, z.Vol)
+	if z.Mute {
+		s += " muted"
+	}
+	return s
+}
+
+func (a *cli) streams(ctx context.Context) error {
+	var resp struct {
+		Streams []models.Stream `json:"streams"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/api/streams", nil, &resp); err != nil {
+		return err
+	}
+	if a.json {
+		return a.print(resp)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTYPE\tSTATE\tPLAYING")
+	for _, s := range resp.Streams {
+		playing := s.Info.Track
+		if s.Info.Artist != "" {
+			playing = s.Info.Artist + " - " + playing
+		}
+		state := s.Info.State
+		if state == "" {
+			state = "-"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", s.ID, s.Name, s.Type, state, playing)
+	}
+	return tw.Flush()
+}
+
+func (a *cli) presets(ctx context.Context) error {
+	presets, err := a.getPresets(ctx)
+	if err != nil {
+		return err
+	}
+	if a.json {
+		return a.print(map[string]interface{}{"presets": presets})
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME")
+	for _, p := range presets {
+		fmt.Fprintf(tw, "%d\t%s\n", p.ID, p.Name)
+	}
+	return tw.Flush()
+}
+
+func (a *cli) getPresets(ctx context.Context) ([]models.Preset, error) {
+	var resp struct {
+		Presets []models.Preset `json:"presets"`
+	}
+	err := a.c.do(ctx, http.MethodGet, "/api/presets", nil, &resp)
+	return resp.Presets, err
+}
+
+func (a *cli) load(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: load <preset>")
+	}
+	presets, err := a.getPresets(ctx)
+	if err != nil {
+		return err
+	}
+	names := make(map[int]string, len(presets))
+	for _, p := range presets {
+		names[p.ID] = p.Name
+	}
+	id, err := resolve("preset", args[0], names)
+	if err != nil {
+		return err
+	}
+	if err := a.c.do(ctx, http.MethodPost, fmt.Sprintf("/api/presets/%d/load", id), nil, nil); err != nil {
+		return err
+	}
+	fmt.Printf("loaded preset %d (%s)\n", id, names[id])
+	return nil
+}
+
+func (a *cli) announce(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("announce", flag.ContinueOnError)
+	zones := fs.String("zones", "", "comma-separated zone IDs (default: all enabled zones)")
+	groups := fs.String("groups", "", "comma-separated group IDs")
+	vol := fs.String("vol", "", "volume, 0-1 or a percentage (default 50%)")
+	source := fs.Int("source", -1, "source to play on (default: the daemon's choice)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: amplipi-cli announce [flags] <media URL>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("announce needs exactly one media URL")
+	}
+
+	req := models.AnnounceRequest{Media: fs.Arg(0)}
+	var err error
+	if req.Zones, err = parseIDs(*zones); err != nil {
+		return err
+	}
+	if req.Groups, err = parseIDs(*groups); err != nil {
+		return err
+	}
+	if *vol != "" {
+		v, err := parseLevel(*vol)
+		if err != nil {
+			return err
+		}
+		req.VolF = &v
+	}
+	if *source >= 0 {
+		req.SourceID = source
+	}
+	// Blocks until the announcement has played.
+	return a.c.do(ctx, http.MethodPost, "/api/announce", req, nil)
+}
+
+// parseIDs parses a comma-separated list of IDs.
+func parseIDs(s string) ([]int, error) {
+	var ids []int
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		id, err := strconv.Atoi(f)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID %q", f)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func (a *cli) backup(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	list := fs.Bool("list", false, "list existing backups instead of creating one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *list {
+		var resp struct {
+			Backups []string `json:"backups"`
+		}
+		if err := a.c.do(ctx, http.MethodGet, "/api/backup", nil, &resp); err != nil {
+			return err
+		}
+		if a.json {
+			return a.print(resp)
+		}
+		for _, f := range resp.Backups {
+			fmt.Println(f)
+		}
+		return nil
+	}
+	var resp struct {
+		File string `json:"file"`
+	}
+	if err := a.c.do(ctx, http.MethodPost, "/api/backup", nil, &resp); err != nil {
+		return err
+	}
+	if a.json {
+		return a.print(resp)
+	}
+	fmt.Println(resp.File)
+	return nil
+}
+
+// events tails the server-sent event stream, one line per event:
+// "<time> <type> <data>". State updates are printed only with -state.
+func (a *cli) events(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	withState := fs.Bool("state", false, "also print full state updates")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	resp, err := a.c.send(ctx, http.MethodGet, "/api/subscribe", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 16<<20) // state updates can be large
+	var name string
+	for sc.Scan() {
+		line := sc.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			a.printEvent(name, strings.TrimPrefix(line, "data: "), *withState)
+		case line == "":
+			name = ""
+		}
+	}
+	if ctx.Err() != nil {
+		return nil // interrupted
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("event stream closed by the server")
+}
+
+// printEvent prints one SSE message; unnamed messages are state updates.
+func (a *cli) printEvent(name, data string, withState bool) {
+	if name == "" && !withState {
+		return
+	}
+	if a.json {
+		fmt.Println(data)
+		return
+	}
+	var ev struct {
+		Time time.Time       `json:"time"`
+		Data json.RawMessage `json:"data"`
+	}
+	if name == "" {
+		fmt.Printf("%s state %s\n", time.Now().Format(time.RFC3339), data)
+		return
+	}
+	if err := json.Unmarshal([]byte(data), &ev); err != nil {
+		fmt.Printf("%s %s %s\n", time.Now().Format(time.RFC3339), name, data)
+		return
+	}
+	fmt.Printf("%s %s %s\n", ev.Time.Local().Format(time.RFC3339), name, ev.Data)
+}
+
+// findZone resolves a zone ID or name.
+func (a *cli) findZone(ctx context.Context, s string) (int, error) {
+	var resp struct {
+		Zones []models.Zone `json:"zones"`
+	}
+	if err := a.c.do(ctx, http.MethodGet, "/api/zones", nil, &resp); err != nil {
+		return 0, err
+	}
+	names := make(map[int]string, len(resp.Zones))
+	for _, z := range resp.Zones {
+		names[z.ID] = z.Name
+	}
+	return resolve("zone", s, names)
+}
+
+// resolve finds s among names by ID, then by case-insensitive name.
+func resolve(kind, s string, names map[int]string) (int, error) {
+	if id, err := strconv.Atoi(s); err == nil {
+		if _, ok := names[id]; ok {
+			return id, nil
+		}
+		return 0, fmt.Errorf("%s %d not found", kind, id)
+	}
+	found := -1
+	for id, name := range names {
+		if strings.EqualFold(name, s) {
+			if found >= 0 {
+				return 0, fmt.Errorf("%s name %q is ambiguous; use its ID", kind, s)
+			}
+			found = id
+		}
+	}
+	if found < 0 {
+		return 0, fmt.Errorf("%s %q not found", kind, s)
+	}
+	return found, nil
+}
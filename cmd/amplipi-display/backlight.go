@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Backlight modes.
+const (
+	backlightAuto = "auto" // dim when idle, off during off hours
+	backlightOn   = "on"
+	backlightOff  = "off"
+)
+
+// BacklightConfig controls the screensaver.
+type BacklightConfig struct {
+	DimAfter time.Duration // idle time before dimming; 0 never dims
+	DimLevel float64       // brightness while dimmed, 0-1
+	OffFrom  string        // nightly off hours, "HH:MM"; empty for none
+	OffTo    string
+}
+
+// parseOffHours parses "-backlight-off": "HH:MM-HH:MM", e.g. "23:00-07:00".
+func parseOffHours(s string) (from, to string, err error) {
+	if s == "" {
+		return "", "", nil
+	}
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return "", "", fmt.Errorf("want HH:MM-HH:MM, got %q", s)
+	}
+	for _, t := range []string{from, to} {
+		if _, err := time.Parse("15:04", t); err != nil {
+			return "", "", fmt.Errorf("%q is not HH:MM", t)
+		}
+	}
+	return from, to, nil
+}
+
+// Backlight dims the display after a period without state changes or
+// front-panel input, and turns it off during the nightly off hours unless
+// woken.
+type Backlight struct {
+	cfg BacklightConfig
+	set func(level float64) error // drives the hardware
+
+	mu           sync.Mutex
+	mode         string
+	lastActivity time.Time
+	level        float64 // level last set; -1 before the first set
+}
+
+// NewBacklight creates an automatic backlight that starts at full
+// brightness.
+func NewBacklight(cfg BacklightConfig, set func(level float64) error) *Backlight {
+	return &Backlight{cfg: cfg, set: set, mode: backlightAuto, lastActivity: time.Now(), level: -1}
+}
+
+// Activity records a state change or button press, waking the display.
+func (b *Backlight) Activity(now time.Time) {
+	b.mu.Lock()
+	b.lastActivity = now
+	b.mu.Unlock()
+	b.Update(now)
+}
+
+// SetMode switches between automatic control and forced on or off.
+func (b *Backlight) SetMode(mode string) error {
+	switch mode {
+	case backlightAuto, backlightOn, backlightOff:
+	default:
+		return fmt.Errorf("unknown backlight mode %q", mode)
+	}
+	b.mu.Lock()
+	b.mode = mode
+	b.mu.Unlock()
+	b.Update(time.Now())
+	return nil
+}
+
+// Invalidate forgets the level last set, so the next Update writes it
+// again (e.g. after the display was reinitialized).
+func (b *Backlight) Invalidate() {
+	b.mu.Lock()
+	b.level = -1
+	b.mu.Unlock()
+}
+
+// Update sets the brightness the mode and idle time call for at now.
+func (b *Backlight) Update(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	level := b.target(now)
+	if level == b.level {
+		return
+	}
+	if err := b.set(level); err != nil {
+		slog.Warn("set backlight failed", "level", level, "err", err)
+		return
+	}
+	slog.Debug("backlight changed", "level", level)
+	b.level = level
+}
+
+// target returns the brightness for now. Must be called with b.mu held.
+func (b *Backlight) target(now time.Time) float64 {
+	switch b.mode {
+	case backlightOn:
+		return 1
+	case backlightOff:
+		return 0
+	}
+	idle := b.cfg.DimAfter > 0 && now.Sub(b.lastActivity) >= b.cfg.DimAfter
+	switch {
+	case inOffHours(b.cfg.OffFrom, b.cfg.OffTo, now) && (idle || b.cfg.DimAfter == 0):
+		return 0
+	case idle:
+		return b.cfg.DimLevel
+	}
+	return 1
+}
+
+// inOffHours reports whether t falls between from and to ("HH:MM", may
+// wrap past midnight).
+func inOffHours(from, to string, t time.Time) bool {
+	if from == "" || to == "" {
+		return false
+	}
+	f, err1 := time.Parse("15:04", from)
+	e, err2 := time.Parse("15:04", to)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	m := t.Hour()*60 + t.Minute()
+	fm, em := f.Hour()*60+f.Minute(), e.Hour()*60+e.Minute()
+	if fm <= em {
+		return m >= fm && m < em
+	}
+	return m >= fm || m < em
+}
+
+// backlightStatus is the body of GET /backlight.
+type backlightStatus struct {
+	Mode         string    `json:"mode"`
+	Level        float64   `json:"level"`
+	LastActivity time.Time `json:"last_activity"`
+}
+
+// Handler serves the local control endpoint:
+//
+//	GET  /backlight       current mode and level
+//	PUT  /backlight       {"mode":"auto"|"on"|"off"}
+//	POST /backlight/wake  wake the display as if a button was pressed
+func (b *Backlight) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /backlight", func(w http.ResponseWriter, r *http.Request) {
+		b.mu.Lock()
+		st := backlightStatus{Mode: b.mode, Level: max(b.level, 0), LastActivity: b.lastActivity}
+		b.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+	})
+	mux.HandleFunc("PUT /backlight", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Mode string `json:"mode"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid JSON: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := b.SetMode(req.Mode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /backlight/wake", func(w http.ResponseWriter, r *http.Request) {
+		b.Activity(time.Now())
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// serveControl serves the control endpoint on a Unix socket until ctx is
+// cancelled.
+func serveControl(ctx context.Context, path string, h http.Handler) error {
+	_ = os.Remove(path) // stale socket from a previous run
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("listen %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return err
+	}
+	srv := &http.Server{Handler: h}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+	slog.Info("display control endpoint listening", "socket", path)
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 )
@@ -21,19 +23,21 @@ type Config struct {
 	APIURL     string // URL of the AmpliPi API
 	UpdateRate int    // Update rate in seconds
 	LogLevel   string // Log level (debug, info, warn, error)
+	Socket     string // Unix socket of the API; empty to use TCP
 }
 
 // Status represents system status for display.
 type Status struct {
-	Hostname     string
-	IP           string
-	Password     string
-	DiskUsedGB   float64
-	DiskTotalGB  float64
-	DiskPercent  float64
-	Sources      []SourceInfo
-	Zones        []ZoneInfo
-	Expanders    int
+	Hostname    string
+	IP          string
+	Password    string
+	PassChanged bool // the user replaced the default password
+	DiskUsedGB  float64
+	DiskTotalGB float64
+	DiskPercent float64
+	Sources     []SourceInfo
+	Zones       []ZoneInfo
+	Expanders   int
 }
 
 // SourceInfo holds source display information.
@@ -57,6 +61,14 @@ func main() {
 		addr       = flag.String("addr", "localhost", "AmpliPi API address")
 		updateRate = flag.Int("update-rate", 1, "Display update rate in seconds")
 		logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
+		target     = flag.String("panel-target", "zones/0", "Zone or group the front-panel controls (zones/<id> or groups/<id>)")
+		buttons    = flag.String("buttons", "", "Front-panel buttons as pin:action[:long_action],... (actions: vol_up, vol_down, mute, mute_all, next_source, prev_source, wake, pair)")
+		encPins    = flag.String("encoder", "", "Front-panel rotary encoder pins A,B (e.g. GPIO20,GPIO21)")
+		dimAfter   = flag.Duration("dim-after", 5*time.Minute, "Dim the backlight after this long without state changes or button presses (0 = never)")
+		dimLevel   = flag.Float64("dim-level", 0.1, "Dimmed backlight brightness, 0-1")
+		offHours   = flag.String("backlight-off", "", "Nightly backlight off hours, HH:MM-HH:MM (e.g. 23:00-07:00)")
+		socket     = flag.String("socket", "/run/amplipi/api.sock", "AmpliPi API Unix socket, used with -addr :unix")
+		control    = flag.String("control", "/run/amplipi-display.sock", "Unix socket for the local control endpoint (empty = disabled)")
 	)
 	flag.Parse()
 
@@ -72,19 +84,25 @@ func main() {
 	}
 	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: level})))
 
-	// Normalize address - ":unix" means "localhost" for backward compatibility
+	// ":unix" talks to the daemon's Unix socket, falling back to
+	// localhost when the daemon was started without one
 	apiHost := *addr
+	sockPath := ""
 	if apiHost == ":unix" || apiHost == "unix" {
 		apiHost = "localhost"
+		if _, err := os.Stat(*socket); err == nil {
+			sockPath = *socket
+		}
 	}
 
 	cfg := Config{
 		APIURL:     fmt.Sprintf("http://%s/api", apiHost),
 		UpdateRate: *updateRate,
 		LogLevel:   *logLevel,
+		Socket:     sockPath,
 	}
 
-	slog.Info("amplipi-display starting", "api", cfg.APIURL, "rate", cfg.UpdateRate)
+	slog.Info("amplipi-display starting", "api", cfg.APIURL, "socket", cfg.Socket, "rate", cfg.UpdateRate)
 
 	// Check for TFT display hardware
 	// TODO: Implement actual hardware detection via SPI
@@ -107,6 +125,49 @@ func main() {
 		cancel()
 	}()
 
+	// Backlight screensaver
+	bl := BacklightConfig{DimAfter: *dimAfter, DimLevel: *dimLevel}
+	var err error
+	if bl.OffFrom, bl.OffTo, err = parseOffHours(*offHours); err != nil {
+		slog.Error("invalid -backlight-off", "err", err)
+		os.Exit(1)
+	}
+	backlight = NewBacklight(bl, func(level float64) error {
+		if tftDisplay == nil {
+			return nil // log-only mode, or the TFT failed to initialize
+		}
+		return tftDisplay.SetBacklight(level)
+	})
+	if *control != "" {
+		go func() {
+			if err := serveControl(ctx, *control, backlight.Handler()); err != nil {
+				slog.Warn("display control endpoint failed", "err", err)
+			}
+		}()
+	}
+
+	// Front-panel buttons and rotary encoder, on boards that have them
+	panel := PanelConfig{Target: *target}
+	if panel.Buttons, err = parseButtons(*buttons); err != nil {
+		slog.Error("invalid -buttons", "err", err)
+		os.Exit(1)
+	}
+	if *encPins != "" {
+		pins := strings.Split(*encPins, ",")
+		if len(pins) != 2 {
+			slog.Error("invalid -encoder: want two pins A,B", "encoder", *encPins)
+			os.Exit(1)
+		}
+		panel.EncoderA, panel.EncoderB = strings.TrimSpace(pins[0]), strings.TrimSpace(pins[1])
+	}
+	if len(panel.Buttons) > 0 || panel.EncoderA != "" {
+		go func() {
+			if err := runPanel(ctx, cfg, panel); err != nil {
+				slog.Error("front panel input failed", "err", err)
+			}
+		}()
+	}
+
 	// Run display update loop
 	if err := run(ctx, cfg, displayType); err != nil {
 		slog.Error("display driver failed", "err", err)
@@ -132,7 +193,7 @@ func detectDisplay() string {
 
 // run executes the main display update loop.
 func run(ctx context.Context, cfg Config, displayType string) error {
-	client := &http.Client{Timeout: 5 * time.Second}
+	client := newAPIClient(cfg)
 	ticker := time.NewTicker(time.Duration(cfg.UpdateRate) * time.Second)
 	defer ticker.Stop()
 
@@ -165,6 +226,21 @@ func run(ctx context.Context, cfg Config, displayType string) error {
 	}
 }
 
+// newAPIClient returns an HTTP client for the API, dialing the Unix socket
+// when one is configured.
+func newAPIClient(cfg Config) *http.Client {
+	client := &http.Client{Timeout: 5 * time.Second}
+	if cfg.Socket != "" {
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", cfg.Socket)
+			},
+		}
+	}
+	return client
+}
+
 // updateDisplay fetches status from API and updates the display.
 func updateDisplay(ctx context.Context, client *http.Client, cfg Config, displayType string) error {
 	// Fetch status from API
@@ -178,9 +254,24 @@ func updateDisplay(ctx context.Context, client *http.Client, cfg Config, display
 		return fmt.Errorf("render: %w", err)
 	}
 
+	// Any change to sources or zones counts as activity for the backlight
+	now := time.Now()
+	if fp := fmt.Sprint(status.Sources, status.Zones); fp != lastStateFingerprint {
+		lastStateFingerprint = fp
+		backlight.Activity(now)
+	} else {
+		backlight.Update(now)
+	}
+
 	return nil
 }
 
+// lastStateFingerprint summarizes the last rendered sources and zones.
+var lastStateFingerprint string
+
+// Global backlight controller
+var backlight *Backlight
+
 // fetchStatus retrieves system status from the AmpliPi API.
 func fetchStatus(ctx context.Context, client *http.Client, apiURL string) (*Status, error) {
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
@@ -238,6 +329,7 @@ func fetchStatus(ctx context.Context, client *http.Client, apiURL string) (*Stat
 
 	// Get disk usage
 	diskUsedGB, diskTotalGB, diskPercent := getDiskUsage()
+	password, passChanged := getPassword()
 
 	// Build source info
 	sources := make([]SourceInfo, len(apiResp.Sources))
@@ -279,7 +371,8 @@ func fetchStatus(ctx context.Context, client *http.Client, apiURL string) (*Stat
 	return &Status{
 		Hostname:    hostname,
 		IP:          ip,
-		Password:    getPassword(),
+		Password:    password,
+		PassChanged: passChanged,
 		DiskUsedGB:  diskUsedGB,
 		DiskTotalGB: diskTotalGB,
 		DiskPercent: diskPercent,
@@ -289,18 +382,58 @@ func fetchStatus(ctx context.Context, client *http.Client, apiURL string) (*Stat
 	}, nil
 }
 
-// getDiskUsage returns disk usage statistics.
+// getDiskUsage returns usage of the root filesystem. Like df, the
+// percentage is of the space available to unprivileged users.
 func getDiskUsage() (usedGB, totalGB, percent float64) {
-	// TODO: Implement actual disk usage check via syscall.Statfs
-	// For now, return placeholder values
-	return 7.2, 29.0, 24.8
+	var st syscall.Statfs_t
+	if err := syscall.Statfs("/", &st); err != nil {
+		slog.Debug("statfs failed", "err", err)
+		return 0, 0, 0
+	}
+	bsize := float64(st.Bsize)
+	used := float64(st.Blocks-st.Bfree) * bsize
+	avail := float64(st.Bavail) * bsize
+	const gb = 1 << 30
+	if used+avail > 0 {
+		percent = used / (used + avail) * 100
+	}
+	return used / gb, float64(st.Blocks) * bsize / gb, percent
 }
 
-// getPassword reads the default password from config file.
-func getPassword() string {
-	// TODO: Read from ~/.config/amplipi/default_password.txt
-	// For now, return default
-	return "raspberry"
+// defaultPasswordFile holds the password generated for the pi user when
+// the image was first booted.
+const defaultPasswordFile = ".config/amplipi/default_password.txt"
+
+// getPassword returns the default password and whether the user has since
+// changed it. The display runs unprivileged and cannot check the password
+// hash, so a change is assumed when /etc/shadow was modified after the
+// default password was written, or the file has been removed.
+func getPassword() (password string, changed bool) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", false
+	}
+	path := filepath.Join(home, defaultPasswordFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", os.IsNotExist(err)
+	}
+	password = strings.TrimSpace(strings.SplitN(string(data), "\n", 2)[0])
+	info, err1 := os.Stat(path)
+	shadow, err2 := os.Stat("/etc/shadow")
+	if err1 == nil && err2 == nil && shadow.ModTime().After(info.ModTime()) {
+		changed = true
+	}
+	return password, changed || password == ""
+}
+
+// displayPassword is the password line of the display: the default
+// password, or a note that it was changed.
+func displayPassword(status *Status) string {
+	if status.PassChanged {
+		return "(changed)"
+	}
+	return status.Password
 }
 
 // render displays the status on the appropriate hardware.
@@ -331,6 +464,8 @@ func renderTFT(status *Status) error {
 			slog.Warn("TFT init failed, falling back to log-only mode", "err", err)
 			return renderLog(status)
 		}
+		// Initialization turns the backlight fully on
+		backlight.Invalidate()
 	}
 
 	// Render status to TFT
@@ -364,7 +499,7 @@ func renderLog(status *Status) error {
 	slog.Info("display status",
 		"hostname", status.Hostname,
 		"ip", status.IP,
-		"password", status.Password,
+		"password", displayPassword(status),
 		"disk", fmt.Sprintf("%.1f/%.1f GB (%.1f%%)", status.DiskUsedGB, status.DiskTotalGB, status.DiskPercent),
 		"zones", fmt.Sprintf("▶%d ⏸%d (total: %d)", playing, muted, len(status.Zones)),
 		"expanders", status.Expanders,
@@ -0,0 +1,373 @@
+//go:build linux
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"periph.io/x/conn/v3/gpio"
+	"periph.io/x/conn/v3/gpio/gpioreg"
+	"periph.io/x/host/v3"
+)
+
+const (
+	panelPollInterval     = 5 * time.Millisecond   // while a button settles, or without edge events
+	panelEdgeTimeout      = time.Second            // how often edge waiters check for shutdown
+	panelDebounce         = 20 * time.Millisecond  // a button level must be stable this long
+	panelLongPress        = 800 * time.Millisecond // held this long, a button fires its long action
+	encoderStepsPerDetent = 4                      // quadrature transitions per encoder click
+)
+
+// Panel actions.
+const (
+	actionVolUp      = "vol_up"
+	actionVolDown    = "vol_down"
+	actionMute       = "mute" // toggles the target's mute
+	actionMuteAll    = "mute_all"
+	actionNextSource = "next_source"
+	actionPrevSource = "prev_source"
+	actionWake       = "wake" // only wakes the display
+	actionPair       = "pair" // lets a mobile app pair for a while
+)
+
+var panelActions = map[string]bool{
+	actionVolUp: true, actionVolDown: true, actionMute: true,
+	actionMuteAll: true, actionNextSource: true, actionPrevSource: true,
+	actionWake: true, actionPair: true,
+}
+
+// PanelConfig describes the front-panel buttons and rotary encoder. Buttons
+// and encoder switch their pins to ground (inputs use the internal
+// pull-ups).
+type PanelConfig struct {
+	Target   string         // "zones/<id>" or "groups/<id>": what the panel controls
+	Buttons  []ButtonConfig // none when the board has no buttons
+	EncoderA string         // encoder pins; empty for no encoder
+	EncoderB string
+}
+
+// ButtonConfig maps a button to a short-press and optional long-press action.
+type ButtonConfig struct {
+	Pin   string
+	Short string
+	Long  string
+}
+
+// parseButtons parses "-buttons": comma-separated pin:short[:long], e.g.
+// "GPIO26:mute:mute_all,GPIO19:next_source".
+func parseButtons(s string) ([]ButtonConfig, error) {
+	var buttons []ButtonConfig
+	for _, spec := range strings.Split(s, ",") {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		parts := strings.Split(spec, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("button %q: want pin:action[:long_action]", spec)
+		}
+		b := ButtonConfig{Pin: parts[0], Short: parts[1]}
+		if len(parts) == 3 {
+			b.Long = parts[2]
+		}
+		for _, a := range []string{b.Short, b.Long} {
+			if a != "" && !panelActions[a] {
+				return nil, fmt.Errorf("button %q: unknown action %q", spec, a)
+			}
+		}
+		buttons = append(buttons, b)
+	}
+	return buttons, nil
+}
+
+// button debounces one button and detects long presses.
+type button struct {
+	cfg       ButtonConfig
+	pin       gpio.PinIn
+	pressed   bool      // debounced state
+	raw       bool      // last raw reading
+	rawSince  time.Time // when raw last changed
+	downSince time.Time // when the debounced press began
+	longFired bool      // the long action fired during this press
+}
+
+// update takes a raw reading and returns the action to fire, if any.
+// Buttons with a long action fire the short one on release; others fire
+// on press, so they feel immediate.
+func (b *button) update(down bool, now time.Time) string {
+	if down != b.raw {
+		b.raw, b.rawSince = down, now
+	}
+	if b.raw != b.pressed && now.Sub(b.rawSince) >= panelDebounce {
+		b.pressed = b.raw
+		if b.pressed {
+			b.downSince, b.longFired = now, false
+			if b.cfg.Long == "" {
+				return b.cfg.Short
+			}
+		} else if b.cfg.Long != "" && !b.longFired {
+			return b.cfg.Short
+		}
+		return ""
+	}
+	if b.pressed && b.cfg.Long != "" && !b.longFired && now.Sub(b.downSince) >= panelLongPress {
+		b.longFired = true
+		return b.cfg.Long
+	}
+	return ""
+}
+
+// settling reports whether the button needs readings without an edge: while
+// it debounces or waits for a long press.
+func (b *button) settling() bool {
+	return b.raw != b.pressed || (b.pressed && b.cfg.Long != "" && !b.longFired)
+}
+
+// encoder decodes a quadrature rotary encoder.
+type encoder struct {
+	a, b  gpio.PinIn
+	state uint8 // last AB reading
+	steps int   // transitions since the last detent
+}
+
+// quadrature maps (previous AB << 2 | current AB) to a step of -1, 0 or
+// +1; invalid (bouncing) transitions count as 0.
+var quadrature = [16]int{0, 1, -1, 0, -1, 0, 0, 1, 1, 0, 0, -1, 0, -1, 1, 0}
+
+// update takes a reading and returns +1 or -1 for a full detent clockwise
+// or anticlockwise, and 0 otherwise.
+func (e *encoder) update(a, b bool) int {
+	var cur uint8
+	if a {
+		cur |= 2
+	}
+	if b {
+		cur |= 1
+	}
+	e.steps += quadrature[e.state<<2|cur]
+	e.state = cur
+	switch {
+	case e.steps >= encoderStepsPerDetent:
+		e.steps = 0
+		return 1
+	case e.steps <= -encoderStepsPerDetent:
+		e.steps = 0
+		return -1
+	}
+	return 0
+}
+
+// runPanel reads the front-panel inputs until ctx is cancelled and turns
+// them into API calls. Action failures are logged, not fatal. The inputs
+// are read when a pin reports an edge, and every panelPollInterval only
+// while a button settles; pins without edge detection are polled.
+func runPanel(ctx context.Context, cfg Config, panel PanelConfig) error {
+	if _, err := host.Init(); err != nil {
+		return fmt.Errorf("periph.io init: %w", err)
+	}
+	edges := make(chan struct{}, 64)
+	polled := false
+	openIn := func(name string) (gpio.PinIn, error) {
+		p := gpioreg.ByName(name)
+		if p == nil {
+			return nil, fmt.Errorf("failed to open %s", name)
+		}
+		if err := p.In(gpio.PullUp, gpio.BothEdges); err != nil {
+			slog.Warn("no edge detection, polling", "pin", name, "err", err)
+			if err := p.In(gpio.PullUp, gpio.NoEdge); err != nil {
+				return nil, fmt.Errorf("configure %s: %w", name, err)
+			}
+			polled = true
+			return p, nil
+		}
+		go func() {
+			for ctx.Err() == nil {
+				if p.WaitForEdge(panelEdgeTimeout) {
+					select {
+					case edges <- struct{}{}:
+					default:
+					}
+				}
+			}
+		}()
+		return p, nil
+	}
+
+	var buttons []*button
+	for _, bc := range panel.Buttons {
+		p, err := openIn(bc.Pin)
+		if err != nil {
+			return err
+		}
+		buttons = append(buttons, &button{cfg: bc, pin: p})
+	}
+	var enc *encoder
+	if panel.EncoderA != "" && panel.EncoderB != "" {
+		a, err := openIn(panel.EncoderA)
+		if err != nil {
+			return err
+		}
+		b, err := openIn(panel.EncoderB)
+		if err != nil {
+			return err
+		}
+		enc = &encoder{a: a, b: b}
+		enc.update(a.Read() == gpio.High, b.Read() == gpio.High)
+		enc.steps = 0
+	}
+	slog.Info("front panel input started", "target", panel.Target, "buttons", len(buttons), "encoder", enc != nil)
+
+	// Actions run in order on their own goroutine so API latency does not
+	// stall polling; bursts beyond the queue are dropped.
+	actions := make(chan string, 16)
+	go func() {
+		client := newAPIClient(cfg)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case a := <-actions:
+				if err := doPanelAction(ctx, client, cfg.APIURL, panel.Target, a); err != nil {
+					slog.Warn("front panel action failed", "action", a, "err", err)
+				}
+			}
+		}
+	}()
+	fire := func(a string) {
+		backlight.Activity(time.Now())
+		if a == actionWake {
+			return
+		}
+		select {
+		case actions <- a:
+		default:
+		}
+	}
+
+	settling := false
+	for {
+		var tick <-chan time.Time
+		if polled || settling {
+			tick = time.After(panelPollInterval)
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-edges:
+		case <-tick:
+		}
+		now := time.Now()
+		settling = false
+		for _, b := range buttons {
+			if a := b.update(b.pin.Read() == gpio.Low, now); a != "" {
+				fire(a)
+			}
+			settling = settling || b.settling()
+		}
+		if enc != nil {
+			switch enc.update(enc.a.Read() == gpio.High, enc.b.Read() == gpio.High) {
+			case 1:
+				fire(actionVolUp)
+			case -1:
+				fire(actionVolDown)
+			}
+		}
+	}
+}
+
+// doPanelAction performs one action on target ("zones/<id>" or
+// "groups/<id>") through the API.
+func doPanelAction(ctx context.Context, client *http.Client, apiURL, target, action string) error {
+	switch action {
+	case actionVolUp, actionVolDown:
+		return apiCall(ctx, client, http.MethodPost, apiURL+"/"+target+"/"+action, nil, nil)
+	case actionPair:
+		return apiCall(ctx, client, http.MethodPost, apiURL+"/pair/window", nil, nil)
+	case actionMuteAll:
+		var zones struct {
+			Zones []struct {
+				ID int `json:"id"`
+			} `json:"zones"`
+		}
+		if err := apiCall(ctx, client, http.MethodGet, apiURL+"/zones", nil, &zones); err != nil {
+			return err
+		}
+		ids := make([]int, len(zones.Zones))
+		for i, z := range zones.Zones {
+			ids[i] = z.ID
+		}
+		body := map[string]interface{}{"zones": ids, "update": map[string]bool{"mute": true}}
+		return apiCall(ctx, client, http.MethodPatch, apiURL+"/zones", body, nil)
+	}
+
+	var cur struct {
+		Mute     *bool `json:"mute"`
+		SourceID *int  `json:"source_id"`
+	}
+	if err := apiCall(ctx, client, http.MethodGet, apiURL+"/"+target, nil, &cur); err != nil {
+		return err
+	}
+	switch action {
+	case actionMute:
+		muted := cur.Mute != nil && *cur.Mute
+		return apiCall(ctx, client, http.MethodPatch, apiURL+"/"+target, map[string]bool{"mute": !muted}, nil)
+	case actionNextSource, actionPrevSource:
+		var sources struct {
+			Sources []struct {
+				ID int `json:"id"`
+			} `json:"sources"`
+		}
+		if err := apiCall(ctx, client, http.MethodGet, apiURL+"/sources", nil, &sources); err != nil {
+			return err
+		}
+		n := len(sources.Sources)
+		if n == 0 {
+			return fmt.Errorf("no sources")
+		}
+		next := 0
+		if cur.SourceID != nil && *cur.SourceID >= 0 {
+			next = *cur.SourceID
+			if action == actionNextSource {
+				next = (next + 1) % n
+			} else {
+				next = (next + n - 1) % n
+			}
+		}
+		return apiCall(ctx, client, http.MethodPatch, apiURL+"/"+target, map[string]int{"source_id": next}, nil)
+	}
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// apiCall sends body as JSON and decodes the response into out, if given.
+func apiCall(ctx context.Context, client *http.Client, method, url string, body, out interface{}) error {
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, &buf)
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("http request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: API returned status %d", method, url, resp.StatusCode)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -22,24 +22,24 @@ import (
 
 // TFT holds the ILI9341 display state.
 type TFT struct {
-	spiDev   spi.Conn
-	dc       gpio.PinOut
+	spiDev    spi.Conn
+	dc        gpio.PinOut
 	backlight gpio.PinOut
-	width    int
-	height   int
-	img      *image.RGBA
+	width     int
+	height    int
+	img       *image.RGBA
 }
 
 const (
 	// ILI9341 commands
-	cmdSWRESET   = 0x01
-	cmdSLPOUT    = 0x11
-	cmdDISPON    = 0x29
-	cmdCASet     = 0x2A
-	cmdPASet     = 0x2B
-	cmdRAMWR     = 0x2C
-	cmdMADCTL    = 0x36
-	cmdPIXFMT    = 0x3A
+	cmdSWRESET = 0x01
+	cmdSLPOUT  = 0x11
+	cmdDISPON  = 0x29
+	cmdCASet   = 0x2A
+	cmdPASet   = 0x2B
+	cmdRAMWR   = 0x2C
+	cmdMADCTL  = 0x36
+	cmdPIXFMT  = 0x3A
 
 	// Display size
 	displayWidth  = 320
@@ -169,6 +169,27 @@ func (t *TFT) init() error {
 	return nil
 }
 
+// backlightPWM is the PWM frequency used to dim the backlight.
+const backlightPWM = 1 * physic.KiloHertz
+
+// SetBacklight sets the backlight brightness from 0 (off) to 1 (full).
+// GPIO12 is a hardware PWM pin; partial levels fall back to full
+// brightness where PWM is unavailable.
+func (t *TFT) SetBacklight(level float64) error {
+	switch {
+	case level <= 0:
+		return t.backlight.Out(gpio.Low)
+	case level >= 1:
+		return t.backlight.Out(gpio.High)
+	}
+	duty := gpio.Duty(level * float64(gpio.DutyMax))
+	if err := t.backlight.PWM(duty, backlightPWM); err != nil {
+		slog.Debug("backlight PWM unavailable, using full brightness", "err", err)
+		return t.backlight.Out(gpio.High)
+	}
+	return nil
+}
+
 // writeCommand writes a command and optional data bytes to the display.
 func (t *TFT) writeCommand(cmd byte, data ...byte) error {
 	// DC low = command
@@ -260,7 +281,7 @@ func (t *TFT) Display() error {
 			b8 := uint8(b >> 8)
 
 			// Convert to RGB565 format (5 bits red, 6 bits green, 5 bits blue)
-			rgb565 := uint16((r8&0xF8)<<8) | uint16((g8&0xFC)<<3) | uint16(b8>>3)
+			rgb565 := uint16(r8&0xF8)<<8 | uint16(g8&0xFC)<<3 | uint16(b8>>3)
 
 			// Big-endian (MSB first) - matches Python ">H" format
 			buf[i] = byte(rgb565 >> 8)
@@ -328,92 +349,95 @@ func (t *TFT) RenderStatus(status *Status) error {
 
 	// TODO: Remove test pattern code above and uncomment below when working
 	/*
-	// Clear to black
-	t.Clear(color.Black)
-
-	// Define colors
-	white := color.RGBA{255, 255, 255, 255}
-	yellow := color.RGBA{255, 255, 0, 255}
-	green := color.RGBA{0, 255, 0, 255}
-	lightGray := color.RGBA{153, 153, 153, 255}
-
-	// Character dimensions (7x13 font)
-	const cw = 7
-	const ch = 13
-
-	// Line 1: Disk usage
-	diskColor := gradientColor(status.DiskPercent)
-	t.DrawText(1*cw, 1*ch+2, "Disk:", white)
-	t.DrawText(7*cw, 1*ch+2, fmt.Sprintf("%.1f%%", status.DiskPercent), diskColor)
-	t.DrawText(14*cw, 1*ch+2, fmt.Sprintf("%.2f/%.2f GB", status.DiskUsedGB, status.DiskTotalGB), diskColor)
-
-	// Line 2: IP address
-	ipStr := fmt.Sprintf("%s, %s.local", status.IP, status.Hostname)
-	t.DrawText(1*cw, 2*ch+2, fmt.Sprintf("IP:   %s", ipStr), white)
-
-	// Line 3: Password
-	passColor := yellow // Default password = yellow
-	t.DrawText(1*cw, 3*ch+2, "Password: ", white)
-	t.DrawText(11*cw, 3*ch+2, status.Password, passColor)
-
-	// Line 0 (status): Zone/source emoji status
-	playing := 0
-	muted := 0
-	for _, z := range status.Zones {
-		if !z.Mute {
-			playing++
-		} else {
-			muted++
+		// Clear to black
+		t.Clear(color.Black)
+
+		// Define colors
+		white := color.RGBA{255, 255, 255, 255}
+		yellow := color.RGBA{255, 255, 0, 255}
+		green := color.RGBA{0, 255, 0, 255}
+		lightGray := color.RGBA{153, 153, 153, 255}
+
+		// Character dimensions (7x13 font)
+		const cw = 7
+		const ch = 13
+
+		// Line 1: Disk usage
+		diskColor := gradientColor(status.DiskPercent)
+		t.DrawText(1*cw, 1*ch+2, "Disk:", white)
+		t.DrawText(7*cw, 1*ch+2, fmt.Sprintf("%.1f%%", status.DiskPercent), diskColor)
+		t.DrawText(14*cw, 1*ch+2, fmt.Sprintf("%.2f/%.2f GB", status.DiskUsedGB, status.DiskTotalGB), diskColor)
+
+		// Line 2: IP address
+		ipStr := fmt.Sprintf("%s, %s.local", status.IP, status.Hostname)
+		t.DrawText(1*cw, 2*ch+2, fmt.Sprintf("IP:   %s", ipStr), white)
+
+		// Line 3: Password
+		passColor := yellow // Default password = yellow
+		if status.PassChanged {
+			passColor = lightGray
 		}
-	}
-	statusStr := fmt.Sprintf("Status: ▶x%d ⏸x%d", playing, muted)
-	t.DrawText(1*cw, 0*ch+2, statusStr, white)
-
-	// Expander count (if > 0)
-	if status.Expanders > 0 {
-		t.DrawText(22*cw, 0*ch+2, fmt.Sprintf("Expanders: %d", status.Expanders), white)
-	}
-
-	// Source labels and playing indicators
-	ys := 4*ch + ch/2
-
-	// Draw top separator line
-	t.DrawHLine(cw, t.width-2*cw, ys-3, 2, lightGray)
+		t.DrawText(1*cw, 3*ch+2, "Password: ", white)
+		t.DrawText(11*cw, 3*ch+2, displayPassword(status), passColor)
+
+		// Line 0 (status): Zone/source emoji status
+		playing := 0
+		muted := 0
+		for _, z := range status.Zones {
+			if !z.Mute {
+				playing++
+			} else {
+				muted++
+			}
+		}
+		statusStr := fmt.Sprintf("Status: ▶x%d ⏸x%d", playing, muted)
+		t.DrawText(1*cw, 0*ch+2, statusStr, white)
 
-	// Source 1-4 labels and playing indicators
-	sources := []string{"Source 1:", "Source 2:", "Source 3:", "Source 4:"}
-	for i := 0; i < 4 && i < len(sources); i++ {
-		t.DrawText(1*cw, int(float64(ys)+float64(i)*1.1*float64(ch)), sources[i], white)
+		// Expander count (if > 0)
+		if status.Expanders > 0 {
+			t.DrawText(22*cw, 0*ch+2, fmt.Sprintf("Expanders: %d", status.Expanders), white)
+		}
 
-		// Draw source name and playing indicator if available
-		if i < len(status.Sources) {
-			src := status.Sources[i]
-			// Playing indicator (green triangle)
-			if src.Playing {
-				xp := 10*cw - cw/2
-				yp := ys + i*ch + 3
-				t.DrawTriangle(xp, yp, cw-3, ch, green)
-			}
-			// Source name
-			if src.Name != "" {
-				t.DrawText(11*cw, ys+i*ch, src.Name, yellow)
+		// Source labels and playing indicators
+		ys := 4*ch + ch/2
+
+		// Draw top separator line
+		t.DrawHLine(cw, t.width-2*cw, ys-3, 2, lightGray)
+
+		// Source 1-4 labels and playing indicators
+		sources := []string{"Source 1:", "Source 2:", "Source 3:", "Source 4:"}
+		for i := 0; i < 4 && i < len(sources); i++ {
+			t.DrawText(1*cw, int(float64(ys)+float64(i)*1.1*float64(ch)), sources[i], white)
+
+			// Draw source name and playing indicator if available
+			if i < len(status.Sources) {
+				src := status.Sources[i]
+				// Playing indicator (green triangle)
+				if src.Playing {
+					xp := 10*cw - cw/2
+					yp := ys + i*ch + 3
+					t.DrawTriangle(xp, yp, cw-3, ch, green)
+				}
+				// Source name
+				if src.Name != "" {
+					t.DrawText(11*cw, ys+i*ch, src.Name, yellow)
+				}
 			}
 		}
-	}
 
-	// Draw bottom separator line
-	t.DrawHLine(cw, t.width-2*cw, ys+4*ch+2, 2, lightGray)
+		// Draw bottom separator line
+		t.DrawHLine(cw, t.width-2*cw, ys+4*ch+2, 2, lightGray)
 
-	// Volume bars for zones (below source section)
-	t.DrawVolumeBars(status.Zones, cw, 9*ch-2, t.width-2*cw, t.height-9*ch)
+		// Volume bars for zones (below source section)
+		t.DrawVolumeBars(status.Zones, cw, 9*ch-2, t.width-2*cw, t.height-9*ch)
 
-	// Display the buffer
-	if err := t.Display(); err != nil {
-		return err
-	}
+		// Display the buffer
+		if err := t.Display(); err != nil {
+			return err
+		}
 
-	slog.Debug("TFT display render complete")
-	return nil
+		slog.Debug("TFT display render complete")
+		return nil
 	*/
 }
 
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// listenAddr is one --addr: where to serve the API and who must sign in.
+type listenAddr struct {
+	Addr    string
+	Trusted bool // auth=none: requests skip authentication, as on the Unix socket
+}
+
+// network returns "tcp4" or "tcp6" for addresses of one IP family, so
+// "0.0.0.0:80" and "[::]:80" can be listened on together, and "tcp"
+// (dual-stack) otherwise.
+func (l listenAddr) network() string {
+	host, _, _ := net.SplitHostPort(l.Addr)
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return "tcp"
+	case ip.To4() != nil:
+		return "tcp4"
+	}
+	return "tcp6"
+}
+
+// loopback reports whether only local clients can reach the address.
+func (l listenAddr) loopback() bool {
+	host, _, _ := net.SplitHostPort(l.Addr)
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (l listenAddr) port() int {
+	_, p, _ := net.SplitHostPort(l.Addr)
+	n, _ := strconv.Atoi(p)
+	return n
+}
+
+// listenFlag collects repeated --addr flags:
+// "ADDR" or "ADDR,auth=none", e.g. "[::]:80" or "127.0.0.1:8081,auth=none".
+type listenFlag []listenAddr
+
+func (f *listenFlag) String() string {
+	var s []string
+	for _, l := range *f {
+		v := l.Addr
+		if l.Trusted {
+			v += ",auth=none"
+		}
+		s = append(s, v)
+	}
+	return strings.Join(s, " ")
+}
+
+func (f *listenFlag) Set(v string) error {
+	addr, opts, _ := strings.Cut(v, ",")
+	l := listenAddr{Addr: addr}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return err
+	}
+	if opts != "" {
+		switch opts {
+		case "auth=none":
+			l.Trusted = true
+		case "auth=required":
+		default:
+			return fmt.Errorf("unknown option %q; want auth=none or auth=required", opts)
+		}
+	}
+	if l.Trusted && !l.loopback() {
+		return fmt.Errorf("auth=none is only allowed on loopback addresses, e.g. 127.0.0.1:8081")
+	}
+	*f = append(*f, l)
+	return nil
+}
+
+// advertisedPort returns the port to advertise to the LAN, over mDNS, Cast
+// and the Hue bridge: that of the first listener reachable from the
+// network, or 80.
+func advertisedPort(addrs []listenAddr) int {
+	for _, l := range addrs {
+		if !l.loopback() && l.port() != 0 {
+			return l.port()
+		}
+	}
+	return 80
+}
+
+// lanHosts returns the hosts of the listeners on that port reachable
+// from the network, for zeroconf to pick the interfaces to advertise on.
+func lanHosts(addrs []listenAddr, port int) []string {
+	var hosts []string
+	for _, l := range addrs {
+		if !l.loopback() && l.port() == port {
+			host, _, _ := net.SplitHostPort(l.Addr)
+			hosts = append(hosts, host)
+		}
+	}
+	return hosts
+}
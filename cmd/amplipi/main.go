@@ -4,10 +4,14 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"embed"
+	"errors"
 	"flag"
+	"fmt"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -15,19 +19,31 @@ import (
 	"syscall"
 	"time"
 
-	"strconv"
 	"strings"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/micro-nova/amplipi-go/internal/api"
+	"github.com/micro-nova/amplipi-go/internal/audio"
 	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/bridge"
+	"github.com/micro-nova/amplipi-go/internal/cast"
+	"github.com/micro-nova/amplipi-go/internal/clips"
 	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/controller"
 	"github.com/micro-nova/amplipi-go/internal/events"
 	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/identity"
+	"github.com/micro-nova/amplipi-go/internal/jsonrpc"
+	"github.com/micro-nova/amplipi-go/internal/keypad"
+	"github.com/micro-nova/amplipi-go/internal/logs"
 	"github.com/micro-nova/amplipi-go/internal/maintenance"
 	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/shares"
+	"github.com/micro-nova/amplipi-go/internal/snapcast"
 	"github.com/micro-nova/amplipi-go/internal/streams"
+	"github.com/micro-nova/amplipi-go/internal/telnet"
+	"github.com/micro-nova/amplipi-go/internal/tlscert"
+	"github.com/micro-nova/amplipi-go/internal/webhooks"
 	"github.com/micro-nova/amplipi-go/internal/zeroconf"
 )
 
@@ -63,19 +79,53 @@ func spaHandler(fsys fs.FS) http.Handler {
 
 func main() {
 	var (
-		mock   = flag.Bool("mock", false, "use mock hardware driver (no I2C device required)")
-		addr   = flag.String("addr", ":80", "HTTP listen address")
-		cfgDir = flag.String("config-dir", "", "config directory (default: ~/.config/amplipi)")
-		debug  = flag.Bool("debug", false, "enable debug logging")
+		mock     = flag.Bool("mock", false, "use mock hardware driver (no I2C device required)")
+		cfgDir   = flag.String("config-dir", "", "config directory (default: ~/.config/amplipi)")
+		debug    = flag.Bool("debug", false, "enable debug logging")
+		asound   = flag.String("asound-conf", "", "write the generated ALSA config to this path at startup (e.g. /etc/asound.conf)")
+		media    = flag.String("media-dir", "", "music library browsed by the file player (default: ~/Music)")
+		units    = flag.Int("mock-units", 1, "number of preamp units (main + expanders) the mock driver simulates")
+		demo     = flag.String("demo-audio", "", "with --mock, streams play demo audio to the ALSA default device instead of running their players: \"tone\" for a tone per source, or a sample file to loop")
+		scenFile = flag.String("mock-scenario", "", "play this JSON scenario of temperature rises, power rail and fan failures and I2C NACKs on the mock driver once the server is up")
+		pairBoot = flag.Duration("pair-after-boot", 0, "let mobile apps pair without confirmation at the unit for this long after startup, e.g. 2m on first setup (0 = only after the pair button is pressed)")
+		graphQL  = flag.Bool("graphql", false, "serve GraphQL queries and subscriptions of zones, sources, streams, groups and presets at /api/graphql")
+		rpcAddr  = flag.String("jsonrpc-addr", "", "also serve JSON-RPC over TCP on this address (e.g. :5555), a persistent control channel for control processors such as Control4 and Crestron")
+		telAddr  = flag.String("telnet-addr", "", "also serve the line-based control protocol (e.g. \"ZONE 3 VOL -40\") on this TCP address (e.g. :23), for AV control systems sending ASCII strings")
+		socket   = flag.String("socket", "", "also serve the API on this Unix socket, without authentication; access is controlled by the socket's permissions (e.g. /run/amplipi/api.sock)")
+		testBins = flag.String("test-binaries", "", "run streams with the fake binaries in this directory instead of pianobar, vlc, go-librespot and the rest (build with: go build -o <dir>/fakebin ./internal/streams/testdata/fakebin)")
+		selfTest = flag.Bool("self-test", true, "check the preamp units, power rails, ALSA loopback cards and stream binaries at startup; results are shown in /api/info")
+
+		tlsAddr     = flag.String("tls-addr", "", "also serve HTTPS on this address (e.g. :443), with a self-signed certificate unless --tls-cert or --acme-domains is given")
+		tlsCert     = flag.String("tls-cert", "", "HTTPS certificate chain (PEM); reloaded when it changes")
+		tlsKey      = flag.String("tls-key", "", "private key (PEM) of --tls-cert")
+		acmeDomains = flag.String("acme-domains", "", "comma-separated public hostnames to get an HTTPS certificate for from an ACME CA such as Let's Encrypt")
+		acmeEmail   = flag.String("acme-email", "", "contact email for the ACME account")
+		acmeDir     = flag.String("acme-directory", tlscert.LetsEncrypt, "ACME CA directory URL")
+		acmeDNSHook = flag.String("acme-dns-hook", "", "prove control of --acme-domains with DNS challenges: run as '<hook> present|cleanup <fqdn> <value>' to add and remove the TXT record; without it, HTTP challenges are answered on --addr, which must be reachable on port 80")
 	)
+	var addrs listenFlag
+	flag.Var(&addrs, "addr", "HTTP listen address, repeatable (default :80); append ',auth=none' to serve a loopback address without authentication, e.g. 127.0.0.1:8081,auth=none")
+	limits := api.DefaultLimits
+	flag.Float64Var(&limits.Rate, "rate-limit", limits.Rate, "API requests per second allowed per client IP (0 = unlimited); loopback clients are not limited")
+	flag.IntVar(&limits.Burst, "rate-burst", limits.Burst, "API requests a client IP may send at once")
+	flag.IntVar(&limits.LoginPerMinute, "login-rate-limit", limits.LoginPerMinute, "login and pairing attempts per minute per client IP (0 = unlimited)")
+	flag.Int64Var(&limits.MaxBody, "max-body", limits.MaxBody, "largest API request body in bytes (0 = unlimited)")
+	flag.Int64Var(&limits.MaxUpload, "max-upload", limits.MaxUpload, "largest config upload (/api/load, /api/import), backup restore or announcement clip in bytes")
 	flag.Parse()
+	api.SetLimits(limits)
+	api.EnableGraphQL(*graphQL)
+	if len(addrs) == 0 {
+		addrs = listenFlag{{Addr: ":80"}}
+	}
 
 	// Configure logging
 	logLevel := slog.LevelInfo
 	if *debug {
 		logLevel = slog.LevelDebug
 	}
-	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel})))
+	// Records are also kept in memory for the /api/logs endpoints.
+	logBuf := logs.NewBuffer(logs.DefaultSize)
+	slog.SetDefault(slog.New(logBuf.Handler(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))))
 
 	// Resolve config directory
 	if *cfgDir == "" {
@@ -91,15 +141,85 @@ func main() {
 		os.Exit(1)
 	}
 
+	lock, err := lockConfigDir(*cfgDir)
+	if err != nil {
+		slog.Error("cannot start", "err", err)
+		os.Exit(1)
+	}
+	defer lock.Close()
+
+	// Home Assistant entity IDs follow the unit ID, not the hostname.
+	unitID, err := identity.UnitID(*cfgDir)
+	if err != nil {
+		slog.Error("cannot read the unit id", "err", err)
+		os.Exit(1)
+	}
+	api.SetUnitID(unitID)
+
+	// Take the listen addresses before touching the hardware, so a port
+	// conflict fails startup with the reason instead of leaving a daemon
+	// nobody can reach.
+	listeners := make([]net.Listener, len(addrs))
+	for i, l := range addrs {
+		if listeners[i], err = listen(l.network(), l.Addr); err != nil {
+			slog.Error("cannot start", "err", err)
+			os.Exit(1)
+		}
+	}
+	var tlsLn net.Listener
+	if *tlsAddr != "" {
+		if tlsLn, err = listen("tcp", *tlsAddr); err != nil {
+			slog.Error("cannot start", "err", err)
+			os.Exit(1)
+		}
+	}
+	var rpcLn net.Listener
+	if *rpcAddr != "" {
+		if rpcLn, err = listen("tcp", *rpcAddr); err != nil {
+			slog.Error("cannot start", "err", err)
+			os.Exit(1)
+		}
+	}
+	var telLn net.Listener
+	if *telAddr != "" {
+		if telLn, err = listen("tcp", *telAddr); err != nil {
+			slog.Error("cannot start", "err", err)
+			os.Exit(1)
+		}
+	}
+
+	if *media == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			*media = filepath.Join(home, "Music")
+		}
+	}
+
 	// Graceful shutdown context
 	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer cancel()
 
 	// Hardware driver
 	var hw hardware.Driver
+	var mockHW *hardware.Mock
+	var scenario *hardware.Scenario
+	if *scenFile != "" {
+		if !*mock {
+			slog.Error("--mock-scenario needs --mock")
+			os.Exit(1)
+		}
+		if scenario, err = hardware.LoadScenario(*scenFile); err != nil {
+			slog.Error("loading mock scenario", "err", err)
+			os.Exit(1)
+		}
+	}
 	if *mock {
-		slog.Info("using mock hardware driver")
-		hw = hardware.NewMock()
+		slog.Info("using mock hardware driver", "units", *units)
+		mockUnits := make([]int, max(1, min(*units, hardware.MaxUnits)))
+		for i := range mockUnits {
+			mockUnits[i] = i
+		}
+		mockHW = hardware.NewMockWithUnits(mockUnits)
+		hw = mockHW
 	} else {
 		slog.Info("using real I2C hardware driver")
 		hw = hardware.NewI2C()
@@ -111,12 +231,44 @@ func main() {
 		}
 	}
 
+	// Fake stream binaries go first on PATH before detection, so the
+	// profile reports the streams they stand in for as available.
+	if *testBins != "" {
+		if err := streams.UseTestBinaries(*testBins); err != nil {
+			slog.Error("test binaries", "err", err)
+			os.Exit(1)
+		}
+		slog.Warn("streams run fake test binaries", "dir", *testBins)
+	}
+
+	// Stream plugins register their types before detection so the profile
+	// knows whether their executables are installed.
+	pluginDir := filepath.Join(*cfgDir, "plugins")
+	pluginTypes, err := streams.LoadPlugins(pluginDir)
+	if err != nil {
+		slog.Warn("some stream plugins could not be loaded", "dir", pluginDir, "err", err)
+	}
+	if len(pluginTypes) > 0 {
+		slog.Info("stream plugins loaded", "types", pluginTypes)
+	}
+
 	// Hardware profile detection
 	profile, err := hardware.Detect(ctx, hw)
 	if err != nil {
 		slog.Warn("hardware detection failed, using mock defaults", "err", err)
 		profile = hardware.MockProfile()
 	}
+	// An override forces what detection got wrong, e.g. on units with
+	// unprogrammed EEPROMs. A bad override is ignored rather than keeping
+	// the unit from starting.
+	if override, err := config.LoadProfileOverride(*cfgDir); err != nil {
+		slog.Error("hardware profile override ignored", "err", err)
+	} else if !override.IsZero() {
+		profile.ApplyOverride(override)
+		// Every loop over the driver's units then covers the forced ones.
+		hw.SetUnits(profile.UnitIndices())
+		slog.Warn("hardware profile overridden", "file", config.ProfileOverrideFileName)
+	}
 	slog.Info("hardware profile",
 		"units", len(profile.Units),
 		"zones", profile.TotalZones,
@@ -127,8 +279,16 @@ func main() {
 	)
 	slog.Info("stream capabilities", "available", profile.AvailableStreamTypes())
 
-	// Config store
+	// Config store. Without a saved config this is the unit's first boot,
+	// and an installer's provision.json sets up its initial configuration.
 	store := config.NewJSONStore(*cfgDir)
+	_, statErr := os.Stat(store.Path())
+	firstBoot := errors.Is(statErr, os.ErrNotExist)
+	provision, err := config.LoadProvision(*cfgDir)
+	if err != nil {
+		slog.Error("invalid provisioning template", "err", err)
+		os.Exit(1)
+	}
 
 	// Event bus
 	bus := events.NewBus()
@@ -141,8 +301,67 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Configure physical outputs availability from hardware profile
-	streams.SetAvailablePhysicalOutputs(profile.AvailablePhysicalOutputs)
+	// ALSA layout: default reference topology unless audio.json overrides it
+	layout, err := audio.LoadLayout(*cfgDir)
+	if err != nil {
+		slog.Error("invalid audio layout", "err", err)
+		os.Exit(1)
+	}
+	if err := checkLoopbacks(layout); err != nil {
+		slog.Error("cannot start", "err", err)
+		os.Exit(1)
+	}
+	// Streams play through the loopback cards and the PCMs asound.conf
+	// defines on them, which the installer sets up as root. Without them the
+	// unit runs degraded: zones and the RCA inputs work, streams are
+	// unavailable with the reason.
+	if !*mock {
+		problems := layout.LoopbackProblems()
+		if *asound == "" {
+			conf, _ := os.ReadFile(audio.AsoundConfPath)
+			if missing := layout.MissingPCMs(string(conf)); len(missing) > 0 {
+				problems = append(problems, fmt.Sprintf("%s lacks the PCMs %s", audio.AsoundConfPath, strings.Join(missing, ", ")))
+			}
+		}
+		if len(problems) > 0 {
+			reason := strings.Join(problems, "; ") + "; re-run setup.sh to configure the audio stack"
+			slog.Error("audio stack not configured, streams are unavailable", "err", reason)
+			profile.DisableStreams("audio stack not configured: " + reason)
+		}
+	}
+	if *asound != "" {
+		if changed, err := layout.WriteAsoundConf(*asound); err != nil {
+			slog.Error("cannot write ALSA config", "path", *asound, "err", err)
+		} else if changed {
+			slog.Info("ALSA config written", "path", *asound)
+		}
+	}
+	streams.SetAudioLayout(layout)
+	streams.SetMediaDir(*media)
+	if *demo != "" {
+		if !*mock {
+			slog.Error("--demo-audio needs --mock")
+			os.Exit(1)
+		}
+		streams.SetDemoAudio(*demo)
+	}
+
+	// Configure physical outputs availability from hardware profile, or from
+	// the layout when the running system's ALSA cards can be inspected.
+	physOutputs := profile.AvailablePhysicalOutputs
+	if cards, err := audio.ReadCards(); err == nil && !*mock {
+		report := layout.Validate(cards)
+		for _, e := range report.Errors {
+			slog.Error("audio layout", "problem", e)
+		}
+		for _, w := range report.Warnings {
+			slog.Warn("audio layout", "problem", w)
+		}
+		if len(report.Available) > 0 {
+			physOutputs = report.Available
+		}
+	}
+	streams.SetAvailablePhysicalOutputs(physOutputs)
 
 	// ctrlRef is used by the stream metadata callback to forward updates.
 	// It is set after controller creation; callbacks only fire during stream
@@ -161,6 +380,62 @@ func main() {
 		os.Exit(1)
 	}
 	ctrlRef = ctrl // safe: controller is initialized before any stream callbacks fire
+	streamMgr.ReportStatus()
+	if provision != nil {
+		ctrl.SetProvisionTemplate(provision)
+		if firstBoot {
+			if _, appErr := ctrl.Provision(ctx, *provision); appErr != nil {
+				slog.Error("provisioning failed", "file", config.ProvisionFileName, "err", appErr.Message)
+			} else {
+				slog.Info("provisioned from template", "file", config.ProvisionFileName)
+			}
+		}
+	}
+
+	// Physical outputs: editable via /api/outputs, with USB DAC hotplug
+	// detection on real hardware. Changes re-route the streams connected
+	// to the outputs affected.
+	var outputs *audio.Outputs
+	outputs = audio.NewOutputs(layout, *cfgDir, func(available, changed []int) {
+		streams.SetAudioLayout(outputs.Layout())
+		if *mock {
+			available = nil
+		}
+		streamMgr.ReconnectOutputs(ctx, available, changed)
+	})
+	if *asound != "" {
+		outputs.SetAsoundConfPath(*asound)
+	}
+	ctrl.SetAudioOutputs(outputs)
+	if !*mock {
+		go outputs.Watch(ctx, 2*time.Second)
+	}
+
+	// Network shares: SMB/NFS shares mounted into the media library. Mounts
+	// survive daemon restarts; already mounted shares are left alone.
+	shareMgr, err := shares.NewManager(*cfgDir, streams.MediaDir)
+	if err != nil {
+		slog.Error("invalid network share config", "err", err)
+		os.Exit(1)
+	}
+	ctrl.SetShares(shareMgr)
+	ctrl.SetLogs(logBuf)
+
+	// Announcement clips uploaded through /api/clips
+	clipLib, err := clips.NewLibrary(*cfgDir)
+	if err != nil {
+		slog.Error("cannot open the clip library", "err", err)
+		os.Exit(1)
+	}
+	ctrl.SetClips(clipLib)
+	ctrl.SetProfileOverrideDir(*cfgDir)
+	if !*mock {
+		go shareMgr.MountAll(ctx)
+	}
+
+	// Snapcast: the stream manager runs snapserver for sources with
+	// snapcast output enabled; the controller drives it over JSON-RPC.
+	ctrl.SetSnapcast(snapcast.NewClient(snapcast.DefaultURL))
 
 	// Auth service
 	authSvc, err := auth.NewService(*cfgDir)
@@ -169,6 +444,9 @@ func main() {
 		os.Exit(1)
 	}
 	defer authSvc.Close()
+	if *pairBoot > 0 {
+		authSvc.OpenPairing(*pairBoot)
+	}
 
 	// Maintenance goroutines (online check, release check, config backups)
 	maint := maintenance.New(*cfgDir,
@@ -177,27 +455,98 @@ func main() {
 		},
 		func(release string) {
 			slog.Info("new release available", "version", release)
+			ctrl.NotifyRelease(release)
 		},
 	)
 	go maint.Start(ctx)
 
 	// Zeroconf mDNS registration
 	hostname, _ := os.Hostname()
-	port := 80
-	if parts := strings.SplitN(*addr, ":", 2); len(parts) == 2 && parts[1] != "" {
-		if p, err := strconv.Atoi(parts[1]); err == nil {
-			port = p
-		}
+	port := advertisedPort(addrs)
+	// Google Cast: discover receivers; sources routed to them are served
+	// as MP3 from this HTTP server.
+	castBrowser := cast.NewBrowser()
+	if err := ctrl.SetCast(castBrowser, port, *cfgDir); err != nil {
+		slog.Error("cannot set up Google Cast", "err", err)
+		os.Exit(1)
 	}
+	if !*mock {
+		go castBrowser.Run(ctx, time.Minute)
+		go ctrl.RestoreCast(ctx, 10*time.Second)
+	}
+
+	// Advertised on the interfaces of the listeners reachable from the LAN
+	// under the friendly name, re-registered when the unit is renamed
 	zc := zeroconf.New(hostname, port)
-	go func() {
-		if err := zc.Start(ctx); err != nil {
-			slog.Warn("zeroconf failed", "err", err)
+	zc.Rename(ctrl.Hostname(), ctrl.FriendlyName())
+	if hosts := lanHosts(addrs, port); len(hosts) == 0 {
+		slog.Info("zeroconf: not advertising, the API only listens on loopback")
+	} else {
+		if err := zc.SetHosts(hosts); err != nil {
+			slog.Warn("zeroconf: advertising on all interfaces", "err", err)
 		}
-	}()
+		go func() {
+			if err := zc.Start(ctx); err != nil {
+				slog.Warn("zeroconf failed", "err", err)
+			}
+		}()
+		go func() {
+			evs := bus.SubscribeEvents("zeroconf")
+			defer bus.UnsubscribeEvents("zeroconf")
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case ev, ok := <-evs:
+					if !ok {
+						return
+					}
+					if c, ok := ev.Data.(models.HostnameChanged); ok {
+						zc.Rename(c.Hostname, c.FriendlyName)
+					}
+				}
+			}
+		}()
+	}
 
 	// Background goroutines
+	if scenario != nil {
+		go mockHW.RunScenario(ctx, scenario)
+	}
+	if *selfTest {
+		go ctrl.RunSelfTest(ctx)
+	}
 	go hardware.RunPiTempSender(ctx, hw)
+	go ctrl.RunAmpPower(ctx, 15*time.Second)
+	go ctrl.RunHealthHistory(ctx, time.Minute)
+	go ctrl.RunInputDetection(ctx, time.Second)
+	go ctrl.RunSourceIdle(ctx, 15*time.Second)
+	go ctrl.RunSilenceWatchdog(ctx, 30*time.Second)
+	go ctrl.RunNightMode(ctx, 15*time.Second)
+	go ctrl.RunLEDActivity(ctx, time.Minute)
+	go ctrl.RunTriggers(ctx, time.Second)
+	go ctrl.RunFanCurve(ctx, 5*time.Second)
+	go streamMgr.RunRecovery(ctx, 30*time.Second)
+
+	// RS-485 wall keypads on the spare UART. Enabled in settings.
+	if !*mock {
+		go keypad.New(ctrl).Run(ctx, bus)
+	}
+
+	// Outbound webhooks, configured through /api/webhooks.
+	go webhooks.New(ctrl).Run(ctx, bus)
+
+	// JSON-RPC control channel for control processors
+	if rpcLn != nil {
+		slog.Info("AmpliPi listening", "addr", *rpcAddr, "jsonrpc", true)
+		go jsonrpc.New(ctrl, bus, authSvc).Serve(ctx, rpcLn)
+	}
+
+	// Line-based control port for AV control systems
+	if telLn != nil {
+		slog.Info("AmpliPi listening", "addr", *telAddr, "telnet", true)
+		go telnet.New(ctrl, bus, authSvc).Serve(ctx, telLn)
+	}
 
 	// HTTP server
 	router := api.NewRouter(ctrl, authSvc, bus)
@@ -210,41 +559,200 @@ func main() {
 	}
 	router.(*chi.Mux).Handle("/*", spaHandler(webFS))
 
-	srv := &http.Server{
-		Addr:         *addr,
-		Handler:      router,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 0, // 0 = no timeout (needed for SSE)
-		IdleTimeout:  120 * time.Second,
+	// Smart-home bridge: a Hue API for voice assistants, which only talk
+	// to port 80, so it shares the API server. Enabled in settings.
+	hueBridge, err := bridge.New(ctrl, port, *cfgDir)
+	if err != nil {
+		slog.Error("cannot set up the smart-home bridge", "err", err)
+		os.Exit(1)
 	}
+	go hueBridge.Run(ctx, bus)
+	for _, pattern := range []string{"/description.xml", "/api/{user}/config", "/api/{user}/lights", "/api/{user}/lights/*"} {
+		router.(*chi.Mux).Handle(pattern, hueBridge.Handler())
+	}
+	router.(*chi.Mux).Post("/api", hueBridge.Handler().ServeHTTP)
 
-	go func() {
-		slog.Info("AmpliPi listening", "addr", *addr, "mock", *mock, "config", *cfgDir)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			slog.Error("server error", "err", err)
+	// HTTPS: the certificate is self-signed, the user's or from ACME
+	var (
+		tlsCfg   *tls.Config
+		acmeCert *tlscert.ACME
+	)
+	if *tlsAddr != "" {
+		tlsCfg, acmeCert, err = httpsConfig(*cfgDir, *tlsCert, *tlsKey, tlscert.ACMEOptions{
+			Domains:   splitList(*acmeDomains),
+			Email:     *acmeEmail,
+			Directory: *acmeDir,
+			CacheDir:  filepath.Join(*cfgDir, "tls", "acme"),
+			DNSHook:   *acmeDNSHook,
+		})
+		if err != nil {
+			slog.Error("HTTPS setup failed", "err", err)
+			os.Exit(1)
 		}
-	}()
+	}
+	var handler http.Handler = router
+	if acmeCert != nil {
+		handler = acmeCert.HTTPHandler(router)
+		go acmeCert.Run(ctx)
+	}
+
+	// One server per --addr; auth=none listeners trust their clients like
+	// the Unix socket does.
+	var servers []*http.Server
+	for i, l := range addrs {
+		ln := listeners[i]
+		srv := &http.Server{
+			Handler:      handler,
+			ReadTimeout:  30 * time.Second,
+			WriteTimeout: 0, // 0 = no timeout (needed for SSE)
+			IdleTimeout:  120 * time.Second,
+		}
+		if l.Trusted {
+			srv.ConnContext = auth.TrustConn
+		}
+		servers = append(servers, srv)
+		go func() {
+			slog.Info("AmpliPi listening", "addr", l.Addr, "auth", !l.Trusted, "mock", *mock, "config", *cfgDir)
+			if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+				slog.Error("server error", "addr", l.Addr, "err", err)
+			}
+		}()
+	}
+
+	var tlsSrv *http.Server
+	if tlsCfg != nil {
+		tlsSrv = &http.Server{
+			Addr:        *tlsAddr,
+			Handler:     router,
+			TLSConfig:   tlsCfg,
+			ReadTimeout: 30 * time.Second,
+			IdleTimeout: 120 * time.Second,
+		}
+		go func() {
+			slog.Info("AmpliPi listening", "addr", *tlsAddr, "tls", true)
+			if err := tlsSrv.ServeTLS(tlsLn, "", ""); err != nil && err != http.ErrServerClosed {
+				slog.Error("HTTPS server error", "err", err)
+			}
+		}()
+	}
+
+	// Local IPC for on-device tools (display driver, CLI): the same API on
+	// a Unix socket, trusted without network auth round-trips.
+	var sockSrv *http.Server
+	if *socket != "" {
+		ln, err := listenUnix(*socket)
+		if err != nil {
+			slog.Error("unix socket listener failed", "socket", *socket, "err", err)
+		} else {
+			sockSrv = &http.Server{
+				Handler:     router,
+				ReadTimeout: 30 * time.Second,
+				IdleTimeout: 120 * time.Second,
+				ConnContext: auth.TrustConn,
+			}
+			go func() {
+				slog.Info("AmpliPi listening", "socket", *socket)
+				if err := sockSrv.Serve(ln); err != nil && err != http.ErrServerClosed {
+					slog.Error("unix socket server error", "err", err)
+				}
+			}()
+		}
+	}
 
 	// Wait for shutdown signal
 	<-ctx.Done()
 	slog.Info("shutting down...")
 
-	// Shutdown stream manager
+	// Turn the amps off and finish queued hardware writes first, so
+	// playing zones ramp down before their streams stop
 	shutCtx, shutCancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer shutCancel()
+	if err := ctrl.Shutdown(shutCtx); err != nil {
+		slog.Warn("hardware writes not flushed", "err", err)
+	}
+
+	// Shutdown stream manager, then flush pending config writes
 	if err := streamMgr.Shutdown(shutCtx); err != nil {
 		slog.Warn("stream manager shutdown error", "err", err)
 	}
-
-	// Flush pending config writes
 	if err := store.Flush(); err != nil {
 		slog.Warn("failed to flush config", "err", err)
 	}
 
 	// Graceful HTTP shutdown
-	if err := srv.Shutdown(shutCtx); err != nil {
-		slog.Warn("server shutdown error", "err", err)
+	for _, srv := range servers {
+		if err := srv.Shutdown(shutCtx); err != nil {
+			slog.Warn("server shutdown error", "err", err)
+		}
+	}
+	if tlsSrv != nil {
+		if err := tlsSrv.Shutdown(shutCtx); err != nil {
+			slog.Warn("HTTPS server shutdown error", "err", err)
+		}
+	}
+	if sockSrv != nil {
+		if err := sockSrv.Shutdown(shutCtx); err != nil {
+			slog.Warn("unix socket server shutdown error", "err", err)
+		}
 	}
 
 	slog.Info("shutdown complete")
 }
+
+// listenUnix listens on a Unix socket readable and writable by the daemon's
+// user and group only, replacing a stale socket from a previous run.
+func listenUnix(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0660); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// httpsConfig returns the HTTPS listener's TLS config: the user's
+// certificate if given, else one from ACME for the ACME domains, falling
+// back to a self-signed certificate for the unit's LAN names. The ACME
+// source is returned so its challenges can be served.
+func httpsConfig(cfgDir, certFile, keyFile string, acmeOpts tlscert.ACMEOptions) (*tls.Config, *tlscert.ACME, error) {
+	if certFile != "" || keyFile != "" {
+		files, err := tlscert.FromFiles(certFile, keyFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		return &tls.Config{GetCertificate: files.GetCertificate}, nil, nil
+	}
+	hostname, _ := os.Hostname()
+	self, err := tlscert.SelfSigned(filepath.Join(cfgDir, "tls"), tlscert.DefaultHosts(hostname))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(acmeOpts.Domains) == 0 {
+		return &tls.Config{Certificates: []tls.Certificate{*self}}, nil, nil
+	}
+	a, err := tlscert.NewACME(acmeOpts, self)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a.TLSConfig(), a, nil
+}
+
+// splitList splits a comma-separated flag value, dropping empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+)
+
+// lockConfigDir takes an exclusive lock on the config directory for the
+// life of the process, so a second daemon, e.g. a mock one started by hand
+// next to the service, cannot corrupt its state. The lock file holds the
+// owner's PID; the kernel releases the lock when the process exits.
+func lockConfigDir(dir string) (*os.File, error) {
+	path := filepath.Join(dir, "amplipi.lock")
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if !errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		owner := "another process"
+		if pid, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(pid))) > 0 {
+			owner = "pid " + strings.TrimSpace(string(pid))
+		}
+		return nil, fmt.Errorf("another AmpliPi (%s) is already using config directory %s; stop it first (e.g. sudo systemctl stop amplipi) or give this one its own --config-dir", owner, dir)
+	}
+	if err := f.Truncate(0); err == nil {
+		f.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+	}
+	return f, nil
+}
+
+// listen opens a listener, explaining the usual reasons it fails.
+func listen(network, addr string) (net.Listener, error) {
+	ln, err := net.Listen(network, addr)
+	if err == nil {
+		return ln, nil
+	}
+	_, port, _ := net.SplitHostPort(addr)
+	switch {
+	case errors.Is(err, syscall.EADDRINUSE):
+		return nil, fmt.Errorf("%s is already in use, probably by another AmpliPi or a web server; find it with \"sudo ss -ltnp 'sport = :%s'\" and stop it, or listen elsewhere with --addr", addr, port)
+	case errors.Is(err, syscall.EACCES):
+		return nil, fmt.Errorf("%s: ports below 1024 need root or CAP_NET_BIND_SERVICE; run as root as amplipi.service does, or listen elsewhere, e.g. --addr :8080", addr)
+	}
+	return nil, err
+}
+
+// checkLoopbacks fails if other processes have the loopback cards open:
+// streams would fail to open them or play into another daemon's sources.
+func checkLoopbacks(layout *audio.Layout) error {
+	users, err := layout.LoopbackUsers()
+	if err != nil || len(users) == 0 {
+		return err
+	}
+	var pids []string
+	seen := make(map[int]bool)
+	for _, u := range users {
+		if !seen[u.PID] {
+			seen[u.PID] = true
+			pids = append(pids, fmt.Sprint(u.PID))
+		}
+	}
+	return fmt.Errorf("ALSA loopback already in use: %s. Another AmpliPi may be running, or these are left over from one that crashed; stop it or run 'kill %s'", users[0], strings.Join(pids, " "))
+}
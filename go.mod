@@ -7,18 +7,20 @@ require (
 	github.com/go-chi/chi/v5 v5.2.5
 	github.com/google/uuid v1.6.0
 	github.com/grandcat/zeroconf v1.0.0
+	github.com/graphql-go/graphql v0.8.1
 	go.bug.st/serial v1.6.4
+	golang.org/x/crypto v0.48.0
+	golang.org/x/image v0.36.0
 	golang.org/x/sys v0.41.0
 	golang.org/x/time v0.14.0
+	periph.io/x/conn/v3 v3.7.2
+	periph.io/x/host/v3 v3.8.5
 )
 
 require (
 	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
 	github.com/creack/goselect v0.1.2 // indirect
 	github.com/miekg/dns v1.1.27 // indirect
-	golang.org/x/crypto v0.48.0 // indirect
-	golang.org/x/image v0.36.0 // indirect
 	golang.org/x/net v0.49.0 // indirect
-	periph.io/x/conn/v3 v3.7.2 // indirect
-	periph.io/x/host/v3 v3.8.5 // indirect
+	golang.org/x/text v0.34.0 // indirect
 )
@@ -1,29 +1,60 @@
 package api_test
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
+	"log/slog"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/micro-nova/amplipi-go/internal/api"
+	"github.com/micro-nova/amplipi-go/internal/audio"
 	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/clips"
 	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/controller"
 	"github.com/micro-nova/amplipi-go/internal/events"
 	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/logs"
+	"github.com/micro-nova/amplipi-go/internal/matter"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
 // newTestServer spins up a full router with mock dependencies.
 func newTestServer(t *testing.T) *httptest.Server {
 	t.Helper()
+	srv, _ := newTestServerCtrl(t)
+	return srv
+}
+
+// newTestServerCtrl is newTestServer that also returns the controller, for
+// tests that wire optional subsystems.
+func newTestServerCtrl(t *testing.T) (*httptest.Server, *controller.Controller) {
+	t.Helper()
+	return newProfiledTestServer(t, nil)
+}
+
+// newProfiledTestServer is newTestServerCtrl with a hardware profile.
+func newProfiledTestServer(t *testing.T, profile *hardware.HardwareProfile) (*httptest.Server, *controller.Controller) {
+	t.Helper()
 
 	hw := hardware.NewMock()
 	if err := hw.Init(context.Background()); err != nil {
@@ -33,7 +64,7 @@ func newTestServer(t *testing.T) *httptest.Server {
 	store := config.NewMemStore()
 	bus := events.NewBus()
 
-	ctrl, err := controller.New(hw, nil, store, bus, nil)
+	ctrl, err := controller.New(hw, profile, store, bus, nil)
 	if err != nil {
 		t.Fatalf("controller.New: %v", err)
 	}
@@ -49,7 +80,7 @@ func newTestServer(t *testing.T) *httptest.Server {
 		srv.Close()
 		authSvc.Close()
 	})
-	return srv
+	return srv, ctrl
 }
 
 // do is a convenience helper for making requests to the test server.
@@ -224,6 +255,47 @@ func TestSetZone_VolClamped(t *testing.T) {
 	}
 }
 
+func TestZoneVolUpDown(t *testing.T) {
+	srv := newTestServer(t)
+	requireStatus(t, do(t, srv, "PATCH", "/api/zones/0", `{"vol":-40}`), http.StatusOK)
+
+	var state models.State
+	resp := do(t, srv, "POST", "/api/zones/0/vol_up", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	if state.Zones[0].Vol != -36 {
+		t.Errorf("after default vol_up vol = %d, want -36", state.Zones[0].Vol)
+	}
+
+	resp = do(t, srv, "POST", "/api/zones/0/vol_down", `{"vol":10}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	if state.Zones[0].Vol != -46 {
+		t.Errorf("after vol_down 10 dB vol = %d, want -46", state.Zones[0].Vol)
+	}
+
+	resp = do(t, srv, "POST", "/api/zones/0/vol_up", `{"vol":-3}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestGroupVolUp(t *testing.T) {
+	srv := newTestServer(t)
+	requireStatus(t, do(t, srv, "PATCH", "/api/zones", `{"zones":[0,1],"update":{"vol":-50}}`), http.StatusOK)
+	resp := do(t, srv, "POST", "/api/group", `{"name":"Keypad","zones":[0,1]}`)
+	requireStatus(t, resp, http.StatusCreated)
+	var state models.State
+	decodeJSON(t, resp, &state)
+	gid := state.Groups[len(state.Groups)-1].ID
+
+	resp = do(t, srv, "POST", fmt.Sprintf("/api/groups/%d/vol_up", gid), `{"vol_f":0.1}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	if state.Zones[0].Vol != -42 || state.Zones[1].Vol != -42 {
+		t.Errorf("zone vols = %d, %d, want -42", state.Zones[0].Vol, state.Zones[1].Vol)
+	}
+}
+
 func TestSetZone_InvalidID(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -396,7 +468,7 @@ func TestDeleteStream(t *testing.T) {
 	srv := newTestServer(t)
 
 	// Create a stream first
-	resp := do(t, srv, "POST", "/api/stream", `{"name":"ToDelete","type":"internet_radio"}`)
+	resp := do(t, srv, "POST", "/api/stream", `{"name":"ToDelete","type":"internet_radio","config":{"url":"http://example.com"}}`)
 	requireStatus(t, resp, http.StatusCreated)
 
 	var createState models.State
@@ -432,6 +504,26 @@ func TestGetStreams(t *testing.T) {
 	requireStatus(t, resp, http.StatusOK)
 }
 
+func TestGetStreamTypes(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "GET", "/api/streams/types", "")
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Types []models.StreamSchema `json:"types"`
+	}
+	decodeJSON(t, resp, &body)
+	for _, sc := range body.Types {
+		if sc.Type == models.StreamTypePandora {
+			if f := sc.Field("password"); f == nil || !f.Secret || !f.Required {
+				t.Errorf("pandora password field = %+v", f)
+			}
+			return
+		}
+	}
+	t.Errorf("pandora missing from %+v", body.Types)
+}
+
 func TestCreatePreset(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -474,6 +566,18 @@ func TestLoadPreset(t *testing.T) {
 	// Load it
 	resp2 := do(t, srv, "POST", fmt.Sprintf("/api/presets/%d/load", pid), "")
 	requireStatus(t, resp2, http.StatusOK)
+
+	var loaded struct {
+		models.State
+		Report models.PresetReport `json:"report"`
+	}
+	decodeJSON(t, resp2, &loaded)
+	if loaded.Report.PresetID != pid || loaded.Report.Items == nil {
+		t.Errorf("report = %+v", loaded.Report)
+	}
+	if len(loaded.Zones) == 0 {
+		t.Error("state missing from load response")
+	}
 }
 
 func TestDeletePreset(t *testing.T) {
@@ -522,14 +626,105 @@ func TestFactoryReset(t *testing.T) {
 	// Modify some state first
 	do(t, srv, "PATCH", "/api/sources/0", `{"input":"local"}`)
 
-	// Factory reset
+	// Factory reset, confirmed with the token the first request returns
 	resp := do(t, srv, "POST", "/api/factory_reset", "")
+	requireStatus(t, resp, http.StatusAccepted)
+	var pending models.FactoryResetResponse
+	decodeJSON(t, resp, &pending)
+	if pending.Scope != models.ResetConfig || pending.Status != "confirm" || pending.Confirm == "" {
+		t.Fatalf("unconfirmed reset = %+v, want a config token", pending)
+	}
+	resp = do(t, srv, "POST", "/api/factory_reset", `{"confirm":"`+pending.Confirm+`"}`)
 	requireStatus(t, resp, http.StatusOK)
 
+	var done models.FactoryResetResponse
+	decodeJSON(t, resp, &done)
+	if done.Status != "reset" || done.State == nil {
+		t.Fatalf("confirmed reset = %+v, want the reset state", done)
+	}
+	if done.State.Sources[0].Input != "" {
+		t.Errorf("after factory reset: sources[0].input = %q, want empty", done.State.Sources[0].Input)
+	}
+
+	// Tokens are single use
+	resp = do(t, srv, "POST", "/api/factory_reset", `{"confirm":"`+pending.Confirm+`"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestFactoryResetScopes(t *testing.T) {
+	srv := newTestServer(t)
+	reset := func(scope string) models.State {
+		t.Helper()
+		resp := do(t, srv, "POST", "/api/factory_reset", `{"scope":"`+scope+`"}`)
+		requireStatus(t, resp, http.StatusAccepted)
+		var pending models.FactoryResetResponse
+		decodeJSON(t, resp, &pending)
+		resp = do(t, srv, "POST", "/api/factory_reset", `{"scope":"`+scope+`","confirm":"`+pending.Confirm+`"}`)
+		requireStatus(t, resp, http.StatusOK)
+		var done models.FactoryResetResponse
+		decodeJSON(t, resp, &done)
+		return *done.State
+	}
+	hasStream := func(state models.State) bool {
+		for _, st := range state.Streams {
+			if st.Name == "Kept Radio" {
+				return true
+			}
+		}
+		return false
+	}
+
+	resp := do(t, srv, "POST", "/api/stream", `{"name":"Kept Radio","type":"internet_radio","config":{"url":"http://example.com"}}`)
+	requireStatus(t, resp, http.StatusCreated)
+	resp.Body.Close()
+	resp = do(t, srv, "PATCH", "/api/zones/0", `{"name":"Den"}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	state := reset(models.ResetAudio)
+	if state.Zones[0].Name == "Den" || !hasStream(state) {
+		t.Errorf("audio reset: zone 0 %q, stream kept %v; want zone reset, stream kept", state.Zones[0].Name, hasStream(state))
+	}
+	state = reset(models.ResetFull)
+	if hasStream(state) {
+		t.Error("full reset kept the stream")
+	}
+
+	// A token only confirms the scope it was issued for
+	resp = do(t, srv, "POST", "/api/factory_reset", `{"scope":"audio"}`)
+	requireStatus(t, resp, http.StatusAccepted)
+	var pending models.FactoryResetResponse
+	decodeJSON(t, resp, &pending)
+	resp = do(t, srv, "POST", "/api/factory_reset", `{"scope":"full","confirm":"`+pending.Confirm+`"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "POST", "/api/factory_reset", `{"scope":"everything"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestProvision(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/provision", `{"zones":[{"id":0,"name":"Lobby","vol_max":-20}],"groups":[{"name":"Public","zones":[0,1]}]}`)
+	requireStatus(t, resp, http.StatusOK)
 	var state models.State
 	decodeJSON(t, resp, &state)
-	if state.Sources[0].Input != "" {
-		t.Errorf("after factory reset: sources[0].input = %q, want empty", state.Sources[0].Input)
+	if state.Zones[0].Name != "Lobby" || state.Zones[0].VolMax != -20 {
+		t.Errorf("zone 0 = %q, vol_max %d; want Lobby, -20", state.Zones[0].Name, state.Zones[0].VolMax)
+	}
+	if len(state.Groups) != 1 || state.Groups[0].Name != "Public" {
+		t.Errorf("groups = %+v, want Public", state.Groups)
+	}
+
+	resp = do(t, srv, "POST", "/api/provision", `{"zones":[{"id":99,"name":"Nowhere"}]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var appErr models.AppError
+	decodeJSON(t, resp, &appErr)
+	if appErr.Field != "zones[0]" {
+		t.Errorf("field = %q, want zones[0]", appErr.Field)
 	}
 }
 
@@ -544,6 +739,142 @@ func TestGetInfo(t *testing.T) {
 	if info.Version == "" {
 		t.Error("GET /api/info: version field is empty")
 	}
+	if len(info.UnitDetails) != 1 || info.UnitDetails[0].Zones != 6 || info.UnitDetails[0].Firmware == "" {
+		t.Errorf("GET /api/info: unit_details = %+v, want the mock's main unit", info.UnitDetails)
+	}
+	if info.UnitDetails[0].Board != nil {
+		t.Errorf("GET /api/info: board = %+v without a hardware profile", info.UnitDetails[0].Board)
+	}
+
+	// With hardware detection, each unit's EEPROM identity is reported.
+	srv, _ = newProfiledTestServer(t, hardware.MockProfile())
+	resp = do(t, srv, "GET", "/api/info", "")
+	requireStatus(t, resp, http.StatusOK)
+	info = models.Info{}
+	decodeJSON(t, resp, &info)
+	if b := info.UnitDetails[0].Board; b == nil || b.Type != "main" || b.Rev != "Rev4.A" || b.EEPROMError != "" {
+		t.Errorf("GET /api/info: board = %+v, want the mock's main unit", b)
+	}
+}
+
+func TestGetUnits(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "GET", "/api/hardware/units", "")
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Units []models.UnitStatus `json:"units"`
+	}
+	decodeJSON(t, resp, &body)
+	if len(body.Units) != 1 || body.Units[0].Type != "main" || body.Units[0].Temps == nil || body.Units[0].Fan == nil {
+		t.Errorf("units = %+v", body.Units)
+	}
+
+	resp = do(t, srv, "GET", "/api/hardware/units/0", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "GET", "/api/hardware/units/3", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func TestHealthAndMetrics(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "GET", "/api/health", "")
+	requireStatus(t, resp, http.StatusOK)
+	var health models.Health
+	decodeJSON(t, resp, &health)
+	if len(health.Units) != 1 || health.Units[0].Estimate == nil || health.Watts <= 0 {
+		t.Fatalf("health = %+v", health)
+	}
+
+	resp = do(t, srv, "GET", "/metrics", "")
+	requireStatus(t, resp, http.StatusOK)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE amplipi_power_watts gauge",
+		fmt.Sprintf(`amplipi_unit_power_watts{unit="0"} %v`, health.Units[0].Estimate.Watts),
+		`amplipi_unit_rail_volts{unit="0",rail="hv1"} 24`,
+		`amplipi_unit_temperature_celsius{unit="0",sensor="amp1"}`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("metrics missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestFanCurve(t *testing.T) {
+	srv, ctrl := newProfiledTestServer(t, hardware.MockProfile())
+
+	resp := do(t, srv, "PATCH", "/api/hardware/fans", `{"curve":[{"temp_c":40,"duty":0.2}]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var appErr models.AppError
+	decodeJSON(t, resp, &appErr)
+	if appErr.Field != "curve" {
+		t.Errorf("field = %q, want curve", appErr.Field)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/hardware/fans", `{"curve":[{"temp_c":40,"duty":0.2},{"temp_c":70,"duty":1}]}`)
+	requireStatus(t, resp, http.StatusOK)
+	var fans models.FanStatus
+	decodeJSON(t, resp, &fans)
+	if !fans.Custom || len(fans.Curve) != 2 || fans.Mode != "pwm" {
+		t.Errorf("fans = %+v", fans)
+	}
+	if got := ctrl.GetSettings().FanCurve; len(got) != 2 {
+		t.Errorf("persisted curve = %+v", got)
+	}
+
+	resp = do(t, srv, "DELETE", "/api/hardware/fans", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &fans)
+	if fans.Custom || len(fans.Curve) != 0 {
+		t.Errorf("after DELETE fans = %+v", fans)
+	}
+}
+
+func TestSetHostname(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "PATCH", "/api/system/hostname", `{"hostname":"AmpliPi Upstairs"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var appErr models.AppError
+	decodeJSON(t, resp, &appErr)
+	if appErr.Field != "hostname" {
+		t.Errorf("field = %q, want hostname", appErr.Field)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/system/hostname", `{"friendly_name":"AmpliPi Upstairs"}`)
+	requireStatus(t, resp, http.StatusOK)
+	var info models.Info
+	decodeJSON(t, resp, &info)
+	if info.FriendlyName != "AmpliPi Upstairs" || info.Hostname == "" {
+		t.Errorf("info = %q, %q", info.Hostname, info.FriendlyName)
+	}
+
+	resp = do(t, srv, "GET", "/api/info", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &info)
+	if info.FriendlyName != "AmpliPi Upstairs" {
+		t.Errorf("GET /api/info friendly_name = %q", info.FriendlyName)
+	}
+}
+
+func TestSetTime_InvalidTimezone(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "PATCH", "/api/system/time", `{"timezone":"Mars/Olympus_Mons"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var appErr models.AppError
+	decodeJSON(t, resp, &appErr)
+	if appErr.Field != "timezone" {
+		t.Errorf("field = %q, want timezone", appErr.Field)
+	}
 }
 
 func TestNotFound_JSON(t *testing.T) {
@@ -630,6 +961,63 @@ func TestSSESubscribe(t *testing.T) {
 	}
 }
 
+func TestCompression(t *testing.T) {
+	srv := newTestServer(t)
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp.Body.Close()
+	requireStatus(t, resp, http.StatusOK)
+	if ce := resp.Header.Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", ce)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var state models.State
+	if err := json.NewDecoder(gz).Decode(&state); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(state.Zones) == 0 {
+		t.Error("decompressed state has no zones")
+	}
+
+	// Without Accept-Encoding the response is plain.
+	req, _ = http.NewRequest(http.MethodGet, srv.URL+"/api", nil)
+	resp2, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	resp2.Body.Close()
+	if ce := resp2.Header.Get("Content-Encoding"); ce != "" {
+		t.Errorf("Content-Encoding = %q without Accept-Encoding", ce)
+	}
+
+	// SSE is never compressed, so events are not held in the encoder.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/subscribe", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp3, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Do: %v", err)
+	}
+	defer resp3.Body.Close()
+	if ce := resp3.Header.Get("Content-Encoding"); ce != "" {
+		t.Errorf("SSE Content-Encoding = %q, want none", ce)
+	}
+	line, err := bufio.NewReader(resp3.Body).ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "data: ") {
+		t.Errorf("first SSE line = %q, %v", line, err)
+	}
+}
+
 func TestSetSource_InvalidJSON(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -710,6 +1098,45 @@ func TestLoadConfig(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_DryRun(t *testing.T) {
+	srv := newTestServer(t)
+
+	state := models.DefaultState()
+	state.Sources[0].Name = "Dry Source"
+	state.Zones[0].Vol = 10
+	state.Zones[1].Name = state.Zones[0].Name
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+
+	for _, path := range []string{"/api/load?dry_run=true", "/api/config/validate"} {
+		resp := do(t, srv, "POST", path, string(data))
+		requireStatus(t, resp, http.StatusOK)
+		var result models.ConfigValidation
+		decodeJSON(t, resp, &result)
+		if result.State.Sources[0].Name != "Dry Source" || result.State.Zones[0].Vol != 0 {
+			t.Errorf("%s: normalized state = %+v", path, result.State.Zones[0])
+		}
+		if len(result.Warnings) != 2 {
+			t.Errorf("%s: warnings = %q, want the clamped volume and duplicate name", path, result.Warnings)
+		}
+	}
+
+	resp := do(t, srv, "GET", "/api", "")
+	var current models.State
+	decodeJSON(t, resp, &current)
+	if current.Sources[0].Name == "Dry Source" {
+		t.Error("dry run applied the config")
+	}
+
+	state.Zones[1].ID = 0
+	data, _ = json.Marshal(state)
+	resp = do(t, srv, "POST", "/api/config/validate", string(data))
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
 func TestGetStream_Valid(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -725,6 +1152,96 @@ func TestGetStream_Invalid(t *testing.T) {
 	requireStatus(t, resp, http.StatusNotFound)
 }
 
+func TestGetStreamImage(t *testing.T) {
+	srv, ctrl := newTestServerCtrl(t)
+	cover := image.NewGray(image.Rect(0, 0, 8, 8))
+	var data bytes.Buffer
+	if err := png.Encode(&data, cover); err != nil {
+		t.Fatal(err)
+	}
+	art := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cover.png" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data.Bytes())
+	}))
+	defer art.Close()
+
+	resp := do(t, srv, "GET", "/api/streams/995/image", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	ctrl.UpdateStreamInfo(995, models.StreamInfo{Name: "Input 1", State: "playing", ImageURL: art.URL + "/cover.png"})
+	resp = do(t, srv, "GET", "/api/streams/995/image?w=4&h=2&fmt=rgb565", "")
+	requireStatus(t, resp, http.StatusOK)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if len(body) != 16 || resp.Header.Get("X-Image-Width") != "4" || resp.Header.Get("X-Image-Height") != "2" {
+		t.Errorf("rgb565: %d bytes, %sx%s; want 16 bytes, 4x2", len(body), resp.Header.Get("X-Image-Width"), resp.Header.Get("X-Image-Height"))
+	}
+
+	resp = do(t, srv, "GET", "/api/streams/995/image", "")
+	requireStatus(t, resp, http.StatusOK)
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(body, data.Bytes()) || resp.Header.Get("Content-Type") != "image/png" {
+		t.Errorf("original: %s, %d bytes; want the PNG as served", resp.Header.Get("Content-Type"), len(body))
+	}
+
+	for _, q := range []string{"w=0", "h=x", "fmt=bmp", "w=5000"} {
+		resp = do(t, srv, "GET", "/api/streams/995/image?"+q, "")
+		requireStatus(t, resp, http.StatusBadRequest)
+		resp.Body.Close()
+	}
+
+	// Artwork the stream points at but that can't be fetched is the
+	// upstream server's fault.
+	ctrl.UpdateStreamInfo(995, models.StreamInfo{Name: "Input 1", State: "playing", ImageURL: art.URL + "/gone.png"})
+	resp = do(t, srv, "GET", "/api/streams/995/image", "")
+	requireStatus(t, resp, http.StatusBadGateway)
+	resp.Body.Close()
+}
+
+func TestPoll(t *testing.T) {
+	srv, ctrl := newTestServerCtrl(t)
+
+	resp := do(t, srv, "GET", "/api/poll", "")
+	var full models.StatePoll
+	decodeJSON(t, resp, &full)
+	if full.Rev == 0 || len(full.Zones) == 0 || full.Info == nil {
+		t.Fatalf("poll without rev = %+v, want the whole state", full)
+	}
+	rev := strconv.FormatInt(full.Rev, 10)
+
+	resp = do(t, srv, "GET", "/api/poll?rev="+rev, "")
+	requireStatus(t, resp, http.StatusNotModified)
+	resp.Body.Close()
+	if resp.Header.Get("X-State-Rev") != rev {
+		t.Errorf("X-State-Rev = %q, want %s", resp.Header.Get("X-State-Rev"), rev)
+	}
+
+	// A waiting poll answers with the change.
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		vol := -25
+		ctrl.SetZone(context.Background(), 0, models.ZoneUpdate{Vol: &vol})
+	}()
+	resp = do(t, srv, "GET", "/api/poll?wait=5&rev="+rev, "")
+	var p models.StatePoll
+	decodeJSON(t, resp, &p)
+	if p.Rev <= full.Rev || len(p.Zones) == 0 || p.Zones[0].Vol != -25 || p.Streams != nil {
+		t.Errorf("waiting poll = %+v, want the changed zones only", p)
+	}
+
+	for _, q := range []string{"rev=x", "rev=-2", "wait=31"} {
+		resp = do(t, srv, "GET", "/api/poll?"+q, "")
+		requireStatus(t, resp, http.StatusBadRequest)
+		resp.Body.Close()
+	}
+}
+
 func TestGetPreset_Valid(t *testing.T) {
 	srv := newTestServer(t)
 
@@ -832,7 +1349,7 @@ func TestPatchStream(t *testing.T) {
 	srv := newTestServer(t)
 
 	// Create a stream
-	resp := do(t, srv, "POST", "/api/stream", `{"name":"PatchStream","type":"internet_radio"}`)
+	resp := do(t, srv, "POST", "/api/stream", `{"name":"PatchStream","type":"internet_radio","config":{"url":"http://example.com"}}`)
 	requireStatus(t, resp, http.StatusCreated)
 
 	var createState models.State
@@ -969,40 +1486,178 @@ func TestLoadPreset_NotFound(t *testing.T) {
 	requireStatus(t, resp, http.StatusNotFound)
 }
 
-func TestSetZones_InvalidJSON(t *testing.T) {
+func TestSnapshots(t *testing.T) {
 	srv := newTestServer(t)
 
-	resp := do(t, srv, "PATCH", "/api/zones", `{bad json}`)
-	requireStatus(t, resp, http.StatusBadRequest)
-}
+	resp := do(t, srv, "POST", "/api/state/snapshot", "")
+	requireStatus(t, resp, http.StatusCreated)
+	var snap models.Snapshot
+	decodeJSON(t, resp, &snap)
+	if snap.ID == "" {
+		t.Fatal("snapshot has no id")
+	}
 
-func TestCreateGroup_InvalidJSON(t *testing.T) {
-	srv := newTestServer(t)
+	resp = do(t, srv, "PATCH", "/api/zones/0", `{"vol":-20}`)
+	requireStatus(t, resp, http.StatusOK)
 
-	resp := do(t, srv, "POST", "/api/group", `{bad json`)
-	requireStatus(t, resp, http.StatusBadRequest)
-}
+	resp = do(t, srv, "POST", "/api/state/restore/"+snap.ID, "")
+	requireStatus(t, resp, http.StatusOK)
+	var restored struct {
+		models.State
+		Report models.PresetReport `json:"report"`
+	}
+	decodeJSON(t, resp, &restored)
+	if restored.Zones[0].Vol == -20 || restored.Report.Skipped != 0 {
+		t.Errorf("restored zone 0 vol %d, report %+v", restored.Zones[0].Vol, restored.Report)
+	}
 
-func TestCreateStream_InvalidJSON(t *testing.T) {
-	srv := newTestServer(t)
+	resp = do(t, srv, "GET", "/api/state/snapshots", "")
+	requireStatus(t, resp, http.StatusOK)
+	var list struct {
+		Snapshots []models.Snapshot `json:"snapshots"`
+	}
+	decodeJSON(t, resp, &list)
+	if len(list.Snapshots) != 1 || list.Snapshots[0].ID != snap.ID {
+		t.Errorf("snapshots = %+v", list.Snapshots)
+	}
 
-	resp := do(t, srv, "POST", "/api/stream", `{bad json`)
-	requireStatus(t, resp, http.StatusBadRequest)
+	resp = do(t, srv, "POST", "/api/state/restore/nope", "")
+	requireStatus(t, resp, http.StatusNotFound)
 }
 
-func TestCreatePreset_InvalidJSON(t *testing.T) {
+func TestUndo(t *testing.T) {
 	srv := newTestServer(t)
 
-	resp := do(t, srv, "POST", "/api/preset", `{bad json`)
-	requireStatus(t, resp, http.StatusBadRequest)
-}
+	resp := do(t, srv, "POST", "/api/undo", "")
+	requireStatus(t, resp, http.StatusConflict)
 
-func TestLoadConfig_InvalidJSON(t *testing.T) {
-	srv := newTestServer(t)
+	resp = do(t, srv, "PATCH", "/api/zones/0", `{"vol":-20}`)
+	requireStatus(t, resp, http.StatusOK)
 
-	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/load", strings.NewReader("{bad json"))
-	if err != nil {
-		t.Fatalf("NewRequest: %v", err)
+	resp = do(t, srv, "POST", "/api/undo", "")
+	requireStatus(t, resp, http.StatusOK)
+	var undone struct {
+		models.State
+		Undone models.Revision `json:"undone"`
+	}
+	decodeJSON(t, resp, &undone)
+	if undone.Zones[0].Vol == -20 || len(undone.Undone.Changes) != 1 || undone.Undone.Changes[0] != "zone 0" {
+		t.Errorf("zone 0 vol %d after undo of %+v", undone.Zones[0].Vol, undone.Undone)
+	}
+}
+
+func TestGraphQL(t *testing.T) {
+	resp := do(t, newTestServer(t), "POST", "/api/graphql", `{"query":"{ zones { id } }"}`)
+	requireStatus(t, resp, http.StatusNotFound)
+
+	api.EnableGraphQL(true)
+	t.Cleanup(func() { api.EnableGraphQL(false) })
+	srv := newTestServer(t)
+
+	resp = do(t, srv, "POST", "/api/graphql", `{"query":"query($id: Int!) { zone(id: $id) { id name vol } sources { id input } streams { id type config } presets { id name state { zones { id vol } } } }","variables":{"id":1}}`)
+	requireStatus(t, resp, http.StatusOK)
+	var res struct {
+		Data struct {
+			Zone    map[string]interface{}   `json:"zone"`
+			Sources []map[string]interface{} `json:"sources"`
+			Streams []map[string]interface{} `json:"streams"`
+		} `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	decodeJSON(t, resp, &res)
+	if len(res.Errors) > 0 {
+		t.Fatalf("errors = %+v", res.Errors)
+	}
+	if len(res.Data.Zone) != 3 || res.Data.Zone["id"] != float64(1) {
+		t.Errorf("zone = %v, want id, name and vol of zone 1", res.Data.Zone)
+	}
+	if len(res.Data.Sources) != 4 || len(res.Data.Sources[0]) != 2 {
+		t.Errorf("sources = %v", res.Data.Sources)
+	}
+
+	resp = do(t, srv, "GET", "/api/graphql?query="+url.QueryEscape("{ zone(id: 99) { id } }"), "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &res)
+	if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, "not found") {
+		t.Errorf("errors = %+v, want zone not found", res.Errors)
+	}
+
+	// A subscription streams the fields after every change.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, srv.URL+"/api/graphql", strings.NewReader(`{"query":"subscription { zones { id vol } }"}`))
+	sub, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Body.Close()
+	if ct := sub.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	sc := bufio.NewScanner(sub.Body)
+	next := func() []map[string]interface{} {
+		t.Helper()
+		for sc.Scan() {
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				var ev struct {
+					Data struct {
+						Zones []map[string]interface{} `json:"zones"`
+					} `json:"data"`
+				}
+				if err := json.Unmarshal([]byte(data), &ev); err != nil {
+					t.Fatal(err)
+				}
+				return ev.Data.Zones
+			}
+		}
+		t.Fatal("subscription ended")
+		return nil
+	}
+	if zones := next(); len(zones) == 0 {
+		t.Fatal("no zones in first result")
+	}
+	requireStatus(t, do(t, srv, "PATCH", "/api/zones/0", `{"vol":-33}`), http.StatusOK)
+	if zones := next(); zones[0]["vol"] != float64(-33) {
+		t.Errorf("zone 0 after change = %v, want vol -33", zones[0])
+	}
+}
+
+func TestSetZones_InvalidJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "PATCH", "/api/zones", `{bad json}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestCreateGroup_InvalidJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/group", `{bad json`)
+	requireStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestCreateStream_InvalidJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/stream", `{bad json`)
+	requireStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestCreatePreset_InvalidJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/preset", `{bad json`)
+	requireStatus(t, resp, http.StatusBadRequest)
+}
+
+func TestLoadConfig_InvalidJSON(t *testing.T) {
+	srv := newTestServer(t)
+
+	req, err := http.NewRequest(http.MethodPost, srv.URL+"/api/load", strings.NewReader("{bad json"))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	resp, err := srv.Client().Do(req)
@@ -1049,7 +1704,7 @@ func TestSetPreset_InvalidJSON(t *testing.T) {
 func TestSetStream_InvalidJSON(t *testing.T) {
 	srv := newTestServer(t)
 
-	resp := do(t, srv, "POST", "/api/stream", `{"name":"Test","type":"internet_radio"}`)
+	resp := do(t, srv, "POST", "/api/stream", `{"name":"Test","type":"internet_radio","config":{"url":"http://example.com"}}`)
 	requireStatus(t, resp, http.StatusCreated)
 
 	var createState models.State
@@ -1069,7 +1724,7 @@ func TestExecStreamCmd(t *testing.T) {
 	srv := newTestServer(t)
 
 	// Create a stream first
-	resp := do(t, srv, "POST", "/api/stream", `{"name":"CmdStream","type":"internet_radio"}`)
+	resp := do(t, srv, "POST", "/api/stream", `{"name":"CmdStream","type":"internet_radio","config":{"url":"http://example.com"}}`)
 	requireStatus(t, resp, http.StatusCreated)
 
 	var createState models.State
@@ -1089,3 +1744,1269 @@ func TestExecStreamCmd(t *testing.T) {
 	resp2 := do(t, srv, "POST", fmt.Sprintf("/api/streams/%d/play", sid), "")
 	requireStatus(t, resp2, http.StatusOK)
 }
+
+func TestOutputs_NotConfigured(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "GET", "/api/outputs", "")
+	requireStatus(t, resp, http.StatusOK)
+	var body map[string][]interface{}
+	decodeJSON(t, resp, &body)
+	if len(body["outputs"]) != 0 || body["cards"] == nil {
+		t.Errorf("GET /api/outputs = %v, want empty outputs and cards", body)
+	}
+
+	resp = do(t, srv, "POST", "/api/output", `{"id":0,"card":"Device"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestGetSourceSDP(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "GET", "/api/sources/0/sdp", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	resp = do(t, srv, "PATCH", "/api/sources/0", `{"rtp":{"enabled":true}}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", "/api/sources/0/sdp", "")
+	requireStatus(t, resp, http.StatusOK)
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/sdp" {
+		t.Errorf("Content-Type = %q, want application/sdp", ct)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "c=IN IP4 239.69.0.1/16") {
+		t.Errorf("unexpected SDP:\n%s", body)
+	}
+}
+
+func TestSnapcast_NotConfigured(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "GET", "/api/snapcast", "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestCast_DevicesAndAudioToken(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "GET", "/api/cast", "")
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Devices []models.CastDevice `json:"devices"`
+	}
+	decodeJSON(t, resp, &body)
+	if body.Devices == nil || len(body.Devices) != 0 {
+		t.Errorf("devices = %v, want empty list", body.Devices)
+	}
+
+	resp = do(t, srv, "GET", "/cast/sources/0.mp3?token=wrong", "")
+	requireStatus(t, resp, http.StatusUnauthorized)
+	resp.Body.Close()
+}
+
+func TestStreamBrowseAndQueue_NoManager(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "GET", "/api/streams/99999/browse?path=Albums", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", "/api/streams/99999/browse/Albums/Blue%20Train", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", fmt.Sprintf("/api/streams/%d/browse/x", models.AuxStreamID), "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", fmt.Sprintf("/api/streams/%d/queue", models.AuxStreamID), "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestStreamLogs_NoManager(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "GET", "/api/streams/99999/logs", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", fmt.Sprintf("/api/streams/%d/logs?lines=x", models.AuxStreamID), "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", fmt.Sprintf("/api/streams/%d/logs?lines=50", models.AuxStreamID), "")
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Logs []models.ProcessLog `json:"logs"`
+	}
+	decodeJSON(t, resp, &body)
+	if body.Logs == nil || len(body.Logs) != 0 {
+		t.Errorf("logs = %#v, want empty list", body.Logs)
+	}
+}
+
+func TestStreamRestart_NoManager(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "POST", "/api/streams/99999/restart", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	resp = do(t, srv, "POST", fmt.Sprintf("/api/streams/%d/restart", models.AuxStreamID), "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestStreamPairingReset_NoManager(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "DELETE", "/api/streams/99999/pairing", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	resp = do(t, srv, "DELETE", fmt.Sprintf("/api/streams/%d/pairing", models.AuxStreamID), "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestShares_NotConfigured(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "GET", "/api/shares", "")
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Shares []models.NetworkShare `json:"shares"`
+	}
+	decodeJSON(t, resp, &body)
+	if body.Shares == nil || len(body.Shares) != 0 {
+		t.Errorf("shares = %v, want empty list", body.Shares)
+	}
+
+	resp = do(t, srv, "POST", "/api/share", `{"name":"nas","type":"smb","server":"nas","path":"music"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestLEDs(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "PATCH", "/api/hardware/leds/0", `{"red":true,"zones":[null,true]}`)
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		LEDs []models.LEDs `json:"leds"`
+	}
+	decodeJSON(t, resp, &body)
+	if len(body.LEDs) != 1 || !body.LEDs[0].Override || !body.LEDs[0].Red || body.LEDs[0].Zones != [6]bool{false, true} {
+		t.Errorf("leds = %+v", body.LEDs)
+	}
+
+	resp = do(t, srv, "POST", "/api/hardware/leds/identify", `{"zone":2}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &body)
+	if body.LEDs[0].Pattern != "identify" {
+		t.Errorf("pattern = %q, want identify", body.LEDs[0].Pattern)
+	}
+	resp = do(t, srv, "DELETE", "/api/hardware/leds/identify", "")
+	requireStatus(t, resp, http.StatusOK)
+	body.LEDs = nil
+	decodeJSON(t, resp, &body)
+	if body.LEDs[0].Pattern != "" || body.LEDs[0].Zones != [6]bool{false, true} {
+		t.Errorf("after stop leds = %+v", body.LEDs[0])
+	}
+
+	for path, req := range map[string]string{
+		"/api/hardware/leds/identify": `{"zone":30}`,
+		"/api/hardware/leds/5":        `{"red":true}`,
+	} {
+		method := "PATCH"
+		if strings.HasSuffix(path, "identify") {
+			method = "POST"
+		}
+		resp = do(t, srv, method, path, req)
+		requireStatus(t, resp, http.StatusNotFound)
+		resp.Body.Close()
+	}
+}
+
+func TestAnnounce_Upload(t *testing.T) {
+	srv := newTestServer(t)
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp) // where uploaded clips are saved
+
+	upload := func(request string, clip bool) *models.AppError {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if request != "" {
+			_ = mw.WriteField("request", request)
+		}
+		if clip {
+			fw, _ := mw.CreateFormFile("file", "chime.wav")
+			_, _ = fw.Write([]byte("RIFF"))
+		}
+		mw.Close()
+		resp, err := srv.Client().Post(srv.URL+"/api/announce", mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requireStatus(t, resp, http.StatusBadRequest)
+		var e models.AppError
+		decodeJSON(t, resp, &e)
+		return &e
+	}
+	if e := upload(`{"zones":[0]}`, false); e.Field != "file" {
+		t.Errorf("no clip: field %q, want file", e.Field)
+	}
+	if e := upload(`{"zones":[`, true); e.Field != "request" {
+		t.Errorf("bad request JSON: field %q, want request", e.Field)
+	}
+	if e := upload(`{"vol_f":2}`, true); !strings.Contains(e.Message, "vol_f") {
+		t.Errorf("bad vol_f: %q", e.Message)
+	}
+	if left, _ := filepath.Glob(filepath.Join(tmp, "amplipi-announce-*")); len(left) > 0 {
+		t.Errorf("uploaded clips left behind: %v", left)
+	}
+
+	resp := do(t, srv, "POST", "/api/announce", `{"media":"`+filepath.Join(tmp, "missing.mp3")+`"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var e models.AppError
+	decodeJSON(t, resp, &e)
+	if e.Field != "media" || !strings.Contains(e.Message, "does not exist") {
+		t.Errorf("missing media: %+v", e)
+	}
+}
+
+func TestClips(t *testing.T) {
+	srv, ctrl := newTestServerCtrl(t)
+	resp := do(t, srv, "GET", "/api/clips", "")
+	requireStatus(t, resp, http.StatusBadRequest) // no library yet
+	resp.Body.Close()
+
+	lib, err := clips.NewLibrary(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl.SetClips(lib)
+
+	var tone bytes.Buffer
+	if err := audio.WriteTestTone(&tone); err != nil {
+		t.Fatal(err)
+	}
+	upload := func(name, filename string) *http.Response {
+		t.Helper()
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		if name != "" {
+			_ = mw.WriteField("name", name)
+		}
+		fw, _ := mw.CreateFormFile("file", filename)
+		_, _ = fw.Write(tone.Bytes())
+		mw.Close()
+		resp, err := srv.Client().Post(srv.URL+"/api/clips", mw.FormDataContentType(), &body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	resp = upload("", "doorbell.wav")
+	requireStatus(t, resp, http.StatusCreated)
+	var clip models.Clip
+	decodeJSON(t, resp, &clip)
+	if clip.Name != "doorbell" || clip.Format != "wav" || clip.Size != int64(tone.Len()) {
+		t.Errorf("uploaded clip = %+v", clip)
+	}
+	for name, filename := range map[string]string{"dinner time": "dinner.wav", "notes": "notes.txt"} {
+		resp = upload(name, filename)
+		requireStatus(t, resp, http.StatusBadRequest)
+		resp.Body.Close()
+	}
+
+	resp = do(t, srv, "GET", "/api/clips", "")
+	requireStatus(t, resp, http.StatusOK)
+	var list models.ClipLibrary
+	decodeJSON(t, resp, &list)
+	if len(list.Clips) != 1 || list.Used != clip.Size || list.Quota != clips.Quota {
+		t.Errorf("clips = %+v", list)
+	}
+
+	resp = do(t, srv, "GET", "/api/clips/doorbell", "")
+	requireStatus(t, resp, http.StatusOK)
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !bytes.Equal(data, tone.Bytes()) {
+		t.Errorf("clip audio is %d bytes, want %d", len(data), tone.Len())
+	}
+
+	resp = do(t, srv, "POST", "/api/announce", `{"media":"clip:chime"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var e models.AppError
+	decodeJSON(t, resp, &e)
+	if e.Field != "media" || !strings.Contains(e.Message, "chime") {
+		t.Errorf("unknown clip: %+v", e)
+	}
+
+	resp = do(t, srv, "DELETE", "/api/clips/doorbell", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &list)
+	if len(list.Clips) != 0 {
+		t.Errorf("after delete: %+v", list.Clips)
+	}
+	resp = do(t, srv, "DELETE", "/api/clips/doorbell", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func TestIdentifyZone_Validation(t *testing.T) {
+	srv := newTestServer(t)
+	for _, tc := range []struct {
+		path, body string
+		status     int
+	}{
+		{"/api/zones/30/identify", "", http.StatusNotFound},
+		{"/api/zones/0/identify", `{"vol_f":0.9}`, http.StatusBadRequest},
+		{"/api/zones/0/identify", `{"mode":"siren"}`, http.StatusBadRequest},
+	} {
+		resp := do(t, srv, "POST", tc.path, tc.body)
+		requireStatus(t, resp, tc.status)
+		resp.Body.Close()
+	}
+}
+
+func TestZoneCalibration(t *testing.T) {
+	srv := newTestServer(t)
+	for _, tc := range []struct {
+		method, path, body string
+		status             int
+	}{
+		{"POST", "/api/zones/30/calibration/noise", "", http.StatusNotFound},
+		{"POST", "/api/zones/0/calibration/noise", `{"seconds":600}`, http.StatusBadRequest},
+		{"POST", "/api/zones/0/calibration/noise", `{"vol":5}`, http.StatusBadRequest},
+		{"POST", "/api/zones/0/calibration", `{"spl":-3}`, http.StatusBadRequest},
+		{"POST", "/api/zones/0/calibration", `{"spl":82.5,"vol":-30}`, http.StatusOK},
+		{"DELETE", "/api/zones/0/calibration", "", http.StatusOK},
+	} {
+		resp := do(t, srv, tc.method, tc.path, tc.body)
+		requireStatus(t, resp, tc.status)
+		resp.Body.Close()
+	}
+}
+
+func TestSpeakerTest(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/test/speakers", `{"tests":["pink"]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+	resp = do(t, srv, "POST", "/api/test/speakers", `{"vol_f":1}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	// Nothing to test: reported as skipped, not ok.
+	resp = do(t, srv, "POST", "/api/test/speakers", `{"zones":[30],"tests":["channels"]}`)
+	requireStatus(t, resp, http.StatusInternalServerError)
+	var result struct {
+		OK        bool  `json:"ok"`
+		Exercised []int `json:"exercised"`
+		Skipped   []int `json:"skipped"`
+	}
+	decodeJSON(t, resp, &result)
+	if result.OK || len(result.Exercised) != 0 || len(result.Skipped) != 1 || result.Skipped[0] != 30 {
+		t.Errorf("result = %+v", result)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	srv := newTestServer(t)
+
+	body := `{"name":"Pandora","type":"pandora","config":{"user":"me@example.com","password":"hunter2"}}`
+	resp := do(t, srv, "POST", "/api/stream", body)
+	requireStatus(t, resp, http.StatusCreated)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", "/api/diagnostics", "")
+	requireStatus(t, resp, http.StatusOK)
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/gzip" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	files := map[string][]byte{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("tar: %v", err)
+		}
+		data, _ := io.ReadAll(tr)
+		files[path.Base(hdr.Name)] = data
+	}
+	for _, name := range []string{"info.json", "hardware.json", "health.json", "streams.json", "config.json", "amplipi.log"} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("bundle missing %s", name)
+		}
+	}
+	if bytes.Contains(files["config.json"], []byte("hunter2")) {
+		t.Error("config.json contains an unredacted password")
+	}
+	if !bytes.Contains(files["config.json"], []byte("me@example.com")) {
+		t.Error("config.json is missing non-secret stream config")
+	}
+}
+
+func TestLogs(t *testing.T) {
+	srv, ctrl := newTestServerCtrl(t)
+	buf := logs.NewBuffer(100)
+	ctrl.SetLogs(buf)
+	log := slog.New(buf.Handler(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	log.Debug("polling")
+	log.With("subsystem", "streams").Warn("stream exited", "code", 1)
+	log.Error("i2c failure", "subsystem", "hardware")
+
+	var body struct {
+		Logs []logs.Entry `json:"logs"`
+	}
+	resp := do(t, srv, "GET", "/api/logs?level=warn", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &body)
+	if len(body.Logs) != 2 || body.Logs[0].Message != "stream exited" {
+		t.Fatalf("level=warn: %+v", body.Logs)
+	}
+
+	body.Logs = nil
+	resp = do(t, srv, "GET", "/api/logs?subsystem=hardware&since=1h", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &body)
+	if len(body.Logs) != 1 || body.Logs[0].Subsystem != "hardware" {
+		t.Fatalf("subsystem=hardware: %+v", body.Logs)
+	}
+
+	for _, q := range []string{"level=loud", "since=yesterday", "limit=-1"} {
+		resp = do(t, srv, "GET", "/api/logs?"+q, "")
+		requireStatus(t, resp, http.StatusBadRequest)
+		resp.Body.Close()
+	}
+
+	// Tail: buffered matches first, then new records.
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/api/logs/tail?subsystem=streams", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("tail: %v", err)
+	}
+	defer resp.Body.Close()
+	requireStatus(t, resp, http.StatusOK)
+	sc := bufio.NewScanner(resp.Body)
+	next := func() logs.Entry {
+		t.Helper()
+		for sc.Scan() {
+			if data, ok := strings.CutPrefix(sc.Text(), "data: "); ok {
+				var e logs.Entry
+				if err := json.Unmarshal([]byte(data), &e); err != nil {
+					t.Fatalf("decode: %v", err)
+				}
+				return e
+			}
+		}
+		t.Fatalf("tail ended: %v", sc.Err())
+		return logs.Entry{}
+	}
+	if e := next(); e.Message != "stream exited" {
+		t.Errorf("backlog = %q", e.Message)
+	}
+	log.Info("ignored")
+	log.Info("stream started", "subsystem", "streams")
+	if e := next(); e.Message != "stream started" {
+		t.Errorf("live = %q", e.Message)
+	}
+}
+
+func TestStreamerMode_NoZoneEndpoints(t *testing.T) {
+	p := &hardware.HardwareProfile{
+		Units:                    []hardware.UnitInfo{{Board: hardware.BoardInfo{UnitType: hardware.UnitTypeStreamer}}},
+		IsStreamer:               true,
+		AvailablePhysicalOutputs: []int{0, 1},
+	}
+	srv, _ := newProfiledTestServer(t, p)
+
+	for _, path := range []string{"/api/zones", "/api/zones/0", "/api/groups"} {
+		resp := do(t, srv, "GET", path, "")
+		requireStatus(t, resp, http.StatusNotFound)
+		var appErr models.AppError
+		decodeJSON(t, resp, &appErr)
+		if !strings.Contains(appErr.Message, "streamer") {
+			t.Errorf("GET %s: error %q does not mention streamer mode", path, appErr.Message)
+		}
+	}
+	resp := do(t, srv, "PATCH", "/api/zones/0", `{"vol_f":0.5}`)
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+
+	// Sources and streams still work, and the state has no zones.
+	resp = do(t, srv, "GET", "/api/sources", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "GET", "/api", "")
+	requireStatus(t, resp, http.StatusOK)
+	var state models.State
+	decodeJSON(t, resp, &state)
+	if len(state.Zones) != 0 || len(state.Groups) != 0 {
+		t.Errorf("state has %d zones, %d groups on a streamer unit", len(state.Zones), len(state.Groups))
+	}
+
+	resp = do(t, srv, "GET", "/api/info", "")
+	requireStatus(t, resp, http.StatusOK)
+	var info models.Info
+	decodeJSON(t, resp, &info)
+	if !info.Streamer {
+		t.Error("info.streamer not set")
+	}
+}
+
+func TestSettings(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "GET", "/api/settings", "")
+	requireStatus(t, resp, http.StatusOK)
+	var settings models.Settings
+	decodeJSON(t, resp, &settings)
+	if len(settings.SourceIdle) != 0 {
+		t.Errorf("default source_idle = %+v", settings.SourceIdle)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/settings", `{"source_idle":[{"source_id":1,"minutes":30}]}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &settings)
+	if len(settings.SourceIdle) != 1 || settings.SourceIdle[0] != (models.SourceIdlePolicy{SourceID: 1, Minutes: 30}) {
+		t.Errorf("source_idle = %+v", settings.SourceIdle)
+	}
+
+	// Absent fields are unchanged.
+	resp = do(t, srv, "PATCH", "/api/settings", `{}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &settings)
+	if len(settings.SourceIdle) != 1 {
+		t.Errorf("empty PATCH changed source_idle: %+v", settings.SourceIdle)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/settings", `{"source_idle":[{"source_id":9,"minutes":30}]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "PATCH", "/api/settings", `{"source_idle":[]}`)
+	requireStatus(t, resp, http.StatusOK)
+	var cleared models.Settings
+	decodeJSON(t, resp, &cleared)
+	if len(cleared.SourceIdle) != 0 {
+		t.Errorf("source_idle not cleared: %+v", cleared.SourceIdle)
+	}
+}
+
+func TestPartyEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/party", `{"source_id":1}`)
+	requireStatus(t, resp, http.StatusOK)
+	var state models.State
+	decodeJSON(t, resp, &state)
+	for _, z := range state.Zones {
+		if !z.Disabled && (z.SourceID != 1 || z.Mute) {
+			t.Errorf("zone %d not in the party: source %d, mute %v", z.ID, z.SourceID, z.Mute)
+		}
+	}
+
+	resp = do(t, srv, "DELETE", "/api/party", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	if state.Zones[0].SourceID != 0 || !state.Zones[0].Mute {
+		t.Errorf("zone 0 not restored: %+v", state.Zones[0])
+	}
+
+	resp = do(t, srv, "DELETE", "/api/party", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func TestHomeAssistantEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+	api.SetUnitID("amplipi_test")
+	t.Cleanup(func() { api.SetUnitID("") })
+
+	resp := do(t, srv, "GET", "/api/ha/discovery", "")
+	requireStatus(t, resp, http.StatusOK)
+	var d struct {
+		Entities []struct {
+			UniqueID string `json:"unique_id"`
+			Kind     string `json:"kind"`
+			ID       int    `json:"id"`
+		} `json:"entities"`
+	}
+	decodeJSON(t, resp, &d)
+	var zone0 string
+	for _, e := range d.Entities {
+		if e.Kind == "zone" && e.ID == 0 {
+			zone0 = e.UniqueID
+		}
+	}
+	// Entity IDs follow the unit ID, not the hostname.
+	if zone0 != "amplipi_test_zone_0" {
+		t.Fatalf("zone 0 unique_id = %q in %+v, want amplipi_test_zone_0", zone0, d.Entities)
+	}
+
+	resp = do(t, srv, "POST", "/api/ha/services/"+zone0+"/volume_mute", `{"is_volume_muted":false}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "GET", "/api/zones/0", "")
+	var z models.Zone
+	decodeJSON(t, resp, &z)
+	if z.Mute {
+		t.Error("volume_mute service did not unmute zone 0")
+	}
+
+	resp = do(t, srv, "GET", "/api/ha/states", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+}
+
+func TestMatterCommissioning(t *testing.T) {
+	srv := newTestServer(t)
+
+	var st matter.Status
+	resp := do(t, srv, "GET", "/api/matter", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &st)
+	if st.Commissionable || st.QRCode != "" {
+		t.Errorf("commissionable before credentials were generated: %+v", st)
+	}
+	if len(st.Endpoints) == 0 || st.Endpoints[0].Endpoint != 1 || st.Endpoints[0].ZoneID != 0 {
+		t.Errorf("Endpoints = %+v", st.Endpoints)
+	}
+
+	resp = do(t, srv, "POST", "/api/matter/commissioning", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &st)
+	if st.Commissionable || !strings.HasPrefix(st.QRCode, "MT:") || len(st.ManualCode) != 13 {
+		t.Fatalf("after commissioning: %+v; want codes, but not commissionable without a stack", st)
+	}
+	qr := st.QRCode
+
+	// The passcode is write-only.
+	resp = do(t, srv, "GET", "/api/settings", "")
+	requireStatus(t, resp, http.StatusOK)
+	var settings map[string]map[string]any
+	decodeJSON(t, resp, &settings)
+	if _, ok := settings["matter"]["passcode"]; ok || settings["matter"]["discriminator"] == nil {
+		t.Errorf("matter settings = %v, want the discriminator only", settings["matter"])
+	}
+
+	// Opening again keeps the credentials; reset replaces them.
+	var again matter.Status
+	resp = do(t, srv, "POST", "/api/matter/commissioning", "")
+	decodeJSON(t, resp, &again)
+	if again.QRCode != qr {
+		t.Errorf("QR code changed without reset: %q -> %q", qr, again.QRCode)
+	}
+	var reset matter.Status
+	resp = do(t, srv, "POST", "/api/matter/commissioning?reset=true", "")
+	decodeJSON(t, resp, &reset)
+	if reset.QRCode == qr {
+		t.Error("reset kept the old QR code")
+	}
+}
+
+func TestTriggerEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/hardware/triggers", `{"name":"Sub","pin":"GPIO22","zones":[0],"hold":10}`)
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Triggers []models.TriggerStatus `json:"triggers"`
+	}
+	decodeJSON(t, resp, &body)
+	if len(body.Triggers) != 1 || body.Triggers[0].Pin != "GPIO22" {
+		t.Fatalf("triggers = %+v", body.Triggers)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/hardware/triggers/0", `{"pin":"GPIO4"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "DELETE", "/api/hardware/triggers/0", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "DELETE", "/api/hardware/triggers/0", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func TestWebhookEndpoints(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/webhooks", `{"name":"HA","url":"http://ha.local/hook","events":["zone_changed"],"secret":"s3cret"}`)
+	requireStatus(t, resp, http.StatusOK)
+	var body struct {
+		Webhooks []map[string]any `json:"webhooks"`
+	}
+	decodeJSON(t, resp, &body)
+	if len(body.Webhooks) != 1 || body.Webhooks[0]["url"] != "http://ha.local/hook" {
+		t.Fatalf("webhooks = %+v", body.Webhooks)
+	}
+	// The secret is write-only.
+	if _, ok := body.Webhooks[0]["secret"]; ok || body.Webhooks[0]["has_secret"] != true {
+		t.Errorf("webhook = %+v, want has_secret and no secret", body.Webhooks[0])
+	}
+	resp = do(t, srv, "GET", "/api", "")
+	requireStatus(t, resp, http.StatusOK)
+	if data, _ := io.ReadAll(resp.Body); strings.Contains(string(data), "s3cret") {
+		t.Error("GET /api returned the webhook secret")
+	}
+	resp.Body.Close()
+
+	resp = do(t, srv, "PATCH", "/api/webhooks/0", `{"events":["nope"]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "POST", "/api/webhooks/0/test", "")
+	requireStatus(t, resp, http.StatusAccepted)
+	resp.Body.Close()
+
+	resp = do(t, srv, "DELETE", "/api/webhooks/0", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "POST", "/api/webhooks/0/test", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func TestExportImport(t *testing.T) {
+	srv := newTestServer(t)
+
+	resp := do(t, srv, "POST", "/api/stream", `{"name":"KEXP","type":"internetradio","config":{"url":"http://kexp.org/live"}}`)
+	requireStatus(t, resp, http.StatusCreated)
+	resp.Body.Close()
+	resp = do(t, srv, "POST", "/api/preset", `{"name":"Morning"}`)
+	requireStatus(t, resp, http.StatusCreated)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", "/api/export?include=streams", "")
+	requireStatus(t, resp, http.StatusOK)
+	var export models.ConfigExport
+	decodeJSON(t, resp, &export)
+	if len(export.Streams) != 1 || export.Streams[0].Name != "KEXP" || export.Presets != nil {
+		t.Fatalf("export = %+v", export)
+	}
+
+	resp = do(t, srv, "GET", "/api/export?include=zones", "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "POST", "/api/import", `{"mode":"replace","presets":[{"name":"Evening"},{"name":"evening"}]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	var appErr models.AppError
+	decodeJSON(t, resp, &appErr)
+	if len(appErr.Fields) != 1 || appErr.Fields[0].Field != "presets[1].name" {
+		t.Errorf("fields = %+v", appErr.Fields)
+	}
+
+	resp = do(t, srv, "POST", "/api/import", `{"mode":"replace","presets":[{"name":"Evening"}]}`)
+	requireStatus(t, resp, http.StatusOK)
+	var state models.State
+	decodeJSON(t, resp, &state)
+	var names []string
+	for _, p := range state.Presets {
+		names = append(names, p.Name)
+	}
+	if slices.Contains(names, "Morning") || !slices.Contains(names, "Evening") {
+		t.Errorf("presets after replace = %v", names)
+	}
+}
+
+func TestPairing(t *testing.T) {
+	dir := t.TempDir()
+	users := `{"admin":{"type":"admin","access_key":"admin-key","password_hash":"$argon2id$fake"}}`
+	if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(users), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authSvc, err := auth.NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.NewRouter(ctrl, authSvc, events.NewBus()))
+	t.Cleanup(func() {
+		srv.Close()
+		authSvc.Close()
+	})
+
+	// Closed until confirmed at the unit or by an administrator.
+	resp := do(t, srv, "POST", "/api/pair", `{"name":"Phone"}`)
+	requireStatus(t, resp, http.StatusForbidden)
+	resp.Body.Close()
+
+	resp = do(t, srv, "POST", "/api/pair/window?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "GET", "/api/pair", "")
+	var status auth.PairingStatus
+	decodeJSON(t, resp, &status)
+	if !status.Open || status.Until == nil {
+		t.Fatalf("pairing status = %+v, want open", status)
+	}
+
+	resp = do(t, srv, "POST", "/api/pair", `{"name":""}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+	resp = do(t, srv, "POST", "/api/pair", `{"name":"Phone"}`)
+	requireStatus(t, resp, http.StatusCreated)
+	var dev auth.Device
+	decodeJSON(t, resp, &dev)
+
+	// The app's key opens the API until it is unpaired, but pairing itself
+	// is for administrators.
+	resp = do(t, srv, "GET", "/api?api-key="+dev.Key, "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	for _, req := range []struct{ method, path string }{
+		{"POST", "/api/pair/window"},
+		{"DELETE", "/api/pair/window"},
+		{"GET", "/api/pair/devices"},
+		{"DELETE", "/api/pair/devices/" + dev.ID},
+	} {
+		resp = do(t, srv, req.method, req.path+"?api-key="+dev.Key, "")
+		requireStatus(t, resp, http.StatusForbidden)
+		resp.Body.Close()
+	}
+	resp = do(t, srv, "GET", "/api/pair/devices?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusOK)
+	var listed struct {
+		Devices []auth.Device `json:"devices"`
+	}
+	decodeJSON(t, resp, &listed)
+	if len(listed.Devices) != 1 || listed.Devices[0].Name != "Phone" || listed.Devices[0].Key != "" {
+		t.Errorf("devices = %+v", listed.Devices)
+	}
+	resp = do(t, srv, "DELETE", "/api/pair/devices/"+dev.ID+"?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	if authSvc.VerifyKey(dev.Key) {
+		t.Error("key still valid after unpairing")
+	}
+	resp = do(t, srv, "DELETE", "/api/pair/devices/"+dev.ID+"?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusNotFound)
+	resp.Body.Close()
+}
+
+func TestPermissionProfiles(t *testing.T) {
+	dir := t.TempDir()
+	users := `{"admin":{"type":"admin","access_key":"admin-key","password_hash":"$argon2id$fake"}}`
+	if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(users), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authSvc, err := auth.NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.NewRouter(ctrl, authSvc, events.NewBus()))
+	t.Cleanup(func() {
+		srv.Close()
+		authSvc.Close()
+	})
+	authSvc.OpenPairing(time.Minute)
+	dev, err := authSvc.Pair("Kids Tablet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "?api-key=" + dev.Key
+
+	// Only administrators manage profiles.
+	resp := do(t, srv, "PATCH", "/api/permissions/"+dev.ID+key, `{"zones":[4,5]}`)
+	requireStatus(t, resp, http.StatusForbidden)
+	resp.Body.Close()
+	resp = do(t, srv, "PATCH", "/api/permissions/"+dev.ID+"?api-key=admin-key", `{"zones":[4,99]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+	resp = do(t, srv, "PATCH", "/api/permissions/"+dev.ID+"?api-key=admin-key", `{"zones":[4,5]}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", "/api/permissions/me"+key, "")
+	var me struct {
+		Admin bool        `json:"admin"`
+		Scope *auth.Scope `json:"scope"`
+	}
+	decodeJSON(t, resp, &me)
+	if me.Admin || me.Scope == nil || len(me.Scope.Zones) != 2 {
+		t.Errorf("own permissions = %+v, want zones 4 and 5", me)
+	}
+
+	// The tablet controls its zones only.
+	resp = do(t, srv, "PATCH", "/api/zones/4"+key, `{"vol":-30}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "POST", "/api/zones/5/vol_up"+key, "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	for _, req := range []struct{ method, path, body string }{
+		{"PATCH", "/api/zones/0", `{"vol":-30}`},
+		{"PATCH", "/api/zones", `{"zones":[4,0],"update":{"mute":true}}`},
+		{"PATCH", "/api/settings", `{}`},
+		{"POST", "/api/group", `{"name":"Mine","zones":[4,5]}`},
+		{"DELETE", "/api/pair/devices/" + dev.ID, ""},
+	} {
+		resp = do(t, srv, req.method, req.path+key, req.body)
+		requireStatus(t, resp, http.StatusForbidden)
+		resp.Body.Close()
+	}
+
+	resp = do(t, srv, "DELETE", "/api/permissions/"+dev.ID+"?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "PATCH", "/api/zones/0"+key, `{"vol":-30}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+}
+
+func TestLimits(t *testing.T) {
+	api.SetLimits(api.Limits{Rate: 1, Burst: 3, LoginPerMinute: 2, MaxBody: 64, MaxUpload: 1 << 20})
+	t.Cleanup(func() { api.SetLimits(api.DefaultLimits) })
+	authSvc, err := auth.NewService("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(authSvc.Close)
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	router := api.NewRouter(ctrl, authSvc, events.NewBus())
+	serve := func(method, path, remote, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.RemoteAddr = remote
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// A burst, then 429 with Retry-After; other clients are unaffected.
+	for i := 0; i < 3; i++ {
+		if rr := serve("GET", "/api/info", "192.0.2.1:1000", ""); rr.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rr.Code)
+		}
+	}
+	rr := serve("GET", "/api/info", "192.0.2.1:1000", "")
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "1" {
+		t.Errorf("over the rate: status %d, Retry-After %q", rr.Code, rr.Header().Get("Retry-After"))
+	}
+	if rr := serve("GET", "/api/info", "192.0.2.2:1000", ""); rr.Code != http.StatusOK {
+		t.Errorf("other client: status %d", rr.Code)
+	}
+	// Forwarding headers do not get a client a fresh allowance.
+	req := httptest.NewRequest("GET", "/api/info", nil)
+	req.RemoteAddr = "192.0.2.1:1000"
+	req.Header.Set("X-Forwarded-For", "198.51.100.7")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("forged X-Forwarded-For: status %d", rr.Code)
+	}
+	// On-device clients are not limited.
+	for i := 0; i < 10; i++ {
+		if rr := serve("GET", "/api/info", "127.0.0.1:1000", ""); rr.Code != http.StatusOK {
+			t.Fatalf("loopback request %d: status %d", i, rr.Code)
+		}
+	}
+
+	// Login attempts have their own, stricter limit.
+	for i := 0; i < 2; i++ {
+		serve("POST", "/auth/login", "192.0.2.3:1000", "")
+	}
+	if rr := serve("POST", "/auth//login", "192.0.2.3:1000", ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("third login: status %d", rr.Code)
+	}
+
+	// IPv6 clients share a limit per /64.
+	for i := 0; i < 3; i++ {
+		serve("GET", "/api/info", fmt.Sprintf("[2001:db8:1:2::%x]:1000", i+1), "")
+	}
+	if rr := serve("GET", "/api/info", "[2001:db8:1:2:ffff::1]:1000", ""); rr.Code != http.StatusTooManyRequests {
+		t.Errorf("same /64: status %d", rr.Code)
+	}
+	if rr := serve("GET", "/api/info", "[2001:db8:1:3::1]:1000", ""); rr.Code != http.StatusOK {
+		t.Errorf("other /64: status %d", rr.Code)
+	}
+
+	// Body sizes: small for most requests, larger for config uploads.
+	big := `{"name":"` + strings.Repeat("x", 100) + `"}`
+	if rr := serve("POST", "/api/preset", "192.0.2.4:1000", big); rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("large preset body: status %d", rr.Code)
+	}
+	if rr := serve("POST", "/api/config/validate", "192.0.2.4:1000", big); rr.Code == http.StatusRequestEntityTooLarge {
+		t.Error("config upload refused as too large")
+	}
+
+	rr = serve("GET", "/api/limits", "127.0.0.1:1000", "")
+	var stats api.LimitStats
+	if err := json.NewDecoder(rr.Body).Decode(&stats); err != nil {
+		t.Fatal(err)
+	}
+	if stats.Limited != 3 || stats.LoginLimited != 1 || stats.TooLarge != 1 || stats.Clients != 6 || stats.Rate != 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestPower(t *testing.T) {
+	dir := t.TempDir()
+	users := `{"admin":{"type":"admin","access_key":"admin-key","password_hash":"$argon2id$fake"}}`
+	if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(users), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authSvc, err := auth.NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.NewRouter(ctrl, authSvc, events.NewBus()))
+	t.Cleanup(func() {
+		srv.Close()
+		authSvc.Close()
+	})
+	authSvc.OpenPairing(time.Minute)
+	dev, err := authSvc.Pair("Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Paired apps may not power the unit down or administer it.
+	for _, req := range []struct{ method, path, body string }{
+		{"POST", "/api/reboot", ""},
+		{"PATCH", "/api/system/hostname", `{"hostname":"mine"}`},
+		{"PATCH", "/api/system/time", `{"ntp":true}`},
+		{"POST", "/api/matter/commissioning", ""},
+		{"POST", "/api/provision", `{"zones":[{"id":0,"name":"Mine"}]}`},
+	} {
+		resp := do(t, srv, req.method, req.path+"?api-key="+dev.Key, req.body)
+		requireStatus(t, resp, http.StatusForbidden)
+		resp.Body.Close()
+	}
+
+	// Nor read the Matter pairing codes, which carry the setup passcode.
+	resp := do(t, srv, "POST", "/api/matter/commissioning?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	var st matter.Status
+	resp = do(t, srv, "GET", "/api/matter?api-key="+dev.Key, "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &st)
+	if st.QRCode != "" || st.ManualCode != "" || len(st.Endpoints) == 0 {
+		t.Errorf("matter status for a paired app = %+v", st)
+	}
+
+	resp = do(t, srv, "POST", "/api/shutdown?api-key=admin-key", "")
+	requireStatus(t, resp, http.StatusAccepted)
+	var power models.PowerResponse
+	decodeJSON(t, resp, &power)
+	if power.Action != "shutdown" || power.Status != "confirm" || power.Confirm == "" {
+		t.Fatalf("unconfirmed shutdown = %+v", power)
+	}
+
+	resp = do(t, srv, "POST", "/api/reboot?api-key=admin-key", `{"confirm":"`+power.Confirm+`"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+}
+
+func TestLoadConfig_PairedAppKeepsZoneLock(t *testing.T) {
+	dir := t.TempDir()
+	users := `{"admin":{"type":"admin","access_key":"admin-key","password_hash":"$argon2id$fake"}}`
+	if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(users), 0600); err != nil {
+		t.Fatal(err)
+	}
+	authSvc, err := auth.NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(api.NewRouter(ctrl, authSvc, events.NewBus()))
+	t.Cleanup(func() {
+		srv.Close()
+		authSvc.Close()
+	})
+	authSvc.OpenPairing(time.Minute)
+	dev, err := authSvc.Pair("Kids Tablet")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp := do(t, srv, "PATCH", "/api/zones/0?api-key=admin-key", `{"lock":{"enabled":true,"vol_max":-30}}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+
+	// A config without the lock would free the zone, so paired apps may
+	// not load one.
+	unlocked := models.DefaultState()
+	unlocked.Zones[0].SourceID = 2
+	data, err := json.Marshal(unlocked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/api/load", "/api/import"} {
+		resp = do(t, srv, "POST", path+"?api-key="+dev.Key, string(data))
+		requireStatus(t, resp, http.StatusForbidden)
+		resp.Body.Close()
+	}
+	if z := ctrl.State().Zones[0]; z.Lock == nil || z.SourceID == 2 {
+		t.Errorf("zone 0 after a paired app's load = %+v, want it still locked", z)
+	}
+
+	resp = do(t, srv, "POST", "/api/load?api-key=admin-key", string(data))
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	if z := ctrl.State().Zones[0]; z.Lock != nil || z.SourceID != 2 {
+		t.Errorf("zone 0 after an admin's load = %+v, want it unlocked on source 2", z)
+	}
+}
+
+func TestVolumeUnits(t *testing.T) {
+	srv := newTestServer(t)
+
+	type zone struct {
+		VolF   float64 `json:"vol_f"`
+		Vol    int     `json:"vol"`
+		Volume float64 `json:"volume"`
+		Units  string  `json:"units"`
+	}
+	var state struct {
+		Zones []zone `json:"zones"`
+	}
+	resp := do(t, srv, "PATCH", "/api/zones/0?units=percent", `{"volume":50}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	if z := state.Zones[0]; z.VolF != 0.5 || z.Volume != 50 || z.Units != "percent" {
+		t.Errorf("PATCH volume 50%%: zone = %+v, want vol_f 0.5, volume 50", z)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/zones/0?units=db", `{"volume":-30}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	if z := state.Zones[0]; z.Vol != -30 || z.Volume != -30 || z.Units != "db" {
+		t.Errorf("PATCH volume -30 dB: zone = %+v, want vol -30", z)
+	}
+
+	// Without units, volume has no meaning and responses are unchanged.
+	resp = do(t, srv, "PATCH", "/api/zones/0", `{"volume":-30}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+	resp = do(t, srv, "GET", "/api/zones/0", "")
+	requireStatus(t, resp, http.StatusOK)
+	var z zone
+	decodeJSON(t, resp, &z)
+	if z.Units != "" {
+		t.Errorf("GET without units: units = %q, want none", z.Units)
+	}
+
+	resp = do(t, srv, "GET", "/api/zones?units=bels", "")
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+	resp = do(t, srv, "PATCH", "/api/settings", `{"volume_units":"bels"}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	// The setting is the default; the query parameter wins.
+	resp = do(t, srv, "PATCH", "/api/settings", `{"volume_units":"percent"}`)
+	requireStatus(t, resp, http.StatusOK)
+	resp.Body.Close()
+	resp = do(t, srv, "PATCH", "/api/zones", `{"zones":[0,1],"update":{"volume":25}}`)
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &state)
+	for i, z := range state.Zones[:2] {
+		if z.VolF != 0.25 || z.Volume != 25 {
+			t.Errorf("PATCH /api/zones volume 25%%: zone %d = %+v, want vol_f 0.25", i, z)
+		}
+	}
+	resp = do(t, srv, "GET", "/api/zones/0?units=db", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &z)
+	if z.Units != "db" || z.Volume != float64(z.Vol) {
+		t.Errorf("GET ?units=db: zone = %+v, want volume = vol", z)
+	}
+}
+
+func TestHardwareProfileOverride(t *testing.T) {
+	srv, ctrl := newTestServerCtrl(t)
+	resp := do(t, srv, "GET", "/api/hardware/profile", "")
+	requireStatus(t, resp, http.StatusBadRequest) // no config directory yet
+	resp.Body.Close()
+
+	ctrl.SetProfileOverrideDir(t.TempDir())
+	resp = do(t, srv, "PATCH", "/api/hardware/profile", `{"units": 2, "analog_sources": false}`)
+	requireStatus(t, resp, http.StatusOK)
+	var status models.ProfileOverrideStatus
+	decodeJSON(t, resp, &status)
+	if !status.RestartRequired || status.Saved.Units == nil || *status.Saved.Units != 2 {
+		t.Errorf("PATCH = %+v, want 2 units saved pending a restart", status)
+	}
+
+	resp = do(t, srv, "PATCH", "/api/hardware/profile", `{"unavailable_streams": ["gramophone"]}`)
+	requireStatus(t, resp, http.StatusBadRequest)
+	resp.Body.Close()
+
+	resp = do(t, srv, "GET", "/api/hardware/profile", "")
+	requireStatus(t, resp, http.StatusOK)
+	decodeJSON(t, resp, &status)
+	if status.Saved.AnalogSources == nil || *status.Saved.AnalogSources || len(status.Saved.UnavailableStreams) != 0 {
+		t.Errorf("GET = %+v", status)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "POST", "/api/test/selftest", "")
+	requireStatus(t, resp, http.StatusOK)
+	var res models.SelfTest
+	decodeJSON(t, resp, &res)
+	if len(res.Checks) == 0 || res.Time.IsZero() {
+		t.Fatalf("self-test = %+v", res)
+	}
+
+	resp = do(t, srv, "GET", "/api/info", "")
+	requireStatus(t, resp, http.StatusOK)
+	var info models.Info
+	decodeJSON(t, resp, &info)
+	if info.SelfTest == nil || len(info.SelfTest.Checks) != len(res.Checks) {
+		t.Errorf("info self_test = %+v, want the last result", info.SelfTest)
+	}
+}
+
+func TestAudioRouting(t *testing.T) {
+	srv := newTestServer(t)
+	resp := do(t, srv, "GET", "/api/audio/routing", "")
+	requireStatus(t, resp, http.StatusOK)
+	var r models.AudioRouting
+	decodeJSON(t, resp, &r)
+	if len(r.Sources) != 4 || r.Sources[0].Zones == nil {
+		t.Errorf("sources = %+v", r.Sources)
+	}
+	// The test server runs no stream manager.
+	if r.Consistent || len(r.Problems) != 1 || r.Problems[0] != "streams are disabled" {
+		t.Errorf("consistent %v, problems %q", r.Consistent, r.Problems)
+	}
+}
@@ -0,0 +1,317 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// GraphQL serves the zones, sources, streams, groups and presets at
+// /api/graphql for clients that want exactly the fields they need in one
+// request. Queries take {"query", "variables", "operationName"} by POST,
+// or ?query= by GET; subscriptions get the fields again after every state
+// change, as a text/event-stream like /api/subscribe. The object types
+// follow the JSON of the REST API, field for field. Changes go through
+// the REST API.
+
+var graphQLEnabled bool
+
+// EnableGraphQL serves /api/graphql from routers created afterwards.
+func EnableGraphQL(on bool) { graphQLEnabled = on }
+
+// graphQLRequest is a GraphQL-over-HTTP request.
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables"`
+	OperationName string                 `json:"operationName"`
+}
+
+// graphQLList describes a top-level list and the field to get one item of
+// it by ID.
+type graphQLList struct {
+	name, one string
+	model     interface{}
+	all       func(Controller) interface{}
+	get       func(Controller, int) (interface{}, *models.AppError)
+	inState   func(models.State) interface{}
+}
+
+var graphQLLists = []graphQLList{
+	{"zones", "zone", models.Zone{},
+		func(c Controller) interface{} { return c.GetZones() },
+		func(c Controller, id int) (interface{}, *models.AppError) { return c.GetZone(id) },
+		func(s models.State) interface{} { return s.Zones }},
+	{"sources", "source", models.Source{},
+		func(c Controller) interface{} { return c.GetSources() },
+		func(c Controller, id int) (interface{}, *models.AppError) { return c.GetSource(id) },
+		func(s models.State) interface{} { return s.Sources }},
+	{"streams", "stream", models.Stream{},
+		func(c Controller) interface{} { return c.GetStreams() },
+		func(c Controller, id int) (interface{}, *models.AppError) { return c.GetStream(id) },
+		func(s models.State) interface{} { return s.Streams }},
+	{"groups", "group", models.Group{},
+		func(c Controller) interface{} { return c.GetGroups() },
+		func(c Controller, id int) (interface{}, *models.AppError) { return c.GetGroup(id) },
+		func(s models.State) interface{} { return s.Groups }},
+	{"presets", "preset", models.Preset{},
+		func(c Controller) interface{} { return c.GetPresets() },
+		func(c Controller, id int) (interface{}, *models.AppError) { return c.GetPreset(id) },
+		func(s models.State) interface{} { return s.Presets }},
+}
+
+// newGraphQLSchema builds the schema, resolving queries with ctrl and
+// subscriptions with the states published on bus.
+func newGraphQLSchema(ctrl Controller, bus EventBus) (graphql.Schema, error) {
+	types := &graphQLTypes{objects: make(map[reflect.Type]graphql.Output)}
+	query := graphql.Fields{}
+	subscription := graphql.Fields{}
+	for _, l := range graphQLLists {
+		typ := types.output(reflect.TypeOf(l.model), "")
+		query[l.name] = &graphql.Field{
+			Type: graphql.NewList(typ),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return jsonValue(l.all(ctrl))
+			},
+		}
+		query[l.one] = &graphql.Field{
+			Type: typ,
+			Args: graphql.FieldConfigArgument{"id": {Type: graphql.NewNonNull(graphql.Int)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				v, appErr := l.get(ctrl, p.Args["id"].(int))
+				if appErr != nil {
+					return nil, appErr
+				}
+				return jsonValue(v)
+			},
+		}
+		subscription[l.name] = &graphql.Field{
+			Type:      graphql.NewList(typ),
+			Subscribe: subscribeStates(ctrl, bus),
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return jsonValue(l.inState(p.Source.(models.State)))
+			},
+		}
+	}
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query:        graphql.NewObject(graphql.ObjectConfig{Name: "Query", Fields: query}),
+		Subscription: graphql.NewObject(graphql.ObjectConfig{Name: "Subscription", Fields: subscription}),
+	})
+}
+
+// subscribeStates sends the current state, then every state published,
+// until the request ends.
+func subscribeStates(ctrl Controller, bus EventBus) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		id := uuid.New().String()
+		states := bus.Subscribe(id)
+		out := make(chan interface{})
+		go func() {
+			defer close(out)
+			defer bus.Unsubscribe(id)
+			state := ctrl.State()
+			for {
+				select {
+				case out <- state:
+				case <-p.Context.Done():
+					return
+				}
+				var ok bool
+				select {
+				case state, ok = <-states:
+					if !ok {
+						return
+					}
+				case <-p.Context.Done():
+					return
+				}
+			}
+		}()
+		return out, nil
+	}
+}
+
+// jsonValue returns v as it is encoded in the REST API's JSON, which the
+// object types follow.
+func jsonValue(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	err = json.Unmarshal(data, &out)
+	return out, err
+}
+
+// graphQLJSON is a value without a fixed type, e.g. a stream's config,
+// returned as it is in the JSON.
+var graphQLJSON = graphql.NewScalar(graphql.ScalarConfig{
+	Name:        "JSON",
+	Description: "Any JSON value",
+	Serialize:   func(v interface{}) interface{} { return v },
+})
+
+// graphQLTypes derives output types from Go types and their JSON tags.
+type graphQLTypes struct {
+	objects map[reflect.Type]graphql.Output
+}
+
+// output returns the type of t's JSON. Anonymous structs are named after
+// the field they are in.
+func (g *graphQLTypes) output(t reflect.Type, name string) graphql.Output {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return graphql.String
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return graphql.Boolean
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return graphql.Int
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return graphql.Float // may not fit GraphQL's 32-bit Int
+	case reflect.String:
+		return graphql.String
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return graphql.String // base64
+		}
+		return graphql.NewList(g.output(t.Elem(), name))
+	case reflect.Struct:
+		return g.object(t, name)
+	}
+	return graphQLJSON
+}
+
+// object returns the object type of struct type t, or JSON if it has no
+// fields.
+func (g *graphQLTypes) object(t reflect.Type, name string) graphql.Output {
+	if obj, ok := g.objects[t]; ok {
+		return obj
+	}
+	if t.Name() != "" {
+		name = t.Name()
+	}
+	fields := graphql.Fields{}
+	obj := graphql.NewObject(graphql.ObjectConfig{
+		Name:   name,
+		Fields: graphql.FieldsThunk(func() graphql.Fields { return fields }),
+	})
+	g.objects[t] = obj
+	g.addFields(fields, t, name)
+	if len(fields) == 0 {
+		g.objects[t] = graphQLJSON
+		return graphQLJSON
+	}
+	return obj
+}
+
+// addFields adds the JSON fields of struct type t, including those of
+// embedded structs.
+func (g *graphQLTypes) addFields(fields graphql.Fields, t reflect.Type, name string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			g.addFields(fields, f.Type, name)
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		if _, ok := fields[tag]; ok {
+			continue // shadowed, as in encoding/json
+		}
+		fields[tag] = &graphql.Field{Type: g.output(f.Type, name+exportedName(tag))}
+	}
+}
+
+// exportedName turns a snake_case JSON name into CamelCase.
+func exportedName(s string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
+
+// graphQL serves /api/graphql.
+func (h *Handlers) graphQL(w http.ResponseWriter, r *http.Request) {
+	var req graphQLRequest
+	if r.Method == http.MethodGet {
+		q := r.URL.Query()
+		req.Query = q.Get("query")
+		req.OperationName = q.Get("operationName")
+		if v := q.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+				writeError(w, models.ErrBadRequest("invalid variables: "+err.Error()))
+				return
+			}
+		}
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	if req.Query == "" {
+		writeError(w, models.ErrBadRequest("query is required").WithField("query"))
+		return
+	}
+
+	params := graphql.Params{
+		Schema:         *h.graphQLSchema,
+		RequestString:  req.Query,
+		VariableValues: req.Variables,
+		OperationName:  req.OperationName,
+		Context:        r.Context(),
+	}
+	if !isSubscription(req.Query, req.OperationName) {
+		writeJSON(w, http.StatusOK, graphql.Do(params))
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	for res := range graphql.Subscribe(params) {
+		sendSSE(w, flusher, res)
+	}
+}
+
+// isSubscription reports whether the operation to run is a subscription.
+// Queries that do not parse are run as queries, which reports the error.
+func isSubscription(query, operationName string) bool {
+	doc, err := parser.Parse(parser.ParseParams{Source: query})
+	if err != nil {
+		return false
+	}
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if operationName == "" || (op.Name != nil && op.Name.Value == operationName) {
+			return op.Operation == ast.OperationTypeSubscription
+		}
+	}
+	return false
+}
@@ -2,7 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
+	"mime"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
@@ -17,10 +22,22 @@ import (
 // - Waits for the announcement to finish playing (blocking)
 // - Restores the previous state
 //
+// A multipart/form-data body uploads the clip to play instead of naming
+// it: a "file" part, and optionally a "request" part with the rest of the
+// request as JSON.
+//
 // This endpoint blocks until the announcement completes or times out.
 func (h *Handlers) announce(w http.ResponseWriter, r *http.Request) {
 	var req models.AnnounceRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if ctype, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ctype == "multipart/form-data" {
+		path, appErr := announceUpload(r, &req)
+		if appErr != nil {
+			writeError(w, appErr)
+			return
+		}
+		defer os.Remove(path)
+		req.Media = path
+	} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
 		return
 	}
@@ -33,3 +50,42 @@ func (h *Handlers) announce(w http.ResponseWriter, r *http.Request) {
 
 	writeJSON(w, http.StatusOK, state)
 }
+
+// announceUploadMemory is how much of an uploaded clip is held in memory
+// while parsing; the rest spills to temporary files.
+const announceUploadMemory = 8 << 20
+
+// announceUpload reads a multipart announcement into req and saves the
+// uploaded clip to a temporary file, returning its path. The caller
+// removes it.
+func announceUpload(r *http.Request, req *models.AnnounceRequest) (string, *models.AppError) {
+	if err := r.ParseMultipartForm(announceUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return "", models.ErrTooLarge("announcement upload is too large")
+		}
+		return "", models.ErrBadRequest("invalid multipart form: " + err.Error())
+	}
+	if v := r.FormValue("request"); v != "" {
+		if err := json.Unmarshal([]byte(v), req); err != nil {
+			return "", models.ErrBadRequest("invalid JSON in request: " + err.Error()).WithField("request")
+		}
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		return "", models.ErrBadRequest("missing clip in form field 'file'").WithField("file")
+	}
+	defer file.Close()
+
+	// Keep the extension: it helps the players tell the format.
+	tmp, err := os.CreateTemp("", "amplipi-announce-*"+filepath.Ext(filepath.Base(header.Filename)))
+	if err != nil {
+		return "", models.ErrInternal("failed to save clip: " + err.Error())
+	}
+	defer tmp.Close()
+	if _, err := io.Copy(tmp, file); err != nil {
+		os.Remove(tmp.Name())
+		return "", models.ErrInternal("failed to save clip: " + err.Error())
+	}
+	return tmp.Name(), nil
+}
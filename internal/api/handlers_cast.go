@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getCastDevices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"devices": h.ctrl.GetCastDevices()})
+}
+
+// castSourceAudio serves a source as an endless MP3 stream to Cast devices.
+// They cannot log in, so the URL carries a per-boot token instead.
+func (h *Handlers) castSourceAudio(w http.ResponseWriter, r *http.Request) {
+	if !h.ctrl.VerifyCastToken(r.URL.Query().Get("token")) {
+		writeError(w, models.ErrUnauthorized)
+		return
+	}
+	sid, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "audio/mpeg")
+	w.Header().Set("Cache-Control", "no-cache")
+	if appErr := h.ctrl.StreamSourceAudio(r.Context(), sid, flushWriter{w}); appErr != nil {
+		writeError(w, appErr)
+	}
+}
+
+// flushWriter flushes after every write so audio reaches the client as it
+// is encoded rather than when the response buffer fills.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if fl, ok := f.w.(http.Flusher); ok {
+		fl.Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getClips(w http.ResponseWriter, r *http.Request) {
+	lib, appErr := h.ctrl.GetClips()
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, lib)
+}
+
+// addClip handles POST /api/clips, a multipart/form-data upload of a
+// "file" part and optionally a "name" field; without one the clip is
+// named after the file.
+func (h *Handlers) addClip(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(announceUploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, models.ErrTooLarge("clip upload is too large"))
+			return
+		}
+		writeError(w, models.ErrBadRequest("invalid multipart form: "+err.Error()))
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeError(w, models.ErrBadRequest("missing clip in form field 'file'").WithField("file"))
+		return
+	}
+	defer file.Close()
+
+	clip, appErr := h.ctrl.AddClip(r.Context(), r.FormValue("name"), header.Filename, file)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusCreated, clip)
+}
+
+// getClipAudio serves a clip's audio, e.g. for the web UI to preview it.
+func (h *Handlers) getClipAudio(w http.ResponseWriter, r *http.Request) {
+	path, appErr := h.ctrl.ClipPath(chi.URLParam(r, "name"))
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	http.ServeFile(w, r, path)
+}
+
+func (h *Handlers) deleteClip(w http.ResponseWriter, r *http.Request) {
+	lib, appErr := h.ctrl.DeleteClip(r.Context(), chi.URLParam(r, "name"))
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, lib)
+}
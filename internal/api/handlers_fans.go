@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getFans(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ctrl.GetFans(r.Context()))
+}
+
+func (h *Handlers) setFanCurve(w http.ResponseWriter, r *http.Request) {
+	var upd models.FanCurveUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	fans, appErr := h.ctrl.SetFanCurve(r.Context(), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, fans)
+}
+
+// clearFanCurve hands the fans back to the firmware.
+func (h *Handlers) clearFanCurve(w http.ResponseWriter, r *http.Request) {
+	fans, appErr := h.ctrl.SetFanCurve(r.Context(), models.FanCurveUpdate{})
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, fans)
+}
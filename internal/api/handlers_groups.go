@@ -71,3 +71,34 @@ func (h *Handlers) deleteGroup(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, state)
 }
+
+func (h *Handlers) groupVolUp(w http.ResponseWriter, r *http.Request)   { h.stepGroupVol(w, r, 1) }
+func (h *Handlers) groupVolDown(w http.ResponseWriter, r *http.Request) { h.stepGroupVol(w, r, -1) }
+
+// stepGroupVol moves every zone in a group by one step in direction dir.
+func (h *Handlers) stepGroupVol(w http.ResponseWriter, r *http.Request, dir int) {
+	id, err := intParam(r, "gid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	step, appErr := decodeVolumeStep(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	var upd models.GroupUpdate
+	if step.Vol != nil {
+		d := dir * *step.Vol
+		upd.Vol = &d
+	} else {
+		d := float64(dir) * *step.VolF
+		upd.VolDeltaF = &d
+	}
+	state, appErr := h.ctrl.SetGroup(r.Context(), id, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/homeassistant"
+	"github.com/micro-nova/amplipi-go/internal/identity"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// unitID prefixes Home Assistant entity IDs; see SetUnitID.
+var unitID string
+
+// SetUnitID sets the persisted unit ID (identity.UnitID) that prefixes
+// Home Assistant entity IDs, so renaming the unit doesn't orphan them.
+func SetUnitID(id string) { unitID = id }
+
+// haUnit is the unit ID that prefixes Home Assistant entity IDs. Without
+// SetUnitID it falls back to one derived from the hostname.
+func haUnit() string {
+	if unitID != "" {
+		return unitID
+	}
+	return "amplipi_" + identity.GetHostname()
+}
+
+func (h *Handlers) getHADiscovery(w http.ResponseWriter, r *http.Request) {
+	state := h.ctrl.State()
+	state.Info = h.ctrl.GetInfo()
+	writeJSON(w, http.StatusOK, homeassistant.NewDiscovery(haUnit(), &state))
+}
+
+func (h *Handlers) getHAStates(w http.ResponseWriter, r *http.Request) {
+	state := h.ctrl.State()
+	writeJSON(w, http.StatusOK, map[string]interface{}{"states": homeassistant.States(haUnit(), &state)})
+}
+
+// callHAService handles POST /api/ha/services/{entity}/{service}: a
+// media_player service call with Home Assistant's service data as the
+// (optional) body.
+func (h *Handlers) callHAService(w http.ResponseWriter, r *http.Request) {
+	var data homeassistant.ServiceData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && err != io.EOF {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	state, appErr := homeassistant.Call(r.Context(), h.ctrl, haUnit(), chi.URLParam(r, "entity"), chi.URLParam(r, "service"), data)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"states": homeassistant.States(haUnit(), &state)})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// getProfileOverride returns the saved hardware profile override and the
+// one in effect.
+func (h *Handlers) getProfileOverride(w http.ResponseWriter, r *http.Request) {
+	status, appErr := h.ctrl.GetProfileOverride()
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+// setProfileOverride changes the saved hardware profile override. It
+// takes effect when the service restarts.
+func (h *Handlers) setProfileOverride(w http.ResponseWriter, r *http.Request) {
+	var upd hardware.ProfileOverride
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	status, appErr := h.ctrl.SetProfileOverride(r.Context(), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
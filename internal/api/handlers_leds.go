@@ -0,0 +1,49 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getLEDs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leds": h.ctrl.GetLEDs(r.Context())})
+}
+
+func (h *Handlers) setLEDs(w http.ResponseWriter, r *http.Request) {
+	unit, err := intParam(r, "unit")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var upd models.LEDUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	leds, appErr := h.ctrl.SetLEDs(r.Context(), unit, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leds": leds})
+}
+
+func (h *Handlers) identifyLEDs(w http.ResponseWriter, r *http.Request) {
+	var req models.LEDIdentify
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	leds, appErr := h.ctrl.IdentifyLEDs(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leds": leds})
+}
+
+func (h *Handlers) stopLEDPatterns(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"leds": h.ctrl.StopLEDPatterns(r.Context())})
+}
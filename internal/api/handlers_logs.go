@@ -0,0 +1,119 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/logs"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// getLogs returns buffered daemon logs, oldest first.
+// Query: level=debug|info|warn|error (minimum), since=RFC 3339 time or a
+// duration such as 15m, subsystem=streams,hardware (repeatable), limit=N.
+func (h *Handlers) getLogs(w http.ResponseWriter, r *http.Request) {
+	f, appErr := parseLogFilter(r.URL.Query(), time.Now())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	entries := []logs.Entry{}
+	if buf := h.ctrl.LogBuffer(); buf != nil {
+		entries = buf.Entries(f)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"logs": entries})
+}
+
+// tailLogs streams daemon logs over SSE: the buffered entries matching the
+// filter first, then new entries as they are logged.
+func (h *Handlers) tailLogs(w http.ResponseWriter, r *http.Request) {
+	f, appErr := parseLogFilter(r.URL.Query(), time.Now())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	buf := h.ctrl.LogBuffer()
+	if buf == nil {
+		writeError(w, models.ErrBadRequest("log buffer is not available"))
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx buffering
+
+	// Subscribe before reading the backlog so nothing logged in between is
+	// lost; Seq drops the overlap.
+	id := uuid.New().String()
+	ch := buf.Subscribe(id)
+	defer buf.Unsubscribe(id)
+
+	var last uint64
+	for _, e := range buf.Entries(f) {
+		sendSSE(w, flusher, e)
+		last = e.Seq
+	}
+	flusher.Flush()
+
+	for {
+		select {
+		case e, ok := <-ch:
+			if !ok {
+				return
+			}
+			if e.Seq > last && f.Match(e) {
+				sendSSE(w, flusher, e)
+				last = e.Seq
+			}
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// parseLogFilter builds a log filter from query parameters. The default
+// level is debug, i.e. everything buffered.
+func parseLogFilter(q url.Values, now time.Time) (logs.Filter, *models.AppError) {
+	f := logs.Filter{Level: slog.LevelDebug}
+	if s := q.Get("level"); s != "" {
+		if err := f.Level.UnmarshalText([]byte(s)); err != nil {
+			return f, models.ErrBadRequest("level must be one of debug, info, warn, error")
+		}
+	}
+	if s := q.Get("since"); s != "" {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			f.Since = t
+		} else if d, err := time.ParseDuration(s); err == nil && d > 0 {
+			f.Since = now.Add(-d)
+		} else {
+			return f, models.ErrBadRequest(fmt.Sprintf("since must be an RFC 3339 time or a duration such as 15m, got %q", s))
+		}
+	}
+	for _, s := range q["subsystem"] {
+		for _, name := range strings.Split(s, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				f.Subsystems = append(f.Subsystems, name)
+			}
+		}
+	}
+	if s := q.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return f, models.ErrBadRequest("limit must be a non-negative integer")
+		}
+		f.Limit = n
+	}
+	return f, nil
+}
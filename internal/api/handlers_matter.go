@@ -0,0 +1,30 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/matter"
+)
+
+// getMatter handles GET /api/matter. The pairing codes carry the setup
+// passcode, so only administrators get them.
+func (h *Handlers) getMatter(w http.ResponseWriter, r *http.Request) {
+	state := h.ctrl.State()
+	st := matter.NewStatus(state.Settings.Matter, state.Zones)
+	if !h.auth.IsAdmin(r) {
+		st.QRCode, st.ManualCode = "", ""
+	}
+	writeJSON(w, http.StatusOK, st)
+}
+
+// openMatterCommissioning handles POST /api/matter/commissioning: make the
+// device commissionable and return its QR and manual pairing codes.
+// ?reset=true generates new credentials, invalidating the old codes.
+func (h *Handlers) openMatterCommissioning(w http.ResponseWriter, r *http.Request) {
+	settings, appErr := h.ctrl.OpenMatterCommissioning(r.Context(), r.URL.Query().Get("reset") == "true")
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, matter.NewStatus(settings, h.ctrl.State().Zones))
+}
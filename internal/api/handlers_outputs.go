@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getOutputs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"outputs": h.ctrl.GetOutputs(),
+		"cards":   h.ctrl.GetAudioCards(),
+	})
+}
+
+func (h *Handlers) createOutput(w http.ResponseWriter, r *http.Request) {
+	var req models.AudioOutputUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	outputs, appErr := h.ctrl.CreateOutput(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"outputs": outputs})
+}
+
+func (h *Handlers) setOutput(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "oid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var upd models.AudioOutputUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	outputs, appErr := h.ctrl.SetOutput(r.Context(), id, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"outputs": outputs})
+}
+
+func (h *Handlers) deleteOutput(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "oid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	outputs, appErr := h.ctrl.DeleteOutput(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"outputs": outputs})
+}
+
+func (h *Handlers) getAudioRouting(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ctrl.AudioRouting())
+}
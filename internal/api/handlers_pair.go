@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// PairWindow is how long pairing stays open after the pair button is
+// pressed or pairing is opened from a signed-in client.
+const PairWindow = 2 * time.Minute
+
+// getPairing tells apps whether they can pair now. No auth required.
+func (h *Handlers) getPairing(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.auth.Pairing())
+}
+
+// pair issues a device key to an app while a pairing window is open. No
+// auth required: being on the LAN while someone confirms at the unit is
+// the proof.
+func (h *Handlers) pair(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	if err := models.ValidateName(req.Name); err != nil {
+		writeError(w, models.ErrBadRequest(err.Error()).WithField("name"))
+		return
+	}
+	dev, err := h.auth.Pair(req.Name)
+	if errors.Is(err, auth.ErrPairingClosed) {
+		writeError(w, models.ErrForbidden(err.Error()))
+		return
+	}
+	if err != nil {
+		writeError(w, models.ErrInternal(err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusCreated, dev)
+}
+
+// openPairing opens a pairing window, as the front-panel pair button does.
+func (h *Handlers) openPairing(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.auth.OpenPairing(PairWindow))
+}
+
+func (h *Handlers) closePairing(w http.ResponseWriter, r *http.Request) {
+	h.auth.ClosePairing()
+	writeJSON(w, http.StatusOK, h.auth.Pairing())
+}
+
+func (h *Handlers) getPairedDevices(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"devices": h.auth.Devices()})
+}
+
+// unpairDevice revokes a paired app's key.
+func (h *Handlers) unpairDevice(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "did")
+	if err := h.auth.Unpair(id); errors.Is(err, os.ErrNotExist) {
+		writeError(w, models.ErrNotFound("paired device "+id+" not found"))
+		return
+	} else if err != nil {
+		writeError(w, models.ErrInternal(err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"devices": h.auth.Devices()})
+}
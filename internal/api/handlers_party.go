@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// startParty handles POST /api/party: route all (or the selected) zones to
+// one source, saving their previous routing.
+func (h *Handlers) startParty(w http.ResponseWriter, r *http.Request) {
+	var req models.PartyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	state, appErr := h.ctrl.StartParty(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// endParty handles DELETE /api/party: restore the routing from before
+// party mode.
+func (h *Handlers) endParty(w http.ResponseWriter, r *http.Request) {
+	state, appErr := h.ctrl.EndParty(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
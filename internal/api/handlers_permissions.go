@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// getPermissions lists the permission profile of every user and paired
+// app.
+func (h *Handlers) getPermissions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"profiles": h.auth.Profiles()})
+}
+
+// getOwnPermissions tells a client what it may do, so UIs can grey out
+// the zones and groups it can't control.
+func (h *Handlers) getOwnPermissions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"admin": auth.AdminFrom(r.Context()),
+		"scope": auth.ScopeFrom(r.Context()),
+	})
+}
+
+// setPermissions restricts a user or paired app to the zones and groups
+// of the body's scope.
+func (h *Handlers) setPermissions(w http.ResponseWriter, r *http.Request) {
+	var sc auth.Scope
+	if err := json.NewDecoder(r.Body).Decode(&sc); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	if appErr := h.checkScope(sc); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	h.writeScope(w, chi.URLParam(r, "uid"), &sc)
+}
+
+// deletePermissions lifts a user's or paired app's restriction.
+func (h *Handlers) deletePermissions(w http.ResponseWriter, r *http.Request) {
+	h.writeScope(w, chi.URLParam(r, "uid"), nil)
+}
+
+func (h *Handlers) writeScope(w http.ResponseWriter, id string, sc *auth.Scope) {
+	if err := h.auth.SetScope(id, sc); errors.Is(err, os.ErrNotExist) {
+		writeError(w, models.ErrNotFound("user "+id+" not found"))
+		return
+	} else if err != nil {
+		writeError(w, models.ErrInternal(err.Error()))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"profiles": h.auth.Profiles()})
+}
+
+// checkScope checks that a scope's zones and groups exist.
+func (h *Handlers) checkScope(sc auth.Scope) *models.AppError {
+	for _, id := range sc.Zones {
+		if _, appErr := h.ctrl.GetZone(id); appErr != nil {
+			return models.ErrBadRequest("zone " + strconv.Itoa(id) + " does not exist").WithField("zones")
+		}
+	}
+	for _, id := range sc.Groups {
+		if _, appErr := h.ctrl.GetGroup(id); appErr != nil {
+			return models.ErrBadRequest("group " + strconv.Itoa(id) + " does not exist").WithField("groups")
+		}
+	}
+	return nil
+}
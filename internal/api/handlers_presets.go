@@ -78,10 +78,14 @@ func (h *Handlers) loadPreset(w http.ResponseWriter, r *http.Request) {
 		writeError(w, err)
 		return
 	}
-	state, appErr := h.ctrl.LoadPreset(r.Context(), id)
+	state, report, appErr := h.ctrl.LoadPresetWithReport(r.Context(), id)
 	if appErr != nil {
 		writeError(w, appErr)
 		return
 	}
-	writeJSON(w, http.StatusOK, state)
+	// The state as for Python clients, plus what was skipped
+	writeJSON(w, http.StatusOK, struct {
+		models.State
+		Report models.PresetReport `json:"report"`
+	}{state, report})
 }
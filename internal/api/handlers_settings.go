@@ -0,0 +1,26 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getSettings(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ctrl.GetSettings())
+}
+
+func (h *Handlers) setSettings(w http.ResponseWriter, r *http.Request) {
+	var upd models.SettingsUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	settings, appErr := h.ctrl.SetSettings(r.Context(), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, settings)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getShares(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": h.ctrl.GetShares()})
+}
+
+func (h *Handlers) createShare(w http.ResponseWriter, r *http.Request) {
+	var req models.NetworkShareUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	shares, appErr := h.ctrl.CreateShare(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]interface{}{"shares": shares})
+}
+
+func (h *Handlers) setShare(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "shid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var upd models.NetworkShareUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	shares, appErr := h.ctrl.SetShare(r.Context(), id, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": shares})
+}
+
+func (h *Handlers) deleteShare(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "shid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	shares, appErr := h.ctrl.DeleteShare(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": shares})
+}
+
+func (h *Handlers) mountShare(w http.ResponseWriter, r *http.Request) {
+	h.doMountShare(w, r, true)
+}
+
+func (h *Handlers) unmountShare(w http.ResponseWriter, r *http.Request) {
+	h.doMountShare(w, r, false)
+}
+
+func (h *Handlers) doMountShare(w http.ResponseWriter, r *http.Request, mount bool) {
+	id, err := intParam(r, "shid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	shares, appErr := h.ctrl.MountShare(r.Context(), id, mount)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"shares": shares})
+}
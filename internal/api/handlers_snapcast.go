@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getSnapcast(w http.ResponseWriter, r *http.Request) {
+	status, appErr := h.ctrl.GetSnapcast(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handlers) setSnapClient(w http.ResponseWriter, r *http.Request) {
+	var upd models.SnapClientUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	status, appErr := h.ctrl.SetSnapClient(r.Context(), chi.URLParam(r, "cid"), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handlers) setSnapGroup(w http.ResponseWriter, r *http.Request) {
+	var upd models.SnapGroupUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	status, appErr := h.ctrl.SetSnapGroup(r.Context(), chi.URLParam(r, "gid"), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// createSnapshot saves the current routing and volumes and returns the
+// snapshot's ID to restore them with.
+func (h *Handlers) createSnapshot(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusCreated, h.ctrl.CreateSnapshot())
+}
+
+func (h *Handlers) getSnapshots(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"snapshots": h.ctrl.GetSnapshots()})
+}
+
+func (h *Handlers) restoreSnapshot(w http.ResponseWriter, r *http.Request) {
+	state, report, appErr := h.ctrl.RestoreSnapshot(r.Context(), chi.URLParam(r, "snid"))
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	// As for loading a preset: the state, plus what was skipped
+	writeJSON(w, http.StatusOK, struct {
+		models.State
+		Report models.PresetReport `json:"report"`
+	}{state, report})
+}
+
+func (h *Handlers) getRevisions(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"revisions": h.ctrl.GetRevisions()})
+}
+
+// undo reverts the most recent routing or volume change.
+func (h *Handlers) undo(w http.ResponseWriter, r *http.Request) {
+	state, undone, appErr := h.ctrl.Undo(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, struct {
+		models.State
+		Undone models.Revision `json:"undone"`
+	}{state, undone})
+}
@@ -2,6 +2,7 @@ package api
 
 import (
 	"encoding/json"
+	"net"
 	"net/http"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
@@ -48,3 +49,27 @@ func (h *Handlers) setSource(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, state)
 }
+
+// getSourceSDP serves the SDP for a source's RTP output so AES67 and other
+// RTP receivers can subscribe. The origin is the address the client used.
+func (h *Handlers) getSourceSDP(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	origin := "0.0.0.0"
+	if addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr); ok {
+		if host, _, err := net.SplitHostPort(addr.String()); err == nil {
+			origin = host
+		}
+	}
+	sdp, appErr := h.ctrl.GetSourceSDP(id, origin)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	w.Header().Set("Content-Type", "application/sdp")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(sdp))
+}
@@ -3,8 +3,11 @@ package api
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/micro-nova/amplipi-go/internal/artwork"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
@@ -26,6 +29,11 @@ func (h *Handlers) getStream(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, s)
 }
 
+// getStreamTypes describes the config each stream type accepts.
+func (h *Handlers) getStreamTypes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"types": h.ctrl.StreamTypes()})
+}
+
 func (h *Handlers) createStream(w http.ResponseWriter, r *http.Request) {
 	var req models.StreamCreate
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -79,8 +87,9 @@ func (h *Handlers) execStreamCmd(w http.ResponseWriter, r *http.Request) {
 		writeError(w, err)
 		return
 	}
-	cmd := chi.URLParam(r, "cmd")
-	if cmd == "" {
+	// Queue commands carry paths; clients escape their slashes as %2F.
+	cmd, uerr := url.PathUnescape(chi.URLParam(r, "cmd"))
+	if uerr != nil || cmd == "" {
 		writeError(w, models.ErrBadRequest("command is required"))
 		return
 	}
@@ -91,3 +100,130 @@ func (h *Handlers) execStreamCmd(w http.ResponseWriter, r *http.Request) {
 	}
 	writeJSON(w, http.StatusOK, state)
 }
+
+func (h *Handlers) browseStream(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// The path is either the rest of the URL (/browse/{path}) or ?path=.
+	path := r.URL.Query().Get("path")
+	if rest := chi.URLParam(r, "*"); rest != "" {
+		path = rest
+		if unescaped, uerr := url.PathUnescape(rest); uerr == nil {
+			path = unescaped
+		}
+	}
+	items, appErr := h.ctrl.BrowseStream(r.Context(), id, path)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, models.BrowseResponse{Items: items})
+}
+
+// restartStream restarts a stream's processes.
+func (h *Handlers) restartStream(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	state, appErr := h.ctrl.RestartStream(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// resetStreamPairing forgets a Spotify Connect stream's paired account.
+func (h *Handlers) resetStreamPairing(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	state, appErr := h.ctrl.ResetStreamPairing(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// getStreamLogs returns the captured stdout/stderr of a stream's processes.
+// Query: lines=N per process (default 200).
+func (h *Handlers) getStreamLogs(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	lines := 0
+	if s := r.URL.Query().Get("lines"); s != "" {
+		n, convErr := strconv.Atoi(s)
+		if convErr != nil || n < 0 {
+			writeError(w, models.ErrBadRequest("lines must be a non-negative integer"))
+			return
+		}
+		lines = n
+	}
+	logs, appErr := h.ctrl.GetStreamLogs(id, lines)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"logs": logs})
+}
+
+// getStreamImage serves the artwork of what a stream is playing.
+// Query: w=N and h=N scale it to fit, fmt=png|jpeg|rgb565|gray|mono
+// converts it; raw pixel formats report their size in X-Image-Width and
+// X-Image-Height. With neither it is served as fetched.
+func (h *Handlers) getStreamImage(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q := r.URL.Query()
+	opts := artwork.Options{Format: q.Get("fmt")}
+	for name, v := range map[string]*int{"w": &opts.Width, "h": &opts.Height} {
+		if s := q.Get(name); s != "" {
+			n, convErr := strconv.Atoi(s)
+			if convErr != nil || n < 1 {
+				writeError(w, models.ErrBadRequest(name+" must be a positive integer"))
+				return
+			}
+			*v = n
+		}
+	}
+	img, appErr := h.ctrl.GetStreamImage(r.Context(), id, opts)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	w.Header().Set("Content-Type", img.ContentType)
+	w.Header().Set("Cache-Control", "no-cache") // changes with the track
+	if img.Width > 0 {
+		w.Header().Set("X-Image-Width", strconv.Itoa(img.Width))
+		w.Header().Set("X-Image-Height", strconv.Itoa(img.Height))
+	}
+	w.Write(img.Data)
+}
+
+func (h *Handlers) getStreamQueue(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "sid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	q, appErr := h.ctrl.GetStreamQueue(id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, q)
+}
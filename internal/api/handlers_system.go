@@ -2,16 +2,21 @@ package api
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/maintenance"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
@@ -20,8 +25,159 @@ func (h *Handlers) getInfo(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, h.ctrl.GetInfo())
 }
 
+// setHostname renames the unit and returns the updated info.
+func (h *Handlers) setHostname(w http.ResponseWriter, r *http.Request) {
+	var upd models.HostnameUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	info, appErr := h.ctrl.SetHostname(r.Context(), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, info)
+}
+
+func (h *Handlers) getTime(w http.ResponseWriter, r *http.Request) {
+	status, appErr := h.ctrl.GetTime(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handlers) setTime(w http.ResponseWriter, r *http.Request) {
+	var upd models.TimeUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	status, appErr := h.ctrl.SetTime(r.Context(), upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, status)
+}
+
+func (h *Handlers) getTimezones(w http.ResponseWriter, r *http.Request) {
+	zones, appErr := h.ctrl.Timezones(r.Context())
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"timezones": zones})
+}
+
+// requireAdmin answers 403 to paired apps, which may play music but not
+// administer the unit.
+func (h *Handlers) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !h.auth.IsAdmin(r) {
+			writeError(w, models.ErrForbidden("sign in as a user to do this; paired apps cannot"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scopedChanges are the changes a key restricted to a scope may make:
+// zone and group volume, mute and source, and stream commands. The
+// controller checks they are to the key's zones, groups and streams and
+// change no other zone or group field.
+var scopedChanges = regexp.MustCompile(`^/api/(zones(/\d+(/vol_up|/vol_down)?)?|groups/\d+(/vol_up|/vol_down)?|streams/\d+/[^/]+)$`)
+
+// markAccess marks in a request's context whether it comes from an
+// administrator, which lets it past zone locks, and the scope it is
+// restricted to, which the controller enforces. Scoped requests may only
+// read and make the changes of scopedChanges.
+func (h *Handlers) markAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.auth.IsAdmin(r) {
+			r = r.WithContext(auth.WithAdmin(r.Context()))
+		} else if sc := h.auth.ScopeFor(r); sc != nil {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			case http.MethodPatch, http.MethodPost:
+				if !scopedChanges.MatchString(r.URL.Path) || strings.HasSuffix(r.URL.Path, "/restart") {
+					writeError(w, models.ErrForbidden("this key may only control its zones and groups"))
+					return
+				}
+			default:
+				writeError(w, models.ErrForbidden("this key may only control its zones and groups"))
+				return
+			}
+			r = r.WithContext(auth.WithScope(r.Context(), sc))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (h *Handlers) reboot(w http.ResponseWriter, r *http.Request) {
+	h.power(w, r, models.PowerReboot)
+}
+
+func (h *Handlers) shutdown(w http.ResponseWriter, r *http.Request) {
+	h.power(w, r, models.PowerShutdown)
+}
+
+// power answers an unconfirmed request with 202 and a token, and a
+// confirmed one with 200 once systemd took over.
+func (h *Handlers) power(w http.ResponseWriter, r *http.Request, action string) {
+	var req models.PowerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	resp, appErr := h.ctrl.Power(r.Context(), action, req.Confirm)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	status := http.StatusOK
+	if resp.Confirm != "" {
+		status = http.StatusAccepted
+	}
+	writeJSON(w, status, resp)
+}
+
+// factoryReset answers an unconfirmed request with 202 and a token, and a
+// confirmed one with 200 and the state after the reset. A full reset also
+// removes users.json.
 func (h *Handlers) factoryReset(w http.ResponseWriter, r *http.Request) {
-	state, appErr := h.ctrl.FactoryReset(r.Context())
+	var req models.FactoryResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	resp, appErr := h.ctrl.ConfirmFactoryReset(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	if resp.Status == "confirm" {
+		writeJSON(w, http.StatusAccepted, resp)
+		return
+	}
+	if resp.Scope == models.ResetFull {
+		if err := h.auth.RemoveUsers(); err != nil {
+			writeError(w, models.ErrInternal("config reset, but users not removed: "+err.Error()))
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handlers) provision(w http.ResponseWriter, r *http.Request) {
+	var p models.Provision
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	state, appErr := h.ctrl.Provision(r.Context(), p)
 	if appErr != nil {
 		writeError(w, appErr)
 		return
@@ -30,12 +186,95 @@ func (h *Handlers) factoryReset(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *Handlers) loadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("dry_run") == "true" {
+		h.validateConfig(w, r)
+		return
+	}
+	incoming, notes, appErr := decodeConfig(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	for _, n := range notes {
+		slog.Warn("load: converting Python AmpliPi config", "note", n)
+	}
+	state, appErr := h.ctrl.LoadConfig(r.Context(), incoming)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// validateConfig checks a config as /api/load would, without loading it.
+// Notes on converting a Python config lead the warnings.
+func (h *Handlers) validateConfig(w http.ResponseWriter, r *http.Request) {
+	incoming, notes, appErr := decodeConfig(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	result, appErr := h.ctrl.ValidateConfig(incoming)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	if len(notes) > 0 {
+		result.Warnings = append(notes, result.Warnings...)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// decodeConfig reads an uploaded config, converting a house.json written
+// by the Python AmpliPi. It returns notes on anything the conversion
+// changed or dropped.
+func decodeConfig(r *http.Request) (models.State, []string, *models.AppError) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return models.State{}, nil, models.ErrBadRequest("read body: " + err.Error())
+	}
+	if config.IsPythonConfig(data) {
+		state, notes, err := config.ImportPython(data)
+		if err != nil {
+			return models.State{}, nil, models.ErrBadRequest("invalid JSON: " + err.Error())
+		}
+		return *state, notes, nil
+	}
 	var incoming models.State
-	if err := json.NewDecoder(r.Body).Decode(&incoming); err != nil {
+	if err := json.Unmarshal(data, &incoming); err != nil {
+		return models.State{}, nil, models.ErrBadRequest("invalid JSON: " + err.Error())
+	}
+	return incoming, nil, nil
+}
+
+// exportConfig returns the user's streams and presets, or only the kinds
+// listed in ?include=streams,presets.
+func (h *Handlers) exportConfig(w http.ResponseWriter, r *http.Request) {
+	streams, presets := true, true
+	if include := r.URL.Query().Get("include"); include != "" {
+		streams, presets = false, false
+		for _, kind := range strings.Split(include, ",") {
+			switch strings.TrimSpace(kind) {
+			case "streams":
+				streams = true
+			case "presets":
+				presets = true
+			default:
+				writeError(w, models.ErrBadRequest(fmt.Sprintf("cannot export %q; include streams and/or presets", kind)).WithField("include"))
+				return
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, h.ctrl.ExportConfig(streams, presets))
+}
+
+func (h *Handlers) importConfig(w http.ResponseWriter, r *http.Request) {
+	var req models.ConfigImport
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
 		return
 	}
-	state, appErr := h.ctrl.LoadConfig(r.Context(), incoming)
+	state, appErr := h.ctrl.ImportConfig(r.Context(), req)
 	if appErr != nil {
 		writeError(w, appErr)
 		return
@@ -93,6 +332,39 @@ func (h *Handlers) testPreamp(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, status, result)
 }
 
+// runSelfTest runs the startup self-test again and returns its result,
+// which replaces the one shown in /api/info.
+func (h *Handlers) runSelfTest(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ctrl.RunSelfTest(r.Context()))
+}
+
+// testSpeakers plays channel check and sweep signals through each zone in
+// turn. Blocks until every zone has been tested.
+func (h *Handlers) testSpeakers(w http.ResponseWriter, r *http.Request) {
+	var req models.SpeakerTest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	result, err := h.ctrl.TestSpeakers(r.Context(), req)
+	if appErr, ok := err.(*models.AppError); ok {
+		writeError(w, appErr)
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"ok":    false,
+			"error": err.Error(),
+		})
+		return
+	}
+	status := http.StatusOK
+	if ok, _ := result["ok"].(bool); !ok {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, result)
+}
+
 // testFans forces fans on for 3 seconds via REG_FANS, then returns to auto.
 func (h *Handlers) testFans(w http.ResponseWriter, r *http.Request) {
 	result, err := h.ctrl.TestFans(r.Context())
@@ -110,6 +382,23 @@ func (h *Handlers) testFans(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, status, result)
 }
 
+// getDiagnostics returns a support bundle (.tar.gz) with hardware, health,
+// stream and redacted config information plus recent logs.
+func (h *Handlers) getDiagnostics(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	if err := h.ctrl.WriteDiagnostics(r.Context(), &buf); err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"error": err.Error(),
+		})
+		return
+	}
+	name := "amplipi-diagnostics-" + time.Now().Format("20060102-150405") + ".tar.gz"
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(buf.Bytes())
+}
+
 // flashFirmware is a stub — firmware flashing is not yet implemented in the Go version.
 func (h *Handlers) flashFirmware(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusNotImplemented, map[string]interface{}{
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getTriggers(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"triggers": h.ctrl.GetTriggers()})
+}
+
+func (h *Handlers) createTrigger(w http.ResponseWriter, r *http.Request) {
+	var req models.TriggerUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	triggers, appErr := h.ctrl.CreateTrigger(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"triggers": triggers})
+}
+
+func (h *Handlers) setTrigger(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "tid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var upd models.TriggerUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	triggers, appErr := h.ctrl.SetTrigger(r.Context(), id, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"triggers": triggers})
+}
+
+func (h *Handlers) deleteTrigger(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "tid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	triggers, appErr := h.ctrl.DeleteTrigger(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"triggers": triggers})
+}
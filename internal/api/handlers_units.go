@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// getUnits reads the live status of every preamp unit.
+func (h *Handlers) getUnits(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"units": h.ctrl.GetUnits(r.Context())})
+}
+
+func (h *Handlers) getUnit(w http.ResponseWriter, r *http.Request) {
+	idx, err := intParam(r, "unit")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	unit, appErr := h.ctrl.GetUnit(r.Context(), idx)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, unit)
+}
+
+// getHealth reads the live status of every unit with their total
+// estimated power draw.
+func (h *Handlers) getHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ctrl.Health(r.Context()))
+}
+
+// getMetrics serves unit health in the Prometheus text format. Scrapers
+// authenticate with the api-key query parameter.
+func (h *Handlers) getMetrics(w http.ResponseWriter, r *http.Request) {
+	health := h.ctrl.Health(r.Context())
+	var b strings.Builder
+	gauge := func(name, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+	}
+	sample := func(name string, v float64, labels ...string) {
+		b.WriteString(name)
+		if len(labels) > 0 {
+			b.WriteString("{")
+			for i := 0; i < len(labels); i += 2 {
+				if i > 0 {
+					b.WriteString(",")
+				}
+				fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+			}
+			b.WriteString("}")
+		}
+		fmt.Fprintf(&b, " %s\n", strconv.FormatFloat(v, 'g', -1, 64))
+	}
+
+	gauge("amplipi_power_watts", "Estimated power draw of all preamp units.")
+	sample("amplipi_power_watts", health.Watts)
+	gauge("amplipi_unit_power_watts", "Estimated power draw of a preamp unit.")
+	for _, u := range health.Units {
+		if u.Estimate != nil {
+			sample("amplipi_unit_power_watts", u.Estimate.Watts, "unit", strconv.Itoa(u.Unit))
+		}
+	}
+	gauge("amplipi_unit_rail_volts", "Amp supply rail voltage.")
+	for _, u := range health.Units {
+		if u.Estimate == nil {
+			continue
+		}
+		sample("amplipi_unit_rail_volts", float64(u.Estimate.HV1Volts), "unit", strconv.Itoa(u.Unit), "rail", "hv1")
+		if u.Estimate.HV2Volts > 0 {
+			sample("amplipi_unit_rail_volts", float64(u.Estimate.HV2Volts), "unit", strconv.Itoa(u.Unit), "rail", "hv2")
+		}
+	}
+	gauge("amplipi_unit_amps_enabled", "Zone amps enabled.")
+	for _, u := range health.Units {
+		if u.Estimate != nil {
+			sample("amplipi_unit_amps_enabled", float64(u.Estimate.AmpsEnabled), "unit", strconv.Itoa(u.Unit))
+		}
+	}
+	gauge("amplipi_unit_zones_playing", "Unmuted zones on a source that may be playing.")
+	for _, u := range health.Units {
+		if u.Estimate != nil {
+			sample("amplipi_unit_zones_playing", float64(u.Estimate.ZonesPlaying), "unit", strconv.Itoa(u.Unit))
+		}
+	}
+	gauge("amplipi_unit_temperature_celsius", "Heatsink and power supply temperatures.")
+	for _, u := range health.Units {
+		if t := u.Temps; t != nil {
+			unit := strconv.Itoa(u.Unit)
+			sample("amplipi_unit_temperature_celsius", float64(t.Amp1C), "unit", unit, "sensor", "amp1")
+			sample("amplipi_unit_temperature_celsius", float64(t.Amp2C), "unit", unit, "sensor", "amp2")
+			sample("amplipi_unit_temperature_celsius", float64(t.PSU1C), "unit", unit, "sensor", "psu1")
+			sample("amplipi_unit_temperature_celsius", float64(t.PSU2C), "unit", unit, "sensor", "psu2")
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	io.WriteString(w, b.String())
+}
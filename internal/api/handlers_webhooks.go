@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func (h *Handlers) getWebhooks(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": h.ctrl.GetWebhooks()})
+}
+
+func (h *Handlers) createWebhook(w http.ResponseWriter, r *http.Request) {
+	var req models.WebhookUpdate
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	hooks, appErr := h.ctrl.CreateWebhook(r.Context(), req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": hooks})
+}
+
+func (h *Handlers) setWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "wid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var upd models.WebhookUpdate
+	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	hooks, appErr := h.ctrl.SetWebhook(r.Context(), id, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": hooks})
+}
+
+func (h *Handlers) deleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "wid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	hooks, appErr := h.ctrl.DeleteWebhook(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"webhooks": hooks})
+}
+
+// testWebhook sends a ping event to one webhook. The delivery happens in
+// the background; failures are logged.
+func (h *Handlers) testWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "wid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if appErr := h.ctrl.TestWebhook(r.Context(), id); appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
@@ -2,13 +2,32 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
+// requireZones answers 404 on streamer units, which have no amplified
+// zones or groups.
+func (h *Handlers) requireZones(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.ctrl.StreamerMode() {
+			writeError(w, models.ErrNotFound("zones and groups are not available on a streamer unit"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (h *Handlers) getZones(w http.ResponseWriter, r *http.Request) {
-	writeJSON(w, http.StatusOK, map[string]interface{}{"zones": h.ctrl.GetZones()})
+	units, appErr := h.volumeUnits(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"zones": zonesInUnits(h.ctrl.GetZones(), units)})
 }
 
 func (h *Handlers) getZone(w http.ResponseWriter, r *http.Request) {
@@ -17,11 +36,20 @@ func (h *Handlers) getZone(w http.ResponseWriter, r *http.Request) {
 		writeError(w, err)
 		return
 	}
+	units, appErr := h.volumeUnits(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
 	z, appErr := h.ctrl.GetZone(id)
 	if appErr != nil {
 		writeError(w, appErr)
 		return
 	}
+	if units != "" {
+		writeJSON(w, http.StatusOK, inUnits(*z, units))
+		return
+	}
 	writeJSON(w, http.StatusOK, z)
 }
 
@@ -31,29 +59,184 @@ func (h *Handlers) setZone(w http.ResponseWriter, r *http.Request) {
 		writeError(w, err)
 		return
 	}
-	var upd models.ZoneUpdate
-	if err := json.NewDecoder(r.Body).Decode(&upd); err != nil {
+	units, appErr := h.volumeUnits(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	var body zoneBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
 		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
 		return
 	}
+	upd, appErr := body.update(units)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
 	state, appErr := h.ctrl.SetZone(r.Context(), id, upd)
 	if appErr != nil {
 		writeError(w, appErr)
 		return
 	}
-	writeJSON(w, http.StatusOK, state)
+	writeJSON(w, http.StatusOK, stateWithUnits(state, units))
 }
 
 func (h *Handlers) setZones(w http.ResponseWriter, r *http.Request) {
-	var req models.MultiZoneUpdate
+	units, appErr := h.volumeUnits(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	var body struct {
+		ZoneIDs []int    `json:"zones"`
+		Update  zoneBody `json:"update"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	upd, appErr := body.Update.update(units)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	state, appErr := h.ctrl.SetZones(r.Context(), models.MultiZoneUpdate{ZoneIDs: body.ZoneIDs, Update: upd})
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, stateWithUnits(state, units))
+}
+
+func (h *Handlers) zoneVolUp(w http.ResponseWriter, r *http.Request)   { h.stepZoneVol(w, r, 1) }
+func (h *Handlers) zoneVolDown(w http.ResponseWriter, r *http.Request) { h.stepZoneVol(w, r, -1) }
+
+// identifyZone plays a test tone or the zone name through one zone. It
+// blocks until playback finishes, like /api/announce.
+func (h *Handlers) identifyZone(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "zid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req models.ZoneIdentify
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	state, appErr := h.ctrl.IdentifyZone(r.Context(), id, req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (h *Handlers) playCalibrationNoise(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "zid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req models.CalibrationNoise
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
+		return
+	}
+	state, appErr := h.ctrl.PlayCalibrationNoise(r.Context(), id, req)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (h *Handlers) setZoneCalibration(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "zid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req models.CalibrationMeasurement
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, models.ErrBadRequest("invalid JSON: "+err.Error()))
 		return
 	}
-	state, appErr := h.ctrl.SetZones(r.Context(), req)
+	state, appErr := h.ctrl.SetZoneCalibration(r.Context(), id, req)
 	if appErr != nil {
 		writeError(w, appErr)
 		return
 	}
 	writeJSON(w, http.StatusOK, state)
 }
+
+func (h *Handlers) clearZoneCalibration(w http.ResponseWriter, r *http.Request) {
+	id, err := intParam(r, "zid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	state, appErr := h.ctrl.ClearZoneCalibration(r.Context(), id)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, state)
+}
+
+// stepZoneVol moves a zone's volume by one step in direction dir (+1/-1).
+func (h *Handlers) stepZoneVol(w http.ResponseWriter, r *http.Request, dir int) {
+	id, err := intParam(r, "zid")
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	units, appErr := h.volumeUnits(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	step, appErr := decodeVolumeStep(r)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	var upd models.ZoneUpdate
+	if step.Vol != nil {
+		d := dir * *step.Vol
+		upd.VolDelta = &d
+	} else {
+		d := float64(dir) * *step.VolF
+		upd.VolDeltaF = &d
+	}
+	state, appErr := h.ctrl.SetZone(r.Context(), id, upd)
+	if appErr != nil {
+		writeError(w, appErr)
+		return
+	}
+	writeJSON(w, http.StatusOK, stateWithUnits(state, units))
+}
+
+// decodeVolumeStep reads the optional vol_up/vol_down body. An empty body
+// gives the default step; negative steps are rejected.
+func decodeVolumeStep(r *http.Request) (models.VolumeStep, *models.AppError) {
+	var step models.VolumeStep
+	if err := json.NewDecoder(r.Body).Decode(&step); err != nil && !errors.Is(err, io.EOF) {
+		return step, models.ErrBadRequest("invalid JSON: " + err.Error())
+	}
+	switch {
+	case step.Vol != nil:
+		if *step.Vol < 0 {
+			return step, models.ErrBadRequest("vol step must not be negative")
+		}
+	case step.VolF != nil:
+		if *step.VolF < 0 || *step.VolF > 1 {
+			return step, models.ErrBadRequest("vol_f step must be between 0 and 1")
+		}
+	default:
+		f := models.DefaultVolStepF
+		step.VolF = &f
+	}
+	return step, nil
+}
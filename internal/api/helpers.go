@@ -4,25 +4,37 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"io"
 	"net/http"
 	"strconv"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/graphql-go/graphql"
+	"github.com/micro-nova/amplipi-go/internal/artwork"
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/logs"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
 // Handlers holds dependencies for all HTTP handlers.
 type Handlers struct {
-	ctrl   Controller
-	events EventBus
+	ctrl    Controller
+	events  EventBus
+	auth    *auth.Service
+	limiter *limiter
+
+	graphQLSchema *graphql.Schema // nil unless EnableGraphQL
 }
 
 // Controller is the interface the handlers use to interact with the system state.
 type Controller interface {
 	State() models.State
+	Poll(rev int64) (models.StatePoll, bool)
 	GetSources() []models.Source
 	GetSource(id int) (*models.Source, *models.AppError)
 	SetSource(ctx context.Context, id int, upd models.SourceUpdate) (models.State, *models.AppError)
+	GetSourceSDP(id int, origin string) (string, *models.AppError)
 	GetZones() []models.Zone
 	GetZone(id int) (*models.Zone, *models.AppError)
 	SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError)
@@ -32,8 +44,11 @@ type Controller interface {
 	CreateGroup(ctx context.Context, req models.GroupUpdate) (models.State, *models.AppError)
 	SetGroup(ctx context.Context, id int, upd models.GroupUpdate) (models.State, *models.AppError)
 	DeleteGroup(ctx context.Context, id int) (models.State, *models.AppError)
+	StartParty(ctx context.Context, req models.PartyRequest) (models.State, *models.AppError)
+	EndParty(ctx context.Context) (models.State, *models.AppError)
 	GetStreams() []models.Stream
 	GetStream(id int) (*models.Stream, *models.AppError)
+	StreamTypes() []models.StreamSchema
 	CreateStream(ctx context.Context, req models.StreamCreate) (models.State, *models.AppError)
 	SetStream(ctx context.Context, id int, upd models.StreamUpdate) (models.State, *models.AppError)
 	DeleteStream(ctx context.Context, id int) (models.State, *models.AppError)
@@ -43,19 +58,94 @@ type Controller interface {
 	CreatePreset(ctx context.Context, req models.PresetCreate) (models.State, *models.AppError)
 	SetPreset(ctx context.Context, id int, upd models.PresetUpdate) (models.State, *models.AppError)
 	DeletePreset(ctx context.Context, id int) (models.State, *models.AppError)
-	LoadPreset(ctx context.Context, id int) (models.State, *models.AppError)
+	LoadPresetWithReport(ctx context.Context, id int) (models.State, models.PresetReport, *models.AppError)
+	CreateSnapshot() models.Snapshot
+	GetSnapshots() []models.Snapshot
+	RestoreSnapshot(ctx context.Context, id string) (models.State, models.PresetReport, *models.AppError)
+	GetRevisions() []models.Revision
+	Undo(ctx context.Context) (models.State, models.Revision, *models.AppError)
 	GetInfo() models.Info
-	FactoryReset(ctx context.Context) (models.State, *models.AppError)
+	GetUnits(ctx context.Context) []models.UnitStatus
+	GetUnit(ctx context.Context, idx int) (models.UnitStatus, *models.AppError)
+	GetProfileOverride() (models.ProfileOverrideStatus, *models.AppError)
+	SetProfileOverride(ctx context.Context, upd hardware.ProfileOverride) (models.ProfileOverrideStatus, *models.AppError)
+	Health(ctx context.Context) models.Health
+	GetFans(ctx context.Context) models.FanStatus
+	SetFanCurve(ctx context.Context, upd models.FanCurveUpdate) (models.FanStatus, *models.AppError)
+	SetHostname(ctx context.Context, upd models.HostnameUpdate) (models.Info, *models.AppError)
+	GetTime(ctx context.Context) (models.TimeStatus, *models.AppError)
+	SetTime(ctx context.Context, upd models.TimeUpdate) (models.TimeStatus, *models.AppError)
+	Timezones(ctx context.Context) ([]string, *models.AppError)
+	StreamerMode() bool
+	ConfirmFactoryReset(ctx context.Context, req models.FactoryResetRequest) (models.FactoryResetResponse, *models.AppError)
+	Provision(ctx context.Context, p models.Provision) (models.State, *models.AppError)
+	Power(ctx context.Context, action, confirm string) (models.PowerResponse, *models.AppError)
+	GetSettings() models.Settings
+	SetSettings(ctx context.Context, upd models.SettingsUpdate) (models.Settings, *models.AppError)
+	OpenMatterCommissioning(ctx context.Context, reset bool) (models.MatterSettings, *models.AppError)
 	LoadConfig(ctx context.Context, incoming models.State) (models.State, *models.AppError)
+	ValidateConfig(incoming models.State) (models.ConfigValidation, *models.AppError)
+	ExportConfig(streams, presets bool) models.ConfigExport
+	ImportConfig(ctx context.Context, req models.ConfigImport) (models.State, *models.AppError)
 	TestPreamp(ctx context.Context) (map[string]interface{}, error)
 	TestFans(ctx context.Context) (map[string]interface{}, error)
+	RunSelfTest(ctx context.Context) models.SelfTest
+	TestSpeakers(ctx context.Context, req models.SpeakerTest) (map[string]interface{}, error)
+	WriteDiagnostics(ctx context.Context, w io.Writer) error
+	LogBuffer() *logs.Buffer
+	GetStreamLogs(id, lines int) ([]models.ProcessLog, *models.AppError)
+	RestartStream(ctx context.Context, id int) (models.State, *models.AppError)
+	ResetStreamPairing(ctx context.Context, id int) (models.State, *models.AppError)
 	Announce(ctx context.Context, req models.AnnounceRequest) (models.State, *models.AppError)
+	GetClips() (models.ClipLibrary, *models.AppError)
+	AddClip(ctx context.Context, name, filename string, r io.Reader) (models.Clip, *models.AppError)
+	DeleteClip(ctx context.Context, name string) (models.ClipLibrary, *models.AppError)
+	ClipPath(name string) (string, *models.AppError)
+	GetOutputs() []models.AudioOutput
+	GetAudioCards() []models.AudioCard
+	CreateOutput(ctx context.Context, req models.AudioOutputUpdate) ([]models.AudioOutput, *models.AppError)
+	SetOutput(ctx context.Context, id int, upd models.AudioOutputUpdate) ([]models.AudioOutput, *models.AppError)
+	DeleteOutput(ctx context.Context, id int) ([]models.AudioOutput, *models.AppError)
+	AudioRouting() models.AudioRouting
+	GetSnapcast(ctx context.Context) (*models.SnapcastStatus, *models.AppError)
+	SetSnapClient(ctx context.Context, id string, upd models.SnapClientUpdate) (*models.SnapcastStatus, *models.AppError)
+	SetSnapGroup(ctx context.Context, id string, upd models.SnapGroupUpdate) (*models.SnapcastStatus, *models.AppError)
+	BrowseStream(ctx context.Context, id int, path string) ([]models.BrowsableItem, *models.AppError)
+	GetStreamQueue(id int) (*models.StreamQueue, *models.AppError)
+	GetStreamImage(ctx context.Context, id int, opts artwork.Options) (*artwork.Image, *models.AppError)
+	GetCastDevices() []models.CastDevice
+	VerifyCastToken(token string) bool
+	StreamSourceAudio(ctx context.Context, id int, w io.Writer) *models.AppError
+	GetShares() []models.NetworkShare
+	CreateShare(ctx context.Context, req models.NetworkShareUpdate) ([]models.NetworkShare, *models.AppError)
+	SetShare(ctx context.Context, id int, upd models.NetworkShareUpdate) ([]models.NetworkShare, *models.AppError)
+	DeleteShare(ctx context.Context, id int) ([]models.NetworkShare, *models.AppError)
+	MountShare(ctx context.Context, id int, mount bool) ([]models.NetworkShare, *models.AppError)
+	GetLEDs(ctx context.Context) []models.LEDs
+	SetLEDs(ctx context.Context, unit int, upd models.LEDUpdate) ([]models.LEDs, *models.AppError)
+	IdentifyLEDs(ctx context.Context, req models.LEDIdentify) ([]models.LEDs, *models.AppError)
+	StopLEDPatterns(ctx context.Context) []models.LEDs
+	GetTriggers() []models.TriggerStatus
+	CreateTrigger(ctx context.Context, req models.TriggerUpdate) ([]models.TriggerStatus, *models.AppError)
+	SetTrigger(ctx context.Context, id int, upd models.TriggerUpdate) ([]models.TriggerStatus, *models.AppError)
+	DeleteTrigger(ctx context.Context, id int) ([]models.TriggerStatus, *models.AppError)
+	GetWebhooks() []models.Webhook
+	CreateWebhook(ctx context.Context, req models.WebhookUpdate) ([]models.Webhook, *models.AppError)
+	SetWebhook(ctx context.Context, id int, upd models.WebhookUpdate) ([]models.Webhook, *models.AppError)
+	DeleteWebhook(ctx context.Context, id int) ([]models.Webhook, *models.AppError)
+	TestWebhook(ctx context.Context, id int) *models.AppError
+	IdentifyZone(ctx context.Context, id int, req models.ZoneIdentify) (models.State, *models.AppError)
+	PlayCalibrationNoise(ctx context.Context, id int, req models.CalibrationNoise) (models.State, *models.AppError)
+	SetZoneCalibration(ctx context.Context, id int, req models.CalibrationMeasurement) (models.State, *models.AppError)
+	ClearZoneCalibration(ctx context.Context, id int) (models.State, *models.AppError)
 }
 
-// EventBus is the interface for subscribing to state change events.
+// EventBus is the interface for subscribing to state changes and events.
 type EventBus interface {
 	Subscribe(id string) <-chan models.State
 	Unsubscribe(id string)
+	SubscribeEvents(id string) <-chan models.Event
+	UnsubscribeEvents(id string)
 }
 
 // writeJSON writes a JSON response with the given status code.
@@ -0,0 +1,220 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"golang.org/x/time/rate"
+)
+
+// Limits protects the API from clients sending too many or too large
+// requests, e.g. on a unit exposed to a large network.
+type Limits struct {
+	Rate           float64 `json:"rate"`             // requests per second per client; 0 = unlimited
+	Burst          int     `json:"burst"`            // requests a client may send at once
+	LoginPerMinute int     `json:"login_per_minute"` // login and pairing attempts per client; 0 = unlimited
+	MaxBody        int64   `json:"max_body"`         // request body bytes; 0 = unlimited
+	MaxUpload      int64   `json:"max_upload"`       // body bytes of config uploads, backup restores and announcement clips
+}
+
+// DefaultLimits are generous enough for the web UI and apps polling the
+// state several times a second.
+var DefaultLimits = Limits{
+	Rate:           20,
+	Burst:          40,
+	LoginPerMinute: 10,
+	MaxBody:        1 << 20,
+	MaxUpload:      100 << 20,
+}
+
+var limits = DefaultLimits
+
+// SetLimits sets the limits of routers created afterwards.
+func SetLimits(l Limits) { limits = l }
+
+// uploadPaths may send bodies up to MaxUpload.
+var uploadPaths = map[string]bool{
+	"/api/load":            true,
+	"/api/config/validate": true,
+	"/api/import":          true,
+	"/api/restore":         true,
+	"/api/announce":        true,
+	"/api/clips":           true,
+}
+
+// loginPaths are limited to LoginPerMinute against password and pairing
+// guessing.
+var loginPaths = map[string]bool{
+	"/auth/login": true,
+	"/api/pair":   true,
+}
+
+// clientIdle is how long a client's limiter is kept after its last
+// request; idle limiters are dropped every clientSweep.
+const (
+	clientIdle  = 10 * time.Minute
+	clientSweep = time.Minute
+)
+
+// LimitStats are the limits in force and how often they were hit.
+type LimitStats struct {
+	Limits
+	Limited      uint64 `json:"limited"`       // requests refused with 429
+	LoginLimited uint64 `json:"login_limited"` // login and pairing attempts refused with 429
+	TooLarge     uint64 `json:"too_large"`     // requests refused with 413
+	Clients      int    `json:"clients"`       // clients seen in the last 10 minutes
+}
+
+// limiter enforces Limits per client IP.
+type limiter struct {
+	limits Limits
+
+	mu      sync.Mutex
+	clients map[string]*client // by clientKey
+	swept   time.Time
+
+	limited, loginLimited, tooLarge atomic.Uint64
+}
+
+type client struct {
+	api, login *rate.Limiter
+	lastSeen   time.Time
+	throttled  bool // refused since its last accepted request; logged once
+}
+
+func newLimiter(l Limits) *limiter {
+	return &limiter{limits: l, clients: make(map[string]*client)}
+}
+
+// Middleware refuses requests over the client's rate with 429 and bodies
+// over the size limit with 413. Clients are told apart by the address of
+// the connection, not forwarding headers they could forge, so it must run
+// before middleware.RealIP. Loopback and Unix socket clients, i.e.
+// on-device tools, are not rate limited. IPv6 clients are limited per /64,
+// as a single host usually holds the whole prefix.
+func (l *limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := path.Clean(r.URL.Path) // as routed after middleware.CleanPath
+		size := l.limits.MaxBody
+		if uploadPaths[p] {
+			size = l.limits.MaxUpload
+		}
+		if size > 0 {
+			if r.ContentLength > size {
+				l.tooLarge.Add(1)
+				writeError(w, models.ErrTooLarge(fmt.Sprintf("request body is larger than %d bytes", size)))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, size)
+		}
+
+		if key := clientKey(r); key != "" {
+			login := loginPaths[p] && r.Method == http.MethodPost
+			if ok, retry := l.allow(key, login); !ok {
+				if login {
+					l.loginLimited.Add(1)
+				} else {
+					l.limited.Add(1)
+				}
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.Seconds()))))
+				writeError(w, models.ErrTooManyRequests("too many requests; slow down"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// allow takes a token from the limiter of the client with key, or reports
+// how long to wait for one.
+func (l *limiter) allow(key string, login bool) (bool, time.Duration) {
+	now := time.Now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) > clientSweep {
+		for k, c := range l.clients {
+			if now.Sub(c.lastSeen) > clientIdle {
+				delete(l.clients, k)
+			}
+		}
+		l.swept = now
+	}
+	c := l.clients[key]
+	if c == nil {
+		c = &client{api: rate.NewLimiter(rate.Inf, 0), login: rate.NewLimiter(rate.Inf, 0)}
+		if l.limits.Rate > 0 {
+			c.api = rate.NewLimiter(rate.Limit(l.limits.Rate), max(l.limits.Burst, 1))
+		}
+		if l.limits.LoginPerMinute > 0 {
+			c.login = rate.NewLimiter(rate.Every(time.Minute/time.Duration(l.limits.LoginPerMinute)), l.limits.LoginPerMinute)
+		}
+		l.clients[key] = c
+	}
+	c.lastSeen = now
+
+	lim := c.api
+	if login {
+		lim = c.login
+	}
+	res := lim.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		if !c.throttled {
+			slog.Warn("api: rate limiting client", "client", key, "login", login)
+			c.throttled = true
+		}
+		return false, delay
+	}
+	c.throttled = false
+	return true, 0
+}
+
+// Stats returns the limits and counters.
+func (l *limiter) Stats() LimitStats {
+	l.mu.Lock()
+	n := 0
+	for _, c := range l.clients {
+		if time.Since(c.lastSeen) <= clientIdle {
+			n++
+		}
+	}
+	l.mu.Unlock()
+	return LimitStats{
+		Limits:       l.limits,
+		Limited:      l.limited.Load(),
+		LoginLimited: l.loginLimited.Load(),
+		TooLarge:     l.tooLarge.Load(),
+		Clients:      n,
+	}
+}
+
+// clientKey returns the IPv4 address or IPv6 /64 prefix of the
+// connection's peer, or "" for loopback and Unix socket clients.
+func clientKey(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() {
+		return ""
+	}
+	if ip.To4() != nil {
+		return ip.String()
+	}
+	prefix := net.IPNet{IP: ip.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}
+	return prefix.String()
+}
+
+func (h *Handlers) getLimits(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.limiter.Stats())
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"log/slog"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
@@ -15,21 +16,43 @@ func NewRouter(ctrl Controller, authSvc *auth.Service, bus EventBus) http.Handle
 	// Global middleware
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
+	lim := newLimiter(limits)
+	r.Use(lim.Middleware) // before RealIP: limits the connection's address
 	r.Use(middleware.RealIP)
 	r.Use(corsMiddleware)
 	r.Use(middleware.CleanPath)
+	// gzip or deflate for clients that accept it. Only these types are
+	// compressed: SSE streams (text/event-stream) must reach the client
+	// event by event, and audio and backups are compressed already.
+	r.Use(middleware.Compress(5, "application/json", "text/html", "text/plain"))
 
-	h := &Handlers{ctrl: ctrl, events: bus}
+	h := &Handlers{ctrl: ctrl, events: bus, auth: authSvc, limiter: lim}
+	if graphQLEnabled {
+		schema, err := newGraphQLSchema(ctrl, bus)
+		if err != nil {
+			slog.Error("api: GraphQL disabled", "err", err)
+		} else {
+			h.graphQLSchema = &schema
+		}
+	}
 
 	// Auth routes (no auth required)
 	r.Group(func(r chi.Router) {
 		r.Get("/auth/login", h.loginPage)
 		r.Post("/auth/login", h.loginPost)
+
+		// Source audio for Google Cast devices (token in URL)
+		r.Get("/cast/sources/{sid}.mp3", h.castSourceAudio)
+
+		// App pairing, confirmed at the unit
+		r.Get("/api/pair", h.getPairing)
+		r.Post("/api/pair", h.pair)
 	})
 
 	// API routes (auth required)
 	r.Group(func(r chi.Router) {
 		r.Use(authSvc.Middleware)
+		r.Use(h.markAccess)
 
 		// System state
 		r.Get("/api", h.getState)
@@ -39,26 +62,50 @@ func NewRouter(ctrl Controller, authSvc *auth.Service, bus EventBus) http.Handle
 		r.Get("/api/sources", h.getSources)
 		r.Get("/api/sources/{sid}", h.getSource)
 		r.Patch("/api/sources/{sid}", h.setSource)
+		r.Get("/api/sources/{sid}/sdp", h.getSourceSDP)
+
+		// Zones and groups (not on streamer units)
+		r.Group(func(r chi.Router) {
+			r.Use(h.requireZones)
 
-		// Zones
-		r.Get("/api/zones", h.getZones)
-		r.Get("/api/zones/{zid}", h.getZone)
-		r.Patch("/api/zones/{zid}", h.setZone)
-		r.Patch("/api/zones", h.setZones)
+			r.Get("/api/zones", h.getZones)
+			r.Get("/api/zones/{zid}", h.getZone)
+			r.Patch("/api/zones/{zid}", h.setZone)
+			r.Patch("/api/zones", h.setZones)
+			r.Post("/api/zones/{zid}/vol_up", h.zoneVolUp)
+			r.Post("/api/zones/{zid}/vol_down", h.zoneVolDown)
+			r.Post("/api/zones/{zid}/identify", h.identifyZone)
+			r.Post("/api/zones/{zid}/calibration/noise", h.playCalibrationNoise)
+			r.Post("/api/zones/{zid}/calibration", h.setZoneCalibration)
+			r.Delete("/api/zones/{zid}/calibration", h.clearZoneCalibration)
 
-		// Groups
-		r.Get("/api/groups", h.getGroups)
-		r.Get("/api/groups/{gid}", h.getGroup)
-		r.Post("/api/group", h.createGroup)
-		r.Patch("/api/groups/{gid}", h.setGroup)
-		r.Delete("/api/groups/{gid}", h.deleteGroup)
+			r.Get("/api/groups", h.getGroups)
+			r.Get("/api/groups/{gid}", h.getGroup)
+			r.Post("/api/group", h.createGroup)
+			r.Patch("/api/groups/{gid}", h.setGroup)
+			r.Delete("/api/groups/{gid}", h.deleteGroup)
+			r.Post("/api/groups/{gid}/vol_up", h.groupVolUp)
+			r.Post("/api/groups/{gid}/vol_down", h.groupVolDown)
+
+			// Party mode: every zone on one source, one call to undo
+			r.Post("/api/party", h.startParty)
+			r.Delete("/api/party", h.endParty)
+		})
 
 		// Streams
 		r.Get("/api/streams", h.getStreams)
+		r.Get("/api/streams/types", h.getStreamTypes)
 		r.Get("/api/streams/{sid}", h.getStream)
 		r.Post("/api/stream", h.createStream)
 		r.Patch("/api/streams/{sid}", h.setStream)
 		r.Delete("/api/streams/{sid}", h.deleteStream)
+		r.Get("/api/streams/{sid}/browse", h.browseStream)
+		r.Get("/api/streams/{sid}/browse/*", h.browseStream)
+		r.Get("/api/streams/{sid}/queue", h.getStreamQueue)
+		r.Get("/api/streams/{sid}/image", h.getStreamImage)
+		r.Get("/api/streams/{sid}/logs", h.getStreamLogs)
+		r.Post("/api/streams/{sid}/restart", h.restartStream)
+		r.Delete("/api/streams/{sid}/pairing", h.resetStreamPairing)
 		r.Post("/api/streams/{sid}/{cmd}", h.execStreamCmd)
 
 		// Presets
@@ -69,17 +116,134 @@ func NewRouter(ctrl Controller, authSvc *auth.Service, bus EventBus) http.Handle
 		r.Delete("/api/presets/{pid}", h.deletePreset)
 		r.Post("/api/presets/{pid}/load", h.loadPreset)
 
+		// State snapshots: save and restore everything without a preset
+		r.Post("/api/state/snapshot", h.createSnapshot)
+		r.Get("/api/state/snapshots", h.getSnapshots)
+		r.Post("/api/state/restore/{snid}", h.restoreSnapshot)
+
+		// Undo of recent routing and volume changes
+		r.Get("/api/revisions", h.getRevisions)
+		r.Post("/api/undo", h.undo)
+
+		// GraphQL queries and subscriptions, if enabled
+		if h.graphQLSchema != nil {
+			r.Get("/api/graphql", h.graphQL)
+			r.Post("/api/graphql", h.graphQL)
+		}
+
+		// Physical outputs (ALSA DACs, including hotplugged USB devices)
+		r.Get("/api/outputs", h.getOutputs)
+		r.Post("/api/output", h.createOutput)
+		r.Patch("/api/outputs/{oid}", h.setOutput)
+		r.Delete("/api/outputs/{oid}", h.deleteOutput)
+		r.Get("/api/audio/routing", h.getAudioRouting)
+
+		// Snapcast satellite speakers
+		r.Get("/api/snapcast", h.getSnapcast)
+		r.Patch("/api/snapcast/clients/{cid}", h.setSnapClient)
+		r.Patch("/api/snapcast/groups/{gid}", h.setSnapGroup)
+
+		// Network shares (SMB/NFS) mounted into the media library
+		r.Get("/api/shares", h.getShares)
+		r.Post("/api/share", h.createShare)
+		r.Patch("/api/shares/{shid}", h.setShare)
+		r.Delete("/api/shares/{shid}", h.deleteShare)
+		r.Post("/api/shares/{shid}/mount", h.mountShare)
+		r.Post("/api/shares/{shid}/unmount", h.unmountShare)
+
+		// Google Cast devices
+		r.Get("/api/cast", h.getCastDevices)
+
 		// Announcements
 		r.Post("/api/announce", h.announce)
+		r.Get("/api/clips", h.getClips)
+		r.Post("/api/clips", h.addClip)
+		r.Get("/api/clips/{name}", h.getClipAudio)
+		r.Delete("/api/clips/{name}", h.deleteClip)
+
+		// Home Assistant integration
+		r.Get("/api/ha/discovery", h.getHADiscovery)
+		r.Get("/api/ha/states", h.getHAStates)
+		r.Post("/api/ha/services/{entity}/{service}", h.callHAService)
+
+		// Matter
+		r.Get("/api/matter", h.getMatter)
+		r.With(h.requireAdmin).Post("/api/matter/commissioning", h.openMatterCommissioning)
 
 		// System
 		r.Get("/api/info", h.getInfo)
-		r.Post("/api/factory_reset", h.factoryReset)
-		r.Post("/api/load", h.loadConfig)
+		r.With(h.requireAdmin).Patch("/api/system/hostname", h.setHostname)
+		r.Get("/api/system/time", h.getTime)
+		r.With(h.requireAdmin).Patch("/api/system/time", h.setTime)
+		r.Get("/api/system/timezones", h.getTimezones)
+		r.With(h.requireAdmin).Post("/api/factory_reset", h.factoryReset)
+		r.With(h.requireAdmin).Post("/api/provision", h.provision)
+		r.With(h.requireAdmin).Post("/api/reboot", h.reboot)
+		r.With(h.requireAdmin).Post("/api/shutdown", h.shutdown)
+		r.With(h.requireAdmin).Post("/api/load", h.loadConfig)
+		r.Post("/api/config/validate", h.validateConfig)
+		r.Get("/api/export", h.exportConfig)
+		r.With(h.requireAdmin).Post("/api/import", h.importConfig)
+		r.Get("/api/limits", h.getLimits)
+		r.Get("/api/settings", h.getSettings)
+		r.Patch("/api/settings", h.setSettings)
 
 		// Hardware tests
 		r.Post("/api/test/preamp", h.testPreamp)
 		r.Post("/api/test/fans", h.testFans)
+		r.Post("/api/test/speakers", h.testSpeakers)
+		r.Post("/api/test/selftest", h.runSelfTest)
+		r.Get("/api/diagnostics", h.getDiagnostics)
+
+		// Daemon logs
+		r.Get("/api/logs", h.getLogs)
+		r.Get("/api/logs/tail", h.tailLogs)
+
+		// Preamp units: main unit and expanders
+		r.Get("/api/hardware/units", h.getUnits)
+		r.Get("/api/hardware/units/{unit}", h.getUnit)
+		r.Get("/api/health", h.getHealth)
+		r.Get("/metrics", h.getMetrics)
+
+		// Hardware profile override, applied at the next start
+		r.Get("/api/hardware/profile", h.getProfileOverride)
+		r.With(h.requireAdmin).Patch("/api/hardware/profile", h.setProfileOverride)
+
+		// Front-panel LEDs
+		r.Get("/api/hardware/leds", h.getLEDs)
+		r.Patch("/api/hardware/leds/{unit}", h.setLEDs)
+		r.Post("/api/hardware/leds/identify", h.identifyLEDs)
+		r.Delete("/api/hardware/leds/identify", h.stopLEDPatterns)
+
+		// Fan curve
+		r.Get("/api/hardware/fans", h.getFans)
+		r.Patch("/api/hardware/fans", h.setFanCurve)
+		r.Delete("/api/hardware/fans", h.clearFanCurve)
+
+		// Amplifier triggers
+		r.Get("/api/hardware/triggers", h.getTriggers)
+		r.Post("/api/hardware/triggers", h.createTrigger)
+		r.Patch("/api/hardware/triggers/{tid}", h.setTrigger)
+		r.Delete("/api/hardware/triggers/{tid}", h.deleteTrigger)
+
+		// Paired apps
+		r.With(h.requireAdmin).Post("/api/pair/window", h.openPairing)
+		r.With(h.requireAdmin).Delete("/api/pair/window", h.closePairing)
+		r.With(h.requireAdmin).Get("/api/pair/devices", h.getPairedDevices)
+		r.With(h.requireAdmin).Delete("/api/pair/devices/{did}", h.unpairDevice)
+
+		// Permission profiles: keys restricted to some zones and groups
+		r.Get("/api/permissions/me", h.getOwnPermissions)
+		r.With(h.requireAdmin).Get("/api/permissions", h.getPermissions)
+		r.With(h.requireAdmin).Patch("/api/permissions/{uid}", h.setPermissions)
+		r.With(h.requireAdmin).Delete("/api/permissions/{uid}", h.deletePermissions)
+
+		// Webhooks
+		r.Get("/api/webhooks", h.getWebhooks)
+		r.Post("/api/webhooks", h.createWebhook)
+		r.Patch("/api/webhooks/{wid}", h.setWebhook)
+		r.Delete("/api/webhooks/{wid}", h.deleteWebhook)
+		r.Post("/api/webhooks/{wid}/test", h.testWebhook)
 
 		// Firmware (stub)
 		r.Post("/api/firmware/flash", h.flashFirmware)
@@ -91,6 +255,9 @@ func NewRouter(ctrl Controller, authSvc *auth.Service, bus EventBus) http.Handle
 
 		// SSE
 		r.Get("/api/subscribe", h.sseEvents)
+
+		// Polling, for clients that can't use SSE
+		r.Get("/api/poll", h.poll)
 	})
 
 	return r
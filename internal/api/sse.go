@@ -4,12 +4,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
 // sseEvents handles the SSE (Server-Sent Events) endpoint.
 // Clients receive the current state immediately, then stream updates as they happen.
+// Events such as source_auto_off are sent as named SSE events, which
+// EventSource clients listening only for messages ignore.
 func (h *Handlers) sseEvents(w http.ResponseWriter, r *http.Request) {
 	// Verify the client supports streaming
 	flusher, ok := w.(http.Flusher)
@@ -26,6 +31,8 @@ func (h *Handlers) sseEvents(w http.ResponseWriter, r *http.Request) {
 	id := uuid.New().String()
 	ch := h.events.Subscribe(id)
 	defer h.events.Unsubscribe(id)
+	evs := h.events.SubscribeEvents(id)
+	defer h.events.UnsubscribeEvents(id)
 
 	// Send current state immediately
 	sendSSE(w, flusher, h.ctrl.State())
@@ -37,6 +44,11 @@ func (h *Handlers) sseEvents(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			sendSSE(w, flusher, state)
+		case ev, ok := <-evs:
+			if !ok {
+				return
+			}
+			sendSSEEvent(w, flusher, ev)
 		case <-r.Context().Done():
 			return
 		}
@@ -51,3 +63,70 @@ func sendSSE(w http.ResponseWriter, flusher http.Flusher, v interface{}) {
 	_, _ = fmt.Fprintf(w, "data: %s\n\n", data)
 	flusher.Flush()
 }
+
+// sendSSEEvent writes ev as a named SSE event.
+func sendSSEEvent(w http.ResponseWriter, flusher http.Flusher, ev models.Event) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	_, _ = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data)
+	flusher.Flush()
+}
+
+// poll serves clients that poll for changes instead of subscribing, e.g.
+// wall tablets with limited browsers. Query: rev=N, the rev of the last
+// poll, answers 304 Not Modified if nothing has changed since and the
+// changed sections of the state otherwise; without it the whole state is
+// sent. wait=S holds an unchanged poll open up to S seconds (at most
+// models.MaxPollWait) for a change, so clients can poll less often without
+// missing one.
+func (h *Handlers) poll(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	rev := int64(-1)
+	if s := q.Get("rev"); s != "" {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil || n < 0 {
+			writeError(w, models.ErrBadRequest("rev must be a non-negative integer"))
+			return
+		}
+		rev = n
+	}
+	var wait time.Duration
+	if s := q.Get("wait"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n > models.MaxPollWait {
+			writeError(w, models.ErrBadRequest(fmt.Sprintf("wait must be 0-%d seconds", models.MaxPollWait)))
+			return
+		}
+		wait = time.Duration(n) * time.Second
+	}
+
+	var changes <-chan models.State
+	if wait > 0 {
+		// Subscribed before polling so no change is missed in between.
+		id := uuid.New().String()
+		changes = h.events.Subscribe(id)
+		defer h.events.Unsubscribe(id)
+	}
+	p, changed := h.ctrl.Poll(rev)
+	if !changed && wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-changes:
+			p, changed = h.ctrl.Poll(rev)
+		case <-timer.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-cache")
+	if !changed {
+		w.Header().Set("X-State-Rev", strconv.FormatInt(p.Rev, 10))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
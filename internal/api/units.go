@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Zone endpoints can give and take a zone's volume in the units a client
+// works in, so every client rounds the same way: ?units=db or
+// ?units=percent, defaulting to the volume_units setting. Responses then
+// carry "volume" and "units" on each zone, and update bodies may set
+// "volume" instead of vol or vol_f. Without units nothing changes.
+
+// zoneInUnits is a zone with its volume in the request's units.
+type zoneInUnits struct {
+	models.Zone
+	Volume float64 `json:"volume"`
+	Units  string  `json:"units"`
+}
+
+// stateInUnits is a state whose zones carry their volume in the request's
+// units. Its Zones shadow the embedded state's.
+type stateInUnits struct {
+	models.State
+	Zones []zoneInUnits `json:"zones"`
+}
+
+// zoneBody is a zone update that may give the volume in the request's
+// units.
+type zoneBody struct {
+	models.ZoneUpdate
+	Volume *float64 `json:"volume,omitempty"`
+}
+
+// volumeUnits returns the units of r's zone volumes: its units parameter,
+// else the volume_units setting. "" leaves volumes as they are.
+func (h *Handlers) volumeUnits(r *http.Request) (string, *models.AppError) {
+	units := r.URL.Query().Get("units")
+	if units == "" {
+		return h.ctrl.GetSettings().VolumeUnits, nil
+	}
+	if units != models.VolumeUnitsDB && units != models.VolumeUnitsPercent {
+		return "", models.ErrBadRequest(fmt.Sprintf("units must be %q or %q", models.VolumeUnitsDB, models.VolumeUnitsPercent)).WithField("units")
+	}
+	return units, nil
+}
+
+// inUnits returns z with its volume in units.
+func inUnits(z models.Zone, units string) zoneInUnits {
+	v := float64(z.Vol)
+	if units == models.VolumeUnitsPercent {
+		v = math.Round(z.VolF*1000) / 10 // 0.1% is finer than the 1 dB steps
+	}
+	return zoneInUnits{Zone: z, Volume: v, Units: units}
+}
+
+// zonesInUnits returns zones in units, or as they are without units.
+func zonesInUnits(zones []models.Zone, units string) interface{} {
+	if units == "" {
+		return zones
+	}
+	out := make([]zoneInUnits, len(zones))
+	for i, z := range zones {
+		out[i] = inUnits(z, units)
+	}
+	return out
+}
+
+// stateWithUnits returns state with its zones in units, or as it is
+// without units.
+func stateWithUnits(state models.State, units string) interface{} {
+	if units == "" {
+		return state
+	}
+	return stateInUnits{State: state, Zones: zonesInUnits(state.Zones, units).([]zoneInUnits)}
+}
+
+// update returns the zone update with Volume converted to vol or vol_f.
+func (b zoneBody) update(units string) (models.ZoneUpdate, *models.AppError) {
+	upd := b.ZoneUpdate
+	if b.Volume == nil {
+		return upd, nil
+	}
+	v := *b.Volume
+	switch units {
+	case models.VolumeUnitsDB:
+		vol := int(math.Round(v))
+		upd.Vol = &vol
+	case models.VolumeUnitsPercent:
+		if v < 0 || v > 100 {
+			return upd, models.ErrBadRequest("volume must be 0-100 percent").WithField("volume")
+		}
+		volF := v / 100
+		upd.VolF = &volF
+	default:
+		return upd, models.ErrBadRequest("volume needs units: add ?units=db or ?units=percent, or set volume_units").WithField("volume")
+	}
+	return upd, nil
+}
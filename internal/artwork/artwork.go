@@ -0,0 +1,330 @@
+// Package artwork fetches stream artwork and converts it for clients that
+// can't decode images themselves, such as the front panel display: scaled
+// to the panel's size and encoded as raw pixels (RGB565 for TFT panels,
+// grayscale or dithered 1-bit for eInk) or as PNG or JPEG.
+package artwork
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // decoders for image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Output formats.
+const (
+	FormatPNG    = "png"
+	FormatJPEG   = "jpeg"
+	FormatRGB565 = "rgb565" // 16 bits per pixel, big-endian, as ILI9341 TFTs take it
+	FormatGray   = "gray"   // 8 bits per pixel
+	FormatMono   = "mono"   // 1 bit per pixel, MSB first, set for white; rows padded to whole bytes
+)
+
+// Formats are the output formats Convert supports.
+var Formats = []string{FormatPNG, FormatJPEG, FormatRGB565, FormatGray, FormatMono}
+
+// MaxSize is the largest width or height Convert scales to.
+const MaxSize = 1024
+
+const (
+	fetchTimeout = 10 * time.Second
+	maxImageSize = 10 << 20    // bytes of artwork fetched
+	maxPixels    = 4096 * 4096 // pixels of artwork decoded
+	cacheEntries = 32          // original and converted images kept
+)
+
+// FetchError is a failure to fetch artwork from its URL, as opposed to
+// converting artwork that was fetched.
+type FetchError struct {
+	Err error
+}
+
+func (e *FetchError) Error() string { return "artwork: " + e.Err.Error() }
+
+func (e *FetchError) Unwrap() error { return e.Err }
+
+// Options say how to convert artwork. A zero Width or Height follows from
+// the other and the artwork's aspect ratio; both zero keep its size. An
+// empty Format with no size serves the artwork as fetched, and is PNG
+// otherwise.
+type Options struct {
+	Width, Height int
+	Format        string
+}
+
+// Validate checks the size and format.
+func (o Options) Validate() error {
+	if o.Width < 0 || o.Width > MaxSize || o.Height < 0 || o.Height > MaxSize {
+		return fmt.Errorf("width and height must be 0-%d", MaxSize)
+	}
+	if o.Format != "" && !slices.Contains(Formats, o.Format) {
+		return fmt.Errorf("unknown format %q (want one of %s)", o.Format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+// Image is fetched or converted artwork.
+type Image struct {
+	Data        []byte
+	ContentType string
+	// Width and Height are set for converted images, so clients reading
+	// raw pixels know the row length.
+	Width, Height int
+}
+
+// Cache fetches artwork by URL and converts it, keeping the most recently
+// used results: a display asks for the same artwork until the track
+// changes.
+type Cache struct {
+	client *http.Client
+
+	mu      sync.Mutex
+	entries map[string]*Image
+	order   []string // keys of entries, least recently used first
+}
+
+// NewCache creates an empty artwork cache.
+func NewCache() *Cache {
+	return &Cache{
+		client:  &http.Client{Timeout: fetchTimeout},
+		entries: make(map[string]*Image),
+	}
+}
+
+// Get returns the artwork at url converted as opts say.
+func (c *Cache) Get(ctx context.Context, url string, opts Options) (*Image, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s|%dx%d|%s", url, opts.Width, opts.Height, opts.Format)
+	if img := c.lookup(key); img != nil {
+		return img, nil
+	}
+
+	orig := c.lookup(url)
+	if orig == nil {
+		var err error
+		if orig, err = c.fetch(ctx, url); err != nil {
+			return nil, err
+		}
+		c.store(url, orig)
+	}
+	if opts == (Options{}) {
+		return orig, nil
+	}
+	img, err := Convert(orig.Data, opts)
+	if err != nil {
+		return nil, err
+	}
+	c.store(key, img)
+	return img, nil
+}
+
+// fetch downloads the artwork at url. Failures are *FetchError.
+func (c *Cache) fetch(ctx context.Context, url string) (*Image, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, &FetchError{fmt.Errorf("unsupported URL %q", url)}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, &FetchError{err}
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, &FetchError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &FetchError{fmt.Errorf("%s: %s", url, resp.Status)}
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxImageSize+1))
+	if err != nil {
+		return nil, &FetchError{err}
+	}
+	if len(data) > maxImageSize {
+		return nil, &FetchError{fmt.Errorf("%s is larger than %d bytes", url, maxImageSize)}
+	}
+	ctype := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(ctype, "image/") {
+		ctype = http.DetectContentType(data)
+	}
+	return &Image{Data: data, ContentType: ctype}, nil
+}
+
+func (c *Cache) lookup(key string) *Image {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	img, ok := c.entries[key]
+	if ok {
+		c.touch(key)
+	}
+	return img
+}
+
+func (c *Cache) store(key string, img *Image) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; !ok && len(c.order) >= cacheEntries {
+		delete(c.entries, c.order[0])
+		c.order = c.order[1:]
+	}
+	c.entries[key] = img
+	c.touch(key)
+}
+
+// touch makes key the most recently used. Must be called with c.mu held.
+func (c *Cache) touch(key string) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
+
+// Convert decodes an image (JPEG, PNG, GIF or WebP), scales it to fit
+// opts' size, centred on black, and encodes it in opts' format. Images of
+// more than maxPixels are refused before they are decoded, since a small
+// file can declare a huge image.
+func Convert(data []byte, opts Options) (*Image, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("artwork: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, fmt.Errorf("artwork: %dx%d image is larger than %d pixels", cfg.Width, cfg.Height, maxPixels)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("artwork: %w", err)
+	}
+	img := Scale(src, opts.Width, opts.Height)
+	b := img.Bounds()
+	out := &Image{Width: b.Dx(), Height: b.Dy()}
+
+	var buf bytes.Buffer
+	switch opts.Format {
+	case "", FormatPNG:
+		out.ContentType = "image/png"
+		err = png.Encode(&buf, img)
+	case FormatJPEG:
+		out.ContentType = "image/jpeg"
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85})
+	case FormatRGB565:
+		out.ContentType = "application/octet-stream"
+		buf.Write(RGB565(img))
+	case FormatGray:
+		out.ContentType = "application/octet-stream"
+		buf.Write(Gray(img))
+	case FormatMono:
+		out.ContentType = "application/octet-stream"
+		buf.Write(Mono(img))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("artwork: %w", err)
+	}
+	out.Data = buf.Bytes()
+	return out, nil
+}
+
+// Scale fits src into a w x h image, keeping its aspect ratio and
+// centring it on black. A zero w or h follows from the other and the
+// aspect ratio; both zero keep the size of src.
+func Scale(src image.Image, w, h int) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	switch {
+	case w == 0 && h == 0:
+		w, h = sw, sh
+	case h == 0:
+		h = max(1, sh*w/sw)
+	case w == 0:
+		w = max(1, sw*h/sh)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.Black, image.Point{}, draw.Src)
+
+	// Fit inside, centred.
+	fw, fh := w, sh*w/sw
+	if fh > h {
+		fw, fh = sw*h/sh, h
+	}
+	fw, fh = max(1, fw), max(1, fh)
+	x0, y0 := (w-fw)/2, (h-fh)/2
+	draw.CatmullRom.Scale(dst, image.Rect(x0, y0, x0+fw, y0+fh), src, sb, draw.Over, nil)
+	return dst
+}
+
+// RGB565 encodes img as big-endian RGB565 pixels, row by row.
+func RGB565(img image.Image) []byte {
+	b := img.Bounds()
+	out := make([]byte, 0, b.Dx()*b.Dy()*2)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			v := uint16(r>>11)<<11 | uint16(g>>10)<<5 | uint16(bl>>11)
+			out = append(out, byte(v>>8), byte(v))
+		}
+	}
+	return out
+}
+
+// Gray encodes img as 8-bit grayscale pixels, row by row.
+func Gray(img image.Image) []byte {
+	b := img.Bounds()
+	out := make([]byte, 0, b.Dx()*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out = append(out, color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+		}
+	}
+	return out
+}
+
+// Mono encodes img as 1-bit pixels, Floyd-Steinberg dithered so shading
+// survives on eInk panels. Each row starts on a byte boundary; the most
+// significant bit is the leftmost pixel and is set for white.
+func Mono(img image.Image) []byte {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	stride := (w + 7) / 8
+	out := make([]byte, stride*h)
+
+	// Errors diffused into the current and next rows.
+	cur, next := make([]int, w+2), make([]int, w+2)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := int(color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y) + cur[x+1]/16
+			q := 0
+			if v >= 128 {
+				q = 255
+				out[y*stride+x/8] |= 0x80 >> (x % 8)
+			}
+			e := v - q
+			cur[x+2] += e * 7
+			next[x] += e * 3
+			next[x+1] += e * 5
+			next[x+2] += e
+		}
+		cur, next = next, cur
+		clear(next)
+	}
+	return out
+}
@@ -0,0 +1,152 @@
+package artwork_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/micro-nova/amplipi-go/internal/artwork"
+)
+
+// pngOf encodes a w x h image of one colour.
+func pngOf(t *testing.T, w, h int, c color.Color) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			img.Set(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestConvert(t *testing.T) {
+	red := pngOf(t, 4, 2, color.RGBA{255, 0, 0, 255})
+
+	// Letterboxed: the 2x1 picture on the top row, black below.
+	img, err := artwork.Convert(red, artwork.Options{Width: 2, Height: 2, Format: artwork.FormatRGB565})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0xF8, 0x00, 0xF8, 0x00, 0x00, 0x00, 0x00, 0x00}
+	if img.Width != 2 || img.Height != 2 || !bytes.Equal(img.Data, want) {
+		t.Errorf("rgb565 = %dx%d % x, want 2x2 % x", img.Width, img.Height, img.Data, want)
+	}
+
+	// Height follows the aspect ratio.
+	img, err = artwork.Convert(red, artwork.Options{Width: 8, Format: artwork.FormatGray})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Width != 8 || img.Height != 4 || len(img.Data) != 32 || img.Data[0] != 76 {
+		t.Errorf("gray = %dx%d, %d bytes, first %d; want 8x4, 32 bytes, 76", img.Width, img.Height, len(img.Data), img.Data[0])
+	}
+
+	// Rows padded to whole bytes.
+	white := pngOf(t, 10, 1, color.White)
+	img, err = artwork.Convert(white, artwork.Options{Format: artwork.FormatMono})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img.Data, []byte{0xFF, 0xC0}) {
+		t.Errorf("mono = % x, want ff c0", img.Data)
+	}
+
+	img, err = artwork.Convert(red, artwork.Options{Width: 3, Height: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err := png.DecodeConfig(bytes.NewReader(img.Data)); err != nil || cfg.Width != 3 || cfg.Height != 3 || img.ContentType != "image/png" {
+		t.Errorf("png = %+v, %v, %s; want 3x3 image/png", cfg, err, img.ContentType)
+	}
+
+	if _, err := artwork.Convert(red, artwork.Options{Format: "bmp"}); err == nil {
+		t.Error("unknown format accepted")
+	}
+	if _, err := artwork.Convert(red, artwork.Options{Width: artwork.MaxSize + 1}); err == nil {
+		t.Error("oversize width accepted")
+	}
+	if _, err := artwork.Convert([]byte("not an image"), artwork.Options{}); err == nil {
+		t.Error("garbage decoded")
+	}
+
+	// A 1x1 PNG whose header claims 20000x20000 is refused undecoded.
+	huge := pngOf(t, 1, 1, color.White)
+	binary.BigEndian.PutUint32(huge[16:], 20000)
+	binary.BigEndian.PutUint32(huge[20:], 20000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+	if _, err := artwork.Convert(huge, artwork.Options{Width: 8}); err == nil || !strings.Contains(err.Error(), "20000x20000") {
+		t.Errorf("huge image: %v", err)
+	}
+}
+
+func TestMonoDither(t *testing.T) {
+	gray := image.NewGray(image.Rect(0, 0, 16, 16))
+	for i := range gray.Pix {
+		gray.Pix[i] = 128
+	}
+	set := 0
+	for _, b := range artwork.Mono(gray) {
+		for ; b != 0; b &= b - 1 {
+			set++
+		}
+	}
+	if set < 100 || set > 156 {
+		t.Errorf("%d of 256 mid-gray pixels white, want about half", set)
+	}
+}
+
+func TestCache(t *testing.T) {
+	data := pngOf(t, 4, 4, color.White)
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cover.png" {
+			http.NotFound(w, r)
+			return
+		}
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(data)
+	}))
+	defer srv.Close()
+
+	c := artwork.NewCache()
+	ctx := context.Background()
+	img, err := c.Get(ctx, srv.URL+"/cover.png", artwork.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img.Data, data) || img.ContentType != "image/png" {
+		t.Errorf("original = %s, %d bytes; want it as served", img.ContentType, len(img.Data))
+	}
+	for range 2 {
+		if img, err = c.Get(ctx, srv.URL+"/cover.png", artwork.Options{Width: 2, Height: 2, Format: artwork.FormatGray}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(img.Data) != 4 || fetches.Load() != 1 {
+		t.Errorf("gray = %d bytes after %d fetches, want 4 after 1", len(img.Data), fetches.Load())
+	}
+
+	var fetchErr *artwork.FetchError
+	if _, err := c.Get(ctx, srv.URL+"/missing.png", artwork.Options{}); !errors.As(err, &fetchErr) {
+		t.Errorf("missing artwork: %v, want a FetchError", err)
+	}
+	if _, err := c.Get(ctx, "file:///etc/passwd", artwork.Options{}); err == nil {
+		t.Error("file URL fetched")
+	}
+}
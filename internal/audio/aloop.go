@@ -0,0 +1,49 @@
+package audio
+
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// AsoundConfPath is the system-wide ALSA config, the one the installer
+// writes.
+const AsoundConfPath = "/etc/asound.conf"
+
+// loopbackSubstreams is how many substreams each loopback card needs: its
+// two vsrcs each play into one device and are captured from the other.
+const loopbackSubstreams = 2
+
+// LoopbackProblems returns what is wrong with the layout's loopback cards
+// on the running system: cards that aren't registered and cards with too
+// few substreams. Empty when they are ready. The installer loads snd-aloop
+// with the options ModprobeOptions returns; the daemon, running
+// unprivileged, only checks.
+func (l *Layout) LoopbackProblems() []string {
+	cards, err := ReadCards()
+	if err != nil {
+		return []string{fmt.Sprintf("cannot list the ALSA cards: %v", err)}
+	}
+	var missing, problems []string
+	for _, lb := range l.Loopbacks {
+		if !slices.Contains(cards, lb) {
+			missing = append(missing, lb)
+			continue
+		}
+		if n := countSubstreams(lb); n < loopbackSubstreams {
+			problems = append(problems, fmt.Sprintf("loopback card %s has %d substreams, needs %d", lb, n, loopbackSubstreams))
+		}
+	}
+	if len(missing) > 0 {
+		problems = append([]string{fmt.Sprintf("loopback cards %s not found", strings.Join(missing, ", "))}, problems...)
+	}
+	return problems
+}
+
+// countSubstreams returns the playback substreams of a loopback card's
+// first device.
+func countSubstreams(card string) int {
+	subs, _ := filepath.Glob(filepath.Join(ProcAsoundDir, card, "pcm0p", "sub*"))
+	return len(subs)
+}
@@ -0,0 +1,226 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RoutePCM is the generated PCM that remixes a source's two channels on the
+// way to its output.
+const RoutePCM = "amplipi_route"
+
+// DuckRangeDB is how far the duck stage of an output can turn the music
+// down, in dB; DuckResolution is its number of steps.
+const (
+	DuckRangeDB    = 60
+	DuckResolution = 121 // 0.5 dB steps
+)
+
+// DuckLevel returns the duck control value that plays music at gain (0-1).
+func DuckLevel(gain float64) int {
+	if gain <= 0 {
+		return 0
+	}
+	db := min(max(20*math.Log10(gain), -DuckRangeDB), 0)
+	return int(math.Round((db + DuckRangeDB) / DuckRangeDB * (DuckResolution - 1)))
+}
+
+const (
+	loopbackIPCKeyBase = 1028 // lb{N} dmix keys: 1028, 1029, ...
+	captureIPCKeyBase  = 1540 // lb{N}s dsnoop keys
+	outputIPCKeyBase   = 2867 // per-card output dmix keys
+)
+
+// AsoundConf renders an asound.conf implementing the layout.
+func (l *Layout) AsoundConf() string {
+	var b strings.Builder
+	b.WriteString("# AmpliPi ALSA configuration — generated by amplipi, do not edit by hand\n\n")
+
+	if len(l.Outputs) > 0 {
+		fmt.Fprintf(&b, "pcm.!default {\n    type            plug\n    slave.pcm       %q\n}\n\n", l.PhysicalOutputDevice(l.Outputs[0].Index))
+	}
+
+	// One dmix per distinct card so several chN outputs can share a multichannel DAC.
+	cardKey := make(map[string]int)
+	var cards []Output
+	for _, o := range l.Outputs {
+		if _, ok := cardKey[o.Card]; ok {
+			continue
+		}
+		cardKey[o.Card] = outputIPCKeyBase + len(cards)
+		cards = append(cards, o)
+	}
+
+	b.WriteString("# ── Physical outputs ──\n")
+	for _, o := range cards {
+		fmt.Fprintf(&b, "pcm.dmix_%s {\n", o.Card)
+		b.WriteString("    type            dmix\n")
+		fmt.Fprintf(&b, "    ipc_key         %d\n", cardKey[o.Card])
+		b.WriteString("    ipc_perm        0666\n")
+		b.WriteString("    slave {\n")
+		fmt.Fprintf(&b, "        pcm         \"hw:%s,%d\"\n", o.Card, o.Device)
+		b.WriteString("        period_time 0\n        period_size 1024\n        buffer_size 8192\n")
+		fmt.Fprintf(&b, "        channels    %d\n", o.Channels)
+		b.WriteString("    }\n}\n")
+		fmt.Fprintf(&b, "ctl.dmix_%s {\n    type            hw\n    card            %s\n}\n\n", o.Card, o.Card)
+	}
+
+	for _, o := range l.Outputs {
+		name := l.PhysicalOutputDevice(o.Index)
+		slave := "dmix_" + o.Card
+		if o.MaxDB != 0 {
+			fmt.Fprintf(&b, "pcm.%s_softvol {\n", name)
+			b.WriteString("    type            softvol\n")
+			fmt.Fprintf(&b, "    slave.pcm       %q\n", slave)
+			fmt.Fprintf(&b, "    control.name    \"Ch%d Volume\"\n", o.Index)
+			fmt.Fprintf(&b, "    control.card    %s\n", o.Card)
+			fmt.Fprintf(&b, "    max_dB          %.1f\n", o.MaxDB)
+			b.WriteString("    resolution      256\n}\n")
+			slave = name + "_softvol"
+		}
+		fmt.Fprintf(&b, "pcm.%s {\n    type                plug\n", name)
+		if o.Channels == 2 && o.Left == 0 && o.Right == 1 && o.Gain == 0 {
+			fmt.Fprintf(&b, "    slave.pcm           %q\n", slave)
+		} else {
+			gain := o.Gain
+			if gain == 0 {
+				gain = 1.0
+			}
+			b.WriteString("    slave.pcm {\n        type            plug\n")
+			fmt.Fprintf(&b, "        slave.pcm       %q\n", slave)
+			fmt.Fprintf(&b, "        slave.channels  %d\n", o.Channels)
+			fmt.Fprintf(&b, "        ttable.0.%d      %g\n", o.Left, gain)
+			fmt.Fprintf(&b, "        ttable.1.%d      %g\n", o.Right, gain)
+			b.WriteString("    }\n")
+		}
+		b.WriteString("    slave.channels      2\n}\n\n")
+	}
+
+	// Music reaches the outputs through a duck stage, announcements mixed
+	// in duck mode go straight to them.
+	b.WriteString("# ── Per-output ducking ──\n")
+	for _, o := range l.Outputs {
+		card, control := l.DuckControl(o.Index)
+		fmt.Fprintf(&b, "pcm.%s {\n", l.DuckOutputDevice(o.Index))
+		b.WriteString("    type            softvol\n")
+		fmt.Fprintf(&b, "    slave.pcm       %q\n", l.PhysicalOutputDevice(o.Index))
+		fmt.Fprintf(&b, "    control.name    %q\n", control)
+		fmt.Fprintf(&b, "    control.card    %s\n", card)
+		fmt.Fprintf(&b, "    min_dB          %.1f\n", -float64(DuckRangeDB))
+		b.WriteString("    max_dB          0.0\n")
+		fmt.Fprintf(&b, "    resolution      %d\n}\n\n", DuckResolution)
+	}
+
+	// Parameterized channel mixer alsaloop plays through when a source has
+	// mono downmix, channel swap or balance set (see RoutedOutputDevice).
+	b.WriteString("# ── Per-source channel processing ──\n")
+	fmt.Fprintf(&b, "pcm.%s {\n", RoutePCM)
+	b.WriteString("    @args [ SLAVE LL LR RL RR ]\n")
+	b.WriteString("    @args.SLAVE { type string }\n")
+	for _, arg := range []string{"LL", "LR", "RL", "RR"} {
+		fmt.Fprintf(&b, "    @args.%s { type real }\n", arg)
+	}
+	b.WriteString("    type                plug\n")
+	b.WriteString("    slave.pcm           $SLAVE\n")
+	b.WriteString("    slave.channels      2\n")
+	b.WriteString("    ttable.0.0          $LL\n    ttable.0.1          $LR\n")
+	b.WriteString("    ttable.1.0          $RL\n    ttable.1.1          $RR\n}\n\n")
+
+	// Captures go through a dsnoop so alsaloop and an RTP sender can both
+	// read the same vsrc.
+	b.WriteString("# ── Loopback virtual sources ──\n")
+	for vsrc := 0; vsrc < l.VSRCCount(); vsrc++ {
+		capture, playback := l.loopbackHW(vsrc)
+		fmt.Fprintf(&b, "pcm.%s {\n    type plug\n    slave { pcm \"lb%ds\"; rate %d; format S16_LE; }\n}\n",
+			l.VirtualCaptureDevice(vsrc), vsrc, l.SampleRate)
+		fmt.Fprintf(&b, "pcm.lb%ds {\n    type dsnoop\n    ipc_key %d; ipc_perm 0666\n", vsrc, captureIPCKeyBase+vsrc)
+		fmt.Fprintf(&b, "    slave { pcm %q; rate %d; format S16_LE; channels 2; }\n}\n", capture, l.SampleRate)
+		fmt.Fprintf(&b, "pcm.lb%d {\n    type dmix\n    ipc_key %d; ipc_perm 0666\n", vsrc, loopbackIPCKeyBase+vsrc)
+		fmt.Fprintf(&b, "    slave { pcm %q; period_time 0; period_size 1024; buffer_size 4096; channels 2; }\n}\n", playback)
+		fmt.Fprintf(&b, "pcm.%s { type plug; slave.pcm \"lb%d\"; }\n\n", l.VirtualOutputDevice(vsrc), vsrc)
+	}
+
+	return b.String()
+}
+
+// ModprobeOptions returns the snd-aloop module options line that creates
+// the layout's loopback cards.
+func (l *Layout) ModprobeOptions() string {
+	n := len(l.Loopbacks)
+	enable := make([]string, n)
+	index := make([]string, n)
+	subs := make([]string, n)
+	for i := range l.Loopbacks {
+		enable[i] = "1"
+		index[i] = fmt.Sprintf("%d", i+2)
+		subs[i] = "2"
+	}
+	return fmt.Sprintf("options snd-aloop enable=%s index=%s id=%s pcm_substreams=%s",
+		strings.Join(enable, ","), strings.Join(index, ","),
+		strings.Join(l.Loopbacks, ","), strings.Join(subs, ","))
+}
+
+// WriteAsoundConf writes the generated config to path atomically.
+// Returns false without writing if the file already has identical content.
+func (l *Layout) WriteAsoundConf(path string) (bool, error) {
+	return writeIfChanged(path, []byte(l.AsoundConf()))
+}
+
+// MissingPCMs returns the PCMs the layout needs that the ALSA config conf
+// doesn't define: the physical outputs, their duck stages and both sides
+// of every vsrc.
+func (l *Layout) MissingPCMs(conf string) []string {
+	var needed []string
+	for _, o := range l.Outputs {
+		needed = append(needed, l.PhysicalOutputDevice(o.Index))
+	}
+	for _, o := range l.Outputs {
+		needed = append(needed, l.DuckOutputDevice(o.Index))
+	}
+	for vsrc := 0; vsrc < l.VSRCCount(); vsrc++ {
+		needed = append(needed, l.VirtualCaptureDevice(vsrc), l.VirtualOutputDevice(vsrc))
+	}
+	var missing []string
+	for _, name := range needed {
+		if !pcmDefined(conf, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// pcmDefined reports whether conf defines pcm.<name>.
+func pcmDefined(conf, name string) bool {
+	for rest := conf; ; {
+		i := strings.Index(rest, "pcm."+name)
+		if i < 0 {
+			return false
+		}
+		rest = rest[i+len("pcm."+name):]
+		if rest == "" || strings.ContainsAny(rest[:1], " \t\n{") {
+			return true
+		}
+	}
+}
+
+// writeIfChanged writes content to path atomically. Returns false without
+// writing if the file already has identical content.
+func writeIfChanged(path string, content []byte) (bool, error) {
+	if existing, err := os.ReadFile(path); err == nil && string(existing) == string(content) {
+		return false, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0644); err != nil {
+		return false, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,492 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDefaultLayout(t *testing.T) {
+	l := DefaultLayout()
+	if err := l.Check(); err != nil {
+		t.Fatalf("default layout invalid: %v", err)
+	}
+	if l.VSRCCount() != 12 {
+		t.Errorf("VSRCCount = %d, want 12", l.VSRCCount())
+	}
+	if got := l.VirtualCaptureDevice(3); got != "lb3p" {
+		t.Errorf("VirtualCaptureDevice(3) = %q", got)
+	}
+	if got := l.VirtualOutputDevice(3); got != "lb3c" {
+		t.Errorf("VirtualOutputDevice(3) = %q", got)
+	}
+	if got := l.PhysicalOutputDevice(2); got != "ch2" {
+		t.Errorf("PhysicalOutputDevice(2) = %q", got)
+	}
+}
+
+func TestLayoutCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		layout Layout
+	}{
+		{"no loopbacks", Layout{}},
+		{"too many loopbacks", Layout{Loopbacks: make([]string, MaxLoopbacks+1)}},
+		{"duplicate output", Layout{Loopbacks: []string{"Loopback"}, Outputs: []Output{
+			{Index: 0, Card: "a", Channels: 2, Right: 1},
+			{Index: 0, Card: "b", Channels: 2, Right: 1},
+		}}},
+		{"channel out of range", Layout{Loopbacks: []string{"Loopback"}, Outputs: []Output{
+			{Index: 0, Card: "a", Channels: 2, Left: 0, Right: 2},
+		}}},
+		{"missing card", Layout{Loopbacks: []string{"Loopback"}, Outputs: []Output{
+			{Index: 0, Channels: 2, Right: 1},
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.layout.Check(); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLoadLayout(t *testing.T) {
+	dir := t.TempDir()
+
+	l, err := LoadLayout(dir)
+	if err != nil {
+		t.Fatalf("LoadLayout (missing): %v", err)
+	}
+	if len(l.Loopbacks) != 6 {
+		t.Errorf("missing file should yield default layout, got %d loopbacks", len(l.Loopbacks))
+	}
+
+	custom := `{"loopbacks":["Loopback","Loopback1"],"outputs":[{"index":0,"card":"Device","channels":2,"left":0,"right":1}]}`
+	if err := os.WriteFile(filepath.Join(dir, LayoutFileName), []byte(custom), 0644); err != nil {
+		t.Fatal(err)
+	}
+	l, err = LoadLayout(dir)
+	if err != nil {
+		t.Fatalf("LoadLayout (custom): %v", err)
+	}
+	if l.VSRCCount() != 4 {
+		t.Errorf("VSRCCount = %d, want 4", l.VSRCCount())
+	}
+	if l.SampleRate != DefaultSampleRate {
+		t.Errorf("SampleRate = %d, want default", l.SampleRate)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, LayoutFileName), []byte(`{"loopbacks":[]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadLayout(dir); err == nil {
+		t.Error("expected error for empty loopback list")
+	}
+}
+
+func TestAsoundConf(t *testing.T) {
+	conf := DefaultLayout().AsoundConf()
+	for _, want := range []string{
+		"pcm.ch0 {",
+		"pcm.ch0_softvol {",
+		"pcm.dmix_cmedia8chint {",
+		"ttable.0.6      0.64",
+		`pcm.lb0p {`,
+		`slave { pcm "lb0s"; rate 48000; format S16_LE; }`,
+		`slave { pcm "hw:Loopback,1"; rate 48000; format S16_LE; channels 2; }`,
+		`pcm.lb11c { type plug; slave.pcm "lb11"; }`,
+		"pcm.amplipi_route {",
+		"ttable.1.0          $RL",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("asound.conf missing %q", want)
+		}
+	}
+	// The USB DAC is shared by ch1-ch3 and must only get one dmix.
+	if n := strings.Count(conf, "pcm.dmix_cmedia8chint {"); n != 1 {
+		t.Errorf("dmix_cmedia8chint defined %d times", n)
+	}
+}
+
+func TestMissingPCMs(t *testing.T) {
+	l := DefaultLayout()
+	if missing := l.MissingPCMs(l.AsoundConf()); len(missing) != 0 {
+		t.Errorf("generated config misses %v", missing)
+	}
+	conf := "pcm.ch0 {\n}\npcm.ch1\n{\n}\npcm.lb0p { type plug }\npcm.lb0c { type plug; slave.pcm \"lb0\"; }\npcm.lb1cx {}\n"
+	missing := l.MissingPCMs(conf)
+	if len(missing) != 2+4+2*11 || missing[0] != "ch2" || slices.Contains(missing, "lb0c") || !slices.Contains(missing, "lb1c") {
+		t.Errorf("MissingPCMs = %v", missing)
+	}
+}
+
+func TestRoutedOutputDevice(t *testing.T) {
+	got := DefaultLayout().RoutedOutputDevice(2, [2][2]float64{{0.5, 0.5}, {0.5, 0.5}})
+	if want := "amplipi_route:SLAVE=ch2_duck,LL=0.5,LR=0.5,RL=0.5,RR=0.5"; got != want {
+		t.Errorf("RoutedOutputDevice = %q, want %q", got, want)
+	}
+}
+
+func TestDuck(t *testing.T) {
+	l := DefaultLayout()
+	if card, name := l.DuckControl(2); card != "cmedia8chint" || name != "Ch2 Duck" {
+		t.Errorf("DuckControl(2) = %q, %q", card, name)
+	}
+	if card, _ := l.DuckControl(7); card != "" {
+		t.Errorf("DuckControl of a missing output = %q", card)
+	}
+	conf := l.AsoundConf()
+	if !strings.Contains(conf, "pcm.ch2_duck {\n    type            softvol\n    slave.pcm       \"ch2\"\n    control.name    \"Ch2 Duck\"") {
+		t.Errorf("no duck stage for ch2 in:\n%s", conf)
+	}
+	for gain, want := range map[float64]int{1: DuckResolution - 1, 2: DuckResolution - 1, 0.1: 80, 0: 0, 1e-9: 0} {
+		if got := DuckLevel(gain); got != want {
+			t.Errorf("DuckLevel(%g) = %d, want %d", gain, got, want)
+		}
+	}
+}
+
+func TestWriteAsoundConf(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "asound.conf")
+	l := DefaultLayout()
+	changed, err := l.WriteAsoundConf(path)
+	if err != nil || !changed {
+		t.Fatalf("first write: changed=%v err=%v", changed, err)
+	}
+	changed, err = l.WriteAsoundConf(path)
+	if err != nil || changed {
+		t.Errorf("second write: changed=%v err=%v, want unchanged", changed, err)
+	}
+}
+
+func TestModprobeOptions(t *testing.T) {
+	got := DefaultLayout().ModprobeOptions()
+	want := "options snd-aloop enable=1,1,1,1,1,1 index=2,3,4,5,6,7 id=Loopback,Loopback1,Loopback2,Loopback3,Loopback4,Loopback5 pcm_substreams=2,2,2,2,2,2"
+	if got != want {
+		t.Errorf("ModprobeOptions =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestParseCards(t *testing.T) {
+	data := ` 0 [sndrpihifiberry]: HifiBerry - snd_rpi_hifiberry_dac
+                      snd_rpi_hifiberry_dac
+ 2 [Loopback       ]: Loopback - Loopback
+                      Loopback 1
+`
+	cards := parseCards(bufio.NewScanner(strings.NewReader(data)))
+	if len(cards) != 2 || cards[0] != "sndrpihifiberry" || cards[1] != "Loopback" {
+		t.Errorf("parseCards = %v", cards)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	l := DefaultLayout()
+	cards := append([]string{"sndrpihifiberry"}, l.Loopbacks...)
+
+	r := l.Validate(cards)
+	if !r.OK() {
+		t.Errorf("unexpected errors: %v", r.Errors)
+	}
+	if len(r.Warnings) != 3 {
+		t.Errorf("expected 3 warnings for missing optional USB DAC, got %v", r.Warnings)
+	}
+	if len(r.Available) != 1 || r.Available[0] != 0 {
+		t.Errorf("Available = %v, want [0]", r.Available)
+	}
+
+	r = l.Validate([]string{"sndrpihifiberry"})
+	if r.OK() {
+		t.Error("expected errors for missing loopback cards")
+	}
+}
+
+func TestReadCardInfo(t *testing.T) {
+	dir := t.TempDir()
+	CardsPath = filepath.Join(dir, "cards")
+	ProcAsoundDir = dir
+	t.Cleanup(func() {
+		CardsPath = "/proc/asound/cards"
+		ProcAsoundDir = "/proc/asound"
+	})
+
+	cards := ` 0 [sndrpihifiberry]: HifiBerry - snd_rpi_hifiberry_dac
+                      snd_rpi_hifiberry_dac
+ 1 [Device         ]: USB-Audio - USB Audio Device
+                      Generic USB Audio Device at usb-0000:01:00.0-1.3, full speed
+`
+	if err := os.WriteFile(CardsPath, []byte(cards), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "card1"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "card1", "usbid"), []byte("0d8c:0014\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadCardInfo()
+	if err != nil {
+		t.Fatalf("ReadCardInfo: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("ReadCardInfo = %+v, want 2 cards", got)
+	}
+	if got[0].ID != "sndrpihifiberry" || got[0].USB {
+		t.Errorf("card 0 = %+v", got[0])
+	}
+	if got[1].ID != "Device" || !got[1].USB || got[1].Name != "USB-Audio - USB Audio Device" {
+		t.Errorf("card 1 = %+v", got[1])
+	}
+}
+
+func TestOutputsSetDeleteAndHotplug(t *testing.T) {
+	dir := t.TempDir()
+	CardsPath = filepath.Join(dir, "cards")
+	ProcAsoundDir = dir
+	t.Cleanup(func() {
+		CardsPath = "/proc/asound/cards"
+		ProcAsoundDir = "/proc/asound"
+	})
+	if err := os.WriteFile(CardsPath, []byte(" 0 [Loopback       ]: Loopback - Loopback\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var notified, changed [][]int
+	layout := &Layout{Loopbacks: []string{"Loopback"}, SampleRate: DefaultSampleRate}
+	o := NewOutputs(layout, dir, func(avail, ch []int) {
+		notified = append(notified, avail)
+		changed = append(changed, ch)
+	})
+	asound := filepath.Join(dir, "asound.conf")
+	o.SetAsoundConfPath(asound)
+
+	if err := o.Set(Output{Index: 0, Card: "Device", Channels: 2, Right: 1}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := o.Set(Output{Index: 1, Channels: 2, Right: 1}); err == nil {
+		t.Error("Set with no card should fail validation")
+	}
+	if len(o.Available()) != 0 {
+		t.Errorf("Available = %v before USB DAC is plugged in", o.Available())
+	}
+
+	// The change must be persisted and reflected in asound.conf.
+	saved, err := LoadLayout(dir)
+	if err != nil || saved.Output(0) == nil {
+		t.Fatalf("LoadLayout after Set: %v, %+v", err, saved)
+	}
+	if conf, err := os.ReadFile(asound); err != nil || !strings.Contains(string(conf), "pcm.dmix_Device {") {
+		t.Errorf("asound.conf not regenerated: %v", err)
+	}
+
+	// Hotplug the DAC.
+	cards := " 0 [Loopback       ]: Loopback - Loopback\n 1 [Device         ]: USB-Audio - USB Audio Device\n"
+	if err := os.WriteFile(CardsPath, []byte(cards), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if !o.Scan() {
+		t.Fatal("Scan did not detect new card")
+	}
+	if o.Scan() {
+		t.Error("second Scan reported a change")
+	}
+	list := o.List()
+	if len(list) != 1 || !list[0].Present {
+		t.Errorf("List = %+v, want ch0 present", list)
+	}
+	if last := notified[len(notified)-1]; len(last) != 1 || last[0] != 0 {
+		t.Errorf("last notification = %v, want [0]", last)
+	}
+	if last := changed[len(changed)-1]; !slices.Equal(last, []int{0}) {
+		t.Errorf("last changed = %v, want [0]", last)
+	}
+
+	// Setting the same output again changes nothing.
+	n := len(notified)
+	if err := o.Set(Output{Index: 0, Card: "Device", Channels: 2, Right: 1}); err != nil || len(notified) != n {
+		t.Errorf("unchanged Set: %v, %d notifications", err, len(notified)-n)
+	}
+
+	// A layout whose asound.conf can't be written is neither saved nor
+	// made current.
+	blocked := filepath.Join(dir, "blocked")
+	if err := os.MkdirAll(filepath.Join(blocked, "asound.conf.tmp"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	o.SetAsoundConfPath(filepath.Join(blocked, "asound.conf"))
+	if err := o.Set(Output{Index: 1, Card: "Device", Channels: 2, Right: 1}); err == nil {
+		t.Error("Set with an unwritable asound.conf should fail")
+	}
+	if o.Layout().Output(1) != nil {
+		t.Error("failed Set changed the layout")
+	}
+	if saved, err := LoadLayout(dir); err != nil || saved.Output(1) != nil {
+		t.Errorf("failed Set saved the layout: %v", err)
+	}
+	o.SetAsoundConfPath(asound)
+
+	if err := o.Delete(0); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := o.Delete(0); err == nil {
+		t.Error("second Delete should fail")
+	}
+}
+
+func TestWriteTestTone(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteTestTone(&buf); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" || string(data[36:40]) != "data" {
+		t.Fatalf("bad WAV header % x", data[:44])
+	}
+	frames := int(TestToneDuration() * toneRate)
+	if got := binary.LittleEndian.Uint32(data[40:44]); got != uint32(frames*4) || len(data) != 44+frames*4 {
+		t.Errorf("data length = %d (file %d), want %d", got, len(data), frames*4)
+	}
+	// The first segment is left channel only.
+	var left, right int
+	for i := 44; i < 44+toneRate*4; i += 4 {
+		if int16(binary.LittleEndian.Uint16(data[i:])) != 0 {
+			left++
+		}
+		if int16(binary.LittleEndian.Uint16(data[i+2:])) != 0 {
+			right++
+		}
+	}
+	if left == 0 || right != 0 {
+		t.Errorf("first second: %d left samples, %d right samples", left, right)
+	}
+}
+
+func TestWritePinkNoise(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WritePinkNoise(&buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()[44:]
+	if len(data) != 2*toneRate*4 {
+		t.Fatalf("data length = %d", len(data))
+	}
+	// Both channels at -20 dBFS RMS (clear of the fades).
+	var sum float64
+	n := 0
+	for i := toneRate / 10; i < toneRate*19/10; i++ {
+		l := int16(binary.LittleEndian.Uint16(data[i*4:]))
+		if r := int16(binary.LittleEndian.Uint16(data[i*4+2:])); l != r {
+			t.Fatalf("frame %d: left %d != right %d", i, l, r)
+		}
+		v := float64(l) / math.MaxInt16
+		sum += v * v
+		n++
+	}
+	if rms := math.Sqrt(sum / float64(n)); rms < 0.09 || rms > 0.11 {
+		t.Errorf("RMS = %.3f, want about %.1f", rms, pinkNoiseRMS)
+	}
+}
+
+func TestWriteSweep(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteSweep(&buf, 100, 10000, 1); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()[44:]
+	if len(data) != toneRate*4 {
+		t.Fatalf("data length = %d", len(data))
+	}
+	// Both channels carry the same signal, and the frequency rises: count
+	// zero crossings in the first and last tenth.
+	crossings := func(from, to int) int {
+		n := 0
+		prev := int16(0)
+		for i := from; i < to; i++ {
+			l := int16(binary.LittleEndian.Uint16(data[i*4:]))
+			if r := int16(binary.LittleEndian.Uint16(data[i*4+2:])); l != r {
+				t.Fatalf("frame %d: left %d != right %d", i, l, r)
+			}
+			if (prev < 0) != (l < 0) {
+				n++
+			}
+			prev = l
+		}
+		return n
+	}
+	if first, last := crossings(0, toneRate/10), crossings(toneRate*9/10, toneRate); last <= first*10 {
+		t.Errorf("zero crossings: first tenth %d, last tenth %d", first, last)
+	}
+}
+
+func TestLoopbackUsers(t *testing.T) {
+	dir := t.TempDir()
+	ProcAsoundDir, procDir = filepath.Join(dir, "asound"), dir
+	t.Cleanup(func() { ProcAsoundDir, procDir = "/proc/asound", "/proc" })
+	write := func(path, content string) {
+		t.Helper()
+		os.MkdirAll(filepath.Dir(path), 0755)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(filepath.Join(ProcAsoundDir, "Loopback", "pcm0p", "sub0", "status"), "closed\n")
+	write(filepath.Join(ProcAsoundDir, "Loopback", "pcm1c", "sub2", "status"), "state: RUNNING\nowner_pid   : 4242\ntrigger_time: 1.0\n")
+	write(filepath.Join(ProcAsoundDir, "Loopback1", "pcm0p", "sub0", "status"), fmt.Sprintf("state: PREPARED\nowner_pid   : %d\n", os.Getpid()))
+	write(filepath.Join(dir, "4242", "comm"), "alsaloop\n")
+
+	l := &Layout{Loopbacks: []string{"Loopback", "Loopback1"}}
+	users, err := l.LoopbackUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := PCMUser{Card: "Loopback", PCM: "pcm1c", Sub: "sub2", PID: 4242, Command: "alsaloop"}
+	if len(users) != 1 || users[0] != want {
+		t.Errorf("users = %+v, want [%+v]", users, want)
+	}
+}
+
+func TestLoopbackProblems(t *testing.T) {
+	dir := t.TempDir()
+	CardsPath, ProcAsoundDir = filepath.Join(dir, "cards"), dir
+	t.Cleanup(func() { CardsPath, ProcAsoundDir = "/proc/asound/cards", "/proc/asound" })
+	l := &Layout{Loopbacks: []string{"Loopback", "Loopback1"}}
+
+	// load registers cards with subs substreams each, as snd-aloop would.
+	load := func(cards []string, subs int) {
+		var list strings.Builder
+		for i, c := range cards {
+			fmt.Fprintf(&list, "%2d [%-15s]: Loopback - Loopback\n", i+2, c)
+			os.RemoveAll(filepath.Join(dir, c))
+			for s := 0; s < subs; s++ {
+				os.MkdirAll(filepath.Join(dir, c, "pcm0p", fmt.Sprintf("sub%d", s)), 0755)
+			}
+		}
+		os.WriteFile(CardsPath, []byte(list.String()), 0644)
+	}
+
+	os.WriteFile(CardsPath, []byte(" 0 [sndrpihifiberry]: HifiBerry\n"), 0644)
+	if probs := l.LoopbackProblems(); len(probs) != 1 || !strings.Contains(probs[0], "Loopback, Loopback1 not found") {
+		t.Errorf("not loaded: LoopbackProblems = %q", probs)
+	}
+	load(l.Loopbacks, 2)
+	if probs := l.LoopbackProblems(); len(probs) != 0 {
+		t.Errorf("ready: LoopbackProblems = %q", probs)
+	}
+	load([]string{"Loopback", "Loopback1"}, 1)
+	if probs := l.LoopbackProblems(); len(probs) != 2 || !strings.Contains(probs[1], "Loopback1 has 1 substreams, needs 2") {
+		t.Errorf("too few substreams: LoopbackProblems = %q", probs)
+	}
+	os.Remove(CardsPath)
+	if probs := l.LoopbackProblems(); len(probs) != 1 || !strings.Contains(probs[0], "cannot list the ALSA cards") {
+		t.Errorf("no cards file: LoopbackProblems = %q", probs)
+	}
+}
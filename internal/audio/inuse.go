@@ -0,0 +1,86 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// procDir is the kernel's process information directory.
+var procDir = "/proc"
+
+// PCMUser is a process with an open loopback substream.
+type PCMUser struct {
+	Card    string `json:"card"`    // loopback card ID, e.g. "Loopback"
+	PCM     string `json:"pcm"`     // e.g. "pcm0p"
+	Sub     string `json:"sub"`     // e.g. "sub3"
+	PID     int    `json:"pid"`     // owner
+	Command string `json:"command"` // the owner's command name, if known
+}
+
+func (u PCMUser) String() string {
+	return fmt.Sprintf("%s %s/%s by pid %d (%s)", u.Card, u.PCM, u.Sub, u.PID, u.Command)
+}
+
+// LoopbackUsers returns the processes other than this one that have the
+// layout's loopback cards open, e.g. another AmpliPi daemon or the
+// alsaloop processes of one that crashed. They would fight this daemon's
+// streams for the substreams.
+func (l *Layout) LoopbackUsers() ([]PCMUser, error) {
+	var users []PCMUser
+	self := os.Getpid()
+	for _, card := range l.Loopbacks {
+		statuses, err := filepath.Glob(filepath.Join(ProcAsoundDir, card, "pcm*", "sub*", "status"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range statuses {
+			pid, err := substreamOwner(path)
+			if err != nil {
+				return nil, err
+			}
+			if pid == 0 || pid == self {
+				continue
+			}
+			sub := filepath.Dir(path)
+			users = append(users, PCMUser{
+				Card:    card,
+				PCM:     filepath.Base(filepath.Dir(sub)),
+				Sub:     filepath.Base(sub),
+				PID:     pid,
+				Command: processName(pid),
+			})
+		}
+	}
+	return users, nil
+}
+
+// substreamOwner returns the owner_pid of an open substream's status
+// file, or 0 if it is closed.
+func substreamOwner(path string) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		key, value, ok := strings.Cut(sc.Text(), ":")
+		if ok && strings.TrimSpace(key) == "owner_pid" {
+			return strconv.Atoi(strings.TrimSpace(value))
+		}
+	}
+	return 0, sc.Err()
+}
+
+// processName returns the command name of pid, or "" if it has exited.
+func processName(pid int) string {
+	data, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
@@ -0,0 +1,187 @@
+// Package audio models the ALSA device topology used by AmpliPi: the
+// snd-aloop loopback cards that back virtual sources (vsrcs) and the
+// physical DAC outputs (ch0-chN) that feed the preamp's digital sources.
+//
+// The default layout matches the reference asound.conf shipped by
+// scripts/lib/30-alsa.sh. A non-default layout (e.g. a USB DAC on a
+// streamer-only unit) can be supplied via audio.json in the config dir.
+package audio
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LayoutFileName is the optional layout override file in the config directory.
+const LayoutFileName = "audio.json"
+
+// MaxLoopbacks is the most loopback cards a layout may list: ALSA supports
+// at most 32 sound cards. Each loopback card provides two vsrcs (one per
+// substream direction).
+const MaxLoopbacks = 32
+
+// DefaultSampleRate is the rate streams are forced to on the loopback sinks.
+const DefaultSampleRate = 48000
+
+// Output is a physical ALSA output that a preamp source (or an external
+// DAC on streamer units) is fed from. It is exposed as pcm "ch{Index}".
+type Output struct {
+	Index    int     `json:"index"`              // physical source index (ch{Index})
+	Card     string  `json:"card"`               // ALSA card ID, e.g. "sndrpihifiberry"
+	Device   int     `json:"device"`             // ALSA device on the card
+	Channels int     `json:"channels"`           // total channels on the card (2 or 8)
+	Left     int     `json:"left"`               // card channel carrying the left signal
+	Right    int     `json:"right"`              // card channel carrying the right signal
+	Gain     float64 `json:"gain,omitempty"`     // ttable coefficient for multichannel cards (default 1.0)
+	MaxDB    float64 `json:"max_db,omitempty"`   // softvol ceiling in dB, 0 = no softvol stage
+	Optional bool    `json:"optional,omitempty"` // card may be absent without failing validation
+	Name     string  `json:"name,omitempty"`     // human-readable label
+}
+
+// Layout is the full ALSA topology.
+type Layout struct {
+	// Loopbacks lists the snd-aloop card IDs in vsrc order. vsrc i uses
+	// Loopbacks[i%n]; the first n vsrcs play into device 0, the next n into device 1.
+	Loopbacks  []string `json:"loopbacks"`
+	Outputs    []Output `json:"outputs"`
+	SampleRate int      `json:"sample_rate,omitempty"`
+}
+
+// DefaultLayout returns the reference AmpliPi layout: six loopback cards
+// (12 vsrcs), the HiFiBerry DAC on ch0, and the optional CM6206 USB 8-channel
+// DAC on ch1-ch3.
+func DefaultLayout() *Layout {
+	return &Layout{
+		Loopbacks:  []string{"Loopback", "Loopback1", "Loopback2", "Loopback3", "Loopback4", "Loopback5"},
+		SampleRate: DefaultSampleRate,
+		Outputs: []Output{
+			{Index: 0, Card: "sndrpihifiberry", Channels: 2, Left: 0, Right: 1, MaxDB: -7.1, Name: "HiFiBerry DAC"},
+			{Index: 1, Card: "cmedia8chint", Channels: 8, Left: 6, Right: 7, Gain: 0.64, Optional: true, Name: "USB DAC 7/8"},
+			{Index: 2, Card: "cmedia8chint", Channels: 8, Left: 0, Right: 1, Gain: 0.64, Optional: true, Name: "USB DAC 1/2"},
+			{Index: 3, Card: "cmedia8chint", Channels: 8, Left: 4, Right: 5, Gain: 0.64, Optional: true, Name: "USB DAC 5/6"},
+		},
+	}
+}
+
+// LoadLayout reads audio.json from configDir. A missing file yields the
+// default layout; a malformed or invalid file is an error.
+func LoadLayout(configDir string) (*Layout, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, LayoutFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return DefaultLayout(), nil
+		}
+		return nil, err
+	}
+	var l Layout
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("audio: parse %s: %w", LayoutFileName, err)
+	}
+	if l.SampleRate == 0 {
+		l.SampleRate = DefaultSampleRate
+	}
+	if err := l.Check(); err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// Check verifies the layout is internally consistent. It does not look at
+// the running system; see Validate for that.
+func (l *Layout) Check() error {
+	if len(l.Loopbacks) == 0 {
+		return errors.New("audio: layout has no loopback cards")
+	}
+	if len(l.Loopbacks) > MaxLoopbacks {
+		return fmt.Errorf("audio: layout has %d loopback cards, max is %d", len(l.Loopbacks), MaxLoopbacks)
+	}
+	seen := make(map[int]bool)
+	for _, o := range l.Outputs {
+		if o.Index < 0 {
+			return fmt.Errorf("audio: output index %d is negative", o.Index)
+		}
+		if seen[o.Index] {
+			return fmt.Errorf("audio: duplicate output index %d", o.Index)
+		}
+		seen[o.Index] = true
+		if o.Card == "" {
+			return fmt.Errorf("audio: output ch%d has no card", o.Index)
+		}
+		if o.Channels < 2 {
+			return fmt.Errorf("audio: output ch%d must have at least 2 channels", o.Index)
+		}
+		if o.Left < 0 || o.Left >= o.Channels || o.Right < 0 || o.Right >= o.Channels {
+			return fmt.Errorf("audio: output ch%d channel map out of range", o.Index)
+		}
+	}
+	return nil
+}
+
+// VSRCCount returns the number of virtual source slots the layout provides.
+func (l *Layout) VSRCCount() int {
+	return 2 * len(l.Loopbacks)
+}
+
+// Output returns the output with the given index, or nil.
+func (l *Layout) Output(index int) *Output {
+	for i := range l.Outputs {
+		if l.Outputs[i].Index == index {
+			return &l.Outputs[i]
+		}
+	}
+	return nil
+}
+
+// VirtualCaptureDevice returns the ALSA PCM name alsaloop reads from for a vsrc.
+func (l *Layout) VirtualCaptureDevice(vsrc int) string {
+	return fmt.Sprintf("lb%dp", vsrc)
+}
+
+// VirtualOutputDevice returns the ALSA PCM name a stream process writes to for a vsrc.
+func (l *Layout) VirtualOutputDevice(vsrc int) string {
+	return fmt.Sprintf("lb%dc", vsrc)
+}
+
+// PhysicalOutputDevice returns the ALSA PCM name for a physical output.
+func (l *Layout) PhysicalOutputDevice(index int) string {
+	return fmt.Sprintf("ch%d", index)
+}
+
+// DuckOutputDevice returns the ALSA PCM alsaloop plays physical output
+// index through: a softvol stage whose control (see DuckControl) turns the
+// music down while an announcement is mixed straight into the output.
+func (l *Layout) DuckOutputDevice(index int) string {
+	return fmt.Sprintf("ch%d_duck", index)
+}
+
+// DuckControl returns the card and name of the mixer control of
+// DuckOutputDevice(index), or "" for an output the layout doesn't have.
+func (l *Layout) DuckControl(index int) (card, name string) {
+	o := l.Output(index)
+	if o == nil {
+		return "", ""
+	}
+	return o.Card, fmt.Sprintf("Ch%d Duck", index)
+}
+
+// RoutedOutputDevice returns a PCM that plays into physical output index
+// through the RoutePCM channel mixer and the output's duck stage.
+// m[in][out] is the gain of each input channel in each output channel.
+func (l *Layout) RoutedOutputDevice(index int, m [2][2]float64) string {
+	return fmt.Sprintf("%s:SLAVE=%s,LL=%g,LR=%g,RL=%g,RR=%g",
+		RoutePCM, l.DuckOutputDevice(index), m[0][0], m[0][1], m[1][0], m[1][1])
+}
+
+// loopbackHW returns the hw: addresses for a vsrc. Streams play into the
+// playback side (through a dmix); alsaloop reads the capture side.
+func (l *Layout) loopbackHW(vsrc int) (capture, playback string) {
+	n := len(l.Loopbacks)
+	card := l.Loopbacks[vsrc%n]
+	if vsrc < n {
+		return fmt.Sprintf("hw:%s,1", card), fmt.Sprintf("hw:%s,0", card)
+	}
+	return fmt.Sprintf("hw:%s,0", card), fmt.Sprintf("hw:%s,1", card)
+}
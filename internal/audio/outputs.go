@@ -0,0 +1,324 @@
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProcAsoundDir is the kernel's ALSA procfs directory.
+var ProcAsoundDir = "/proc/asound"
+
+// Card is an ALSA card registered with the kernel.
+type Card struct {
+	Index int    `json:"index"`
+	ID    string `json:"id"`   // e.g. "sndrpihifiberry", "Device"
+	Name  string `json:"name"` // long description from /proc/asound/cards
+	USB   bool   `json:"usb"`  // true if the card is a USB audio device
+}
+
+// ReadCardInfo returns all registered ALSA cards with USB detection.
+func ReadCardInfo() ([]Card, error) {
+	data, err := os.ReadFile(CardsPath)
+	if err != nil {
+		return nil, err
+	}
+	var cards []Card
+	for _, line := range strings.Split(string(data), "\n") {
+		open := strings.Index(line, "[")
+		end := strings.Index(line, "]")
+		if open < 0 || end < open {
+			continue
+		}
+		idx, err := strconv.Atoi(strings.TrimSpace(line[:open]))
+		if err != nil {
+			continue
+		}
+		c := Card{Index: idx, ID: strings.TrimSpace(line[open+1 : end])}
+		if rest := strings.TrimPrefix(line[end+1:], ":"); rest != "" {
+			c.Name = strings.TrimSpace(rest)
+		}
+		// USB audio cards expose a usbid file in their procfs directory.
+		if _, err := os.Stat(filepath.Join(ProcAsoundDir, fmt.Sprintf("card%d", idx), "usbid")); err == nil {
+			c.USB = true
+		}
+		cards = append(cards, c)
+	}
+	return cards, nil
+}
+
+// OutputStatus is an Output plus its runtime presence.
+type OutputStatus struct {
+	Output
+	Present bool   `json:"present"`
+	PCM     string `json:"pcm"` // ALSA PCM name alsaloop writes to
+}
+
+// Outputs owns the mutable set of physical outputs. It persists changes to
+// audio.json and tracks which output cards are currently plugged in.
+// All methods are safe for concurrent use.
+type Outputs struct {
+	mu        sync.Mutex
+	layout    *Layout
+	configDir string
+	asound    string // asound.conf path regenerated on change, "" = don't write
+	cards     []Card
+	onChange  func(available, changed []int)
+}
+
+// NewOutputs creates an output manager for the given layout.
+// onChange is called with the available output indices and the outputs
+// that changed whenever the set of present cards or the output
+// configuration changes. It may be nil.
+func NewOutputs(layout *Layout, configDir string, onChange func(available, changed []int)) *Outputs {
+	o := &Outputs{
+		layout:    layout,
+		configDir: configDir,
+		onChange:  onChange,
+	}
+	if cards, err := ReadCardInfo(); err == nil {
+		o.cards = cards
+	}
+	return o
+}
+
+// SetAsoundConfPath makes every configuration change also regenerate the
+// ALSA config at path.
+func (o *Outputs) SetAsoundConfPath(path string) {
+	o.mu.Lock()
+	o.asound = path
+	o.mu.Unlock()
+}
+
+// Layout returns the current layout. The returned value must not be modified.
+func (o *Outputs) Layout() *Layout {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.layout
+}
+
+// Cards returns the ALSA cards seen at the last scan.
+func (o *Outputs) Cards() []Card {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return slices.Clone(o.cards)
+}
+
+// List returns all configured outputs with their presence state.
+func (o *Outputs) List() []OutputStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.listLocked()
+}
+
+func (o *Outputs) listLocked() []OutputStatus {
+	result := make([]OutputStatus, 0, len(o.layout.Outputs))
+	for _, out := range o.layout.Outputs {
+		result = append(result, OutputStatus{
+			Output:  out,
+			Present: o.cardPresentLocked(out.Card),
+			PCM:     o.layout.PhysicalOutputDevice(out.Index),
+		})
+	}
+	return result
+}
+
+// Available returns the indices of outputs whose card is present, never
+// nil.
+func (o *Outputs) Available() []int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.availableLocked()
+}
+
+func (o *Outputs) availableLocked() []int {
+	avail := []int{}
+	for _, out := range o.layout.Outputs {
+		if o.cardPresentLocked(out.Card) {
+			avail = append(avail, out.Index)
+		}
+	}
+	slices.Sort(avail)
+	return avail
+}
+
+func (o *Outputs) cardPresentLocked(id string) bool {
+	for _, c := range o.cards {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Set creates or replaces the output with out.Index and persists the layout.
+func (o *Outputs) Set(out Output) error {
+	o.mu.Lock()
+	next := o.layout.clone()
+	if existing := next.Output(out.Index); existing != nil {
+		if *existing == out {
+			o.mu.Unlock()
+			return nil
+		}
+		*existing = out
+	} else {
+		next.Outputs = append(next.Outputs, out)
+		slices.SortFunc(next.Outputs, func(a, b Output) int { return a.Index - b.Index })
+	}
+	if err := o.commitLocked(next); err != nil {
+		o.mu.Unlock()
+		return err
+	}
+	avail := o.availableLocked()
+	o.mu.Unlock()
+	o.notify(avail, []int{out.Index})
+	return nil
+}
+
+// Delete removes the output with the given index and persists the layout.
+func (o *Outputs) Delete(index int) error {
+	o.mu.Lock()
+	next := o.layout.clone()
+	i := slices.IndexFunc(next.Outputs, func(out Output) bool { return out.Index == index })
+	if i < 0 {
+		o.mu.Unlock()
+		return fmt.Errorf("output ch%d not found", index)
+	}
+	next.Outputs = slices.Delete(next.Outputs, i, i+1)
+	if err := o.commitLocked(next); err != nil {
+		o.mu.Unlock()
+		return err
+	}
+	avail := o.availableLocked()
+	o.mu.Unlock()
+	o.notify(avail, []int{index})
+	return nil
+}
+
+// commitLocked validates next, writes its asound.conf and persists it, then
+// makes it current. The ALSA config is written first so a layout that
+// can't be applied is never saved; if saving fails, the old config is put
+// back.
+func (o *Outputs) commitLocked(next *Layout) error {
+	if err := next.Check(); err != nil {
+		return err
+	}
+	if o.asound != "" {
+		if _, err := next.WriteAsoundConf(o.asound); err != nil {
+			return err
+		}
+	}
+	if o.configDir != "" {
+		if err := next.Save(o.configDir); err != nil {
+			if o.asound != "" {
+				if _, rerr := o.layout.WriteAsoundConf(o.asound); rerr != nil {
+					err = errors.Join(err, rerr)
+				}
+			}
+			return err
+		}
+	}
+	o.layout = next
+	return nil
+}
+
+// Scan re-reads the ALSA card list and fires onChange if it changed, with
+// the outputs whose card was plugged in or removed. Returns true if the
+// card set changed.
+func (o *Outputs) Scan() bool {
+	cards, err := ReadCardInfo()
+	if err != nil {
+		return false
+	}
+	o.mu.Lock()
+	changed := !slices.Equal(cardIDs(cards), cardIDs(o.cards))
+	prev := o.availableLocked()
+	o.cards = cards
+	avail := o.availableLocked()
+	o.mu.Unlock()
+
+	if changed {
+		slog.Info("audio: ALSA cards changed", "cards", cardIDs(cards), "available_outputs", avail)
+		var flipped []int
+		for _, i := range prev {
+			if !slices.Contains(avail, i) {
+				flipped = append(flipped, i)
+			}
+		}
+		for _, i := range avail {
+			if !slices.Contains(prev, i) {
+				flipped = append(flipped, i)
+			}
+		}
+		slices.Sort(flipped)
+		o.notify(avail, flipped)
+	}
+	return changed
+}
+
+// Watch polls for ALSA card hotplug (USB DACs) until ctx is cancelled.
+// procfs does not support inotify, so polling is the only portable option.
+func (o *Outputs) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			o.Scan()
+		}
+	}
+}
+
+func (o *Outputs) notify(avail, changed []int) {
+	if o.onChange != nil {
+		o.onChange(avail, changed)
+	}
+}
+
+func cardIDs(cards []Card) []string {
+	ids := make([]string, len(cards))
+	for i, c := range cards {
+		ids[i] = c.ID
+	}
+	return ids
+}
+
+// clone returns a deep copy of the layout.
+func (l *Layout) clone() *Layout {
+	return &Layout{
+		Loopbacks:  slices.Clone(l.Loopbacks),
+		Outputs:    slices.Clone(l.Outputs),
+		SampleRate: l.SampleRate,
+	}
+}
+
+// Save writes the layout to audio.json in configDir atomically.
+func (l *Layout) Save(configDir string) error {
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(configDir, LayoutFileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Join(err, os.Remove(tmp))
+	}
+	return nil
+}
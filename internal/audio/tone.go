@@ -0,0 +1,166 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"math/rand/v2"
+)
+
+// Test tone format: 16-bit stereo PCM.
+const (
+	toneRate      = 44100
+	toneAmplitude = 0.3 // fraction of full scale
+	toneFade      = 0.02
+)
+
+// toneSegment is a sine burst on the left and/or right channel; freq 0 is
+// silence.
+type toneSegment struct {
+	freq        float64
+	seconds     float64
+	left, right bool
+}
+
+// testToneSegments plays the left channel, then the right, then both, so
+// an installer can hear both the zone and its speaker polarity/channel
+// wiring.
+var testToneSegments = []toneSegment{
+	{440, 1, true, false},
+	{0, 0.25, false, false},
+	{880, 1, false, true},
+	{0, 0.25, false, false},
+	{660, 1, true, true},
+}
+
+// TestToneDuration is the length of the tone written by WriteTestTone.
+func TestToneDuration() float64 {
+	var d float64
+	for _, s := range testToneSegments {
+		d += s.seconds
+	}
+	return d
+}
+
+// WriteTestTone writes a WAV file with a short left/right/both test tone
+// for identifying zones.
+func WriteTestTone(w io.Writer) error {
+	type span struct {
+		toneSegment
+		start, frames int
+	}
+	var spans []span
+	var frames int
+	for _, s := range testToneSegments {
+		n := int(s.seconds * toneRate)
+		spans = append(spans, span{s, frames, n})
+		frames += n
+	}
+	fade := int(toneFade * toneRate)
+	seg := 0
+	return writeWAV(w, frames, func(i int) (float64, float64) {
+		for i >= spans[seg].start+spans[seg].frames {
+			seg++
+		}
+		s := spans[seg]
+		if s.freq == 0 {
+			return 0, 0
+		}
+		j := i - s.start
+		// Short fades avoid clicks at the segment edges.
+		gain := math.Min(1, math.Min(float64(j)/float64(fade), float64(s.frames-j)/float64(fade)))
+		v := toneAmplitude * gain * math.Sin(2*math.Pi*s.freq*float64(j)/toneRate)
+		var l, r float64
+		if s.left {
+			l = v
+		}
+		if s.right {
+			r = v
+		}
+		return l, r
+	})
+}
+
+// WriteSweep writes a WAV file with a logarithmic sine sweep from fromHz
+// to toHz on both channels, for checking a speaker's frequency response by
+// ear (rattles, missing tweeters).
+func WriteSweep(w io.Writer, fromHz, toHz, seconds float64) error {
+	frames := int(seconds * toneRate)
+	fade := int(toneFade * toneRate)
+	k := math.Log(toHz / fromHz)
+	return writeWAV(w, frames, func(i int) (float64, float64) {
+		t := float64(i) / toneRate
+		// Phase of an exponential sweep: 2π f0 T/k (e^(k t/T) - 1).
+		phase := 2 * math.Pi * fromHz * seconds / k * (math.Exp(k*t/seconds) - 1)
+		gain := math.Min(1, math.Min(float64(i)/float64(fade), float64(frames-i)/float64(fade)))
+		v := toneAmplitude * gain * math.Sin(phase)
+		return v, v
+	})
+}
+
+// writeWAV writes a 16-bit stereo WAV file of frames frames, taking each
+// frame's left and right samples (-1..1) from sample.
+func writeWAV(w io.Writer, frames int, sample func(i int) (left, right float64)) error {
+	dataLen := uint32(frames * 4)
+	hdr := struct {
+		RIFF          [4]byte
+		Size          uint32
+		WAVE          [4]byte
+		Fmt           [4]byte
+		FmtLen        uint32
+		Format        uint16
+		Channels      uint16
+		Rate          uint32
+		ByteRate      uint32
+		BlockAlign    uint16
+		BitsPerSample uint16
+		Data          [4]byte
+		DataLen       uint32
+	}{
+		RIFF: [4]byte{'R', 'I', 'F', 'F'}, Size: 36 + dataLen, WAVE: [4]byte{'W', 'A', 'V', 'E'},
+		Fmt: [4]byte{'f', 'm', 't', ' '}, FmtLen: 16, Format: 1, Channels: 2,
+		Rate: toneRate, ByteRate: toneRate * 4, BlockAlign: 4, BitsPerSample: 16,
+		Data: [4]byte{'d', 'a', 't', 'a'}, DataLen: dataLen,
+	}
+	if err := binary.Write(w, binary.LittleEndian, hdr); err != nil {
+		return err
+	}
+	buf := make([]byte, 0, dataLen)
+	for i := 0; i < frames; i++ {
+		l, r := sample(i)
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(l*math.MaxInt16)))
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(int16(r*math.MaxInt16)))
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// pinkNoiseRMS is the level of the calibration noise, -20 dBFS, leaving
+// headroom for its peaks.
+const pinkNoiseRMS = 0.1
+
+// WritePinkNoise writes a WAV file with seconds of pink noise on both
+// channels at -20 dBFS RMS, the usual signal for measuring a room's SPL.
+// The noise is the same every time.
+func WritePinkNoise(w io.Writer, seconds float64) error {
+	frames := int(seconds * toneRate)
+	rng := rand.New(rand.NewPCG(1, 2))
+	samples := make([]float64, frames)
+	var b0, b1, b2, sum float64
+	for i := range samples {
+		// Paul Kellet's economy filter turns white noise pink (-3 dB/octave).
+		white := 2*rng.Float64() - 1
+		b0 = 0.99765*b0 + white*0.0990460
+		b1 = 0.96300*b1 + white*0.2965164
+		b2 = 0.57000*b2 + white*1.0526913
+		samples[i] = b0 + b1 + b2 + white*0.1848
+		sum += samples[i] * samples[i]
+	}
+	scale := pinkNoiseRMS / math.Sqrt(sum/float64(frames))
+	fade := int(toneFade * toneRate)
+	return writeWAV(w, frames, func(i int) (float64, float64) {
+		gain := math.Min(1, math.Min(float64(i)/float64(fade), float64(frames-i)/float64(fade)))
+		v := math.Max(-1, math.Min(1, scale*gain*samples[i]))
+		return v, v
+	})
+}
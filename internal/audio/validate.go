@@ -0,0 +1,80 @@
+package audio
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// CardsPath is the kernel's list of registered ALSA cards.
+var CardsPath = "/proc/asound/cards"
+
+// Report is the result of validating a layout against the running system.
+type Report struct {
+	Cards     []string `json:"cards"`     // card IDs present on the system
+	Available []int    `json:"available"` // output indices whose card is present
+	Errors    []string `json:"errors,omitempty"`
+	Warnings  []string `json:"warnings,omitempty"`
+}
+
+// OK returns true if no hard errors were found.
+func (r *Report) OK() bool { return len(r.Errors) == 0 }
+
+// ReadCards returns the IDs of the ALSA cards currently registered.
+func ReadCards() ([]string, error) {
+	f, err := os.Open(CardsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseCards(bufio.NewScanner(f)), nil
+}
+
+// parseCards extracts card IDs from /proc/asound/cards lines of the form
+// " 0 [sndrpihifiberry]: HifiBerry - snd_rpi_hifiberry_dac".
+func parseCards(sc *bufio.Scanner) []string {
+	var cards []string
+	for sc.Scan() {
+		line := sc.Text()
+		open := strings.Index(line, "[")
+		end := strings.Index(line, "]")
+		if open < 0 || end < open {
+			continue
+		}
+		cards = append(cards, strings.TrimSpace(line[open+1:end]))
+	}
+	return cards
+}
+
+// Validate checks that every card the layout references is present.
+// Missing loopback cards and missing non-optional outputs are errors;
+// missing optional outputs are warnings.
+func (l *Layout) Validate(cards []string) Report {
+	present := make(map[string]bool, len(cards))
+	for _, c := range cards {
+		present[c] = true
+	}
+	r := Report{Cards: cards}
+
+	for _, lb := range l.Loopbacks {
+		if !present[lb] {
+			r.Errors = append(r.Errors, fmt.Sprintf("loopback card %q not found (is snd-aloop loaded?)", lb))
+		}
+	}
+	for _, o := range l.Outputs {
+		if present[o.Card] {
+			r.Available = append(r.Available, o.Index)
+			continue
+		}
+		msg := fmt.Sprintf("output ch%d: card %q not found", o.Index, o.Card)
+		if o.Optional {
+			r.Warnings = append(r.Warnings, msg)
+		} else {
+			r.Errors = append(r.Errors, msg)
+		}
+	}
+	sort.Ints(r.Available)
+	return r
+}
@@ -1,12 +1,15 @@
 package auth_test
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/micro-nova/amplipi-go/internal/auth"
 )
@@ -324,3 +327,225 @@ func TestService_MissingConfigDir_NoError(t *testing.T) {
 		t.Error("expected open mode for non-existent config dir")
 	}
 }
+
+func TestMiddleware_SecuredMode_TrustedConn_Passes(t *testing.T) {
+	svc := newSecuredService(t, "correct-key")
+
+	called := false
+	handler := svc.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(200)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/api", nil)
+	req = req.WithContext(auth.TrustConn(req.Context(), nil))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if !called || rr.Code != http.StatusOK {
+		t.Errorf("trusted request: called = %v, status = %d", called, rr.Code)
+	}
+}
+
+// --- Pairing ---
+
+func TestPairing(t *testing.T) {
+	svc := newSecuredService(t, "admin-key")
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.SetClock(func() time.Time { return now })
+
+	if _, err := svc.Pair("Phone"); !errors.Is(err, auth.ErrPairingClosed) {
+		t.Fatalf("Pair with no window: err = %v", err)
+	}
+
+	// The window closes when it times out...
+	svc.OpenPairing(2 * time.Minute)
+	now = now.Add(3 * time.Minute)
+	if st := svc.Pairing(); st.Open {
+		t.Errorf("pairing still open after its window: %+v", st)
+	}
+	if _, err := svc.Pair("Phone"); !errors.Is(err, auth.ErrPairingClosed) {
+		t.Fatalf("Pair after the window: err = %v", err)
+	}
+
+	// ...or after one app paired.
+	svc.OpenPairing(2 * time.Minute)
+	dev, err := svc.Pair("Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dev.Key == "" || dev.Name != "Phone" {
+		t.Fatalf("device = %+v", dev)
+	}
+	if svc.Pairing().Open {
+		t.Error("pairing still open after an app paired")
+	}
+	if _, err := svc.Pair("Tablet"); !errors.Is(err, auth.ErrPairingClosed) {
+		t.Errorf("second Pair: err = %v", err)
+	}
+
+	// The key works, is not listed and survives a reload; the admin
+	// account is kept.
+	if !svc.VerifyKey(dev.Key) {
+		t.Error("device key rejected")
+	}
+	if err := svc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if !svc.VerifyKey(dev.Key) || !svc.VerifyKey("admin-key") || svc.IsOpenMode() {
+		t.Error("keys or password lost when saving users.json")
+	}
+	devices := svc.Devices()
+	if len(devices) != 1 || devices[0].ID != dev.ID || devices[0].Key != "" {
+		t.Errorf("devices = %+v", devices)
+	}
+
+	// Unpairing revokes the key.
+	if err := svc.Unpair(dev.ID); err != nil {
+		t.Fatal(err)
+	}
+	if svc.VerifyKey(dev.Key) {
+		t.Error("key still valid after unpairing")
+	}
+	if err := svc.Unpair("admin"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Unpair(admin): err = %v, want not found", err)
+	}
+}
+
+func TestIsAdmin(t *testing.T) {
+	svc := newSecuredService(t, "admin-key")
+	svc.OpenPairing(time.Minute)
+	dev, err := svc.Pair("Phone")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		req  *http.Request
+		want bool
+	}{
+		{"user key", httptest.NewRequest(http.MethodPost, "/api/reboot?api-key=admin-key", nil), true},
+		{"paired app key", httptest.NewRequest(http.MethodPost, "/api/reboot?api-key="+dev.Key, nil), false},
+		{"no key", httptest.NewRequest(http.MethodPost, "/api/reboot", nil), false},
+	} {
+		if got := svc.IsAdmin(tc.req); got != tc.want {
+			t.Errorf("%s: IsAdmin = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	cookie := httptest.NewRequest(http.MethodPost, "/api/reboot", nil)
+	cookie.AddCookie(&http.Cookie{Name: "amplipi-session", Value: "admin-key"})
+	if !svc.IsAdmin(cookie) {
+		t.Error("session cookie: not admin")
+	}
+	trustedReq := httptest.NewRequest(http.MethodPost, "/api/reboot", nil)
+	trustedReq = trustedReq.WithContext(auth.TrustConn(trustedReq.Context(), nil))
+	if !svc.IsAdmin(trustedReq) {
+		t.Error("trusted connection: not admin")
+	}
+}
+
+func TestScope(t *testing.T) {
+	svc := newSecuredService(t, "admin-key")
+	svc.OpenPairing(time.Minute)
+	dev, err := svc.Pair("Kids Tablet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest(http.MethodPatch, "/api/zones/4?api-key="+dev.Key, nil)
+	if sc := svc.ScopeFor(req); sc != nil {
+		t.Errorf("unrestricted app scope = %+v, want nil", sc)
+	}
+
+	if err := svc.SetScope(dev.ID, &auth.Scope{Zones: []int{4, 5}}); err != nil {
+		t.Fatal(err)
+	}
+	if sc := svc.ScopeFor(req); sc == nil || len(sc.Zones) != 2 || sc.Zones[0] != 4 {
+		t.Errorf("restricted app scope = %+v, want zones 4 and 5", sc)
+	}
+
+	// A restricted user is no longer an administrator.
+	if err := svc.SetScope("admin", &auth.Scope{}); err != nil {
+		t.Fatal(err)
+	}
+	adminReq := httptest.NewRequest(http.MethodPost, "/api/reboot?api-key=admin-key", nil)
+	if svc.IsAdmin(adminReq) || svc.ScopeFor(adminReq) == nil {
+		t.Error("restricted user: still an unrestricted admin")
+	}
+	if err := svc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if svc.ScopeFor(req) == nil {
+		t.Error("scope lost on reload")
+	}
+	if err := svc.SetScope("admin", nil); err != nil {
+		t.Fatal(err)
+	}
+	if !svc.IsAdmin(adminReq) {
+		t.Error("lifted restriction: not admin")
+	}
+
+	if err := svc.SetScope("nobody", nil); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("SetScope of an unknown user = %v, want ErrNotExist", err)
+	}
+	if profiles := svc.Profiles(); len(profiles) != 2 || profiles[0].ID != "admin" {
+		t.Errorf("profiles = %+v, want admin and the tablet", profiles)
+	}
+}
+
+func TestContextForKey(t *testing.T) {
+	ctx := context.Background()
+	if got, ok := auth.ContextForKey(ctx, nil, nil); !ok || !auth.AdminFrom(got) {
+		t.Error("no auth: client is not an administrator")
+	}
+
+	svc := newSecuredService(t, "admin-key")
+	svc.OpenPairing(time.Minute)
+	dev, err := svc.Pair("Kids Tablet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SetScope(dev.ID, &auth.Scope{Zones: []int{4}}); err != nil {
+		t.Fatal(err)
+	}
+	adminKey, wrong := "admin-key", "wrong"
+	if _, ok := auth.ContextForKey(ctx, svc, nil); ok {
+		t.Error("client that has not signed in: allowed")
+	}
+	if _, ok := auth.ContextForKey(ctx, svc, &wrong); ok {
+		t.Error("unknown key: allowed")
+	}
+	if got, ok := auth.ContextForKey(ctx, svc, &adminKey); !ok || !auth.AdminFrom(got) || auth.ScopeFrom(got) != nil {
+		t.Error("admin key: not an unrestricted administrator")
+	}
+	got, ok := auth.ContextForKey(ctx, svc, &dev.Key)
+	if sc := auth.ScopeFrom(got); !ok || auth.AdminFrom(got) || sc == nil || len(sc.Zones) != 1 {
+		t.Errorf("scoped app key: ok %v, admin %v, scope %+v; want scoped to zone 4", ok, auth.AdminFrom(got), sc)
+	}
+
+	// Keys are checked again on every call.
+	if err := svc.Unpair(dev.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := auth.ContextForKey(ctx, svc, &dev.Key); ok {
+		t.Error("removed key: still allowed")
+	}
+}
+
+func TestService_RemoveUsers(t *testing.T) {
+	svc := newSecuredService(t, "secret-key-123")
+
+	if err := svc.RemoveUsers(); err != nil {
+		t.Fatalf("RemoveUsers: %v", err)
+	}
+	if !svc.IsOpenMode() {
+		t.Error("IsOpenMode() = false after RemoveUsers, want true")
+	}
+	if svc.VerifyKey("secret-key-123") {
+		t.Error("key still valid after RemoveUsers")
+	}
+	if err := svc.RemoveUsers(); err != nil {
+		t.Errorf("RemoveUsers without users.json: %v", err)
+	}
+}
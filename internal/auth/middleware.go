@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"context"
+	"net"
 	"net/http"
 	"net/url"
 )
@@ -10,12 +12,76 @@ const (
 	apiKeyQueryParam  = "api-key"
 )
 
+type trustedKey struct{}
+
+// TrustConn marks requests on a connection as trusted, bypassing
+// authentication. Use it as the http.Server ConnContext of listeners whose
+// access is controlled otherwise, e.g. a Unix socket's file permissions.
+func TrustConn(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, trustedKey{}, true)
+}
+
+func trusted(ctx context.Context) bool {
+	v, _ := ctx.Value(trustedKey{}).(bool)
+	return v
+}
+
+type adminKey struct{}
+
+// WithAdmin marks ctx as carrying a request from an administrator (see
+// IsAdmin), so code outside the HTTP layer can let administrators past
+// restrictions such as zone locks.
+func WithAdmin(ctx context.Context) context.Context {
+	return context.WithValue(ctx, adminKey{}, true)
+}
+
+// AdminFrom reports whether ctx carries an administrator's request.
+func AdminFrom(ctx context.Context) bool {
+	v, _ := ctx.Value(adminKey{}).(bool)
+	return v
+}
+
+// IsAdmin reports whether the request may administer the unit, e.g. reboot
+// it: in open mode, on trusted connections, or signed in with a user's key
+// rather than a paired app's, and not restricted to a Scope.
+func (s *Service) IsAdmin(r *http.Request) bool {
+	if s.IsOpenMode() || trusted(r.Context()) {
+		return true
+	}
+	for _, u := range s.requestUsers(r) {
+		if u.Type != DeviceType && u.Scope == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// requestUsers returns the users whose keys the request carries, in its
+// session cookie or api-key query parameter.
+func (s *Service) requestUsers(r *http.Request) []User {
+	var keys []string
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		keys = append(keys, cookie.Value)
+	}
+	if key := r.URL.Query().Get(apiKeyQueryParam); key != "" {
+		keys = append(keys, key)
+	}
+	var users []User
+	for _, key := range keys {
+		if u, ok := s.userFor(key); ok {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
 // Middleware returns an http.Handler middleware that enforces authentication.
 // In open mode (no passwords configured), all requests pass through.
-// Otherwise, checks the session cookie and api-key query param.
+// Otherwise, checks the session cookie and api-key query param. Requests on
+// trusted connections (see TrustConn) always pass.
 func (s *Service) Middleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if s.IsOpenMode() {
+		if s.IsOpenMode() || trusted(r.Context()) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -0,0 +1,171 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DeviceType is the users.json type of keys issued to paired apps.
+const DeviceType = "device"
+
+const devicePrefix = "device-"
+
+// ErrPairingClosed is returned by Pair outside a pairing window.
+var ErrPairingClosed = errors.New("pairing is not open; press the pair button on the unit or open pairing from a signed-in client")
+
+// Device is an app paired with the unit. Its key is only revealed when
+// it pairs.
+type Device struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Paired string `json:"paired"`
+	Key    string `json:"key,omitempty"`
+}
+
+// PairingStatus says whether apps can pair now.
+type PairingStatus struct {
+	Open  bool       `json:"open"`
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// SetClock replaces the clock pairing windows are timed with, for tests.
+func (s *Service) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	s.now = now
+	s.mu.Unlock()
+}
+
+// OpenPairing lets apps pair for d, e.g. after the front-panel pair
+// button was pressed. The window closes after one app has paired.
+func (s *Service) OpenPairing(d time.Duration) PairingStatus {
+	s.mu.Lock()
+	s.pairUntil = s.now().Add(d)
+	s.mu.Unlock()
+	return s.Pairing()
+}
+
+// ClosePairing ends the pairing window.
+func (s *Service) ClosePairing() {
+	s.mu.Lock()
+	s.pairUntil = time.Time{}
+	s.mu.Unlock()
+}
+
+// Pairing returns whether a pairing window is open and until when.
+func (s *Service) Pairing() PairingStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.now().Before(s.pairUntil) {
+		return PairingStatus{}
+	}
+	until := s.pairUntil
+	return PairingStatus{Open: true, Until: &until}
+}
+
+// Pair issues a key for the named app if a pairing window is open, and
+// closes the window.
+func (s *Service) Pair(name string) (Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.now().Before(s.pairUntil) {
+		return Device{}, ErrPairingClosed
+	}
+	id := devicePrefix + strings.ToLower(rand.Text()[:8])
+	dev := Device{ID: id, Name: name, Paired: s.now().UTC().Format(time.RFC3339), Key: rand.Text()}
+	users := make(map[string]User, len(s.users)+1)
+	for k, u := range s.users {
+		users[k] = u
+	}
+	users[id] = User{Type: DeviceType, Name: name, AccessKey: dev.Key, AccessKeyUpdated: dev.Paired}
+	if err := s.writeUsers(users); err != nil {
+		return Device{}, err
+	}
+	s.users = users
+	s.pairUntil = time.Time{}
+	return dev, nil
+}
+
+// Devices lists the paired apps, oldest first.
+func (s *Service) Devices() []Device {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	devices := []Device{}
+	for id, u := range s.users {
+		if u.Type == DeviceType {
+			devices = append(devices, Device{ID: id, Name: u.Name, Paired: u.AccessKeyUpdated})
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Paired != devices[j].Paired {
+			return devices[i].Paired < devices[j].Paired
+		}
+		return devices[i].ID < devices[j].ID
+	})
+	return devices
+}
+
+// Unpair revokes a paired app's key. It returns os.ErrNotExist if there is
+// no such device.
+func (s *Service) Unpair(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u, ok := s.users[id]; !ok || u.Type != DeviceType {
+		return os.ErrNotExist
+	}
+	users := make(map[string]User, len(s.users))
+	for k, u := range s.users {
+		if k != id {
+			users[k] = u
+		}
+	}
+	if err := s.writeUsers(users); err != nil {
+		return err
+	}
+	s.users = users
+	return nil
+}
+
+// RemoveUsers deletes users.json, dropping every password and app key:
+// the service is back in open mode.
+func (s *Service) RemoveUsers() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.configDir != "" {
+		if err := os.Remove(s.usersPath()); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	s.users = make(map[string]User)
+	return nil
+}
+
+// writeUsers replaces users.json, readable by the daemon's user only as
+// it holds keys. Must be called with s.mu held.
+func (s *Service) writeUsers(users map[string]User) error {
+	if s.configDir == "" {
+		return errors.New("no config directory to store keys in")
+	}
+	data, err := json.MarshalIndent(users, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(s.configDir, ".users-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.configDir, usersFileName))
+}
@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"sort"
+)
+
+// Scope restricts a key to controlling some zones and groups, e.g. a
+// tablet in the kids' room that may only control zones 4 and 5. A scoped
+// key can still read the whole state but is never an administrator; with
+// no zones or groups it can control nothing.
+type Scope struct {
+	Zones  []int `json:"zones"`
+	Groups []int `json:"groups"`
+}
+
+// Profile is the permission profile of a user or paired app.
+type Profile struct {
+	ID    string `json:"id"`
+	Type  string `json:"type"`
+	Name  string `json:"name,omitempty"`
+	Scope *Scope `json:"scope,omitempty"`
+}
+
+// Profiles lists the permission profiles of every user and paired app, by
+// ID.
+func (s *Service) Profiles() []Profile {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	profiles := []Profile{}
+	for id, u := range s.users {
+		profiles = append(profiles, Profile{ID: id, Type: u.Type, Name: u.Name, Scope: u.Scope})
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].ID < profiles[j].ID })
+	return profiles
+}
+
+// SetScope restricts the user or paired app id to sc, or lifts its
+// restriction if sc is nil. It returns os.ErrNotExist if there is no such
+// user.
+func (s *Service) SetScope(id string, sc *Scope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[id]; !ok {
+		return os.ErrNotExist
+	}
+	users := make(map[string]User, len(s.users))
+	for k, u := range s.users {
+		users[k] = u
+	}
+	u := users[id]
+	u.Scope = nil
+	if sc != nil {
+		cp := Scope{Zones: append([]int{}, sc.Zones...), Groups: append([]int{}, sc.Groups...)}
+		u.Scope = &cp
+	}
+	users[id] = u
+	if err := s.writeUsers(users); err != nil {
+		return err
+	}
+	s.users = users
+	return nil
+}
+
+// ScopeFor returns the scope the request is restricted to, nil if it may
+// control everything: in open mode, on trusted connections, or with a key
+// that has no scope.
+func (s *Service) ScopeFor(r *http.Request) *Scope {
+	if s.IsOpenMode() || trusted(r.Context()) {
+		return nil
+	}
+	var scope *Scope
+	for _, u := range s.requestUsers(r) {
+		if u.Scope == nil {
+			return nil
+		}
+		scope = u.Scope
+	}
+	return scope
+}
+
+// KeyAccess is ScopeFor and IsAdmin for a client that signed in with key
+// over a protocol other than HTTP, such as JSON-RPC or telnet. ok is false
+// if no user has the key.
+func (s *Service) KeyAccess(key string) (admin bool, sc *Scope, ok bool) {
+	u, ok := s.userFor(key)
+	if !ok {
+		return false, nil, false
+	}
+	return u.Type != DeviceType && u.Scope == nil, u.Scope, true
+}
+
+// KeyChecker checks the keys of clients that sign in over a protocol other
+// than HTTP. Service is one.
+type KeyChecker interface {
+	IsOpenMode() bool
+	KeyAccess(key string) (admin bool, sc *Scope, ok bool)
+}
+
+// ContextForKey marks ctx with what a client of a protocol other than HTTP
+// may do, as the HTTP API marks requests. key is the one it signed in
+// with, nil before it did. Servers call this for every command and
+// notification, so setting a password or removing the key locks out
+// sessions that are already open. ok is false if the client may do
+// nothing; kc may be nil when authentication is disabled.
+func ContextForKey(ctx context.Context, kc KeyChecker, key *string) (_ context.Context, ok bool) {
+	if kc == nil || kc.IsOpenMode() {
+		return WithAdmin(ctx), true
+	}
+	if key == nil {
+		return ctx, false
+	}
+	admin, sc, ok := kc.KeyAccess(*key)
+	if !ok {
+		return ctx, false
+	}
+	if admin {
+		ctx = WithAdmin(ctx)
+	}
+	if sc != nil {
+		ctx = WithScope(ctx, sc)
+	}
+	return ctx, true
+}
+
+type scopeKey struct{}
+
+// WithScope marks ctx as carrying a request restricted to sc, which the
+// controller enforces.
+func WithScope(ctx context.Context, sc *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, sc)
+}
+
+// ScopeFrom returns the scope of the request ctx carries, nil if it is not
+// restricted.
+func ScopeFrom(ctx context.Context) *Scope {
+	sc, _ := ctx.Value(scopeKey{}).(*Scope)
+	return sc
+}
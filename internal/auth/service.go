@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -22,6 +23,8 @@ type User struct {
 	AccessKey        string `json:"access_key"`
 	AccessKeyUpdated string `json:"access_key_updated"`
 	PasswordHash     string `json:"password_hash,omitempty"`
+	Name             string `json:"name,omitempty"`  // a paired device's name
+	Scope            *Scope `json:"scope,omitempty"` // nil: controls everything
 }
 
 // Service handles authentication for AmpliPi.
@@ -30,6 +33,9 @@ type Service struct {
 	configDir string
 	users     map[string]User
 	watcher   *fsnotify.Watcher
+
+	pairUntil time.Time        // apps can pair until then
+	now       func() time.Time // replaced in tests
 }
 
 // NewService creates a new auth service watching the given config directory.
@@ -37,6 +43,7 @@ func NewService(configDir string) (*Service, error) {
 	s := &Service{
 		configDir: configDir,
 		users:     make(map[string]User),
+		now:       time.Now,
 	}
 
 	// Load initial state (missing file is OK — open mode)
@@ -106,17 +113,23 @@ func (s *Service) IsOpenMode() bool {
 // VerifyKey returns true if the given access key matches any user's access key.
 // Uses constant-time comparison to prevent timing attacks.
 func (s *Service) VerifyKey(key string) bool {
+	_, ok := s.userFor(key)
+	return ok
+}
+
+// userFor returns the user whose access key is key.
+func (s *Service) userFor(key string) (User, bool) {
 	if key == "" {
-		return false
+		return User{}, false
 	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	for _, u := range s.users {
 		if subtle.ConstantTimeCompare([]byte(key), []byte(u.AccessKey)) == 1 {
-			return true
+			return u, true
 		}
 	}
-	return false
+	return User{}, false
 }
 
 // Close stops the file watcher.
@@ -0,0 +1,152 @@
+// Package bridge is a local smart-home bridge for voice assistants. It
+// emulates a Philips Hue bridge on the LAN, exposing each zone as a
+// dimmable light: on/off unmutes and mutes the zone and brightness sets its
+// volume, so "Alexa, turn on the kitchen" works without a cloud skill. The
+// bridge is toggled with the "bridge" setting.
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Controller is the part of the controller the bridge drives.
+type Controller interface {
+	State() models.State
+	SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError)
+}
+
+// EventBus delivers state updates, from which the bridge follows its
+// setting.
+type EventBus interface {
+	Subscribe(id string) <-chan models.State
+	Unsubscribe(id string)
+}
+
+// Bridge serves the Hue API and answers SSDP discovery while enabled.
+type Bridge struct {
+	ctrl Controller
+	port int    // HTTP port the Hue API is served on
+	id   string // bridge UUID, kept in the config dir so paired assistants find it again
+
+	mu      sync.Mutex
+	enabled bool
+	stop    context.CancelFunc // stops the SSDP responder; nil while disabled
+}
+
+// idFile holds the bridge UUID in the config dir.
+const idFile = "bridge-id"
+
+// New creates a bridge whose Hue API is served on the daemon's HTTP port.
+// Its UUID is read from configDir, or created there on first use:
+// assistants identify the bridge by it and would otherwise see a new one
+// after every restart.
+func New(ctrl Controller, port int, configDir string) (*Bridge, error) {
+	id, err := loadID(filepath.Join(configDir, idFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Bridge{ctrl: ctrl, port: port, id: id}, nil
+}
+
+// loadID reads the UUID in path, writing a new one if there is none.
+func loadID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id, err := uuid.Parse(strings.TrimSpace(string(data)))
+		if err != nil {
+			return "", fmt.Errorf("bridge id %s: %w", path, err)
+		}
+		return id.String(), nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	id := uuid.New().String()
+	if err := os.WriteFile(path, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("saving the bridge id: %w", err)
+	}
+	return id, nil
+}
+
+// Enabled reports whether the bridge is on.
+func (b *Bridge) Enabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.enabled
+}
+
+// Run follows the bridge setting, answering SSDP discovery while it is
+// enabled. Blocks until ctx is cancelled.
+func (b *Bridge) Run(ctx context.Context, bus EventBus) {
+	id := "bridge-" + uuid.New().String()
+	ch := bus.Subscribe(id)
+	defer bus.Unsubscribe(id)
+
+	b.setEnabled(ctx, b.ctrl.State().Settings.Bridge.Enabled)
+	for {
+		select {
+		case <-ctx.Done():
+			b.setEnabled(ctx, false)
+			return
+		case s, ok := <-ch:
+			if !ok {
+				return
+			}
+			b.setEnabled(ctx, s.Settings.Bridge.Enabled)
+		}
+	}
+}
+
+// setEnabled starts or stops the SSDP responder.
+func (b *Bridge) setEnabled(ctx context.Context, on bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if on == b.enabled {
+		return
+	}
+	b.enabled = on
+	if !on {
+		b.stop()
+		b.stop = nil
+		slog.Info("smart-home bridge disabled")
+		return
+	}
+	ssdpCtx, cancel := context.WithCancel(ctx)
+	b.stop = cancel
+	go func() {
+		if err := b.serveSSDP(ssdpCtx); err != nil {
+			slog.Warn("smart-home bridge: SSDP discovery unavailable", "err", err)
+		}
+	}()
+	slog.Info("smart-home bridge enabled", "port", b.port)
+}
+
+// Handler returns the Hue API and device description. Requests are
+// answered with 404 while the bridge is disabled.
+func (b *Bridge) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /description.xml", b.description)
+	mux.HandleFunc("POST /api", b.createUser)
+	mux.HandleFunc("GET /api/{user}/config", b.getConfig)
+	mux.HandleFunc("GET /api/{user}/lights", b.getLights)
+	mux.HandleFunc("GET /api/{user}/lights/{id}", b.getLight)
+	mux.HandleFunc("PUT /api/{user}/lights/{id}/state", b.setLight)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !b.Enabled() {
+			http.NotFound(w, r)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,150 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+)
+
+func newTestBridge(t *testing.T) (*Bridge, *controller.Controller) {
+	t.Helper()
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(ctrl, 80, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, ctrl
+}
+
+func serve(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	return rec
+}
+
+func TestHueLights(t *testing.T) {
+	b, ctrl := newTestBridge(t)
+	h := b.Handler()
+
+	if rec := serve(h, "GET", "/api/user/lights", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("disabled bridge answered with %d", rec.Code)
+	}
+	b.enabled = true
+
+	rec := serve(h, "GET", "/api/user/lights", "")
+	var lights map[string]light
+	if err := json.Unmarshal(rec.Body.Bytes(), &lights); err != nil {
+		t.Fatal(err)
+	}
+	if len(lights) != 6 || lights["1"].Name != ctrl.State().Zones[0].Name {
+		t.Errorf("lights = %+v", lights)
+	}
+
+	rec = serve(h, "PUT", "/api/user/lights/3/state", `{"on":true,"bri":127}`)
+	if !strings.Contains(rec.Body.String(), `"/lights/3/state/on":true`) {
+		t.Errorf("state change response = %s", rec.Body)
+	}
+	if z := ctrl.State().Zones[2]; z.Mute || z.VolF != 0.5 {
+		t.Errorf("zone 2 after Hue update: mute = %v, vol_f = %v", z.Mute, z.VolF)
+	}
+
+	rec = serve(h, "GET", "/api/user/lights/99", "")
+	if !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Errorf("unknown light response = %s", rec.Body)
+	}
+	rec = serve(h, "POST", "/api", `{"devicetype":"echo"}`)
+	if !strings.Contains(rec.Body.String(), `"username"`) {
+		t.Errorf("pairing response = %s", rec.Body)
+	}
+}
+
+func TestBridgeIDPersists(t *testing.T) {
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	first, err := New(ctrl, 80, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := New(ctrl, 80, dir)
+	if err != nil || again.id != first.id {
+		t.Errorf("bridge id after restart = %q (%v), want %q", again.id, err, first.id)
+	}
+
+	os.WriteFile(filepath.Join(dir, idFile), []byte("not a uuid\n"), 0644)
+	if _, err := New(ctrl, 80, dir); err == nil {
+		t.Error("corrupt bridge id accepted")
+	}
+}
+
+func TestBridgeFollowsSetting(t *testing.T) {
+	b, ctrl := newTestBridge(t)
+	bus := events.NewBus()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Run(ctx, bus)
+		close(done)
+	}()
+
+	s := ctrl.State()
+	s.Settings.Bridge.Enabled = true
+	waitFor(t, func() bool { return bus.SubscriberCount() == 1 })
+	bus.Publish(s)
+	waitFor(t, b.Enabled)
+	cancel()
+	<-done
+	if b.Enabled() {
+		t.Error("bridge still enabled after Run returned")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSSDPSearch(t *testing.T) {
+	b, err := New(nil, 8080, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := "M-SEARCH * HTTP/1.1\r\nHOST: 239.255.255.250:1900\r\nMAN: \"ssdp:discover\"\r\nMX: 1\r\nST: urn:schemas-upnp-org:device:basic:1\r\n\r\n"
+	st, ok := searchTarget([]byte(req))
+	if !ok {
+		t.Fatal("basic device search not answered")
+	}
+	resp := string(b.searchResponse(st, net.IPv4(192, 168, 1, 5)))
+	if !strings.Contains(resp, "LOCATION: http://192.168.1.5:8080/description.xml") || !strings.Contains(resp, "IpBridge") {
+		t.Errorf("response = %q", resp)
+	}
+	if _, ok := searchTarget([]byte(strings.Replace(req, "device:basic:1", "device:MediaRenderer:1", 1))); ok {
+		t.Error("answered a search for another device type")
+	}
+	if _, ok := searchTarget([]byte("NOTIFY * HTTP/1.1\r\n\r\n")); ok {
+		t.Error("answered a NOTIFY")
+	}
+}
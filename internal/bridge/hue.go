@@ -0,0 +1,182 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// hueUser is the username handed to every client that pairs. The bridge
+// does not press-to-pair: it is only reachable while enabled.
+const hueUser = "amplipi"
+
+// light is a zone in Hue terms. Light IDs are zone IDs plus one, as Hue
+// numbers lights from 1.
+type light struct {
+	State struct {
+		On        bool   `json:"on"`
+		Bri       int    `json:"bri"`
+		Alert     string `json:"alert"`
+		Reachable bool   `json:"reachable"`
+	} `json:"state"`
+	Type             string `json:"type"`
+	Name             string `json:"name"`
+	ModelID          string `json:"modelid"`
+	ManufacturerName string `json:"manufacturername"`
+	UniqueID         string `json:"uniqueid"`
+	SWVersion        string `json:"swversion"`
+}
+
+// hueState is the body of a light state change.
+type hueState struct {
+	On  *bool `json:"on,omitempty"`
+	Bri *int  `json:"bri,omitempty"` // 1-254
+}
+
+func zoneLight(z models.Zone) light {
+	var l light
+	l.State.On = !z.Mute
+	l.State.Bri = volFToBri(z.VolF)
+	l.State.Alert = "none"
+	l.State.Reachable = true
+	l.Type = "Dimmable light"
+	l.Name = z.Name
+	l.ModelID = "LWB007"
+	l.ManufacturerName = "MicroNova"
+	l.UniqueID = fmt.Sprintf("00:17:88:01:00:00:%02x:%02x-0b", z.ID>>8, z.ID&0xff)
+	l.SWVersion = "66012040"
+	return l
+}
+
+// volFToBri maps a volume fraction onto Hue brightness 1-254.
+func volFToBri(v float64) int {
+	return max(1, min(254, int(math.Round(v*254))))
+}
+
+// briToVolF maps Hue brightness onto a volume fraction.
+func briToVolF(bri int) float64 {
+	return float64(max(0, min(254, bri))) / 254
+}
+
+func (b *Bridge) createUser(w http.ResponseWriter, r *http.Request) {
+	writeHue(w, []map[string]interface{}{{"success": map[string]string{"username": hueUser}}})
+}
+
+func (b *Bridge) getConfig(w http.ResponseWriter, r *http.Request) {
+	writeHue(w, map[string]interface{}{
+		"name":       "AmpliPi",
+		"bridgeid":   b.bridgeID(),
+		"modelid":    "BSB002",
+		"apiversion": "1.17.0",
+		"swversion":  "1935144020",
+		"mac":        "00:17:88:00:00:00",
+	})
+}
+
+func (b *Bridge) getLights(w http.ResponseWriter, r *http.Request) {
+	lights := make(map[string]light)
+	for _, z := range b.ctrl.State().Zones {
+		if !z.Disabled {
+			lights[strconv.Itoa(z.ID+1)] = zoneLight(z)
+		}
+	}
+	writeHue(w, lights)
+}
+
+func (b *Bridge) getLight(w http.ResponseWriter, r *http.Request) {
+	z, ok := b.findZone(r.PathValue("id"))
+	if !ok {
+		writeHueError(w, r, 3, "resource not available")
+		return
+	}
+	writeHue(w, zoneLight(z))
+}
+
+func (b *Bridge) setLight(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	z, ok := b.findZone(id)
+	if !ok {
+		writeHueError(w, r, 3, "resource not available")
+		return
+	}
+	var req hueState
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeHueError(w, r, 2, "body contains invalid JSON")
+		return
+	}
+	var upd models.ZoneUpdate
+	if req.On != nil {
+		mute := !*req.On
+		upd.Mute = &mute
+	}
+	if req.Bri != nil {
+		volF := briToVolF(*req.Bri)
+		upd.VolF = &volF
+	}
+	if _, appErr := b.ctrl.SetZone(r.Context(), z.ID, upd); appErr != nil {
+		writeHueError(w, r, 901, appErr.Message)
+		return
+	}
+	// Hue acknowledges each attribute that was set.
+	prefix := "/lights/" + id + "/state/"
+	var result []map[string]interface{}
+	if req.On != nil {
+		result = append(result, map[string]interface{}{"success": map[string]interface{}{prefix + "on": *req.On}})
+	}
+	if req.Bri != nil {
+		result = append(result, map[string]interface{}{"success": map[string]interface{}{prefix + "bri": *req.Bri}})
+	}
+	writeHue(w, result)
+}
+
+// findZone returns the enabled zone with Hue light ID id.
+func (b *Bridge) findZone(id string) (models.Zone, bool) {
+	n, err := strconv.Atoi(id)
+	if err != nil {
+		return models.Zone{}, false
+	}
+	for _, z := range b.ctrl.State().Zones {
+		if z.ID == n-1 && !z.Disabled {
+			return z, true
+		}
+	}
+	return models.Zone{}, false
+}
+
+// bridgeID is the Hue bridge ID: 16 hex digits derived from the UUID.
+func (b *Bridge) bridgeID() string {
+	id := []byte{}
+	for _, c := range b.id {
+		if c != '-' && len(id) < 16 {
+			id = append(id, byte(c))
+		}
+	}
+	return string(id)
+}
+
+// localIP returns the address other hosts on the LAN reach this one on.
+func localIP() (net.IP, error) {
+	conn, err := net.Dial("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// writeHue writes a Hue API response; Hue answers errors with 200 too.
+func writeHue(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeHueError(w http.ResponseWriter, r *http.Request, typ int, desc string) {
+	writeHue(w, []map[string]interface{}{{"error": map[string]interface{}{
+		"type": typ, "address": r.URL.Path, "description": desc,
+	}}})
+}
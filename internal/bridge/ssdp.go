@@ -0,0 +1,119 @@
+package bridge
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ssdpAddr is the SSDP multicast group.
+const ssdpAddr = "239.255.255.250:1900"
+
+// serveSSDP answers M-SEARCH discovery requests for Hue bridges until ctx
+// is cancelled.
+func (b *Bridge) serveSSDP(ctx context.Context) error {
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return err
+	}
+	conn, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	buf := make([]byte, 2048)
+	for {
+		n, from, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		st, ok := searchTarget(buf[:n])
+		if !ok {
+			continue
+		}
+		ip, err := localIP()
+		if err != nil {
+			slog.Debug("smart-home bridge: no local address", "err", err)
+			continue
+		}
+		resp := b.searchResponse(st, ip)
+		if _, err := conn.WriteToUDP(resp, from); err != nil {
+			slog.Debug("smart-home bridge: SSDP reply failed", "to", from, "err", err)
+		}
+	}
+}
+
+// searchTarget returns the ST of an M-SEARCH request that a Hue bridge
+// answers: all devices, root devices or basic devices.
+func searchTarget(req []byte) (string, bool) {
+	if !bytes.HasPrefix(req, []byte("M-SEARCH")) {
+		return "", false
+	}
+	for _, line := range strings.Split(string(req), "\r\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(strings.TrimSpace(name), "ST") {
+			continue
+		}
+		st := strings.TrimSpace(value)
+		switch st {
+		case "ssdp:all", "upnp:rootdevice", "urn:schemas-upnp-org:device:basic:1":
+			return st, true
+		}
+	}
+	return "", false
+}
+
+// searchResponse is the reply to an M-SEARCH for st.
+func (b *Bridge) searchResponse(st string, ip net.IP) []byte {
+	if st == "ssdp:all" {
+		st = "upnp:rootdevice"
+	}
+	return []byte(fmt.Sprintf("HTTP/1.1 200 OK\r\n"+
+		"CACHE-CONTROL: max-age=100\r\n"+
+		"EXT:\r\n"+
+		"LOCATION: http://%s:%d/description.xml\r\n"+
+		"SERVER: Linux/3.14.0 UPnP/1.0 IpBridge/1.17.0\r\n"+
+		"hue-bridgeid: %s\r\n"+
+		"ST: %s\r\n"+
+		"USN: uuid:%s::%s\r\n\r\n",
+		ip, b.port, strings.ToUpper(b.bridgeID()), st, b.id, st))
+}
+
+// description serves the UPnP device description that SSDP replies
+// point to.
+func (b *Bridge) description(w http.ResponseWriter, r *http.Request) {
+	ip, err := localIP()
+	if err != nil {
+		ip = net.IPv4(127, 0, 0, 1)
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8" ?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<specVersion><major>1</major><minor>0</minor></specVersion>
+<URLBase>http://%s:%d/</URLBase>
+<device>
+<deviceType>urn:schemas-upnp-org:device:Basic:1</deviceType>
+<friendlyName>AmpliPi (%s)</friendlyName>
+<manufacturer>Royal Philips Electronics</manufacturer>
+<manufacturerURL>http://www.philips.com</manufacturerURL>
+<modelDescription>Philips hue Personal Wireless Lighting</modelDescription>
+<modelName>Philips hue bridge 2015</modelName>
+<modelNumber>BSB002</modelNumber>
+<modelURL>http://www.meethue.com</modelURL>
+<serialNumber>%s</serialNumber>
+<UDN>uuid:%s</UDN>
+</device>
+</root>
+`, ip, b.port, ip, b.bridgeID(), b.id)
+}
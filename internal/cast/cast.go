@@ -0,0 +1,202 @@
+// Package cast discovers Google Cast (Chromecast) devices via mDNS and
+// controls playback on them through the go-chromecast CLI, so rooms without
+// wired speakers can play AmpliPi sources and announcements.
+package cast
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// ServiceType is the DNS-SD service advertised by Cast devices.
+const ServiceType = "_googlecast._tcp"
+
+// Binary is the Cast control CLI (github.com/vishen/go-chromecast).
+var Binary = "go-chromecast"
+
+// commandTimeout bounds every go-chromecast invocation.
+const commandTimeout = 15 * time.Second
+
+// Device is a Cast receiver found on the LAN.
+type Device struct {
+	ID    string `json:"id"`    // stable UUID from the TXT "id" record
+	Name  string `json:"name"`  // friendly name, e.g. "Kitchen speaker"
+	Model string `json:"model"` // e.g. "Google Home Mini"
+	Addr  string `json:"addr"`
+	Port  int    `json:"port"`
+}
+
+// deviceFromEntry builds a Device from an mDNS browse result.
+func deviceFromEntry(e *zeroconf.ServiceEntry) (Device, bool) {
+	d := Device{Port: e.Port, Name: e.Instance}
+	for _, txt := range e.Text {
+		k, v, ok := strings.Cut(txt, "=")
+		if !ok {
+			continue
+		}
+		switch k {
+		case "id":
+			d.ID = v
+		case "fn":
+			d.Name = v
+		case "md":
+			d.Model = v
+		}
+	}
+	switch {
+	case len(e.AddrIPv4) > 0:
+		d.Addr = e.AddrIPv4[0].String()
+	case len(e.AddrIPv6) > 0:
+		d.Addr = e.AddrIPv6[0].String()
+	}
+	return d, d.ID != "" && d.Addr != ""
+}
+
+// Browser keeps the set of Cast devices seen on the network.
+// All methods are safe for concurrent use.
+type Browser struct {
+	mu      sync.RWMutex
+	devices map[string]Device
+}
+
+// NewBrowser creates an empty Browser. Call Run to start discovery.
+func NewBrowser() *Browser {
+	return &Browser{devices: make(map[string]Device)}
+}
+
+// Devices returns all known devices sorted by name.
+func (b *Browser) Devices() []Device {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	result := make([]Device, 0, len(b.devices))
+	for _, d := range b.devices {
+		result = append(result, d)
+	}
+	slices.SortFunc(result, func(a, b Device) int { return strings.Compare(a.Name, b.Name) })
+	return result
+}
+
+// Device returns the device with the given ID.
+func (b *Browser) Device(id string) (Device, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	d, ok := b.devices[id]
+	return d, ok
+}
+
+// Add records a device. Used by Scan and by tests.
+func (b *Browser) Add(d Device) {
+	b.mu.Lock()
+	b.devices[d.ID] = d
+	b.mu.Unlock()
+}
+
+// Scan browses for Cast devices for the given duration and replaces the
+// known set with what answered.
+func (b *Browser) Scan(ctx context.Context, timeout time.Duration) error {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return fmt.Errorf("cast: mDNS resolver: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := make(map[string]Device)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range entries {
+			if d, ok := deviceFromEntry(e); ok {
+				found[d.ID] = d
+			}
+		}
+	}()
+	if err := resolver.Browse(ctx, ServiceType, "local.", entries); err != nil {
+		return fmt.Errorf("cast: browse: %w", err)
+	}
+	<-done
+
+	b.mu.Lock()
+	b.devices = found
+	b.mu.Unlock()
+	return nil
+}
+
+// Run rescans every interval until ctx is cancelled.
+func (b *Browser) Run(ctx context.Context, interval time.Duration) {
+	for {
+		if err := b.Scan(ctx, 5*time.Second); err != nil {
+			slog.Warn("cast: discovery failed", "err", err)
+		} else {
+			slog.Debug("cast: discovery complete", "devices", len(b.Devices()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// runCommand executes the Cast CLI. Replaced in tests.
+var runCommand = func(ctx context.Context, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s %s: %w: %s", name, args[0], err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// deviceArgs addresses a device directly so go-chromecast skips its own discovery.
+func deviceArgs(d Device) []string {
+	return []string{"--addr", d.Addr, "--port", strconv.Itoa(d.Port)}
+}
+
+// Load makes the device play mediaURL (which it fetches itself). An empty
+// contentType lets go-chromecast guess it from the URL.
+func Load(ctx context.Context, d Device, mediaURL, contentType string) error {
+	args := []string{"load", mediaURL}
+	if contentType != "" {
+		args = append(args, "--content-type", contentType)
+	}
+	args = append(args, deviceArgs(d)...)
+	return runCommand(ctx, Binary, args...)
+}
+
+// SetVolume sets the device volume (0-100).
+func SetVolume(ctx context.Context, d Device, percent int) error {
+	level := strconv.FormatFloat(float64(percent)/100, 'f', 2, 64)
+	args := append([]string{"volume", level}, deviceArgs(d)...)
+	return runCommand(ctx, Binary, args...)
+}
+
+// Stop ends playback on the device.
+func Stop(ctx context.Context, d Device) error {
+	args := append([]string{"stop"}, deviceArgs(d)...)
+	return runCommand(ctx, Binary, args...)
+}
+
+// LocalAddrFor returns this host's address on the interface that routes
+// to d, i.e. the address the device can fetch media from.
+func LocalAddrFor(d Device) (string, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(d.Addr, strconv.Itoa(d.Port)))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	host, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	return host, err
+}
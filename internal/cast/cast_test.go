@@ -0,0 +1,79 @@
+package cast
+
+import (
+	"context"
+	"net"
+	"slices"
+	"testing"
+
+	"github.com/grandcat/zeroconf"
+)
+
+func TestDeviceFromEntry(t *testing.T) {
+	e := zeroconf.NewServiceEntry("Google-Home-Mini-abc", ServiceType, "local.")
+	e.Port = 8009
+	e.AddrIPv4 = []net.IP{net.ParseIP("192.168.1.40")}
+	e.Text = []string{"id=abc123", "md=Google Home Mini", "fn=Kitchen speaker", "junk"}
+
+	d, ok := deviceFromEntry(e)
+	if !ok {
+		t.Fatal("deviceFromEntry rejected a complete entry")
+	}
+	want := Device{ID: "abc123", Name: "Kitchen speaker", Model: "Google Home Mini", Addr: "192.168.1.40", Port: 8009}
+	if d != want {
+		t.Errorf("device = %+v, want %+v", d, want)
+	}
+
+	e.Text = []string{"fn=No id"}
+	if _, ok := deviceFromEntry(e); ok {
+		t.Error("entry without id should be rejected")
+	}
+}
+
+func TestBrowserDevicesSortedByName(t *testing.T) {
+	b := NewBrowser()
+	b.Add(Device{ID: "2", Name: "Patio"})
+	b.Add(Device{ID: "1", Name: "Kitchen"})
+	b.Add(Device{ID: "2", Name: "Porch"}) // replaces by ID
+
+	var names []string
+	for _, d := range b.Devices() {
+		names = append(names, d.Name)
+	}
+	if !slices.Equal(names, []string{"Kitchen", "Porch"}) {
+		t.Errorf("names = %v", names)
+	}
+	if _, ok := b.Device("3"); ok {
+		t.Error("unknown device found")
+	}
+}
+
+func TestCommands(t *testing.T) {
+	var got [][]string
+	orig := runCommand
+	runCommand = func(_ context.Context, name string, args ...string) error {
+		got = append(got, append([]string{name}, args...))
+		return nil
+	}
+	t.Cleanup(func() { runCommand = orig })
+
+	d := Device{ID: "x", Addr: "10.0.0.5", Port: 8009}
+	ctx := context.Background()
+	_ = Load(ctx, d, "http://10.0.0.2/cast/sources/1.mp3", "audio/mpeg")
+	_ = SetVolume(ctx, d, 35)
+	_ = Stop(ctx, d)
+
+	want := [][]string{
+		{Binary, "load", "http://10.0.0.2/cast/sources/1.mp3", "--content-type", "audio/mpeg", "--addr", "10.0.0.5", "--port", "8009"},
+		{Binary, "volume", "0.35", "--addr", "10.0.0.5", "--port", "8009"},
+		{Binary, "stop", "--addr", "10.0.0.5", "--port", "8009"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d commands, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("command %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,229 @@
+// Package clips keeps the announcement clip library: short audio files
+// such as a doorbell chime, uploaded once and played by name with
+// "clip:<name>" as announcement media instead of an external URL. Each
+// clip is stored as <name>.<format> in the clips directory of the config
+// directory, within size quotas.
+package clips
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// DirName is the clip directory in the config directory.
+const DirName = "clips"
+
+// Quotas.
+const (
+	MaxClipSize = 10 << 20  // bytes of one clip
+	Quota       = 100 << 20 // bytes of all clips
+	MaxClips    = 100
+)
+
+var (
+	// ErrNotFound is returned for an unknown clip name.
+	ErrNotFound = errors.New("clip not found")
+	// ErrQuota is returned for a clip over MaxClipSize, or one that would
+	// take the library over Quota or MaxClips.
+	ErrQuota = errors.New("clip quota exceeded")
+)
+
+// Formats are the file extensions clips may have.
+var Formats = []string{"mp3", "wav", "ogg", "oga", "opus", "flac", "m4a", "aac"}
+
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// CheckName validates a clip name: 1-64 letters, digits, '_' or '-', so it
+// can be used in URLs and media strings as is.
+func CheckName(name string) error {
+	if !validName.MatchString(name) {
+		return fmt.Errorf("clip name %q must be 1-64 letters, digits, '_' or '-'", name)
+	}
+	return nil
+}
+
+// CheckFormat validates a clip format, a file extension with or without
+// its dot.
+func CheckFormat(format string) error {
+	if !slices.Contains(Formats, normFormat(format)) {
+		return fmt.Errorf("clip format %q must be one of %s", format, strings.Join(Formats, ", "))
+	}
+	return nil
+}
+
+func normFormat(format string) string {
+	return strings.ToLower(strings.TrimPrefix(format, "."))
+}
+
+// Library is the clip directory. All methods are safe for concurrent use.
+type Library struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewLibrary opens the clip library in configDir, creating its directory.
+func NewLibrary(configDir string) (*Library, error) {
+	dir := filepath.Join(configDir, DirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Library{dir: dir}, nil
+}
+
+// List returns the clips by name, with the quota they use.
+func (l *Library) List() (models.ClipLibrary, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list, err := l.listLocked()
+	if err != nil {
+		return models.ClipLibrary{}, err
+	}
+	lib := models.ClipLibrary{Clips: list, Quota: Quota, MaxClip: MaxClipSize, MaxClips: MaxClips}
+	for _, c := range list {
+		lib.Used += c.Size
+	}
+	return lib, nil
+}
+
+// Path returns the file of the named clip.
+func (l *Library) Path(name string) (string, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, err := l.findLocked(name)
+	if err != nil {
+		return "", err
+	}
+	return l.file(c), nil
+}
+
+// Add stores the audio read from r as the named clip, replacing any clip
+// of that name. format is its file extension. check, if not nil, is
+// given the stored file before the clip is added, and its error refuses
+// the clip.
+func (l *Library) Add(name, format string, r io.Reader, check func(path string) error) (models.Clip, error) {
+	if err := CheckName(name); err != nil {
+		return models.Clip{}, err
+	}
+	if err := CheckFormat(format); err != nil {
+		return models.Clip{}, err
+	}
+	format = normFormat(format)
+
+	// Saved under a hidden name, which List skips, until it is checked.
+	tmp, err := os.CreateTemp(l.dir, ".upload-*."+format)
+	if err != nil {
+		return models.Clip{}, err
+	}
+	defer os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, io.LimitReader(r, MaxClipSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return models.Clip{}, err
+	}
+	if size > MaxClipSize {
+		return models.Clip{}, fmt.Errorf("%w: a clip may be at most %d bytes", ErrQuota, MaxClipSize)
+	}
+	if check != nil {
+		if err := check(tmp.Name()); err != nil {
+			return models.Clip{}, err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	list, err := l.listLocked()
+	if err != nil {
+		return models.Clip{}, err
+	}
+	var used int64
+	var old *models.Clip
+	for i, c := range list {
+		if c.Name == name {
+			old = &list[i]
+			continue
+		}
+		used += c.Size
+	}
+	if old == nil && len(list) >= MaxClips {
+		return models.Clip{}, fmt.Errorf("%w: the library holds at most %d clips", ErrQuota, MaxClips)
+	}
+	if used+size > Quota {
+		return models.Clip{}, fmt.Errorf("%w: %d of %d bytes are used", ErrQuota, used, Quota)
+	}
+	c := models.Clip{Name: name, Format: format, Size: size}
+	if err := os.Rename(tmp.Name(), l.file(c)); err != nil {
+		return models.Clip{}, err
+	}
+	if old != nil && old.Format != format {
+		os.Remove(l.file(*old))
+	}
+	fi, err := os.Stat(l.file(c))
+	if err != nil {
+		return models.Clip{}, err
+	}
+	c.Modified = fi.ModTime()
+	return c, nil
+}
+
+// Delete removes the named clip.
+func (l *Library) Delete(name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	c, err := l.findLocked(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(l.file(c))
+}
+
+// file is the path of clip c.
+func (l *Library) file(c models.Clip) string {
+	return filepath.Join(l.dir, c.Name+"."+c.Format)
+}
+
+// findLocked returns the named clip. Must be called with l.mu held.
+func (l *Library) findLocked(name string) (models.Clip, error) {
+	list, err := l.listLocked()
+	if err != nil {
+		return models.Clip{}, err
+	}
+	for _, c := range list {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return models.Clip{}, ErrNotFound
+}
+
+// listLocked reads the clips from the directory, skipping files that
+// aren't clips. Must be called with l.mu held.
+func (l *Library) listLocked() ([]models.Clip, error) {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.Clip{}
+	for _, e := range entries {
+		name, format, ok := strings.Cut(e.Name(), ".")
+		if !ok || !e.Type().IsRegular() || CheckName(name) != nil || !slices.Contains(Formats, format) {
+			continue
+		}
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		list = append(list, models.Clip{Name: name, Format: format, Size: fi.Size(), Modified: fi.ModTime()})
+	}
+	return list, nil
+}
@@ -0,0 +1,79 @@
+package clips
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLibrary(t *testing.T) {
+	cfg := t.TempDir()
+	lib, err := NewLibrary(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Files that aren't clips are ignored.
+	_ = os.WriteFile(filepath.Join(cfg, DirName, "notes.txt"), []byte("x"), 0644)
+
+	if _, err := lib.Add("doorbell", "mp3", strings.NewReader("ding"), nil); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if _, err := lib.Add("dinner-time", ".WAV", strings.NewReader("dinner"), nil); err != nil {
+		t.Fatalf("Add wav: %v", err)
+	}
+	got, err := lib.List()
+	if err != nil || len(got.Clips) != 2 || got.Used != 10 || got.Quota != Quota {
+		t.Fatalf("List = %+v, %v; want 2 clips of 10 bytes", got, err)
+	}
+	if c := got.Clips[0]; c.Name != "dinner-time" || c.Format != "wav" || c.Modified.IsZero() {
+		t.Errorf("first clip = %+v", c)
+	}
+
+	// Replacing a clip in another format leaves one file.
+	if _, err := lib.Add("doorbell", "ogg", strings.NewReader("dong"), nil); err != nil {
+		t.Fatalf("replace: %v", err)
+	}
+	path, err := lib.Path("doorbell")
+	if err != nil || filepath.Base(path) != "doorbell.ogg" {
+		t.Errorf("Path = %q, %v; want doorbell.ogg", path, err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg, DirName, "doorbell.mp3")); !os.IsNotExist(err) {
+		t.Errorf("replaced doorbell.mp3 still there: %v", err)
+	}
+
+	for _, tc := range []struct{ name, format string }{
+		{"../x", "mp3"}, {"", "mp3"}, {"two words", "mp3"}, {"chime", "txt"},
+	} {
+		if _, err := lib.Add(tc.name, tc.format, strings.NewReader("x"), nil); err == nil {
+			t.Errorf("Add(%q, %q) accepted", tc.name, tc.format)
+		}
+	}
+	refuse := errors.New("not audio")
+	if _, err := lib.Add("chime", "mp3", strings.NewReader("x"), func(string) error { return refuse }); !errors.Is(err, refuse) {
+		t.Errorf("refused by check: %v", err)
+	}
+	big := io.LimitReader(bytes.NewReader(make([]byte, MaxClipSize+1)), MaxClipSize+1)
+	if _, err := lib.Add("big", "wav", big, nil); !errors.Is(err, ErrQuota) {
+		t.Errorf("oversize clip: %v, want ErrQuota", err)
+	}
+	if got, _ := lib.List(); len(got.Clips) != 2 {
+		t.Errorf("refused clips were added: %+v", got.Clips)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(cfg, DirName)); len(entries) != 3 {
+		t.Errorf("uploads left behind: %v", entries)
+	}
+
+	if err := lib.Delete("doorbell"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := lib.Delete("doorbell"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Delete twice: %v, want ErrNotFound", err)
+	}
+	if _, err := lib.Path("doorbell"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Path of deleted: %v, want ErrNotFound", err)
+	}
+}
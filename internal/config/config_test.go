@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
@@ -70,6 +72,47 @@ func TestJSONStore_SaveLoadRoundTrip(t *testing.T) {
 	}
 }
 
+func TestJSONStore_SecretsKeptOutOfHouseJSON(t *testing.T) {
+	dir := newTempDir(t)
+	store := config.NewJSONStore(dir)
+
+	st := models.DefaultState()
+	st.Settings.Webhooks = []models.Webhook{
+		{ID: 0, Name: "signed", URL: "http://ha.local/hook", Secret: "s3cret"},
+		{ID: 1, Name: "unsigned", URL: "http://ha.local/other"},
+	}
+	st.Settings.Matter = models.MatterSettings{Discriminator: 3840, Passcode: 20202021}
+	if err := store.Save(&st); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := store.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+
+	house, _ := os.ReadFile(store.Path())
+	if strings.Contains(string(house), "s3cret") || strings.Contains(string(house), "20202021") {
+		t.Error("house.json contains a secret")
+	}
+	if !strings.Contains(string(house), `"has_secret": true`) {
+		t.Errorf("house.json lacks has_secret: %s", house)
+	}
+	info, err := os.Stat(filepath.Join(dir, "secrets.json"))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("secrets.json: %v, %v", info, err)
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if loaded.Settings.Webhooks[0].Secret != "s3cret" || loaded.Settings.Webhooks[1].Secret != "" {
+		t.Errorf("loaded webhooks = %+v", loaded.Settings.Webhooks)
+	}
+	if loaded.Settings.Matter.Passcode != 20202021 {
+		t.Errorf("loaded matter settings = %+v", loaded.Settings.Matter)
+	}
+}
+
 func TestJSONStore_CorruptJSON_ReturnsDefault(t *testing.T) {
 	dir := newTempDir(t)
 	store := config.NewJSONStore(dir)
@@ -609,3 +652,195 @@ func TestMemStore_SaveMutationIsolation(t *testing.T) {
 		t.Error("Save did not deep copy: mutation of original affected stored state")
 	}
 }
+
+func TestMigrate_ReportsChanges(t *testing.T) {
+	state := models.DefaultState()
+	if changes := config.Migrate(&state); len(changes) != 0 {
+		t.Errorf("default state: changes = %q", changes)
+	}
+
+	state.Zones[0].Vol = 10
+	state.Streams = nil
+	changes := config.Migrate(&state)
+	// The clamped volume, the Aux stream and four RCA streams
+	if len(changes) != 6 || changes[0] != "zones[0]: vol 10 lowered to vol_max 0" {
+		t.Errorf("changes = %q", changes)
+	}
+}
+
+// pythonHouse is a house.json as the Python AmpliPi writes it.
+const pythonHouse = `{
+  "sources": [
+    {"id": 0, "name": "Input 1", "input": "stream=1000"},
+    {"id": 1, "name": "Input 2", "input": "stream=1003"},
+    {"id": 2, "name": "Input 3", "input": "local"},
+    {"id": 3, "name": "Input 4", "input": ""}
+  ],
+  "zones": [
+    {"id": 0, "name": "Kitchen", "source_id": 0, "mute": false, "vol": -30, "vol_min": -80, "vol_max": 0, "disabled": false},
+    {"id": 1, "name": "Patio", "source_id": 0, "mute": true, "vol": -50, "vol_min": -80, "vol_max": 0, "disabled": false}
+  ],
+  "groups": [{"id": 100, "name": "Downstairs", "zones": [0, 1], "source_id": 0, "mute": false, "vol_delta": -40}],
+  "streams": [
+    {"id": 996, "name": "Input 1", "type": "rca", "index": 0, "disabled": false},
+    {"id": 1000, "name": "Groove Salad", "type": "internetradio", "url": "http://ice1.somafm.com/groovesalad-256-mp3", "logo": "https://somafm.com/img3/groovesalad-400.jpg"},
+    {"id": 1001, "name": "Living Room", "type": "shairport", "ap2": true},
+    {"id": 1002, "name": "Doorbell", "type": "fileplayer", "url": "/home/pi/doorbell.mp3"},
+    {"id": 1003, "name": "USB", "type": "media_device", "url": "/media/usb"}
+  ],
+  "presets": [
+    {"id": 1, "name": "Party", "state": {
+      "sources": [{"id": 1, "input": "stream=1003"}],
+      "zones": [{"id": 1, "vol": -20}],
+      "groups": [{"id": 100, "mute": false, "vol_delta": -35}]
+    }, "commands": [{"stream_id": 1000, "cmd": "play"}], "last_used": 1700000000},
+    {"id": 10000, "name": "Mute All", "state": {"zones": [{"id": 0, "mute": true}, {"id": 1, "mute": true}]}}
+  ]
+}`
+
+func TestImportPython(t *testing.T) {
+	if !config.IsPythonConfig([]byte(pythonHouse)) {
+		t.Fatal("Python config not detected")
+	}
+	def := models.DefaultState()
+	if data, _ := json.Marshal(def); config.IsPythonConfig(data) {
+		t.Error("Go config detected as Python")
+	}
+
+	state, notes, err := config.ImportPython([]byte(pythonHouse))
+	if err != nil {
+		t.Fatal(err)
+	}
+	streams := make(map[int]models.Stream)
+	for _, st := range state.Streams {
+		streams[st.ID] = st
+	}
+	if st := streams[1000]; st.Config["url"] != "http://ice1.somafm.com/groovesalad-256-mp3" || st.Config["logo"] == nil {
+		t.Errorf("internet radio = %+v", st)
+	}
+	if st := streams[1001]; st.Type != models.StreamTypeAirPlay || st.Config != nil {
+		t.Errorf("shairport = %+v", st)
+	}
+	if st := streams[1002]; st.Config["path"] != "/home/pi/doorbell.mp3" {
+		t.Errorf("file player = %+v", st)
+	}
+	if _, ok := streams[1003]; ok {
+		t.Error("unsupported media_device stream kept")
+	}
+	if state.Sources[1].Input != "" || state.Sources[2].Input != "local" {
+		t.Errorf("sources = %+v", state.Sources)
+	}
+
+	p := state.Presets[0]
+	if len(p.Commands) != 1 || p.Commands[0].Endpoint != "/api/streams/1000/play" {
+		t.Errorf("commands = %+v", p.Commands)
+	}
+	if g := p.State.Groups[0]; g.Vol != nil || g.Mute == nil {
+		t.Errorf("group update = %+v", g)
+	}
+	vols := make(map[int]int)
+	deltas := make(map[int]int)
+	for _, zu := range p.State.Zones {
+		if zu.Vol != nil {
+			vols[*zu.ID] = *zu.Vol
+		}
+		if zu.VolDelta != nil {
+			deltas[*zu.ID] = *zu.VolDelta
+		}
+	}
+	// The zone's own volume wins over the group's relative change.
+	if len(vols) != 1 || vols[1] != -20 || len(deltas) != 1 || deltas[0] != -35 {
+		t.Errorf("zone volumes = %v, deltas = %v", vols, deltas)
+	}
+
+	want := []string{
+		`stream 1001 "Living Room": type "shairport" renamed to "airplay"`,
+		`stream 1001 "Living Room": dropped setting "ap2", which is not supported`,
+		`stream 1003 "USB": type "media_device" is not supported; dropped`,
+		`source 1: cleared input "stream=1003", whose stream was dropped`,
+		`preset 1 "Party": cleared input "stream=1003", whose stream was dropped`,
+	}
+	if strings.Join(notes, "\n") != strings.Join(want, "\n") {
+		t.Errorf("notes:\n%s\nwant:\n%s", strings.Join(notes, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestJSONStore_ConvertsPythonConfig(t *testing.T) {
+	dir := newTempDir(t)
+	os.WriteFile(filepath.Join(dir, "house.json"), []byte(pythonHouse), 0644)
+
+	state, err := config.NewJSONStore(dir).Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	for _, st := range state.Streams {
+		if st.ID == 1000 && st.Config["url"] == nil {
+			t.Errorf("stream 1000 config = %v", st.Config)
+		}
+	}
+	if len(state.Streams) != 8 { // 4 converted plus the missing Aux and RCA inputs
+		t.Errorf("%d streams, want 8", len(state.Streams))
+	}
+}
+
+func TestLoadProvision(t *testing.T) {
+	dir := newTempDir(t)
+
+	p, err := config.LoadProvision(dir)
+	if err != nil || p != nil {
+		t.Fatalf("LoadProvision (missing) = %v, %v; want nil, nil", p, err)
+	}
+
+	tmpl := `{"zones":[{"id":0,"name":"Lobby","vol_max":-20}],"groups":[{"name":"Public","zones":[0]}]}`
+	if err := os.WriteFile(filepath.Join(dir, config.ProvisionFileName), []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	p, err = config.LoadProvision(dir)
+	if err != nil {
+		t.Fatalf("LoadProvision: %v", err)
+	}
+	if len(p.Zones) != 1 || *p.Zones[0].Name != "Lobby" || *p.Zones[0].VolMax != -20 || len(p.Groups) != 1 {
+		t.Errorf("LoadProvision = %+v", p)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, config.ProvisionFileName), []byte(`{"zones":`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadProvision(dir); err == nil {
+		t.Error("expected error for malformed template")
+	}
+}
+
+func TestProfileOverride(t *testing.T) {
+	dir := newTempDir(t)
+
+	o, err := config.LoadProfileOverride(dir)
+	if err != nil || !o.IsZero() {
+		t.Fatalf("LoadProfileOverride (missing) = %+v, %v; want zero, nil", o, err)
+	}
+
+	units, analog := 2, false
+	want := hardware.ProfileOverride{Units: &units, AnalogSources: &analog, UnavailableStreams: []string{"pandora"}}
+	if err := config.SaveProfileOverride(dir, want); err != nil {
+		t.Fatalf("SaveProfileOverride: %v", err)
+	}
+	o, err = config.LoadProfileOverride(dir)
+	if err != nil || *o.Units != 2 || *o.AnalogSources || len(o.UnavailableStreams) != 1 {
+		t.Fatalf("LoadProfileOverride = %+v, %v", o, err)
+	}
+
+	// Overriding nothing removes the file.
+	if err := config.SaveProfileOverride(dir, hardware.ProfileOverride{}); err != nil {
+		t.Fatalf("SaveProfileOverride (zero): %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, config.ProfileOverrideFileName)); !os.IsNotExist(err) {
+		t.Errorf("override file still there: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, config.ProfileOverrideFileName), []byte(`{"units":99}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadProfileOverride(dir); err == nil {
+		t.Error("expected error for an out of range unit count")
+	}
+}
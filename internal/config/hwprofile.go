@@ -0,0 +1,59 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+)
+
+// ProfileOverrideFileName is the hardware profile override in the config
+// directory. It is applied to the detected profile at startup.
+const ProfileOverrideFileName = "hardware_profile.json"
+
+// LoadProfileOverride reads hardware_profile.json from configDir. A missing
+// file overrides nothing; a malformed or invalid file is an error.
+func LoadProfileOverride(configDir string) (hardware.ProfileOverride, error) {
+	var o hardware.ProfileOverride
+	data, err := os.ReadFile(filepath.Join(configDir, ProfileOverrideFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return o, nil
+		}
+		return o, err
+	}
+	if err := json.Unmarshal(data, &o); err != nil {
+		return o, fmt.Errorf("config: parse %s: %w", ProfileOverrideFileName, err)
+	}
+	if err := o.Validate(); err != nil {
+		return o, fmt.Errorf("config: %s: %w", ProfileOverrideFileName, err)
+	}
+	return o, nil
+}
+
+// SaveProfileOverride writes o to hardware_profile.json in configDir, or
+// removes the file when o overrides nothing.
+func SaveProfileOverride(configDir string, o hardware.ProfileOverride) error {
+	path := filepath.Join(configDir, ProfileOverrideFileName)
+	if o.IsZero() {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	data, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(configDir, 0755); err != nil {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
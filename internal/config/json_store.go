@@ -14,6 +14,7 @@ import (
 
 const (
 	configFileName  = "house.json"
+	secretsFileName = "secrets.json" // models.Secrets, private to the daemon
 	debounceDelay   = 500 * time.Millisecond
 )
 
@@ -46,6 +47,17 @@ func (s *JSONStore) Load() (*models.State, error) {
 		return nil, err
 	}
 
+	if IsPythonConfig(data) {
+		if state, notes, err := ImportPython(data); err == nil {
+			slog.Info("config: converting Python AmpliPi config", "path", s.path)
+			for _, n := range notes {
+				slog.Warn("config: " + n)
+			}
+			Migrate(state)
+			return state, nil
+		}
+	}
+
 	var state models.State
 	if err := json.Unmarshal(data, &state); err != nil {
 		slog.Warn("config: corrupt JSON config, using defaults", "path", s.path, "err", err)
@@ -53,10 +65,34 @@ func (s *JSONStore) Load() (*models.State, error) {
 		return &def, nil
 	}
 
-	migrateState(&state)
+	if err := s.loadSecrets(&state); err != nil {
+		slog.Warn("config: cannot read secrets", "path", s.secretsPath(), "err", err)
+	}
+	Migrate(&state)
 	return &state, nil
 }
 
+func (s *JSONStore) secretsPath() string {
+	return filepath.Join(filepath.Dir(s.path), secretsFileName)
+}
+
+// loadSecrets restores the write-only values left out of house.json.
+func (s *JSONStore) loadSecrets(state *models.State) error {
+	data, err := os.ReadFile(s.secretsPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var sec models.Secrets
+	if err := json.Unmarshal(data, &sec); err != nil {
+		return err
+	}
+	state.SetSecrets(sec)
+	return nil
+}
+
 // Save schedules a debounced write of the state to disk.
 // The actual write happens after 500ms of no further Save calls.
 func (s *JSONStore) Save(state *models.State) error {
@@ -109,6 +145,11 @@ func (s *JSONStore) writeAtomic(state *models.State) error {
 		return err
 	}
 
+	// Secrets first, so house.json never refers to secrets not yet saved.
+	if err := s.writeSecrets(state.Secrets()); err != nil {
+		return err
+	}
+
 	// Write to temp file, then rename (atomic on Linux)
 	tmpPath := s.path + ".tmp"
 	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
@@ -116,3 +157,22 @@ func (s *JSONStore) writeAtomic(state *models.State) error {
 	}
 	return os.Rename(tmpPath, s.path)
 }
+
+// writeSecrets saves the write-only values, readable only by the daemon.
+func (s *JSONStore) writeSecrets(sec models.Secrets) error {
+	path := s.secretsPath()
+	data, err := json.MarshalIndent(sec, "", "  ")
+	if err != nil {
+		return err
+	}
+	if old, err := os.ReadFile(path); err == nil && string(old) == string(data) {
+		return nil
+	} else if errors.Is(err, os.ErrNotExist) && sec.Webhooks == nil && sec.MatterPasscode == 0 {
+		return nil
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -4,12 +4,18 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/micro-nova/amplipi-go/internal/hardware"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
-// migrateState fills in default values for fields that may be missing
-// in older config files or Python-format configs.
-func migrateState(state *models.State) {
+// Migrate fills in default values for fields that may be missing
+// in older config files or Python-format configs, and fixes invalid IDs.
+// It returns a description of each change.
+func Migrate(state *models.State) []string {
+	var changes []string
+	changed := func(format string, args ...interface{}) {
+		changes = append(changes, fmt.Sprintf(format, args...))
+	}
 	def := models.DefaultState()
 
 	// Ensure sources slice has at least 4 entries
@@ -17,6 +23,7 @@ func migrateState(state *models.State) {
 		idx := len(state.Sources)
 		if idx < len(def.Sources) {
 			state.Sources = append(state.Sources, def.Sources[idx])
+			changed("added missing source %d", idx)
 		} else {
 			break
 		}
@@ -26,6 +33,7 @@ func migrateState(state *models.State) {
 	for i := range state.Sources {
 		if state.Sources[i].ID < 0 || state.Sources[i].ID > 3 {
 			slog.Warn("config: invalid source ID, fixing", "id", state.Sources[i].ID, "index", i)
+			changed("sources[%d]: invalid ID %d changed to %d", i, state.Sources[i].ID, i)
 			state.Sources[i].ID = i
 		}
 	}
@@ -33,8 +41,9 @@ func migrateState(state *models.State) {
 	// Validate and fix zone fields
 	for i := range state.Zones {
 		z := &state.Zones[i]
-		if z.ID < 0 || z.ID > models.MaxZones-1 {
+		if z.ID < 0 || z.ID >= hardware.MaxUnits*hardware.ZonesPerUnit {
 			slog.Warn("config: invalid zone ID, fixing", "id", z.ID, "index", i)
+			changed("zones[%d]: invalid ID %d changed to %d", i, z.ID, i)
 			z.ID = i
 		}
 		// Apply defaults for unset volume limits
@@ -44,14 +53,16 @@ func migrateState(state *models.State) {
 		}
 		// Clamp vol to configured limits
 		if z.Vol < z.VolMin {
+			changed("zones[%d]: vol %d raised to vol_min %d", i, z.Vol, z.VolMin)
 			z.Vol = z.VolMin
 		}
 		if z.Vol > z.VolMax {
+			changed("zones[%d]: vol %d lowered to vol_max %d", i, z.Vol, z.VolMax)
 			z.Vol = z.VolMax
 		}
 		// Sync vol_f from vol if not set
 		if z.VolF == 0 && z.Vol != z.VolMin {
-			z.VolF = models.DBToVolF(z.Vol)
+			z.VolF = z.DBToVolF(z.Vol)
 		}
 	}
 
@@ -60,6 +71,7 @@ func migrateState(state *models.State) {
 		g := &state.Groups[i]
 		if g.ID < 0 {
 			slog.Warn("config: invalid group ID, fixing", "id", g.ID, "index", i)
+			changed("groups[%d]: invalid ID %d changed to %d", i, g.ID, 100+i)
 			g.ID = 100 + i
 		}
 		if g.ZoneIDs == nil {
@@ -71,17 +83,19 @@ func migrateState(state *models.State) {
 	for i := range state.Streams {
 		if state.Streams[i].ID < 0 {
 			slog.Warn("config: invalid stream ID, fixing", "id", state.Streams[i].ID, "index", i)
+			changed("streams[%d]: invalid ID %d changed to %d", i, state.Streams[i].ID, 1000+i)
 			state.Streams[i].ID = 1000 + i
 		}
 	}
 
 	// Ensure default RCA and Aux streams exist (needed for physical RCA inputs)
-	ensureDefaultStreams(state)
+	ensureDefaultStreams(state, changed)
 
 	// Validate preset IDs
 	for i := range state.Presets {
 		if state.Presets[i].ID < 0 {
 			slog.Warn("config: invalid preset ID, fixing", "id", state.Presets[i].ID, "index", i)
+			changed("presets[%d]: invalid ID %d changed to %d", i, state.Presets[i].ID, i)
 			state.Presets[i].ID = i
 		}
 	}
@@ -96,11 +110,12 @@ func migrateState(state *models.State) {
 	if state.Presets == nil {
 		state.Presets = []models.Preset{}
 	}
+	return changes
 }
 
 // ensureDefaultStreams adds missing default RCA and Aux streams to the state.
 // These streams represent physical hardware inputs and should always be present.
-func ensureDefaultStreams(state *models.State) {
+func ensureDefaultStreams(state *models.State, changed func(format string, args ...interface{})) {
 	// Check which default streams are missing and ensure existing RCA streams have index field
 	hasAux := false
 	rcaPresent := make(map[int]bool)
@@ -122,6 +137,7 @@ func ensureDefaultStreams(state *models.State) {
 				if index >= 0 && index <= 3 {
 					s.Config["index"] = index
 					slog.Info("config: adding missing index to RCA stream", "id", s.ID, "index", index)
+					changed("stream %d: added missing RCA index %d", s.ID, index)
 				}
 			}
 		}
@@ -132,6 +148,7 @@ func ensureDefaultStreams(state *models.State) {
 
 	if !hasAux {
 		slog.Info("config: adding missing Aux stream")
+		changed("added missing Aux stream")
 		state.Streams = append(state.Streams, models.Stream{
 			ID:        models.AuxStreamID,
 			Name:      "Aux",
@@ -147,6 +164,7 @@ func ensureDefaultStreams(state *models.State) {
 		if !rcaPresent[rcaID] {
 			name := fmt.Sprintf("Input %d", i+1)
 			slog.Info("config: adding missing RCA stream", "id", rcaID, "name", name)
+			changed("added missing RCA stream %q", name)
 			state.Streams = append(state.Streams, models.Stream{
 				ID:        rcaID,
 				Name:      name,
@@ -0,0 +1,32 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// ProvisionFileName is the installer's first-boot template in the config
+// directory.
+const ProvisionFileName = "provision.json"
+
+// LoadProvision reads provision.json from configDir. A missing file yields
+// nil; a malformed file is an error.
+func LoadProvision(configDir string) (*models.Provision, error) {
+	data, err := os.ReadFile(filepath.Join(configDir, ProvisionFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var p models.Provision
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("config: parse %s: %w", ProvisionFileName, err)
+	}
+	return &p, nil
+}
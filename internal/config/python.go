@@ -0,0 +1,274 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// pythonStreamTypes maps stream type names only the Python AmpliPi used to
+// their Go equivalents.
+var pythonStreamTypes = map[string]string{
+	"shairport": models.StreamTypeAirPlay,
+}
+
+// pythonStreamKeys maps the Python names of stream settings that were
+// renamed, by stream type.
+var pythonStreamKeys = map[string]map[string]string{
+	models.StreamTypeFileplayer: {"url": "path"},
+}
+
+// streamFields are the fields of a stream that are not settings; the
+// Python AmpliPi keeps the settings next to them rather than in "config".
+var streamFields = map[string]bool{
+	"id": true, "name": true, "type": true, "disabled": true, "browsable": true,
+	"info": true, "active": true, "config": true,
+}
+
+// pythonPreset is a preset as the Python AmpliPi stores it.
+type pythonPreset struct {
+	ID       int                 `json:"id"`
+	Name     string              `json:"name"`
+	State    *models.PresetState `json:"state,omitempty"`
+	Commands []pythonCommand     `json:"commands,omitempty"`
+}
+
+// pythonCommand is a stream command run when a Python preset is loaded.
+type pythonCommand struct {
+	StreamID int    `json:"stream_id"`
+	Cmd      string `json:"cmd"`
+}
+
+// IsPythonConfig reports whether data looks like a house.json written by
+// the Python AmpliPi: its streams keep their settings in flat fields
+// rather than a "config" object or use a type name only it has, or its
+// presets run stream commands by stream_id.
+func IsPythonConfig(data []byte) bool {
+	var raw struct {
+		Streams []map[string]json.RawMessage `json:"streams"`
+		Presets []struct {
+			Commands []map[string]json.RawMessage `json:"commands"`
+		} `json:"presets"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return false
+	}
+	for _, st := range raw.Streams {
+		if _, ok := st["config"]; ok {
+			continue
+		}
+		var typ string
+		_ = json.Unmarshal(st["type"], &typ)
+		if _, ok := pythonStreamTypes[typ]; ok {
+			return true
+		}
+		for k := range st {
+			if !streamFields[k] {
+				return true
+			}
+		}
+	}
+	for _, p := range raw.Presets {
+		for _, cmd := range p.Commands {
+			if _, ok := cmd["stream_id"]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ImportPython converts a house.json written by the Python AmpliPi: stream
+// settings move into "config" under their Go names, group volume, mute and
+// source changes in presets become changes to the group's zones, as the
+// Python version applied them, and preset stream commands become API
+// calls. It returns a note on everything it changed or could not
+// translate. The result still needs Migrate.
+func ImportPython(data []byte) (*models.State, []string, error) {
+	var raw struct {
+		Sources []models.Source          `json:"sources"`
+		Zones   []models.Zone            `json:"zones"`
+		Groups  []models.Group           `json:"groups"`
+		Streams []map[string]interface{} `json:"streams"`
+		Presets []pythonPreset           `json:"presets"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, err
+	}
+
+	var notes []string
+	note := func(format string, args ...interface{}) {
+		notes = append(notes, fmt.Sprintf(format, args...))
+	}
+
+	state := &models.State{Sources: raw.Sources, Zones: raw.Zones, Groups: raw.Groups}
+	dropped := make(map[int]bool)
+	for _, r := range raw.Streams {
+		st, ok := pythonStream(r, note)
+		if !ok {
+			dropped[st.ID] = true
+			continue
+		}
+		state.Streams = append(state.Streams, st)
+	}
+
+	for i := range state.Sources {
+		src := &state.Sources[i]
+		if id, ok := streamInput(src.Input); ok && dropped[id] {
+			note("source %d: cleared input %q, whose stream was dropped", src.ID, src.Input)
+			src.Input = ""
+		}
+	}
+
+	for _, pp := range raw.Presets {
+		p := models.Preset{ID: pp.ID, Name: pp.Name, State: pp.State}
+		if p.State != nil {
+			pythonPresetGroups(&p, state.Groups, note)
+			for i, su := range p.State.Sources {
+				if su.Input == nil {
+					continue
+				}
+				if id, ok := streamInput(*su.Input); ok && dropped[id] {
+					note("preset %d %q: cleared input %q, whose stream was dropped", p.ID, p.Name, *su.Input)
+					empty := ""
+					p.State.Sources[i].Input = &empty
+				}
+			}
+		}
+		for _, cmd := range pp.Commands {
+			if dropped[cmd.StreamID] {
+				note("preset %d %q: dropped command %q for stream %d, which was dropped", p.ID, p.Name, cmd.Cmd, cmd.StreamID)
+				continue
+			}
+			p.Commands = append(p.Commands, models.Command{
+				Endpoint: fmt.Sprintf("/api/streams/%d/%s", cmd.StreamID, cmd.Cmd),
+				Method:   "POST",
+			})
+		}
+		state.Presets = append(state.Presets, p)
+	}
+	return state, notes, nil
+}
+
+// pythonStream converts a Python stream. It returns false if the stream's
+// type is not supported.
+func pythonStream(r map[string]interface{}, note func(string, ...interface{})) (models.Stream, bool) {
+	id, _ := r["id"].(float64)
+	name, _ := r["name"].(string)
+	typ, _ := r["type"].(string)
+	st := models.Stream{ID: int(id), Name: name, Type: typ}
+	if to, ok := pythonStreamTypes[typ]; ok {
+		st.Type = to
+		note("stream %d %q: type %q renamed to %q", st.ID, name, typ, to)
+	}
+	schema := models.FindStreamSchema(st.Type)
+	if schema == nil {
+		note("stream %d %q: type %q is not supported; dropped", st.ID, name, typ)
+		return st, false
+	}
+	if v, ok := r["disabled"].(bool); ok {
+		st.Disabled = &v
+	}
+	if v, ok := r["browsable"].(bool); ok {
+		st.Browsable = &v
+	}
+
+	keys := make([]string, 0, len(r))
+	for k := range r {
+		if !streamFields[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	cfg := make(map[string]interface{})
+	for _, k := range keys {
+		key := k
+		if to, ok := pythonStreamKeys[schema.Type][k]; ok {
+			key = to
+		}
+		if schema.Field(key) == nil {
+			note("stream %d %q: dropped setting %q, which is not supported", st.ID, name, k)
+			continue
+		}
+		cfg[key] = r[k]
+	}
+	if len(cfg) > 0 {
+		st.Config = cfg
+	}
+	if err := schema.Validate(cfg); err != nil {
+		note("stream %d %q: %s", st.ID, name, err)
+	}
+	return st, true
+}
+
+// pythonPresetGroups rewrites a preset's group volume, mute and source
+// changes, which the Python AmpliPi applied to each zone of the group, as
+// changes to those zones. Changes the preset makes to a zone itself win.
+func pythonPresetGroups(p *models.Preset, groups []models.Group, note func(string, ...interface{})) {
+	ps := p.State
+	for i, gu := range ps.Groups {
+		if gu.ID == nil || (gu.Vol == nil && gu.VolF == nil && gu.Mute == nil && gu.SourceID == nil) {
+			continue
+		}
+		zones := gu.ZoneIDs
+		if zones == nil {
+			for _, g := range groups {
+				if g.ID == *gu.ID {
+					zones = g.ZoneIDs
+				}
+			}
+		}
+		if zones == nil {
+			note("preset %d %q: group %d not found; dropped its changes", p.ID, p.Name, *gu.ID)
+			continue
+		}
+		for _, zid := range zones {
+			zu := presetZone(ps, zid)
+			if gu.SourceID != nil && zu.SourceID == nil {
+				v := *gu.SourceID
+				zu.SourceID = &v
+			}
+			if gu.Mute != nil && zu.Mute == nil {
+				v := *gu.Mute
+				zu.Mute = &v
+			}
+			if zu.Vol == nil && zu.VolF == nil && zu.VolDelta == nil {
+				if gu.VolF != nil {
+					v := *gu.VolF
+					zu.VolF = &v
+				} else if gu.Vol != nil {
+					v := *gu.Vol
+					zu.VolDelta = &v
+				}
+			}
+		}
+		ps.Groups[i].Vol, ps.Groups[i].VolF = nil, nil
+	}
+}
+
+// presetZone returns the preset's update for zone id, adding one if it has
+// none.
+func presetZone(ps *models.PresetState, id int) *models.ZoneUpdate {
+	for i := range ps.Zones {
+		if zu := &ps.Zones[i]; zu.ID != nil && *zu.ID == id {
+			return zu
+		}
+	}
+	zid := id
+	ps.Zones = append(ps.Zones, models.ZoneUpdate{ID: &zid})
+	return &ps.Zones[len(ps.Zones)-1]
+}
+
+// streamInput returns the stream ID of a "stream=<id>" source input.
+func streamInput(input string) (int, bool) {
+	s, ok := strings.CutPrefix(input, "stream=")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(s)
+	return id, err == nil
+}
@@ -0,0 +1,68 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
+)
+
+// airplayNetworkNames lists the names of the AirPlay receivers on the LAN.
+// Replaced in tests.
+var airplayNetworkNames = func(ctx context.Context) ([]string, error) {
+	return streams.AirPlayNamesOnNetwork(ctx, 2*time.Second)
+}
+
+// airplayField is the request field an AirPlay stream's advertised name
+// comes from.
+func airplayField(st *models.Stream) string {
+	if st.ConfigString("device_name") != "" {
+		return "device_name"
+	}
+	return "name"
+}
+
+// checkAirPlayName rejects the name st would be advertised under if
+// another AirPlay stream of this unit or another receiver on the network
+// already uses it, as senders could not tell them apart. id is st's ID, or
+// 0 for a new stream. The network is only browsed for a changed name on a
+// unit running streams.
+func (c *Controller) checkAirPlayName(ctx context.Context, id int, st *models.Stream) *models.AppError {
+	name := strings.TrimSpace(streams.AirPlayDeviceName(*st))
+	c.mu.RLock()
+	var current string
+	for _, other := range c.state.Streams {
+		if schema := models.FindStreamSchema(other.Type); schema == nil || schema.Type != models.StreamTypeAirPlay {
+			continue
+		}
+		otherName := streams.AirPlayDeviceName(other)
+		if other.ID == id {
+			current = otherName
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(otherName), name) {
+			c.mu.RUnlock()
+			return models.ErrConflict(fmt.Sprintf("AirPlay stream %q is already advertised as %q", other.Name, otherName)).WithField(airplayField(st))
+		}
+	}
+	c.mu.RUnlock()
+	if strings.EqualFold(strings.TrimSpace(current), name) || c.streams == nil {
+		return nil
+	}
+
+	found, err := airplayNetworkNames(ctx)
+	if err != nil {
+		slog.Warn("airplay: cannot check the network for duplicate names", "err", err)
+		return nil
+	}
+	for _, n := range found {
+		if strings.EqualFold(strings.TrimSpace(n), name) {
+			return models.ErrConflict(fmt.Sprintf("an AirPlay receiver named %q is already on the network", n)).WithField(airplayField(st))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// RunAmpPower re-evaluates zone amplifier enables every interval so that
+// idle timeouts and off hours take effect without an API call. Blocks
+// until ctx is cancelled.
+func (c *Controller) RunAmpPower(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.mu.Lock()
+			c.refreshAmps()
+			c.mu.Unlock()
+		}
+	}
+}
+
+// refreshAmps queues amp enable writes for every unit whose desired enables
+// have changed since the last write. Must be called with c.mu held.
+func (c *Controller) refreshAmps() {
+	if c.ampsOff {
+		return
+	}
+	now := c.localNow()
+	c.updateUnitPower(now)
+	for _, unit := range c.hw.Units() {
+		enables := c.ampEnablesFor(&c.state, unit, now)
+		prev, ok := c.ampEnables[unit]
+		if ok && prev == enables {
+			continue
+		}
+		// Zones whose amp comes on while unmuted ramp up from silence, and
+		// down before it goes off.
+		soft := softStart(&c.state)
+		for i := range enables {
+			z := findZone(&c.state, unit*6+i)
+			switch {
+			case z == nil || z.Mute || enables[i] == prev[i]:
+			case enables[i]:
+				soft.in = append(soft.in, i)
+			case ok:
+				soft.out = append(soft.out, i)
+			}
+		}
+		c.queueAmpEnables(unit, enables, soft)
+		c.ampEnables[unit] = enables
+	}
+}
+
+// Shutdown turns every amp off, ramping audible zones down first with soft
+// start, and waits until the hardware has been written, so speakers do not
+// pop when the preamp loses power. Amps stay off afterwards. It also waits
+// for Cast devices being loaded or stopped.
+func (c *Controller) Shutdown(ctx context.Context) error {
+	c.mu.Lock()
+	c.ampsOff = true
+	for _, unit := range c.hw.Units() {
+		soft := softStart(&c.state)
+		enabled := c.ampEnables[unit]
+		for i := range enabled {
+			if z := findZone(&c.state, unit*6+i); enabled[i] && z != nil && !z.Mute {
+				soft.out = append(soft.out, i)
+			}
+		}
+		c.queueAmpEnables(unit, [6]bool{}, soft)
+		c.ampEnables[unit] = [6]bool{}
+	}
+	c.mu.Unlock()
+	if err := c.FlushHardware(ctx); err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		c.castSyncs.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// resumeAmps undoes Shutdown, e.g. after a reboot that failed: the amps
+// come back on as the state wants them.
+func (c *Controller) resumeAmps() {
+	c.mu.Lock()
+	c.ampsOff = false
+	c.refreshAmps()
+	c.mu.Unlock()
+}
+
+// softStart returns a crossfade of the configured soft start duration,
+// without zones.
+func softStart(s *models.State) crossfade {
+	return crossfade{duration: time.Duration(s.Settings.SoftStartMS) * time.Millisecond}
+}
+
+// ampEnablesFor returns the desired amp enables for the zones on unit and
+// records when each zone was last in use.
+func (c *Controller) ampEnablesFor(s *models.State, unit int, now time.Time) [6]bool {
+	var enables [6]bool
+	if c.unitDown[unit] {
+		return enables
+	}
+	for i := 0; i < 6; i++ {
+		z := findZone(s, unit*6+i)
+		if z == nil || z.Disabled {
+			continue
+		}
+		if p := bridgePrimary(s, z); p != nil {
+			z = p // both channels of a bridged output switch together
+		}
+		if zoneInUse(s, z) {
+			c.ampLastUsed[z.ID] = now
+			enables[i] = true
+			continue
+		}
+		if z.Amp == nil {
+			enables[i] = true
+			continue
+		}
+		switch {
+		case z.Amp.InOffHours(now):
+			// off while idle
+		case z.Amp.Mode == models.AmpAuto:
+			last, ok := c.ampLastUsed[z.ID]
+			enables[i] = ok && now.Sub(last) < z.Amp.Idle()
+		default:
+			enables[i] = true
+		}
+	}
+	return enables
+}
+
+// zoneInUse reports whether a zone is unmuted and fed by a source with an
+// input selected.
+func zoneInUse(s *models.State, z *models.Zone) bool {
+	if z.Mute {
+		return false
+	}
+	for _, src := range s.Sources {
+		if src.ID == z.SourceID {
+			return src.Input != ""
+		}
+	}
+	return false
+}
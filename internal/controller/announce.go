@@ -3,9 +3,11 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
 )
 
 const (
@@ -20,11 +22,19 @@ const (
 )
 
 // Announce creates a PA-style announcement that:
-// 1. Saves current state
-// 2. Creates a temporary fileplayer stream with the media URL
-// 3. Creates a temporary preset connecting target zones to the announcement
-// 4. Waits for the announcement to finish playing (blocking)
-// 5. Cleans up temporary resources and restores previous state
+// 1. Saves current state and starts network outputs (Cast, Snapcast, AirPlay)
+// 2. In duck mode, mixes the announcement into target zones' music
+// 3. Creates a temporary fileplayer stream with the media URL
+// 4. Creates a temporary preset connecting the other target zones to it
+// 5. Waits for the announcement to finish playing (blocking)
+// 6. Cleans up temporary resources and restores previous state
+//
+// Network outputs start ahead of the wired zones by their latency so the
+// announcement is heard everywhere at once.
+//
+// Media "clip:<name>" plays a clip from the clip library. The media is
+// checked first, so an unreachable URL or a file that isn't audio fails
+// with 400 and a reason before any zone changes.
 //
 // This operation blocks until the announcement completes or times out.
 func (c *Controller) Announce(ctx context.Context, req models.AnnounceRequest) (models.State, *models.AppError) {
@@ -32,14 +42,19 @@ func (c *Controller) Announce(ctx context.Context, req models.AnnounceRequest) (
 	if req.Media == "" {
 		return models.State{}, models.ErrBadRequest("media URL is required")
 	}
+	media, appErr := c.resolveMedia(req.Media)
+	if appErr != nil {
+		return models.State{}, appErr
+	}
+	req.Media = media
 
 	// Set defaults
 	sourceID := 3 // default to source 3
 	if req.SourceID != nil {
 		sourceID = *req.SourceID
 	}
-	if sourceID < 0 || sourceID >= models.MaxSources {
-		return models.State{}, models.ErrBadRequest(fmt.Sprintf("source_id must be 0-%d", models.MaxSources-1))
+	if limit := c.sourceLimit(); sourceID < 0 || sourceID >= limit {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("source_id must be 0-%d", limit-1))
 	}
 
 	volF := 0.5 // default to 50% relative volume
@@ -50,25 +65,79 @@ func (c *Controller) Announce(ctx context.Context, req models.AnnounceRequest) (
 		}
 	}
 
+	duckGain, err := announceDuckGain(req)
+	if err != nil {
+		return models.State{}, err
+	}
+
+	plan, err := c.planAnnounceOutputs(ctx, req)
+	if err != nil {
+		return models.State{}, err
+	}
+
+	// Check the media before touching any zone, rather than leaving the
+	// players to fail on it once the zones have been taken over.
+	if err := streams.ProbeMedia(ctx, req.Media); err != nil {
+		return models.State{}, models.ErrBadRequest("media can't be played: " + err.Error()).WithField("media")
+	}
+
 	// Step 1: Save current state to a restore preset
 	saveState, err := c.saveCurrentState(ctx)
 	if err != nil {
 		return models.State{}, err
 	}
 
-	// Step 2: Create temporary fileplayer stream
-	streamID, err := c.createAnnouncementStream(ctx, req.Media)
+	// Network outputs buffer for seconds before they are heard, so they
+	// start first and the wired zones follow once the slowest has caught up.
+	// They play out and are restored in the background, after the request
+	// has returned, so they get a context of their own.
+	if len(plan.outputs) > 0 {
+		netCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ANNOUNCE_MAX_DURATION)
+		netDone := c.startAnnounceOutputs(netCtx, plan, req.Media, volF)
+		defer func() { go c.finishAnnounceOutputs(netCtx, cancel, plan, netDone) }()
+		if !sleepCtx(ctx, plan.zoneDelay()) {
+			cancel()
+			_, _ = c.restoreStateAndCleanup(ctx, saveState, 0)
+			return models.State{}, models.ErrInternal("announcement cancelled")
+		}
+	}
+
+	// Step 2: Determine target zones
+	targetZones, err := c.determineTargetZones(req.Zones, req.Groups)
 	if err != nil {
-		// Try to restore state before returning error
 		_, _ = c.restoreStateAndCleanup(ctx, saveState, 0)
 		return models.State{}, err
 	}
 
-	// Step 3: Determine target zones
-	targetZones, err := c.determineTargetZones(req.Zones, req.Groups)
+	// In duck mode, zones playing music keep it, turned down, and hear the
+	// announcement mixed in; the rest are taken over below.
+	if req.Mode == models.AnnounceDuck {
+		var ducked []int
+		ducked, targetZones = c.splitDuckTargets(targetZones)
+		if len(ducked) > 0 {
+			mixDone := c.mixAnnouncement(ctx, ducked, req.Media, duckGain)
+			defer func() { <-mixDone }()
+			if len(targetZones) > 0 && slices.Contains(ducked, sourceID) {
+				// The announcement source is ducked itself: the remaining
+				// zones join it and hear the mix.
+				if _, err := c.createAndLoadAnnouncementPreset(ctx, sourceID, 0, targetZones, req.Vol, volF); err != nil {
+					_, _ = c.restoreStateAndCleanup(ctx, saveState, 0)
+					return models.State{}, err
+				}
+				targetZones = nil
+			}
+			if len(targetZones) == 0 {
+				<-mixDone
+				return c.restoreStateAndCleanup(ctx, saveState, 0)
+			}
+		}
+	}
+
+	// Step 3: Create temporary fileplayer stream
+	streamID, err := c.createAnnouncementStream(ctx, req.Media)
 	if err != nil {
-		// Cleanup stream and restore state
-		_, _ = c.restoreStateAndCleanup(ctx, saveState, streamID)
+		// Try to restore state before returning error
+		_, _ = c.restoreStateAndCleanup(ctx, saveState, 0)
 		return models.State{}, err
 	}
 
@@ -99,12 +168,43 @@ func (c *Controller) Announce(ctx context.Context, req models.AnnounceRequest) (
 // saveCurrentState captures the current system state in a preset for later restoration
 func (c *Controller) saveCurrentState(ctx context.Context) (models.State, *models.AppError) {
 	c.mu.RLock()
-	currentState := c.state.DeepCopy()
+	presetState := captureState(&c.state)
 	c.mu.RUnlock()
 
-	// Build a preset that captures current source, zone, and group state
+	// Create or update the restore preset
+	state, err := c.apply(func(s *models.State) error {
+		// Check if restore preset already exists
+		existing := findPreset(s, ANNOUNCE_RESTORE_PRESET_ID)
+		if existing != nil {
+			// Update it
+			existing.Name = "PA - Saved State"
+			existing.State = &presetState
+		} else {
+			// Create it
+			preset := models.Preset{
+				ID:    ANNOUNCE_RESTORE_PRESET_ID,
+				Name:  "PA - Saved State",
+				State: &presetState,
+			}
+			s.Presets = append(s.Presets, preset)
+		}
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, appErr
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+
+	return state, nil
+}
+
+// captureState returns a preset state that puts every source, zone and
+// group back as they are in s. The updates share nothing with s.
+func captureState(s *models.State) models.PresetState {
 	var sourceUpdates []models.SourceUpdate
-	for _, src := range currentState.Sources {
+	for _, src := range s.Sources {
 		id := src.ID
 		name := src.Name
 		input := src.Input
@@ -116,7 +216,7 @@ func (c *Controller) saveCurrentState(ctx context.Context) (models.State, *model
 	}
 
 	var zoneUpdates []models.ZoneUpdate
-	for _, z := range currentState.Zones {
+	for _, z := range s.Zones {
 		id := z.ID
 		name := z.Name
 		sourceID := z.SourceID
@@ -140,7 +240,7 @@ func (c *Controller) saveCurrentState(ctx context.Context) (models.State, *model
 	}
 
 	var groupUpdates []models.GroupUpdate
-	for _, g := range currentState.Groups {
+	for _, g := range s.Groups {
 		id := g.ID
 		name := g.Name
 		zones := make([]int, len(g.ZoneIDs))
@@ -156,39 +256,11 @@ func (c *Controller) saveCurrentState(ctx context.Context) (models.State, *model
 		})
 	}
 
-	presetState := models.PresetState{
+	return models.PresetState{
 		Sources: sourceUpdates,
 		Zones:   zoneUpdates,
 		Groups:  groupUpdates,
 	}
-
-	// Create or update the restore preset
-	state, err := c.apply(func(s *models.State) error {
-		// Check if restore preset already exists
-		existing := findPreset(s, ANNOUNCE_RESTORE_PRESET_ID)
-		if existing != nil {
-			// Update it
-			existing.Name = "PA - Saved State"
-			existing.State = &presetState
-		} else {
-			// Create it
-			preset := models.Preset{
-				ID:    ANNOUNCE_RESTORE_PRESET_ID,
-				Name:  "PA - Saved State",
-				State: &presetState,
-			}
-			s.Presets = append(s.Presets, preset)
-		}
-		return nil
-	})
-	if err != nil {
-		if appErr, ok := err.(*models.AppError); ok {
-			return models.State{}, appErr
-		}
-		return models.State{}, models.ErrInternal(err.Error())
-	}
-
-	return state, nil
 }
 
 // createAnnouncementStream creates a temporary fileplayer stream for the announcement
@@ -255,7 +327,7 @@ func (c *Controller) determineTargetZones(zoneIDs, groupIDs []int) ([]int, *mode
 		for _, gid := range groupIDs {
 			g := findGroup(&c.state, gid)
 			if g != nil {
-				for _, zid := range g.ZoneIDs {
+				for _, zid := range groupZones(&c.state, g) {
 					z := findZone(&c.state, zid)
 					if z != nil && !z.Disabled {
 						targetZones[zid] = true
@@ -272,14 +344,14 @@ func (c *Controller) determineTargetZones(zoneIDs, groupIDs []int) ([]int, *mode
 	}
 
 	if len(result) == 0 {
-		return nil, models.ErrBadRequest("no enabled zones found for announcement")
+		return nil, models.ErrBadRequest("no enabled zones selected")
 	}
 
 	return result, nil
 }
 
 // createAndLoadAnnouncementPreset creates a preset that configures the announcement
-// and immediately loads it
+// and immediately loads it. A streamID of 0 leaves the source's input as it is.
 func (c *Controller) createAndLoadAnnouncementPreset(
 	ctx context.Context,
 	sourceID, streamID int,
@@ -288,12 +360,15 @@ func (c *Controller) createAndLoadAnnouncementPreset(
 	volF float64,
 ) (models.State, *models.AppError) {
 	// Build the announcement preset
-	sourceInput := fmt.Sprintf("stream=%d", streamID)
-	srcID := sourceID
-	srcInput := sourceInput
-	sourceUpdate := models.SourceUpdate{
-		ID:    &srcID,
-		Input: &srcInput,
+	var sourceUpdates []models.SourceUpdate
+	if streamID != 0 {
+		sourceInput := fmt.Sprintf("stream=%d", streamID)
+		srcID := sourceID
+		srcInput := sourceInput
+		sourceUpdates = append(sourceUpdates, models.SourceUpdate{
+			ID:    &srcID,
+			Input: &srcInput,
+		})
 	}
 
 	// Build zone updates for target zones
@@ -321,11 +396,12 @@ func (c *Controller) createAndLoadAnnouncementPreset(
 		zoneUpdates = append(zoneUpdates, update)
 	}
 
-	// Get all zones affected by changing this source
+	// Get all zones affected by changing this source. Without a stream the
+	// source keeps playing and nothing else changes.
 	c.mu.RLock()
 	affectedZones := make(map[int]bool)
 	for _, z := range c.state.Zones {
-		if z.SourceID == sourceID {
+		if z.SourceID == sourceID && streamID != 0 {
 			affectedZones[z.ID] = true
 		}
 	}
@@ -353,7 +429,7 @@ func (c *Controller) createAndLoadAnnouncementPreset(
 	}
 
 	presetState := models.PresetState{
-		Sources: []models.SourceUpdate{sourceUpdate},
+		Sources: sourceUpdates,
 		Zones:   zoneUpdates,
 	}
 
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// maxDuckDB bounds how far duck mode turns music down: as far as the duck
+// stage of an output goes, beyond which the music is as good as muted.
+const maxDuckDB = audio.DuckRangeDB
+
+// announceDuckGain validates an announcement's mode and returns the gain
+// (0-1) duck mode plays the music at.
+func announceDuckGain(req models.AnnounceRequest) (float64, *models.AppError) {
+	switch req.Mode {
+	case "", models.AnnounceTakeover, models.AnnounceDuck:
+	default:
+		return 0, models.ErrBadRequest(`mode must be "takeover" or "duck"`)
+	}
+	duckDB := models.DefaultDuckDB
+	if req.DuckDB != nil {
+		duckDB = *req.DuckDB
+		if duckDB < 0 || duckDB > maxDuckDB {
+			return 0, models.ErrBadRequest(fmt.Sprintf("duck_db must be 0-%d", maxDuckDB))
+		}
+	}
+	return math.Pow(10, -float64(duckDB)/20), nil
+}
+
+// splitDuckTargets returns the sources of the target zones that can be
+// ducked, and the target zones that must be taken over instead. A zone can
+// be ducked if it is unmuted on a source a stream is playing on.
+func (c *Controller) splitDuckTargets(targets []int) (sources, rest []int) {
+	if c.streams == nil {
+		return nil, targets
+	}
+	c.mu.RLock()
+	zoneSource := make(map[int]int)
+	for _, zid := range targets {
+		if z := findZone(&c.state, zid); z != nil && !z.Mute && z.SourceID >= 0 {
+			zoneSource[zid] = z.SourceID
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, zid := range targets {
+		sid, ok := zoneSource[zid]
+		if ok {
+			_, ok = c.streams.SourceCaptureDevice(sid)
+		}
+		if !ok {
+			rest = append(rest, zid)
+			continue
+		}
+		if !slices.Contains(sources, sid) {
+			sources = append(sources, sid)
+		}
+	}
+	return sources, rest
+}
+
+// mixAnnouncement turns the music on each source down to duckGain, mixes
+// media into it and turns it back up once the announcement has played.
+// The returned channel is closed when every source is back to full level.
+func (c *Controller) mixAnnouncement(ctx context.Context, sources []int, media string, duckGain float64) <-chan struct{} {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		var wg sync.WaitGroup
+		for _, sid := range sources {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				c.streams.DuckSource(ctx, sid, duckGain)
+				if err := c.streams.MixAnnounce(ctx, sid, media, 1); err != nil {
+					slog.Warn("announce: mixed playback failed", "source", sid, "err", err)
+				}
+				// Back to full level even if the request was cancelled.
+				c.streams.DuckSource(context.Background(), sid, 1)
+			}()
+		}
+		wg.Wait()
+	}()
+	return done
+}
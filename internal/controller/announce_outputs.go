@@ -0,0 +1,242 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/cast"
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/snapcast"
+	"github.com/micro-nova/amplipi-go/internal/streams"
+)
+
+// maxAnnounceLatencyMS bounds latency overrides; anything longer is a typo.
+const maxAnnounceLatencyMS = 10000
+
+// announceRestoreTimeout bounds putting the network outputs back after an
+// announcement.
+const announceRestoreTimeout = time.Minute
+
+// announceOutput is a network output resolved for one announcement.
+type announceOutput struct {
+	models.AnnounceOutput
+	latency time.Duration
+	device  cast.Device // Type "cast"
+}
+
+// announcePlan schedules an announcement's outputs. Every output is started
+// lead minus its own latency after the plan begins, so all of them — wired
+// zones included — are heard at lead.
+type announcePlan struct {
+	outputs     []announceOutput
+	zoneLatency time.Duration
+	lead        time.Duration
+	snapGroups  map[string]string // Snapcast group ID → stream it played before
+}
+
+// zoneDelay is how long to wait before routing the wired zones.
+func (p *announcePlan) zoneDelay() time.Duration {
+	return p.lead - p.zoneLatency
+}
+
+// planAnnounceOutputs validates the network outputs of an announcement and
+// resolves them to devices.
+func (c *Controller) planAnnounceOutputs(ctx context.Context, req models.AnnounceRequest) (*announcePlan, *models.AppError) {
+	plan := &announcePlan{snapGroups: map[string]string{}}
+	if req.ZoneLatencyMS != nil {
+		if *req.ZoneLatencyMS < 0 || *req.ZoneLatencyMS > maxAnnounceLatencyMS {
+			return nil, models.ErrBadRequest(fmt.Sprintf("zone_latency_ms must be 0-%d", maxAnnounceLatencyMS))
+		}
+		plan.zoneLatency = time.Duration(*req.ZoneLatencyMS) * time.Millisecond
+	}
+
+	requested := make([]models.AnnounceOutput, 0, len(req.Cast)+len(req.Outputs))
+	for _, id := range req.Cast {
+		requested = append(requested, models.AnnounceOutput{Type: "cast", ID: id})
+	}
+	requested = append(requested, req.Outputs...)
+
+	var snapStatus *models.SnapcastStatus
+	for _, o := range requested {
+		if o.ID == "" {
+			return nil, models.ErrBadRequest("announcement output id is required")
+		}
+		if o.LatencyMS != nil && (*o.LatencyMS < 0 || *o.LatencyMS > maxAnnounceLatencyMS) {
+			return nil, models.ErrBadRequest(fmt.Sprintf("latency_ms must be 0-%d", maxAnnounceLatencyMS))
+		}
+		out := announceOutput{AnnounceOutput: o, latency: time.Duration(o.Latency()) * time.Millisecond}
+
+		switch o.Type {
+		case "cast":
+			devices, appErr := c.resolveCastDevices([]string{o.ID})
+			if appErr != nil {
+				return nil, appErr
+			}
+			out.device = devices[0]
+		case "snapcast":
+			if snapStatus == nil {
+				status, appErr := c.GetSnapcast(ctx)
+				if appErr != nil {
+					return nil, appErr
+				}
+				if !hasSnapStream(status, snapcast.AnnounceStreamID) {
+					return nil, models.ErrBadRequest("snapcast announcements need a source with snapcast output enabled")
+				}
+				snapStatus = status
+			}
+			group := snapClientGroup(snapStatus, o.ID)
+			if group == nil {
+				return nil, models.ErrBadRequest(fmt.Sprintf("snapcast client %q not found", o.ID))
+			}
+			plan.snapGroups[group.ID] = group.StreamID
+		case "airplay":
+		default:
+			return nil, models.ErrBadRequest(`announcement output type must be "cast", "snapcast" or "airplay"`)
+		}
+		plan.outputs = append(plan.outputs, out)
+	}
+
+	plan.lead = plan.zoneLatency
+	for _, out := range plan.outputs {
+		plan.lead = max(plan.lead, out.latency)
+	}
+	return plan, nil
+}
+
+// hasSnapStream reports whether snapserver serves the given stream.
+func hasSnapStream(status *models.SnapcastStatus, id string) bool {
+	for _, s := range status.Streams {
+		if s.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// snapClientGroup returns the group a Snapcast client belongs to.
+func snapClientGroup(status *models.SnapcastStatus, clientID string) *models.SnapGroup {
+	for i := range status.Groups {
+		for _, cl := range status.Groups[i].Clients {
+			if cl.ID == clientID {
+				return &status.Groups[i]
+			}
+		}
+	}
+	return nil
+}
+
+// sleepCtx waits for d, returning false if ctx ends first.
+func sleepCtx(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
+
+// startAnnounceOutputs starts each network output at its offset in plan and
+// returns a channel that is closed once all of them have finished playing.
+func (c *Controller) startAnnounceOutputs(ctx context.Context, plan *announcePlan, media string, volF float64) <-chan struct{} {
+	var wg sync.WaitGroup
+	var snapLatency time.Duration
+	for _, out := range plan.outputs {
+		if out.Type == "snapcast" {
+			// All Snapcast clients share one announcement stream; the
+			// server keeps them in sync with each other.
+			snapLatency = max(snapLatency, out.latency)
+			continue
+		}
+		wg.Add(1)
+		go func(out announceOutput) {
+			defer wg.Done()
+			if sleepCtx(ctx, plan.lead-out.latency) {
+				c.playAnnounceOutput(ctx, out, media, volF)
+			}
+		}(out)
+	}
+
+	if len(plan.snapGroups) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if client, appErr := c.snapClient(); appErr == nil {
+				for gid := range plan.snapGroups {
+					if err := client.SetGroupStream(ctx, gid, snapcast.AnnounceStreamID); err != nil {
+						slog.Warn("announce: snapcast group switch failed", "group", gid, "err", err)
+					}
+				}
+			}
+			if !sleepCtx(ctx, plan.lead-snapLatency) || c.streams == nil {
+				return
+			}
+			if err := c.streams.SnapcastAnnounce(ctx, media, volF); err != nil {
+				slog.Warn("announce: snapcast playback failed", "err", err)
+				return
+			}
+			// Let the buffered tail play out before the groups switch back.
+			sleepCtx(ctx, snapLatency)
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// playAnnounceOutput plays media on a single Cast or AirPlay output.
+func (c *Controller) playAnnounceOutput(ctx context.Context, out announceOutput, media string, volF float64) {
+	switch out.Type {
+	case "cast":
+		c.castAnnouncement(ctx, []cast.Device{out.device}, media, volF)
+	case "airplay":
+		if err := streams.AirPlayAnnounce(ctx, out.ID, media, int(volF*100), out.latency); err != nil {
+			slog.Warn("announce: airplay playback failed", "receiver", out.ID, "err", err)
+		}
+	}
+}
+
+// finishAnnounceOutputs waits for the network outputs started with ctx to
+// finish, or ctx to end, then cancels it and returns Snapcast groups and
+// Cast devices to what they were playing.
+func (c *Controller) finishAnnounceOutputs(ctx context.Context, cancel context.CancelFunc, plan *announcePlan, done <-chan struct{}) {
+	select {
+	case <-done:
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			slog.Warn("announce: network outputs did not finish in time")
+		}
+		<-done
+	}
+	cancel()
+
+	ctx, cancel = context.WithTimeout(context.Background(), announceRestoreTimeout)
+	defer cancel()
+	if client, appErr := c.snapClient(); appErr == nil {
+		for gid, stream := range plan.snapGroups {
+			if err := client.SetGroupStream(ctx, gid, stream); err != nil {
+				slog.Warn("announce: snapcast group restore failed", "group", gid, "err", err)
+			}
+		}
+	}
+	var devices []cast.Device
+	for _, out := range plan.outputs {
+		if out.Type == "cast" {
+			devices = append(devices, out.device)
+		}
+	}
+	if len(devices) > 0 {
+		c.resumeCast(ctx, devices)
+	}
+}
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// bridgePartner returns the zone whose channel a bridged zone also drives,
+// or nil if z is not bridged.
+func bridgePartner(s *models.State, z *models.Zone) *models.Zone {
+	if !z.Bridged {
+		return nil
+	}
+	return findZone(s, z.ID+1)
+}
+
+// bridgePrimary returns the bridged zone driving z's channel, or nil if z
+// is not a bridge partner.
+func bridgePrimary(s *models.State, z *models.Zone) *models.Zone {
+	if z.ID%2 == 0 {
+		return nil
+	}
+	if p := findZone(s, z.ID-1); p != nil && p.Bridged {
+		return p
+	}
+	return nil
+}
+
+// mirrorBridge copies what reaches the amplifier - source, mute and
+// volume - from a bridged zone to its partner, so both channels of the
+// pair always get the same signal.
+func mirrorBridge(partner *models.Zone, primary models.Zone) {
+	partner.SourceID = primary.SourceID
+	partner.Mute = primary.Mute
+	partner.Vol, partner.VolF = primary.Vol, primary.VolF
+	partner.VolMin, partner.VolMax = primary.VolMin, primary.VolMax
+	partner.VolLimit = nil
+	if primary.VolLimit != nil {
+		limit := *primary.VolLimit
+		partner.VolLimit = &limit
+	}
+}
+
+// controlsPlayback reports whether upd changes more than a zone's name,
+// which a bridge partner does not allow.
+func controlsPlayback(upd models.ZoneUpdate) bool {
+	return upd.SourceID != nil || upd.Mute != nil || upd.Vol != nil || upd.VolF != nil ||
+		upd.VolDelta != nil || upd.VolDeltaF != nil || upd.VolMin != nil || upd.VolMax != nil ||
+		upd.Amp != nil || upd.Night != nil || upd.Bridged != nil || upd.Disabled != nil
+}
+
+// validateBridge checks that z can be bridged with its partner: z must be
+// the first zone of a channel pair on hardware that supports bridging, and
+// the partner must exist and not be grouped.
+func (c *Controller) validateBridge(s *models.State, z *models.Zone) error {
+	if z.ID%2 != 0 {
+		return models.ErrBadRequest(fmt.Sprintf("zone %d is the second of its channel pair; bridge zone %d instead", z.ID, z.ID-1))
+	}
+	if c.profile != nil && !c.profile.CanBridge(z.ID) {
+		return models.ErrBadRequest(fmt.Sprintf("the unit driving zone %d does not support bridged outputs", z.ID))
+	}
+	partner := findZone(s, z.ID+1)
+	if partner == nil || partner.Disabled {
+		return models.ErrBadRequest(fmt.Sprintf("zone %d has no partner zone %d to bridge with", z.ID, z.ID+1))
+	}
+	for i := range s.Groups {
+		g := &s.Groups[i]
+		for _, id := range groupZones(s, g) {
+			if id == partner.ID {
+				return models.ErrConflict(fmt.Sprintf("zone %d is in group %q; remove it before bridging it with zone %d", partner.ID, g.Name, z.ID))
+			}
+		}
+	}
+	return nil
+}
+
+// checkGroupZones rejects bridge partners in a group's zones; the bridged
+// zone they follow is grouped instead.
+func checkGroupZones(s *models.State, ids []int) error {
+	for _, id := range ids {
+		if z := findZone(s, id); z != nil {
+			if p := bridgePrimary(s, z); p != nil {
+				return models.ErrBadRequest(fmt.Sprintf("zone %d is bridged with zone %d; group zone %d instead", id, p.ID, p.ID))
+			}
+		}
+	}
+	return nil
+}
+
+// reconcileBridges unbridges zones that cannot be bridged, e.g. after the
+// config was moved to older hardware, and re-mirrors the partners of the
+// rest. It returns why each zone was unbridged.
+func (c *Controller) reconcileBridges(s *models.State) []string {
+	var unbridged []string
+	for i := range s.Zones {
+		z := &s.Zones[i]
+		if !z.Bridged {
+			continue
+		}
+		if err := c.validateBridge(s, z); err != nil {
+			slog.Warn("unbridging zone", "zone", z.ID, "err", err)
+			z.Bridged = false
+			unbridged = append(unbridged, fmt.Sprintf("zone %d unbridged: %s", z.ID, err))
+			continue
+		}
+		mirrorBridge(findZone(s, z.ID+1), *z)
+	}
+	return unbridged
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// PlayCalibrationNoise plays pink noise through a single zone at a fixed
+// volume so an installer can measure its SPL, then record it with
+// SetZoneCalibration. It blocks like IdentifyZone until playback finishes
+// and the previous state is restored.
+func (c *Controller) PlayCalibrationNoise(ctx context.Context, id int, req models.CalibrationNoise) (models.State, *models.AppError) {
+	vol := models.DefaultCalibrationVol
+	if req.Vol != nil {
+		vol = *req.Vol
+	}
+	seconds := models.DefaultCalibrationSeconds
+	if req.Seconds != nil {
+		seconds = *req.Seconds
+	}
+	if seconds < 1 || seconds > models.MaxCalibrationSeconds {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("seconds must be 1-%d", models.MaxCalibrationSeconds)).WithField("seconds")
+	}
+	z, appErr := c.calibrationZone(id, vol)
+	if appErr != nil {
+		return models.State{}, appErr
+	}
+
+	path, err := tempSignal(func(w io.Writer) error {
+		return audio.WritePinkNoise(w, float64(seconds))
+	})
+	if err != nil {
+		return models.State{}, models.ErrInternal("pink noise: " + err.Error())
+	}
+	defer os.Remove(path)
+	return c.Announce(ctx, models.AnnounceRequest{
+		Media:    path,
+		Vol:      &vol,
+		SourceID: req.SourceID,
+		Zones:    []int{z.ID},
+	})
+}
+
+// SetZoneCalibration records the SPL measured in a zone and recomputes the
+// offsets of every calibrated zone, keeping each zone's vol_f.
+func (c *Controller) SetZoneCalibration(ctx context.Context, id int, req models.CalibrationMeasurement) (models.State, *models.AppError) {
+	vol := models.DefaultCalibrationVol
+	if req.Vol != nil {
+		vol = *req.Vol
+	}
+	if req.SPL <= 0 || req.SPL > 140 {
+		return models.State{}, models.ErrBadRequest("spl must be a measured level in dB SPL, 0-140").WithField("spl")
+	}
+	if _, appErr := c.calibrationZone(id, vol); appErr != nil {
+		return models.State{}, appErr
+	}
+	return c.calibrate(ctx, id, &models.ZoneCalibration{Vol: vol, SPL: req.SPL})
+}
+
+// ClearZoneCalibration removes a zone's measurement and recomputes the
+// offsets of the others.
+func (c *Controller) ClearZoneCalibration(ctx context.Context, id int) (models.State, *models.AppError) {
+	return c.calibrate(ctx, id, nil)
+}
+
+// calibrationZone returns the zone to calibrate, checking that it can play
+// at vol.
+func (c *Controller) calibrationZone(id, vol int) (models.Zone, *models.AppError) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	z := findZone(&c.state, id)
+	if z == nil {
+		return models.Zone{}, models.ErrNotFound(fmt.Sprintf("zone %d not found", id))
+	}
+	if z.Disabled {
+		return models.Zone{}, models.ErrBadRequest("zone is disabled")
+	}
+	if vol < z.VolMin || vol > z.VolMax {
+		return models.Zone{}, models.ErrBadRequest(fmt.Sprintf("vol must be within the zone's range, %d to %d dB", z.VolMin, z.VolMax)).WithField("vol")
+	}
+	return *z, nil
+}
+
+// calibrate sets zone id's calibration, recomputes every offset and moves
+// the zones whose offset changed to the volume their vol_f now maps to.
+func (c *Controller) calibrate(ctx context.Context, id int, cal *models.ZoneCalibration) (models.State, *models.AppError) {
+	state, err := c.apply(func(s *models.State) error {
+		z := findZone(s, id)
+		if z == nil {
+			return models.ErrNotFound(fmt.Sprintf("zone %d not found", id))
+		}
+		z.Calibration = cal
+		changed := models.CalibrateZones(s)
+		if cal == nil {
+			changed = append(changed, id) // back to no offset
+		}
+		for _, zid := range changed {
+			cz := findZone(s, zid)
+			volF := cz.VolF
+			if err := applyZoneUpdate(ctx, c, s, cz, models.ZoneUpdate{VolF: &volF}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, appErr
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return state, nil
+}
@@ -0,0 +1,360 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/cast"
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
+)
+
+// castTokenFile holds the secret of the source audio URLs in the config
+// dir.
+const castTokenFile = "cast-token"
+
+// SetCast enables Google Cast output using devices found by b. port is the
+// HTTP port Cast devices fetch source audio from. The secret in the URLs
+// handed to devices is read from configDir, or created there on first use,
+// so devices already playing a source keep playing it across restarts.
+// Must be called before the HTTP server starts.
+func (c *Controller) SetCast(b *cast.Browser, port int, configDir string) error {
+	token, err := loadCastToken(filepath.Join(configDir, castTokenFile))
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	c.cast = b
+	c.castPort = port
+	c.castToken = token
+	c.mu.Unlock()
+	return nil
+}
+
+// loadCastToken reads the token in path, writing a new one if there is none.
+func loadCastToken(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		token := strings.TrimSpace(string(data))
+		if _, err := hex.DecodeString(token); err != nil || len(token) != 32 {
+			return "", fmt.Errorf("cast token %s is not 32 hex digits", path)
+		}
+		return token, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(raw)
+	if err := os.WriteFile(path, []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("saving the cast token: %w", err)
+	}
+	return token, nil
+}
+
+// RestoreCast reapplies the Cast routing of the sources after a restart:
+// each device a source is routed to is loaded with the source again once
+// discovery finds it. Checks every interval and returns when every routed
+// device has been loaded or ctx is cancelled.
+func (c *Controller) RestoreCast(ctx context.Context, interval time.Duration) {
+	if c.castBrowser() == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if c.castPending() == 0 {
+			return
+		}
+		c.kickCast()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// castBrowser returns the Cast browser, or nil if Cast is disabled.
+func (c *Controller) castBrowser() *cast.Browser {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.cast
+}
+
+// VerifyCastToken reports whether token grants access to the source audio
+// streams served to Cast devices, which cannot log in.
+func (c *Controller) VerifyCastToken(token string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.castToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(c.castToken)) == 1
+}
+
+// GetCastDevices returns the discovered Cast devices and the source each plays.
+func (c *Controller) GetCastDevices() []models.CastDevice {
+	b := c.castBrowser()
+	if b == nil {
+		return []models.CastDevice{}
+	}
+	routed := make(map[string]int)
+	for _, src := range c.GetSources() {
+		if src.Cast != nil && src.Cast.Enabled {
+			for _, id := range src.Cast.Devices {
+				routed[id] = src.ID
+			}
+		}
+	}
+	devices := b.Devices()
+	result := make([]models.CastDevice, 0, len(devices))
+	for _, d := range devices {
+		cd := models.CastDevice{ID: d.ID, Name: d.Name, Model: d.Model, Addr: d.Addr}
+		if sid, ok := routed[d.ID]; ok {
+			cd.SourceID = &sid
+		}
+		result = append(result, cd)
+	}
+	return result
+}
+
+// StreamSourceAudio writes source id's audio to w as MP3 until ctx ends.
+func (c *Controller) StreamSourceAudio(ctx context.Context, id int, w io.Writer) *models.AppError {
+	if c.streams == nil {
+		return models.ErrBadRequest("streams are not available")
+	}
+	device, ok := c.streams.SourceCaptureDevice(id)
+	if !ok {
+		return models.ErrNotFound("no stream is playing on source")
+	}
+	if err := streams.EncodeMP3(ctx, device, w); err != nil {
+		return models.ErrInternal(err.Error())
+	}
+	return nil
+}
+
+// validateCastOutput checks a source's Cast routing request.
+func (c *Controller) validateCastOutput(out *models.CastOutput) *models.AppError {
+	if out.Enabled && c.castBrowser() == nil {
+		return models.ErrBadRequest("cast is not available")
+	}
+	if out.Volume != nil && (*out.Volume < 0 || *out.Volume > 100) {
+		return models.ErrBadRequest("cast volume must be 0-100")
+	}
+	return nil
+}
+
+// castSourceURL returns the URL device d can fetch source sid's audio from.
+func (c *Controller) castSourceURL(d cast.Device, sid int) (string, error) {
+	host, err := cast.LocalAddrFor(d)
+	if err != nil {
+		return "", err
+	}
+	c.mu.RLock()
+	port, token := c.castPort, c.castToken
+	c.mu.RUnlock()
+	return fmt.Sprintf("http://%s/cast/sources/%d.mp3?token=%s",
+		net.JoinHostPort(host, strconv.Itoa(port)), sid, token), nil
+}
+
+// castDevices returns the Cast device IDs an output routes to, or nil if disabled.
+func castDevices(out *models.CastOutput) []string {
+	if out == nil || !out.Enabled {
+		return nil
+	}
+	return out.Devices
+}
+
+// castRoute is the source a Cast device plays and the volume it was set
+// to, nil = left as it was.
+type castRoute struct {
+	source int
+	volume *int
+}
+
+// castRoutes returns the route the sources want for each Cast device. A
+// device routed from several sources plays the last.
+func castRoutes(sources []models.Source) map[string]castRoute {
+	routes := make(map[string]castRoute)
+	for _, src := range sources {
+		for _, id := range castDevices(src.Cast) {
+			routes[id] = castRoute{source: src.ID, volume: src.Cast.Volume}
+		}
+	}
+	return routes
+}
+
+// kickCast makes the Cast devices follow the sources' routing in the
+// background: each device command is a network round trip and must not
+// hold up the API. One sync runs at a time and always works from the
+// current routing, so quick changes cannot be applied out of order.
+func (c *Controller) kickCast() {
+	c.castMu.Lock()
+	defer c.castMu.Unlock()
+	c.castDirty = true
+	if c.castRunning {
+		return
+	}
+	c.castRunning = true
+	c.castSyncs.Add(1)
+	go func() {
+		defer c.castSyncs.Done()
+		for {
+			c.castMu.Lock()
+			if !c.castDirty {
+				c.castRunning = false
+				c.castMu.Unlock()
+				return
+			}
+			c.castDirty = false
+			c.castMu.Unlock()
+			c.syncCast(context.Background())
+		}
+	}()
+}
+
+// castPending returns how many routed devices are not playing their source.
+func (c *Controller) castPending() int {
+	want := castRoutes(c.GetSources())
+	c.castMu.Lock()
+	defer c.castMu.Unlock()
+	n := 0
+	for id, r := range want {
+		if loaded, ok := c.castLoaded[id]; !ok || loaded.source != r.source {
+			n++
+		}
+	}
+	return n
+}
+
+// syncCast stops, loads and re-levels Cast devices until each plays what
+// the sources route to it. Devices not discovered yet and failed loads are
+// left for the next sync. Only run by the kickCast goroutine.
+func (c *Controller) syncCast(ctx context.Context) {
+	b := c.castBrowser()
+	if b == nil {
+		return
+	}
+	want := castRoutes(c.GetSources())
+	c.castMu.Lock()
+	loaded := maps.Clone(c.castLoaded)
+	c.castMu.Unlock()
+	if loaded == nil {
+		loaded = make(map[string]castRoute)
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(loaded)) {
+		if _, ok := want[id]; ok {
+			continue
+		}
+		if d, ok := b.Device(id); ok {
+			if err := cast.Stop(ctx, d); err != nil {
+				slog.Warn("cast: stop failed", "device", d.Name, "err", err)
+			}
+		}
+		delete(loaded, id)
+	}
+	for _, id := range slices.Sorted(maps.Keys(want)) {
+		r := want[id]
+		d, ok := b.Device(id)
+		if !ok {
+			slog.Debug("cast: device not found", "device", id, "source", r.source)
+			continue
+		}
+		cur, ok := loaded[id]
+		if !ok || cur.source != r.source {
+			slog.Info("cast: loading source", "source", r.source, "device", d.Name)
+			url, err := c.castSourceURL(d, r.source)
+			if err == nil {
+				err = cast.Load(ctx, d, url, "audio/mpeg")
+			}
+			if err != nil {
+				slog.Warn("cast: load failed", "device", d.Name, "source", r.source, "err", err)
+				delete(loaded, id)
+				continue
+			}
+			cur = castRoute{source: r.source}
+		}
+		if r.volume != nil && (cur.volume == nil || *cur.volume != *r.volume) {
+			if err := cast.SetVolume(ctx, d, *r.volume); err != nil {
+				slog.Warn("cast: volume failed", "device", d.Name, "err", err)
+			} else {
+				vol := *r.volume
+				cur.volume = &vol
+			}
+		}
+		loaded[id] = cur
+	}
+
+	c.castMu.Lock()
+	c.castLoaded = loaded
+	c.castMu.Unlock()
+}
+
+// resolveCastDevices maps requested device IDs to discovered devices.
+func (c *Controller) resolveCastDevices(ids []string) ([]cast.Device, *models.AppError) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	b := c.castBrowser()
+	if b == nil {
+		return nil, models.ErrBadRequest("cast is not available")
+	}
+	devices := make([]cast.Device, 0, len(ids))
+	for _, id := range ids {
+		d, ok := b.Device(id)
+		if !ok {
+			return nil, models.ErrBadRequest(fmt.Sprintf("cast device %q not found", id))
+		}
+		devices = append(devices, d)
+	}
+	return devices, nil
+}
+
+// castAnnouncement plays mediaURL on the given Cast devices at volF.
+func (c *Controller) castAnnouncement(ctx context.Context, devices []cast.Device, mediaURL string, volF float64) {
+	for _, d := range devices {
+		if err := cast.SetVolume(ctx, d, int(volF*100)); err != nil {
+			slog.Warn("cast: announcement volume failed", "device", d.Name, "err", err)
+		}
+		if err := cast.Load(ctx, d, mediaURL, ""); err != nil {
+			slog.Warn("cast: announcement failed", "device", d.Name, "err", err)
+		}
+	}
+}
+
+// resumeCast reloads the source each device was playing before an
+// announcement interrupted it, or stops devices that were idle.
+func (c *Controller) resumeCast(ctx context.Context, devices []cast.Device) {
+	sources := c.GetSources()
+	for _, d := range devices {
+		resumed := false
+		for _, src := range sources {
+			if !slices.Contains(castDevices(src.Cast), d.ID) {
+				continue
+			}
+			if url, err := c.castSourceURL(d, src.ID); err == nil {
+				resumed = cast.Load(ctx, d, url, "audio/mpeg") == nil
+			}
+			break
+		}
+		if !resumed {
+			_ = cast.Stop(ctx, d)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/clips"
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
+)
+
+// SetClips enables the announcement clip library backed by lib. Must be
+// called before the HTTP server starts.
+func (c *Controller) SetClips(lib *clips.Library) {
+	c.mu.Lock()
+	c.clipLib = lib
+	c.mu.Unlock()
+}
+
+// clipLibrary returns the clip library or a 400 if none is configured.
+func (c *Controller) clipLibrary() (*clips.Library, *models.AppError) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.clipLib == nil {
+		return nil, models.ErrBadRequest("the clip library is not available")
+	}
+	return c.clipLib, nil
+}
+
+// GetClips returns the announcement clips and the quota they use.
+func (c *Controller) GetClips() (models.ClipLibrary, *models.AppError) {
+	lib, appErr := c.clipLibrary()
+	if appErr != nil {
+		return models.ClipLibrary{}, appErr
+	}
+	list, err := lib.List()
+	if err != nil {
+		return models.ClipLibrary{}, models.ErrInternal(err.Error())
+	}
+	return list, nil
+}
+
+// AddClip stores the audio read from r as the named clip, replacing any
+// clip of that name. The format comes from filename's extension, and the
+// name too when name is empty. The audio is probed like announcement
+// media, so a clip that can't be played is refused with 400.
+func (c *Controller) AddClip(ctx context.Context, name, filename string, r io.Reader) (models.Clip, *models.AppError) {
+	lib, appErr := c.clipLibrary()
+	if appErr != nil {
+		return models.Clip{}, appErr
+	}
+	ext := filepath.Ext(filepath.Base(filename))
+	if name == "" {
+		name = strings.TrimSuffix(filepath.Base(filename), ext)
+	}
+	if err := clips.CheckName(name); err != nil {
+		return models.Clip{}, models.ErrBadRequest(err.Error()).WithField("name")
+	}
+	if err := clips.CheckFormat(ext); err != nil {
+		return models.Clip{}, models.ErrBadRequest(err.Error()).WithField("file")
+	}
+	var probeErr error
+	clip, err := lib.Add(name, ext, r, func(path string) error {
+		probeErr = streams.ProbeMedia(ctx, path)
+		return probeErr
+	})
+	switch {
+	case err == nil:
+		return clip, nil
+	case probeErr != nil:
+		return models.Clip{}, models.ErrBadRequest("clip can't be played: " + probeErr.Error()).WithField("file")
+	case errors.Is(err, clips.ErrQuota):
+		return models.Clip{}, models.ErrTooLarge(err.Error())
+	}
+	return models.Clip{}, models.ErrInternal(err.Error())
+}
+
+// DeleteClip removes the named clip.
+func (c *Controller) DeleteClip(ctx context.Context, name string) (models.ClipLibrary, *models.AppError) {
+	lib, appErr := c.clipLibrary()
+	if appErr != nil {
+		return models.ClipLibrary{}, appErr
+	}
+	if err := lib.Delete(name); err != nil {
+		return models.ClipLibrary{}, clipError(name, err)
+	}
+	return c.GetClips()
+}
+
+// ClipPath returns the file of the named clip.
+func (c *Controller) ClipPath(name string) (string, *models.AppError) {
+	lib, appErr := c.clipLibrary()
+	if appErr != nil {
+		return "", appErr
+	}
+	path, err := lib.Path(name)
+	if err != nil {
+		return "", clipError(name, err)
+	}
+	return path, nil
+}
+
+// resolveMedia returns the file of media naming a clip ("clip:<name>"),
+// and other media unchanged.
+func (c *Controller) resolveMedia(media string) (string, *models.AppError) {
+	name, ok := models.ClipName(media)
+	if !ok {
+		return media, nil
+	}
+	path, appErr := c.ClipPath(name)
+	if appErr != nil {
+		return "", models.ErrBadRequest(appErr.Message).WithField("media")
+	}
+	return path, nil
+}
+
+// clipError maps clip library errors to API errors.
+func clipError(name string, err error) *models.AppError {
+	if errors.Is(err, clips.ErrNotFound) {
+		return models.ErrNotFound(fmt.Sprintf("clip %q not found", name))
+	}
+	return models.ErrInternal(err.Error())
+}
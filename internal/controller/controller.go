@@ -5,11 +5,20 @@ package controller
 import (
 	"context"
 	"sync"
+	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/artwork"
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/cast"
+	"github.com/micro-nova/amplipi-go/internal/clips"
 	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/events"
 	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/identity"
+	"github.com/micro-nova/amplipi-go/internal/logs"
 	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/shares"
+	"github.com/micro-nova/amplipi-go/internal/snapcast"
 	"github.com/micro-nova/amplipi-go/internal/streams"
 )
 
@@ -17,13 +26,101 @@ import (
 // All state mutations go through the apply() method which ensures
 // atomicity, persistence, and event publishing.
 type Controller struct {
-	mu      sync.RWMutex
-	state   models.State
-	hw      hardware.Driver
-	profile *hardware.HardwareProfile // may be nil (no capability restrictions)
-	store   config.Store
-	bus     *events.Bus
-	streams *streams.Manager
+	mu       sync.RWMutex
+	state    models.State
+	hw       hardware.Driver
+	profile  *hardware.HardwareProfile // may be nil (no capability restrictions)
+	store    config.Store
+	bus      *events.Bus
+	hwq      *hwQueue       // hardware writes, applied outside mu
+	syncs    sync.WaitGroup // background stream manager syncs
+	streams  *streams.Manager
+
+	syncMu      sync.Mutex // guards the stream sync flags; never held while acquiring mu
+	syncDirty   bool       // state changed since the running stream sync read it
+	syncRunning bool       // a stream sync goroutine is running
+
+	outputs  *audio.Outputs   // physical output mapping; nil = not configurable
+	snapcast *snapcast.Client // managed snapserver; nil = Snapcast API disabled
+
+	cast      *cast.Browser // Google Cast discovery; nil = Cast disabled
+	castPort  int           // HTTP port Cast devices fetch source audio from
+	castToken string        // secret in source audio URLs handed to Cast devices
+
+	castMu      sync.Mutex           // guards castLoaded and the sync flags; never held while acquiring mu
+	castLoaded  map[string]castRoute // device ID -> source it was loaded with
+	castDirty   bool                 // routing changed since the running sync looked
+	castRunning bool                 // a sync goroutine is running
+	castSyncs   sync.WaitGroup       // background Cast syncs
+
+	shares  *shares.Manager // network shares in the media library; nil = disabled
+	clipLib *clips.Library  // announcement clips; nil = disabled
+	logBuf  *logs.Buffer    // recent daemon logs served by the API; nil = disabled
+
+	provision *models.Provision // installer template factory reset reapplies; nil = none; guarded by mu
+
+	overrideDir string           // config directory holding the hardware profile override; "" = not changeable
+	selfTest    *models.SelfTest // last self-test result; nil = not run; guarded by mu
+
+	now         func() time.Time  // clock for amp idle timeouts and off hours
+	ampLastUsed map[int]time.Time // zone ID -> last time the zone was in use
+	ampEnables  map[int][6]bool   // unit -> amp enables last written
+	ampsOff     bool              // set by Shutdown: amps stay disabled
+
+	streamHooks   []streamHook      // run by apply when a stream starts or stops; set in New
+	unitDown      map[int]bool      // unit -> powered down while idle; guarded by mu
+	unitIdleSince map[int]time.Time // unit -> when it went idle; guarded by mu
+	railsOff      map[int]bool      // unit -> rails taken down by unit power; only touched by the hwq drain
+
+	fadeMu sync.Mutex       // guards fades; never held while acquiring mu
+	fades  map[int]*fadeRun // unit -> last crossfade started on it, until it finishes
+
+	ledMu   sync.Mutex       // guards leds; never held while acquiring mu
+	leds    map[int]*ledUnit // unit -> software LED state, created on first use
+	ledKick chan struct{}    // wakes RunLEDActivity after state changes
+
+	rcaMu sync.Mutex // guards rca; held across apply, never acquired under mu
+	rca   rcaState   // last RCA signal reading for auto-switch
+
+	idleMu    sync.Mutex        // guards idleSince; never held while acquiring mu
+	idleSince map[int]time.Time // source ID -> when its stream went idle
+
+	trigMu sync.Mutex            // guards trig; never held while acquiring mu
+	trig   map[int]*triggerState // trigger ID -> output state
+
+	fanMu   sync.Mutex         // guards fanDuty; never held while acquiring mu
+	fanDuty map[int]fanReading // unit -> last fan curve reading and duty written
+
+	meter     levelMeter           // meters a source's audio; nil without streams
+	silenceMu sync.Mutex           // guards silence; never held while acquiring mu
+	silence   map[int]silenceState // stream ID -> silence while playing
+
+	healthMu sync.Mutex
+	health   []healthSample // recent temperature/power readings for diagnostics
+	overTemp map[int]bool   // unit -> over-temperature last reported
+
+	powerMu sync.Mutex // guards power
+	power   powerToken // outstanding reboot, shutdown or factory reset confirmation
+
+	tzMu sync.Mutex     // guards tz
+	tz   *time.Location // timezone SetTime last set, nil = the process's own
+
+	snapMu    sync.Mutex // guards snapshots; never held while acquiring mu
+	snapshots []snapshot // state snapshots, oldest first
+
+	revisions []revision // changes that can be undone, oldest first; guarded by mu
+	revID     int        // ID of the last revision recorded; guarded by mu
+	undoing   bool       // the change being applied is an undo; guarded by mu
+
+	stateRev    int64    // revision of the state, for polling clients; guarded by mu
+	sectionRevs [7]int64 // revision each of stateSections last changed in; guarded by mu
+
+	release  string // newest release update_available was emitted for; guarded by mu
+	hostname string // OS hostname; guarded by mu
+
+	unitFirmware map[int]string // unit -> firmware version, read at startup
+
+	artwork *artwork.Cache // stream artwork fetched for GetStreamImage
 }
 
 // New creates and initializes a new Controller.
@@ -35,6 +132,11 @@ func New(hw hardware.Driver, profile *hardware.HardwareProfile, store config.Sto
 	if err != nil {
 		return nil, err
 	}
+	state.Info.HardwareErrors = nil // from a previous run
+	for i := range state.Streams {
+		state.Streams[i].Active = nil // until the detectors are read
+		state.Streams[i].Info = staleStreamInfo(state.Streams[i].Info)
+	}
 
 	c := &Controller{
 		state:   *state,
@@ -43,18 +145,55 @@ func New(hw hardware.Driver, profile *hardware.HardwareProfile, store config.Sto
 		store:   store,
 		bus:     bus,
 		streams: mgr,
+
+		hostname:    identity.GetHostname(),
+		now:         time.Now,
+		ampLastUsed: make(map[int]time.Time),
+		ampEnables:  make(map[int][6]bool),
+		unitDown:    make(map[int]bool),
+		leds:        make(map[int]*ledUnit),
+		ledKick:     make(chan struct{}, 1),
+		artwork:     artwork.NewCache(),
+		rca:         rcaState{prev: make(map[int]string)},
+		trig:        make(map[int]*triggerState),
+		fanDuty:     make(map[int]fanReading),
+		silence:     make(map[int]silenceState),
+
+		unitIdleSince: make(map[int]time.Time),
+		railsOff:      make(map[int]bool),
+		fades:         make(map[int]*fadeRun),
+		unitFirmware:  make(map[int]string),
+	}
+	c.hwq = newHWQueue(c.reportHWError)
+	c.stateRev = c.now().UnixMilli()
+	for i := range c.sectionRevs {
+		c.sectionRevs[i] = c.stateRev
+	}
+	if mgr != nil {
+		c.meter = mgr.SourceLevel
 	}
+	c.onStreamActivity(c.unitPowerStream)
+	for _, unit := range hw.Units() {
+		if v, err := hw.ReadVersion(context.Background(), unit); err == nil {
+			c.unitFirmware[unit] = v.String()
+		}
+	}
+	c.reconcileZones(&c.state)
+	pruneKeypad(&c.state)
+	c.reconcileBridges(&c.state)
+	c.markUnavailableStreams(&c.state)
+	computeSources(&c.state)
+	c.emitUnavailable(&models.State{}, &c.state)
 
-	// Apply initial state to hardware
+	// Apply initial state to hardware. Failures are not fatal — we can run
+	// without hardware (mock or debug mode) — and end up in Info.
 	ctx := context.Background()
-	if err := c.applyStateToHW(ctx, *state); err != nil {
-		// Not fatal — we can run without hardware (mock or debug mode)
-		_ = err
-	}
+	c.applyStateToHW(c.state)
+	_ = c.FlushHardware(ctx)
 
 	// Sync initial stream state if manager is available
 	if c.streams != nil {
-		if err := c.streams.Sync(ctx, state.Streams, state.Sources); err != nil {
+		if err := c.streams.Sync(ctx, c.runnableStreams(c.state.Streams), c.state.Sources); err != nil {
 			// Not fatal — log and continue
 			_ = err
 		}
@@ -69,7 +208,8 @@ func (c *Controller) UpdateStreamInfo(id int, info models.StreamInfo) {
 	_, _ = c.apply(func(s *models.State) error {
 		for i := range s.Streams {
 			if s.Streams[i].ID == id {
-				s.Streams[i].Info = info
+				s.Streams[i].Info = mergeStaleInfo(s.Streams[i].Info, info)
+				linkAirPlayGroups(s)
 				return nil
 			}
 		}
@@ -88,7 +228,12 @@ func (c *Controller) State() models.State {
 //  1. Acquires the write lock
 //  2. Makes a deep copy of current state
 //  3. Calls fn to modify the copy (fn may return an error to abort)
-//  4. If fn succeeds: updates state, schedules save, publishes event, syncs streams
+//  4. If fn succeeds: updates state, records it for undo, schedules save,
+//     publishes event, syncs streams
+//
+// fn must not touch hardware directly; it queues writes (queueZoneVol, ...)
+// which are applied after the lock is released. Write failures are
+// reported asynchronously in Info.HardwareErrors.
 func (c *Controller) apply(fn func(*models.State) error) (models.State, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -97,78 +242,117 @@ func (c *Controller) apply(fn func(*models.State) error) (models.State, error) {
 	if err := fn(&next); err != nil {
 		return models.State{}, err
 	}
+	c.finishState(&next)
 
+	prev := c.state
 	c.state = next
+	c.recordRevision(&prev, &c.state)
+	c.bumpStateRev(&prev, &c.state)
 	_ = c.store.Save(&c.state) // debounced, async
 	c.bus.Publish(c.state)
+	c.emitChanges(&prev, &c.state)
+	c.refreshAmps()
+	c.kickLEDs()
+	if c.cast != nil {
+		c.kickCast()
+	}
 
 	// Sync stream manager with updated state (non-blocking: runs in background)
 	if c.streams != nil {
-		go func(streams_ []models.Stream, sources_ []models.Source) {
-			if err := c.streams.Sync(context.Background(), streams_, sources_); err != nil {
+		c.kickStreams()
+	}
+
+	return c.state, nil
+}
+
+// kickStreams syncs the stream manager with the state in the background.
+// One sync runs at a time and reads the state when it starts, so a sync
+// of an older state cannot undo a newer one.
+func (c *Controller) kickStreams() {
+	c.syncMu.Lock()
+	defer c.syncMu.Unlock()
+	c.syncDirty = true
+	if c.syncRunning {
+		return
+	}
+	c.syncRunning = true
+	c.syncs.Add(1)
+	go func() {
+		defer c.syncs.Done()
+		for {
+			c.syncMu.Lock()
+			if !c.syncDirty {
+				c.syncRunning = false
+				c.syncMu.Unlock()
+				return
+			}
+			c.syncDirty = false
+			c.syncMu.Unlock()
+
+			c.mu.RLock()
+			state := c.state.DeepCopy()
+			c.mu.RUnlock()
+			if err := c.streams.Sync(context.Background(), c.runnableStreams(state.Streams), state.Sources); err != nil {
 				// Log but don't fail the apply
 				_ = err
 			}
-		}(next.Streams, next.Sources)
-	}
+		}
+	}()
+}
 
-	return c.state, nil
+// finishState fills in what apply derives from a changed state: stream
+// availability and the zones listening to each source.
+func (c *Controller) finishState(s *models.State) {
+	c.markUnavailableStreams(s)
+	computeSources(s)
 }
 
-// applyStateToHW writes the complete state to the hardware driver.
+// applyStateToHW queues writes of the complete state to the hardware driver.
 // Called at startup and after factory reset.
-func (c *Controller) applyStateToHW(ctx context.Context, state models.State) error {
+func (c *Controller) applyStateToHW(state models.State) {
 	for _, unit := range c.hw.Units() {
 		// Determine source types (analog/digital) for this unit
 		// For simplicity, assume all sources are digital initially
 		var analog [4]bool // false = digital
-		if err := c.hw.SetSourceTypes(ctx, unit, analog); err != nil {
-			return err
-		}
+		c.queueSourceTypes(unit, analog)
 
 		// Configure zones
 		baseZone := unit * 6
 		var sources [6]int
 		var mutes [6]bool
-		var enables [6]bool
+		enables := c.ampEnablesFor(&state, unit, c.localNow())
 
 		for i := 0; i < 6; i++ {
-			zoneIdx := baseZone + i
-			if zoneIdx < len(state.Zones) {
-				z := state.Zones[zoneIdx]
+			if z := findZone(&state, baseZone+i); z != nil {
 				if z.SourceID >= 0 && z.SourceID <= 3 {
 					sources[i] = z.SourceID
 				}
 				mutes[i] = z.Mute
-				enables[i] = !z.Disabled
 			} else {
 				mutes[i] = true
-				enables[i] = false
 			}
 		}
 
-		if err := c.hw.SetZoneSources(ctx, unit, sources); err != nil {
-			return err
-		}
-		if err := c.hw.SetZoneMutes(ctx, unit, mutes); err != nil {
-			return err
-		}
-		if err := c.hw.SetAmpEnables(ctx, unit, enables); err != nil {
-			return err
+		// Amps come on before zones are unmuted; with soft start the
+		// unmuted zones then ramp up from silence.
+		soft := softStart(&state)
+		for i := range mutes {
+			if !mutes[i] && enables[i] {
+				soft.in = append(soft.in, i)
+			}
 		}
+		c.queueZoneSources(unit, sources, crossfade{})
+		c.queueAmpEnables(unit, enables, crossfade{})
+		c.ampEnables[unit] = enables
+		c.queueZoneMutes(unit, mutes, soft)
 
 		// Set volumes
 		for i := 0; i < 6; i++ {
-			zoneIdx := baseZone + i
-			if zoneIdx < len(state.Zones) {
-				vol := state.Zones[zoneIdx].Vol
-				if err := c.hw.SetZoneVol(ctx, unit, i, vol); err != nil {
-					return err
-				}
+			if z := findZone(&state, baseZone+i); z != nil {
+				c.queueZoneVol(unit, i, z.Vol)
 			}
 		}
 	}
-	return nil
 }
 
 // findZone returns a pointer to the zone with the given ID in the state, or nil.
@@ -233,11 +417,12 @@ func nextStreamID(state *models.State) int {
 	return maxID + 1
 }
 
-// nextPresetID returns the next available preset ID.
+// nextPresetID returns the next available preset ID below the reserved
+// ones.
 func nextPresetID(state *models.State) int {
 	maxID := 0
 	for _, p := range state.Presets {
-		if p.ID > maxID && p.ID < models.LastPresetID {
+		if p.ID > maxID && !models.ReservedPresetID(p.ID) {
 			maxID = p.ID
 		}
 	}
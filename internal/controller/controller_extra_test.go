@@ -2,10 +2,29 @@ package controller_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"testing"
-
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/cast"
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
 	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/snapcast"
+	"github.com/micro-nova/amplipi-go/internal/streams"
 )
 
 func TestSetZoneVolClamped_AboveMax(t *testing.T) {
@@ -326,6 +345,151 @@ func TestCreateStream_MissingType(t *testing.T) {
 	}
 }
 
+func TestStreamConfigValidation(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	if _, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Test", Type: "gramophone"}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("unknown stream type: %v", appErr)
+	}
+	_, appErr := ctrl.CreateStream(ctx, models.StreamCreate{
+		Name: "Radio", Type: "internetradio", Config: map[string]interface{}{"ulr": "http://example.com"},
+	})
+	if appErr == nil || !strings.Contains(appErr.Message, `unknown key "ulr"`) || !strings.Contains(appErr.Message, `missing required key "url"`) {
+		t.Errorf("misspelled key: %v", appErr)
+	}
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Files", Type: "fileplayer"})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	st := state.Streams[len(state.Streams)-1]
+	if st.Config["shuffle"] != false || st.Config["repeat"] != false {
+		t.Errorf("defaults not applied: %v", st.Config)
+	}
+	if _, appErr := ctrl.SetStream(ctx, st.ID, models.StreamUpdate{Config: map[string]interface{}{"shuffle": "yes"}}); appErr == nil {
+		t.Error("SetStream accepted a string for a bool")
+	}
+	if s, _ := ctrl.GetStream(st.ID); s.Config["shuffle"] != false {
+		t.Errorf("rejected update was applied: %v", s.Config)
+	}
+	if _, appErr := ctrl.SetStream(ctx, st.ID, models.StreamUpdate{Config: map[string]interface{}{"shuffle": true}}); appErr != nil {
+		t.Error(appErr)
+	}
+
+	// A key left over from an older config doesn't block edits and can be deleted
+	incoming := ctrl.State()
+	incoming.Streams = append(incoming.Streams, models.Stream{
+		ID: 1100, Name: "Radio", Type: "internetradio",
+		Config: map[string]interface{}{"url": "http://example.com", "volume": 80},
+	})
+	if _, appErr := ctrl.LoadConfig(ctx, incoming); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetStream(ctx, 1100, models.StreamUpdate{Config: map[string]interface{}{"logo": "http://example.com/logo.png"}}); appErr != nil {
+		t.Errorf("edit blocked by a stale key: %v", appErr)
+	}
+	if _, appErr := ctrl.SetStream(ctx, 1100, models.StreamUpdate{Config: map[string]interface{}{"volume": nil, "logo": nil}}); appErr != nil {
+		t.Errorf("deleting keys: %v", appErr)
+	}
+	if s, _ := ctrl.GetStream(1100); len(s.Config) != 1 || s.Config["url"] != "http://example.com" {
+		t.Errorf("config after deleting keys = %v", s.Config)
+	}
+	if _, appErr := ctrl.SetStream(ctx, 1100, models.StreamUpdate{Config: map[string]interface{}{"url": nil}}); appErr == nil || !strings.Contains(appErr.Message, `required key "url" can't be deleted`) {
+		t.Errorf("deleting a required key: %v", appErr)
+	}
+}
+
+func TestAirPlayGroups(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	var ids []int
+	for _, name := range []string{"Kitchen AirPlay", "Patio AirPlay", "Den AirPlay"} {
+		state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: name, Type: models.StreamTypeAirPlay})
+		if appErr != nil {
+			t.Fatal(appErr)
+		}
+		ids = append(ids, state.Streams[len(state.Streams)-1].ID)
+	}
+	playing := func(dacp string) models.StreamInfo {
+		return models.StreamInfo{State: "playing", AirPlayActive: true, AirPlay: &models.AirPlaySession{Client: "iPhone", DACPID: dacp}}
+	}
+	ctrl.UpdateStreamInfo(ids[0], playing("D1"))
+	ctrl.UpdateStreamInfo(ids[1], playing("D1"))
+	ctrl.UpdateStreamInfo(ids[2], playing("D2"))
+
+	group := func(id int) []int {
+		s, _ := ctrl.GetStream(id)
+		return s.Info.AirPlay.Group
+	}
+	if g := group(ids[0]); len(g) != 1 || g[0] != ids[1] {
+		t.Errorf("stream %d group = %v, want [%d]", ids[0], g, ids[1])
+	}
+	if g := group(ids[1]); len(g) != 1 || g[0] != ids[0] {
+		t.Errorf("stream %d group = %v, want [%d]", ids[1], g, ids[0])
+	}
+	if g := group(ids[2]); len(g) != 0 {
+		t.Errorf("another sender's stream is grouped: %v", g)
+	}
+
+	ctrl.UpdateStreamInfo(ids[1], models.StreamInfo{State: "connected"})
+	if g := group(ids[0]); len(g) != 0 {
+		t.Errorf("group after the other stream's sender left = %v", g)
+	}
+}
+
+func TestAirPlayNames(t *testing.T) {
+	ctx := context.Background()
+	mgr := streams.NewManager(t.TempDir(), nil)
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), events.NewBus(), mgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stop the streams before their config dir is removed.
+	t.Cleanup(func() {
+		ctrl.WaitStreamSyncs()
+		mgr.Shutdown(ctx)
+	})
+	browsed := 0
+	controller.SetAirPlayNetworkNames(t, func(context.Context) ([]string, error) {
+		browsed++
+		return []string{"Office", "Living Room"}, nil
+	})
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Kitchen", Type: models.StreamTypeAirPlay,
+		Config: map[string]interface{}{"device_name": "Kitchen Speakers", "password": "secret"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	id := state.Streams[len(state.Streams)-1].ID
+
+	// Another stream of this unit may not advertise the same name...
+	_, appErr = ctrl.CreateStream(ctx, models.StreamCreate{Name: "kitchen speakers", Type: models.StreamTypeAirPlay})
+	if appErr == nil || appErr.Status != http.StatusConflict || appErr.Field != "name" {
+		t.Errorf("duplicate of a local AirPlay name: %v", appErr)
+	}
+	// ...nor another receiver's on the network.
+	_, appErr = ctrl.SetStream(ctx, id, models.StreamUpdate{Config: map[string]interface{}{"device_name": "office"}})
+	if appErr == nil || appErr.Status != http.StatusConflict || appErr.Field != "device_name" {
+		t.Errorf("duplicate of a network AirPlay name: %v", appErr)
+	}
+
+	// Renaming the stream keeps the device name, so the network is not
+	// browsed again.
+	n := browsed
+	if _, appErr := ctrl.SetStream(ctx, id, models.StreamUpdate{Name: strPtr("Kitchen AirPlay")}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if browsed != n {
+		t.Error("network browsed for an unchanged AirPlay name")
+	}
+	// Other stream types may share names with AirPlay receivers.
+	if _, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Office", Type: models.StreamTypeAux}); appErr != nil {
+		t.Errorf("aux stream named like a receiver: %v", appErr)
+	}
+}
+
 func TestCreateGroup_MissingName(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
@@ -346,6 +510,113 @@ func TestCreatePreset_MissingName(t *testing.T) {
 	}
 }
 
+func TestNameValidation(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	state, appErr := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: strPtr("Upstairs"), ZoneIDs: []int{0}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	gid := state.Groups[len(state.Groups)-1].ID
+	if _, appErr := ctrl.CreatePreset(ctx, models.PresetCreate{Name: "Dinner"}); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	cases := []struct {
+		name   string
+		call   func() *models.AppError
+		status int
+	}{
+		{"blank zone", func() *models.AppError { _, e := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: strPtr("  ")}); return e }, 400},
+		{"long zone", func() *models.AppError {
+			_, e := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: strPtr(strings.Repeat("x", models.MaxNameLength+1))})
+			return e
+		}, 400},
+		{"control char", func() *models.AppError {
+			_, e := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: strPtr("Den\n")})
+			return e
+		}, 400},
+		{"duplicate zone", func() *models.AppError {
+			other := ctrl.State().Zones[1].Name
+			_, e := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: strPtr(strings.ToUpper(other))})
+			return e
+		}, 409},
+		{"bulk rename", func() *models.AppError {
+			_, e := ctrl.SetZones(ctx, models.MultiZoneUpdate{ZoneIDs: []int{0, 1}, Update: models.ZoneUpdate{Name: strPtr("Same")}})
+			return e
+		}, 400},
+		{"duplicate group", func() *models.AppError {
+			_, e := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: strPtr("upstairs"), ZoneIDs: []int{1}})
+			return e
+		}, 409},
+		{"duplicate preset", func() *models.AppError {
+			_, e := ctrl.CreatePreset(ctx, models.PresetCreate{Name: "Dinner "})
+			return e
+		}, 409},
+		{"blank stream", func() *models.AppError {
+			_, e := ctrl.CreateStream(ctx, models.StreamCreate{Name: "", Type: models.StreamTypeAux})
+			return e
+		}, 400},
+	}
+	for _, tc := range cases {
+		appErr := tc.call()
+		if appErr == nil || appErr.Status != tc.status || appErr.Field != "name" {
+			t.Errorf("%s: got %+v, want %d on field name", tc.name, appErr, tc.status)
+		}
+	}
+
+	// Renaming to its own name, in another case, is fine.
+	if _, appErr := ctrl.SetGroup(ctx, gid, models.GroupUpdate{Name: strPtr("UPSTAIRS")}); appErr != nil {
+		t.Errorf("renaming group to itself: %v", appErr)
+	}
+
+	// A preset can't bring back invalid or duplicate names either.
+	intPtr := func(v int) *int { return &v }
+	zone1 := ctrl.State().Zones[1].Name
+	state, appErr = ctrl.CreatePreset(ctx, models.PresetCreate{Name: "Renames", State: &models.PresetState{
+		Zones:  []models.ZoneUpdate{{ID: intPtr(0), Name: strPtr(zone1), Vol: intPtr(-30)}},
+		Groups: []models.GroupUpdate{{ID: intPtr(gid), Name: strPtr("Den\n")}},
+	}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	state, report, appErr := ctrl.LoadPresetWithReport(ctx, state.Presets[len(state.Presets)-1].ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if report.Skipped != 2 {
+		t.Errorf("report = %+v, want both renames skipped", report)
+	}
+	if state.Zones[0].Name == zone1 || state.Zones[0].Vol == -30 {
+		t.Errorf("zone 0 = %+v, want the skipped update unapplied", state.Zones[0])
+	}
+	if g := state.Groups[len(state.Groups)-1]; g.Name != "UPSTAIRS" {
+		t.Errorf("group name = %q", g.Name)
+	}
+}
+
+func TestLoadConfig_DuplicateIDs(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	cfg := ctrl.State()
+	cfg.Zones = append(cfg.Zones, cfg.Zones[0])
+	cfg.Presets = []models.Preset{{ID: 5, Name: "a"}, {ID: 5, Name: "b"}}
+	_, appErr := ctrl.LoadConfig(ctx, cfg)
+	if appErr == nil || appErr.Status != 400 {
+		t.Fatalf("got %v, want 400", appErr)
+	}
+	want := []string{fmt.Sprintf("zones[%d].id", len(cfg.Zones)-1), "presets[1].id"}
+	var got []string
+	for _, f := range appErr.Fields {
+		got = append(got, f.Field)
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+}
+
 func TestDeleteGroup_NotFound(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
@@ -413,7 +684,7 @@ func TestSetStream(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
 
-	createState, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "MyStream", Type: "internet_radio"})
+	createState, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "MyStream", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
 	if appErr != nil {
 		t.Fatalf("CreateStream: %v", appErr)
 	}
@@ -446,7 +717,7 @@ func TestExecStreamCommand_Play(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
 
-	createState, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "PlayStream", Type: "internet_radio"})
+	createState, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "PlayStream", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
 	if appErr != nil {
 		t.Fatalf("CreateStream: %v", appErr)
 	}
@@ -481,6 +752,179 @@ func TestLoadPreset_NotFound(t *testing.T) {
 	}
 }
 
+func TestLoadPreset_Report(t *testing.T) {
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	evs := bus.SubscribeEvents("test")
+	defer bus.UnsubscribeEvents("test")
+
+	intPtr := func(v int) *int { return &v }
+	before := ctrl.State().Sources[0].Input
+	state, appErr := ctrl.CreatePreset(ctx, models.PresetCreate{Name: "Party", State: &models.PresetState{
+		Sources: []models.SourceUpdate{{ID: intPtr(0), Input: strPtr("stream=4242")}},
+		Zones:   []models.ZoneUpdate{{ID: intPtr(0), Vol: intPtr(-30)}, {ID: intPtr(99), Vol: intPtr(-30)}},
+	}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	pid := state.Presets[len(state.Presets)-1].ID
+
+	state, report, appErr := ctrl.LoadPresetWithReport(ctx, pid)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if report.Applied != 1 || report.Skipped != 2 || len(report.Items) != 3 {
+		t.Fatalf("report = %+v, want 1 applied and 2 skipped", report)
+	}
+	src := report.Items[0]
+	if src.Kind != "source" || src.Status != models.PresetItemSkipped || !strings.Contains(src.Reason, "stream 4242 does not exist") {
+		t.Errorf("source item = %+v", src)
+	}
+	if z := report.Items[2]; z.Kind != "zone" || z.Status != models.PresetItemSkipped || *z.ID != 99 {
+		t.Errorf("missing zone item = %+v", z)
+	}
+	if state.Sources[0].Input != before {
+		t.Errorf("source 0 input = %q, want unchanged %q", state.Sources[0].Input, before)
+	}
+	if state.Zones[0].Vol != -30 {
+		t.Errorf("zone 0 vol = %d, want -30", state.Zones[0].Vol)
+	}
+
+	for {
+		select {
+		case ev := <-evs:
+			if ev.Type != models.EventPresetLoaded {
+				continue
+			}
+			if got := ev.Data.(models.PresetReport); got.PresetID != pid || got.Skipped != 2 {
+				t.Errorf("preset_loaded data = %+v", got)
+			}
+			return
+		default:
+			t.Fatal("no preset_loaded event")
+		}
+	}
+}
+
+func TestLoadPreset_Commands(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Radio", Type: "internetradio", Config: map[string]interface{}{"url": "http://example.com"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	sid := state.Streams[len(state.Streams)-1].ID
+	state, appErr = ctrl.CreatePreset(ctx, models.PresetCreate{Name: "Radio on", Commands: []models.Command{
+		{Method: "POST", Endpoint: fmt.Sprintf("/api/streams/%d/play", sid)},
+		{Method: "PATCH", Endpoint: "/api/zones/0"},
+	}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	state, report, appErr := ctrl.LoadPresetWithReport(ctx, state.Presets[len(state.Presets)-1].ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if report.Applied != 1 || report.Skipped != 1 || !strings.Contains(report.Items[1].Reason, "unsupported command PATCH /api/zones/0") {
+		t.Errorf("report = %+v", report)
+	}
+	for _, st := range state.Streams {
+		if st.ID == sid && st.Info.State != "playing" {
+			t.Errorf("stream state = %q, want playing", st.Info.State)
+		}
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	intPtr := func(v int) *int { return &v }
+	muted := true
+
+	before := ctrl.State().Zones[0]
+	snap := ctrl.CreateSnapshot()
+	if snap.ID == "" || snap.Time.IsZero() {
+		t.Fatalf("snapshot = %+v", snap)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: intPtr(-20), Mute: &muted, SourceID: intPtr(1)}); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	state, report, appErr := ctrl.RestoreSnapshot(ctx, snap.ID)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	z := state.Zones[0]
+	if z.Vol != before.Vol || z.Mute != before.Mute || z.SourceID != before.SourceID {
+		t.Errorf("zone 0 = vol %d mute %v source %d, want vol %d mute %v source %d",
+			z.Vol, z.Mute, z.SourceID, before.Vol, before.Mute, before.SourceID)
+	}
+	if report.Skipped != 0 || report.Applied == 0 {
+		t.Errorf("report = %+v, want everything applied", report)
+	}
+
+	// Restoring again works; only the newest maxSnapshots are kept.
+	if _, _, appErr := ctrl.RestoreSnapshot(ctx, snap.ID); appErr != nil {
+		t.Errorf("second restore: %v", appErr)
+	}
+	for range 10 {
+		ctrl.CreateSnapshot()
+	}
+	if n := len(ctrl.GetSnapshots()); n != 10 {
+		t.Errorf("%d snapshots kept, want 10", n)
+	}
+	if _, _, appErr := ctrl.RestoreSnapshot(ctx, snap.ID); appErr == nil || appErr.Status != 404 {
+		t.Errorf("restore of evicted snapshot = %v, want 404", appErr)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	intPtr := func(v int) *int { return &v }
+
+	if _, _, appErr := ctrl.Undo(ctx); appErr == nil || appErr.Status != 409 {
+		t.Fatalf("undo with no changes = %v, want 409", appErr)
+	}
+
+	vol0 := ctrl.State().Zones[0].Vol
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: intPtr(-40)}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{Vol: intPtr(0)}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	revs := ctrl.GetRevisions()
+	if len(revs) != 2 || len(revs[1].Changes) != 1 || revs[1].Changes[0] != "zone 1" {
+		t.Fatalf("revisions = %+v, want 2, the last of zone 1", revs)
+	}
+
+	state, undone, appErr := ctrl.Undo(ctx)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if undone.ID != revs[1].ID || state.Zones[1].Vol == 0 || state.Zones[0].Vol != -40 {
+		t.Errorf("after undo: undone %+v, zone 0 vol %d, zone 1 vol %d", undone, state.Zones[0].Vol, state.Zones[1].Vol)
+	}
+	// The undo itself is not recorded: undoing again goes further back.
+	state, _, appErr = ctrl.Undo(ctx)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if state.Zones[0].Vol != vol0 {
+		t.Errorf("zone 0 vol = %d after second undo, want %d", state.Zones[0].Vol, vol0)
+	}
+	if n := len(ctrl.GetRevisions()); n != 0 {
+		t.Errorf("%d revisions left, want 0", n)
+	}
+}
+
 func TestGetInfo(t *testing.T) {
 	ctrl := newTestController(t)
 
@@ -770,7 +1214,7 @@ func TestExecStreamCommand_Pause(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
 
-	createState, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "PauseStream", Type: "internet_radio"})
+	createState, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "PauseStream", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
 	if appErr != nil {
 		t.Fatalf("CreateStream: %v", appErr)
 	}
@@ -799,7 +1243,7 @@ func TestExecStreamCommand_Stop(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
 
-	createState, _ := ctrl.CreateStream(ctx, models.StreamCreate{Name: "StopStream", Type: "internet_radio"})
+	createState, _ := ctrl.CreateStream(ctx, models.StreamCreate{Name: "StopStream", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
 	var sid int
 	for _, s := range createState.Streams {
 		if s.Name == "StopStream" {
@@ -824,7 +1268,7 @@ func TestExecStreamCommand_Unknown(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
 
-	createState, _ := ctrl.CreateStream(ctx, models.StreamCreate{Name: "CmdStream", Type: "internet_radio"})
+	createState, _ := ctrl.CreateStream(ctx, models.StreamCreate{Name: "CmdStream", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
 	var sid int
 	for _, s := range createState.Streams {
 		if s.Name == "CmdStream" {
@@ -904,6 +1348,41 @@ func TestSetZone_VolDeltaF(t *testing.T) {
 	}
 }
 
+func TestSetZone_VolDelta(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	vol := -40
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol})
+	delta := 6
+	state, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{VolDelta: &delta})
+	if appErr != nil {
+		t.Fatalf("SetZone with VolDelta: %v", appErr)
+	}
+	if state.Zones[0].Vol != -34 {
+		t.Errorf("zone 0 vol after VolDelta(6) = %d, want -34", state.Zones[0].Vol)
+	}
+}
+
+func TestSetGroup_VolDeltaF(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	state, appErr := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: strPtr("g"), ZoneIDs: []int{0, 1}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	gid := state.Groups[len(state.Groups)-1].ID
+	delta := 0.5
+	state, appErr = ctrl.SetGroup(ctx, gid, models.GroupUpdate{VolDeltaF: &delta})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if state.Zones[0].Vol != -40 || state.Zones[1].Vol != -40 {
+		t.Errorf("zone vols = %d, %d, want -40", state.Zones[0].Vol, state.Zones[1].Vol)
+	}
+}
+
 func TestSetGroup_ZoneIDs(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
@@ -965,3 +1444,1021 @@ func TestLoadPreset_WithSources(t *testing.T) {
 		t.Errorf("after source preset: sources[0].input = %q, want local", loadedState.Sources[0].Input)
 	}
 }
+
+func TestSnapcast_GroupSourceAndClientLatency(t *testing.T) {
+	var mu sync.Mutex
+	var methods []string
+	params := map[string]map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string                 `json:"method"`
+			Params map[string]interface{} `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Method == "Server.GetStatus" {
+			_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"server":{"groups":[{"id":"g1","stream_id":"default","clients":[{"id":"c1","connected":true,"config":{"latency":0,"volume":{"percent":50,"muted":true}}}]}],"streams":[]}}}`))
+			return
+		}
+		mu.Lock()
+		methods = append(methods, req.Method)
+		params[req.Method] = req.Params
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{}}`))
+	}))
+	defer srv.Close()
+
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	if _, appErr := ctrl.GetSnapcast(ctx); appErr == nil || appErr.Status != 400 {
+		t.Fatalf("GetSnapcast without client: got %v, want 400", appErr)
+	}
+	ctrl.SetSnapcast(snapcast.NewClient(srv.URL))
+
+	src := 1
+	if _, appErr := ctrl.SetSnapGroup(ctx, "g1", models.SnapGroupUpdate{SourceID: &src}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("SetSnapGroup to source without snapcast: got %v, want 400", appErr)
+	}
+	if _, appErr := ctrl.SetSource(ctx, src, models.SourceUpdate{Snapcast: &models.SnapcastOutput{Enabled: true}}); appErr != nil {
+		t.Fatalf("SetSource snapcast: %v", appErr)
+	}
+	if _, appErr := ctrl.SetSnapGroup(ctx, "g1", models.SnapGroupUpdate{SourceID: &src}); appErr != nil {
+		t.Fatalf("SetSnapGroup: %v", appErr)
+	}
+	if _, appErr := ctrl.SetSnapGroup(ctx, "nope", models.SnapGroupUpdate{SourceID: &src}); appErr == nil || appErr.Status != 404 {
+		t.Errorf("SetSnapGroup unknown group: got %v, want 404", appErr)
+	}
+
+	latency, vol := 120, 80
+	if _, appErr := ctrl.SetSnapClient(ctx, "c1", models.SnapClientUpdate{Latency: &latency, Volume: &vol}); appErr != nil {
+		t.Fatalf("SetSnapClient: %v", appErr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(methods) != 3 || methods[0] != "Group.SetStream" || methods[1] != "Client.SetLatency" || methods[2] != "Client.SetVolume" {
+		t.Fatalf("methods = %v", methods)
+	}
+	if params["Group.SetStream"]["stream_id"] != "amplipi-src1" {
+		t.Errorf("Group.SetStream params = %v", params["Group.SetStream"])
+	}
+	// Mute was not in the update, so the client's current mute is preserved.
+	volume := params["Client.SetVolume"]["volume"].(map[string]interface{})
+	if volume["percent"] != float64(80) || volume["muted"] != true {
+		t.Errorf("Client.SetVolume volume = %v", volume)
+	}
+}
+
+func TestCast_SourceRoutingAndAnnounceValidation(t *testing.T) {
+	orig := cast.Binary
+	cast.Binary = "true" // don't reach for real devices from the background sync
+	t.Cleanup(func() { cast.Binary = orig })
+
+	ctrl := newTestController(t)
+	t.Cleanup(ctrl.WaitCastSyncs)
+	ctx := context.Background()
+	route := &models.CastOutput{Enabled: true, Devices: []string{"kitchen"}}
+
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Cast: route}); appErr == nil || appErr.Status != 400 {
+		t.Fatalf("SetSource cast without browser: got %v, want 400", appErr)
+	}
+
+	b := cast.NewBrowser()
+	b.Add(cast.Device{ID: "kitchen", Name: "Kitchen", Addr: "127.0.0.1", Port: 8009})
+	b.Add(cast.Device{ID: "patio", Name: "Patio", Addr: "127.0.0.1", Port: 8009})
+	if err := ctrl.SetCast(b, 8080, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+
+	bad := 101
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Cast: &models.CastOutput{Enabled: true, Volume: &bad}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("SetSource cast volume 101: got %v, want 400", appErr)
+	}
+	state, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Cast: route})
+	if appErr != nil {
+		t.Fatalf("SetSource cast: %v", appErr)
+	}
+	if got := state.Sources[0].Cast; got == nil || !got.Enabled || len(got.Devices) != 1 {
+		t.Errorf("source 0 cast = %+v", got)
+	}
+
+	devices := ctrl.GetCastDevices()
+	if len(devices) != 2 {
+		t.Fatalf("GetCastDevices = %d devices, want 2", len(devices))
+	}
+	if devices[0].ID != "kitchen" || devices[0].SourceID == nil || *devices[0].SourceID != 0 {
+		t.Errorf("kitchen = %+v, want routed to source 0", devices[0])
+	}
+	if devices[1].SourceID != nil {
+		t.Errorf("patio = %+v, want unrouted", devices[1])
+	}
+
+	if _, appErr := ctrl.Announce(ctx, models.AnnounceRequest{Media: "http://x/a.mp3", Cast: []string{"garage"}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("Announce to unknown cast device: got %v, want 400", appErr)
+	}
+
+	if ctrl.VerifyCastToken("") || ctrl.VerifyCastToken("nope") {
+		t.Error("VerifyCastToken accepted a bad token")
+	}
+}
+
+func TestCast_RestoredAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "cast.log")
+	bin := filepath.Join(dir, "go-chromecast")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho \"$@\" >> "+log+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orig := cast.Binary
+	cast.Binary = bin
+	t.Cleanup(func() { cast.Binary = orig })
+
+	ctx := context.Background()
+	store := config.NewMemStore()
+	ctrl, err := controller.New(hardware.NewMock(), nil, store, events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(ctrl.WaitCastSyncs)
+	if err := ctrl.SetCast(cast.NewBrowser(), 8080, dir); err != nil {
+		t.Fatal(err)
+	}
+	vol := 30
+	if _, appErr := ctrl.SetSource(ctx, 1, models.SourceUpdate{Cast: &models.CastOutput{Enabled: true, Devices: []string{"kitchen"}, Volume: &vol}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	token, err := os.ReadFile(filepath.Join(dir, "cast-token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// After a restart the token is the same and the device, once
+	// discovered, is loaded with the source again.
+	restarted, err := controller.New(hardware.NewMock(), nil, store, events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(restarted.WaitCastSyncs)
+	b := cast.NewBrowser()
+	if err := restarted.SetCast(b, 8080, dir); err != nil {
+		t.Fatal(err)
+	}
+	if !restarted.VerifyCastToken(strings.TrimSpace(string(token))) {
+		t.Error("cast token changed across a restart")
+	}
+	done := make(chan struct{})
+	go func() {
+		restarted.RestoreCast(ctx, 10*time.Millisecond)
+		close(done)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	b.Add(cast.Device{ID: "kitchen", Name: "Kitchen", Addr: "127.0.0.1", Port: 8009})
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("RestoreCast did not finish once the device was found")
+	}
+	out, _ := os.ReadFile(log)
+	if want := "/cast/sources/1.mp3?token=" + strings.TrimSpace(string(token)); !strings.Contains(string(out), want) || !strings.Contains(string(out), "volume 0.30") {
+		t.Errorf("cast commands:\n%s\nwant a load of %s and the volume", out, want)
+	}
+}
+
+func TestCast_FollowsLatestRouting(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "cast.log")
+	bin := filepath.Join(dir, "go-chromecast")
+	if err := os.WriteFile(bin, []byte("#!/bin/sh\necho \"$1\" >> "+log+"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orig := cast.Binary
+	cast.Binary = bin
+	t.Cleanup(func() { cast.Binary = orig })
+
+	ctrl := newTestController(t)
+	t.Cleanup(ctrl.WaitCastSyncs)
+	ctx := context.Background()
+	b := cast.NewBrowser()
+	b.Add(cast.Device{ID: "kitchen", Name: "Kitchen", Addr: "127.0.0.1", Port: 8009})
+	if err := ctrl.SetCast(b, 8080, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Quick changes end with the device doing what the last one asked.
+	on := &models.CastOutput{Enabled: true, Devices: []string{"kitchen"}}
+	off := &models.CastOutput{Devices: []string{"kitchen"}}
+	for i := 0; i < 3; i++ {
+		ctrl.SetSource(ctx, 0, models.SourceUpdate{Cast: on})
+		ctrl.SetSource(ctx, 0, models.SourceUpdate{Cast: off})
+	}
+	ctrl.WaitCastSyncs()
+	out, _ := os.ReadFile(log)
+	if lines := strings.Fields(string(out)); len(lines) > 0 && lines[len(lines)-1] != "stop" {
+		t.Errorf("cast commands = %q, want the device stopped last", lines)
+	}
+	if devices := ctrl.GetCastDevices(); devices[0].SourceID != nil {
+		t.Errorf("kitchen = %+v, want unrouted", devices[0])
+	}
+}
+
+func TestAnnounce_NetworkOutputValidation(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":{"server":{"groups":[{"id":"g1","stream_id":"amplipi-src0","clients":[{"id":"c1","connected":true,"config":{"latency":0,"volume":{"percent":50}}}]}],"streams":[{"id":"amplipi-src0","status":"idle"}]}}}`))
+	}))
+	defer srv.Close()
+
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	announce := func(req models.AnnounceRequest) *models.AppError {
+		req.Media = "http://x/a.mp3"
+		_, appErr := ctrl.Announce(ctx, req)
+		return appErr
+	}
+	big, neg := 20000, -1
+
+	cases := []struct {
+		name string
+		req  models.AnnounceRequest
+	}{
+		{"unknown type", models.AnnounceRequest{Outputs: []models.AnnounceOutput{{Type: "sonos", ID: "x"}}}},
+		{"missing id", models.AnnounceRequest{Outputs: []models.AnnounceOutput{{Type: "airplay"}}}},
+		{"latency too long", models.AnnounceRequest{Outputs: []models.AnnounceOutput{{Type: "airplay", ID: "10.0.0.9", LatencyMS: &big}}}},
+		{"negative zone latency", models.AnnounceRequest{ZoneLatencyMS: &neg}},
+		{"snapcast unavailable", models.AnnounceRequest{Outputs: []models.AnnounceOutput{{Type: "snapcast", ID: "c1"}}}},
+	}
+	for _, tc := range cases {
+		if appErr := announce(tc.req); appErr == nil || appErr.Status != 400 {
+			t.Errorf("%s: got %v, want 400", tc.name, appErr)
+		}
+	}
+
+	// snapserver is running but has no announcement stream
+	ctrl.SetSnapcast(snapcast.NewClient(srv.URL))
+	if appErr := announce(models.AnnounceRequest{Outputs: []models.AnnounceOutput{{Type: "snapcast", ID: "c1"}}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("snapcast without announce stream: got %v, want 400", appErr)
+	}
+}
+
+func TestAnnounce_DuckValidation(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	big := 90
+
+	for _, req := range []models.AnnounceRequest{
+		{Media: "http://x/a.mp3", Mode: "mix"},
+		{Media: "http://x/a.mp3", Mode: models.AnnounceDuck, DuckDB: &big},
+	} {
+		if _, appErr := ctrl.Announce(ctx, req); appErr == nil || appErr.Status != 400 {
+			t.Errorf("mode %q duck_db %v: got %v, want 400", req.Mode, req.DuckDB, appErr)
+		}
+	}
+	for _, p := range ctrl.State().Presets {
+		if p.ID == controller.ANNOUNCE_RESTORE_PRESET_ID {
+			t.Error("rejected announcement saved the state")
+		}
+	}
+}
+
+// useFakeBinaries makes streams run the fake binaries from the streams
+// package's testdata instead of vlc, ffmpeg, amixer and the rest.
+func useFakeBinaries(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the fake binaries")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not in PATH")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command(gobin, "build", "-o", filepath.Join(dir, streams.FakeBinary), "../streams/testdata/fakebin").CombinedOutput(); err != nil {
+		t.Fatalf("building fakebin: %v: %s", err, out)
+	}
+	t.Setenv("PATH", os.Getenv("PATH"))
+	t.Setenv("FAKEBIN_PLAY_SECONDS", "0.2")
+	if err := streams.UseTestBinaries(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// newRadioController returns a controller whose zone 0 plays a radio
+// stream on source 0 through fake stream binaries, the stream's input and
+// the number of streams.
+func newRadioController(t *testing.T) (*controller.Controller, string, int) {
+	t.Helper()
+	useFakeBinaries(t)
+	ctx := context.Background()
+	mgr := streams.NewManager(t.TempDir(), nil)
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), events.NewBus(), mgr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		ctrl.WaitStreamSyncs()
+		mgr.Shutdown(ctx)
+	})
+
+	// Zone 0 plays a radio stream on source 0.
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Radio", Type: models.StreamTypeInternetRadio,
+		Config: map[string]interface{}{"url": "http://radio.example/stream.mp3"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	nStreams := len(state.Streams)
+	input := fmt.Sprintf("stream=%d", state.Streams[nStreams-1].ID)
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	src, unmuted := 0, false
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{SourceID: &src, Mute: &unmuted}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	ctrl.WaitStreamSyncs()
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		if _, ok := mgr.SourceCaptureDevice(0); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("stream never connected to source 0")
+		}
+	}
+	return ctrl, input, nStreams
+}
+
+func TestAnnounce_Duck(t *testing.T) {
+	amixerLog := filepath.Join(t.TempDir(), "amixer.log")
+	t.Setenv("FAKEBIN_AMIXER_LOG", amixerLog)
+	ctrl, input, nStreams := newRadioController(t)
+	ctx := context.Background()
+
+	media := filepath.Join(t.TempDir(), "chime.mp3")
+	if err := os.WriteFile(media, []byte("mp3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	duck := 20
+	state, appErr := ctrl.Announce(ctx, models.AnnounceRequest{Media: media, Mode: models.AnnounceDuck, DuckDB: &duck, Zones: []int{0}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	// The music kept playing, turned down to -20 dB and back up.
+	if state.Sources[0].Input != input || state.Zones[0].SourceID != 0 || len(state.Streams) != nStreams {
+		t.Errorf("after a ducked announcement: source 0 %q, zone 0 on %d, %d streams", state.Sources[0].Input, state.Zones[0].SourceID, len(state.Streams))
+	}
+	out, err := os.ReadFile(amixerLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := fmt.Sprintf("-q -c sndrpihifiberry cset name=Ch0 Duck %d\n-q -c sndrpihifiberry cset name=Ch0 Duck %d\n",
+		audio.DuckLevel(0.1), audio.DuckLevel(1))
+	if string(out) != want {
+		t.Errorf("amixer calls:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestAnnounce_NetworkOutputsOutliveRequest(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "cast.log")
+	bin := filepath.Join(dir, "go-chromecast")
+	script := "#!/bin/sh\nif [ \"$1\" = load ]; then sleep 2; fi\necho \"$1\" >> " + log + "\n"
+	if err := os.WriteFile(bin, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	orig := cast.Binary
+	cast.Binary = bin
+	t.Cleanup(func() { cast.Binary = orig })
+
+	ctrl, _, _ := newRadioController(t)
+	t.Cleanup(ctrl.WaitCastSyncs)
+	b := cast.NewBrowser()
+	b.Add(cast.Device{ID: "kitchen", Name: "Kitchen", Addr: "127.0.0.1", Port: 8009})
+	if err := ctrl.SetCast(b, 8080, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	media := filepath.Join(t.TempDir(), "chime.mp3")
+	if err := os.WriteFile(media, []byte("mp3"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The request ends while the Cast device is still loading the
+	// announcement; it plays out and the device is put back afterwards.
+	ctx, cancel := context.WithCancel(context.Background())
+	zero := 0
+	_, appErr := ctrl.Announce(ctx, models.AnnounceRequest{Media: media, Mode: models.AnnounceDuck, Zones: []int{0},
+		Outputs: []models.AnnounceOutput{{Type: "cast", ID: "kitchen", LatencyMS: &zero}}})
+	cancel()
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if out, _ := os.ReadFile(log); strings.Contains(string(out), "load\n") {
+		t.Error("Announce waited for the Cast device")
+	}
+	for deadline := time.Now().Add(10 * time.Second); ; time.Sleep(20 * time.Millisecond) {
+		out, _ := os.ReadFile(log)
+		if strings.Contains(string(out), "load\n") && strings.Contains(string(out), "stop\n") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("cast commands after the request ended:\n%s\nwant the load to finish and the device to stop", out)
+		}
+	}
+}
+
+func TestIdentifyZone_RemovesTone(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	ctrl := newTestController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel() // fail the announcement once the tone is written
+	_, _ = ctrl.IdentifyZone(ctx, 0, models.ZoneIdentify{})
+	if left, _ := filepath.Glob(filepath.Join(tmp, "*.wav")); len(left) != 0 {
+		t.Errorf("test tone left behind: %v", left)
+	}
+}
+
+func TestAnnounce_MediaValidation(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	for _, media := range []string{srv.URL + "/chime.mp3", filepath.Join(t.TempDir(), "chime.mp3")} {
+		_, appErr := ctrl.Announce(ctx, models.AnnounceRequest{Media: media})
+		if appErr == nil || appErr.Status != 400 || appErr.Field != "media" {
+			t.Errorf("media %s: got %v, want 400 on media", media, appErr)
+		}
+	}
+	for _, p := range ctrl.State().Presets {
+		if p.ID == controller.ANNOUNCE_RESTORE_PRESET_ID {
+			t.Error("rejected announcement saved the state")
+		}
+	}
+}
+
+func TestSetSource_Processing(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Processing: &models.AudioProcessing{Balance: -150}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("balance -150: got %v, want 400", appErr)
+	}
+
+	state, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Processing: &models.AudioProcessing{Mono: true, Balance: 20}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if p := state.Sources[0].Processing; p == nil || !p.Mono || p.Balance != 20 {
+		t.Errorf("processing = %+v", p)
+	}
+
+	// Other updates leave processing alone; the default clears it.
+	state, _ = ctrl.SetSource(ctx, 0, models.SourceUpdate{Name: strPtr("Kitchen")})
+	if state.Sources[0].Processing == nil {
+		t.Error("processing lost on rename")
+	}
+	state, _ = ctrl.SetSource(ctx, 0, models.SourceUpdate{Processing: &models.AudioProcessing{}})
+	if state.Sources[0].Processing != nil {
+		t.Errorf("default processing stored as %+v", state.Sources[0].Processing)
+	}
+}
+
+func TestImportConfig(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "KEXP", Type: models.StreamTypeInternetRadio,
+		Config: map[string]interface{}{"url": "http://old"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	kexp := state.Streams[len(state.Streams)-1].ID
+
+	// From another unit: KEXP under another ID and a new stream taking
+	// KEXP's ID here, with a preset playing it.
+	input, src := fmt.Sprintf("stream=%d", kexp), 0
+	state, appErr = ctrl.ImportConfig(ctx, models.ConfigImport{
+		Streams: []models.Stream{
+			{ID: kexp + 5, Name: "kexp", Type: "internet_radio", Config: map[string]interface{}{"url": "http://new"}},
+			{ID: kexp, Name: "Jazz", Type: models.StreamTypeInternetRadio, Config: map[string]interface{}{"url": "http://jazz"}},
+		},
+		Presets: []models.Preset{{ID: 1, Name: "Jazz", State: &models.PresetState{
+			Sources: []models.SourceUpdate{{ID: &src, Input: &input}},
+		}}},
+	})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	stream := func(name string) *models.Stream {
+		for _, st := range state.Streams {
+			if st.Name == name {
+				return &st
+			}
+		}
+		return nil
+	}
+	if st := stream("kexp"); st == nil || st.ID != kexp || st.Config["url"] != "http://new" {
+		t.Errorf("merged KEXP = %+v", st)
+	}
+	jazz := stream("Jazz")
+	if jazz == nil || jazz.ID == kexp {
+		t.Fatalf("imported Jazz = %+v", jazz)
+	}
+	p := state.Presets[len(state.Presets)-1]
+	if p.Name != "Jazz" || *p.State.Sources[0].Input != fmt.Sprintf("stream=%d", jazz.ID) {
+		t.Errorf("imported preset = %+v", p)
+	}
+
+	if _, appErr := ctrl.ImportConfig(ctx, models.ConfigImport{Mode: "append"}); appErr == nil || appErr.Field != "mode" {
+		t.Errorf("unknown mode: got %v", appErr)
+	}
+	_, appErr = ctrl.ImportConfig(ctx, models.ConfigImport{Streams: []models.Stream{{Name: "x", Type: models.StreamTypeInternetRadio}}})
+	if appErr == nil || len(appErr.Fields) != 1 || appErr.Fields[0].Field != "streams[0].config" {
+		t.Errorf("missing url: got %+v", appErr)
+	}
+
+	// Replace keeps the built-in streams.
+	state, appErr = ctrl.ImportConfig(ctx, models.ConfigImport{Mode: models.ImportReplace, Streams: []models.Stream{}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, st := range state.Streams {
+		if st.ID > models.RCAStream3 {
+			t.Errorf("stream %+v left after replace", st)
+		}
+	}
+	if stream("Aux") == nil {
+		t.Error("replace removed the aux stream")
+	}
+
+	// System presets such as the party restore point are never exported.
+	if _, appErr := ctrl.StartParty(ctx, models.PartyRequest{SourceID: 0, Zones: []int{0}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, p := range ctrl.ExportConfig(false, true).Presets {
+		if models.ReservedPresetID(p.ID) {
+			t.Errorf("system preset %+v exported", p)
+		}
+	}
+}
+
+func TestZoneVolLimits(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	intPtr := func(v int) *int { return &v }
+
+	cases := []struct {
+		name  string
+		upd   models.ZoneUpdate
+		field string
+	}{
+		{"min below range", models.ZoneUpdate{VolMin: intPtr(-90)}, "vol_min"},
+		{"max above range", models.ZoneUpdate{VolMax: intPtr(6)}, "vol_max"},
+		{"min above max", models.ZoneUpdate{VolMin: intPtr(-10), VolMax: intPtr(-20)}, "vol_min"},
+		{"max below min", models.ZoneUpdate{VolMax: intPtr(-80)}, "vol_max"},
+	}
+	for _, tc := range cases {
+		if _, appErr := ctrl.SetZone(ctx, 0, tc.upd); appErr == nil || appErr.Status != 400 || appErr.Field != tc.field {
+			t.Errorf("%s: got %+v, want 400 on %s", tc.name, appErr, tc.field)
+		}
+	}
+
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: intPtr(-10)}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	state, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{VolMax: intPtr(-30)})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if z := state.Zones[0]; z.VolMax != -30 || z.Vol != -30 {
+		t.Errorf("zone after lowering vol_max = %+v, want vol -30", z)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{Vol: intPtr(-30)}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if a, b := hw.GetReg(0, hardware.VolZoneReg(0)), hw.GetReg(0, hardware.VolZoneReg(1)); a != b {
+		t.Errorf("re-clamped volume register %#x, want %#x", a, b)
+	}
+}
+
+func TestNestedGroups(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	create := func(req models.GroupUpdate) int {
+		t.Helper()
+		state, appErr := ctrl.CreateGroup(ctx, req)
+		if appErr != nil {
+			t.Fatal(appErr)
+		}
+		return state.Groups[len(state.Groups)-1].ID
+	}
+	kitchen := create(models.GroupUpdate{Name: strPtr("Kitchen"), ZoneIDs: []int{0, 1}})
+	living := create(models.GroupUpdate{Name: strPtr("Living Room"), ZoneIDs: []int{1, 2}})
+	down := create(models.GroupUpdate{Name: strPtr("Downstairs"), GroupIDs: []int{kitchen, living}, ExcludeZoneIDs: []int{0}})
+	house := create(models.GroupUpdate{Name: strPtr("House"), ZoneIDs: []int{3}, GroupIDs: []int{down}})
+
+	// Volume, mute and source reach the resolved zones 1-3 only.
+	vol, mute, src := 0.5, false, 2
+	state, appErr := ctrl.SetGroup(ctx, house, models.GroupUpdate{VolF: &vol, Mute: &mute, SourceID: &src})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, z := range state.Zones[:5] {
+		in := z.ID >= 1 && z.ID <= 3
+		if in != (z.SourceID == 2 && !z.Mute) {
+			t.Errorf("zone %d = source %d mute %v; in group: %v", z.ID, z.SourceID, z.Mute, in)
+		}
+	}
+
+	cases := []struct {
+		name   string
+		id     int
+		groups []int
+	}{
+		{"self", down, []int{down}},
+		{"cycle", kitchen, []int{house}},
+		{"missing", kitchen, []int{999}},
+	}
+	for _, tc := range cases {
+		if _, appErr := ctrl.SetGroup(ctx, tc.id, models.GroupUpdate{GroupIDs: tc.groups}); appErr == nil || appErr.Field != "groups" {
+			t.Errorf("%s: got %v, want 400 on groups", tc.name, appErr)
+		}
+	}
+
+	// Deleting a nested group removes it from the groups containing it.
+	state, appErr = ctrl.DeleteGroup(ctx, down)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, g := range state.Groups {
+		if g.ID == house && len(g.GroupIDs) != 0 {
+			t.Errorf("house still nests %v", g.GroupIDs)
+		}
+	}
+}
+
+func TestSetHostname(t *testing.T) {
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	evs := bus.SubscribeEvents("test")
+	defer bus.UnsubscribeEvents("test")
+
+	var set []string
+	controller.SetOSHostname(t, func(ctx context.Context, name string) error {
+		set = append(set, name)
+		return nil
+	})
+
+	for _, bad := range []string{"", "Upstairs", "amplipi upstairs", "-amplipi", "amplipi-", strings.Repeat("a", 64)} {
+		if _, appErr := ctrl.SetHostname(ctx, models.HostnameUpdate{Hostname: &bad}); appErr == nil || appErr.Field != "hostname" {
+			t.Errorf("hostname %q: err = %v, want a hostname field error", bad, appErr)
+		}
+	}
+	if len(set) != 0 {
+		t.Fatalf("invalid hostnames set: %v", set)
+	}
+
+	info, appErr := ctrl.SetHostname(ctx, models.HostnameUpdate{Hostname: strPtr("amplipi-upstairs"), FriendlyName: strPtr("AmpliPi Upstairs")})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if info.Hostname != "amplipi-upstairs" || info.FriendlyName != "AmpliPi Upstairs" {
+		t.Errorf("info = %q, %q", info.Hostname, info.FriendlyName)
+	}
+	if len(set) != 1 || set[0] != "amplipi-upstairs" {
+		t.Errorf("OS hostname set to %v", set)
+	}
+	if got := ctrl.GetSettings().FriendlyName; got != "AmpliPi Upstairs" {
+		t.Errorf("settings friendly name = %q", got)
+	}
+	for done := false; !done; {
+		select {
+		case ev := <-evs:
+			if ev.Type != models.EventHostnameChanged {
+				continue
+			}
+			if c := ev.Data.(models.HostnameChanged); c.Hostname != "amplipi-upstairs" || c.FriendlyName != "AmpliPi Upstairs" {
+				t.Errorf("hostname_changed data = %+v", c)
+			}
+			done = true
+		default:
+			t.Fatal("no hostname_changed event")
+		}
+	}
+
+	// The same hostname is not set again; clearing the friendly name falls
+	// back to the hostname.
+	info, appErr = ctrl.SetHostname(ctx, models.HostnameUpdate{Hostname: strPtr("amplipi-upstairs"), FriendlyName: strPtr("")})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if len(set) != 1 || info.FriendlyName != "amplipi-upstairs" {
+		t.Errorf("set = %v, friendly name = %q", set, info.FriendlyName)
+	}
+
+	controller.SetOSHostname(t, func(ctx context.Context, name string) error {
+		return errors.New("permission denied")
+	})
+	if _, appErr := ctrl.SetHostname(ctx, models.HostnameUpdate{Hostname: strPtr("kitchen")}); appErr == nil || !strings.Contains(appErr.Message, "permission denied") {
+		t.Errorf("err = %v, want the setter's error", appErr)
+	}
+	if ctrl.Hostname() != "amplipi-upstairs" {
+		t.Errorf("hostname = %q after a failed rename", ctrl.Hostname())
+	}
+}
+
+func TestPower(t *testing.T) {
+	store := newMemStore()
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, nil, store, events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ctrl.SetClock(func() time.Time { return now })
+
+	var ran []string
+	controller.SetSystemPower(t, func(ctx context.Context, action string) error {
+		ran = append(ran, action)
+		return nil
+	})
+
+	if _, appErr := ctrl.Power(ctx, "hibernate", ""); appErr == nil {
+		t.Error("unknown action accepted")
+	}
+
+	// Without a token nothing happens but a token is handed out.
+	resp, appErr := ctrl.Power(ctx, models.PowerReboot, "")
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if resp.Status != "confirm" || resp.Confirm == "" || resp.Expires == nil || len(ran) != 0 {
+		t.Fatalf("unconfirmed: resp = %+v, ran = %v", resp, ran)
+	}
+
+	// A reboot token does not shut down, and a wrong one is refused.
+	for _, tc := range []struct{ action, token string }{
+		{models.PowerShutdown, resp.Confirm},
+		{models.PowerReboot, "guess"},
+	} {
+		if _, appErr := ctrl.Power(ctx, tc.action, tc.token); appErr == nil || appErr.Field != "confirm" {
+			t.Errorf("%s with %q: err = %v, want a confirm field error", tc.action, tc.token, appErr)
+		}
+	}
+
+	token := resp.Confirm
+	resp, appErr = ctrl.Power(ctx, models.PowerReboot, token)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if resp.Status != "rebooting" || len(ran) != 1 || ran[0] != models.PowerReboot {
+		t.Errorf("confirmed: resp = %+v, ran = %v", resp, ran)
+	}
+
+	// Tokens are single use and expire.
+	if _, appErr := ctrl.Power(ctx, models.PowerReboot, token); appErr == nil {
+		t.Error("token reused")
+	}
+	resp, _ = ctrl.Power(ctx, models.PowerShutdown, "")
+	now = now.Add(time.Minute)
+	if _, appErr := ctrl.Power(ctx, models.PowerShutdown, resp.Confirm); appErr == nil {
+		t.Error("expired token accepted")
+	}
+
+	// The amps are off before systemd takes over, and back on if it fails.
+	var ampsAtPower byte
+	controller.SetSystemPower(t, func(ctx context.Context, action string) error {
+		ampsAtPower, _ = hw.Read(ctx, 0, hardware.RegAmpEn)
+		return errors.New("interactive authentication required")
+	})
+	resp, _ = ctrl.Power(ctx, models.PowerShutdown, "")
+	if _, appErr := ctrl.Power(ctx, models.PowerShutdown, resp.Confirm); appErr == nil || !strings.Contains(appErr.Message, "interactive authentication") {
+		t.Errorf("err = %v, want systemctl's error", appErr)
+	}
+	if ampsAtPower != 0 {
+		t.Errorf("amp enables at poweroff = 0b%08b, want all off", ampsAtPower)
+	}
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegAmpEn); v == 0 {
+		t.Error("amps still off after a failed poweroff")
+	}
+}
+
+func TestSystemTime(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	ctrl.SetClock(func() time.Time { return time.Date(2026, 1, 15, 18, 0, 0, 0, time.UTC) })
+	local := time.Local
+
+	tz, ntp := "Etc/UTC", "no"
+	var calls []string
+	controller.SetTimedatectl(t, func(ctx context.Context, args ...string) (string, error) {
+		calls = append(calls, strings.Join(args, " "))
+		switch args[0] {
+		case "show":
+			return "Timezone=" + tz + "\nLocalRTC=no\nNTP=" + ntp + "\nNTPSynchronized=" + ntp + "\nRTCTimeUSec=n/a\n", nil
+		case "set-timezone":
+			tz = args[1]
+		case "set-ntp":
+			ntp = map[string]string{"true": "yes", "false": "no"}[args[1]]
+		case "list-timezones":
+			return "America/Chicago\nEtc/UTC\nEurope/Berlin\n", nil
+		}
+		return "", nil
+	})
+
+	status, appErr := ctrl.GetTime(ctx)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if status.Timezone != "Etc/UTC" || status.NTP || status.Synced || status.RTC {
+		t.Errorf("status = %+v", status)
+	}
+
+	for _, bad := range []string{"", "Local", "Mars/Olympus_Mons"} {
+		if _, appErr := ctrl.SetTime(ctx, models.TimeUpdate{Timezone: &bad}); appErr == nil || appErr.Field != "timezone" {
+			t.Errorf("timezone %q: err = %v, want a timezone field error", bad, appErr)
+		}
+	}
+	if len(calls) != 1 {
+		t.Fatalf("invalid timezones reached timedatectl: %v", calls)
+	}
+
+	on := true
+	status, appErr = ctrl.SetTime(ctx, models.TimeUpdate{Timezone: strPtr("America/Chicago"), NTP: &on})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if status.Timezone != "America/Chicago" || status.UTCOffset != "-06:00" || status.Time.Hour() != 12 || !status.NTP || !status.Synced {
+		t.Errorf("after update status = %+v", status)
+	}
+	if time.Local != local {
+		t.Errorf("process timezone changed to %s", time.Local)
+	}
+	// 18:00 UTC is noon in Chicago.
+	state, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Night: &models.NightMode{From: "11:00", To: "13:00", VolMax: -40}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if state.Zones[0].VolLimit == nil || *state.Zones[0].VolLimit != -40 {
+		t.Errorf("night mode limit = %v, want -40 in the new timezone", state.Zones[0].VolLimit)
+	}
+
+	zones, appErr := ctrl.Timezones(ctx)
+	if appErr != nil || len(zones) != 3 || zones[0] != "America/Chicago" {
+		t.Errorf("timezones = %v, %v", zones, appErr)
+	}
+
+	controller.SetTimedatectl(t, func(ctx context.Context, args ...string) (string, error) {
+		return "", errors.New("timedatectl show: exit status 1: System has not been booted with systemd")
+	})
+	if _, appErr := ctrl.GetTime(ctx); appErr == nil {
+		t.Error("timedatectl failure not reported")
+	}
+}
+
+func TestZoneCalibration(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	half := 0.5
+	for _, id := range []int{0, 1} {
+		if _, appErr := ctrl.SetZone(ctx, id, models.ZoneUpdate{VolF: &half}); appErr != nil {
+			t.Fatal(appErr)
+		}
+	}
+
+	// Zone 0 is 6 dB louder than zone 1 at the same volume.
+	if _, appErr := ctrl.SetZoneCalibration(ctx, 0, models.CalibrationMeasurement{SPL: 80}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	state, appErr := ctrl.SetZoneCalibration(ctx, 1, models.CalibrationMeasurement{SPL: 74})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if z := state.Zones[0]; z.Calibration.OffsetDB != -6 || z.Vol != -46 || z.VolF != half {
+		t.Errorf("zone 0: offset %d vol %d vol_f %g, want -6, -46, 0.5", z.Calibration.OffsetDB, z.Vol, z.VolF)
+	}
+	if z := state.Zones[1]; z.Calibration.OffsetDB != 0 || z.Vol != -40 {
+		t.Errorf("zone 1: offset %d vol %d, want 0, -40", z.Calibration.OffsetDB, z.Vol)
+	}
+
+	// A group of both is at their common vol_f, not that of their mean dB.
+	state, appErr = ctrl.CreateGroup(ctx, models.GroupUpdate{Name: strPtr("Both"), ZoneIDs: []int{0, 1}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if g := state.Groups[len(state.Groups)-1]; g.VolF == nil || *g.VolF != half {
+		t.Errorf("group vol_f = %v, want 0.5", g.VolF)
+	}
+
+	// vol_f maps through the offset; vol is the attenuation itself.
+	vol := -30
+	state, _ = ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol})
+	if got := state.Zones[0].VolF; got != 0.7 {
+		t.Errorf("zone 0 vol_f at -30 dB = %g, want 0.7", got)
+	}
+
+	// Alone, zone 0 has nothing to match.
+	state, appErr = ctrl.ClearZoneCalibration(ctx, 1)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if z := state.Zones[0]; z.Calibration.OffsetDB != 0 || z.Vol != -24 {
+		t.Errorf("zone 0 after clearing zone 1: offset %d vol %d, want 0, -24", z.Calibration.OffsetDB, z.Vol)
+	}
+	if state.Zones[1].Calibration != nil {
+		t.Error("zone 1 calibration not cleared")
+	}
+
+	loud := -100
+	for _, req := range []models.CalibrationMeasurement{{SPL: 0}, {SPL: 80, Vol: &loud}} {
+		if _, appErr := ctrl.SetZoneCalibration(ctx, 0, req); appErr == nil || appErr.Status != 400 {
+			t.Errorf("measurement %+v: got %v, want 400", req, appErr)
+		}
+	}
+	if _, appErr := ctrl.SetZoneCalibration(ctx, 99, models.CalibrationMeasurement{SPL: 80}); appErr == nil || appErr.Status != 404 {
+		t.Errorf("missing zone: got %v, want 404", appErr)
+	}
+}
+
+func TestAudioRouting(t *testing.T) {
+	ctx := context.Background()
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), events.NewBus(), streams.NewManager(t.TempDir(), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := ctrl.AudioRouting()
+	if !r.Consistent || len(r.Sources) != 4 || r.VSRCs == 0 || r.VSRCsUsed != 0 {
+		t.Fatalf("idle routing = %+v", r)
+	}
+
+	// The stream manager syncs in the background.
+	waitRouting := func(what string, ok func(models.AudioRouting) bool) models.AudioRouting {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			r := ctrl.AudioRouting()
+			if ok(r) {
+				return r
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("no %s: %+v", what, r)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	music := t.TempDir()
+	if err := os.WriteFile(filepath.Join(music, "a.mp3"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	prevMedia := streams.MediaDir()
+	streams.SetMediaDir(music)
+	t.Cleanup(func() { streams.SetMediaDir(prevMedia) })
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Files", Type: models.StreamTypeFileplayer,
+		Config: map[string]interface{}{"path": music}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	id := state.Streams[len(state.Streams)-1].ID
+	waitRouting("stream", func(r models.AudioRouting) bool {
+		return slices.ContainsFunc(r.Streams, func(rt models.StreamRoute) bool { return rt.ID == id })
+	})
+	if _, appErr := ctrl.SetSource(ctx, 2, models.SourceUpdate{Input: strPtr(fmt.Sprintf("stream=%d", id))}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	r = waitRouting("vsrc on source 2", func(r models.AudioRouting) bool { return r.Sources[2].VSRC != nil })
+	src := r.Sources[2]
+	vsrc := *src.VSRC
+	if src.StreamID == nil || *src.StreamID != id || src.Analog || r.VSRCsUsed != 1 {
+		t.Errorf("source 2 route = %+v", src)
+	}
+	if src.Device != fmt.Sprintf("lb%dc", vsrc) || src.Capture != fmt.Sprintf("lb%dp", vsrc) {
+		t.Errorf("source 2 devices = %q, %q", src.Device, src.Capture)
+	}
+	// Only ch0 exists here, so the stream falls back to it.
+	if src.Output != "ch0" || r.Consistent || len(r.Problems) != 1 || !strings.Contains(r.Problems[0], "output ch2 isn't present") {
+		t.Errorf("source 2 output %q, problems %q", src.Output, r.Problems)
+	}
+
+	// Aux is the preamp's analog input: no vsrc and nothing to check.
+	if _, appErr := ctrl.SetSource(ctx, 2, models.SourceUpdate{Input: strPtr(fmt.Sprintf("stream=%d", models.AuxStreamID))}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	r = waitRouting("aux on source 2", func(r models.AudioRouting) bool { return r.Consistent })
+	if src := r.Sources[2]; !src.Analog || src.VSRC != nil || src.Output != "" || r.VSRCsUsed != 0 {
+		t.Errorf("aux route = %+v, %d vsrcs used", src, r.VSRCsUsed)
+	}
+}
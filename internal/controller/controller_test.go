@@ -2,8 +2,16 @@ package controller_test
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/auth"
 	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/controller"
 	"github.com/micro-nova/amplipi-go/internal/events"
@@ -76,6 +84,36 @@ func TestSetSource(t *testing.T) {
 	}
 }
 
+func TestSetSourceRTP(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	state, appErr := ctrl.SetSource(ctx, 1, models.SourceUpdate{RTP: &models.RTPOutput{Enabled: true, Port: 5010}})
+	if appErr != nil {
+		t.Fatalf("SetSource rtp failed: %v", appErr)
+	}
+	rtp := state.Sources[1].RTP
+	if rtp == nil || !rtp.Enabled || rtp.Port != 5010 || rtp.Address != "239.69.0.2" {
+		t.Errorf("source rtp = %+v, want enabled with defaults filled", rtp)
+	}
+
+	sdp, appErr := ctrl.GetSourceSDP(1, "10.0.0.5")
+	if appErr != nil {
+		t.Fatalf("GetSourceSDP: %v", appErr)
+	}
+	if !strings.Contains(sdp, "m=audio 5010 RTP/AVP 96") {
+		t.Errorf("unexpected SDP:\n%s", sdp)
+	}
+	if _, appErr := ctrl.GetSourceSDP(0, "10.0.0.5"); appErr == nil || appErr.Status != 404 {
+		t.Errorf("GetSourceSDP without rtp: got %v, want 404", appErr)
+	}
+
+	_, appErr = ctrl.SetSource(ctx, 1, models.SourceUpdate{RTP: &models.RTPOutput{Enabled: true, Address: "nope"}})
+	if appErr == nil || appErr.Status != 400 {
+		t.Errorf("SetSource with invalid rtp address: got %v, want 400", appErr)
+	}
+}
+
 func TestSetSourceInvalidID(t *testing.T) {
 	ctrl := newTestController(t)
 	ctx := context.Background()
@@ -179,7 +217,7 @@ func TestFactoryReset(t *testing.T) {
 	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: &name})
 
 	// Reset
-	state, appErr := ctrl.FactoryReset(ctx)
+	state, appErr := ctrl.FactoryReset(ctx, models.ResetConfig)
 	if appErr != nil {
 		t.Fatalf("FactoryReset failed: %v", appErr)
 	}
@@ -187,3 +225,1307 @@ func TestFactoryReset(t *testing.T) {
 		t.Error("factory reset did not restore default zone name")
 	}
 }
+
+func TestProvision(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	ptr := func(v int) *int { return &v }
+	str := func(v string) *string { return &v }
+	tmpl := models.Provision{
+		Sources: []models.SourceUpdate{{ID: ptr(0), Name: str("Lobby Feed")}},
+		Zones: []models.ZoneUpdate{
+			{ID: ptr(0), Name: str("Lobby"), VolMax: ptr(-20)},
+			{ID: ptr(1), Name: str("Patio")},
+		},
+		Groups:  []models.GroupUpdate{{Name: str("Public"), ZoneIDs: []int{0, 1}}},
+		Streams: []models.StreamCreate{{Name: "House Radio", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}}},
+	}
+	check := func(state models.State) {
+		t.Helper()
+		if state.Sources[0].Name != "Lobby Feed" || state.Zones[0].Name != "Lobby" || state.Zones[1].Name != "Patio" {
+			t.Errorf("names not provisioned: source %q, zones %q, %q", state.Sources[0].Name, state.Zones[0].Name, state.Zones[1].Name)
+		}
+		if state.Zones[0].VolMax != -20 {
+			t.Errorf("zone 0 vol_max = %d, want -20", state.Zones[0].VolMax)
+		}
+		groups, streams := 0, 0
+		for _, g := range state.Groups {
+			if g.Name == "Public" {
+				groups++
+			}
+		}
+		for _, st := range state.Streams {
+			if st.Name == "House Radio" {
+				streams++
+			}
+		}
+		if groups != 1 || streams != 1 {
+			t.Errorf("got %d Public groups and %d House Radio streams, want one each", groups, streams)
+		}
+	}
+
+	state, appErr := ctrl.Provision(ctx, tmpl)
+	if appErr != nil {
+		t.Fatalf("Provision: %v", appErr)
+	}
+	check(state)
+	// Applying the template again matches groups and streams by name.
+	state, appErr = ctrl.Provision(ctx, tmpl)
+	if appErr != nil {
+		t.Fatalf("Provision again: %v", appErr)
+	}
+	check(state)
+
+	// Factory reset comes back to the template once it is the unit's.
+	ctrl.SetProvisionTemplate(&tmpl)
+	name := "Renamed"
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: &name})
+	state, appErr = ctrl.FactoryReset(ctx, models.ResetConfig)
+	if appErr != nil {
+		t.Fatalf("FactoryReset: %v", appErr)
+	}
+	check(state)
+
+	_, appErr = ctrl.Provision(ctx, models.Provision{Zones: []models.ZoneUpdate{{ID: ptr(1)}, {ID: ptr(0), VolMin: ptr(10)}}})
+	if appErr == nil || appErr.Status != 400 || !strings.HasPrefix(appErr.Field, "zones[1]") {
+		t.Errorf("invalid vol_min: err = %+v, want 400 on zones[1]", appErr)
+	}
+	_, appErr = ctrl.Provision(ctx, models.Provision{Zones: []models.ZoneUpdate{{Name: str("No ID")}}})
+	if appErr == nil || appErr.Field != "zones[0].id" {
+		t.Errorf("missing id: err = %+v, want field zones[0].id", appErr)
+	}
+}
+
+func TestZoneAmpPower(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ampOn := func(zone int) bool {
+		t.Helper()
+		if err := ctrl.FlushHardware(ctx); err != nil {
+			t.Fatal(err)
+		}
+		val, err := hw.Read(ctx, 0, hardware.RegAmpEn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return val&(1<<zone) != 0
+	}
+
+	// Auto mode: an idle zone that was never used is switched off.
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Amp: &models.AmpPower{Mode: models.AmpAuto}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if ampOn(0) || !ampOn(1) {
+		t.Errorf("amp enables after auto: zone0=%v zone1=%v", ampOn(0), ampOn(1))
+	}
+
+	// Using the zone switches it back on; it stays on through the idle timeout.
+	input := "local"
+	ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input})
+	mute := false
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute})
+	if !ampOn(0) {
+		t.Error("amp off while zone in use")
+	}
+	mute = true
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute})
+	if !ampOn(0) {
+		t.Error("amp off before idle timeout")
+	}
+
+	// Off hours switch an idle "always" zone off.
+	now := time.Now()
+	off := &models.AmpPower{OffFrom: now.Add(-time.Hour).Format("15:04"), OffTo: now.Add(time.Hour).Format("15:04")}
+	ctrl.SetZone(ctx, 1, models.ZoneUpdate{Amp: off})
+	if ampOn(1) {
+		t.Error("amp on during off hours")
+	}
+
+	// Back to the default clears the setting.
+	state, _ := ctrl.SetZone(ctx, 1, models.ZoneUpdate{Amp: &models.AmpPower{Mode: models.AmpAlways}})
+	if state.Zones[1].Amp != nil || !ampOn(1) {
+		t.Errorf("zone 1 amp = %+v, on = %v", state.Zones[1].Amp, ampOn(1))
+	}
+
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{Amp: &models.AmpPower{OffFrom: "22:00"}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("half-set off hours: %v", appErr)
+	}
+}
+
+func TestUnitPower(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, hardware.MockProfile(), newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ctrl.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+	powered := func() (amps byte, rails hardware.Power) {
+		t.Helper()
+		if err := ctrl.FlushHardware(ctx); err != nil {
+			t.Fatal(err)
+		}
+		amps, _ = hw.Read(ctx, 0, hardware.RegAmpEn)
+		rails, _ = hw.ReadPower(ctx, 0)
+		return amps, rails
+	}
+
+	// With unit power off the rails are left as they are.
+	hw.Write(ctx, 0, hardware.RegPower, hardware.PowerEN9V)
+	input := fmt.Sprintf("stream=%d", models.AuxStreamID)
+	mute := false
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute})
+	if amps, rails := powered(); amps == 0 || rails.EN12V {
+		t.Errorf("unit power off: amps %#x, rails %+v; want the 12V rail left off", amps, rails)
+	}
+	hw.Write(ctx, 0, hardware.RegPower, hardware.PowerEN9V|hardware.PowerEN12V)
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "playing"})
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{UnitPower: &models.UnitPowerSettings{Enabled: true, IdleMinutes: 10}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	// The stream stopping starts the idle time; the unit powers down
+	// once it is up, amps first and then the rails.
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "stopped"})
+	now = now.Add(9 * time.Minute)
+	ctrl.RefreshAmps()
+	if amps, rails := powered(); amps == 0 || !rails.EN12V {
+		t.Errorf("before idle timeout: amps %#x, rails %+v; want powered", amps, rails)
+	}
+	now = now.Add(2 * time.Minute)
+	ctrl.RefreshAmps()
+	if amps, rails := powered(); amps != 0 || rails.EN9V || rails.EN12V || rails.PG12V {
+		t.Errorf("after idle timeout: amps %#x, rails %+v; want all off", amps, rails)
+	}
+
+	// Playing again powers it straight back up.
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "playing"})
+	if amps, rails := powered(); amps != 0x3F || !rails.EN9V || !rails.PG9V || !rails.EN12V || !rails.PG12V {
+		t.Errorf("after playing: amps %#x, rails %+v; want all on", amps, rails)
+	}
+
+	// Turning unit power off powers idle units back up, rails included,
+	// and they stay powered.
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "stopped"})
+	now = now.Add(11 * time.Minute)
+	ctrl.RefreshAmps()
+	if amps, rails := powered(); amps != 0 || rails.EN12V {
+		t.Errorf("idle again: amps %#x, rails %+v; want all off", amps, rails)
+	}
+	ctrl.SetSettings(ctx, models.SettingsUpdate{UnitPower: &models.UnitPowerSettings{}})
+	if amps, rails := powered(); amps != 0x3F || !rails.EN9V || !rails.EN12V {
+		t.Errorf("unit power turned off: amps %#x, rails %+v; want all on", amps, rails)
+	}
+	now = now.Add(time.Hour)
+	ctrl.RefreshAmps()
+	if amps, rails := powered(); amps != 0x3F || !rails.EN12V {
+		t.Errorf("unit power off: amps %#x, rails %+v; want powered", amps, rails)
+	}
+
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{UnitPower: &models.UnitPowerSettings{IdleMinutes: -1}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("negative idle_minutes: %v", appErr)
+	}
+}
+
+func TestSilenceWatchdog(t *testing.T) {
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	ctrl.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+	peak, metered := math.Inf(-1), 0
+	ctrl.SetLevelMeter(func(ctx context.Context, sid int, d time.Duration) (float64, error) {
+		metered++
+		if sid != 0 {
+			return 0, fmt.Errorf("source %d has no stream", sid)
+		}
+		return peak, nil
+	})
+
+	input := fmt.Sprintf("stream=%d", models.AuxStreamID)
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "playing"})
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{SilenceWatchdog: &models.SilenceWatchdog{Minutes: -1}}); appErr == nil || appErr.Field != "silence_watchdog" {
+		t.Errorf("negative minutes: %v, want 400 on silence_watchdog", appErr)
+	}
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{SilenceWatchdog: &models.SilenceWatchdog{Enabled: true, Minutes: 3}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	evs := bus.SubscribeEvents("test")
+	silent := func() *models.StreamSilent {
+		t.Helper()
+		ctrl.CheckSilence()
+		select {
+		case ev := <-evs:
+			if ev.Type != models.EventStreamSilent {
+				t.Fatalf("event = %+v, want stream_silent", ev)
+			}
+			data := ev.Data.(models.StreamSilent)
+			return &data
+		default:
+			return nil
+		}
+	}
+
+	// Reported once after three minutes of silence while playing.
+	if ev := silent(); ev != nil {
+		t.Errorf("first silent pass reported %+v", ev)
+	}
+	now = now.Add(2 * time.Minute)
+	if ev := silent(); ev != nil {
+		t.Errorf("after 2 minutes reported %+v", ev)
+	}
+	now = now.Add(time.Minute)
+	ev := silent()
+	if ev == nil || ev.StreamID != models.AuxStreamID || ev.SourceID != 0 || ev.Minutes != 3 || ev.PeakDB != -96 || ev.Restarted {
+		t.Fatalf("after 3 minutes: %+v", ev)
+	}
+	now = now.Add(5 * time.Minute)
+	if ev := silent(); ev != nil {
+		t.Errorf("reported again: %+v", ev)
+	}
+
+	// Sound ends the silence; a paused stream is not metered.
+	peak = -30
+	silent()
+	peak = math.Inf(-1)
+	silent()
+	now = now.Add(2 * time.Minute)
+	if ev := silent(); ev != nil {
+		t.Errorf("2 minutes after sound returned: %+v", ev)
+	}
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "paused"})
+	before := metered
+	if ev := silent(); ev != nil || metered != before {
+		t.Errorf("paused stream: event %+v, metered %d times", ev, metered-before)
+	}
+}
+
+func TestIdentifyLEDsRestores(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	zone := 3
+	if _, appErr := ctrl.IdentifyLEDs(ctx, models.LEDIdentify{Zone: &zone, Duration: 1}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegLEDCtrl); v != 1 {
+		t.Error("override not enabled while identifying")
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for ctrl.GetLEDs(ctx)[0].Pattern != "" {
+		if time.Now().After(deadline) {
+			t.Fatal("identify did not finish")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegLEDCtrl); v != 0 {
+		t.Error("override not restored")
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegLEDVal); v != 0 {
+		t.Errorf("LED value = %#x, want restored to 0", v)
+	}
+}
+
+func TestLEDActivity(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctrl.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+	ledVal := func() byte {
+		v, _ := hw.Read(ctx, 0, hardware.RegLEDVal)
+		return v
+	}
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Radio", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	streamID := state.Streams[len(state.Streams)-1].ID
+	input := fmt.Sprintf("stream=%d", streamID)
+	ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input})
+	mute := false
+	ctrl.SetZone(ctx, 2, models.ZoneUpdate{Mute: &mute})
+	ctrl.UpdateStreamInfo(streamID, models.StreamInfo{State: "playing"})
+
+	// Off by default: the firmware drives the LEDs.
+	ctrl.RefreshLEDActivity()
+	if v, _ := hw.Read(ctx, 0, hardware.RegLEDCtrl); v != 0 {
+		t.Fatal("override enabled without LED settings")
+	}
+
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{LEDs: &models.LEDSettings{ZoneActivity: true, OffFrom: "22:00", OffTo: "07:00"}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	ctrl.RefreshLEDActivity()
+	// Green (bit 0) and zone 2 (bit 2+2): unmuted and playing; muted zones stay dark.
+	if v := ledVal(); v != 1|1<<4 {
+		t.Errorf("LED value = %#b, want green and zone 2", v)
+	}
+	if leds := ctrl.GetLEDs(ctx)[0]; !leds.Auto || !leds.Override {
+		t.Errorf("leds = %+v, want auto override", leds)
+	}
+
+	ctrl.UpdateStreamInfo(streamID, models.StreamInfo{State: "paused"})
+	ctrl.RefreshLEDActivity()
+	if v := ledVal(); v != 1 {
+		t.Errorf("LED value after pause = %#b, want green only", v)
+	}
+
+	// Off hours turn everything off.
+	now = time.Date(2026, 1, 1, 23, 0, 0, 0, time.UTC)
+	ctrl.RefreshLEDActivity()
+	if v := ledVal(); v != 0 {
+		t.Errorf("LED value at night = %#b, want all off", v)
+	}
+
+	// LEDs set through the API win until their override is turned off.
+	on := true
+	ctrl.SetLEDs(ctx, 0, models.LEDUpdate{Red: &on})
+	ctrl.RefreshLEDActivity()
+	if v := ledVal(); v != 1<<1 {
+		t.Errorf("LED value after manual set = %#b, want red", v)
+	}
+	off := false
+	ctrl.SetLEDs(ctx, 0, models.LEDUpdate{Override: &off})
+	ctrl.RefreshLEDActivity()
+	if leds := ctrl.GetLEDs(ctx)[0]; !leds.Auto || leds.Red || leds.Green {
+		t.Errorf("leds = %+v, want back to night off", leds)
+	}
+
+	// Turning the settings off hands the LEDs back to the firmware.
+	ctrl.SetSettings(ctx, models.SettingsUpdate{LEDs: &models.LEDSettings{}})
+	ctrl.RefreshLEDActivity()
+	if v, _ := hw.Read(ctx, 0, hardware.RegLEDCtrl); v != 0 {
+		t.Error("override still on with LED settings off")
+	}
+
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{LEDs: &models.LEDSettings{OffFrom: "22:00"}}); appErr == nil || appErr.Field != "leds" {
+		t.Errorf("half-set off hours: %v", appErr)
+	}
+}
+
+func TestLEDActivity_ZoneBase(t *testing.T) {
+	// A 4-zone main unit puts the expander's first zone at ID 4, not 6.
+	p := &hardware.HardwareProfile{
+		Units: []hardware.UnitInfo{
+			{Index: 0, Board: hardware.BoardInfo{UnitType: hardware.UnitTypeMain}, ZoneBase: 0, ZoneCount: 4, HasAnalog: true},
+			{Index: 1, Board: hardware.BoardInfo{UnitType: hardware.UnitTypeExpansion}, ZoneBase: 4, ZoneCount: 6},
+		},
+		TotalSources: 4,
+		TotalZones:   10,
+		Streams:      []hardware.StreamCapability{{Type: "internet_radio", Available: true}},
+	}
+	hw := hardware.NewMockWithUnits([]int{0, 1})
+	ctrl, err := controller.New(hw, p, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Radio", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	streamID := state.Streams[len(state.Streams)-1].ID
+	input := fmt.Sprintf("stream=%d", streamID)
+	ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input})
+	mute := false
+	ctrl.SetZone(ctx, 4, models.ZoneUpdate{Mute: &mute})
+	ctrl.UpdateStreamInfo(streamID, models.StreamInfo{State: "playing"})
+	ctrl.SetSettings(ctx, models.SettingsUpdate{LEDs: &models.LEDSettings{ZoneActivity: true}})
+	ctrl.RefreshLEDActivity()
+
+	if v, _ := hw.Read(ctx, 0, hardware.RegLEDVal); v != 1 {
+		t.Errorf("main unit LED value = %#b, want green only", v)
+	}
+	// Zone 4 is the expander's first zone (bit 2).
+	if v, _ := hw.Read(ctx, 1, hardware.RegLEDVal); v != 1|1<<2 {
+		t.Errorf("expander LED value = %#b, want green and its first zone", v)
+	}
+}
+
+// slowHW is a mock driver whose volume writes block until release is closed.
+type slowHW struct {
+	*hardware.Mock
+	release chan struct{}
+	volSets atomic.Int32
+}
+
+func (h *slowHW) SetZoneVol(ctx context.Context, unit, zone int, vol int) error {
+	<-h.release
+	h.volSets.Add(1)
+	return h.Mock.SetZoneVol(ctx, unit, zone, vol)
+}
+
+// fadeHW is a mock driver that records zone 0's volume and source writes.
+type fadeHW struct {
+	*hardware.Mock
+	mu     sync.Mutex
+	writes []string
+}
+
+func (h *fadeHW) record(w string) {
+	h.mu.Lock()
+	h.writes = append(h.writes, w)
+	h.mu.Unlock()
+}
+
+func (h *fadeHW) SetZoneVol(ctx context.Context, unit, zone int, vol int) error {
+	switch {
+	case unit == 0 && zone == 0:
+		h.record(fmt.Sprint(vol))
+	case unit == 0 && zone == 1:
+		h.record(fmt.Sprintf("zone 1 %d", vol))
+	}
+	return h.Mock.SetZoneVol(ctx, unit, zone, vol)
+}
+
+func (h *fadeHW) SetZoneSources(ctx context.Context, unit int, sources [6]int) error {
+	h.record(fmt.Sprintf("source %d", sources[0]))
+	return h.Mock.SetZoneSources(ctx, unit, sources)
+}
+
+func TestCrossfade(t *testing.T) {
+	hw := &fadeHW{Mock: hardware.NewMock()}
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	vol, unmuted, src := -20, false, 0
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol, Mute: &unmuted, SourceID: &src})
+
+	fade := 80
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{CrossfadeMS: &fade}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	bad := models.MaxCrossfadeMS + 1
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{CrossfadeMS: &bad}); appErr == nil || appErr.Field != "crossfade_ms" {
+		t.Errorf("crossfade too long: %v", appErr)
+	}
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	hw.mu.Lock()
+	hw.writes = nil
+	hw.mu.Unlock()
+
+	src = 1
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{SourceID: &src})
+	// Another zone's volume does not wait for the crossfade.
+	vol = -30
+	ctrl.SetZone(ctx, 1, models.ZoneUpdate{Vol: &vol})
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	writes := slices.DeleteFunc(slices.Clone(hw.writes), func(w string) bool { return strings.HasPrefix(w, "zone") })
+	// Down to silence, switch, back up to -20.
+	if len(writes) < 4 || writes[0] == "source 1" {
+		t.Fatalf("writes = %v, want a fade around the switch", hw.writes)
+	}
+	i := slices.Index(writes, "source 1")
+	if i < 0 || writes[i-1] != fmt.Sprint(models.MinVolDB) || writes[len(writes)-1] != "-20" {
+		t.Errorf("writes = %v, want silence before the switch and -20 after", hw.writes)
+	}
+	if j := slices.Index(hw.writes, "zone 1 -30"); j < 0 || j > slices.Index(hw.writes, "source 1") {
+		t.Errorf("writes = %v, want zone 1's volume written during the fade", hw.writes)
+	}
+}
+
+func TestSoftStart(t *testing.T) {
+	store := newMemStore()
+	store.state.Settings.SoftStartMS = 80
+	store.state.Zones[0].Mute = false
+	store.state.Zones[0].Vol = -30
+	hw := &fadeHW{Mock: hardware.NewMock()}
+	ctrl, err := controller.New(hw, nil, store, events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Zone 0 starts silent and ramps up to its volume.
+	hw.mu.Lock()
+	writes := hw.writes
+	hw.writes = nil
+	hw.mu.Unlock()
+	vols := slices.DeleteFunc(slices.Clone(writes), func(w string) bool { return strings.HasPrefix(w, "source") || strings.HasPrefix(w, "zone") })
+	// The volume may be written before the ramp while the amps are still off.
+	i := slices.Index(vols, fmt.Sprint(models.MinVolDB))
+	if i < 0 || i > 1 || len(vols)-i < 3 || vols[len(vols)-1] != "-30" {
+		t.Errorf("startup volume writes = %v, want a ramp from silence to -30", vols)
+	}
+
+	if err := ctrl.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegAmpEn); v != 0 {
+		t.Errorf("amp enables after shutdown = 0b%08b, want all off", v)
+	}
+	hw.mu.Lock()
+	if !slices.Contains(hw.writes, fmt.Sprint(models.MinVolDB)) {
+		t.Errorf("shutdown volume writes = %v, want a ramp down to silence", hw.writes)
+	}
+	hw.mu.Unlock()
+
+	// Amps stay off whatever changes afterwards.
+	vol := -20
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol})
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegAmpEn); v != 0 {
+		t.Errorf("amp enables after a change = 0b%08b, want all off", v)
+	}
+
+	bad := -1
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{SoftStartMS: &bad}); appErr == nil || appErr.Field != "soft_start_ms" {
+		t.Errorf("negative soft start: %v", appErr)
+	}
+	// With both out of range the first field in the request is reported.
+	for i := 0; i < 10; i++ {
+		if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{CrossfadeMS: &bad, SoftStartMS: &bad}); appErr == nil || appErr.Field != "crossfade_ms" {
+			t.Fatalf("both out of range: %v", appErr)
+		}
+	}
+}
+
+func TestHardwareWritesDoNotBlockAPI(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	hw := &slowHW{Mock: hardware.NewMock(), release: release}
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	hw.volSets.Store(0)
+	hw.release = make(chan struct{})
+
+	// The first write blocks in the driver; the API keeps answering and
+	// the queued writes to the same register collapse into one.
+	for _, vol := range []int{-50, -40, -30, -20} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			v := vol
+			ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &v})
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("SetZone blocked on a hardware write")
+		}
+	}
+	if got := ctrl.State().Zones[0].Vol; got != -20 {
+		t.Errorf("state vol = %d, want -20", got)
+	}
+	close(hw.release)
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := hw.volSets.Load(); n > 2 {
+		t.Errorf("%d volume writes, want at most 2 (in flight, then latest)", n)
+	}
+	if v, _ := hw.Read(ctx, 0, hardware.RegVolZone1); v != 20 {
+		t.Errorf("zone 0 volume register = %d, want 20", v)
+	}
+}
+
+func TestHardwareErrorsPublished(t *testing.T) {
+	release := make(chan struct{})
+	close(release)
+	hw := &slowHW{Mock: hardware.NewMock(), release: release}
+	bus := events.NewBus()
+	ctrl, err := controller.New(hw, nil, newMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	ch := bus.Subscribe("test")
+	defer bus.Unsubscribe("test")
+
+	hw.SetFailWrite(true)
+	vol := -30
+	if _, appErr := ctrl.SetZone(ctx, 2, models.ZoneUpdate{Vol: &vol}); appErr != nil {
+		t.Fatalf("SetZone: %v (write failures must not fail the request)", appErr)
+	}
+	deadline := time.After(2 * time.Second)
+	for reported := false; !reported; {
+		select {
+		case s := <-ch:
+			for _, e := range s.Info.HardwareErrors {
+				reported = reported || e.Register == "zone 2 volume" && strings.Contains(e.Error, "write failure")
+			}
+		case <-deadline:
+			t.Fatal("hardware error not published")
+		}
+	}
+
+	// A successful write to the same register clears the error.
+	hw.SetFailWrite(false)
+	vol = -25
+	ctrl.SetZone(ctx, 2, models.ZoneUpdate{Vol: &vol})
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if errs := ctrl.State().Info.HardwareErrors; len(errs) != 0 {
+		t.Errorf("hardware errors after recovery: %+v", errs)
+	}
+}
+
+func TestSourceIdleAutoOff(t *testing.T) {
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	ctrl.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+	evs := bus.SubscribeEvents("test")
+	defer bus.UnsubscribeEvents("test")
+
+	state, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Radio", Type: "internet_radio", Config: map[string]interface{}{"url": "http://example.com"}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	streamID := state.Streams[len(state.Streams)-1].ID
+	input := fmt.Sprintf("stream=%d", streamID)
+	ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input})
+	mute := false
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute})
+
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{SourceIdle: []models.SourceIdlePolicy{{SourceID: 0, Minutes: -1}}}); appErr == nil {
+		t.Error("negative idle minutes accepted")
+	}
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{SourceIdle: []models.SourceIdlePolicy{{SourceID: 0, Minutes: 5}}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	// Playing streams are never idle.
+	ctrl.UpdateStreamInfo(streamID, models.StreamInfo{State: "playing"})
+	ctrl.CheckSourceIdle()
+	now = now.Add(10 * time.Minute)
+	ctrl.CheckSourceIdle()
+	if ctrl.State().Sources[0].Input != input {
+		t.Fatal("playing source turned off")
+	}
+
+	// Paused: off once the timeout has passed since the pause was noticed.
+	ctrl.UpdateStreamInfo(streamID, models.StreamInfo{State: "paused"})
+	ctrl.CheckSourceIdle()
+	now = now.Add(4 * time.Minute)
+	ctrl.CheckSourceIdle()
+	if ctrl.State().Sources[0].Input != input {
+		t.Fatal("source turned off before the idle timeout")
+	}
+	now = now.Add(time.Minute)
+	ctrl.CheckSourceIdle()
+	state = ctrl.State()
+	if state.Sources[0].Input != "" || !state.Zones[0].Mute {
+		t.Errorf("after idle timeout: input = %q, zone 0 mute = %v", state.Sources[0].Input, state.Zones[0].Mute)
+	}
+	// Skip the zone_changed and stream_started events of the changes above.
+	for {
+		select {
+		case ev := <-evs:
+			if ev.Type != models.EventSourceAutoOff {
+				continue
+			}
+			off, _ := ev.Data.(models.SourceAutoOff)
+			if off.SourceID != 0 || off.StreamID != streamID || off.IdleMinutes != 5 {
+				t.Errorf("event = %+v", ev)
+			}
+		default:
+			t.Error("no source_auto_off event")
+		}
+		break
+	}
+}
+
+func TestZoneNightMode(t *testing.T) {
+	ctrl := newTestController(t)
+	now := time.Date(2026, 1, 1, 20, 0, 0, 0, time.Local)
+	ctrl.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	night := &models.NightMode{From: "21:00", To: "07:00", VolMax: -40}
+	vol := -20
+	state, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Night: night, Vol: &vol})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if z := state.Zones[0]; z.Vol != -20 || z.VolLimit != nil {
+		t.Errorf("before quiet hours: vol = %d, vol_limit = %v", z.Vol, z.VolLimit)
+	}
+
+	// Entering quiet hours turns the zone down and caps later changes.
+	now = now.Add(2 * time.Hour)
+	ctrl.ApplyNightMode()
+	if z := ctrl.State().Zones[0]; z.Vol != -40 || z.VolLimit == nil || *z.VolLimit != -40 {
+		t.Errorf("in quiet hours: vol = %d, vol_limit = %v", z.Vol, z.VolLimit)
+	}
+	vol = -10
+	state, _ = ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol})
+	if state.Zones[0].Vol != -40 {
+		t.Errorf("vol = %d during quiet hours, want capped at -40", state.Zones[0].Vol)
+	}
+	if state.Zones[1].VolLimit != nil {
+		t.Error("zone without night mode has a vol_limit")
+	}
+
+	// The cap lifts in the morning.
+	now = now.Add(10 * time.Hour)
+	ctrl.ApplyNightMode()
+	state, _ = ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol})
+	if z := state.Zones[0]; z.Vol != -10 || z.VolLimit != nil {
+		t.Errorf("after quiet hours: vol = %d, vol_limit = %v", z.Vol, z.VolLimit)
+	}
+
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Night: &models.NightMode{From: "21:00"}}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("half-set night mode: %v", appErr)
+	}
+	state, _ = ctrl.SetZone(ctx, 0, models.ZoneUpdate{Night: &models.NightMode{}})
+	if state.Zones[0].Night != nil {
+		t.Error("night mode not cleared")
+	}
+}
+
+func TestPartyMode(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	src, mute, vol := 2, false, -30
+	ctrl.SetZone(ctx, 1, models.ZoneUpdate{SourceID: &src, Mute: &mute, Vol: &vol})
+	before := ctrl.State()
+
+	if _, appErr := ctrl.EndParty(ctx); appErr == nil || appErr.Status != 404 {
+		t.Errorf("EndParty without a party: %v", appErr)
+	}
+	if _, appErr := ctrl.StartParty(ctx, models.PartyRequest{SourceID: 7}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("invalid source: %v", appErr)
+	}
+
+	volF := 0.5
+	state, appErr := ctrl.StartParty(ctx, models.PartyRequest{SourceID: 0, Zones: []int{0, 1}, VolF: &volF})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, id := range []int{0, 1} {
+		if z := state.Zones[id]; z.SourceID != 0 || z.Mute || z.VolF != 0.5 {
+			t.Errorf("party zone %d = %+v", id, z)
+		}
+	}
+	if state.Zones[2] != before.Zones[2] {
+		t.Error("party changed an unselected zone")
+	}
+
+	// Adjusting the party keeps the original routing for restore.
+	if _, appErr := ctrl.StartParty(ctx, models.PartyRequest{SourceID: 3, Zones: []int{1, 2}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	state, appErr = ctrl.EndParty(ctx)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	for id := 0; id < 3; id++ {
+		got, want := state.Zones[id], before.Zones[id]
+		if got.SourceID != want.SourceID || got.Mute != want.Mute || got.Vol != want.Vol {
+			t.Errorf("zone %d after party = %+v, want %+v", id, got, want)
+		}
+	}
+	if len(state.Presets) != len(before.Presets) {
+		t.Errorf("restore preset left behind: %+v", state.Presets)
+	}
+
+	// Presets created during a party stay clear of the reserved IDs.
+	if _, appErr := ctrl.StartParty(ctx, models.PartyRequest{SourceID: 0, Zones: []int{0}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	state, appErr = ctrl.CreatePreset(ctx, models.PresetCreate{Name: "During the party"})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if p := state.Presets[len(state.Presets)-1]; p.Name != "During the party" || models.ReservedPresetID(p.ID) {
+		t.Errorf("preset created during a party = %+v", p)
+	}
+}
+
+func TestTriggers(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	ctrl.SetClock(func() time.Time { return now })
+	ctx := context.Background()
+
+	name, pin, delay, hold := "Sub amp", "GPIO22", 2.0, 60.0
+	triggers, appErr := ctrl.CreateTrigger(ctx, models.TriggerUpdate{
+		Name: &name, Pin: &pin, Zones: []int{0}, Delay: &delay, Hold: &hold,
+	})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if len(triggers) != 1 || triggers[0].Active {
+		t.Fatalf("triggers = %+v", triggers)
+	}
+	if high, ok := hw.GPIO("GPIO22"); !ok || high {
+		t.Errorf("GPIO22 = %v (set %v), want released low", high, ok)
+	}
+
+	// Zone 0 starts playing: asserted after the delay.
+	input, mute, src := "local", false, 0
+	ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input})
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{SourceID: &src, Mute: &mute})
+	ctrl.RefreshTriggers()
+	if ctrl.GetTriggers()[0].Active {
+		t.Error("trigger asserted before its delay")
+	}
+	now = now.Add(3 * time.Second)
+	ctrl.RefreshTriggers()
+	if high, _ := hw.GPIO("GPIO22"); !high || !ctrl.GetTriggers()[0].Active {
+		t.Error("trigger not asserted after its delay")
+	}
+
+	// Muted: held, then released.
+	mute = true
+	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute})
+	ctrl.RefreshTriggers()
+	now = now.Add(30 * time.Second)
+	ctrl.RefreshTriggers()
+	if high, _ := hw.GPIO("GPIO22"); !high {
+		t.Error("trigger released during its hold time")
+	}
+	now = now.Add(31 * time.Second)
+	ctrl.RefreshTriggers()
+	if high, _ := hw.GPIO("GPIO22"); high {
+		t.Error("trigger not released after its hold time")
+	}
+
+	for _, upd := range []models.TriggerUpdate{
+		{Name: &name, Pin: &pin, Zones: []int{1}}, // pin taken
+		{Name: &name, Pin: strPtr("GPIO4"), Zones: []int{1}},
+		{Name: &name, Pin: strPtr("GPIO0"), Zones: []int{1}},
+		{Name: &name, Pin: strPtr("GPIO9"), Zones: []int{1}},
+		{Name: &name, Pin: strPtr("GPIO17"), Zones: []int{1}},
+		{Name: &name, Pin: strPtr("GPIO24"), Zones: []int{1}},
+		{Name: &name, Pin: strPtr("GPIO18")},
+		{Name: &name, Pin: strPtr("GPIO18"), Zones: []int{99}},
+	} {
+		if _, appErr := ctrl.CreateTrigger(ctx, upd); appErr == nil {
+			t.Errorf("CreateTrigger(%+v) succeeded", upd)
+		}
+	}
+	if _, appErr := ctrl.DeleteTrigger(ctx, 0); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.DeleteTrigger(ctx, 0); appErr == nil || appErr.Status != 404 {
+		t.Errorf("deleting a missing trigger: %v", appErr)
+	}
+}
+
+func TestWebhooks(t *testing.T) {
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	name, url := "Home Assistant", "http://ha.local:8123/api/webhook/amplipi"
+	hooks, appErr := ctrl.CreateWebhook(ctx, models.WebhookUpdate{
+		Name: &name, URL: &url, Events: []string{models.EventZoneChanged},
+	})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if len(hooks) != 1 || !hooks[0].Wants(models.EventZoneChanged) || hooks[0].Wants(models.EventOverTemp) {
+		t.Fatalf("webhooks = %+v", hooks)
+	}
+
+	disabled := true
+	if hooks, appErr = ctrl.SetWebhook(ctx, 0, models.WebhookUpdate{Disabled: &disabled}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if hooks[0].Wants(models.EventZoneChanged) {
+		t.Error("disabled webhook still wants events")
+	}
+
+	for _, upd := range []models.WebhookUpdate{
+		{Name: &name},
+		{Name: &name, URL: strPtr("ftp://example.com")},
+		{Name: &name, URL: &url, Events: []string{"bogus"}},
+	} {
+		if _, appErr := ctrl.CreateWebhook(ctx, upd); appErr == nil {
+			t.Errorf("CreateWebhook(%+v) succeeded", upd)
+		}
+	}
+	if appErr := ctrl.TestWebhook(ctx, 5); appErr == nil || appErr.Status != 404 {
+		t.Errorf("testing a missing webhook: %v", appErr)
+	}
+	if _, appErr := ctrl.DeleteWebhook(ctx, 0); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if len(ctrl.GetWebhooks()) != 0 {
+		t.Error("webhook not deleted")
+	}
+}
+
+func TestChangeEvents(t *testing.T) {
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, newMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	evs := bus.SubscribeEvents("test")
+	next := func() models.Event {
+		t.Helper()
+		select {
+		case ev := <-evs:
+			return ev
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+		}
+		return models.Event{}
+	}
+
+	vol := 0.4
+	ctrl.SetZone(ctx, 1, models.ZoneUpdate{VolF: &vol})
+	ev := next()
+	zc, ok := ev.Data.(models.ZoneChanged)
+	if ev.Type != models.EventZoneChanged || !ok || len(zc.Zones) != 1 || zc.Zones[0].ID != 1 {
+		t.Fatalf("event = %+v", ev)
+	}
+
+	st := ctrl.State().Streams[0]
+	ctrl.UpdateStreamInfo(st.ID, models.StreamInfo{Name: st.Name, State: "playing"})
+	ctrl.UpdateStreamInfo(st.ID, models.StreamInfo{Name: st.Name, State: "playing", Track: "Next"})
+	if ev := next(); ev.Type != models.EventStreamStarted || ev.Data.(models.StreamStarted).StreamID != st.ID {
+		t.Fatalf("event = %+v, want stream_started", ev)
+	}
+
+	ctrl.ReportFanStatus(0, hardware.FanStatus{OvrTmp: true})
+	ctrl.ReportFanStatus(0, hardware.FanStatus{OvrTmp: true})
+	if ev := next(); ev.Type != models.EventOverTemp {
+		t.Fatalf("event = %+v, want over_temp", ev)
+	}
+
+	ctrl.NotifyRelease("0.0.1") // the running version
+	ctrl.NotifyRelease("9.0.0")
+	ctrl.NotifyRelease("9.0.0")
+	ev = next()
+	if up, ok := ev.Data.(models.UpdateAvailable); ev.Type != models.EventUpdateAvailable || !ok || up.Latest != "9.0.0" {
+		t.Fatalf("event = %+v, want update_available 9.0.0", ev)
+	}
+	select {
+	case ev := <-evs:
+		t.Errorf("unexpected event %+v", ev)
+	default:
+	}
+
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"0.4.10", "0.4.9", true},
+		{"v1.0", "0.9.9", true},
+		{"1.0.0", "1.0", false},
+		{"1.0.0-rc1", "1.0.0", false},
+	} {
+		if got := controller.NewerVersion(tc.a, tc.b); got != tc.want {
+			t.Errorf("newerVersion(%q, %q) = %v", tc.a, tc.b, got)
+		}
+	}
+}
+
+func TestStreamInfoRestoredStale(t *testing.T) {
+	store := newMemStore()
+	id := store.state.Streams[0].ID
+	store.state.Streams[0].Info = models.StreamInfo{
+		Name: "Radio", State: "playing", Track: "Song", Artist: "Artist",
+		Supervisor: &models.SupervisorStatus{},
+	}
+	ctrl, err := controller.New(hardware.NewMock(), nil, store, events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info := ctrl.State().Streams[0].Info
+	if !info.Stale || info.Track != "Song" || info.Supervisor != nil {
+		t.Fatalf("restored info = %+v, want stale Song without a supervisor", info)
+	}
+
+	// A report without metadata keeps the last-known track.
+	ctrl.UpdateStreamInfo(id, models.StreamInfo{Name: "Radio", State: "connected"})
+	info = ctrl.State().Streams[0].Info
+	if !info.Stale || info.Track != "Song" || info.Artist != "Artist" || info.State != "connected" {
+		t.Errorf("info after a status report = %+v, want stale Song, connected", info)
+	}
+
+	// The stream's own metadata replaces it.
+	ctrl.UpdateStreamInfo(id, models.StreamInfo{Name: "Radio", State: "playing", Track: "Live"})
+	info = ctrl.State().Streams[0].Info
+	if info.Stale || info.Track != "Live" || info.Artist != "" {
+		t.Errorf("info after a metadata report = %+v, want fresh Live", info)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	full, changed := ctrl.Poll(0)
+	if !changed || full.Sources == nil || full.Zones == nil || full.Streams == nil || full.Info == nil || full.Settings == nil {
+		t.Fatalf("poll from 0 = %+v, want the whole state", full)
+	}
+	if p, changed := ctrl.Poll(full.Rev); changed || p.Rev != full.Rev {
+		t.Errorf("poll at the current rev = %+v, %v; want unchanged", p, changed)
+	}
+
+	vol := -25
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Vol: &vol}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	p, changed := ctrl.Poll(full.Rev)
+	if !changed || p.Rev <= full.Rev || len(p.Zones) == 0 || p.Zones[0].Vol != -25 {
+		t.Fatalf("poll after a zone change = %+v, want the zones", p)
+	}
+	if p.Sources != nil || p.Streams != nil || p.Presets != nil || p.Settings != nil {
+		t.Errorf("poll after a zone change = %+v, want only what changed", p)
+	}
+
+	// A rev from another run gets everything.
+	if p, _ := ctrl.Poll(p.Rev + 1000); p.Sources == nil || p.Streams == nil {
+		t.Errorf("poll from a future rev = %+v, want the whole state", p)
+	}
+}
+
+func TestZoneLock(t *testing.T) {
+	ctrl := newTestController(t)
+	guest := context.Background()
+	admin := auth.WithAdmin(guest)
+
+	volCap := -30
+	lock := models.ZoneLock{Enabled: true, VolMax: &volCap}
+	if _, appErr := ctrl.SetZone(guest, 0, models.ZoneUpdate{Lock: &lock}); appErr == nil || appErr.Status != 403 {
+		t.Fatalf("guest locking a zone: %v, want 403", appErr)
+	}
+	state, appErr := ctrl.SetZone(admin, 0, models.ZoneUpdate{Lock: &lock})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if z := state.Zones[0]; z.Lock == nil || z.VolLimit == nil || *z.VolLimit != -30 {
+		t.Errorf("locked zone = %+v, want the lock and a -30 vol_limit", z)
+	}
+
+	// Guests can't change the source or go above the cap.
+	src := 1
+	if _, appErr := ctrl.SetZone(guest, 0, models.ZoneUpdate{SourceID: &src}); appErr == nil || appErr.Status != 403 || appErr.Field != "source_id" {
+		t.Errorf("guest changing a locked zone's source: %v, want 403 on source_id", appErr)
+	}
+	vol := -10
+	state, appErr = ctrl.SetZone(guest, 0, models.ZoneUpdate{Vol: &vol})
+	if appErr != nil || state.Zones[0].Vol != -30 {
+		t.Errorf("guest volume -10 on a locked zone: vol %d, %v; want -30", state.Zones[0].Vol, appErr)
+	}
+
+	// Groups leave the locked zone on its source.
+	name := "Both"
+	state, _ = ctrl.CreateGroup(guest, models.GroupUpdate{Name: &name, ZoneIDs: []int{0, 1}})
+	gid := state.Groups[len(state.Groups)-1].ID
+	state, appErr = ctrl.SetGroup(guest, gid, models.GroupUpdate{SourceID: &src})
+	if appErr != nil || state.Zones[0].SourceID != 0 || state.Zones[1].SourceID != 1 {
+		t.Errorf("group source change: zones on %d and %d, %v; want 0 and 1", state.Zones[0].SourceID, state.Zones[1].SourceID, appErr)
+	}
+
+	// Administrators override the lock, and remove it.
+	if state, appErr = ctrl.SetZone(admin, 0, models.ZoneUpdate{SourceID: &src}); appErr != nil || state.Zones[0].SourceID != 1 {
+		t.Errorf("admin changing a locked zone's source: %v", appErr)
+	}
+	if state, appErr = ctrl.SetZone(admin, 0, models.ZoneUpdate{Vol: &vol}); appErr != nil || state.Zones[0].Vol != -10 {
+		t.Errorf("admin volume -10 on a locked zone: vol %d, %v; want -10", state.Zones[0].Vol, appErr)
+	}
+	if ctrl.ApplyNightMode(); ctrl.State().Zones[0].Vol != -10 {
+		t.Errorf("night mode pass capped the admin's volume to %d", ctrl.State().Zones[0].Vol)
+	}
+	if _, appErr := ctrl.SetZone(guest, 0, models.ZoneUpdate{Lock: &models.ZoneLock{}}); appErr == nil {
+		t.Error("guest unlocked a zone")
+	}
+	state, appErr = ctrl.SetZone(admin, 0, models.ZoneUpdate{Lock: &models.ZoneLock{}})
+	if appErr != nil || state.Zones[0].Lock != nil || state.Zones[0].VolLimit != nil {
+		t.Errorf("unlocked zone = %+v, %v; want no lock or vol_limit", state.Zones[0], appErr)
+	}
+
+	bad := 10
+	if _, appErr := ctrl.SetZone(admin, 0, models.ZoneUpdate{Lock: &models.ZoneLock{Enabled: true, VolMax: &bad}}); appErr == nil || appErr.Field != "lock" {
+		t.Errorf("lock cap above 0 dB: %v, want an error on lock", appErr)
+	}
+}
+
+func TestScopedControl(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+	scoped := auth.WithScope(ctx, &auth.Scope{Zones: []int{0, 1}})
+
+	name, other := "Mine", "Theirs"
+	state, _ := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: &name, ZoneIDs: []int{0, 1}})
+	mine := state.Groups[len(state.Groups)-1].ID
+	state, _ = ctrl.CreateGroup(ctx, models.GroupUpdate{Name: &other, ZoneIDs: []int{0, 2}})
+	theirs := state.Groups[len(state.Groups)-1].ID
+
+	mute := true
+	if _, appErr := ctrl.SetGroup(scoped, mine, models.GroupUpdate{Mute: &mute}); appErr != nil {
+		t.Errorf("group of the key's zones: %v", appErr)
+	}
+	if _, appErr := ctrl.SetGroup(scoped, theirs, models.GroupUpdate{Mute: &mute}); appErr == nil || appErr.Status != 403 {
+		t.Errorf("group with another zone: %v, want 403", appErr)
+	}
+	if _, appErr := ctrl.SetGroup(scoped, mine, models.GroupUpdate{ZoneIDs: []int{0, 1, 2}}); appErr == nil || appErr.Status != 403 {
+		t.Errorf("adding a zone to the key's group: %v, want 403", appErr)
+	}
+	if _, appErr := ctrl.SetZones(scoped, models.MultiZoneUpdate{ZoneIDs: []int{1, 2}, Update: models.ZoneUpdate{Mute: &mute}}); appErr == nil || appErr.Status != 403 {
+		t.Errorf("bulk update with another zone: %v, want 403", appErr)
+	}
+
+	// Only volume, mute and source may change.
+	vol, src, zname, off, maxVol := -30, 1, "Hacked", true, 0
+	if _, appErr := ctrl.SetZone(scoped, 0, models.ZoneUpdate{Vol: &vol, Mute: &mute, SourceID: &src}); appErr != nil {
+		t.Errorf("volume, mute and source of the key's zone: %v", appErr)
+	}
+	for _, upd := range []models.ZoneUpdate{
+		{Name: &zname}, {Disabled: &off}, {VolMax: &maxVol}, {Vol: &vol, Bridged: &off},
+		{Night: &models.NightMode{}}, {Amp: &models.AmpPower{}}, {Lock: &models.ZoneLock{}},
+	} {
+		if _, appErr := ctrl.SetZone(scoped, 0, upd); appErr == nil || appErr.Status != 403 {
+			t.Errorf("SetZone(%+v): %v, want 403", upd, appErr)
+		}
+		if _, appErr := ctrl.SetZones(scoped, models.MultiZoneUpdate{ZoneIDs: []int{0}, Update: upd}); appErr == nil || appErr.Status != 403 {
+			t.Errorf("SetZones(%+v): %v, want 403", upd, appErr)
+		}
+	}
+	if _, appErr := ctrl.SetGroup(scoped, mine, models.GroupUpdate{Name: &zname}); appErr == nil || appErr.Status != 403 {
+		t.Errorf("renaming the key's group: %v, want 403", appErr)
+	}
+
+	// Streams are controlled where they play.
+	sid := state.Streams[0].ID
+	if _, appErr := ctrl.ExecStreamCommand(scoped, sid, "play"); appErr == nil || appErr.Status != 403 {
+		t.Errorf("command to a stream not in the key's zones: %v, want 403", appErr)
+	}
+	input := fmt.Sprintf("stream=%d", sid)
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.ExecStreamCommand(scoped, sid, "play"); appErr != nil {
+		t.Errorf("command to a stream in the key's zones: %v", appErr)
+	}
+}
+
+func TestSourceZones(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	state := ctrl.State()
+	for _, src := range state.Sources {
+		var want []int
+		for _, z := range state.Zones {
+			if z.SourceID == src.ID && !z.Disabled {
+				want = append(want, z.ID)
+			}
+		}
+		if src.Zones == nil || !slices.Equal(src.Zones, want) {
+			t.Errorf("source %d zones = %v at startup, want %v", src.ID, src.Zones, want)
+		}
+	}
+
+	src, unmute := 2, false
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{SourceID: &src, Mute: &unmute}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 3, models.ZoneUpdate{SourceID: &src}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	mute := true
+	state, appErr := ctrl.SetZone(ctx, 3, models.ZoneUpdate{Mute: &mute})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if s := state.Sources[2]; !slices.Equal(s.Zones, []int{1, 3}) || s.Listeners != 1 {
+		t.Errorf("source 2 zones %v listeners %d, want [1 3] and 1", s.Zones, s.Listeners)
+	}
+	for _, s := range state.Sources {
+		if s.ID != 2 && (slices.Contains(s.Zones, 1) || slices.Contains(s.Zones, 3)) {
+			t.Errorf("source %d still lists zones 1 or 3: %v", s.ID, s.Zones)
+		}
+	}
+
+	disabled := true
+	state, appErr = ctrl.SetZone(ctx, 1, models.ZoneUpdate{Disabled: &disabled})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if s := state.Sources[2]; !slices.Equal(s.Zones, []int{3}) || s.Listeners != 0 {
+		t.Errorf("after disabling zone 1: source 2 zones %v listeners %d, want [3] and 0", s.Zones, s.Listeners)
+	}
+}
+
+func TestRCALabels(t *testing.T) {
+	ctrl := newTestController(t)
+	ctx := context.Background()
+
+	name := ctrl.State().Sources[1].Name
+	label := "Turntable"
+	state, appErr := ctrl.SetSource(ctx, 1, models.SourceUpdate{RCALabel: &label})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if src := state.Sources[1]; src.RCALabel != "Turntable" || src.Name != name {
+		t.Errorf("source 1 = %q labelled %q, want %q labelled Turntable", src.Name, src.RCALabel, name)
+	}
+	for _, st := range state.Streams {
+		if st.ID == models.RCAStream1 && st.Name != "Turntable" {
+			t.Errorf("RCA stream name = %q, want Turntable", st.Name)
+		}
+	}
+
+	// Renaming the RCA stream relabels the source.
+	tv := "TV"
+	state, appErr = ctrl.SetStream(ctx, models.RCAStream2, models.StreamUpdate{Name: &tv})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if got := state.Sources[2].RCALabel; got != "TV" {
+		t.Errorf("source 2 label = %q after renaming its RCA stream, want TV", got)
+	}
+
+	empty := ""
+	if _, appErr := ctrl.SetSource(ctx, 1, models.SourceUpdate{RCALabel: &empty}); appErr == nil || appErr.Status != 400 || appErr.Field != "rca_label" {
+		t.Errorf("empty label: %v, want 400 on rca_label", appErr)
+	}
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+const (
+	diagnosticsLogLines = 2000             // journal lines in the bundle
+	diagnosticsTimeout  = 30 * time.Second // bounds the time spent gathering a bundle
+)
+
+// secretKey matches config keys whose values are redacted from the bundle.
+var secretKey = regexp.MustCompile(`(?i)pass|secret|token|key|auth|cred`)
+
+// journalLog returns recent daemon logs. Replaced in tests.
+var journalLog = func(ctx context.Context, lines int) ([]byte, error) {
+	return exec.CommandContext(ctx, "journalctl", "-u", "amplipi", "--no-pager", "-o", "short-iso",
+		"-n", fmt.Sprint(lines)).CombinedOutput()
+}
+
+// i2cProbe is the result of probing one preamp I2C address.
+type i2cProbe struct {
+	Unit       int    `json:"unit"`
+	Responding bool   `json:"responding"`
+	Version    string `json:"version,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// WriteDiagnostics writes a support bundle (.tar.gz) to w: system info,
+// hardware profile and EEPROM data, an I2C probe of all preamp addresses,
+// current and recent temperatures/power, stream binary availability, the
+// configuration with secrets redacted, and recent logs.
+func (c *Controller) WriteDiagnostics(ctx context.Context, w io.Writer) error {
+	ctx, cancel := context.WithTimeout(ctx, diagnosticsTimeout)
+	defer cancel()
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	now := c.now()
+	dir := "amplipi-diagnostics-" + now.Format("20060102-150405") + "/"
+
+	add := func(name string, data []byte) error {
+		hdr := &tar.Header{Name: dir + name, Mode: 0644, Size: int64(len(data)), ModTime: now}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	addJSON := func(name string, v any) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		return add(name, append(data, '\n'))
+	}
+
+	hostname, _ := os.Hostname()
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"info.json", map[string]any{
+			"info":     c.GetInfo(),
+			"time":     now,
+			"hostname": hostname,
+			"go":       runtime.Version(),
+			"arch":     runtime.GOOS + "/" + runtime.GOARCH,
+			"hardware": c.hw.IsReal(),
+		}},
+		{"hardware.json", map[string]any{
+			"profile":     c.profile,
+			"units":       c.hw.Units(),
+			"boards":      c.unitDetails(),
+			"i2c_probe":   c.probeUnits(ctx),
+			"audio_cards": readFileString("/proc/asound/cards"),
+		}},
+		{"health.json", map[string]any{
+			"current": c.readHealth(ctx),
+			"history": c.healthHistory(),
+		}},
+		{"streams.json", hardware.DetectStreamCapabilities()},
+		{"config.json", redactState(c.State())},
+	}
+	for _, f := range files {
+		if err := addJSON(f.name, f.v); err != nil {
+			return err
+		}
+	}
+
+	// Prefer the journal, which covers previous runs; fall back to the
+	// in-memory log when journald is unavailable.
+	log, err := journalLog(ctx, diagnosticsLogLines)
+	if err != nil {
+		if buffered := c.bufferedLog(); buffered != nil {
+			log = buffered
+		}
+		log = append(log, fmt.Sprintf("\n(journalctl: %v)\n", err)...)
+	}
+	if err := add("amplipi.log", log); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// probeUnits reads the firmware version at every possible preamp address.
+func (c *Controller) probeUnits(ctx context.Context) []i2cProbe {
+	probes := make([]i2cProbe, 0, hardware.MaxUnits)
+	for unit := 0; unit < hardware.MaxUnits; unit++ {
+		p := i2cProbe{Unit: unit}
+		v, err := c.hw.ReadVersion(ctx, unit)
+		if err != nil {
+			p.Error = err.Error()
+		} else {
+			p.Responding = true
+			p.Version = fmt.Sprintf("%d.%d-%x", v.Major, v.Minor, v.GitHash)
+		}
+		probes = append(probes, p)
+	}
+	return probes
+}
+
+// redactState blanks secrets in stream configs (secret schema fields and
+// secret-looking keys) and preset command data. Webhook secrets and the
+// Matter setup passcode are never marshaled.
+func redactState(s models.State) models.State {
+	for i := range s.Streams {
+		cfg := redactMap(s.Streams[i].Config)
+		if schema := models.FindStreamSchema(s.Streams[i].Type); schema != nil {
+			for _, f := range schema.Fields {
+				if v, ok := cfg[f.Key]; f.Secret && ok && v != "" {
+					cfg[f.Key] = "[redacted]"
+				}
+			}
+		}
+		s.Streams[i].Config = cfg
+	}
+	for i := range s.Presets {
+		for j := range s.Presets[i].Commands {
+			s.Presets[i].Commands[j].Data = redactMap(s.Presets[i].Commands[j].Data)
+		}
+	}
+	return s
+}
+
+// redactMap returns a copy of m with secret-looking values replaced.
+func redactMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		nested, isMap := v.(map[string]interface{})
+		switch {
+		case secretKey.MatchString(k) && v != nil && v != "":
+			out[k] = "[redacted]"
+		case isMap:
+			out[k] = redactMap(nested)
+		default:
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// readFileString returns a file's contents, or the read error as text.
+func readFileString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err.Error()
+	}
+	return string(data)
+}
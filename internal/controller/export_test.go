@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+)
+
+// SetClock replaces the controller's clock.
+func (c *Controller) SetClock(now func() time.Time) { c.now = now }
+
+// CheckSourceIdle runs one pass of the source idle policies.
+func (c *Controller) CheckSourceIdle() { c.checkSourceIdle() }
+
+// WaitStreamSyncs waits for the background stream manager syncs started so
+// far.
+func (c *Controller) WaitStreamSyncs() { c.syncs.Wait() }
+
+// WaitCastSyncs waits for the background Cast syncs started so far.
+func (c *Controller) WaitCastSyncs() { c.castSyncs.Wait() }
+
+// ApplyNightMode runs one pass of the zone night mode schedules.
+func (c *Controller) ApplyNightMode() { c.applyNightMode() }
+
+// RefreshTriggers runs one pass of the GPIO trigger outputs.
+func (c *Controller) RefreshTriggers() { c.refreshTriggers(context.Background()) }
+
+// ReportFanStatus feeds one unit's fan status to the over-temperature check.
+func (c *Controller) ReportFanStatus(unit int, f hardware.FanStatus) {
+	c.checkOverTemp([]healthSample{{Time: c.now(), Unit: unit, Fan: &f}})
+}
+
+// NewerVersion exposes the release version comparison.
+func NewerVersion(a, b string) bool { return newerVersion(a, b) }
+
+// SetOSHostname replaces the OS hostname setter until the test ends.
+func SetOSHostname(t interface{ Cleanup(func()) }, f func(ctx context.Context, name string) error) {
+	prev := setOSHostname
+	setOSHostname = f
+	t.Cleanup(func() { setOSHostname = prev })
+}
+
+// SetSystemPower replaces the reboot and poweroff command until the test
+// ends.
+func SetSystemPower(t interface{ Cleanup(func()) }, f func(ctx context.Context, action string) error) {
+	prev := systemPower
+	systemPower = f
+	t.Cleanup(func() { systemPower = prev })
+}
+
+// SetTimedatectl replaces the timedatectl command until the test ends.
+func SetTimedatectl(t interface{ Cleanup(func()) }, f func(ctx context.Context, args ...string) (string, error)) {
+	prev := timedatectl
+	timedatectl = f
+	t.Cleanup(func() { timedatectl = prev })
+}
+
+// SetAirPlayNetworkNames replaces the browse for AirPlay receivers on the
+// network until the test ends.
+func SetAirPlayNetworkNames(t interface{ Cleanup(func()) }, f func(ctx context.Context) ([]string, error)) {
+	prev := airplayNetworkNames
+	airplayNetworkNames = f
+	t.Cleanup(func() { airplayNetworkNames = prev })
+}
+
+// RecordHealth runs one pass of the health history.
+func (c *Controller) RecordHealth() { c.recordHealth(context.Background()) }
+
+// RefreshLEDActivity runs one pass of the LED settings.
+func (c *Controller) RefreshLEDActivity() { c.refreshLEDActivity(context.Background()) }
+
+// RefreshAmps runs one pass of the amp enables and unit power.
+func (c *Controller) RefreshAmps() {
+	c.mu.Lock()
+	c.refreshAmps()
+	c.mu.Unlock()
+}
+
+// RefreshFans runs one pass of the fan curve.
+func (c *Controller) RefreshFans() {
+	c.refreshFans(context.Background())
+}
+
+// SetLevelMeter replaces the source level meter of the silence watchdog.
+func (c *Controller) SetLevelMeter(meter func(ctx context.Context, sid int, d time.Duration) (float64, error)) {
+	c.meter = meter
+}
+
+// CheckSilence runs one silence watchdog pass.
+func (c *Controller) CheckSilence() {
+	c.checkSilence(context.Background())
+}
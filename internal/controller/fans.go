@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// fanReading is the last fan curve pass of one unit.
+type fanReading struct {
+	tempC   float32
+	duty    float64
+	err     string // why the temperature could not be read or the duty written
+	written bool   // a duty was written, so the firmware is not in control
+}
+
+// GetFans returns the fan control mode, the fan curve and each unit's
+// temperature and fan duty.
+func (c *Controller) GetFans(ctx context.Context) models.FanStatus {
+	curve := c.GetSettings().FanCurve
+	st := models.FanStatus{
+		Custom: len(curve) > 0 && curve.Validate() == nil && c.fanControllable(),
+		Curve:  curve,
+		Units:  []models.UnitFanDuty{},
+	}
+	if c.profile == nil {
+		return st
+	}
+	st.Mode = c.profile.FanMode.String()
+	if st.Curve == nil {
+		st.Curve = models.FanCurve{}
+	}
+	c.fanMu.Lock()
+	defer c.fanMu.Unlock()
+	for _, u := range fanUnits(c.profile) {
+		ud := models.UnitFanDuty{Unit: u.Index}
+		if r, ok := c.fanDuty[u.Index]; ok && st.Custom {
+			ud.TempC, ud.Error = r.tempC, r.err
+			if r.written {
+				ud.Duty = &r.duty
+			}
+		} else if t, err := c.hottest(ctx, u.Index); err == nil {
+			ud.TempC = t
+		} else {
+			ud.Error = err.Error()
+		}
+		st.Units = append(st.Units, ud)
+	}
+	return st
+}
+
+// SetFanCurve sets the fan curve and applies it at once. An empty curve
+// hands the fans back to the firmware.
+func (c *Controller) SetFanCurve(ctx context.Context, upd models.FanCurveUpdate) (models.FanStatus, *models.AppError) {
+	if len(upd.Curve) > 0 {
+		if !c.fanControllable() {
+			mode := "unknown"
+			if c.profile != nil {
+				mode = c.profile.FanMode.String()
+			}
+			return models.FanStatus{}, models.ErrConflict(fmt.Sprintf("a fan curve needs pwm or linear fan control; this power board's is %s", mode))
+		}
+		if err := upd.Curve.Validate(); err != nil {
+			return models.FanStatus{}, models.ErrBadRequest(err.Error()).WithField("curve")
+		}
+	} else {
+		upd.Curve = nil
+	}
+	_, err := c.apply(func(s *models.State) error {
+		s.Settings.FanCurve = upd.Curve
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.FanStatus{}, appErr
+		}
+		return models.FanStatus{}, models.ErrInternal(err.Error())
+	}
+	c.refreshFans(ctx)
+	return c.GetFans(ctx), nil
+}
+
+// RunFanCurve drives the fans from the fan curve, if one is set, every
+// interval. Blocks until ctx is cancelled.
+func (c *Controller) RunFanCurve(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.refreshFans(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshFans runs one pass of the fan curve: each unit's fans are set to
+// the curve's duty at its hottest temperature, or to full duty if it cannot
+// be read. Without a valid curve (one saved before the rules tightened may
+// not be), units that were driven are handed back to the firmware; failed
+// writes are retried on the next pass.
+func (c *Controller) refreshFans(ctx context.Context) {
+	curve := c.GetSettings().FanCurve
+	c.fanMu.Lock()
+	defer c.fanMu.Unlock()
+	if len(curve) == 0 || curve.Validate() != nil || !c.fanControllable() {
+		for unit, r := range c.fanDuty {
+			if r.written {
+				if err := c.hw.Write(ctx, unit, hardware.RegFanDuty, hardware.FanDutyAuto); err != nil {
+					slog.Warn("fans: handing back to firmware failed", "unit", unit, "err", err)
+					continue
+				}
+			}
+			delete(c.fanDuty, unit)
+		}
+		return
+	}
+	for _, u := range fanUnits(c.profile) {
+		r := fanReading{duty: 1, written: c.fanDuty[u.Index].written}
+		if t, err := c.hottest(ctx, u.Index); err == nil {
+			r.tempC, r.duty = t, curve.Duty(t)
+		} else {
+			r.err = "temps: " + err.Error()
+		}
+		if err := c.hw.Write(ctx, u.Index, hardware.RegFanDuty, hardware.FanDutyToReg(r.duty)); err != nil {
+			slog.Warn("fans: duty write failed", "unit", u.Index, "err", err)
+			r.err = "duty: " + err.Error()
+		} else {
+			r.written = true
+		}
+		c.fanDuty[u.Index] = r
+	}
+}
+
+// fanControllable reports whether the power board's fans can follow a
+// curve: PWM and linear control can, the MAX6644 and forced modes cannot.
+func (c *Controller) fanControllable() bool {
+	return c.profile != nil && (c.profile.FanMode == hardware.FanModePWM || c.profile.FanMode == hardware.FanModeLinear)
+}
+
+// fanUnits returns the units with amps, and so fans.
+func fanUnits(p *hardware.HardwareProfile) []hardware.UnitInfo {
+	var units []hardware.UnitInfo
+	for _, u := range p.Units {
+		if u.ZoneCount > 0 {
+			units = append(units, u)
+		}
+	}
+	return units
+}
+
+// hottest reads a unit's hottest amp heatsink or power supply temperature.
+// Disconnected sensors are skipped; a shorted one reads hottest of all.
+func (c *Controller) hottest(ctx context.Context, unit int) (float32, error) {
+	t, err := c.hw.ReadTemps(ctx, unit)
+	if err != nil {
+		return 0, err
+	}
+	hottest, ok := float32(0), false
+	for _, v := range []float32{t.Amp1C, t.Amp2C, t.PSU1C, t.PSU2C} {
+		if v == -999 {
+			continue
+		}
+		if !ok || v > hottest {
+			hottest, ok = v, true
+		}
+	}
+	if !ok {
+		return 0, fmt.Errorf("no temperature sensor connected")
+	}
+	return hottest, nil
+}
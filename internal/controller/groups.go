@@ -3,7 +3,9 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 
+	"github.com/micro-nova/amplipi-go/internal/auth"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
@@ -30,15 +32,27 @@ func (c *Controller) GetGroup(id int) (*models.Group, *models.AppError) {
 
 // CreateGroup creates a new group and returns the updated state.
 func (c *Controller) CreateGroup(ctx context.Context, req models.GroupUpdate) (models.State, *models.AppError) {
-	if req.Name == nil || *req.Name == "" {
-		return models.State{}, models.ErrBadRequest("group name is required")
+	if req.Name == nil {
+		return models.State{}, models.ErrBadRequest("group name is required").WithField("name")
 	}
 
 	state, err := c.apply(func(s *models.State) error {
+		if err := checkName("group", *req.Name, groupNames(s, -1)); err != nil {
+			return err
+		}
+		if err := checkGroupZones(s, req.ZoneIDs); err != nil {
+			return err
+		}
+		id := nextGroupID(s)
+		if err := checkGroupMembers(s, id, req.GroupIDs); err != nil {
+			return err
+		}
 		g := models.Group{
-			ID:      nextGroupID(s),
-			Name:    *req.Name,
-			ZoneIDs: req.ZoneIDs,
+			ID:             id,
+			Name:           *req.Name,
+			ZoneIDs:        req.ZoneIDs,
+			GroupIDs:       req.GroupIDs,
+			ExcludeZoneIDs: req.ExcludeZoneIDs,
 		}
 		if req.SourceID != nil {
 			v := *req.SourceID
@@ -68,20 +82,45 @@ func (c *Controller) SetGroup(ctx context.Context, id int, upd models.GroupUpdat
 		if g == nil {
 			return models.ErrNotFound("group not found")
 		}
+		if appErr := checkGroupScope(ctx, s, id); appErr != nil {
+			return appErr
+		}
+		if auth.ScopeFrom(ctx) != nil && (upd.ZoneIDs != nil || upd.GroupIDs != nil || upd.ExcludeZoneIDs != nil) {
+			return models.ErrForbidden("this key may not change which zones are in a group")
+		}
+		if auth.ScopeFrom(ctx) != nil && upd.Name != nil {
+			return models.ErrForbidden("this key may only change a group's volume, mute and source")
+		}
 
 		if upd.Name != nil {
+			if err := checkName("group", *upd.Name, groupNames(s, id)); err != nil {
+				return err
+			}
 			g.Name = *upd.Name
 		}
 		if upd.ZoneIDs != nil {
+			if err := checkGroupZones(s, upd.ZoneIDs); err != nil {
+				return err
+			}
 			g.ZoneIDs = upd.ZoneIDs
 		}
+		if upd.GroupIDs != nil {
+			if err := checkGroupMembers(s, id, upd.GroupIDs); err != nil {
+				return err
+			}
+			g.GroupIDs = upd.GroupIDs
+		}
+		if upd.ExcludeZoneIDs != nil {
+			g.ExcludeZoneIDs = upd.ExcludeZoneIDs
+		}
+		zones := groupZones(s, g)
 		if upd.SourceID != nil {
 			v := *upd.SourceID
 			g.SourceID = &v
-			// Apply source to all member zones
-			for _, zid := range g.ZoneIDs {
+			// Apply source to all member zones, including those of member groups
+			for _, zid := range zones {
 				z := findZone(s, zid)
-				if z == nil {
+				if z == nil || sourceLocked(ctx, z) {
 					continue
 				}
 				src := *upd.SourceID
@@ -94,7 +133,7 @@ func (c *Controller) SetGroup(ctx context.Context, id int, upd models.GroupUpdat
 
 		// Volume delta: apply to each member zone
 		if upd.Vol != nil {
-			for _, zid := range g.ZoneIDs {
+			for _, zid := range zones {
 				z := findZone(s, zid)
 				if z == nil {
 					continue
@@ -108,7 +147,7 @@ func (c *Controller) SetGroup(ctx context.Context, id int, upd models.GroupUpdat
 			}
 		} else if upd.VolF != nil {
 			// VolF sets absolute float volume on all zones
-			for _, zid := range g.ZoneIDs {
+			for _, zid := range zones {
 				z := findZone(s, zid)
 				if z == nil {
 					continue
@@ -119,11 +158,24 @@ func (c *Controller) SetGroup(ctx context.Context, id int, upd models.GroupUpdat
 					return err
 				}
 			}
+		} else if upd.VolDeltaF != nil {
+			// Relative float delta, scaled to each member zone's range
+			for _, zid := range zones {
+				z := findZone(s, zid)
+				if z == nil {
+					continue
+				}
+				d := *upd.VolDeltaF
+				zupd := models.ZoneUpdate{VolDeltaF: &d}
+				if err := applyZoneUpdate(ctx, c, s, z, zupd); err != nil {
+					return err
+				}
+			}
 		}
 
 		// Mute: apply to all member zones
 		if upd.Mute != nil {
-			for _, zid := range g.ZoneIDs {
+			for _, zid := range zones {
 				z := findZone(s, zid)
 				if z == nil {
 					continue
@@ -154,6 +206,12 @@ func (c *Controller) DeleteGroup(_ context.Context, id int) (models.State, *mode
 		for i, g := range s.Groups {
 			if g.ID == id {
 				s.Groups = append(s.Groups[:i], s.Groups[i+1:]...)
+				// Groups it was nested in lose it
+				for j := range s.Groups {
+					s.Groups[j].GroupIDs = slices.DeleteFunc(s.Groups[j].GroupIDs, func(m int) bool { return m == id })
+				}
+				updateGroupAggregates(s)
+				pruneKeypad(s)
 				return nil
 			}
 		}
@@ -172,22 +230,25 @@ func (c *Controller) DeleteGroup(_ context.Context, id int) (models.State, *mode
 func updateGroupAggregates(s *models.State) {
 	for gi := range s.Groups {
 		g := &s.Groups[gi]
-		if len(g.ZoneIDs) == 0 {
+		zones := groupZones(s, g)
+		if len(zones) == 0 {
 			continue
 		}
 
 		allMuted := true
 		anyMuted := false
 		totalVol := 0
+		totalVolF := 0.0
 		validZones := 0
 		var unanimousSource *int
 
-		for _, zid := range g.ZoneIDs {
+		for _, zid := range zones {
 			z := findZone(s, zid)
 			if z == nil {
 				continue
 			}
 			totalVol += z.Vol
+			totalVolF += z.VolF
 			validZones++
 			if z.Mute {
 				anyMuted = true
@@ -207,7 +268,9 @@ func updateGroupAggregates(s *models.State) {
 		if validZones > 0 {
 			avgVol := totalVol / validZones
 			g.Vol = &avgVol
-			avgVolF := models.DBToVolF(avgVol)
+			// Averaged from the zones' own vol_f, which follows their
+			// calibration, rather than converted from avgVol
+			avgVolF := totalVolF / float64(validZones)
 			g.VolF = &avgVolF
 		}
 
@@ -219,3 +282,66 @@ func updateGroupAggregates(s *models.State) {
 		g.SourceID = unanimousSource
 	}
 }
+
+// groupZones returns the zones of g: its own and those of its member
+// groups, less its excluded zones. Each zone is listed once, in the order
+// first reached.
+func groupZones(s *models.State, g *models.Group) []int {
+	return resolveGroup(s, g, make(map[int]bool))
+}
+
+// resolveGroup resolves g's zones, skipping member groups already being
+// resolved in case a hand-edited config nests groups in a cycle.
+func resolveGroup(s *models.State, g *models.Group, resolving map[int]bool) []int {
+	resolving[g.ID] = true
+	defer delete(resolving, g.ID)
+
+	all := slices.Clone(g.ZoneIDs)
+	for _, id := range g.GroupIDs {
+		if m := findGroup(s, id); m != nil && !resolving[id] {
+			all = append(all, resolveGroup(s, m, resolving)...)
+		}
+	}
+	zones := make([]int, 0, len(all))
+	for _, z := range all {
+		if !slices.Contains(zones, z) && !slices.Contains(g.ExcludeZoneIDs, z) {
+			zones = append(zones, z)
+		}
+	}
+	return zones
+}
+
+// checkGroupMembers rejects member groups of group id that do not exist or
+// that contain it, which would nest it in itself.
+func checkGroupMembers(s *models.State, id int, members []int) error {
+	for _, m := range members {
+		if m == id {
+			return models.ErrBadRequest("a group cannot contain itself").WithField("groups")
+		}
+		mg := findGroup(s, m)
+		if mg == nil {
+			return models.ErrBadRequest(fmt.Sprintf("group %d not found", m)).WithField("groups")
+		}
+		if groupContains(s, mg, id, make(map[int]bool)) {
+			return models.ErrBadRequest(fmt.Sprintf("group %q contains this group; nesting it here would form a cycle", mg.Name)).WithField("groups")
+		}
+	}
+	return nil
+}
+
+// groupContains reports whether group id is nested anywhere within g.
+func groupContains(s *models.State, g *models.Group, id int, seen map[int]bool) bool {
+	if seen[g.ID] {
+		return false
+	}
+	seen[g.ID] = true
+	for _, m := range g.GroupIDs {
+		if m == id {
+			return true
+		}
+		if mg := findGroup(s, m); mg != nil && groupContains(s, mg, id, seen) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// healthHistoryLen is how many health samples are kept (6 hours at the
+// default one-minute interval).
+const healthHistoryLen = 360
+
+// healthSample is one reading of a unit's temperatures, power rails and fan.
+type healthSample struct {
+	Time  time.Time           `json:"time"`
+	Unit  int                 `json:"unit"`
+	Temps *hardware.Temps     `json:"temps,omitempty"`
+	Power *hardware.Power     `json:"power,omitempty"`
+	Fan   *hardware.FanStatus `json:"fan,omitempty"`
+	Error string              `json:"error,omitempty"`
+}
+
+// RunHealthHistory records temperatures, power and fan status of every unit
+// every interval for the diagnostics bundle, and emits over_temp events.
+// Blocks until ctx is cancelled.
+func (c *Controller) RunHealthHistory(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.recordHealth(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// recordHealth reads the health of every unit into the history.
+func (c *Controller) recordHealth(ctx context.Context) {
+	samples := c.readHealth(ctx)
+	c.checkOverTemp(samples)
+	c.healthMu.Lock()
+	c.health = append(c.health, samples...)
+	if over := len(c.health) - healthHistoryLen*max(1, len(samples)); over > 0 {
+		c.health = append([]healthSample(nil), c.health[over:]...)
+	}
+	c.healthMu.Unlock()
+}
+
+// readHealth reads the current health of every unit.
+func (c *Controller) readHealth(ctx context.Context) []healthSample {
+	now := c.now()
+	var samples []healthSample
+	for _, unit := range c.hw.Units() {
+		s := healthSample{Time: now, Unit: unit}
+		if t, err := c.hw.ReadTemps(ctx, unit); err == nil {
+			s.Temps = &t
+		} else {
+			s.Error = err.Error()
+		}
+		if p, err := c.hw.ReadPower(ctx, unit); err == nil {
+			s.Power = &p
+		} else {
+			s.Error = err.Error()
+		}
+		if f, err := c.hw.ReadFanStatus(ctx, unit); err == nil {
+			s.Fan = &f
+		} else {
+			s.Error = err.Error()
+		}
+		samples = append(samples, s)
+	}
+	return samples
+}
+
+// healthHistory returns a copy of the recorded health samples.
+func (c *Controller) healthHistory() []healthSample {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	return append([]healthSample{}, c.health...)
+}
+
+// latestTemps returns each unit's most recent temperature reading.
+func (c *Controller) latestTemps() map[int]*models.UnitTemps {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	latest := make(map[int]*models.UnitTemps)
+	for _, s := range c.health {
+		if s.Temps != nil {
+			latest[s.Unit] = &models.UnitTemps{
+				Time:  s.Time,
+				Amp1C: s.Temps.Amp1C,
+				Amp2C: s.Temps.Amp2C,
+				PSU1C: s.Temps.PSU1C,
+				PSU2C: s.Temps.PSU2C,
+			}
+		}
+	}
+	return latest
+}
@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// hostnameHelper sets the OS hostname and its /etc/hosts entry, which
+// Debian resolves its own hostname through and sudo complains about on
+// every call when stale. It is root-owned, installed by setup.sh and the
+// only way the daemon may rename the unit through sudo; see
+// scripts/configs/amplipi-hostname.
+const hostnameHelper = "/usr/local/sbin/amplipi-hostname"
+
+// setOSHostname renames the unit through hostnameHelper. Replaced in tests.
+var setOSHostname = func(ctx context.Context, name string) error {
+	if out, err := exec.CommandContext(ctx, "sudo", "-n", hostnameHelper, name).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %v: %s", hostnameHelper, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// SetHostname renames the unit: the OS hostname it is reachable under as
+// <hostname>.local, and the friendly name it is advertised under over
+// mDNS. Zeroconf re-registers on the hostname_changed event.
+func (c *Controller) SetHostname(ctx context.Context, upd models.HostnameUpdate) (models.Info, *models.AppError) {
+	if upd.Hostname != nil {
+		if err := models.ValidateHostname(*upd.Hostname); err != nil {
+			return models.Info{}, models.ErrBadRequest(err.Error()).WithField("hostname")
+		}
+	}
+	if upd.FriendlyName != nil && *upd.FriendlyName != "" {
+		if err := models.ValidateName(*upd.FriendlyName); err != nil {
+			return models.Info{}, models.ErrBadRequest(err.Error()).WithField("friendly_name")
+		}
+	}
+
+	if upd.Hostname != nil && *upd.Hostname != c.Hostname() {
+		if err := setOSHostname(ctx, *upd.Hostname); err != nil {
+			return models.Info{}, models.ErrInternal("setting hostname: " + err.Error())
+		}
+		c.mu.Lock()
+		c.hostname = *upd.Hostname
+		c.mu.Unlock()
+	}
+	if upd.FriendlyName != nil {
+		_, err := c.apply(func(s *models.State) error {
+			s.Settings.FriendlyName = *upd.FriendlyName
+			return nil
+		})
+		if err != nil {
+			return models.Info{}, models.ErrInternal(err.Error())
+		}
+	}
+
+	info := c.GetInfo()
+	c.bus.Emit(models.Event{Type: models.EventHostnameChanged, Time: c.now(), Data: models.HostnameChanged{
+		Hostname:     info.Hostname,
+		FriendlyName: info.FriendlyName,
+	}})
+	return info, nil
+}
+
+// Hostname returns the unit's OS hostname.
+func (c *Controller) Hostname() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.hostname
+}
+
+// FriendlyName returns the name the unit is advertised under over mDNS:
+// the friendly name from settings, or the hostname.
+func (c *Controller) FriendlyName() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.state.Settings.FriendlyName != "" {
+		return c.state.Settings.FriendlyName
+	}
+	return c.hostname
+}
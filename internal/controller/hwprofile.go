@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// overrideReason is why streams are unavailable when the hardware profile
+// override disables them.
+const overrideReason = "disabled by the hardware profile override"
+
+// SetProfileOverrideDir enables changing the hardware profile override
+// saved in configDir. Must be called before the HTTP server starts.
+func (c *Controller) SetProfileOverrideDir(configDir string) {
+	c.mu.Lock()
+	c.overrideDir = configDir
+	c.mu.Unlock()
+}
+
+// GetProfileOverride returns the saved hardware profile override and the
+// one the running profile uses.
+func (c *Controller) GetProfileOverride() (models.ProfileOverrideStatus, *models.AppError) {
+	c.mu.RLock()
+	dir := c.overrideDir
+	c.mu.RUnlock()
+	if dir == "" {
+		return models.ProfileOverrideStatus{}, models.ErrBadRequest("the hardware profile override is not available")
+	}
+	saved, err := config.LoadProfileOverride(dir)
+	if err != nil {
+		return models.ProfileOverrideStatus{}, models.ErrInternal(err.Error())
+	}
+	return c.overrideStatus(saved), nil
+}
+
+// SetProfileOverride changes the saved hardware profile override, which is
+// applied at the next start. Fields left out of upd are kept; units 0,
+// analog_sources true and an empty unavailable_streams go back to what
+// is detected.
+func (c *Controller) SetProfileOverride(_ context.Context, upd hardware.ProfileOverride) (models.ProfileOverrideStatus, *models.AppError) {
+	saved, appErr := c.GetProfileOverride()
+	if appErr != nil {
+		return models.ProfileOverrideStatus{}, appErr
+	}
+	o := saved.Saved
+	if upd.Units != nil {
+		if *upd.Units < 0 || *upd.Units > hardware.MaxUnits {
+			return models.ProfileOverrideStatus{}, models.ErrBadRequest(fmt.Sprintf("units must be 1-%d, or 0 to detect them", hardware.MaxUnits)).WithField("units")
+		}
+		o.Units = upd.Units
+		if *upd.Units == 0 {
+			o.Units = nil
+		}
+	}
+	if upd.AnalogSources != nil {
+		o.AnalogSources = upd.AnalogSources
+		if *upd.AnalogSources {
+			o.AnalogSources = nil
+		}
+	}
+	if upd.UnavailableStreams != nil {
+		o.UnavailableStreams = nil
+		for _, t := range upd.UnavailableStreams {
+			schema := models.FindStreamSchema(t)
+			if schema == nil {
+				return models.ProfileOverrideStatus{}, models.ErrBadRequest(fmt.Sprintf("unknown stream type %q", t)).WithField("unavailable_streams")
+			}
+			if !slices.Contains(o.UnavailableStreams, schema.Type) {
+				o.UnavailableStreams = append(o.UnavailableStreams, schema.Type)
+			}
+		}
+	}
+
+	c.mu.RLock()
+	dir := c.overrideDir
+	c.mu.RUnlock()
+	if err := config.SaveProfileOverride(dir, o); err != nil {
+		return models.ProfileOverrideStatus{}, models.ErrInternal(err.Error())
+	}
+	return c.overrideStatus(o), nil
+}
+
+// overrideStatus reports saved against the override the profile uses.
+func (c *Controller) overrideStatus(saved hardware.ProfileOverride) models.ProfileOverrideStatus {
+	var active hardware.ProfileOverride
+	if c.profile != nil && c.profile.Override != nil {
+		active = *c.profile.Override
+	}
+	return models.ProfileOverrideStatus{
+		Saved:           saved,
+		Active:          active,
+		RestartRequired: !reflect.DeepEqual(saved, active),
+	}
+}
+
+// streamDisabled reports whether the hardware profile override makes a
+// stream type unavailable, by name or by disabling the analog inputs.
+func (c *Controller) streamDisabled(schema *models.StreamSchema) bool {
+	if c.profile == nil {
+		return false
+	}
+	if c.profile.AnalogDisabled() && (schema.Type == models.StreamTypeRCA || schema.Type == models.StreamTypeAux) {
+		return true
+	}
+	return c.profile.StreamDisabled(schema.Type, schema.Aliases...)
+}
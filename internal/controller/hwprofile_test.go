@@ -2,8 +2,15 @@ package controller_test
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/audio"
 	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/controller"
 	"github.com/micro-nova/amplipi-go/internal/events"
@@ -66,7 +73,10 @@ func TestCreateStream_AvailableType(t *testing.T) {
 	ctrl := newProfiledController(t, p)
 	ctx := context.Background()
 
-	_, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "My Pandora", Type: "pandora"})
+	_, appErr := ctrl.CreateStream(ctx, models.StreamCreate{
+		Name: "My Pandora", Type: "pandora",
+		Config: map[string]interface{}{"user": "me@example.com", "password": "secret"},
+	})
 	if appErr != nil {
 		t.Fatalf("CreateStream with available type should succeed: %v", appErr)
 	}
@@ -85,12 +95,123 @@ func TestCreateStream_RCAAlwaysAvailable(t *testing.T) {
 	ctrl := newProfiledController(t, p)
 	ctx := context.Background()
 
-	_, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "RCA Input", Type: "rca"})
+	_, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "RCA Input", Type: "rca", Config: map[string]interface{}{"index": 2}})
 	if appErr != nil {
 		t.Fatalf("CreateStream with rca type should always succeed: %v", appErr)
 	}
 }
 
+func TestLoadConfig_UnavailableStreamType(t *testing.T) {
+	// A config from a system with pianobar installed, loaded where it is not
+	p := &hardware.HardwareProfile{
+		Units: []hardware.UnitInfo{
+			{Board: hardware.BoardInfo{UnitType: hardware.UnitTypeMain}},
+		},
+		TotalSources: 4,
+		TotalZones:   6,
+		Streams: []hardware.StreamCapability{
+			{Type: "pandora", Available: false, Reason: "binary not found"},
+			{Type: "internet_radio", Available: true, Binary: "/usr/bin/vlc"},
+		},
+	}
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), p, config.NewMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	evs := bus.SubscribeEvents("test")
+	defer bus.UnsubscribeEvents("test")
+	ctx := context.Background()
+
+	incoming := ctrl.State()
+	incoming.Streams = []models.Stream{
+		{ID: 1000, Name: "Pandora", Type: "pandora", Config: map[string]interface{}{"user": "me", "password": "pw"}},
+		{ID: 1001, Name: "Radio", Type: "internetradio", Config: map[string]interface{}{"url": "http://example.com"}},
+	}
+	state, appErr := ctrl.LoadConfig(ctx, incoming)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	for _, st := range state.Streams {
+		switch st.ID {
+		case 1000:
+			if st.Info.State != "unavailable" || st.Info.Reason != "binary not found" {
+				t.Errorf("pandora info = %+v", st.Info)
+			}
+		case 1001:
+			if st.Info.State == "unavailable" {
+				t.Errorf("internet radio marked unavailable: %+v", st.Info)
+			}
+		}
+	}
+
+	var warned []models.StreamUnavailable
+	for len(evs) > 0 {
+		if ev := <-evs; ev.Type == models.EventStreamUnavailable {
+			warned = append(warned, ev.Data.(models.StreamUnavailable))
+		}
+	}
+	if len(warned) != 1 || warned[0].StreamID != 1000 || warned[0].Reason != "binary not found" {
+		t.Errorf("stream_unavailable events = %+v", warned)
+	}
+
+	// Later changes keep the mark without warning again
+	name := "Pandora (old)"
+	if _, appErr := ctrl.SetStream(ctx, 1000, models.StreamUpdate{Name: &name}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	for len(evs) > 0 {
+		if ev := <-evs; ev.Type == models.EventStreamUnavailable {
+			t.Error("stream_unavailable emitted again")
+		}
+	}
+	if _, appErr := ctrl.RestartStream(ctx, 1000); appErr == nil || appErr.Status != 400 {
+		t.Errorf("restarting an unavailable stream: %v", appErr)
+	}
+}
+
+func TestRestartStream_Redetects(t *testing.T) {
+	p := &hardware.HardwareProfile{
+		Units: []hardware.UnitInfo{
+			{Board: hardware.BoardInfo{UnitType: hardware.UnitTypeMain}},
+		},
+		TotalSources: 4,
+		TotalZones:   6,
+		Streams: []hardware.StreamCapability{
+			{Type: "pandora", Available: false, Reason: "binary not found"},
+		},
+	}
+	ctrl := newProfiledController(t, p)
+	ctx := context.Background()
+
+	incoming := ctrl.State()
+	incoming.Streams = []models.Stream{
+		{ID: 1000, Name: "Pandora", Type: "pandora", Config: map[string]interface{}{"user": "me", "password": "pw"}},
+	}
+	if _, appErr := ctrl.LoadConfig(ctx, incoming); appErr != nil {
+		t.Fatal(appErr)
+	}
+
+	// pianobar is installed after startup
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "pianobar"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+
+	ctrl.RestartStream(ctx, 1000) // streams are off in tests, so this still fails
+	st, appErr := ctrl.GetStream(1000)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if st.Info.State == "unavailable" {
+		t.Errorf("pandora still unavailable after restart: %+v", st.Info)
+	}
+	if !p.StreamAvailable("pandora") {
+		t.Error("profile still reports pandora unavailable")
+	}
+}
+
 func TestSetSource_NoSourcesOnExpander(t *testing.T) {
 	// Profile with expansion unit only → SetSource → 400 error
 	p := &hardware.HardwareProfile{
@@ -237,7 +358,7 @@ func TestFactoryReset_WithProfile(t *testing.T) {
 	ctrl.SetZone(ctx, 0, models.ZoneUpdate{Name: &name})
 
 	// Reset
-	state, appErr := ctrl.FactoryReset(ctx)
+	state, appErr := ctrl.FactoryReset(ctx, models.ResetConfig)
 	if appErr != nil {
 		t.Fatalf("FactoryReset: %v", appErr)
 	}
@@ -247,3 +368,695 @@ func TestFactoryReset_WithProfile(t *testing.T) {
 		t.Errorf("Zones after reset = %d, want 6", len(state.Zones))
 	}
 }
+
+func TestZoneLimitsFollowProfile(t *testing.T) {
+	units := []int{0, 1, 2, 3, 4, 5, 6, 7}
+	hw := hardware.NewMockWithUnits(units)
+	p, err := hardware.Detect(context.Background(), hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hw, p, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	// Zones for the expanders are added to the default six-zone config.
+	if n := len(ctrl.State().Zones); n != 48 {
+		t.Fatalf("zones = %d, want 48", n)
+	}
+	name := "Garage"
+	if _, appErr := ctrl.SetZone(ctx, 47, models.ZoneUpdate{Name: &name}); appErr != nil {
+		t.Errorf("SetZone(47): %v", appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 48, models.ZoneUpdate{Name: &name}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("SetZone(48) beyond hardware: %v", appErr)
+	}
+}
+
+func TestLoadConfig_ExceedsHardware(t *testing.T) {
+	ctrl := newProfiledController(t, hardware.MockProfile())
+	ctx := context.Background()
+
+	cfg := ctrl.State()
+	cfg.Zones = append(cfg.Zones, models.Zone{ID: 6, Name: "Zone 7"})
+	if _, appErr := ctrl.LoadConfig(ctx, cfg); appErr == nil || appErr.Status != 400 {
+		t.Fatalf("LoadConfig with 7 zones on a 6-zone unit: %v", appErr)
+	}
+	if n := len(ctrl.State().Zones); n != 6 {
+		t.Errorf("zones = %d after rejected load, want 6", n)
+	}
+
+	cfg = ctrl.State()
+	cfg.Sources = append(cfg.Sources, models.Source{ID: 4, Name: "Input 5"})
+	if _, appErr := ctrl.LoadConfig(ctx, cfg); appErr == nil || appErr.Status != 400 {
+		t.Errorf("LoadConfig with 5 sources: %v", appErr)
+	}
+}
+
+func TestValidateConfig_RejectsAsLoad(t *testing.T) {
+	ctrl := newProfiledController(t, hardware.MockProfile())
+	ctx := context.Background()
+
+	tooMany := ctrl.State()
+	tooMany.Zones = append(tooMany.Zones, models.Zone{ID: 6, Name: "Zone 7"})
+	dupIDs := ctrl.State()
+	dupIDs.Zones = append(dupIDs.Zones, dupIDs.Zones[0])
+	badIdle := ctrl.State()
+	badIdle.Settings.SourceIdle = []models.SourceIdlePolicy{{SourceID: 7}}
+
+	for name, cfg := range map[string]models.State{"zones": tooMany, "ids": dupIDs, "source_idle": badIdle} {
+		_, loadErr := ctrl.LoadConfig(ctx, cfg)
+		_, dryErr := ctrl.ValidateConfig(cfg)
+		if loadErr == nil || dryErr == nil {
+			t.Errorf("%s: LoadConfig = %v, ValidateConfig = %v, want both rejected", name, loadErr, dryErr)
+			continue
+		}
+		if loadErr.Error() != dryErr.Error() || len(loadErr.Fields) != len(dryErr.Fields) {
+			t.Errorf("%s: ValidateConfig = %v %v, LoadConfig = %v %v", name, dryErr, dryErr.Fields, loadErr, loadErr.Fields)
+		}
+	}
+}
+
+func TestDefaultStateFromProfile_Streamer(t *testing.T) {
+	// Streamer unit with two DACs → 2 sources on the DAC outputs, no zones
+	p := &hardware.HardwareProfile{
+		Units:                    []hardware.UnitInfo{{Board: hardware.BoardInfo{UnitType: hardware.UnitTypeStreamer}}},
+		IsStreamer:               true,
+		AvailablePhysicalOutputs: []int{0, 2},
+	}
+
+	state := models.DefaultStateFromProfile(p)
+
+	if len(state.Zones) != 0 || state.Zones == nil {
+		t.Errorf("Zones = %v, want empty", state.Zones)
+	}
+	if len(state.Sources) != 2 || state.Sources[0].ID != 0 || state.Sources[1].ID != 2 {
+		t.Errorf("Sources = %+v, want outputs 0 and 2", state.Sources)
+	}
+	if len(state.Streams) != 0 {
+		t.Errorf("Streams = %d, want 0 (no RCA inputs)", len(state.Streams))
+	}
+}
+
+func TestStreamerMode_DropsZones(t *testing.T) {
+	p := &hardware.HardwareProfile{
+		Units:      []hardware.UnitInfo{{Board: hardware.BoardInfo{UnitType: hardware.UnitTypeStreamer}}},
+		IsStreamer: true,
+	}
+	// The store starts from the generic six-zone default.
+	ctrl := newProfiledController(t, p)
+	ctx := context.Background()
+
+	if !ctrl.StreamerMode() || !ctrl.GetInfo().Streamer {
+		t.Fatal("streamer profile not in streamer mode")
+	}
+	if s := ctrl.State(); len(s.Zones) != 0 || len(s.Groups) != 0 {
+		t.Errorf("zones = %d, groups = %d, want none", len(s.Zones), len(s.Groups))
+	}
+	cfg := ctrl.State()
+	cfg.Zones = []models.Zone{{ID: 0, Name: "Zone 1"}}
+	if _, appErr := ctrl.LoadConfig(ctx, cfg); appErr == nil || appErr.Status != 400 {
+		t.Errorf("LoadConfig with zones on a streamer unit: %v", appErr)
+	}
+}
+
+func TestOutputs_StreamerUnitSourcesFollowOutputs(t *testing.T) {
+	// Streamer-only unit: sources exist only for configured outputs
+	p := &hardware.HardwareProfile{
+		Units: []hardware.UnitInfo{
+			{Board: hardware.BoardInfo{UnitType: hardware.UnitTypeStreamer}},
+		},
+		TotalSources: 0,
+		IsStreamer:   true,
+	}
+	ctrl := newProfiledController(t, p)
+	ctx := context.Background()
+
+	layout := &audio.Layout{Loopbacks: []string{"Loopback"}, SampleRate: audio.DefaultSampleRate}
+	ctrl.SetAudioOutputs(audio.NewOutputs(layout, t.TempDir(), nil))
+	if n := len(ctrl.GetSources()); n != 0 {
+		t.Fatalf("Sources = %d, want 0 before any output is mapped", n)
+	}
+
+	id, card := 0, "Device"
+	outputs, appErr := ctrl.CreateOutput(ctx, models.AudioOutputUpdate{ID: &id, Card: &card})
+	if appErr != nil {
+		t.Fatalf("CreateOutput: %v", appErr)
+	}
+	if len(outputs) != 1 || outputs[0].PCM != "ch0" || outputs[0].Right != 1 {
+		t.Errorf("outputs = %+v", outputs)
+	}
+	if _, appErr := ctrl.CreateOutput(ctx, models.AudioOutputUpdate{ID: &id, Card: &card}); appErr == nil || appErr.Status != 409 {
+		t.Errorf("duplicate CreateOutput: got %v, want 409", appErr)
+	}
+
+	sources := ctrl.GetSources()
+	if len(sources) != 1 || sources[0].ID != 0 {
+		t.Fatalf("Sources = %+v, want [0]", sources)
+	}
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: strPtr("stream=1000")}); appErr != nil {
+		t.Errorf("SetSource on mapped output should succeed: %v", appErr)
+	}
+	if _, appErr := ctrl.SetSource(ctx, 1, models.SourceUpdate{Input: strPtr("stream=1000")}); appErr == nil {
+		t.Error("SetSource on unmapped source should fail on streamer unit")
+	}
+
+	if _, appErr := ctrl.DeleteOutput(ctx, 0); appErr != nil {
+		t.Fatalf("DeleteOutput: %v", appErr)
+	}
+	if n := len(ctrl.GetSources()); n != 0 {
+		t.Errorf("Sources = %d after delete, want 0", n)
+	}
+}
+
+func TestOutputs_NotConfigured(t *testing.T) {
+	ctrl := newProfiledController(t, nil)
+	if _, appErr := ctrl.DeleteOutput(context.Background(), 0); appErr == nil || appErr.Status != 400 {
+		t.Errorf("DeleteOutput without output manager: got %v, want 400", appErr)
+	}
+	if outs := ctrl.GetOutputs(); len(outs) != 0 {
+		t.Errorf("GetOutputs = %v, want empty", outs)
+	}
+}
+
+func TestRCAInputDetectionAutoSwitch(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, hardware.MockProfile(), config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: strPtr("local")}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetStream(ctx, models.RCAStream0, models.StreamUpdate{Config: map[string]interface{}{"auto_switch": true}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	var clock atomic.Int64
+	clock.Store(time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC).UnixNano())
+	ctrl.SetClock(func() time.Time { return time.Unix(0, clock.Load()) })
+	advance := func(d time.Duration) { clock.Add(int64(d)) }
+	go ctrl.RunInputDetection(ctx, 10*time.Millisecond)
+
+	waitFor := func(what string, cond func(models.State) bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond(ctrl.State()) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	rcaActive := func(s models.State, id int) bool {
+		for _, st := range s.Streams {
+			if st.ID == id {
+				return st.Active != nil && *st.Active
+			}
+		}
+		return false
+	}
+	rcaInput := fmt.Sprintf("stream=%d", models.RCAStream0)
+
+	// Signal on input 1 switches source 0 to it once it has held;
+	// input 2 is only flagged.
+	waitFor("first reading", func(s models.State) bool {
+		for _, st := range s.Streams {
+			if st.ID == models.RCAStream0 {
+				return st.Active != nil
+			}
+		}
+		return false
+	})
+	_ = hw.Write(ctx, 0, hardware.RegInputSig, 0b0011)
+	time.Sleep(50 * time.Millisecond)
+	if s := ctrl.State(); s.Sources[0].Input == rcaInput || rcaActive(s, models.RCAStream1) {
+		t.Fatal("signal counted before the hold time")
+	}
+	advance(2 * time.Second)
+	waitFor("auto-switch", func(s models.State) bool {
+		return s.Sources[0].Input == rcaInput && rcaActive(s, models.RCAStream1)
+	})
+	if src := ctrl.State().Sources[1]; src.Input == fmt.Sprintf("stream=%d", models.RCAStream1) {
+		t.Error("source 1 switched without auto_switch")
+	}
+
+	// A short drop is ignored; losing the signal for longer restores the
+	// previous input.
+	_ = hw.Write(ctx, 0, hardware.RegInputSig, 0)
+	time.Sleep(50 * time.Millisecond)
+	advance(5 * time.Second)
+	_ = hw.Write(ctx, 0, hardware.RegInputSig, 0b0011)
+	time.Sleep(50 * time.Millisecond)
+	_ = hw.Write(ctx, 0, hardware.RegInputSig, 0)
+	time.Sleep(50 * time.Millisecond)
+	advance(5 * time.Second)
+	time.Sleep(50 * time.Millisecond)
+	if s := ctrl.State(); s.Sources[0].Input != rcaInput {
+		t.Fatal("short signal drop restored the previous input")
+	}
+	advance(5 * time.Second)
+	waitFor("restore", func(s models.State) bool {
+		return s.Sources[0].Input == "local" && !rcaActive(s, models.RCAStream0)
+	})
+}
+
+func TestBridgedZones(t *testing.T) {
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, hardware.MockProfile(), config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	bridged, mute, vol := true, false, 0.5
+
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{Bridged: &bridged}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("bridging the second zone of a pair: %v", appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Bridged: &bridged}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute, VolF: &vol}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+	z := ctrl.State().Zones
+	if z[1].Mute || z[1].Vol != z[0].Vol {
+		t.Errorf("partner zone = %+v, want it to mirror zone 0 %+v", z[1], z[0])
+	}
+	if a, b := hw.GetReg(0, hardware.VolZoneReg(0)), hw.GetReg(0, hardware.VolZoneReg(1)); a != b {
+		t.Errorf("bridged channel volumes %#x and %#x differ", a, b)
+	}
+
+	// The partner only follows: direct control is refused, preset and
+	// group changes are overridden, and it cannot be grouped.
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{VolF: &vol}); appErr == nil || appErr.Status != 409 {
+		t.Errorf("controlling the bridge partner: %v", appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{Name: strPtr("Patio (bridged)")}); appErr != nil {
+		t.Errorf("renaming the bridge partner: %v", appErr)
+	}
+	if _, appErr := ctrl.LoadPreset(ctx, models.MuteAllPresetID); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if z := ctrl.State().Zones; !z[0].Mute || !z[1].Mute {
+		t.Error("Mute All left the bridged output playing")
+	}
+	if _, appErr := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: strPtr("Outside"), ZoneIDs: []int{1, 2}}); appErr == nil {
+		t.Error("grouped the bridge partner")
+	}
+
+	// A grouped zone cannot become a partner.
+	if _, appErr := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: strPtr("Back"), ZoneIDs: []int{3}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 2, models.ZoneUpdate{Bridged: &bridged}); appErr == nil || appErr.Status != 409 {
+		t.Errorf("bridging with a grouped partner: %v", appErr)
+	}
+
+	unbridge := false
+	if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Bridged: &unbridge}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.SetZone(ctx, 1, models.ZoneUpdate{VolF: &vol}); appErr != nil {
+		t.Errorf("controlling an unbridged zone: %v", appErr)
+	}
+
+	// Units before Rev4 cannot bridge.
+	p := hardware.MockProfile()
+	p.Units[0].Rev4Plus = false
+	old := newProfiledController(t, p)
+	if _, appErr := old.SetZone(ctx, 0, models.ZoneUpdate{Bridged: &bridged}); appErr == nil || appErr.Status != 400 {
+		t.Errorf("bridging on a pre-Rev4 unit: %v", appErr)
+	}
+}
+
+func TestExpanderRouting(t *testing.T) {
+	ctx := context.Background()
+	hw := hardware.NewMockWithUnits([]int{0, 1, 2})
+	hw.Write(ctx, 1, hardware.RegVersionMin, 7)
+	p, err := hardware.Detect(ctx, hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hw, p, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Zone 7 (ID 7) is the second zone of the first expander.
+	src, vol, mute := 2, -20, false
+	if _, appErr := ctrl.SetZone(ctx, 7, models.ZoneUpdate{SourceID: &src, Vol: &vol, Mute: &mute}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	vol = -30
+	if _, appErr := ctrl.SetZone(ctx, 12, models.ZoneUpdate{Vol: &vol}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if err := ctrl.FlushHardware(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hw.GetReg(1, hardware.VolZoneReg(1)); got != hardware.DBToVolReg(-20) {
+		t.Errorf("unit 1 zone 1 vol reg = %#x, want %#x", got, hardware.DBToVolReg(-20))
+	}
+	if got := hw.GetReg(0, hardware.VolZoneReg(1)); got != hardware.VolMuteReg {
+		t.Errorf("unit 0 zone 1 vol reg = %#x, want untouched", got)
+	}
+	if got := hw.GetReg(1, hardware.RegZone321); got != hardware.PackZone321(0, 2, 0) {
+		t.Errorf("unit 1 sources reg = %#x, want %#x", got, hardware.PackZone321(0, 2, 0))
+	}
+	if got := hw.GetReg(0, hardware.RegZone321); got != hardware.PackZone321(0, 0, 0) {
+		t.Errorf("unit 0 sources reg = %#x, want all source 0", got)
+	}
+	if got := hw.GetReg(1, hardware.RegMute); got&0x02 != 0 {
+		t.Errorf("unit 1 mute reg = %#x, want zone 1 unmuted", got)
+	}
+	if got := hw.GetReg(2, hardware.VolZoneReg(0)); got != hardware.DBToVolReg(-30) {
+		t.Errorf("unit 2 zone 0 vol reg = %#x, want %#x", got, hardware.DBToVolReg(-30))
+	}
+
+	// Info lists every unit with its own firmware and temperatures.
+	hw.Write(ctx, 2, hardware.RegAmpTemp1, hardware.TempToReg(55))
+	ctrl.RecordHealth()
+	units := ctrl.GetInfo().UnitDetails
+	if len(units) != 3 {
+		t.Fatalf("unit details = %+v, want 3 units", units)
+	}
+	if u := units[1]; u.Unit != 1 || u.ZoneBase != 6 || u.Zones != 6 || u.Firmware != "1.7-deadbeef" {
+		t.Errorf("unit 1 = %+v", u)
+	}
+	if b := units[1].Board; b == nil || b.Type != "expansion" || b.Rev != "Rev4.A" || !b.Rev4Plus {
+		t.Errorf("unit 1 board = %+v", b)
+	}
+	if units[0].Firmware != "1.0-deadbeef" {
+		t.Errorf("unit 0 firmware = %q", units[0].Firmware)
+	}
+	if u := units[2]; u.Temps == nil || u.Temps.Amp1C < 54 || u.Temps.Amp1C > 56 {
+		t.Errorf("unit 2 temps = %+v, want amp1 55°C", u.Temps)
+	}
+}
+
+func TestApplyStateToHW_ZonesOutOfOrder(t *testing.T) {
+	ctx := context.Background()
+	hw := hardware.NewMockWithUnits([]int{0, 1})
+	p, err := hardware.Detect(ctx, hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A config listing zones out of ID order still drives each zone's own
+	// channel.
+	st := models.DefaultStateFromProfile(p)
+	st.Zones[0].Vol = -25
+	st.Zones[7].Vol = -35
+	for i, j := 0, len(st.Zones)-1; i < j; i, j = i+1, j-1 {
+		st.Zones[i], st.Zones[j] = st.Zones[j], st.Zones[i]
+	}
+	store := config.NewMemStore()
+	if err := store.Save(&st); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := controller.New(hw, p, store, events.NewBus(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := hw.GetReg(0, hardware.VolZoneReg(0)); got != hardware.DBToVolReg(-25) {
+		t.Errorf("zone 0 vol reg = %#x, want %#x", got, hardware.DBToVolReg(-25))
+	}
+	if got := hw.GetReg(1, hardware.VolZoneReg(1)); got != hardware.DBToVolReg(-35) {
+		t.Errorf("zone 7 vol reg = %#x, want %#x", got, hardware.DBToVolReg(-35))
+	}
+}
+
+func TestGetUnits(t *testing.T) {
+	ctx := context.Background()
+	hw := hardware.NewMockWithUnits([]int{0, 1})
+	p, err := hardware.Detect(ctx, hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hw, p, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw.Write(ctx, 1, hardware.RegHV1Temp, hardware.TempToReg(48))
+
+	units := ctrl.GetUnits(ctx)
+	if len(units) != 2 {
+		t.Fatalf("units = %+v, want 2", units)
+	}
+	u := units[1]
+	if u.Unit != 1 || u.ZoneBase != 6 || u.I2CAddr != 0x10 || u.Firmware != "1.0-deadbeef" {
+		t.Errorf("unit 1 = %+v", u)
+	}
+	if u.Temps == nil || u.Temps.PSU1C < 47 || u.Temps.PSU1C > 49 || u.Power == nil || !u.Power.PG12V || u.Fan == nil {
+		t.Errorf("unit 1 readings = %+v, %+v, %+v", u.Temps, u.Power, u.Fan)
+	}
+
+	if _, appErr := ctrl.GetUnit(ctx, 2); appErr == nil || appErr.Status != 404 {
+		t.Errorf("GetUnit(2) = %v, want 404", appErr)
+	}
+
+	// A unit that cannot be read is still listed, with what failed.
+	hw.SetFailRead(true)
+	u, appErr := ctrl.GetUnit(ctx, 0)
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if u.Temps != nil || u.Power != nil || len(u.Errors) != 4 || u.Type != "main" {
+		t.Errorf("unreadable unit = %+v", u)
+	}
+}
+
+func TestFanCurve(t *testing.T) {
+	ctx := context.Background()
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, hardware.MockProfile(), config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hw.Write(ctx, 0, hardware.RegAmpTemp1, hardware.TempToReg(50))
+	hw.Write(ctx, 0, hardware.RegAmpTemp2, hardware.TempToReg(45))
+	duty := func() byte {
+		v, _ := hw.Read(ctx, 0, hardware.RegFanDuty)
+		return v
+	}
+
+	if st := ctrl.GetFans(ctx); st.Mode != "pwm" || st.Custom || len(st.Units) != 1 || st.Units[0].TempC != 50 || st.Units[0].Duty != nil {
+		t.Errorf("firmware fans = %+v", st)
+	}
+
+	_, appErr := ctrl.SetFanCurve(ctx, models.FanCurveUpdate{Curve: models.FanCurve{{TempC: 60, Duty: 0.5}, {TempC: 40, Duty: 1}}})
+	if appErr == nil || appErr.Status != 400 || appErr.Field != "curve" {
+		t.Errorf("falling curve: %v, want 400 on curve", appErr)
+	}
+
+	// The curve is applied at once, at the hottest sensor's temperature.
+	st, appErr := ctrl.SetFanCurve(ctx, models.FanCurveUpdate{Curve: models.FanCurve{{TempC: 40, Duty: 0.25}, {TempC: 60, Duty: 0.75}, {TempC: 80, Duty: 1}}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if !st.Custom || st.Units[0].Duty == nil || *st.Units[0].Duty != 0.5 || duty() != 64 {
+		t.Errorf("custom fans = %+v, duty reg %#x; want 0.5 (0x40)", st, duty())
+	}
+	if got := ctrl.GetSettings().FanCurve; len(got) != 3 {
+		t.Errorf("settings fan curve = %+v", got)
+	}
+
+	// Hotter: the next pass follows the curve up.
+	hw.Write(ctx, 0, hardware.RegHV1Temp, hardware.TempToReg(70))
+	ctrl.RefreshFans()
+	if duty() != 0x70 {
+		t.Errorf("at 70°C duty reg = %#x, want 0x70", duty())
+	}
+
+	// Clearing the curve hands the fans back to the firmware.
+	if st, appErr = ctrl.SetFanCurve(ctx, models.FanCurveUpdate{}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if st.Custom || len(st.Curve) != 0 || duty() != hardware.FanDutyAuto {
+		t.Errorf("cleared fans = %+v, duty reg %#x", st, duty())
+	}
+
+	// Fans under MAX6644 control cannot follow a curve.
+	p := hardware.MockProfile()
+	p.FanMode = hardware.FanModeExternal
+	ext, err := controller.New(hardware.NewMock(), p, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, appErr := ext.SetFanCurve(ctx, models.FanCurveUpdate{Curve: models.FanCurve{{TempC: 40, Duty: 0}, {TempC: 60, Duty: 1}}}); appErr == nil || appErr.Status != 409 {
+		t.Errorf("external fan control: %v, want 409", appErr)
+	}
+}
+
+func TestHealthPowerEstimate(t *testing.T) {
+	ctx := context.Background()
+	hw := hardware.NewMock()
+	ctrl, err := controller.New(hw, hardware.MockProfile(), config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	estimate := func() *models.PowerEstimate {
+		t.Helper()
+		if err := ctrl.FlushHardware(ctx); err != nil {
+			t.Fatal(err)
+		}
+		h := ctrl.Health(ctx)
+		if len(h.Units) != 1 || h.Units[0].Estimate == nil {
+			t.Fatalf("health = %+v", h)
+		}
+		if h.Watts != h.Units[0].Estimate.Watts {
+			t.Errorf("total %v W, unit %v W", h.Watts, h.Units[0].Estimate.Watts)
+		}
+		return h.Units[0].Estimate
+	}
+
+	// Nothing playing: the preamp and the enabled amps' idle draw.
+	idle := estimate()
+	if idle.HV1Volts != 24 || idle.ZonesPlaying != 0 || idle.Watts != 3+1.2*float64(idle.AmpsEnabled) {
+		t.Errorf("idle estimate = %+v", idle)
+	}
+
+	input := fmt.Sprintf("stream=%d", models.AuxStreamID)
+	mute := false
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: &input}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	ctrl.UpdateStreamInfo(models.AuxStreamID, models.StreamInfo{State: "playing"})
+	var prev float64
+	for _, vol := range []int{-20, -10, 0} {
+		if _, appErr := ctrl.SetZone(ctx, 0, models.ZoneUpdate{Mute: &mute, Vol: &vol}); appErr != nil {
+			t.Fatal(appErr)
+		}
+		est := estimate()
+		if est.ZonesPlaying != 1 || est.Watts <= prev {
+			t.Errorf("vol %d: estimate = %+v, want one zone playing above %v W", vol, est, prev)
+		}
+		prev = est.Watts
+	}
+	// Full scale at 24V into 8 ohms is 36 W a channel; music averages an
+	// eighth of it, at 85% efficiency.
+	if want := 3 + 1.2*float64(estimate().AmpsEnabled) + 2*36*0.125/0.85; math.Abs(prev-want) > 0.1 {
+		t.Errorf("vol 0: %v W, want %.1f", prev, want)
+	}
+}
+
+func TestProfileOverride(t *testing.T) {
+	units, analog := 2, false
+	p := hardware.MockProfile()
+	p.ApplyOverride(hardware.ProfileOverride{AnalogSources: &analog, UnavailableStreams: []string{"pandora"}})
+	ctrl := newProfiledController(t, p)
+	ctx := context.Background()
+
+	// Disabled stream types and analog inputs are unavailable.
+	state := ctrl.State()
+	for _, st := range state.Streams {
+		if st.ID == models.RCAStream0 && st.Info.State != "unavailable" {
+			t.Errorf("RCA stream state = %q, want unavailable", st.Info.State)
+		}
+	}
+	if _, appErr := ctrl.CreateStream(ctx, models.StreamCreate{Name: "Radio", Type: "pandora"}); appErr == nil {
+		t.Error("CreateStream accepted a type the override disables")
+	}
+	if _, appErr := ctrl.SetSource(ctx, 0, models.SourceUpdate{Input: strPtr("local")}); appErr == nil {
+		t.Error("SetSource accepted an analog input the override disables")
+	}
+	info := ctrl.GetInfo()
+	if info.ProfileOverride == nil {
+		t.Error("Info.ProfileOverride = nil")
+	}
+	for _, typ := range info.AvailableStreams {
+		if typ == "pandora" || typ == "rca" {
+			t.Errorf("Info.AvailableStreams lists %s", typ)
+		}
+	}
+
+	// The saved override is changed through the API and applied at the
+	// next start.
+	if _, appErr := ctrl.GetProfileOverride(); appErr == nil || appErr.Status != 400 {
+		t.Errorf("GetProfileOverride without a config directory = %v, want 400", appErr)
+	}
+	ctrl.SetProfileOverrideDir(t.TempDir())
+	status, appErr := ctrl.SetProfileOverride(ctx, hardware.ProfileOverride{Units: &units, UnavailableStreams: []string{"internet_radio"}})
+	if appErr != nil {
+		t.Fatalf("SetProfileOverride: %v", appErr)
+	}
+	if !status.RestartRequired || *status.Saved.Units != 2 || status.Saved.UnavailableStreams[0] != models.StreamTypeInternetRadio {
+		t.Errorf("SetProfileOverride = %+v", status)
+	}
+	if status.Active.AnalogSources == nil || *status.Active.AnalogSources {
+		t.Errorf("Active = %+v, want the applied override", status.Active)
+	}
+	bad := -1
+	if _, appErr := ctrl.SetProfileOverride(ctx, hardware.ProfileOverride{Units: &bad}); appErr == nil || appErr.Field != "units" {
+		t.Errorf("SetProfileOverride(units -1) = %v, want 400 on units", appErr)
+	}
+	if _, appErr := ctrl.SetProfileOverride(ctx, hardware.ProfileOverride{UnavailableStreams: []string{"gramophone"}}); appErr == nil || appErr.Field != "unavailable_streams" {
+		t.Errorf("SetProfileOverride(gramophone) = %v, want 400 on unavailable_streams", appErr)
+	}
+
+	// Setting the saved override back to the applied one needs no restart.
+	none, off := 0, false
+	status, appErr = ctrl.SetProfileOverride(ctx, hardware.ProfileOverride{Units: &none, AnalogSources: &off, UnavailableStreams: []string{"pandora"}})
+	if appErr != nil || status.RestartRequired || status.Saved.Units != nil {
+		t.Errorf("SetProfileOverride back = %+v, %v", status, appErr)
+	}
+}
+
+func TestSelfTest(t *testing.T) {
+	ctx := context.Background()
+	hw := hardware.NewMockWithUnits([]int{0, 1, 2})
+	p, err := hardware.Detect(ctx, hw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl, err := controller.New(hw, p, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ctrl.GetInfo().SelfTest != nil {
+		t.Error("Info.SelfTest set before the self-test ran")
+	}
+
+	// Unit 1 is off the bus and unit 2's 9V rail fails.
+	nack := 1.0
+	hw.RunScenario(ctx, &hardware.Scenario{Steps: []hardware.ScenarioStep{
+		{Unit: 1, NACKRate: &nack},
+		{Unit: 2, Rails: map[string]bool{"9v": false}},
+	}})
+
+	res := ctrl.RunSelfTest(ctx)
+	results := make(map[string]string)
+	for _, ch := range res.Checks {
+		results[ch.Check+" "+ch.Target] = ch.Result
+	}
+	want := map[string]string{
+		"i2c unit 0":         models.SelfTestPass,
+		"power unit 0":       models.SelfTestPass,
+		"i2c unit 1":         models.SelfTestFail,
+		"temperature unit 1": "", // not read from a unit that doesn't answer
+		"i2c unit 2":         models.SelfTestPass,
+		"power unit 2":       models.SelfTestFail,
+		"loopback ALSA":      models.SelfTestSkip,
+		"stream rca":         models.SelfTestPass,
+	}
+	for name, result := range want {
+		if results[name] != result {
+			t.Errorf("%s = %q, want %q", name, results[name], result)
+		}
+	}
+	if res.Passed || res.Failed != 2 {
+		t.Errorf("Passed = %v, Failed = %d; want false, 2", res.Passed, res.Failed)
+	}
+	if got := ctrl.GetInfo().SelfTest; got == nil || got.Failed != 2 {
+		t.Errorf("Info.SelfTest = %+v, want the result", got)
+	}
+}
@@ -0,0 +1,394 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// hwKey identifies a hardware register, or a group of registers that is
+// always written as a whole like a unit's six zone sources.
+type hwKey struct {
+	unit int
+	reg  string // "source types", "zone sources", "zone mutes", "amp enables", "volume"
+	zone int    // zone within the unit for per-zone registers, else -1
+}
+
+func (k hwKey) String() string {
+	if k.zone >= 0 {
+		return fmt.Sprintf("zone %d %s", k.unit*6+k.zone, k.reg)
+	}
+	return k.reg
+}
+
+// hwQueue applies hardware writes in the background so that a slow I2C
+// transaction never holds the state lock. Writes run one at a time in the
+// order they were queued. A write to a register that already has one
+// pending replaces it in place, so a burst of volume changes costs one
+// transaction and a register never goes back to an older value.
+type hwQueue struct {
+	mu      sync.Mutex
+	pending map[hwKey]func(context.Context) error
+	order   []hwKey
+	tickets map[hwKey]uint64 // writes queued or reserved per register
+	running bool
+	idle    chan struct{} // closed while nothing is pending or running
+
+	failing map[hwKey]bool // only touched by the running drain
+
+	// report is called, without mu held, for every failed write (err !=
+	// nil) and when a failing register recovers (err == nil).
+	report func(key hwKey, err error)
+}
+
+func newHWQueue(report func(hwKey, error)) *hwQueue {
+	idle := make(chan struct{})
+	close(idle)
+	return &hwQueue{
+		pending: make(map[hwKey]func(context.Context) error),
+		tickets: make(map[hwKey]uint64),
+		idle:    idle,
+		failing: make(map[hwKey]bool),
+		report:  report,
+	}
+}
+
+// Queue schedules write for key, replacing any write to key that has not
+// started yet.
+func (q *hwQueue) Queue(key hwKey, write func(context.Context) error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tickets[key]++
+	q.queue(key, write)
+}
+
+// Reserve holds key's place for a write queued later with QueueReserved,
+// so that the write is superseded by any write to key queued or reserved
+// after this call.
+func (q *hwQueue) Reserve(key hwKey) uint64 {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.tickets[key]++
+	return q.tickets[key]
+}
+
+// QueueReserved schedules write for key unless a newer write to key has
+// been queued or reserved since ticket was, reporting whether it did.
+func (q *hwQueue) QueueReserved(key hwKey, ticket uint64, write func(context.Context) error) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.tickets[key] != ticket {
+		return false
+	}
+	q.queue(key, write)
+	return true
+}
+
+// queue schedules write for key. q.mu must be held.
+func (q *hwQueue) queue(key hwKey, write func(context.Context) error) {
+	if _, ok := q.pending[key]; !ok {
+		q.order = append(q.order, key)
+	}
+	q.pending[key] = write
+	if !q.running {
+		q.running = true
+		q.idle = make(chan struct{})
+		go q.drain()
+	}
+}
+
+// Flush waits until every queued write has been applied (or ctx is done).
+// Must not be called with the controller lock held, since failures are
+// reported through apply.
+func (q *hwQueue) Flush(ctx context.Context) error {
+	q.mu.Lock()
+	idle := q.idle
+	q.mu.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// drain applies queued writes until the queue is empty.
+func (q *hwQueue) drain() {
+	ctx := context.Background()
+	for {
+		q.mu.Lock()
+		if len(q.order) == 0 {
+			q.running = false
+			close(q.idle)
+			q.mu.Unlock()
+			return
+		}
+		key := q.order[0]
+		q.order = q.order[1:]
+		write := q.pending[key]
+		delete(q.pending, key)
+		q.mu.Unlock()
+
+		err := write(ctx)
+		if err != nil {
+			slog.Warn("hardware write failed", "unit", key.unit, "register", key.String(), "err", err)
+		}
+		if err == nil && !q.failing[key] {
+			continue
+		}
+		if err != nil {
+			q.failing[key] = true
+		} else {
+			delete(q.failing, key)
+		}
+		if q.report != nil {
+			q.report(key, err)
+		}
+	}
+}
+
+// errNoChange aborts an apply that would not change the state.
+var errNoChange = errors.New("no change")
+
+// FlushHardware waits until every crossfade has finished and every queued
+// hardware write has been applied.
+func (c *Controller) FlushHardware(ctx context.Context) error {
+	for {
+		c.fadeMu.Lock()
+		var run *fadeRun
+		for _, r := range c.fades {
+			run = r
+			break
+		}
+		c.fadeMu.Unlock()
+		if run == nil {
+			break
+		}
+		select {
+		case <-run.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return c.hwq.Flush(ctx)
+}
+
+// reportHWError records a failed hardware write in Info.HardwareErrors, or
+// clears it once the register has been written successfully, publishing
+// the change to event subscribers.
+func (c *Controller) reportHWError(key hwKey, err error) {
+	reg := key.String()
+	_, _ = c.apply(func(s *models.State) error {
+		changed := false
+		errs := make([]models.HardwareError, 0, len(s.Info.HardwareErrors)+1)
+		for _, e := range s.Info.HardwareErrors {
+			if e.Unit != key.unit || e.Register != reg {
+				errs = append(errs, e)
+			} else if err != nil && e.Error == err.Error() {
+				return errNoChange // already reported
+			} else {
+				changed = true
+			}
+		}
+		if err != nil {
+			errs = append(errs, models.HardwareError{Time: c.now(), Unit: key.unit, Register: reg, Error: err.Error()})
+			changed = true
+		}
+		if !changed {
+			return errNoChange
+		}
+		if len(errs) == 0 {
+			errs = nil
+		}
+		s.Info.HardwareErrors = errs
+		return nil
+	})
+}
+
+// The queue* methods schedule a write of the given registers on unit.
+
+func (c *Controller) queueSourceTypes(unit int, analog [4]bool) {
+	c.hwq.Queue(hwKey{unit, "source types", -1}, func(ctx context.Context) error {
+		return c.hw.SetSourceTypes(ctx, unit, analog)
+	})
+}
+
+func (c *Controller) queueZoneSources(unit int, sources [6]int, fade crossfade) {
+	c.queueFaded(hwKey{unit, "zone sources", -1}, fade, func(ctx context.Context) error {
+		return c.hw.SetZoneSources(ctx, unit, sources)
+	})
+}
+
+func (c *Controller) queueZoneMutes(unit int, mutes [6]bool, fade crossfade) {
+	c.queueFaded(hwKey{unit, "zone mutes", -1}, fade, func(ctx context.Context) error {
+		return c.hw.SetZoneMutes(ctx, unit, mutes)
+	})
+}
+
+// queueAmpEnables also switches the unit's rails when unit power is on and
+// the unit can: they are taken down after every amp is disabled and brought
+// up before any amp is enabled. Rails unit power took down are brought back
+// up once it is turned off; otherwise they are left alone.
+func (c *Controller) queueAmpEnables(unit int, enables [6]bool, fade crossfade) {
+	c.queueFaded(hwKey{unit, "amp enables", -1}, fade, func(ctx context.Context) error {
+		c.mu.RLock()
+		enabled := c.state.Settings.UnitPower.Enabled
+		c.mu.RUnlock()
+		rails := c.profile != nil && c.profile.CanSwitchRails(unit) && (enabled || c.railsOff[unit])
+		railsOn := slices.Contains(enables[:], true) || !enabled
+		if rails && railsOn {
+			if err := c.setRails(ctx, unit, true); err != nil {
+				return err
+			}
+			delete(c.railsOff, unit)
+		}
+		if err := c.hw.SetAmpEnables(ctx, unit, enables); err != nil {
+			return err
+		}
+		if rails && !railsOn {
+			if err := c.setRails(ctx, unit, false); err != nil {
+				return err
+			}
+			c.railsOff[unit] = true
+		}
+		return nil
+	})
+}
+
+func (c *Controller) queueZoneVol(unit, zone, vol int) {
+	c.hwq.Queue(hwKey{unit, "volume", zone}, func(ctx context.Context) error {
+		return c.hw.SetZoneVol(ctx, unit, zone, vol)
+	})
+}
+
+// crossfadeSteps is how many volume writes each half of a crossfade takes.
+const crossfadeSteps = 8
+
+// crossfade ramps zone volumes around a source or mute write so the change
+// does not pop: zones that were audible fade out before it, zones that are
+// audible after it fade in. A zero duration writes straight away.
+type crossfade struct {
+	duration time.Duration
+	out, in  []int // zones within the unit
+}
+
+// fadeRun is a crossfade running on a unit.
+type fadeRun struct {
+	done chan struct{} // closed once it has finished
+}
+
+// queueFaded queues write for key wrapped in fade's volume ramps. The ramps
+// run on a goroutine of their own, after any crossfade already running on
+// the unit, so they never hold up the hardware queue; the write itself
+// still goes through it once the zones are silent. A write to key queued
+// in the meantime supersedes this one. Without a fade, write is queued
+// straight away.
+func (c *Controller) queueFaded(key hwKey, fade crossfade, write func(context.Context) error) {
+	if fade.duration <= 0 || len(fade.out)+len(fade.in) == 0 {
+		c.hwq.Queue(key, write)
+		return
+	}
+	ticket := c.hwq.Reserve(key)
+	run := &fadeRun{done: make(chan struct{})}
+	c.fadeMu.Lock()
+	prev := c.fades[key.unit]
+	c.fades[key.unit] = run
+	c.fadeMu.Unlock()
+
+	go func() {
+		defer func() {
+			c.fadeMu.Lock()
+			if c.fades[key.unit] == run {
+				delete(c.fades, key.unit)
+			}
+			c.fadeMu.Unlock()
+			close(run.done)
+		}()
+		if prev != nil {
+			<-prev.done
+		}
+		if err := c.crossfade(key, ticket, fade, write); err != nil {
+			slog.Warn("crossfade failed", "unit", key.unit, "register", key.String(), "err", err)
+		}
+	}()
+}
+
+// crossfade ramps fade's zones out, queues write for key under ticket,
+// waits for it and ramps them back in. Each zone ramps between silence and
+// its volume when the crossfade starts, and ends up at its volume when it
+// finishes. Only failed volume writes are returned; a failed write is
+// reported by the queue.
+func (c *Controller) crossfade(key hwKey, ticket uint64, fade crossfade, write func(context.Context) error) error {
+	ctx := context.Background()
+	unit := key.unit
+	zones := slices.Concat(fade.out, fade.in)
+	zoneVols := func() map[int]int {
+		vols := make(map[int]int)
+		c.mu.RLock()
+		defer c.mu.RUnlock()
+		for _, zone := range zones {
+			if z := findZone(&c.state, unit*6+zone); z != nil {
+				vols[zone] = z.Vol
+			}
+		}
+		return vols
+	}
+	vols := zoneVols()
+
+	step := fade.duration / 2 / crossfadeSteps
+	ramp := func(zones []int, up bool) error {
+		for i := 1; i <= crossfadeSteps; i++ {
+			level := float64(i) / crossfadeSteps
+			if !up {
+				level = 1 - level
+			}
+			for _, zone := range zones {
+				vol := models.MinVolDB + int(math.Round(level*float64(vols[zone]-models.MinVolDB)))
+				if err := c.hw.SetZoneVol(ctx, unit, zone, vol); err != nil {
+					return err
+				}
+			}
+			time.Sleep(step)
+		}
+		return nil
+	}
+	// setVols writes zones straight to silence or to their volume.
+	setVols := func(zones []int, silent bool) error {
+		for _, zone := range zones {
+			vol := vols[zone]
+			if silent {
+				vol = models.MinVolDB
+			}
+			if err := c.hw.SetZoneVol(ctx, unit, zone, vol); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	err := ramp(fade.out, false)
+	if err == nil {
+		err = setVols(fade.in, true)
+	}
+	var werr error
+	if c.hwq.QueueReserved(key, ticket, func(ctx context.Context) error {
+		werr = write(ctx)
+		return werr
+	}) {
+		_ = c.hwq.Flush(ctx)
+	}
+	if err == nil && werr == nil {
+		err = ramp(fade.in, true)
+	}
+	// Whatever happened, every zone ends up at its volume as it is now,
+	// ready for when a muted zone is unmuted.
+	vols = zoneVols()
+	return errors.Join(err, setVols(zones, false))
+}
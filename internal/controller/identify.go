@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// identifyLEDSeconds is how long the zone LED blinks during an identify.
+const identifyLEDSeconds = 6
+
+// IdentifyZone plays a test tone or the spoken zone name through a single
+// zone at a safe volume, blinking the zone's LED meanwhile, so installers
+// can check speaker wiring. It uses the announcement machinery and blocks
+// until playback finishes and the previous state is restored.
+func (c *Controller) IdentifyZone(ctx context.Context, id int, req models.ZoneIdentify) (models.State, *models.AppError) {
+	volF := models.DefaultIdentifyVolF
+	if req.VolF != nil {
+		volF = *req.VolF
+	}
+	if volF < 0 || volF > models.MaxIdentifyVolF {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("vol_f must be between 0 and %g", models.MaxIdentifyVolF))
+	}
+
+	c.mu.RLock()
+	z := findZone(&c.state, id)
+	var name string
+	disabled := false
+	if z != nil {
+		name, disabled = z.Name, z.Disabled
+	}
+	c.mu.RUnlock()
+	if z == nil {
+		return models.State{}, models.ErrNotFound("zone not found")
+	}
+	if disabled {
+		return models.State{}, models.ErrBadRequest("zone is disabled")
+	}
+
+	var media string
+	switch req.Mode {
+	case "", models.IdentifyTone:
+		path, err := tempSignal(audio.WriteTestTone)
+		if err != nil {
+			return models.State{}, models.ErrInternal("test tone: " + err.Error())
+		}
+		defer os.Remove(path)
+		media = path
+	case models.IdentifyVoice:
+		path, appErr := speakToFile(ctx, name)
+		if appErr != nil {
+			return models.State{}, appErr
+		}
+		defer os.Remove(path)
+		media = path
+	default:
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("mode must be %q or %q", models.IdentifyTone, models.IdentifyVoice))
+	}
+
+	zone := id
+	_, _ = c.IdentifyLEDs(ctx, models.LEDIdentify{Zone: &zone, Duration: identifyLEDSeconds})
+
+	return c.Announce(ctx, models.AnnounceRequest{
+		Media:    media,
+		VolF:     &volF,
+		SourceID: req.SourceID,
+		Zones:    []int{id},
+	})
+}
+
+// tempSignal writes a generated test signal to a new temporary file and
+// returns its path. The caller removes the file.
+func tempSignal(write func(io.Writer) error) (string, error) {
+	f, err := os.CreateTemp("", "amplipi-signal-*.wav")
+	if err != nil {
+		return "", err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// speakToFile renders text to a temporary WAV file with espeak-ng (or
+// espeak). The caller removes the file.
+func speakToFile(ctx context.Context, text string) (string, *models.AppError) {
+	bin, err := exec.LookPath("espeak-ng")
+	if err != nil {
+		if bin, err = exec.LookPath("espeak"); err != nil {
+			return "", models.ErrBadRequest("voice identify requires espeak-ng, which is not installed")
+		}
+	}
+	f, err := os.CreateTemp("", "amplipi-identify-*.wav")
+	if err != nil {
+		return "", models.ErrInternal(err.Error())
+	}
+	f.Close()
+	if out, err := exec.CommandContext(ctx, bin, "-w", f.Name(), "--", text).CombinedOutput(); err != nil {
+		os.Remove(f.Name())
+		return "", models.ErrInternal(fmt.Sprintf("espeak: %v: %s", err, out))
+	}
+	return f.Name(), nil
+}
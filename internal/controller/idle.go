@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// RunSourceIdle applies the source idle policies every interval: a source
+// whose stream has been stopped or paused for longer than its policy allows
+// is turned off. Blocks until ctx is cancelled.
+func (c *Controller) RunSourceIdle(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkSourceIdle()
+		}
+	}
+}
+
+// checkSourceIdle records when each policed source's stream went idle and
+// turns off the sources whose timeout has passed.
+func (c *Controller) checkSourceIdle() {
+	now := c.now()
+	state := c.State()
+
+	var due []models.SourceAutoOff
+	c.idleMu.Lock()
+	since := make(map[int]time.Time)
+	for _, p := range state.Settings.SourceIdle {
+		st := connectedStream(&state, p.SourceID)
+		if p.Minutes <= 0 || st == nil || !streamIdle(st) {
+			continue
+		}
+		start, ok := c.idleSince[p.SourceID]
+		if !ok {
+			start = now
+		}
+		if now.Sub(start) < time.Duration(p.Minutes)*time.Minute {
+			since[p.SourceID] = start
+			continue
+		}
+		due = append(due, models.SourceAutoOff{SourceID: p.SourceID, StreamID: st.ID, IdleMinutes: p.Minutes})
+	}
+	c.idleSince = since
+	c.idleMu.Unlock()
+
+	for _, off := range due {
+		c.sourceAutoOff(off)
+	}
+}
+
+// sourceAutoOff disconnects a source from its stream and mutes the zones
+// playing it. Stream sync then frees the stream's virtual source.
+func (c *Controller) sourceAutoOff(off models.SourceAutoOff) {
+	_, err := c.apply(func(s *models.State) error {
+		src := findSourceInState(s, off.SourceID)
+		if src == nil || src.Input != fmt.Sprintf("stream=%d", off.StreamID) {
+			return errNoChange // changed since the check
+		}
+		src.Input = ""
+		c.updateSourceTypeHW(s)
+		units := make(map[int]bool)
+		for i := range s.Zones {
+			z := &s.Zones[i]
+			if z.SourceID == off.SourceID && !z.Mute {
+				z.Mute = true
+				units[z.ID/6] = true
+			}
+		}
+		for unit := range units {
+			pushZoneMutes(c, s, unit)
+		}
+		updateGroupAggregates(s)
+		return nil
+	})
+	if err != nil {
+		return
+	}
+	slog.Info("source idle, turned off", "source", off.SourceID, "stream", off.StreamID, "minutes", off.IdleMinutes)
+	c.bus.Emit(models.Event{Type: models.EventSourceAutoOff, Time: c.now(), Data: off})
+}
+
+// connectedStream returns the stream a source's input selects, if any.
+func connectedStream(s *models.State, sourceID int) *models.Stream {
+	src := findSourceInState(s, sourceID)
+	if src == nil {
+		return nil
+	}
+	var id int
+	if _, err := fmt.Sscanf(src.Input, "stream=%d", &id); err != nil {
+		return nil
+	}
+	return findStream(s, id)
+}
+
+// streamIdle reports whether a stream is stopped or paused.
+func streamIdle(st *models.Stream) bool {
+	return st.Info.State == "stopped" || st.Info.State == "paused"
+}
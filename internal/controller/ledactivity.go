@@ -0,0 +1,110 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// RunLEDActivity drives the front-panel LEDs from the LED settings: after
+// every state change, and every interval for the off hours. Blocks until
+// ctx is cancelled.
+func (c *Controller) RunLEDActivity(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.refreshLEDActivity(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-c.ledKick:
+		}
+	}
+}
+
+// kickLEDs asks RunLEDActivity to refresh the LEDs without blocking.
+func (c *Controller) kickLEDs() {
+	select {
+	case c.ledKick <- struct{}{}:
+	default:
+	}
+}
+
+// refreshLEDActivity writes each unit's LEDs as the settings want them.
+// Units whose LEDs were set through the API are left alone; with neither
+// zone activity nor off hours in force the firmware gets them back.
+func (c *Controller) refreshLEDActivity(ctx context.Context) {
+	c.mu.RLock()
+	settings := c.state.Settings.LEDs
+	active := make(map[int]bool)
+	for i := range c.state.Zones {
+		z := &c.state.Zones[i]
+		active[z.ID] = zonePlaying(&c.state, z)
+	}
+	c.mu.RUnlock()
+	off := settings.InOffHours(c.localNow())
+	units := make(map[int]hardware.UnitInfo)
+	for _, u := range c.detectedUnits() {
+		units[u.Index] = u
+	}
+
+	c.ledMu.Lock()
+	defer c.ledMu.Unlock()
+	for _, unit := range c.hw.Units() {
+		l, appErr := c.ledUnitLocked(ctx, unit)
+		if appErr != nil || l.manual {
+			continue
+		}
+		if !off && !settings.ZoneActivity {
+			if l.auto {
+				l.auto, l.override = false, false
+				c.writeAutoLEDsLocked(ctx, l)
+			}
+			continue
+		}
+		want := hardware.LEDState{}
+		if !off {
+			want.Green = true // powered on, as the firmware shows it
+			u := units[unit]
+			for i := 0; i < min(u.ZoneCount, len(want.Zones)); i++ {
+				want.Zones[i] = active[u.ZoneBase+i]
+			}
+		}
+		if l.auto && l.override && l.state == want {
+			continue
+		}
+		l.auto, l.override, l.state = true, true, want
+		c.writeAutoLEDsLocked(ctx, l)
+	}
+}
+
+// writeAutoLEDsLocked writes a unit's LEDs unless a pattern is running,
+// which restores them when it ends. Must be called with c.ledMu held.
+func (c *Controller) writeAutoLEDsLocked(ctx context.Context, l *ledUnit) {
+	if l.cancel != nil {
+		return
+	}
+	if err := c.writeLEDs(ctx, l.unit, l.override, l.state); err != nil {
+		slog.Debug("LED activity write failed", "unit", l.unit, "err", err)
+	}
+}
+
+// zonePlaying reports whether a zone is unmuted and its source's stream is
+// playing or, for RCA inputs, has a signal.
+func zonePlaying(s *models.State, z *models.Zone) bool {
+	if z.Mute || z.Disabled {
+		return false
+	}
+	st := connectedStream(s, z.SourceID)
+	if st == nil {
+		return false
+	}
+	if st.Active != nil {
+		return *st.Active
+	}
+	return st.Info.State == "playing"
+}
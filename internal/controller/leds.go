@@ -0,0 +1,250 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+const (
+	defaultIdentifyDuration = 10 * time.Second
+	maxIdentifyDuration     = 5 * time.Minute
+	identifyBlinkInterval   = 250 * time.Millisecond
+)
+
+// ledUnit is the software LED state of one unit. While a pattern runs,
+// override and state hold what to restore when it ends.
+type ledUnit struct {
+	unit     int
+	override bool
+	state    hardware.LEDState
+	pattern  string
+	patCtx   context.Context // context of the running pattern
+	cancel   context.CancelFunc
+	manual   bool // override set through SetLEDs; LED settings leave the unit alone
+	auto     bool // driven by the LED settings
+}
+
+// ledUnitLocked returns the LED state of unit, creating it from the
+// firmware registers on first use. Must be called with c.ledMu held.
+func (c *Controller) ledUnitLocked(ctx context.Context, unit int) (*ledUnit, *models.AppError) {
+	if !slices.Contains(c.hw.Units(), unit) {
+		return nil, models.ErrNotFound(fmt.Sprintf("unit %d not found", unit))
+	}
+	if l, ok := c.leds[unit]; ok {
+		return l, nil
+	}
+	l := &ledUnit{unit: unit}
+	if v, err := c.hw.Read(ctx, unit, hardware.RegLEDCtrl); err == nil {
+		l.override = v&1 != 0
+	}
+	if v, err := c.hw.Read(ctx, unit, hardware.RegLEDVal); err == nil {
+		l.state.Green = v&(1<<0) != 0
+		l.state.Red = v&(1<<1) != 0
+		for i := range l.state.Zones {
+			l.state.Zones[i] = v&(1<<uint(i+2)) != 0
+		}
+	}
+	c.leds[unit] = l
+	return l, nil
+}
+
+// GetLEDs returns the LED state of every unit.
+func (c *Controller) GetLEDs(ctx context.Context) []models.LEDs {
+	c.ledMu.Lock()
+	defer c.ledMu.Unlock()
+	result := []models.LEDs{}
+	for _, unit := range c.hw.Units() {
+		l, appErr := c.ledUnitLocked(ctx, unit)
+		if appErr != nil {
+			continue
+		}
+		result = append(result, models.LEDs{
+			Unit:     unit,
+			Override: l.override,
+			Green:    l.state.Green,
+			Red:      l.state.Red,
+			Zones:    l.state.Zones,
+			Pattern:  l.pattern,
+			Auto:     l.auto,
+		})
+	}
+	return result
+}
+
+// SetLEDs updates a unit's LED override and LED states. A running pattern
+// on the unit is stopped.
+func (c *Controller) SetLEDs(ctx context.Context, unit int, upd models.LEDUpdate) ([]models.LEDs, *models.AppError) {
+	if len(upd.Zones) > 6 {
+		return nil, models.ErrBadRequest("at most 6 zone LEDs per unit")
+	}
+	c.ledMu.Lock()
+	l, appErr := c.ledUnitLocked(ctx, unit)
+	if appErr != nil {
+		c.ledMu.Unlock()
+		return nil, appErr
+	}
+	c.stopPatternLocked(l)
+
+	override := l.override
+	if upd.Green != nil || upd.Red != nil || len(upd.Zones) > 0 {
+		override = true
+	}
+	if upd.Override != nil {
+		override = *upd.Override
+	}
+	state := l.state
+	if upd.Green != nil {
+		state.Green = *upd.Green
+	}
+	if upd.Red != nil {
+		state.Red = *upd.Red
+	}
+	for i, on := range upd.Zones {
+		if on != nil {
+			state.Zones[i] = *on
+		}
+	}
+	err := c.writeLEDs(ctx, unit, override, state)
+	if err == nil {
+		l.override, l.state = override, state
+		l.manual, l.auto = override, false
+	}
+	c.ledMu.Unlock()
+
+	if err != nil {
+		return nil, models.ErrInternal(err.Error())
+	}
+	c.kickLEDs() // LED settings take over again when the override is off
+	return c.GetLEDs(ctx), nil
+}
+
+// IdentifyLEDs blinks a zone's LED, or all LEDs of a unit, for the
+// requested duration and then restores the previous LED state.
+func (c *Controller) IdentifyLEDs(ctx context.Context, req models.LEDIdentify) ([]models.LEDs, *models.AppError) {
+	duration := defaultIdentifyDuration
+	if req.Duration < 0 || time.Duration(req.Duration)*time.Second > maxIdentifyDuration {
+		return nil, models.ErrBadRequest(fmt.Sprintf("duration must be 0-%d seconds", int(maxIdentifyDuration/time.Second)))
+	} else if req.Duration > 0 {
+		duration = time.Duration(req.Duration) * time.Second
+	}
+
+	var unit int
+	var blink hardware.LEDState
+	switch {
+	case req.Zone != nil:
+		c.mu.RLock()
+		z := findZone(&c.state, *req.Zone)
+		c.mu.RUnlock()
+		if z == nil {
+			return nil, models.ErrNotFound(fmt.Sprintf("zone %d not found", *req.Zone))
+		}
+		unit = z.ID / 6
+		blink.Zones[z.ID%6] = true
+	case req.Unit != nil:
+		unit = *req.Unit
+		blink = hardware.LEDState{Green: true, Red: true, Zones: [6]bool{true, true, true, true, true, true}}
+	default:
+		return nil, models.ErrBadRequest("zone or unit is required")
+	}
+
+	c.ledMu.Lock()
+	l, appErr := c.ledUnitLocked(ctx, unit)
+	if appErr != nil {
+		c.ledMu.Unlock()
+		return nil, appErr
+	}
+	c.stopPatternLocked(l)
+	pctx, cancel := context.WithTimeout(context.Background(), duration)
+	l.pattern, l.patCtx, l.cancel = "identify", pctx, cancel
+	c.writeBlinkFrame(pctx, l, blink, true)
+	c.ledMu.Unlock()
+
+	go c.runBlink(pctx, l, blink)
+	return c.GetLEDs(ctx), nil
+}
+
+// StopLEDPatterns stops all running LED patterns, restoring the LEDs.
+func (c *Controller) StopLEDPatterns(ctx context.Context) []models.LEDs {
+	c.ledMu.Lock()
+	for _, l := range c.leds {
+		c.stopPatternLocked(l)
+	}
+	c.ledMu.Unlock()
+	return c.GetLEDs(ctx)
+}
+
+// stopPatternLocked cancels a running pattern and restores the unit's LEDs
+// synchronously. Must be called with c.ledMu held.
+func (c *Controller) stopPatternLocked(l *ledUnit) {
+	if l.cancel == nil {
+		return
+	}
+	l.cancel()
+	l.pattern, l.patCtx, l.cancel = "", nil, nil
+	if err := c.writeLEDs(context.Background(), l.unit, l.override, l.state); err != nil {
+		slog.Warn("LED restore failed", "unit", l.unit, "err", err)
+	}
+}
+
+// runBlink toggles the blink LEDs on top of the unit's state until ctx
+// ends, then restores the unit unless the pattern was replaced or stopped.
+// The first (lit) frame has already been written.
+func (c *Controller) runBlink(ctx context.Context, l *ledUnit, blink hardware.LEDState) {
+	ticker := time.NewTicker(identifyBlinkInterval)
+	defer ticker.Stop()
+	on := false
+	for {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		c.ledMu.Lock()
+		if ctx.Err() != nil {
+			if l.patCtx == ctx {
+				c.stopPatternLocked(l)
+			}
+			c.ledMu.Unlock()
+			return
+		}
+		c.writeBlinkFrame(ctx, l, blink, on)
+		c.ledMu.Unlock()
+		on = !on
+	}
+}
+
+// writeBlinkFrame writes the unit's state with the blink LEDs forced on or
+// off. Must be called with c.ledMu held.
+func (c *Controller) writeBlinkFrame(ctx context.Context, l *ledUnit, blink hardware.LEDState, on bool) {
+	state := l.state
+	if on {
+		state.Green = state.Green || blink.Green
+		state.Red = state.Red || blink.Red
+		for i := range state.Zones {
+			state.Zones[i] = state.Zones[i] || blink.Zones[i]
+		}
+	} else {
+		state.Green = state.Green && !blink.Green
+		state.Red = state.Red && !blink.Red
+		for i := range state.Zones {
+			state.Zones[i] = state.Zones[i] && !blink.Zones[i]
+		}
+	}
+	if err := c.writeLEDs(ctx, l.unit, true, state); err != nil {
+		slog.Debug("LED blink write failed", "unit", l.unit, "err", err)
+	}
+}
+
+// writeLEDs writes the LED state and then the override flag, so the
+// firmware never shows a stale state with the override on.
+func (c *Controller) writeLEDs(ctx context.Context, unit int, override bool, state hardware.LEDState) error {
+	if err := c.hw.SetLEDState(ctx, unit, state); err != nil {
+		return err
+	}
+	return c.hw.SetLEDOverride(ctx, unit, override)
+}
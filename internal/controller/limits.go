@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// StreamerMode reports whether the controller runs on a streamer-only unit,
+// which has sources and streams but no amplified zones or groups.
+func (c *Controller) StreamerMode() bool {
+	return c.profile != nil && c.profile.IsStreamer && c.profile.TotalZones == 0
+}
+
+// zoneLimit returns the number of zones the detected hardware provides.
+// Without a profile (tests) any zone the bus can address is allowed.
+func (c *Controller) zoneLimit() int {
+	if c.profile == nil {
+		return hardware.MaxUnits * hardware.ZonesPerUnit
+	}
+	return c.profile.TotalZones
+}
+
+// sourceLimit returns the number of sources. Streamer-only units have no
+// preamp sources but still play up to SourcesPerUnit outputs through DACs.
+func (c *Controller) sourceLimit() int {
+	if c.profile == nil || c.profile.TotalSources == 0 {
+		return hardware.SourcesPerUnit
+	}
+	return c.profile.TotalSources
+}
+
+// checkLimits returns an error if a configuration has zones or sources the
+// detected hardware cannot drive.
+func (c *Controller) checkLimits(s *models.State) error {
+	if c.StreamerMode() && len(s.Groups) > 0 {
+		return fmt.Errorf("groups are not available on a streamer unit")
+	}
+	zones, sources := c.zoneLimit(), c.sourceLimit()
+	for _, z := range s.Zones {
+		if z.ID < 0 || z.ID >= zones {
+			return fmt.Errorf("zone %d exceeds the detected hardware (%d zones)", z.ID, zones)
+		}
+	}
+	for _, src := range s.Sources {
+		if src.ID < 0 || src.ID >= sources {
+			return fmt.Errorf("source %d exceeds the detected hardware (%d sources)", src.ID, sources)
+		}
+	}
+	return nil
+}
+
+// reconcileZones appends default zones for detected units the
+// configuration does not cover yet, e.g. after an expander is added, and
+// warns about configured zones beyond the detected hardware. Streamer units
+// drop zones and groups altogether.
+func (c *Controller) reconcileZones(s *models.State) {
+	if c.profile == nil {
+		return
+	}
+	if c.StreamerMode() {
+		if len(s.Zones) > 0 || len(s.Groups) > 0 {
+			slog.Info("streamer unit: removing zones and groups from config", "zones", len(s.Zones), "groups", len(s.Groups))
+		}
+		s.Zones, s.Groups = []models.Zone{}, []models.Group{}
+		return
+	}
+	if err := c.checkLimits(s); err != nil {
+		slog.Warn("config exceeds detected hardware", "err", err)
+	}
+	def := models.DefaultStateFromProfile(c.profile)
+	for _, z := range def.Zones {
+		if findZone(s, z.ID) == nil {
+			slog.Info("adding zone for detected unit", "zone", z.ID, "unit", z.ID/hardware.ZonesPerUnit)
+			s.Zones = append(s.Zones, z)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/logs"
+)
+
+// SetLogs enables the log API backed by buf. Must be called before the
+// HTTP server starts.
+func (c *Controller) SetLogs(buf *logs.Buffer) {
+	c.mu.Lock()
+	c.logBuf = buf
+	c.mu.Unlock()
+}
+
+// LogBuffer returns the in-memory daemon log, or nil if none is configured.
+func (c *Controller) LogBuffer() *logs.Buffer {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.logBuf
+}
+
+// bufferedLog renders the in-memory log as text, one record per line.
+func (c *Controller) bufferedLog() []byte {
+	buf := c.LogBuffer()
+	if buf == nil {
+		return nil
+	}
+	var sb strings.Builder
+	for _, e := range buf.Entries(logs.Filter{Level: slog.LevelDebug}) {
+		fmt.Fprintf(&sb, "%s %s [%s] %s", e.Time.Format("2006-01-02T15:04:05.000Z07:00"), e.Level, e.Subsystem, e.Message)
+		keys := make([]string, 0, len(e.Attrs))
+		for k := range e.Attrs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(&sb, " %s=%v", k, e.Attrs[k])
+		}
+		sb.WriteByte('\n')
+	}
+	return []byte(sb.String())
+}
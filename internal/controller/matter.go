@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/micro-nova/amplipi-go/internal/matter"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// OpenMatterCommissioning makes the device commissionable, generating its
+// setup credentials on first use. With reset, new credentials replace the
+// old ones, so previously printed QR codes stop working.
+func (c *Controller) OpenMatterCommissioning(ctx context.Context, reset bool) (models.MatterSettings, *models.AppError) {
+	state, err := c.apply(func(s *models.State) error {
+		if s.Settings.Matter.Passcode != 0 && !reset {
+			return errNoChange
+		}
+		d, p, err := matter.NewCredentials()
+		if err != nil {
+			return err
+		}
+		s.Settings.Matter = models.MatterSettings{Discriminator: int(d), Passcode: int(p)}
+		return nil
+	})
+	if err == errNoChange {
+		return c.GetSettings().Matter, nil
+	}
+	if err != nil {
+		return models.MatterSettings{}, models.ErrInternal(err.Error())
+	}
+	return state.Settings.Matter, nil
+}
@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// checkName validates a name for the zone, group, preset or stream kind
+// and, given the names already taken by others of its kind, rejects
+// duplicates ignoring case. The error's Field is "name" so forms can show
+// it next to the input.
+func checkName(kind, name string, taken []string) *models.AppError {
+	if err := models.ValidateName(name); err != nil {
+		return models.ErrBadRequest(fmt.Sprintf("%s %s", kind, err)).WithField("name")
+	}
+	for _, t := range taken {
+		if strings.EqualFold(strings.TrimSpace(t), strings.TrimSpace(name)) {
+			return models.ErrConflict(fmt.Sprintf("a %s named %q already exists", kind, t)).WithField("name")
+		}
+	}
+	return nil
+}
+
+// zoneNames returns the names of all zones except id.
+func zoneNames(s *models.State, id int) []string {
+	var names []string
+	for _, z := range s.Zones {
+		if z.ID != id {
+			names = append(names, z.Name)
+		}
+	}
+	return names
+}
+
+// groupNames returns the names of all groups except id.
+func groupNames(s *models.State, id int) []string {
+	var names []string
+	for _, g := range s.Groups {
+		if g.ID != id {
+			names = append(names, g.Name)
+		}
+	}
+	return names
+}
+
+// presetNames returns the names of all presets except id.
+func presetNames(s *models.State, id int) []string {
+	var names []string
+	for _, p := range s.Presets {
+		if p.ID != id {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// duplicateIDs returns a field error for each item of list whose ID
+// repeats an earlier one, e.g. "zones[3].id".
+func duplicateIDs[T any](list string, items []T, id func(T) int) []models.FieldError {
+	var errs []models.FieldError
+	seen := make(map[int]int)
+	for i, it := range items {
+		n := id(it)
+		if first, ok := seen[n]; ok {
+			errs = append(errs, models.FieldError{
+				Field:   fmt.Sprintf("%s[%d].id", list, i),
+				Message: fmt.Sprintf("id %d is already used by %s[%d]", n, list, first),
+			})
+			continue
+		}
+		seen[n] = i
+	}
+	return errs
+}
+
+// configIDCollisions checks that no two sources, zones, groups, streams or
+// presets of an uploaded config share an ID.
+func configIDCollisions(s *models.State) []models.FieldError {
+	var errs []models.FieldError
+	errs = append(errs, duplicateIDs("sources", s.Sources, func(v models.Source) int { return v.ID })...)
+	errs = append(errs, duplicateIDs("zones", s.Zones, func(v models.Zone) int { return v.ID })...)
+	errs = append(errs, duplicateIDs("groups", s.Groups, func(v models.Group) int { return v.ID })...)
+	errs = append(errs, duplicateIDs("streams", s.Streams, func(v models.Stream) int { return v.ID })...)
+	errs = append(errs, duplicateIDs("presets", s.Presets, func(v models.Preset) int { return v.ID })...)
+	return errs
+}
+
+// duplicateNames describes the zones, groups and presets that share a
+// name, ignoring case. Configs from before names had to be unique may
+// have them.
+func duplicateNames(s *models.State) []string {
+	var dups []string
+	check := func(kind string, id int, name string, seen map[string]int) {
+		key := strings.ToLower(strings.TrimSpace(name))
+		if first, ok := seen[key]; ok {
+			dups = append(dups, fmt.Sprintf("%ss %d and %d are both named %q", kind, first, id, name))
+			return
+		}
+		seen[key] = id
+	}
+	seen := make(map[string]int)
+	for _, z := range s.Zones {
+		check("zone", z.ID, z.Name, seen)
+	}
+	seen = make(map[string]int)
+	for _, g := range s.Groups {
+		check("group", g.ID, g.Name, seen)
+	}
+	seen = make(map[string]int)
+	for _, p := range s.Presets {
+		check("preset", p.ID, p.Name, seen)
+	}
+	return dups
+}
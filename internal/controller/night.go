@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// RunNightMode applies zone quiet hours every interval: zones entering
+// their night window are turned down to the cap, and vol_limit follows the
+// schedule. Blocks until ctx is cancelled.
+func (c *Controller) RunNightMode(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.applyNightMode()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyNightMode updates every zone's night mode cap for the current time.
+func (c *Controller) applyNightMode() {
+	_, _ = c.apply(func(s *models.State) error {
+		now := c.localNow()
+		changed := false
+		for i := range s.Zones {
+			z := &s.Zones[i]
+			if bridgePrimary(s, z) != nil {
+				continue // follows its bridged zone below
+			}
+			prevLimit, prevVol := z.VolLimit, z.Vol
+			// The lock's cap was applied when the volume was set, unless
+			// an administrator overrode it
+			volMax := setVolLimit(z, now, true)
+			if z.Vol > volMax {
+				z.Vol = volMax
+				z.VolF = z.DBToVolF(z.Vol)
+				c.queueZoneVol(z.ID/6, z.ID%6, z.Vol)
+			}
+			if z.Vol != prevVol || !sameLimit(z.VolLimit, prevLimit) {
+				changed = true
+			}
+			if partner := bridgePartner(s, z); partner != nil && (partner.Vol != z.Vol || !sameLimit(partner.VolLimit, z.VolLimit)) {
+				mirrorBridge(partner, *z)
+				c.queueZoneVol(partner.ID/6, partner.ID%6, partner.Vol)
+				changed = true
+			}
+		}
+		if !changed {
+			return errNoChange
+		}
+		updateGroupAggregates(s)
+		return nil
+	})
+}
+
+// setVolLimit records the night mode or lock cap in force on z at t in
+// z.VolLimit, the lower if both are, and returns the zone's effective
+// maximum volume. With overrideLock, as for administrators, the lock's cap
+// is recorded but not applied.
+func setVolLimit(z *models.Zone, t time.Time, overrideLock bool) int {
+	z.VolLimit = nil
+	volMax := z.VolMax
+	var caps []int
+	if z.Night != nil && z.Night.Active(t) {
+		caps = append(caps, z.Night.VolMax)
+		volMax = min(volMax, z.Night.VolMax)
+	}
+	if z.Lock != nil && z.Lock.VolMax != nil {
+		caps = append(caps, *z.Lock.VolMax)
+		if !overrideLock {
+			volMax = min(volMax, *z.Lock.VolMax)
+		}
+	}
+	if len(caps) > 0 {
+		limit := slices.Min(caps)
+		z.VolLimit = &limit
+	}
+	return volMax
+}
+
+func sameLimit(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
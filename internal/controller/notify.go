@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// emitChanges emits the events describing a state change: zone_changed
+// for all changed zones at once, stream_started for each stream that
+// began playing and stream_unavailable for each stream that cannot run.
+// Must be called with c.mu held.
+func (c *Controller) emitChanges(prev, next *models.State) {
+	now := c.now()
+	var changed []models.Zone
+	for _, z := range next.Zones {
+		if old := findZone(prev, z.ID); old == nil || !reflect.DeepEqual(*old, z) {
+			changed = append(changed, z)
+		}
+	}
+	if len(changed) > 0 {
+		c.bus.Emit(models.Event{Type: models.EventZoneChanged, Time: now, Data: models.ZoneChanged{Zones: changed}})
+	}
+
+	for _, st := range next.Streams {
+		if st.Info.State != "playing" {
+			continue
+		}
+		if old := findStream(prev, st.ID); old != nil && old.Info.State == "playing" {
+			continue
+		}
+		c.bus.Emit(models.Event{Type: models.EventStreamStarted, Time: now, Data: models.StreamStarted{
+			StreamID: st.ID, Name: st.Name, Type: st.Type, Info: st.Info,
+		}})
+	}
+	c.emitUnavailable(prev, next)
+	c.runStreamHooks(prev, next)
+}
+
+// emitUnavailable warns about streams marked unavailable since prev, once
+// each, instead of letting them crash-loop.
+func (c *Controller) emitUnavailable(prev, next *models.State) {
+	for _, st := range next.Streams {
+		if st.Info.State != "unavailable" || st.Info.Reason == "" {
+			continue
+		}
+		if old := findStream(prev, st.ID); old != nil && old.Info.Reason != "" {
+			continue
+		}
+		slog.Warn("stream unavailable on this hardware, not starting it", "id", st.ID, "type", st.Type, "reason", st.Info.Reason)
+		c.bus.Emit(models.Event{Type: models.EventStreamUnavailable, Time: c.now(), Data: models.StreamUnavailable{
+			StreamID: st.ID, Name: st.Name, Type: st.Type, Reason: st.Info.Reason,
+		}})
+	}
+}
+
+// checkOverTemp emits over_temp when a unit's fan controller starts
+// reporting over-temperature.
+func (c *Controller) checkOverTemp(samples []healthSample) {
+	c.healthMu.Lock()
+	defer c.healthMu.Unlock()
+	if c.overTemp == nil {
+		c.overTemp = make(map[int]bool)
+	}
+	for _, s := range samples {
+		if s.Fan == nil {
+			continue
+		}
+		was := c.overTemp[s.Unit]
+		c.overTemp[s.Unit] = s.Fan.OvrTmp
+		if !s.Fan.OvrTmp || was {
+			continue
+		}
+		ev := models.OverTemp{Unit: s.Unit}
+		if t := s.Temps; t != nil {
+			ev.MaxC = max(t.Amp1C, t.Amp2C, t.PSU1C, t.PSU2C)
+		}
+		c.bus.Emit(models.Event{Type: models.EventOverTemp, Time: s.Time, Data: ev})
+	}
+}
+
+// NotifyRelease emits update_available when latest is newer than the
+// running version, once per release. Called by the release checker.
+func (c *Controller) NotifyRelease(latest string) {
+	c.mu.Lock()
+	current := c.state.Info.Version
+	notify := latest != c.release && newerVersion(latest, current)
+	if notify {
+		c.release = latest
+	}
+	c.mu.Unlock()
+	if notify {
+		c.bus.Emit(models.Event{Type: models.EventUpdateAvailable, Time: c.now(), Data: models.UpdateAvailable{
+			Current: current, Latest: latest,
+		}})
+	}
+}
+
+// newerVersion reports whether dotted version a is newer than b, comparing
+// numeric components and ignoring any "v" prefix and pre-release or build
+// suffix.
+func newerVersion(a, b string) bool {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := 0; i < max(len(pa), len(pb)); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+func versionParts(v string) []int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts []int
+	for _, f := range strings.Split(v, ".") {
+		n, _ := strconv.Atoi(f)
+		parts = append(parts, n)
+	}
+	return parts
+}
@@ -0,0 +1,210 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// SetAudioOutputs enables the physical output API backed by o. Must be
+// called before the HTTP server starts. On streamer-only units this also
+// creates a source for every configured output so streams can be routed to it.
+func (c *Controller) SetAudioOutputs(o *audio.Outputs) {
+	c.mu.Lock()
+	c.outputs = o
+	c.mu.Unlock()
+	c.syncOutputSources()
+}
+
+// audioOutputs returns the output manager or a 400 if none is configured.
+func (c *Controller) audioOutputs() (*audio.Outputs, *models.AppError) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.outputs == nil {
+		return nil, models.ErrBadRequest("physical output configuration is not available")
+	}
+	return c.outputs, nil
+}
+
+// GetOutputs returns all configured physical outputs.
+func (c *Controller) GetOutputs() []models.AudioOutput {
+	o, appErr := c.audioOutputs()
+	if appErr != nil {
+		return []models.AudioOutput{}
+	}
+	usb := make(map[string]bool)
+	for _, card := range o.Cards() {
+		usb[card.ID] = card.USB
+	}
+	list := o.List()
+	result := make([]models.AudioOutput, 0, len(list))
+	for _, out := range list {
+		result = append(result, models.AudioOutput{
+			ID:       out.Index,
+			Name:     out.Name,
+			Card:     out.Card,
+			Device:   out.Device,
+			Channels: out.Channels,
+			Left:     out.Left,
+			Right:    out.Right,
+			PCM:      out.PCM,
+			Present:  out.Present,
+			USB:      usb[out.Card],
+		})
+	}
+	return result
+}
+
+// GetAudioCards returns the ALSA cards currently present.
+func (c *Controller) GetAudioCards() []models.AudioCard {
+	o, appErr := c.audioOutputs()
+	if appErr != nil {
+		return []models.AudioCard{}
+	}
+	cards := o.Cards()
+	result := make([]models.AudioCard, 0, len(cards))
+	for _, card := range cards {
+		result = append(result, models.AudioCard{Index: card.Index, ID: card.ID, Name: card.Name, USB: card.USB})
+	}
+	return result
+}
+
+// CreateOutput maps source req.ID to a new physical output.
+func (c *Controller) CreateOutput(ctx context.Context, req models.AudioOutputUpdate) ([]models.AudioOutput, *models.AppError) {
+	o, appErr := c.audioOutputs()
+	if appErr != nil {
+		return nil, appErr
+	}
+	if req.ID == nil {
+		return nil, models.ErrBadRequest("output id is required")
+	}
+	if limit := c.sourceLimit(); *req.ID < 0 || *req.ID >= limit {
+		return nil, models.ErrBadRequest(fmt.Sprintf("output id must be 0-%d", limit-1))
+	}
+	if req.Card == nil || *req.Card == "" {
+		return nil, models.ErrBadRequest("output card is required")
+	}
+	if o.Layout().Output(*req.ID) != nil {
+		return nil, models.ErrConflict(fmt.Sprintf("output %d already exists", *req.ID))
+	}
+
+	out := audio.Output{Index: *req.ID, Channels: 2, Left: 0, Right: 1}
+	applyOutputUpdate(&out, req)
+	if err := o.Set(out); err != nil {
+		return nil, models.ErrBadRequest(err.Error())
+	}
+	c.syncOutputSources()
+	return c.GetOutputs(), nil
+}
+
+// SetOutput edits an existing physical output.
+func (c *Controller) SetOutput(ctx context.Context, id int, upd models.AudioOutputUpdate) ([]models.AudioOutput, *models.AppError) {
+	o, appErr := c.audioOutputs()
+	if appErr != nil {
+		return nil, appErr
+	}
+	existing := o.Layout().Output(id)
+	if existing == nil {
+		return nil, models.ErrNotFound("output not found")
+	}
+	out := *existing
+	applyOutputUpdate(&out, upd)
+	if err := o.Set(out); err != nil {
+		return nil, models.ErrBadRequest(err.Error())
+	}
+	c.syncOutputSources()
+	return c.GetOutputs(), nil
+}
+
+// DeleteOutput removes a physical output mapping.
+func (c *Controller) DeleteOutput(ctx context.Context, id int) ([]models.AudioOutput, *models.AppError) {
+	o, appErr := c.audioOutputs()
+	if appErr != nil {
+		return nil, appErr
+	}
+	if o.Layout().Output(id) == nil {
+		return nil, models.ErrNotFound("output not found")
+	}
+	if err := o.Delete(id); err != nil {
+		return nil, models.ErrInternal(err.Error())
+	}
+	c.syncOutputSources()
+	return c.GetOutputs(), nil
+}
+
+// applyOutputUpdate copies the set fields of upd into out. ID is not copied.
+func applyOutputUpdate(out *audio.Output, upd models.AudioOutputUpdate) {
+	if upd.Name != nil {
+		out.Name = *upd.Name
+	}
+	if upd.Card != nil {
+		out.Card = *upd.Card
+	}
+	if upd.Device != nil {
+		out.Device = *upd.Device
+	}
+	if upd.Channels != nil {
+		out.Channels = *upd.Channels
+	}
+	if upd.Left != nil {
+		out.Left = *upd.Left
+	}
+	if upd.Right != nil {
+		out.Right = *upd.Right
+	}
+}
+
+// hasOutput reports whether source id has a configured physical output.
+func (c *Controller) hasOutput(id int) bool {
+	c.mu.RLock()
+	o := c.outputs
+	c.mu.RUnlock()
+	return o != nil && o.Layout().Output(id) != nil
+}
+
+// syncOutputSources makes the source list mirror the configured outputs on
+// streamer-only units, which have no preamp sources of their own. Main units
+// keep their fixed four sources and are left untouched.
+func (c *Controller) syncOutputSources() {
+	if c.profile == nil || c.profile.TotalSources > 0 {
+		return
+	}
+	c.mu.RLock()
+	o := c.outputs
+	c.mu.RUnlock()
+	if o == nil {
+		return
+	}
+	layout := o.Layout()
+
+	_, _ = c.apply(func(s *models.State) error {
+		var sources []models.Source
+		for _, src := range s.Sources {
+			if layout.Output(src.ID) != nil {
+				sources = append(sources, src)
+			}
+		}
+		for _, out := range layout.Outputs {
+			if out.Index >= c.sourceLimit() {
+				continue
+			}
+			if slices.ContainsFunc(sources, func(src models.Source) bool { return src.ID == out.Index }) {
+				continue
+			}
+			name := out.Name
+			if name == "" {
+				name = fmt.Sprintf("Output %d", out.Index+1)
+			}
+			sources = append(sources, models.Source{ID: out.Index, Name: name})
+		}
+		slices.SortFunc(sources, func(a, b models.Source) int { return a.ID - b.ID })
+		if sources == nil {
+			sources = []models.Source{}
+		}
+		s.Sources = sources
+		return nil
+	})
+}
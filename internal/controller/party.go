@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// PARTY_RESTORE_PRESET_ID is the fixed ID of the preset holding the zone
+// routing from before party mode.
+const PARTY_RESTORE_PRESET_ID = 9997
+
+// StartParty routes the selected zones (default: all enabled zones) to one
+// source, unmuted. The routing of each zone is saved in a restore preset
+// the first time party mode changes it, so EndParty returns to the state
+// from before the party however often it is adjusted.
+func (c *Controller) StartParty(ctx context.Context, req models.PartyRequest) (models.State, *models.AppError) {
+	if limit := c.sourceLimit(); req.SourceID < 0 || req.SourceID >= limit {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("source_id must be 0-%d", limit-1))
+	}
+	if req.VolF != nil && (*req.VolF < 0 || *req.VolF > 1) {
+		return models.State{}, models.ErrBadRequest("vol_f must be between 0.0 and 1.0")
+	}
+	zones, appErr := c.determineTargetZones(req.Zones, req.Groups)
+	if appErr != nil {
+		return models.State{}, appErr
+	}
+	slices.Sort(zones)
+
+	state, err := c.apply(func(s *models.State) error {
+		saveZoneRouting(s, zones)
+		party := &models.PresetState{}
+		for _, id := range zones {
+			zid, src, mute := id, req.SourceID, false
+			upd := models.ZoneUpdate{ID: &zid, SourceID: &src, Mute: &mute, Vol: req.Vol}
+			if req.Vol == nil {
+				upd.VolF = req.VolF
+			}
+			party.Zones = append(party.Zones, upd)
+		}
+		return c.applyPresetState(ctx, s, party, nil)
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, appErr
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return state, nil
+}
+
+// EndParty restores the zone routing saved by StartParty.
+func (c *Controller) EndParty(ctx context.Context) (models.State, *models.AppError) {
+	state, err := c.apply(func(s *models.State) error {
+		for i, p := range s.Presets {
+			if p.ID != PARTY_RESTORE_PRESET_ID {
+				continue
+			}
+			s.Presets = append(s.Presets[:i], s.Presets[i+1:]...)
+			if p.State == nil {
+				return nil
+			}
+			return c.applyPresetState(ctx, s, p.State, nil)
+		}
+		return models.ErrNotFound("party mode is not active")
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, appErr
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return state, nil
+}
+
+// saveZoneRouting records the source, mute and volume of the given zones
+// in the party restore preset, creating it if needed. Zones already saved
+// keep their pre-party routing.
+func saveZoneRouting(s *models.State, zones []int) {
+	p := findPreset(s, PARTY_RESTORE_PRESET_ID)
+	if p == nil {
+		s.Presets = append(s.Presets, models.Preset{ID: PARTY_RESTORE_PRESET_ID, Name: "Party - Saved State"})
+		p = &s.Presets[len(s.Presets)-1]
+	}
+	// DeepCopy shares the update slices, so build a new preset state.
+	var ps models.PresetState
+	if p.State != nil {
+		ps.Zones = append(ps.Zones, p.State.Zones...)
+	}
+	saved := make(map[int]bool)
+	for _, upd := range ps.Zones {
+		if upd.ID != nil {
+			saved[*upd.ID] = true
+		}
+	}
+	for _, id := range zones {
+		z := findZone(s, id)
+		if z == nil || saved[id] {
+			continue
+		}
+		zid, src, mute, vol := z.ID, z.SourceID, z.Mute, z.Vol
+		ps.Zones = append(ps.Zones, models.ZoneUpdate{ID: &zid, SourceID: &src, Mute: &mute, Vol: &vol})
+	}
+	p.State = &ps
+}
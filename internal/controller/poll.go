@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"reflect"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// stateSections are the sections of the state a poll returns separately:
+// sources, zones, groups, streams, presets, info and settings.
+func stateSections(s *models.State) [7]any {
+	return [...]any{s.Sources, s.Zones, s.Groups, s.Streams, s.Presets, s.Info, s.Settings}
+}
+
+// bumpStateRev moves to the next state revision and records the sections
+// that changed from prev in it. Must be called with c.mu held.
+func (c *Controller) bumpStateRev(prev, next *models.State) {
+	c.stateRev++
+	before, after := stateSections(prev), stateSections(next)
+	for i := range after {
+		if !reflect.DeepEqual(before[i], after[i]) {
+			c.sectionRevs[i] = c.stateRev
+		}
+	}
+}
+
+// Poll returns the sections of the state that changed since revision rev,
+// and false if nothing did. Revisions start at the time the controller was
+// created, in milliseconds, so a revision from before a restart is older
+// than every section and gets the whole state, as does one from the
+// future.
+func (c *Controller) Poll(rev int64) (models.StatePoll, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	p := models.StatePoll{Rev: c.stateRev}
+	if rev == c.stateRev {
+		return p, false
+	}
+	changed := func(section int) bool { return rev > c.stateRev || c.sectionRevs[section] > rev }
+	s := c.state.DeepCopy()
+	if changed(0) {
+		p.Sources = orEmpty(s.Sources)
+	}
+	if changed(1) {
+		p.Zones = orEmpty(s.Zones)
+	}
+	if changed(2) {
+		p.Groups = orEmpty(s.Groups)
+	}
+	if changed(3) {
+		p.Streams = orEmpty(s.Streams)
+	}
+	if changed(4) {
+		p.Presets = orEmpty(s.Presets)
+	}
+	if changed(5) {
+		p.Info = &s.Info
+	}
+	if changed(6) {
+		p.Settings = &s.Settings
+	}
+	return p, true
+}
+
+// orEmpty returns s, or an empty slice if it is nil, so a section that
+// changed to empty is still sent.
+func orEmpty[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
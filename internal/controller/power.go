@@ -0,0 +1,116 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// powerConfirmWindow is how long a reboot, shutdown or factory reset token
+// is valid.
+const powerConfirmWindow = 30 * time.Second
+
+// systemPower reboots or powers off the Pi through systemd, with sudo;
+// setup.sh allows exactly "systemctl reboot" and "systemctl poweroff" in
+// sudoers. systemd then stops the daemon as on any shutdown. Replaced in
+// tests.
+var systemPower = func(ctx context.Context, action string) error {
+	verb := "reboot"
+	if action == models.PowerShutdown {
+		verb = "poweroff"
+	}
+	if out, err := exec.CommandContext(ctx, "sudo", "-n", "systemctl", verb).CombinedOutput(); err != nil {
+		return fmt.Errorf("systemctl %s: %v: %s", verb, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// powerToken is an outstanding reboot, shutdown or factory reset
+// confirmation. There is one at a time: a new request replaces it.
+type powerToken struct {
+	action  string
+	token   string
+	expires time.Time
+}
+
+// issueConfirm returns a new token for action.
+func (c *Controller) issueConfirm(action string) powerToken {
+	c.powerMu.Lock()
+	defer c.powerMu.Unlock()
+	c.power = powerToken{action: action, token: rand.Text(), expires: c.now().Add(powerConfirmWindow)}
+	return c.power
+}
+
+// checkConfirm reports whether confirm is the unexpired token for action,
+// using it up.
+func (c *Controller) checkConfirm(action, confirm string) bool {
+	c.powerMu.Lock()
+	defer c.powerMu.Unlock()
+	t := c.power
+	ok := t.token != "" && t.action == action && confirm == t.token && c.now().Before(t.expires)
+	if ok {
+		c.power = powerToken{} // single use
+	}
+	return ok
+}
+
+// Power reboots or shuts down the unit in two steps, so a stray request
+// cannot: without a token it returns one, valid for 30 seconds, and with
+// that token it turns the amps off, stops the streams, saves the config
+// and hands over to systemd.
+func (c *Controller) Power(ctx context.Context, action, confirm string) (models.PowerResponse, *models.AppError) {
+	if action != models.PowerReboot && action != models.PowerShutdown {
+		return models.PowerResponse{}, models.ErrBadRequest("unknown power action " + action)
+	}
+	if confirm == "" {
+		t := c.issueConfirm(action)
+		return models.PowerResponse{Action: action, Status: "confirm", Confirm: t.token, Expires: &t.expires}, nil
+	}
+	if !c.checkConfirm(action, confirm) {
+		return models.PowerResponse{}, models.ErrBadRequest("invalid or expired confirmation token; request a new one").WithField("confirm")
+	}
+
+	slog.Info("power: going down", "action", action)
+	// Playing zones ramp down before their streams stop.
+	if err := c.Shutdown(ctx); err != nil {
+		slog.Warn("power: hardware writes not flushed", "err", err)
+	}
+	if c.streams != nil {
+		if err := c.streams.Shutdown(ctx); err != nil {
+			slog.Warn("power: stream shutdown error", "err", err)
+		}
+	}
+	if err := c.store.Flush(); err != nil {
+		slog.Warn("power: config not flushed", "err", err)
+	}
+	if err := systemPower(ctx, action); err != nil {
+		c.resumeAmps()
+		c.resumeStreams()
+		return models.PowerResponse{}, models.ErrInternal(err.Error())
+	}
+
+	status := "rebooting"
+	if action == models.PowerShutdown {
+		status = "shutting_down"
+	}
+	return models.PowerResponse{Action: action, Status: status}, nil
+}
+
+// resumeStreams restarts the streams stopped for a reboot that failed.
+func (c *Controller) resumeStreams() {
+	if c.streams == nil {
+		return
+	}
+	c.mu.RLock()
+	state := c.state.DeepCopy()
+	c.mu.RUnlock()
+	if err := c.streams.Sync(context.Background(), c.runnableStreams(state.Streams), state.Sources); err != nil {
+		slog.Warn("power: streams not restarted", "err", err)
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"context"
+	"math"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Power estimate model. The figures are typical of a unit's preamp and its
+// class-D zone amps; the estimate is for energy use and supply headroom,
+// not a measurement.
+const (
+	preampWatts      = 3.0   // preamp, controller and fan with the 9V/12V rails on
+	standbyWatts     = 1.5   // with the rails off
+	ampIdleWatts     = 1.2   // an enabled zone amp without signal
+	speakerOhms      = 8.0   // nominal speaker load per channel
+	musicPowerRatio  = 0.125 // average to full-scale sine power of music, ~9 dB crest factor
+	ampEfficiency    = 0.85
+	nominalRailVolts = 24.0 // assumed when a rail voltage reads 0
+)
+
+// estimatePower estimates a unit's power draw from its rail voltages, the
+// amps it has enabled and the volumes of its zones that are playing.
+func (c *Controller) estimatePower(ctx context.Context, u hardware.UnitInfo, p hardware.Power) (*models.PowerEstimate, error) {
+	en, err := c.hw.Read(ctx, u.Index, hardware.RegAmpEn)
+	if err != nil {
+		return nil, err
+	}
+	hv1, err := c.hw.Read(ctx, u.Index, hardware.RegHV1Voltage)
+	if err != nil {
+		return nil, err
+	}
+	est := &models.PowerEstimate{HV1Volts: hardware.VoltageFromReg(hv1)}
+	if p.HV2Present {
+		hv2, err := c.hw.Read(ctx, u.Index, hardware.RegHV2Voltage)
+		if err != nil {
+			return nil, err
+		}
+		est.HV2Volts = hardware.VoltageFromReg(hv2)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	est.Watts = standbyWatts
+	if p.EN9V || p.EN12V {
+		est.Watts = preampWatts
+	}
+	for i := range c.state.Zones {
+		z := &c.state.Zones[i]
+		ch := z.ID - u.ZoneBase
+		if ch < 0 || ch >= u.ZoneCount || en&(1<<ch) == 0 {
+			continue
+		}
+		est.AmpsEnabled++
+		est.Watts += ampIdleWatts
+		if z.Disabled || z.Mute || !zoneFed(&c.state, z) {
+			continue
+		}
+		est.ZonesPlaying++
+		volts := est.HV1Volts
+		if ch >= 3 && est.HV2Volts > 0 {
+			volts = est.HV2Volts
+		}
+		est.Watts += zoneWatts(float64(volts), z.Vol)
+	}
+	est.Watts = math.Round(est.Watts*10) / 10
+	return est, nil
+}
+
+// zoneWatts is the supply power a zone's two channels draw playing music
+// at vol dB: a fraction of the full-scale sine power the rail can drive
+// into the speakers.
+func zoneWatts(railVolts float64, vol int) float64 {
+	if railVolts <= 0 {
+		railVolts = nominalRailVolts
+	}
+	fullScale := railVolts * railVolts / (2 * speakerOhms)
+	return 2 * fullScale * math.Pow(10, float64(vol)/10) * musicPowerRatio / ampEfficiency
+}
+
+// Health reads the live status of every unit, with their total estimated
+// power draw.
+func (c *Controller) Health(ctx context.Context) models.Health {
+	h := models.Health{Time: c.now(), Units: c.GetUnits(ctx)}
+	for _, u := range h.Units {
+		if u.Estimate != nil {
+			h.Watts += u.Estimate.Watts
+		}
+	}
+	h.Watts = math.Round(h.Watts*10) / 10
+	return h
+}
@@ -3,6 +3,8 @@ package controller
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
@@ -30,11 +32,10 @@ func (c *Controller) GetPreset(id int) (*models.Preset, *models.AppError) {
 
 // CreatePreset creates a new preset.
 func (c *Controller) CreatePreset(_ context.Context, req models.PresetCreate) (models.State, *models.AppError) {
-	if req.Name == "" {
-		return models.State{}, models.ErrBadRequest("preset name is required")
-	}
-
 	state, err := c.apply(func(s *models.State) error {
+		if err := checkName("preset", req.Name, presetNames(s, -1)); err != nil {
+			return err
+		}
 		p := models.Preset{
 			ID:       nextPresetID(s),
 			Name:     req.Name,
@@ -61,6 +62,9 @@ func (c *Controller) SetPreset(_ context.Context, id int, upd models.PresetUpdat
 			return models.ErrNotFound(fmt.Sprintf("preset %d not found", id))
 		}
 		if upd.Name != nil {
+			if err := checkName("preset", *upd.Name, presetNames(s, id)); err != nil {
+				return err
+			}
 			p.Name = *upd.Name
 		}
 		if upd.State != nil {
@@ -100,87 +104,201 @@ func (c *Controller) DeletePreset(_ context.Context, id int) (models.State, *mod
 	return state, nil
 }
 
-// LoadPreset applies a preset's state and commands to the system.
+// LoadPreset applies a preset's state to the system and then runs its
+// stream commands.
 func (c *Controller) LoadPreset(ctx context.Context, id int) (models.State, *models.AppError) {
+	state, _, appErr := c.LoadPresetWithReport(ctx, id)
+	return state, appErr
+}
+
+// LoadPresetWithReport loads a preset like LoadPreset and reports which of
+// its updates were applied and which were skipped, e.g. a source whose
+// stream is missing. The report is also emitted as a preset_loaded event.
+func (c *Controller) LoadPresetWithReport(ctx context.Context, id int) (models.State, models.PresetReport, *models.AppError) {
 	// Get the preset to load
 	c.mu.RLock()
 	p := findPreset(&c.state, id)
 	if p == nil {
 		c.mu.RUnlock()
-		return models.State{}, models.ErrNotFound(fmt.Sprintf("preset %d not found", id))
+		return models.State{}, models.PresetReport{}, models.ErrNotFound(fmt.Sprintf("preset %d not found", id))
 	}
 	preset := *p
 	c.mu.RUnlock()
 
+	var report models.PresetReport
 	state, err := c.apply(func(s *models.State) error {
-		if preset.State == nil {
-			return nil
+		report = models.PresetReport{PresetID: preset.ID, Name: preset.Name, Items: []models.PresetItem{}}
+		if preset.State != nil {
+			if err := c.applyPresetState(ctx, s, preset.State, &report); err != nil {
+				return err
+			}
 		}
-		ps := preset.State
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, models.PresetReport{}, appErr
+		}
+		return models.State{}, models.PresetReport{}, models.ErrInternal(err.Error())
+	}
+	// Commands run after the state is applied, outside the lock
+	for i, cmd := range preset.Commands {
+		idx := i
+		reportItem(&report, "command", &idx, c.runPresetCommand(ctx, cmd))
+	}
+	if len(preset.Commands) > 0 {
+		state = c.State()
+	}
+	c.bus.Emit(models.Event{Type: models.EventPresetLoaded, Time: c.now(), Data: report})
+	return state, report, nil
+}
 
-		// Apply source updates
-		for _, upd := range ps.Sources {
-			if upd.ID == nil {
-				continue
-			}
-			src := findSourceInState(s, *upd.ID)
-			if src == nil {
+// applyPresetState applies the source, zone and group updates of a preset
+// to s. Updates for sources, zones and groups that no longer exist, and
+// source inputs whose stream is missing or cannot run, are skipped and
+// noted in report if it is not nil.
+func (c *Controller) applyPresetState(ctx context.Context, s *models.State, ps *models.PresetState, report *models.PresetReport) error {
+	// Apply source updates
+	for _, upd := range ps.Sources {
+		if upd.ID == nil {
+			reportItem(report, "source", nil, "no source id")
+			continue
+		}
+		src := findSourceInState(s, *upd.ID)
+		if src == nil {
+			reportItem(report, "source", upd.ID, fmt.Sprintf("source %d does not exist", *upd.ID))
+			continue
+		}
+		if upd.Input != nil {
+			if reason := inputUnavailable(s, *upd.Input); reason != "" {
+				reportItem(report, "source", upd.ID, reason)
 				continue
 			}
-			if upd.Name != nil {
-				src.Name = *upd.Name
-			}
-			if upd.Input != nil {
-				src.Input = *upd.Input
-			}
 		}
+		if upd.Name != nil {
+			src.Name = *upd.Name
+		}
+		if upd.Input != nil {
+			src.Input = *upd.Input
+		}
+		reportItem(report, "source", upd.ID, "")
+	}
 
-		// Apply zone updates
-		for _, upd := range ps.Zones {
-			if upd.ID == nil {
-				continue
-			}
-			z := findZone(s, *upd.ID)
-			if z == nil {
+	// Apply zone updates
+	for _, upd := range ps.Zones {
+		if upd.ID == nil {
+			reportItem(report, "zone", nil, "no zone id")
+			continue
+		}
+		z := findZone(s, *upd.ID)
+		if z == nil {
+			reportItem(report, "zone", upd.ID, fmt.Sprintf("zone %d does not exist", *upd.ID))
+			continue
+		}
+		if upd.SourceID != nil && *upd.SourceID != z.SourceID && sourceLocked(ctx, z) {
+			reportItem(report, "zone", upd.ID, fmt.Sprintf("zone %d is locked", *upd.ID))
+			continue
+		}
+		if upd.Name != nil {
+			if appErr := checkName("zone", *upd.Name, zoneNames(s, z.ID)); appErr != nil {
+				reportItem(report, "zone", upd.ID, appErr.Message)
 				continue
 			}
-			if err := applyZoneUpdate(ctx, c, s, z, upd); err != nil {
-				return err
-			}
 		}
+		if err := applyZoneUpdate(ctx, c, s, z, upd); err != nil {
+			return err
+		}
+		reportItem(report, "zone", upd.ID, "")
+	}
 
-		// Apply group updates
-		for _, upd := range ps.Groups {
-			if upd.ID == nil {
-				continue
-			}
-			g := findGroup(s, *upd.ID)
-			if g == nil {
+	// Apply group updates
+	for _, upd := range ps.Groups {
+		if upd.ID == nil {
+			reportItem(report, "group", nil, "no group id")
+			continue
+		}
+		g := findGroup(s, *upd.ID)
+		if g == nil {
+			reportItem(report, "group", upd.ID, fmt.Sprintf("group %d does not exist", *upd.ID))
+			continue
+		}
+		if upd.Name != nil {
+			if appErr := checkName("group", *upd.Name, groupNames(s, g.ID)); appErr != nil {
+				reportItem(report, "group", upd.ID, appErr.Message)
 				continue
 			}
-			if upd.Name != nil {
-				g.Name = *upd.Name
-			}
-			if upd.SourceID != nil {
-				v := *upd.SourceID
-				g.SourceID = &v
-			}
-			if upd.Mute != nil {
-				v := *upd.Mute
-				g.Mute = &v
-			}
+			g.Name = *upd.Name
+		}
+		if upd.SourceID != nil {
+			v := *upd.SourceID
+			g.SourceID = &v
+		}
+		if upd.Mute != nil {
+			v := *upd.Mute
+			g.Mute = &v
 		}
+		reportItem(report, "group", upd.ID, "")
+	}
+	return nil
+}
 
-		// TODO Phase 3: execute preset Commands via stream subsystem
-		return nil
-	})
+// runPresetCommand runs a preset command and returns why it failed, or ""
+// if it ran. Only stream commands, POST /api/streams/{sid}/{cmd}, are
+// supported.
+func (c *Controller) runPresetCommand(ctx context.Context, cmd models.Command) string {
+	rest, ok := strings.CutPrefix(cmd.Endpoint, "/api/streams/")
+	sid, name, found := strings.Cut(rest, "/")
+	id, err := strconv.Atoi(sid)
+	if !ok || !found || err != nil || name == "" || !strings.EqualFold(cmd.Method, "POST") {
+		return fmt.Sprintf("unsupported command %s %s", cmd.Method, cmd.Endpoint)
+	}
+	if _, appErr := c.ExecStreamCommand(ctx, id, name); appErr != nil {
+		return appErr.Message
+	}
+	return ""
+}
+
+// inputUnavailable returns why a source cannot be switched to input, or
+// "" if it can.
+func inputUnavailable(s *models.State, input string) string {
+	id, ok := strings.CutPrefix(input, "stream=")
+	if !ok {
+		return ""
+	}
+	sid, err := strconv.Atoi(id)
 	if err != nil {
-		if appErr, ok := err.(*models.AppError); ok {
-			return models.State{}, appErr
-		}
-		return models.State{}, models.ErrInternal(err.Error())
+		return fmt.Sprintf("invalid input %q", input)
 	}
-	return state, nil
+	st := findStream(s, sid)
+	switch {
+	case st == nil:
+		return fmt.Sprintf("stream %d does not exist", sid)
+	case st.Disabled != nil && *st.Disabled:
+		return fmt.Sprintf("stream %d (%s) is disabled", sid, st.Name)
+	case st.Info.State == "unavailable":
+		return fmt.Sprintf("stream %d (%s) is unavailable: %s", sid, st.Name, st.Info.Reason)
+	}
+	return ""
+}
+
+// reportItem adds an item to report, applied if reason is empty and
+// skipped otherwise. A nil report is ignored.
+func reportItem(report *models.PresetReport, kind string, id *int, reason string) {
+	if report == nil {
+		return
+	}
+	item := models.PresetItem{Kind: kind, Status: models.PresetItemApplied, Reason: reason}
+	if id != nil {
+		v := *id
+		item.ID = &v
+	}
+	if reason != "" {
+		item.Status = models.PresetItemSkipped
+		report.Skipped++
+	} else {
+		report.Applied++
+	}
+	report.Items = append(report.Items, item)
 }
 
 func findSourceInState(s *models.State, id int) *models.Source {
@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// SetProvisionTemplate makes FactoryReset reapply p after restoring the
+// defaults, so a reset unit comes back as the installer provisioned it.
+func (c *Controller) SetProvisionTemplate(p *models.Provision) {
+	c.mu.Lock()
+	c.provision = p
+	c.mu.Unlock()
+}
+
+// Provision applies an installer's template: sources, then zones, then
+// groups, then streams. Each item goes through the same validation as the
+// API; the first that fails stops provisioning and is named in the
+// error's field, e.g. "zones[2].vol_min".
+func (c *Controller) Provision(ctx context.Context, p models.Provision) (models.State, *models.AppError) {
+	for i, upd := range p.Sources {
+		if upd.ID == nil {
+			return models.State{}, provisionError("sources", i, models.ErrBadRequest("source id is required").WithField("id"))
+		}
+		if _, appErr := c.SetSource(ctx, *upd.ID, upd); appErr != nil {
+			return models.State{}, provisionError("sources", i, appErr)
+		}
+	}
+	for i, upd := range p.Zones {
+		if upd.ID == nil {
+			return models.State{}, provisionError("zones", i, models.ErrBadRequest("zone id is required").WithField("id"))
+		}
+		if _, appErr := c.SetZone(ctx, *upd.ID, upd); appErr != nil {
+			return models.State{}, provisionError("zones", i, appErr)
+		}
+	}
+	for i, upd := range p.Groups {
+		if upd.Name == nil {
+			return models.State{}, provisionError("groups", i, models.ErrBadRequest("group name is required").WithField("name"))
+		}
+		var appErr *models.AppError
+		if id, ok := c.groupNamed(*upd.Name); ok {
+			_, appErr = c.SetGroup(ctx, id, upd)
+		} else {
+			_, appErr = c.CreateGroup(ctx, upd)
+		}
+		if appErr != nil {
+			return models.State{}, provisionError("groups", i, appErr)
+		}
+	}
+	for i, req := range p.Streams {
+		var appErr *models.AppError
+		if st, ok := c.streamNamed(req.Name); !ok {
+			_, appErr = c.CreateStream(ctx, req)
+		} else if st.Type != req.Type {
+			appErr = models.ErrConflict(fmt.Sprintf("stream %q exists with type %q", req.Name, st.Type)).WithField("type")
+		} else if req.Config != nil {
+			_, appErr = c.SetStream(ctx, st.ID, models.StreamUpdate{Config: req.Config})
+		}
+		if appErr != nil {
+			return models.State{}, provisionError("streams", i, appErr)
+		}
+	}
+	return c.State(), nil
+}
+
+// groupNamed returns the ID of the group called name.
+func (c *Controller) groupNamed(name string) (int, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, g := range c.state.Groups {
+		if g.Name == name {
+			return g.ID, true
+		}
+	}
+	return 0, false
+}
+
+// streamNamed returns the stream called name.
+func (c *Controller) streamNamed(name string) (models.Stream, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	for _, st := range c.state.Streams {
+		if st.Name == name {
+			return st, true
+		}
+	}
+	return models.Stream{}, false
+}
+
+// provisionError places err at item i of a template's list.
+func provisionError(list string, i int, err *models.AppError) *models.AppError {
+	field := fmt.Sprintf("%s[%d]", list, i)
+	if err.Field != "" {
+		field += "." + err.Field
+	}
+	return &models.AppError{
+		Code:    err.Code,
+		Message: field + ": " + err.Message,
+		Field:   field,
+		Status:  err.Status,
+	}
+}
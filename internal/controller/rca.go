@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// An RCA input's signal must be seen, or be gone, for this long before it
+// counts, so a quiet passage or the gap between tracks doesn't flap
+// auto-switch. Losing the signal waits longer than gaining it.
+const (
+	rcaSignalOnHold  = 2 * time.Second
+	rcaSignalOffHold = 10 * time.Second
+)
+
+// rcaState is the last debounced RCA signal reading and the inputs that
+// auto-switch replaced, so they can be restored when the signal goes away.
+type rcaState struct {
+	known   bool
+	signal  [4]bool
+	pending [4]time.Time   // when a reading differing from signal was first seen
+	prev    map[int]string // source ID -> input before auto-switching to RCA
+}
+
+// debounce returns the signal once each input's change has held for its
+// hold time. The first reading is taken as is.
+func (r *rcaState) debounce(raw [4]bool, now time.Time) [4]bool {
+	if !r.known {
+		return raw
+	}
+	sig := r.signal
+	for i := range raw {
+		if raw[i] == r.signal[i] {
+			r.pending[i] = time.Time{}
+			continue
+		}
+		if r.pending[i].IsZero() {
+			r.pending[i] = now
+		}
+		hold := rcaSignalOffHold
+		if raw[i] {
+			hold = rcaSignalOnHold
+		}
+		if now.Sub(r.pending[i]) >= hold {
+			sig[i] = raw[i]
+			r.pending[i] = time.Time{}
+		}
+	}
+	return sig
+}
+
+// RunInputDetection polls the RCA signal detectors of a Rev4+ main unit
+// every interval, publishing each RCA stream's "active" flag and applying
+// auto-switch rules. Returns at once on hardware without detectors; else
+// blocks until ctx is cancelled.
+func (c *Controller) RunInputDetection(ctx context.Context, interval time.Duration) {
+	unit, ok := c.signalUnit()
+	if !ok {
+		slog.Debug("no RCA signal detection on this hardware")
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if sig, err := hardware.ReadInputSignal(ctx, c.hw, unit); err != nil {
+			slog.Debug("RCA signal read failed", "unit", unit, "err", err)
+		} else {
+			c.updateInputSignal(sig)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// signalUnit returns the main unit with RCA signal detectors.
+func (c *Controller) signalUnit() (int, bool) {
+	if c.profile == nil {
+		return 0, false
+	}
+	for _, u := range c.profile.Units {
+		if u.HasAnalog && u.Rev4Plus {
+			return u.Index, true
+		}
+	}
+	return 0, false
+}
+
+// updateInputSignal records a signal reading. When an RCA input with
+// "auto_switch" in its stream config gains a signal, its source is switched
+// to the RCA stream; when the signal goes away the previous input returns,
+// unless the source has been changed since. Changes only count once they
+// have held for rcaSignalOnHold or rcaSignalOffHold.
+func (c *Controller) updateInputSignal(raw [4]bool) {
+	c.rcaMu.Lock()
+	defer c.rcaMu.Unlock()
+	sig := c.rca.debounce(raw, c.now())
+	if c.rca.known && c.rca.signal == sig {
+		return
+	}
+	was := c.rca.signal
+	_, _ = c.apply(func(s *models.State) error {
+		switched := false
+		for i := range s.Streams {
+			st := &s.Streams[i]
+			idx := st.ID - models.RCAStreamBaseID
+			if st.Type != models.StreamTypeRCA || idx < 0 || idx >= len(sig) {
+				continue
+			}
+			active := sig[idx]
+			st.Active = &active
+			if active == was[idx] || !autoSwitch(st) {
+				continue
+			}
+			src := findSourceInState(s, idx)
+			if src == nil {
+				continue
+			}
+			rcaInput := fmt.Sprintf("stream=%d", st.ID)
+			prev, hadPrev := c.rca.prev[idx]
+			delete(c.rca.prev, idx)
+			switch {
+			case active && src.Input != rcaInput:
+				slog.Info("RCA signal detected, switching source", "source", idx, "stream", st.Name, "from", src.Input)
+				c.rca.prev[idx] = src.Input
+				src.Input = rcaInput
+				switched = true
+			case !active && src.Input == rcaInput && hadPrev:
+				slog.Info("RCA signal lost, restoring source", "source", idx, "input", prev)
+				src.Input = prev
+				switched = true
+			}
+		}
+		if switched {
+			c.updateSourceTypeHW(s)
+		}
+		return nil
+	})
+	c.rca.known, c.rca.signal = true, sig
+}
+
+// autoSwitch reports whether an RCA stream has auto-switch enabled.
+func autoSwitch(st *models.Stream) bool {
+	on, _ := st.Config["auto_switch"].(bool)
+	return on
+}
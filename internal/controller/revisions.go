@@ -0,0 +1,105 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// maxRevisions is how many changes can be undone.
+const maxRevisions = 20
+
+// revision is a recorded change and the sources, zones and groups from
+// before it.
+type revision struct {
+	models.Revision
+	before models.PresetState
+}
+
+// recordRevision remembers the routing and volumes of prev if next
+// changed them, so the change can be undone. Changes made by Undo are not
+// recorded. Must be called with mu held.
+func (c *Controller) recordRevision(prev, next *models.State) {
+	if c.undoing {
+		c.undoing = false
+		return
+	}
+	before, after := captureState(prev), captureState(next)
+	changes := changedIDs("source", before.Sources, after.Sources, func(u models.SourceUpdate) int { return *u.ID })
+	changes = append(changes, changedIDs("zone", before.Zones, after.Zones, func(u models.ZoneUpdate) int { return *u.ID })...)
+	changes = append(changes, changedIDs("group", before.Groups, after.Groups, func(u models.GroupUpdate) int { return *u.ID })...)
+	if len(changes) == 0 {
+		return
+	}
+	c.revID++
+	c.revisions = append(c.revisions, revision{
+		Revision: models.Revision{ID: c.revID, Time: c.now(), Changes: changes},
+		before:   before,
+	})
+	if n := len(c.revisions) - maxRevisions; n > 0 {
+		c.revisions = append([]revision(nil), c.revisions[n:]...)
+	}
+}
+
+// changedIDs lists the items of a kind that differ between before and
+// after, or are in only one of them, as "kind ID".
+func changedIDs[T any](kind string, before, after []T, id func(T) int) []string {
+	old := make(map[int]T, len(before))
+	for _, u := range before {
+		old[id(u)] = u
+	}
+	var changes []string
+	for _, u := range after {
+		if o, ok := old[id(u)]; !ok || !reflect.DeepEqual(o, u) {
+			changes = append(changes, fmt.Sprintf("%s %d", kind, id(u)))
+		}
+		delete(old, id(u))
+	}
+	for _, u := range before {
+		if _, ok := old[id(u)]; ok {
+			changes = append(changes, fmt.Sprintf("%s %d", kind, id(u)))
+		}
+	}
+	return changes
+}
+
+// GetRevisions lists the changes that can be undone, oldest first.
+func (c *Controller) GetRevisions() []models.Revision {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	list := make([]models.Revision, 0, len(c.revisions))
+	for _, rev := range c.revisions {
+		list = append(list, rev.Revision)
+	}
+	return list
+}
+
+// Undo reverts the most recent recorded change and writes the result to
+// the hardware. Calling it again reverts the change before that. Sources,
+// zones and groups deleted since are skipped; created ones are kept.
+func (c *Controller) Undo(ctx context.Context) (models.State, models.Revision, *models.AppError) {
+	var undone models.Revision
+	state, err := c.apply(func(s *models.State) error {
+		n := len(c.revisions)
+		if n == 0 {
+			return models.ErrConflict("nothing to undo")
+		}
+		rev := c.revisions[n-1]
+		if err := c.applyPresetState(ctx, s, &rev.before, nil); err != nil {
+			return err
+		}
+		c.revisions = c.revisions[:n-1]
+		c.undoing = true
+		undone = rev.Revision
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, models.Revision{}, appErr
+		}
+		return models.State{}, models.Revision{}, models.ErrInternal(err.Error())
+	}
+	return state, undone, nil
+}
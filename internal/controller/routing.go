@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
+)
+
+// AudioRouting reports the audio path of every source as the stream
+// manager runs it and checks it against the state, so "no sound on
+// source 2" can be traced to the stream, vsrc or output at fault. Nothing
+// is changed.
+func (c *Controller) AudioRouting() models.AudioRouting {
+	state := c.State()
+	c.mu.RLock()
+	outputs := c.outputs
+	c.mu.RUnlock()
+
+	r := models.AudioRouting{
+		Sources:  make([]models.SourceRoute, 0, len(state.Sources)),
+		Streams:  []models.StreamRoute{},
+		Problems: []string{},
+	}
+	if c.streams == nil {
+		r.Problems = append(r.Problems, "streams are disabled")
+	} else {
+		r.Streams = c.streams.Routes()
+		r.VSRCs = c.streams.VSRCs()
+	}
+	for _, rt := range r.Streams {
+		if rt.VSRC >= 0 {
+			r.VSRCsUsed++
+		}
+	}
+	cards := make(map[string]string)
+	present := make(map[string]bool)
+	if outputs != nil {
+		for _, out := range outputs.List() {
+			cards[out.PCM], present[out.PCM] = out.Card, out.Present
+		}
+	}
+
+	for _, src := range state.Sources {
+		sr := models.SourceRoute{
+			ID:     src.ID,
+			Input:  src.Input,
+			Analog: isAnalogInput(src.Input, &state),
+			Zones:  slices.Clone(src.Zones),
+		}
+		if sr.Zones == nil {
+			sr.Zones = []int{}
+		}
+		if id, ok := inputStreamID(src.Input); ok {
+			sr.StreamID = &id
+		}
+		for _, rt := range r.Streams {
+			if rt.Source != src.ID || rt.VSRC < 0 {
+				continue
+			}
+			vsrc := rt.VSRC
+			sr.VSRC = &vsrc
+			sr.Device, sr.Capture, sr.Output = rt.Device, rt.Capture, rt.Output
+			sr.Card, sr.Present = cards[rt.Output], present[rt.Output]
+		}
+		r.Sources = append(r.Sources, sr)
+		if c.streams != nil {
+			r.Problems = append(r.Problems, sourceRoutingProblems(&state, src, sr, &r)...)
+		}
+	}
+	r.Consistent = len(r.Problems) == 0
+	return r
+}
+
+// sourceRoutingProblems returns why source src, whose path is sr, would
+// not play the stream its input selects.
+func sourceRoutingProblems(s *models.State, src models.Source, sr models.SourceRoute, r *models.AudioRouting) []string {
+	var problems []string
+	for _, rt := range r.Streams {
+		if rt.Source == src.ID && (sr.StreamID == nil || rt.ID != *sr.StreamID) {
+			problems = append(problems, fmt.Sprintf("source %d: stream %d (%s) is connected to it but its input is %q", src.ID, rt.ID, rt.Name, src.Input))
+		}
+	}
+	if sr.StreamID == nil {
+		return problems
+	}
+	if reason := inputUnavailable(s, src.Input); reason != "" {
+		return append(problems, fmt.Sprintf("source %d: %s", src.ID, reason))
+	}
+	id := *sr.StreamID
+	i := slices.IndexFunc(r.Streams, func(rt models.StreamRoute) bool { return rt.ID == id })
+	if i < 0 {
+		return append(problems, fmt.Sprintf("source %d: stream %d isn't running", src.ID, id))
+	}
+	rt := r.Streams[i]
+	switch {
+	case !rt.Active && r.VSRCs > 0 && r.VSRCsUsed >= r.VSRCs:
+		problems = append(problems, fmt.Sprintf("source %d: stream %d (%s) isn't active: all %d vsrcs are in use", src.ID, id, rt.Name, r.VSRCs))
+	case !rt.Active:
+		problems = append(problems, fmt.Sprintf("source %d: stream %d (%s) isn't active", src.ID, id, rt.Name))
+	case rt.Source < 0:
+		problems = append(problems, fmt.Sprintf("source %d: stream %d (%s) isn't connected to it", src.ID, id, rt.Name))
+	case rt.Source != src.ID:
+		problems = append(problems, fmt.Sprintf("source %d: stream %d (%s) is connected to source %d instead", src.ID, id, rt.Name, rt.Source))
+	case rt.Output != "" && rt.Output != streams.PhysicalOutputDevice(src.ID):
+		problems = append(problems, fmt.Sprintf("source %d: output %s isn't present, stream %d (%s) plays to %s", src.ID, streams.PhysicalOutputDevice(src.ID), id, rt.Name, rt.Output))
+	}
+	return problems
+}
+
+// inputStreamID returns the stream a source input selects.
+func inputStreamID(input string) (int, bool) {
+	s, ok := strings.CutPrefix(input, "stream=")
+	if !ok {
+		return 0, false
+	}
+	id, err := strconv.Atoi(s)
+	return id, err == nil
+}
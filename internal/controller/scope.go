@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// scopeZones returns the zones sc lets a key control: its zones and those
+// of its groups.
+func scopeZones(s *models.State, sc *auth.Scope) []int {
+	zones := slices.Clone(sc.Zones)
+	for _, gid := range sc.Groups {
+		if g := findGroup(s, gid); g != nil {
+			zones = append(zones, groupZones(s, g)...)
+		}
+	}
+	return zones
+}
+
+// checkZoneScope returns 403 unless the request in ctx may control every
+// zone in ids.
+func checkZoneScope(ctx context.Context, s *models.State, ids ...int) *models.AppError {
+	sc := auth.ScopeFrom(ctx)
+	if sc == nil {
+		return nil
+	}
+	allowed := scopeZones(s, sc)
+	for _, id := range ids {
+		if !slices.Contains(allowed, id) {
+			return models.ErrForbidden(fmt.Sprintf("this key may not control zone %d", id))
+		}
+	}
+	return nil
+}
+
+// checkZoneUpdateScope returns 403 if the request in ctx is restricted to
+// a scope and upd changes more than volume, mute and source.
+func checkZoneUpdateScope(ctx context.Context, upd models.ZoneUpdate) *models.AppError {
+	if auth.ScopeFrom(ctx) == nil {
+		return nil
+	}
+	rest := upd
+	rest.ID, rest.SourceID, rest.Mute = nil, nil, nil
+	rest.Vol, rest.VolF, rest.VolDelta, rest.VolDeltaF = nil, nil, nil, nil
+	if rest != (models.ZoneUpdate{}) {
+		return models.ErrForbidden("this key may only change a zone's volume, mute and source")
+	}
+	return nil
+}
+
+// checkGroupScope returns 403 unless the request in ctx may control group
+// id: one of its groups, or a group of its zones only.
+func checkGroupScope(ctx context.Context, s *models.State, id int) *models.AppError {
+	sc := auth.ScopeFrom(ctx)
+	if sc == nil || slices.Contains(sc.Groups, id) {
+		return nil
+	}
+	if g := findGroup(s, id); g != nil {
+		zones := groupZones(s, g)
+		if len(zones) > 0 && !slices.ContainsFunc(zones, func(z int) bool { return !slices.Contains(sc.Zones, z) }) {
+			return nil
+		}
+	}
+	return models.ErrForbidden(fmt.Sprintf("this key may not control group %d", id))
+}
+
+// checkStreamScope returns 403 unless the request in ctx may control
+// stream id: the stream plays on a source one of its zones is playing.
+func checkStreamScope(ctx context.Context, s *models.State, id int) *models.AppError {
+	sc := auth.ScopeFrom(ctx)
+	if sc == nil {
+		return nil
+	}
+	for _, zid := range scopeZones(s, sc) {
+		if z := findZone(s, zid); z != nil {
+			if st := connectedStream(s, z.SourceID); st != nil && st.ID == id {
+				return nil
+			}
+		}
+	}
+	return models.ErrForbidden(fmt.Sprintf("this key may not control stream %d; it is not playing in its zones", id))
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// selfTestTimeout bounds the whole self-test.
+const selfTestTimeout = 30 * time.Second
+
+// RunSelfTest checks what support would check first on a misbehaving unit:
+// every preamp unit answers on I2C, its temperatures and power rails read
+// back sane, the ALSA loopback cards exist and the configured streams'
+// binaries are installed. The result is kept for GetInfo; failures are
+// logged.
+func (c *Controller) RunSelfTest(ctx context.Context) models.SelfTest {
+	ctx, cancel := context.WithTimeout(ctx, selfTestTimeout)
+	defer cancel()
+
+	start := c.now()
+	var checks []models.SelfTestCheck
+	checks = append(checks, c.selfTestUnits(ctx)...)
+	checks = append(checks, c.selfTestLoopbacks()...)
+	checks = append(checks, c.selfTestStreams()...)
+
+	res := models.SelfTest{Time: start, Duration: c.now().Sub(start).Seconds(), Checks: checks}
+	for _, ch := range checks {
+		if ch.Result == models.SelfTestFail {
+			res.Failed++
+			slog.Warn("self-test failed", "check", ch.Check, "target", ch.Target, "detail", ch.Detail)
+		}
+	}
+	res.Passed = res.Failed == 0
+	slog.Info("self-test done", "checks", len(checks), "failed", res.Failed)
+
+	c.mu.Lock()
+	c.selfTest = &res
+	c.mu.Unlock()
+	return res
+}
+
+// SelfTest returns the result of the last self-test, or nil if none ran.
+func (c *Controller) SelfTest() *models.SelfTest {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.selfTest
+}
+
+// selfTestUnits probes each preamp unit on I2C and reads its temperatures
+// and power rails.
+func (c *Controller) selfTestUnits(ctx context.Context) []models.SelfTestCheck {
+	var checks []models.SelfTestCheck
+	for _, u := range c.detectedUnits() {
+		target := fmt.Sprintf("unit %d", u.Index)
+		v, err := c.hw.ReadVersion(ctx, u.Index)
+		if err != nil {
+			// Nothing more can be read from a unit that doesn't answer.
+			checks = append(checks, selfTestFailed("i2c", target, err.Error()))
+			continue
+		}
+		checks = append(checks, selfTestPassed("i2c", target, "firmware "+v.String()))
+		checks = append(checks, c.selfTestTemps(ctx, u.Index, target))
+		if u.ZoneCount > 0 {
+			checks = append(checks, c.selfTestPower(ctx, u.Index, target))
+		}
+	}
+	return checks
+}
+
+// selfTestTemps reads a unit's temperatures and its over-temperature flag.
+func (c *Controller) selfTestTemps(ctx context.Context, unit int, target string) models.SelfTestCheck {
+	t, err := c.hw.ReadTemps(ctx, unit)
+	if err != nil {
+		return selfTestFailed("temperature", target, err.Error())
+	}
+	hottest := fmt.Sprintf("hottest %.1f°C", max(t.Amp1C, t.Amp2C, t.PSU1C, t.PSU2C))
+	if f, err := c.hw.ReadFanStatus(ctx, unit); err == nil && f.OvrTmp {
+		return selfTestFailed("temperature", target, "over temperature, "+hottest)
+	}
+	return selfTestPassed("temperature", target, hottest)
+}
+
+// selfTestPower checks that a unit's enabled rails are good.
+func (c *Controller) selfTestPower(ctx context.Context, unit int, target string) models.SelfTestCheck {
+	p, err := c.hw.ReadPower(ctx, unit)
+	if err != nil {
+		return selfTestFailed("power", target, err.Error())
+	}
+	var bad []string
+	for _, rail := range []struct {
+		name          string
+		enabled, good bool
+	}{
+		{"5V digital", true, p.PG5VD},
+		{"5V analog", true, p.PG5VA},
+		{"9V", p.EN9V, p.PG9V},
+		{"12V", p.EN12V, p.PG12V},
+	} {
+		if rail.enabled && !rail.good {
+			bad = append(bad, rail.name)
+		}
+	}
+	if len(bad) > 0 {
+		return selfTestFailed("power", target, "rails not good: "+strings.Join(bad, ", "))
+	}
+	return selfTestPassed("power", target, "rails good")
+}
+
+// selfTestLoopbacks checks that the audio layout's snd-aloop cards are
+// registered. Mock hardware has no ALSA cards to check.
+func (c *Controller) selfTestLoopbacks() []models.SelfTestCheck {
+	outputs, appErr := c.audioOutputs()
+	switch {
+	case !c.hw.IsReal():
+		return []models.SelfTestCheck{{Check: "loopback", Target: "ALSA", Result: models.SelfTestSkip, Detail: "mock hardware"}}
+	case appErr != nil:
+		return []models.SelfTestCheck{{Check: "loopback", Target: "ALSA", Result: models.SelfTestSkip, Detail: appErr.Message}}
+	}
+	cards, err := audio.ReadCards()
+	if err != nil {
+		return []models.SelfTestCheck{selfTestFailed("loopback", "ALSA", err.Error())}
+	}
+	var checks []models.SelfTestCheck
+	for _, lb := range outputs.Layout().Loopbacks {
+		if slices.Contains(cards, lb) {
+			checks = append(checks, selfTestPassed("loopback", lb, "registered"))
+		} else {
+			checks = append(checks, selfTestFailed("loopback", lb, "card not found (is snd-aloop loaded?)"))
+		}
+	}
+	return checks
+}
+
+// selfTestStreams checks that the binaries of each configured stream type
+// are installed now, not just when the profile was detected. Mock hardware
+// goes by its profile, which reports every type available.
+func (c *Controller) selfTestStreams() []models.SelfTestCheck {
+	caps := hardware.DetectStreamCapabilities()
+	if !c.hw.IsReal() && c.profile != nil {
+		caps = c.profile.StreamCapabilities()
+	}
+	var checks []models.SelfTestCheck
+	var seen []string
+	for _, st := range c.State().Streams {
+		schema := models.FindStreamSchema(st.Type)
+		if schema == nil || slices.Contains(seen, schema.Type) {
+			continue
+		}
+		seen = append(seen, schema.Type)
+		if c.streamDisabled(schema) {
+			checks = append(checks, models.SelfTestCheck{Check: "stream", Target: schema.Type, Result: models.SelfTestSkip, Detail: overrideReason})
+			continue
+		}
+		check := selfTestFailed("stream", schema.Type, "not supported on this hardware")
+		for _, sc := range caps {
+			if sc.Type != schema.Type && !slices.Contains(schema.Aliases, sc.Type) {
+				continue
+			}
+			if sc.Available {
+				check = selfTestPassed("stream", schema.Type, sc.Binary)
+				break
+			}
+			check.Detail = sc.Reason
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+func selfTestPassed(check, target, detail string) models.SelfTestCheck {
+	return models.SelfTestCheck{Check: check, Target: target, Result: models.SelfTestPass, Detail: detail}
+}
+
+func selfTestFailed(check, target, detail string) models.SelfTestCheck {
+	return models.SelfTestCheck{Check: check, Target: target, Result: models.SelfTestFail, Detail: detail}
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// GetSettings returns the system settings.
+func (c *Controller) GetSettings() models.Settings {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.state.DeepCopy().Settings
+}
+
+// SetSettings updates the system settings and returns them.
+func (c *Controller) SetSettings(ctx context.Context, upd models.SettingsUpdate) (models.Settings, *models.AppError) {
+	if upd.SourceIdle != nil {
+		if err := c.validateSourceIdle(upd.SourceIdle); err != nil {
+			return models.Settings{}, models.ErrBadRequest(err.Error())
+		}
+	}
+	if upd.LEDs != nil {
+		if err := upd.LEDs.Validate(); err != nil {
+			return models.Settings{}, models.ErrBadRequest(err.Error()).WithField("leds")
+		}
+	}
+	if upd.UnitPower != nil && upd.UnitPower.IdleMinutes < 0 {
+		return models.Settings{}, models.ErrBadRequest("unit_power: idle_minutes must not be negative").WithField("unit_power")
+	}
+	if upd.SilenceWatchdog != nil && upd.SilenceWatchdog.Minutes < 0 {
+		return models.Settings{}, models.ErrBadRequest("silence_watchdog: minutes must not be negative").WithField("silence_watchdog")
+	}
+	for _, d := range []struct {
+		field string
+		ms    *int
+	}{{"crossfade_ms", upd.CrossfadeMS}, {"soft_start_ms", upd.SoftStartMS}} {
+		if d.ms != nil && (*d.ms < 0 || *d.ms > models.MaxCrossfadeMS) {
+			return models.Settings{}, models.ErrBadRequest(fmt.Sprintf("%s must be 0-%d", d.field, models.MaxCrossfadeMS)).WithField(d.field)
+		}
+	}
+	if u := upd.VolumeUnits; u != nil && *u != "" && *u != models.VolumeUnitsDB && *u != models.VolumeUnitsPercent {
+		return models.Settings{}, models.ErrBadRequest(fmt.Sprintf("volume_units must be %q or %q", models.VolumeUnitsDB, models.VolumeUnitsPercent)).WithField("volume_units")
+	}
+	state, err := c.apply(func(s *models.State) error {
+		if upd.VolumeUnits != nil {
+			s.Settings.VolumeUnits = *upd.VolumeUnits
+		}
+		if upd.CrossfadeMS != nil {
+			s.Settings.CrossfadeMS = *upd.CrossfadeMS
+		}
+		if upd.SoftStartMS != nil {
+			s.Settings.SoftStartMS = *upd.SoftStartMS
+		}
+		if upd.LEDs != nil {
+			s.Settings.LEDs = *upd.LEDs
+		}
+		if upd.UnitPower != nil {
+			s.Settings.UnitPower = *upd.UnitPower
+		}
+		if upd.SilenceWatchdog != nil {
+			s.Settings.SilenceWatchdog = *upd.SilenceWatchdog
+		}
+		if upd.Keypad != nil {
+			if err := validateKeypad(s, upd.Keypad); err != nil {
+				return models.ErrBadRequest(err.Error())
+			}
+			s.Settings.Keypad = *upd.Keypad
+		}
+		if upd.SourceIdle != nil {
+			s.Settings.SourceIdle = nil
+			for _, p := range upd.SourceIdle {
+				if p.Minutes > 0 {
+					s.Settings.SourceIdle = append(s.Settings.SourceIdle, p)
+				}
+			}
+		}
+		if upd.Bridge != nil {
+			s.Settings.Bridge = *upd.Bridge
+		}
+		return nil
+	})
+	if appErr, ok := err.(*models.AppError); ok {
+		return models.Settings{}, appErr
+	}
+	if err != nil {
+		return models.Settings{}, models.ErrInternal(err.Error())
+	}
+	return state.Settings, nil
+}
+
+// validateSourceIdle checks that idle policies name existing sources, once
+// each, with a non-negative timeout.
+func (c *Controller) validateSourceIdle(policies []models.SourceIdlePolicy) error {
+	seen := make(map[int]bool)
+	for _, p := range policies {
+		if p.SourceID < 0 || p.SourceID >= c.sourceLimit() {
+			return fmt.Errorf("source_idle: source %d does not exist", p.SourceID)
+		}
+		if seen[p.SourceID] {
+			return fmt.Errorf("source_idle: source %d listed twice", p.SourceID)
+		}
+		seen[p.SourceID] = true
+		if p.Minutes < 0 {
+			return fmt.Errorf("source_idle: minutes must not be negative")
+		}
+	}
+	return nil
+}
+
+// validateKeypad checks keypad mappings against the zones, groups and
+// sources of s.
+func validateKeypad(s *models.State, k *models.KeypadSettings) error {
+	if k.Baud < 0 {
+		return fmt.Errorf("keypad: baud must not be negative")
+	}
+	seen := make(map[string]bool)
+	for _, m := range k.Mappings {
+		if err := m.Validate(); err != nil {
+			return err
+		}
+		if seen[m.Message] {
+			return fmt.Errorf("keypad: message %q mapped twice", m.Message)
+		}
+		seen[m.Message] = true
+		if m.ZoneID != nil && findZone(s, *m.ZoneID) == nil {
+			return fmt.Errorf("keypad mapping %q: zone %d not found", m.Message, *m.ZoneID)
+		}
+		if m.GroupID != nil && findGroup(s, *m.GroupID) == nil {
+			return fmt.Errorf("keypad mapping %q: group %d not found", m.Message, *m.GroupID)
+		}
+		if m.Action == models.KeypadSource && findSourceInState(s, m.SourceID()) == nil {
+			return fmt.Errorf("keypad mapping %q: source %v not found", m.Message, *m.Value)
+		}
+	}
+	return nil
+}
+
+// pruneKeypad drops the keypad mappings of s whose zone, group or source no
+// longer exists, after one is deleted.
+func pruneKeypad(s *models.State) {
+	k := &s.Settings.Keypad
+	k.Mappings = slices.DeleteFunc(k.Mappings, func(m models.KeypadMapping) bool {
+		err := validateKeypad(s, &models.KeypadSettings{Mappings: []models.KeypadMapping{m}})
+		if err != nil {
+			slog.Info("removing keypad mapping", "err", err)
+		}
+		return err != nil
+	})
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/shares"
+)
+
+// SetShares enables the network share API backed by m. Must be called
+// before the HTTP server starts.
+func (c *Controller) SetShares(m *shares.Manager) {
+	c.mu.Lock()
+	c.shares = m
+	c.mu.Unlock()
+}
+
+// shareManager returns the share manager or a 400 if none is configured.
+func (c *Controller) shareManager() (*shares.Manager, *models.AppError) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.shares == nil {
+		return nil, models.ErrBadRequest("network shares are not available")
+	}
+	return c.shares, nil
+}
+
+// GetShares returns all configured network shares with their mount state.
+func (c *Controller) GetShares() []models.NetworkShare {
+	m, appErr := c.shareManager()
+	if appErr != nil {
+		return []models.NetworkShare{}
+	}
+	list := m.List()
+	result := make([]models.NetworkShare, 0, len(list))
+	for _, s := range list {
+		result = append(result, models.NetworkShare{
+			ID:          s.ID,
+			Name:        s.Name,
+			Type:        s.Type,
+			Server:      s.Server,
+			Path:        s.Path,
+			Username:    s.Username,
+			HasPassword: s.Password != "",
+			Options:     s.Options,
+			Disabled:    s.Disabled,
+			MountPoint:  s.MountPoint,
+			Mounted:     s.Mounted,
+			Error:       s.Error,
+		})
+	}
+	return result
+}
+
+// CreateShare adds a network share and mounts it. A share that fails to
+// mount is still created; the failure is reported in its error field.
+func (c *Controller) CreateShare(ctx context.Context, req models.NetworkShareUpdate) ([]models.NetworkShare, *models.AppError) {
+	m, appErr := c.shareManager()
+	if appErr != nil {
+		return nil, appErr
+	}
+	var s shares.Share
+	applyShareUpdate(&s, req)
+	if _, err := m.Create(ctx, s); err != nil {
+		return nil, models.ErrBadRequest(err.Error())
+	}
+	return c.GetShares(), nil
+}
+
+// SetShare edits a network share and remounts it.
+func (c *Controller) SetShare(ctx context.Context, id int, upd models.NetworkShareUpdate) ([]models.NetworkShare, *models.AppError) {
+	m, appErr := c.shareManager()
+	if appErr != nil {
+		return nil, appErr
+	}
+	s, err := m.Get(id)
+	if err != nil {
+		return nil, models.ErrNotFound("share not found")
+	}
+	applyShareUpdate(&s, upd)
+	if err := m.Update(ctx, s); err != nil {
+		return nil, models.ErrBadRequest(err.Error())
+	}
+	return c.GetShares(), nil
+}
+
+// DeleteShare unmounts and removes a network share.
+func (c *Controller) DeleteShare(ctx context.Context, id int) ([]models.NetworkShare, *models.AppError) {
+	m, appErr := c.shareManager()
+	if appErr != nil {
+		return nil, appErr
+	}
+	if err := m.Delete(ctx, id); err != nil {
+		return nil, shareError(err)
+	}
+	return c.GetShares(), nil
+}
+
+// MountShare mounts a share, or unmounts it when mount is false, without
+// changing its configuration.
+func (c *Controller) MountShare(ctx context.Context, id int, mount bool) ([]models.NetworkShare, *models.AppError) {
+	m, appErr := c.shareManager()
+	if appErr != nil {
+		return nil, appErr
+	}
+	var err error
+	if mount {
+		err = m.Mount(ctx, id)
+	} else {
+		err = m.Unmount(ctx, id)
+	}
+	if err != nil {
+		return nil, shareError(err)
+	}
+	return c.GetShares(), nil
+}
+
+// shareError maps share manager errors to API errors.
+func shareError(err error) *models.AppError {
+	if errors.Is(err, shares.ErrNotFound) {
+		return models.ErrNotFound("share not found")
+	}
+	return models.ErrInternal(err.Error())
+}
+
+// applyShareUpdate copies the set fields of upd into s. Changing the user
+// without giving a password clears the stored password.
+func applyShareUpdate(s *shares.Share, upd models.NetworkShareUpdate) {
+	if upd.Name != nil {
+		s.Name = *upd.Name
+	}
+	if upd.Type != nil {
+		s.Type = *upd.Type
+	}
+	if upd.Server != nil {
+		s.Server = *upd.Server
+	}
+	if upd.Path != nil {
+		s.Path = *upd.Path
+	}
+	if upd.Username != nil && *upd.Username != s.Username {
+		s.Username = *upd.Username
+		s.Password = ""
+	}
+	if upd.Password != nil {
+		s.Password = *upd.Password
+	}
+	if upd.Options != nil {
+		s.Options = *upd.Options
+	}
+	if upd.Disabled != nil {
+		s.Disabled = *upd.Disabled
+	}
+}
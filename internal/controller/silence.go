@@ -0,0 +1,112 @@
+package controller
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// silenceSample is how much of a source's audio each watchdog pass meters.
+const silenceSample = 2 * time.Second
+
+// digitalSilenceDB stands for the -Inf dBFS of digital silence in events:
+// below the quietest 16-bit sample, -90.3 dBFS.
+const digitalSilenceDB = -96
+
+// levelMeter measures the peak level of a source's audio over d, in dBFS.
+type levelMeter func(ctx context.Context, sid int, d time.Duration) (float64, error)
+
+// silenceState is how long a playing stream has been silent.
+type silenceState struct {
+	since    time.Time
+	reported bool
+}
+
+// RunSilenceWatchdog meters the sources of playing streams every interval
+// and reports the streams that stay silent, restarting them if the silence
+// watchdog says so. Blocks until ctx is cancelled.
+func (c *Controller) RunSilenceWatchdog(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.checkSilence(ctx)
+		}
+	}
+}
+
+// checkSilence runs one watchdog pass. A stream's silence starts when it is
+// first metered silent and ends when it is heard, stops playing or can no
+// longer be metered; a stream that is restarted starts over.
+func (c *Controller) checkSilence(ctx context.Context) {
+	state := c.State()
+	w := state.Settings.SilenceWatchdog
+	if !w.Enabled || c.meter == nil {
+		c.silenceMu.Lock()
+		clear(c.silence)
+		c.silenceMu.Unlock()
+		return
+	}
+
+	type metered struct {
+		src  int
+		st   *models.Stream
+		peak float64
+	}
+	var silent []metered
+	for _, src := range state.Sources {
+		st := connectedStream(&state, src.ID)
+		if st == nil || st.Info.State != "playing" {
+			continue
+		}
+		peak, err := c.meter(ctx, src.ID, silenceSample)
+		if err != nil {
+			slog.Debug("silence watchdog: metering failed", "source", src.ID, "stream", st.ID, "err", err)
+			continue
+		}
+		if peak < models.SilenceThresholdDB {
+			silent = append(silent, metered{src.ID, st, peak})
+		}
+	}
+
+	now := c.now()
+	var due []models.StreamSilent
+	c.silenceMu.Lock()
+	next := make(map[int]silenceState)
+	for _, m := range silent {
+		s, ok := c.silence[m.st.ID]
+		if !ok {
+			s.since = now
+		}
+		if !s.reported && now.Sub(s.since) >= w.Timeout() {
+			due = append(due, models.StreamSilent{
+				StreamID: m.st.ID, SourceID: m.src, Name: m.st.Name, Type: m.st.Type,
+				Minutes: now.Sub(s.since).Minutes(), PeakDB: max(m.peak, digitalSilenceDB),
+			})
+			s.reported = true
+		}
+		next[m.st.ID] = s
+	}
+	c.silence = next
+	c.silenceMu.Unlock()
+
+	for _, ev := range due {
+		slog.Warn("stream playing silence", "stream", ev.StreamID, "name", ev.Name, "source", ev.SourceID, "minutes", int(ev.Minutes))
+		if w.Restart {
+			if _, appErr := c.RestartStream(ctx, ev.StreamID); appErr != nil {
+				slog.Warn("silence watchdog: restart failed", "stream", ev.StreamID, "err", appErr.Message)
+			} else {
+				ev.Restarted = true
+				c.silenceMu.Lock()
+				delete(c.silence, ev.StreamID)
+				c.silenceMu.Unlock()
+			}
+		}
+		c.bus.Emit(models.Event{Type: models.EventStreamSilent, Time: now, Data: ev})
+	}
+}
@@ -0,0 +1,145 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/snapcast"
+)
+
+// SetSnapcast enables the Snapcast control API backed by client.
+// Must be called before the HTTP server starts.
+func (c *Controller) SetSnapcast(client *snapcast.Client) {
+	c.mu.Lock()
+	c.snapcast = client
+	c.mu.Unlock()
+}
+
+// snapClient returns the snapserver client or a 400 if none is configured.
+func (c *Controller) snapClient() (*snapcast.Client, *models.AppError) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.snapcast == nil {
+		return nil, models.ErrBadRequest("snapcast is not available")
+	}
+	return c.snapcast, nil
+}
+
+// GetSnapcast returns the groups, clients and streams of the managed snapserver.
+func (c *Controller) GetSnapcast(ctx context.Context) (*models.SnapcastStatus, *models.AppError) {
+	client, appErr := c.snapClient()
+	if appErr != nil {
+		return nil, appErr
+	}
+	status, err := client.Status(ctx)
+	if err != nil {
+		return nil, models.ErrInternal(err.Error())
+	}
+	return status, nil
+}
+
+// SetSnapClient updates a Snapcast client's name, latency or volume.
+func (c *Controller) SetSnapClient(ctx context.Context, id string, upd models.SnapClientUpdate) (*models.SnapcastStatus, *models.AppError) {
+	client, appErr := c.snapClient()
+	if appErr != nil {
+		return nil, appErr
+	}
+	status, appErr := c.GetSnapcast(ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+	var cur *models.SnapClient
+	for _, g := range status.Groups {
+		for i := range g.Clients {
+			if g.Clients[i].ID == id {
+				cur = &g.Clients[i]
+			}
+		}
+	}
+	if cur == nil {
+		return nil, models.ErrNotFound("snapcast client not found")
+	}
+	if upd.Latency != nil && (*upd.Latency < -10000 || *upd.Latency > 10000) {
+		return nil, models.ErrBadRequest("latency must be between -10000 and 10000 ms")
+	}
+	if upd.Volume != nil && (*upd.Volume < 0 || *upd.Volume > 100) {
+		return nil, models.ErrBadRequest("volume must be 0-100")
+	}
+
+	if upd.Name != nil {
+		if err := client.SetClientName(ctx, id, *upd.Name); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	if upd.Latency != nil {
+		if err := client.SetClientLatency(ctx, id, *upd.Latency); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	if upd.Volume != nil || upd.Mute != nil {
+		vol, mute := cur.Volume, cur.Muted
+		if upd.Volume != nil {
+			vol = *upd.Volume
+		}
+		if upd.Mute != nil {
+			mute = *upd.Mute
+		}
+		if err := client.SetClientVolume(ctx, id, vol, mute); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	return c.GetSnapcast(ctx)
+}
+
+// SetSnapGroup updates a Snapcast group's members, source, name or mute.
+func (c *Controller) SetSnapGroup(ctx context.Context, id string, upd models.SnapGroupUpdate) (*models.SnapcastStatus, *models.AppError) {
+	client, appErr := c.snapClient()
+	if appErr != nil {
+		return nil, appErr
+	}
+	status, appErr := c.GetSnapcast(ctx)
+	if appErr != nil {
+		return nil, appErr
+	}
+	found := false
+	for _, g := range status.Groups {
+		if g.ID == id {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, models.ErrNotFound("snapcast group not found")
+	}
+	if upd.SourceID != nil {
+		src, appErr := c.GetSource(*upd.SourceID)
+		if appErr != nil {
+			return nil, appErr
+		}
+		if src.Snapcast == nil || !src.Snapcast.Enabled {
+			return nil, models.ErrBadRequest("snapcast output is not enabled for source")
+		}
+	}
+
+	if upd.Clients != nil {
+		if err := client.SetGroupClients(ctx, id, upd.Clients); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	if upd.SourceID != nil {
+		if err := client.SetGroupStream(ctx, id, snapcast.SourceStreamID(*upd.SourceID)); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	if upd.Name != nil {
+		if err := client.SetGroupName(ctx, id, *upd.Name); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	if upd.Mute != nil {
+		if err := client.SetGroupMute(ctx, id, *upd.Mute); err != nil {
+			return nil, models.ErrInternal(err.Error())
+		}
+	}
+	return c.GetSnapcast(ctx)
+}
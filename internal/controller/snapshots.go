@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// maxSnapshots is how many state snapshots are kept; taking another
+// forgets the oldest.
+const maxSnapshots = 10
+
+// snapshot is a state snapshot and what restoring it applies.
+type snapshot struct {
+	models.Snapshot
+	state models.PresetState
+}
+
+// CreateSnapshot saves the routing and volumes of every source, zone and
+// group, and returns the snapshot to restore them from.
+func (c *Controller) CreateSnapshot() models.Snapshot {
+	c.mu.RLock()
+	snap := snapshot{
+		Snapshot: models.Snapshot{ID: strings.ToLower(rand.Text()[:8]), Time: c.now()},
+		state:    captureState(&c.state),
+	}
+	c.mu.RUnlock()
+
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	c.snapshots = append(c.snapshots, snap)
+	if n := len(c.snapshots) - maxSnapshots; n > 0 {
+		c.snapshots = append([]snapshot(nil), c.snapshots[n:]...)
+	}
+	return snap.Snapshot
+}
+
+// GetSnapshots lists the kept snapshots, oldest first.
+func (c *Controller) GetSnapshots() []models.Snapshot {
+	c.snapMu.Lock()
+	defer c.snapMu.Unlock()
+	list := make([]models.Snapshot, 0, len(c.snapshots))
+	for _, snap := range c.snapshots {
+		list = append(list, snap.Snapshot)
+	}
+	return list
+}
+
+// RestoreSnapshot puts the sources, zones and groups back as they were
+// when the snapshot was taken. Those deleted since are skipped, and
+// noted in the report. The snapshot is kept, so it can be restored again.
+func (c *Controller) RestoreSnapshot(ctx context.Context, id string) (models.State, models.PresetReport, *models.AppError) {
+	c.snapMu.Lock()
+	var ps *models.PresetState
+	for i := range c.snapshots {
+		if c.snapshots[i].ID == id {
+			ps = &c.snapshots[i].state
+			break
+		}
+	}
+	c.snapMu.Unlock()
+	if ps == nil {
+		return models.State{}, models.PresetReport{}, models.ErrNotFound(fmt.Sprintf("snapshot %q not found", id))
+	}
+
+	var report models.PresetReport
+	state, err := c.apply(func(s *models.State) error {
+		report = models.PresetReport{Name: "snapshot " + id, Items: []models.PresetItem{}}
+		return c.applyPresetState(ctx, s, ps, &report)
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, models.PresetReport{}, appErr
+		}
+		return models.State{}, models.PresetReport{}, models.ErrInternal(err.Error())
+	}
+	return state, report, nil
+}
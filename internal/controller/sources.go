@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
 )
 
 // GetSources returns all sources.
@@ -18,6 +19,41 @@ func (c *Controller) GetSources() []models.Source {
 	return result
 }
 
+// computeSources sets the computed fields of each source: its RCA label,
+// from its RCA stream, and its zones and listeners, from the zones'
+// routing.
+func computeSources(s *models.State) {
+	for i := range s.Sources {
+		src := &s.Sources[i]
+		src.RCALabel = ""
+		if st := rcaStream(s, src.ID); st != nil {
+			src.RCALabel = st.Name
+		}
+		src.Zones, src.Listeners = []int{}, 0
+		for _, z := range s.Zones {
+			if z.SourceID != src.ID || z.Disabled {
+				continue
+			}
+			src.Zones = append(src.Zones, z.ID)
+			if !z.Mute {
+				src.Listeners++
+			}
+		}
+	}
+}
+
+// rcaStream returns the RCA stream playing source id's RCA jack, or nil
+// on units without one.
+func rcaStream(s *models.State, id int) *models.Stream {
+	if id < 0 || id > 3 {
+		return nil
+	}
+	if st := findStream(s, models.RCAStreamBaseID+id); st != nil && st.Type == models.StreamTypeRCA {
+		return st
+	}
+	return nil
+}
+
 // GetSource returns a single source by ID.
 func (c *Controller) GetSource(id int) (*models.Source, *models.AppError) {
 	c.mu.RLock()
@@ -31,22 +67,42 @@ func (c *Controller) GetSource(id int) (*models.Source, *models.AppError) {
 	return nil, models.ErrNotFound("source not found")
 }
 
+// GetSourceSDP returns the SDP description of a source's RTP output.
+// origin is the address receivers reached this unit on.
+func (c *Controller) GetSourceSDP(id int, origin string) (string, *models.AppError) {
+	src, appErr := c.GetSource(id)
+	if appErr != nil {
+		return "", appErr
+	}
+	if src.RTP == nil || !src.RTP.Enabled {
+		return "", models.ErrNotFound("rtp output not enabled for source")
+	}
+	return streams.RTPSessionSDP(src.Name, origin, src.RTP.WithDefaults(id)), nil
+}
+
 // validateSourceInput checks hardware capability constraints for a source input change.
 // Returns a non-nil error if the profile prohibits the requested input on this hardware.
 // Returns nil if profile is nil (no restrictions — used in tests/mock mode).
-func (c *Controller) validateSourceInput(input string) *models.AppError {
+// Units without preamp sources may still play to sources that are mapped to
+// a physical output (e.g. a USB DAC on a streamer).
+func (c *Controller) validateSourceInput(id int, input string) *models.AppError {
 	if c.profile == nil {
 		return nil
 	}
-	if c.profile.TotalSources == 0 {
+	if c.profile.TotalSources == 0 && !c.hasOutput(id) {
 		return models.ErrBadRequest("this unit has no audio sources")
 	}
 	// Read the current state to resolve stream types
 	c.mu.RLock()
 	state := c.state
 	c.mu.RUnlock()
-	if isAnalogInput(input, &state) && !c.profile.HasMainUnit() {
-		return models.ErrBadRequest(fmt.Sprintf("analog input not supported on %s unit", c.profile.PrimaryUnitType()))
+	if isAnalogInput(input, &state) {
+		if !c.profile.HasMainUnit() {
+			return models.ErrBadRequest(fmt.Sprintf("analog input not supported on %s unit", c.profile.PrimaryUnitType()))
+		}
+		if c.profile.AnalogDisabled() {
+			return models.ErrBadRequest("analog inputs are " + overrideReason)
+		}
 	}
 	return nil
 }
@@ -59,10 +115,35 @@ func (c *Controller) SetSource(ctx context.Context, id int, upd models.SourceUpd
 
 	// Validate hardware capability before applying
 	if upd.Input != nil {
-		if appErr := c.validateSourceInput(*upd.Input); appErr != nil {
+		if appErr := c.validateSourceInput(id, *upd.Input); appErr != nil {
 			return models.State{}, appErr
 		}
 	}
+	var rtp *models.RTPOutput
+	if upd.RTP != nil {
+		cfg := upd.RTP.WithDefaults(id)
+		if appErr := cfg.Validate(); appErr != nil {
+			return models.State{}, appErr
+		}
+		rtp = &cfg
+	}
+	if upd.Snapcast != nil {
+		switch upd.Snapcast.Codec {
+		case "", "flac", "pcm", "opus", "ogg":
+		default:
+			return models.State{}, models.ErrBadRequest(`snapcast codec must be "flac", "pcm", "opus" or "ogg"`)
+		}
+	}
+	if upd.Cast != nil {
+		if appErr := c.validateCastOutput(upd.Cast); appErr != nil {
+			return models.State{}, appErr
+		}
+	}
+	if upd.Processing != nil {
+		if err := upd.Processing.Validate(); err != nil {
+			return models.State{}, models.ErrBadRequest(err.Error())
+		}
+	}
 
 	state, err := c.apply(func(s *models.State) error {
 		var src *models.Source
@@ -79,12 +160,41 @@ func (c *Controller) SetSource(ctx context.Context, id int, upd models.SourceUpd
 		if upd.Name != nil {
 			src.Name = *upd.Name
 		}
+		if upd.RCALabel != nil {
+			st := rcaStream(s, id)
+			if st == nil {
+				return models.ErrBadRequest(fmt.Sprintf("source %d has no RCA input on this unit", id)).WithField("rca_label")
+			}
+			if appErr := checkName("RCA label", *upd.RCALabel, nil); appErr != nil {
+				return appErr.WithField("rca_label")
+			}
+			st.Name = *upd.RCALabel
+		}
 		if upd.Input != nil {
 			oldInput := src.Input
 			src.Input = *upd.Input
 			if oldInput != *upd.Input {
 				// Update hardware source type (analog/digital)
-				_ = c.updateSourceTypeHW(ctx, s, id)
+				c.updateSourceTypeHW(s)
+			}
+		}
+		if rtp != nil {
+			src.RTP = rtp
+		}
+		if upd.Snapcast != nil {
+			snap := *upd.Snapcast
+			src.Snapcast = &snap
+		}
+		if upd.Cast != nil {
+			out := *upd.Cast
+			out.Devices = append([]string(nil), upd.Cast.Devices...)
+			src.Cast = &out
+		}
+		if upd.Processing != nil {
+			src.Processing = nil
+			if !upd.Processing.IsDefault() {
+				proc := *upd.Processing
+				src.Processing = &proc
 			}
 		}
 
@@ -99,8 +209,8 @@ func (c *Controller) SetSource(ctx context.Context, id int, upd models.SourceUpd
 	return state, nil
 }
 
-// updateSourceTypeHW updates the hardware source type (analog/digital) registers.
-func (c *Controller) updateSourceTypeHW(ctx context.Context, state *models.State, _ int) error {
+// updateSourceTypeHW queues the hardware source type (analog/digital) registers.
+func (c *Controller) updateSourceTypeHW(state *models.State) {
 	var analog [4]bool
 	for i := range state.Sources {
 		src := &state.Sources[i]
@@ -109,11 +219,8 @@ func (c *Controller) updateSourceTypeHW(ctx context.Context, state *models.State
 		}
 	}
 	for _, unit := range c.hw.Units() {
-		if err := c.hw.SetSourceTypes(ctx, unit, analog); err != nil {
-			return err
-		}
+		c.queueSourceTypes(unit, analog)
 	}
-	return nil
 }
 
 // isAnalogInput returns true if the input string corresponds to an analog source.
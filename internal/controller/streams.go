@@ -2,9 +2,14 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"slices"
 
+	"github.com/micro-nova/amplipi-go/internal/artwork"
 	"github.com/micro-nova/amplipi-go/internal/models"
+	"github.com/micro-nova/amplipi-go/internal/streams"
 )
 
 // GetStreams returns all streams.
@@ -29,27 +34,42 @@ func (c *Controller) GetStream(id int) (*models.Stream, *models.AppError) {
 }
 
 // CreateStream creates a new stream and returns the updated state.
-func (c *Controller) CreateStream(_ context.Context, req models.StreamCreate) (models.State, *models.AppError) {
-	if req.Name == "" {
-		return models.State{}, models.ErrBadRequest("stream name is required")
+func (c *Controller) CreateStream(ctx context.Context, req models.StreamCreate) (models.State, *models.AppError) {
+	if err := checkName("stream", req.Name, nil); err != nil {
+		return models.State{}, err
 	}
 	if req.Type == "" {
 		return models.State{}, models.ErrBadRequest("stream type is required")
 	}
 
+	schema := models.FindStreamSchema(req.Type)
+	if schema == nil {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("unknown stream type %q", req.Type))
+	}
+
 	// Reject stream types whose binary isn't installed on this hardware
-	if c.profile != nil && !c.profile.StreamAvailable(req.Type) {
+	if !c.streamTypeAvailable(schema) {
 		return models.State{}, models.ErrBadRequest(
 			fmt.Sprintf("stream type %q is not available on this hardware", req.Type))
 	}
 
+	cfg := schema.ApplyDefaults(copyConfig(req.Config))
+	if err := schema.Validate(cfg); err != nil {
+		return models.State{}, models.ErrBadRequest(err.Error())
+	}
+	if schema.Type == models.StreamTypeAirPlay {
+		if err := c.checkAirPlayName(ctx, 0, &models.Stream{Name: req.Name, Config: cfg}); err != nil {
+			return models.State{}, err
+		}
+	}
+
 	state, err := c.apply(func(s *models.State) error {
 		f := false
 		stream := models.Stream{
 			ID:        nextStreamID(s),
 			Name:      req.Name,
 			Type:      req.Type,
-			Config:    req.Config,
+			Config:    cfg,
 			Disabled:  &f,
 			Browsable: &f,
 		}
@@ -66,22 +86,30 @@ func (c *Controller) CreateStream(_ context.Context, req models.StreamCreate) (m
 }
 
 // SetStream updates a stream by ID.
-func (c *Controller) SetStream(_ context.Context, id int, upd models.StreamUpdate) (models.State, *models.AppError) {
+func (c *Controller) SetStream(ctx context.Context, id int, upd models.StreamUpdate) (models.State, *models.AppError) {
+	if upd.Name != nil || upd.Config != nil {
+		if appErr := c.checkRenamedAirPlay(ctx, id, upd); appErr != nil {
+			return models.State{}, appErr
+		}
+	}
 	state, err := c.apply(func(s *models.State) error {
 		stream := findStream(s, id)
 		if stream == nil {
 			return models.ErrNotFound("stream not found")
 		}
 		if upd.Name != nil {
+			if err := checkName("stream", *upd.Name, nil); err != nil {
+				return err
+			}
 			stream.Name = *upd.Name
 		}
 		if upd.Config != nil {
-			if stream.Config == nil {
-				stream.Config = make(map[string]interface{})
-			}
-			for k, v := range upd.Config {
-				stream.Config[k] = v
+			if schema := models.FindStreamSchema(stream.Type); schema != nil {
+				if err := schema.ValidatePatch(upd.Config); err != nil {
+					return models.ErrBadRequest(err.Error())
+				}
 			}
+			stream.Config = patchConfig(stream.Config, upd.Config)
 		}
 		return nil
 	})
@@ -94,6 +122,152 @@ func (c *Controller) SetStream(_ context.Context, id int, upd models.StreamUpdat
 	return state, nil
 }
 
+// checkRenamedAirPlay checks the name an AirPlay stream would be
+// advertised under after upd. Other streams pass.
+func (c *Controller) checkRenamedAirPlay(ctx context.Context, id int, upd models.StreamUpdate) *models.AppError {
+	st, appErr := c.GetStream(id)
+	if appErr != nil {
+		return nil // SetStream reports it
+	}
+	if schema := models.FindStreamSchema(st.Type); schema == nil || schema.Type != models.StreamTypeAirPlay {
+		return nil
+	}
+	if upd.Name != nil {
+		st.Name = *upd.Name
+	}
+	if upd.Config != nil {
+		st.Config = patchConfig(st.Config, upd.Config)
+	}
+	return c.checkAirPlayName(ctx, id, st)
+}
+
+// StreamTypes returns the config schema of every stream type and whether
+// it can run on this hardware.
+func (c *Controller) StreamTypes() []models.StreamSchema {
+	types := make([]models.StreamSchema, len(models.StreamSchemas))
+	for i, sc := range models.StreamSchemas {
+		types[i] = sc
+		types[i].Available = c.streamTypeAvailable(&sc)
+	}
+	return types
+}
+
+// streamTypeAvailable reports whether the profile lists a stream type's
+// binaries as installed, under its name or an alias, and the profile
+// override doesn't disable it.
+func (c *Controller) streamTypeAvailable(schema *models.StreamSchema) bool {
+	if c.profile == nil {
+		return true
+	}
+	if c.streamDisabled(schema) {
+		return false
+	}
+	if c.profile.StreamAvailable(schema.Type) {
+		return true
+	}
+	for _, alias := range schema.Aliases {
+		if c.profile.StreamAvailable(alias) {
+			return true
+		}
+	}
+	return false
+}
+
+// streamUnavailable returns why st cannot run on this hardware, or "" if
+// it can.
+func (c *Controller) streamUnavailable(st *models.Stream) string {
+	schema := models.FindStreamSchema(st.Type)
+	if schema == nil {
+		return fmt.Sprintf("unknown stream type %q", st.Type)
+	}
+	if c.streamTypeAvailable(schema) {
+		return ""
+	}
+	if c.streamDisabled(schema) {
+		return overrideReason
+	}
+	for _, sc := range c.profile.StreamCapabilities() {
+		if (sc.Type == schema.Type || slices.Contains(schema.Aliases, sc.Type)) && sc.Reason != "" {
+			return sc.Reason
+		}
+	}
+	return "not supported on this hardware"
+}
+
+// markUnavailableStreams marks streams that cannot run here, e.g. loaded
+// from a config made on hardware with more stream binaries installed, as
+// unavailable with the reason. They are not given to the stream manager.
+func (c *Controller) markUnavailableStreams(s *models.State) {
+	for i := range s.Streams {
+		st := &s.Streams[i]
+		if reason := c.streamUnavailable(st); reason != "" {
+			st.Info = models.StreamInfo{Name: st.Name, State: "unavailable", Reason: reason}
+		}
+	}
+}
+
+// redetectStreams detects the stream types that were unavailable again,
+// e.g. after their binary was installed, and clears the unavailable state
+// of the streams that can now run so the stream manager starts them.
+func (c *Controller) redetectStreams() {
+	if c.profile == nil || !c.profile.RedetectStreams() {
+		return
+	}
+	_, _ = c.apply(func(s *models.State) error {
+		changed := false
+		for i := range s.Streams {
+			st := &s.Streams[i]
+			if st.Info.State == "unavailable" && c.streamUnavailable(st) == "" {
+				st.Info = models.StreamInfo{}
+				changed = true
+			}
+		}
+		if !changed {
+			return errNoChange
+		}
+		return nil
+	})
+}
+
+// runnableStreams returns the streams the stream manager should run.
+func (c *Controller) runnableStreams(all []models.Stream) []models.Stream {
+	runnable := make([]models.Stream, 0, len(all))
+	for i := range all {
+		if c.streamUnavailable(&all[i]) == "" {
+			runnable = append(runnable, all[i])
+		}
+	}
+	return runnable
+}
+
+// patchConfig returns a copy of cfg with the keys of patch set, or deleted
+// where their value is nil.
+func patchConfig(cfg, patch map[string]interface{}) map[string]interface{} {
+	out := copyConfig(cfg)
+	if out == nil {
+		out = make(map[string]interface{})
+	}
+	for k, v := range patch {
+		if v == nil {
+			delete(out, k)
+		} else {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+func copyConfig(cfg map[string]interface{}) map[string]interface{} {
+	if cfg == nil {
+		return nil
+	}
+	cp := make(map[string]interface{}, len(cfg))
+	for k, v := range cfg {
+		cp[k] = v
+	}
+	return cp
+}
+
 // DeleteStream removes a stream by ID.
 func (c *Controller) DeleteStream(_ context.Context, id int) (models.State, *models.AppError) {
 	state, err := c.apply(func(s *models.State) error {
@@ -124,10 +298,14 @@ func (c *Controller) ExecStreamCommand(ctx context.Context, id int, cmd string)
 	// Validate that the stream exists first
 	c.mu.RLock()
 	stream := findStream(&c.state, id)
+	scopeErr := checkStreamScope(ctx, &c.state, id)
 	c.mu.RUnlock()
 	if stream == nil {
 		return models.State{}, models.ErrNotFound(fmt.Sprintf("stream %d not found", id))
 	}
+	if scopeErr != nil {
+		return models.State{}, scopeErr
+	}
 
 	// Route to stream manager if available
 	if c.streams != nil {
@@ -168,3 +346,187 @@ func (c *Controller) ExecStreamCommand(ctx context.Context, id int, cmd string)
 	}
 	return state, nil
 }
+
+// BrowseStream lists the browsable content of a stream at path.
+func (c *Controller) BrowseStream(ctx context.Context, id int, path string) ([]models.BrowsableItem, *models.AppError) {
+	if _, appErr := c.GetStream(id); appErr != nil {
+		return nil, appErr
+	}
+	if c.streams == nil {
+		return nil, models.ErrBadRequest("streams are not available")
+	}
+	items, err := c.streams.Browse(ctx, id, path)
+	if err != nil {
+		return nil, streamQueryError(err)
+	}
+	return items, nil
+}
+
+// GetStreamQueue returns the play queue of a file player stream.
+func (c *Controller) GetStreamQueue(id int) (*models.StreamQueue, *models.AppError) {
+	if _, appErr := c.GetStream(id); appErr != nil {
+		return nil, appErr
+	}
+	if c.streams == nil {
+		return nil, models.ErrBadRequest("streams are not available")
+	}
+	q, err := c.streams.Queue(id)
+	if err != nil {
+		return nil, streamQueryError(err)
+	}
+	return &q, nil
+}
+
+// GetStreamImage returns the artwork of what stream id is playing,
+// converted as opts say, e.g. scaled to a display panel in its pixel
+// format.
+func (c *Controller) GetStreamImage(ctx context.Context, id int, opts artwork.Options) (*artwork.Image, *models.AppError) {
+	if err := opts.Validate(); err != nil {
+		return nil, models.ErrBadRequest(err.Error())
+	}
+	st, appErr := c.GetStream(id)
+	if appErr != nil {
+		return nil, appErr
+	}
+	if st.Info.ImageURL == "" {
+		return nil, models.ErrNotFound("stream has no artwork")
+	}
+	img, err := c.artwork.Get(ctx, st.Info.ImageURL, opts)
+	var fetchErr *artwork.FetchError
+	if errors.As(err, &fetchErr) {
+		return nil, models.ErrBadGateway(err.Error())
+	} else if err != nil {
+		return nil, models.ErrInternal(err.Error())
+	}
+	return img, nil
+}
+
+// RestartStream restarts a stream's processes, e.g. after fixing
+// credentials or installing a missing binary, and resets its automatic
+// retry backoff. Stream types found unavailable are detected again first.
+func (c *Controller) RestartStream(ctx context.Context, id int) (models.State, *models.AppError) {
+	c.redetectStreams()
+	st, appErr := c.GetStream(id)
+	if appErr != nil {
+		return models.State{}, appErr
+	}
+	if reason := c.streamUnavailable(st); reason != "" {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("stream %d is unavailable: %s", id, reason))
+	}
+	if c.streams == nil {
+		return models.State{}, models.ErrBadRequest("streams are not available")
+	}
+	if err := c.streams.Restart(ctx, id); err != nil {
+		if errors.Is(err, streams.ErrNotActive) {
+			return models.State{}, models.ErrBadRequest(err.Error())
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return c.State(), nil
+}
+
+// ResetStreamPairing forgets the account a Spotify Connect stream was
+// paired with and restarts it, so it can be picked again in the app.
+func (c *Controller) ResetStreamPairing(ctx context.Context, id int) (models.State, *models.AppError) {
+	if _, appErr := c.GetStream(id); appErr != nil {
+		return models.State{}, appErr
+	}
+	if c.streams == nil {
+		return models.State{}, models.ErrBadRequest("streams are not available")
+	}
+	if err := c.streams.ResetPairing(ctx, id); err != nil {
+		if errors.Is(err, streams.ErrNotPairable) {
+			return models.State{}, models.ErrBadRequest(err.Error())
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return c.State(), nil
+}
+
+// GetStreamLogs returns the recent output of the processes a stream runs,
+// up to lines lines each (0 for the default).
+func (c *Controller) GetStreamLogs(id, lines int) ([]models.ProcessLog, *models.AppError) {
+	if _, appErr := c.GetStream(id); appErr != nil {
+		return nil, appErr
+	}
+	if c.streams == nil {
+		return []models.ProcessLog{}, nil
+	}
+	logs, err := c.streams.Logs(id, lines)
+	if err != nil {
+		return nil, models.ErrInternal(err.Error())
+	}
+	return logs, nil
+}
+
+// streamQueryError maps stream browse/queue errors to API errors.
+func streamQueryError(err error) *models.AppError {
+	switch {
+	case errors.Is(err, streams.ErrNotBrowsable), errors.Is(err, streams.ErrNoQueue),
+		errors.Is(err, streams.ErrInvalidMediaPath), errors.Is(err, streams.ErrNotActive):
+		return models.ErrBadRequest(err.Error())
+	case errors.Is(err, fs.ErrNotExist):
+		return models.ErrNotFound("path not found")
+	}
+	return models.ErrInternal(err.Error())
+}
+
+// linkAirPlayGroups records, in each active AirPlay session, the other
+// streams the same sender is playing to: an iPhone sending to several
+// AmpliPi AirPlay streams at once has grouped them into one AirPlay 2
+// multi-room group.
+func linkAirPlayGroups(s *models.State) {
+	for i := range s.Streams {
+		cur := s.Streams[i].Info.AirPlay
+		if cur == nil {
+			continue
+		}
+		var group []int
+		if cur.DACPID != "" {
+			for _, other := range s.Streams {
+				if other.ID != s.Streams[i].ID && other.Info.AirPlay != nil && other.Info.AirPlay.DACPID == cur.DACPID {
+					group = append(group, other.ID)
+				}
+			}
+		}
+		if slices.Equal(group, cur.Group) {
+			continue
+		}
+		session := *cur // shared with the previous state
+		session.Group = group
+		s.Streams[i].Info.AirPlay = &session
+	}
+}
+
+// hasMetadata reports whether info describes what is playing.
+func hasMetadata(info models.StreamInfo) bool {
+	return info.Track != "" || info.Artist != "" || info.Album != "" || info.Station != ""
+}
+
+// staleStreamInfo turns a stream's info saved by a previous run into
+// last-known info: its metadata is kept, flagged stale, so UIs have
+// something to show until the stream reports again, and the state of
+// processes and sessions that ended with that run is dropped.
+func staleStreamInfo(info models.StreamInfo) models.StreamInfo {
+	if !hasMetadata(info) {
+		return info
+	}
+	info.Stale = true
+	info.Supervisor = nil
+	info.Pairing = nil
+	info.AirPlayActive, info.AirPlay = false, nil
+	info.Bluetooth = nil
+	return info
+}
+
+// mergeStaleInfo returns the info a stream reports, keeping the stale
+// metadata of cur while the report has none of its own.
+func mergeStaleInfo(cur, info models.StreamInfo) models.StreamInfo {
+	if !cur.Stale || hasMetadata(info) {
+		return info
+	}
+	info.Track, info.Artist, info.Album, info.Station = cur.Track, cur.Artist, cur.Album, cur.Station
+	info.ImageURL, info.Rating = cur.ImageURL, cur.Rating
+	info.Stale = true
+	return info
+}
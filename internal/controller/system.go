@@ -3,8 +3,14 @@ package controller
 import (
 	"context"
 	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
 	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/config"
 	"github.com/micro-nova/amplipi-go/internal/hardware"
 	"github.com/micro-nova/amplipi-go/internal/identity"
 	"github.com/micro-nova/amplipi-go/internal/models"
@@ -16,6 +22,9 @@ func (c *Controller) GetInfo() models.Info {
 		Version:  identity.GetVersion(),
 		IsUpdate: identity.IsUpdateMode(),
 		Offline:  !identity.GetOnlineStatus(),
+
+		Hostname:     c.Hostname(),
+		FriendlyName: c.FriendlyName(),
 	}
 
 	// Populate hardware profile fields if a profile is available
@@ -24,12 +33,43 @@ func (c *Controller) GetInfo() models.Info {
 		info.Zones = c.profile.TotalZones
 		info.FirmwareVersion = c.profile.FirmwareVersion
 		info.FanMode = c.profile.FanMode.String()
-		info.AvailableStreams = c.profile.AvailableStreamTypes()
+		info.AvailableStreams = slices.DeleteFunc(c.profile.AvailableStreamTypes(), func(typ string) bool {
+			schema := models.FindStreamSchema(typ)
+			return schema != nil && c.streamDisabled(schema)
+		})
+		info.ProfileOverride = c.profile.Override
+		info.Streamer = c.StreamerMode()
+		for _, u := range c.profile.Units {
+			if u.Board.UnitType != hardware.UnitTypeExpansion && u.EEPROMError == "" && u.Board.Serial != 0 {
+				info.Serial = fmt.Sprint(u.Board.Serial)
+				break
+			}
+		}
 	}
+	info.UnitDetails = c.unitDetails()
+	info.SelfTest = c.SelfTest()
 
 	return info
 }
 
+// unitDetails summarizes each detected unit: the zones it drives, its
+// firmware and its last temperatures.
+func (c *Controller) unitDetails() []models.UnitSummary {
+	var units []models.UnitSummary
+	temps := c.latestTemps()
+	for _, u := range c.detectedUnits() {
+		units = append(units, models.UnitSummary{
+			Unit:     u.Index,
+			ZoneBase: u.ZoneBase,
+			Zones:    u.ZoneCount,
+			Firmware: c.unitFirmware[u.Index],
+			Temps:    temps[u.Index],
+			Board:    c.unitBoard(u),
+		})
+	}
+	return units
+}
+
 // TestPreamp runs a quick preamp self-test by reading the version registers from all units.
 func (c *Controller) TestPreamp(ctx context.Context) (map[string]interface{}, error) {
 	if c.hw == nil {
@@ -81,7 +121,8 @@ func (c *Controller) TestPreamp(ctx context.Context) (map[string]interface{}, er
 	}, nil
 }
 
-// TestFans forces fans on for 3 seconds then returns to auto mode.
+// TestFans forces fans on for 3 seconds then returns to auto mode, or to
+// the fan curve.
 func (c *Controller) TestFans(ctx context.Context) (map[string]interface{}, error) {
 	if c.hw == nil {
 		return map[string]interface{}{"ok": false, "error": "no hardware driver"}, nil
@@ -95,7 +136,6 @@ func (c *Controller) TestFans(ctx context.Context) (map[string]interface{}, erro
 	}
 
 	const fanFullDuty byte = 0xFF // 100% duty cycle
-	const fanAutoDuty byte = 0x00 // return to firmware-controlled auto
 
 	// Force fans on for all units
 	for _, unit := range c.profile.Units {
@@ -114,10 +154,11 @@ func (c *Controller) TestFans(ctx context.Context) (map[string]interface{}, erro
 		return map[string]interface{}{"ok": false, "error": "context cancelled"}, nil
 	}
 
-	// Return to auto
+	// Return to auto, then to the fan curve if one is set
 	for _, unit := range c.profile.Units {
-		_ = c.hw.Write(ctx, unit.Index, hardware.RegFanDuty, fanAutoDuty)
+		_ = c.hw.Write(ctx, unit.Index, hardware.RegFanDuty, hardware.FanDutyAuto)
 	}
+	c.refreshFans(ctx)
 
 	return map[string]interface{}{
 		"ok":      true,
@@ -125,17 +166,66 @@ func (c *Controller) TestFans(ctx context.Context) (map[string]interface{}, erro
 	}, nil
 }
 
+// ConfirmFactoryReset resets the unit in two steps like Power: without a
+// token it returns one, valid for 30 seconds, and with that token it runs
+// FactoryReset. Removing users.json for ResetFull is left to the caller,
+// which owns authentication.
+func (c *Controller) ConfirmFactoryReset(ctx context.Context, req models.FactoryResetRequest) (models.FactoryResetResponse, *models.AppError) {
+	scope := req.Scope
+	if scope == "" {
+		scope = models.ResetConfig
+	}
+	if appErr := checkResetScope(scope); appErr != nil {
+		return models.FactoryResetResponse{}, appErr
+	}
+	action := "factory_reset:" + scope
+	if req.Confirm == "" {
+		t := c.issueConfirm(action)
+		return models.FactoryResetResponse{Scope: scope, Status: "confirm", Confirm: t.token, Expires: &t.expires}, nil
+	}
+	if !c.checkConfirm(action, req.Confirm) {
+		return models.FactoryResetResponse{}, models.ErrBadRequest("invalid or expired confirmation token; request a new one").WithField("confirm")
+	}
+	slog.Info("factory reset", "scope", scope)
+	state, appErr := c.FactoryReset(ctx, scope)
+	if appErr != nil {
+		return models.FactoryResetResponse{}, appErr
+	}
+	return models.FactoryResetResponse{Scope: scope, Status: "reset", State: &state}, nil
+}
+
+// checkResetScope validates a factory reset scope.
+func checkResetScope(scope string) *models.AppError {
+	switch scope {
+	case models.ResetAudio, models.ResetConfig, models.ResetFull:
+		return nil
+	}
+	return models.ErrBadRequest(fmt.Sprintf("scope must be %q, %q or %q", models.ResetAudio, models.ResetConfig, models.ResetFull)).WithField("scope")
+}
+
 // FactoryReset resets the system to default state and pushes it to hardware.
-func (c *Controller) FactoryReset(ctx context.Context) (models.State, *models.AppError) {
+// ResetAudio only resets sources, zones, groups and presets, keeping the
+// streams and settings; the other scopes reset the whole config. An
+// installer's provisioning template, if any, is then applied again.
+func (c *Controller) FactoryReset(ctx context.Context, scope string) (models.State, *models.AppError) {
+	if appErr := checkResetScope(scope); appErr != nil {
+		return models.State{}, appErr
+	}
 	state, err := c.apply(func(s *models.State) error {
-		// Preserve the current version info
-		info := s.Info
 		// Use profile-aware default state if profile is available
-		*s = models.DefaultStateFromProfile(c.profile)
-		s.Info = info
+		def := models.DefaultStateFromProfile(c.profile)
+		if scope == models.ResetAudio {
+			s.Sources, s.Zones, s.Groups, s.Presets = def.Sources, def.Zones, def.Groups, def.Presets
+		} else {
+			// Preserve the current version info
+			info := s.Info
+			*s = def
+			s.Info = info
+		}
 
 		// Push to hardware
-		return c.applyStateToHW(ctx, *s)
+		c.applyStateToHW(*s)
+		return nil
 	})
 	if err != nil {
 		if appErr, ok := err.(*models.AppError); ok {
@@ -143,63 +233,237 @@ func (c *Controller) FactoryReset(ctx context.Context) (models.State, *models.Ap
 		}
 		return models.State{}, models.ErrInternal(err.Error())
 	}
+	if c.StreamerMode() {
+		// Sources follow the configured outputs rather than the detected ones
+		c.syncOutputSources()
+		state = c.State()
+	}
+	c.mu.RLock()
+	tmpl := c.provision
+	c.mu.RUnlock()
+	if tmpl != nil {
+		return c.Provision(ctx, *tmpl)
+	}
 	return state, nil
 }
 
 // LoadConfig merges an uploaded state into the current state.
 // Zones and sources are replaced; streams and presets are additive (deduplicated by ID).
+// A config in which two items of a kind share an ID is rejected.
 func (c *Controller) LoadConfig(ctx context.Context, incoming models.State) (models.State, *models.AppError) {
 	state, err := c.apply(func(s *models.State) error {
-		// Replace sources and zones
-		if incoming.Sources != nil {
-			s.Sources = incoming.Sources
+		if _, err := c.loadConfig(s, incoming); err != nil {
+			return err
 		}
-		if incoming.Zones != nil {
-			s.Zones = incoming.Zones
+		c.applyStateToHW(*s)
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, appErr
 		}
-		if incoming.Groups != nil {
-			s.Groups = incoming.Groups
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return state, nil
+}
+
+// ValidateConfig checks an uploaded state as LoadConfig would and returns
+// the state loading it would produce, with warnings about what was fixed
+// up or will not work, without applying it. It runs the same checks as
+// LoadConfig, so a config it accepts is one LoadConfig accepts.
+func (c *Controller) ValidateConfig(incoming models.State) (models.ConfigValidation, *models.AppError) {
+	c.mu.RLock()
+	next := c.state.DeepCopy()
+	warnings, err := c.loadConfig(&next, incoming)
+	if err == nil {
+		c.finishState(&next)
+	}
+	c.mu.RUnlock()
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.ConfigValidation{}, appErr
+		}
+		return models.ConfigValidation{}, models.ErrInternal(err.Error())
+	}
+	for _, st := range next.Streams {
+		if st.Info.State == "unavailable" && st.Info.Reason != "" {
+			warnings = append(warnings, fmt.Sprintf("stream %d (%s) will not start: %s", st.ID, st.Name, st.Info.Reason))
 		}
+	}
+	warnings = append(warnings, duplicateNames(&next)...)
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return models.ConfigValidation{State: next, Warnings: warnings}, nil
+}
 
-		// Additive merge for streams (dedup by ID)
-		if incoming.Streams != nil {
-			existingIDs := make(map[int]int) // id → index in s.Streams
-			for i, st := range s.Streams {
-				existingIDs[st.ID] = i
-			}
-			for _, st := range incoming.Streams {
-				if idx, exists := existingIDs[st.ID]; exists {
-					s.Streams[idx] = st // update existing
-				} else {
-					s.Streams = append(s.Streams, st)
-					existingIDs[st.ID] = len(s.Streams) - 1
-				}
+// loadConfig merges incoming into s, migrates the result as a config read
+// from disk would be and checks it against the hardware. It returns a
+// description of each change made beyond the merge. LoadConfig and
+// ValidateConfig both check a config here alone.
+func (c *Controller) loadConfig(s *models.State, incoming models.State) ([]string, error) {
+	if errs := configIDCollisions(&incoming); len(errs) > 0 {
+		return nil, models.ErrInvalidFields(errs)
+	}
+	// Replace sources and zones
+	if incoming.Sources != nil {
+		s.Sources = incoming.Sources
+	}
+	if incoming.Zones != nil {
+		s.Zones = incoming.Zones
+	}
+	if incoming.Groups != nil {
+		s.Groups = incoming.Groups
+	}
+
+	// Additive merge for streams (dedup by ID)
+	if incoming.Streams != nil {
+		existingIDs := make(map[int]int) // id → index in s.Streams
+		for i, st := range s.Streams {
+			existingIDs[st.ID] = i
+		}
+		for _, st := range incoming.Streams {
+			if idx, exists := existingIDs[st.ID]; exists {
+				s.Streams[idx] = st // update existing
+			} else {
+				s.Streams = append(s.Streams, st)
+				existingIDs[st.ID] = len(s.Streams) - 1
 			}
 		}
+	}
 
-		// Additive merge for presets (dedup by ID)
-		if incoming.Presets != nil {
-			existingIDs := make(map[int]int)
-			for i, p := range s.Presets {
-				existingIDs[p.ID] = i
+	// Additive merge for presets (dedup by ID)
+	if incoming.Presets != nil {
+		existingIDs := make(map[int]int)
+		for i, p := range s.Presets {
+			existingIDs[p.ID] = i
+		}
+		for _, p := range incoming.Presets {
+			if idx, exists := existingIDs[p.ID]; exists {
+				s.Presets[idx] = p
+			} else {
+				s.Presets = append(s.Presets, p)
+				existingIDs[p.ID] = len(s.Presets) - 1
 			}
-			for _, p := range incoming.Presets {
-				if idx, exists := existingIDs[p.ID]; exists {
-					s.Presets[idx] = p
-				} else {
-					s.Presets = append(s.Presets, p)
-					existingIDs[p.ID] = len(s.Presets) - 1
-				}
+		}
+	}
+
+	if incoming.Settings.SourceIdle != nil {
+		if err := c.validateSourceIdle(incoming.Settings.SourceIdle); err != nil {
+			return nil, models.ErrBadRequest(err.Error())
+		}
+		s.Settings.SourceIdle = incoming.Settings.SourceIdle
+	}
+
+	changes := config.Migrate(s)
+	if err := c.checkLimits(s); err != nil {
+		return nil, models.ErrBadRequest(err.Error())
+	}
+	changes = append(changes, c.reconcileBridges(s)...)
+	return changes, nil
+}
+
+// Speaker test sweep range and length.
+const (
+	sweepFromHz   = 50
+	sweepToHz     = 16000
+	sweepDuration = 8 // seconds
+)
+
+// TestSpeakers plays test signals through each zone in turn, one zone at a
+// time at a safe volume, and reports which zones were exercised. It blocks
+// until every zone has been tested.
+func (c *Controller) TestSpeakers(ctx context.Context, req models.SpeakerTest) (map[string]interface{}, error) {
+	tests := req.Tests
+	if len(tests) == 0 {
+		tests = []string{models.SpeakerTestChannels, models.SpeakerTestSweep}
+	}
+	media := make([]string, 0, len(tests))
+	for _, t := range tests {
+		var path string
+		var err error
+		switch t {
+		case models.SpeakerTestChannels:
+			path, err = tempSignal(audio.WriteTestTone)
+		case models.SpeakerTestSweep:
+			path, err = tempSignal(func(w io.Writer) error {
+				return audio.WriteSweep(w, sweepFromHz, sweepToHz, sweepDuration)
+			})
+		default:
+			return nil, models.ErrBadRequest(fmt.Sprintf("unknown test %q", t))
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(path)
+		media = append(media, path)
+	}
+	volF := models.DefaultIdentifyVolF
+	if req.VolF != nil {
+		volF = *req.VolF
+	}
+	if volF < 0 || volF > models.MaxIdentifyVolF {
+		return nil, models.ErrBadRequest(fmt.Sprintf("vol_f must be between 0 and %g", models.MaxIdentifyVolF))
+	}
+
+	c.mu.RLock()
+	var zones []models.Zone
+	var skipped []int
+	if len(req.Zones) == 0 {
+		for _, z := range c.state.Zones {
+			if z.Disabled {
+				skipped = append(skipped, z.ID)
+			} else {
+				zones = append(zones, z)
 			}
 		}
+	}
+	for _, id := range req.Zones {
+		if z := findZone(&c.state, id); z != nil && !z.Disabled {
+			zones = append(zones, *z)
+		} else {
+			skipped = append(skipped, id)
+		}
+	}
+	c.mu.RUnlock()
 
-		return c.applyStateToHW(ctx, *s)
-	})
-	if err != nil {
-		if appErr, ok := err.(*models.AppError); ok {
-			return models.State{}, appErr
+	results := make([]map[string]interface{}, 0, len(zones))
+	exercised := []int{}
+	allOK := true
+	for _, z := range zones {
+		if ctx.Err() != nil {
+			skipped = append(skipped, z.ID)
+			continue
 		}
-		return models.State{}, models.ErrInternal(err.Error())
+		zone := z.ID
+		_, _ = c.IdentifyLEDs(ctx, models.LEDIdentify{Zone: &zone, Duration: identifyLEDSeconds})
+		result := map[string]interface{}{"zone": z.ID, "name": z.Name, "ok": true}
+		for i, m := range media {
+			_, appErr := c.Announce(ctx, models.AnnounceRequest{
+				Media:    m,
+				VolF:     &volF,
+				SourceID: req.SourceID,
+				Zones:    []int{z.ID},
+			})
+			if appErr != nil {
+				result["ok"] = false
+				result["error"] = fmt.Sprintf("%s: %s", tests[i], appErr.Message)
+				allOK = false
+				break
+			}
+		}
+		if result["ok"] == true {
+			exercised = append(exercised, z.ID)
+		}
+		results = append(results, result)
 	}
-	return state, nil
+
+	return map[string]interface{}{
+		"ok":        allOK && len(zones) > 0,
+		"details":   fmt.Sprintf("tested %d zone(s) with %v", len(zones), tests),
+		"tests":     tests,
+		"zones":     results,
+		"exercised": exercised,
+		"skipped":   skipped,
+	}, nil
 }
@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// timedatectl runs timedatectl, through sudo for changes, which the
+// installer's sudoers entry allows. Replaced in tests.
+var timedatectl = func(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "timedatectl", args...)
+	if strings.HasPrefix(args[0], "set-") {
+		cmd = exec.CommandContext(ctx, "sudo", append([]string{"-n", "timedatectl"}, args...)...)
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("timedatectl %s: %v: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// GetTime returns the clock, timezone and NTP status.
+func (c *Controller) GetTime(ctx context.Context) (models.TimeStatus, *models.AppError) {
+	out, err := timedatectl(ctx, "show")
+	if err != nil {
+		return models.TimeStatus{}, models.ErrInternal(err.Error())
+	}
+	props := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		if k, v, ok := strings.Cut(sc.Text(), "="); ok {
+			props[k] = v
+		}
+	}
+
+	now := c.now()
+	if loc, err := time.LoadLocation(props["Timezone"]); err == nil && props["Timezone"] != "" {
+		now = now.In(loc)
+	}
+	return models.TimeStatus{
+		Time:      now,
+		Timezone:  props["Timezone"],
+		UTCOffset: now.Format("-07:00"),
+		NTP:       props["NTP"] == "yes",
+		Synced:    props["NTPSynchronized"] == "yes",
+		RTC:       props["RTCTimeUSec"] != "" && props["RTCTimeUSec"] != "n/a",
+	}, nil
+}
+
+// SetTime changes the timezone and turns NTP synchronization on or off.
+// Schedules such as night mode follow the new timezone right away.
+func (c *Controller) SetTime(ctx context.Context, upd models.TimeUpdate) (models.TimeStatus, *models.AppError) {
+	if upd.Timezone != nil {
+		tz := *upd.Timezone
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "" || tz == "Local" {
+			return models.TimeStatus{}, models.ErrBadRequest(fmt.Sprintf("unknown timezone %q; see GET /api/system/timezones", tz)).WithField("timezone")
+		}
+		if _, err := timedatectl(ctx, "set-timezone", tz); err != nil {
+			return models.TimeStatus{}, models.ErrInternal(err.Error())
+		}
+		// The process keeps the timezone it started with otherwise.
+		c.tzMu.Lock()
+		c.tz = loc
+		c.tzMu.Unlock()
+	}
+	if upd.NTP != nil {
+		if _, err := timedatectl(ctx, "set-ntp", fmt.Sprint(*upd.NTP)); err != nil {
+			return models.TimeStatus{}, models.ErrInternal(err.Error())
+		}
+	}
+	return c.GetTime(ctx)
+}
+
+// localNow returns the current time in the unit's timezone, for schedules
+// such as night mode and off hours.
+func (c *Controller) localNow() time.Time {
+	c.tzMu.Lock()
+	loc := c.tz
+	c.tzMu.Unlock()
+	if loc == nil {
+		return c.now()
+	}
+	return c.now().In(loc)
+}
+
+// Timezones lists the timezone names SetTime accepts.
+func (c *Controller) Timezones(ctx context.Context) ([]string, *models.AppError) {
+	out, err := timedatectl(ctx, "list-timezones")
+	if err != nil {
+		return nil, models.ErrInternal(err.Error())
+	}
+	return strings.Fields(out), nil
+}
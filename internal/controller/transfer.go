@@ -0,0 +1,227 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// userStream reports whether a stream was created by the user rather than
+// being one of the unit's built-in inputs.
+func userStream(id int) bool { return id > models.RCAStream3 }
+
+// userPreset reports whether a preset was created by the user rather than
+// being a system preset such as "Mute All" or the party restore point.
+func userPreset(id int) bool { return id > 0 && !models.ReservedPresetID(id) }
+
+// ExportConfig returns the user-created streams and/or presets, without
+// the streams' runtime info.
+func (c *Controller) ExportConfig(streams, presets bool) models.ConfigExport {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	var out models.ConfigExport
+	if streams {
+		out.Streams = []models.Stream{}
+		for _, st := range c.state.Streams {
+			if !userStream(st.ID) {
+				continue
+			}
+			st.Info = models.StreamInfo{}
+			st.Active = nil
+			st.Config = copyConfig(st.Config)
+			out.Streams = append(out.Streams, st)
+		}
+	}
+	if presets {
+		out.Presets = []models.Preset{}
+		for _, p := range c.state.Presets {
+			if userPreset(p.ID) {
+				out.Presets = append(out.Presets, p)
+			}
+		}
+	}
+	return out
+}
+
+// ImportConfig loads the streams and presets of an export. In merge mode
+// an imported stream replaces the existing one of the same type and name
+// and an imported preset the one of the same name; the rest are added. In
+// replace mode the user-created streams or presets are replaced outright.
+// Imported items whose IDs are taken get new ones, and imported presets
+// are updated to play the streams under their new IDs.
+func (c *Controller) ImportConfig(_ context.Context, req models.ConfigImport) (models.State, *models.AppError) {
+	switch req.Mode {
+	case "":
+		req.Mode = models.ImportMerge
+	case models.ImportMerge, models.ImportReplace:
+	default:
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("unknown import mode %q; use %q or %q",
+			req.Mode, models.ImportMerge, models.ImportReplace)).WithField("mode")
+	}
+
+	state, err := c.apply(func(s *models.State) error {
+		if errs := checkImport(s, req); len(errs) > 0 {
+			return models.ErrInvalidFields(errs)
+		}
+		ids := importStreams(s, req)
+		importPresets(s, req, ids)
+		return nil
+	})
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return models.State{}, appErr
+		}
+		return models.State{}, models.ErrInternal(err.Error())
+	}
+	return state, nil
+}
+
+// checkImport validates the imported items' names, types and configs.
+func checkImport(s *models.State, req models.ConfigImport) []models.FieldError {
+	var errs []models.FieldError
+	for i, st := range req.Streams {
+		field := fmt.Sprintf("streams[%d]", i)
+		if err := models.ValidateName(st.Name); err != nil {
+			errs = append(errs, models.FieldError{Field: field + ".name", Message: err.Error()})
+		}
+		schema := models.FindStreamSchema(st.Type)
+		if schema == nil {
+			errs = append(errs, models.FieldError{Field: field + ".type", Message: fmt.Sprintf("unknown stream type %q", st.Type)})
+			continue
+		}
+		if err := schema.Validate(schema.ApplyDefaults(copyConfig(st.Config))); err != nil {
+			errs = append(errs, models.FieldError{Field: field + ".config", Message: err.Error()})
+		}
+	}
+
+	// Presets must end up with unique names. Those matching a user preset
+	// update it, so only the system presets' names are taken.
+	var taken []string
+	for _, p := range s.Presets {
+		if !userPreset(p.ID) {
+			taken = append(taken, p.Name)
+		}
+	}
+	for i, p := range req.Presets {
+		if err := checkName("preset", p.Name, taken); err != nil {
+			errs = append(errs, models.FieldError{Field: fmt.Sprintf("presets[%d].name", i), Message: err.Message})
+		}
+		taken = append(taken, p.Name)
+	}
+	return errs
+}
+
+// importStreams adds the imported streams to s and returns the ID each
+// was given, keyed by its ID in the export.
+func importStreams(s *models.State, req models.ConfigImport) map[int]int {
+	ids := make(map[int]int)
+	if req.Streams == nil {
+		return ids
+	}
+	if req.Mode == models.ImportReplace {
+		kept := s.Streams[:0]
+		for _, st := range s.Streams {
+			if !userStream(st.ID) {
+				kept = append(kept, st)
+			}
+		}
+		s.Streams = kept
+	}
+	for _, st := range req.Streams {
+		st.Info = models.StreamInfo{}
+		st.Active = nil
+		st.Config = models.FindStreamSchema(st.Type).ApplyDefaults(copyConfig(st.Config))
+		if existing := findStreamNamed(s, st.Type, st.Name); existing != nil && req.Mode == models.ImportMerge {
+			ids[st.ID] = existing.ID
+			st.ID = existing.ID
+			*existing = st
+			continue
+		}
+		id := st.ID
+		if !userStream(id) || findStream(s, id) != nil {
+			id = nextStreamID(s)
+		}
+		ids[st.ID] = id
+		st.ID = id
+		s.Streams = append(s.Streams, st)
+	}
+	return ids
+}
+
+// importPresets adds the imported presets to s, pointing their sources at
+// the imported streams' new IDs.
+func importPresets(s *models.State, req models.ConfigImport, streamIDs map[int]int) {
+	if req.Presets == nil {
+		return
+	}
+	if req.Mode == models.ImportReplace {
+		kept := s.Presets[:0]
+		for _, p := range s.Presets {
+			if !userPreset(p.ID) {
+				kept = append(kept, p)
+			}
+		}
+		s.Presets = kept
+	}
+	for _, p := range req.Presets {
+		p.State = remapStreamInputs(p.State, streamIDs)
+		if existing := findPresetNamed(s, p.Name); existing != nil {
+			p.ID = existing.ID
+			*existing = p
+			continue
+		}
+		if !userPreset(p.ID) || findPreset(s, p.ID) != nil {
+			p.ID = nextPresetID(s)
+		}
+		s.Presets = append(s.Presets, p)
+	}
+}
+
+// remapStreamInputs returns a copy of ps whose "stream=<id>" source
+// inputs use the IDs in ids.
+func remapStreamInputs(ps *models.PresetState, ids map[int]int) *models.PresetState {
+	if ps == nil {
+		return nil
+	}
+	cp := *ps
+	cp.Sources = make([]models.SourceUpdate, len(ps.Sources))
+	for i, src := range ps.Sources {
+		if src.Input != nil && strings.HasPrefix(*src.Input, "stream=") {
+			id, err := strconv.Atoi(strings.TrimPrefix(*src.Input, "stream="))
+			if to, ok := ids[id]; err == nil && ok {
+				input := "stream=" + strconv.Itoa(to)
+				src.Input = &input
+			}
+		}
+		cp.Sources[i] = src
+	}
+	return &cp
+}
+
+// findStreamNamed returns the user stream of the given type, or one of its
+// aliases, with the given name, ignoring case.
+func findStreamNamed(s *models.State, typ, name string) *models.Stream {
+	schema := models.FindStreamSchema(typ)
+	for i := range s.Streams {
+		st := &s.Streams[i]
+		if userStream(st.ID) && models.FindStreamSchema(st.Type) == schema && strings.EqualFold(st.Name, name) {
+			return st
+		}
+	}
+	return nil
+}
+
+// findPresetNamed returns the user preset with the given name, ignoring
+// case.
+func findPresetNamed(s *models.State, name string) *models.Preset {
+	for i := range s.Presets {
+		if userPreset(s.Presets[i].ID) && strings.EqualFold(strings.TrimSpace(s.Presets[i].Name), strings.TrimSpace(name)) {
+			return &s.Presets[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,253 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// triggerState is the output state of one trigger.
+type triggerState struct {
+	demand    bool      // a watched zone or source is playing
+	since     time.Time // when demand last changed
+	active    bool      // output asserted
+	pin       string    // pin last written
+	activeLow bool      // polarity of the last write
+	level     bool      // level last written
+	written   bool      // pin and level reflect the hardware
+}
+
+// GetTriggers returns the GPIO triggers and whether each is asserted.
+func (c *Controller) GetTriggers() []models.TriggerStatus {
+	triggers := c.GetSettings().Triggers
+	c.trigMu.Lock()
+	defer c.trigMu.Unlock()
+	out := make([]models.TriggerStatus, 0, len(triggers))
+	for _, t := range triggers {
+		st := models.TriggerStatus{Trigger: t}
+		if ts, ok := c.trig[t.ID]; ok {
+			st.Active = ts.active
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// CreateTrigger adds a GPIO trigger.
+func (c *Controller) CreateTrigger(ctx context.Context, req models.TriggerUpdate) ([]models.TriggerStatus, *models.AppError) {
+	return c.updateTriggers(func(s *models.State) error {
+		var t models.Trigger
+		for _, other := range s.Settings.Triggers {
+			t.ID = max(t.ID, other.ID+1)
+		}
+		applyTriggerUpdate(&t, req)
+		if err := validateTrigger(s, t); err != nil {
+			return err
+		}
+		s.Settings.Triggers = append(s.Settings.Triggers, t)
+		return nil
+	})
+}
+
+// SetTrigger updates a GPIO trigger.
+func (c *Controller) SetTrigger(ctx context.Context, id int, upd models.TriggerUpdate) ([]models.TriggerStatus, *models.AppError) {
+	return c.updateTriggers(func(s *models.State) error {
+		t := findTrigger(s, id)
+		if t == nil {
+			return models.ErrNotFound("trigger not found")
+		}
+		next := *t
+		applyTriggerUpdate(&next, upd)
+		if err := validateTrigger(s, next); err != nil {
+			return err
+		}
+		*t = next
+		return nil
+	})
+}
+
+// DeleteTrigger removes a GPIO trigger; its output is released on the next
+// trigger pass.
+func (c *Controller) DeleteTrigger(ctx context.Context, id int) ([]models.TriggerStatus, *models.AppError) {
+	return c.updateTriggers(func(s *models.State) error {
+		for i, t := range s.Settings.Triggers {
+			if t.ID == id {
+				s.Settings.Triggers = append(s.Settings.Triggers[:i], s.Settings.Triggers[i+1:]...)
+				return nil
+			}
+		}
+		return models.ErrNotFound("trigger not found")
+	})
+}
+
+// updateTriggers applies fn and re-evaluates the trigger outputs.
+func (c *Controller) updateTriggers(fn func(*models.State) error) ([]models.TriggerStatus, *models.AppError) {
+	_, err := c.apply(fn)
+	if err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return nil, appErr
+		}
+		return nil, models.ErrInternal(err.Error())
+	}
+	c.refreshTriggers(context.Background())
+	return c.GetTriggers(), nil
+}
+
+func applyTriggerUpdate(t *models.Trigger, upd models.TriggerUpdate) {
+	if upd.Name != nil {
+		t.Name = *upd.Name
+	}
+	if upd.Pin != nil {
+		t.Pin = *upd.Pin
+	}
+	if upd.ActiveLow != nil {
+		t.ActiveLow = *upd.ActiveLow
+	}
+	if upd.Zones != nil {
+		t.Zones = upd.Zones
+	}
+	if upd.Sources != nil {
+		t.Sources = upd.Sources
+	}
+	if upd.Delay != nil {
+		t.Delay = *upd.Delay
+	}
+	if upd.Hold != nil {
+		t.Hold = *upd.Hold
+	}
+}
+
+// validateTrigger checks t and that its zones and sources exist and its
+// pin is not used by another trigger.
+func validateTrigger(s *models.State, t models.Trigger) error {
+	if err := t.Validate(); err != nil {
+		return models.ErrBadRequest(err.Error())
+	}
+	for _, id := range t.Zones {
+		if findZone(s, id) == nil {
+			return models.ErrBadRequest(fmt.Sprintf("trigger zone %d not found", id))
+		}
+	}
+	for _, id := range t.Sources {
+		if findSourceInState(s, id) == nil {
+			return models.ErrBadRequest(fmt.Sprintf("trigger source %d not found", id))
+		}
+	}
+	for _, other := range s.Settings.Triggers {
+		if other.ID != t.ID && other.Pin == t.Pin {
+			return models.ErrConflict(fmt.Sprintf("%s is used by trigger %q", t.Pin, other.Name))
+		}
+	}
+	return nil
+}
+
+func findTrigger(s *models.State, id int) *models.Trigger {
+	for i := range s.Settings.Triggers {
+		if s.Settings.Triggers[i].ID == id {
+			return &s.Settings.Triggers[i]
+		}
+	}
+	return nil
+}
+
+// RunTriggers asserts and releases trigger outputs as their zones and
+// sources start and stop playing, honouring each trigger's delay and hold.
+// Blocks until ctx is cancelled.
+func (c *Controller) RunTriggers(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		c.refreshTriggers(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshTriggers runs one pass of the trigger outputs. Outputs are written
+// once at startup (released) and then only when they change; failed writes
+// are retried on the next pass.
+func (c *Controller) refreshTriggers(ctx context.Context) {
+	state := c.State()
+	now := c.now()
+
+	c.trigMu.Lock()
+	defer c.trigMu.Unlock()
+	seen := make(map[int]bool)
+	for _, t := range state.Settings.Triggers {
+		seen[t.ID] = true
+		ts, ok := c.trig[t.ID]
+		if !ok {
+			ts = &triggerState{since: now}
+			c.trig[t.ID] = ts
+		}
+		if demand := triggerDemand(&state, t); demand != ts.demand {
+			ts.demand, ts.since = demand, now
+		}
+		switch {
+		case ts.demand && !ts.active && now.Sub(ts.since) >= seconds(t.Delay):
+			ts.active = true
+		case !ts.demand && ts.active && now.Sub(ts.since) >= seconds(t.Hold):
+			ts.active = false
+		}
+
+		if ts.written && ts.pin != t.Pin {
+			// Moved to another pin: release the old one.
+			c.writeTrigger(ctx, ts.pin, ts.activeLow)
+			ts.written = false
+		}
+		level := ts.active != t.ActiveLow
+		if ts.written && ts.level == level {
+			continue
+		}
+		if c.writeTrigger(ctx, t.Pin, level) {
+			ts.pin, ts.activeLow, ts.level, ts.written = t.Pin, t.ActiveLow, level, true
+		}
+	}
+	for id, ts := range c.trig {
+		if seen[id] {
+			continue
+		}
+		if ts.written && ts.level != ts.activeLow {
+			c.writeTrigger(ctx, ts.pin, ts.activeLow)
+		}
+		delete(c.trig, id)
+	}
+}
+
+// writeTrigger sets a trigger pin and reports whether the write succeeded.
+func (c *Controller) writeTrigger(ctx context.Context, pin string, high bool) bool {
+	if err := c.hw.SetGPIO(ctx, pin, high); err != nil {
+		slog.Warn("trigger: GPIO write failed", "pin", pin, "err", err)
+		return false
+	}
+	return true
+}
+
+// triggerDemand reports whether any of t's zones is playing or any of its
+// sources feeds a playing zone.
+func triggerDemand(s *models.State, t models.Trigger) bool {
+	for _, id := range t.Zones {
+		if z := findZone(s, id); z != nil && !z.Disabled && zoneInUse(s, z) {
+			return true
+		}
+	}
+	for _, src := range t.Sources {
+		for i := range s.Zones {
+			z := &s.Zones[i]
+			if z.SourceID == src && !z.Disabled && zoneInUse(s, z) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// streamHook is called by apply, with c.mu held, for each stream of the
+// state being applied that started (active) or stopped playing. It must
+// not call apply.
+type streamHook func(s *models.State, st *models.Stream, active bool)
+
+// onStreamActivity registers hook. Hooks are registered in New, before
+// any stream reports.
+func (c *Controller) onStreamActivity(hook streamHook) {
+	c.streamHooks = append(c.streamHooks, hook)
+}
+
+// runStreamHooks calls the stream hooks for every stream whose activity
+// differs between prev and next. New streams count as stopped before.
+func (c *Controller) runStreamHooks(prev, next *models.State) {
+	for i := range next.Streams {
+		st := &next.Streams[i]
+		active := streamActive(st)
+		old := findStream(prev, st.ID)
+		if (old != nil && streamActive(old) == active) || (old == nil && !active) {
+			continue
+		}
+		for _, hook := range c.streamHooks {
+			hook(next, st, active)
+		}
+	}
+}
+
+// streamActive reports whether a stream may be playing: it is not
+// stopped, paused or unavailable.
+func streamActive(st *models.Stream) bool {
+	return !streamIdle(st) && st.Info.State != "unavailable"
+}
+
+// unitPowerStream is the unit power stream hook: a stream that starts
+// wakes the units it plays into, and one that stops starts the idle time
+// of the units it was the last to feed.
+func (c *Controller) unitPowerStream(s *models.State, st *models.Stream, active bool) {
+	if !s.Settings.UnitPower.Enabled {
+		return
+	}
+	input := fmt.Sprintf("stream=%d", st.ID)
+	for _, unit := range c.hw.Units() {
+		fed := slices.ContainsFunc(unitZones(s, unit), func(z *models.Zone) bool {
+			src := findSourceInState(s, z.SourceID)
+			return src != nil && src.Input == input
+		})
+		switch {
+		case !fed:
+		case active:
+			c.wakeUnit(unit)
+		case !unitFed(s, unit):
+			if _, ok := c.unitIdleSince[unit]; !ok {
+				c.unitIdleSince[unit] = c.now()
+			}
+		}
+	}
+}
+
+// updateUnitPower wakes the units that are fed and powers down those that
+// have been idle long enough. Units without zones are left alone. Must be
+// called with c.mu held.
+func (c *Controller) updateUnitPower(now time.Time) {
+	policy := c.state.Settings.UnitPower
+	for _, unit := range c.hw.Units() {
+		if !slices.ContainsFunc(c.state.Zones, func(z models.Zone) bool { return z.ID/6 == unit }) {
+			continue
+		}
+		if !policy.Enabled || unitFed(&c.state, unit) {
+			c.wakeUnit(unit)
+			continue
+		}
+		if c.unitDown[unit] {
+			continue
+		}
+		since, ok := c.unitIdleSince[unit]
+		if !ok {
+			c.unitIdleSince[unit] = now
+			continue
+		}
+		if now.Sub(since) >= policy.Idle() {
+			slog.Info("unit idle, powering down", "unit", unit, "idle", now.Sub(since).Round(time.Second))
+			c.unitDown[unit] = true
+		}
+	}
+}
+
+// wakeUnit clears a unit's idle time, powering it up if it was down. Must
+// be called with c.mu held.
+func (c *Controller) wakeUnit(unit int) {
+	delete(c.unitIdleSince, unit)
+	if c.unitDown[unit] {
+		slog.Info("unit in use, powering up", "unit", unit)
+		delete(c.unitDown, unit)
+	}
+}
+
+// unitZones returns the enabled, unmuted zones on unit.
+func unitZones(s *models.State, unit int) []*models.Zone {
+	var zones []*models.Zone
+	for i := range s.Zones {
+		z := &s.Zones[i]
+		if z.ID/6 == unit && !z.Disabled && !z.Mute {
+			zones = append(zones, z)
+		}
+	}
+	return zones
+}
+
+// unitFed reports whether an unmuted zone on unit is fed.
+func unitFed(s *models.State, unit int) bool {
+	return slices.ContainsFunc(unitZones(s, unit), func(z *models.Zone) bool { return zoneFed(s, z) })
+}
+
+// zoneFed reports whether z plays a source that may be active: an analog
+// input, or a stream that is not stopped or paused.
+func zoneFed(s *models.State, z *models.Zone) bool {
+	src := findSourceInState(s, z.SourceID)
+	if src == nil || src.Input == "" {
+		return false
+	}
+	st := connectedStream(s, src.ID)
+	return st == nil || streamActive(st)
+}
+
+// railSettle is how long a rail may take to report power good once
+// enabled.
+const railSettle = 500 * time.Millisecond
+
+// setRails switches a unit's rails in sequence: on, the 9V rail first and
+// the 12V rail once the 9V rail is good; off, the 12V rail first.
+func (c *Controller) setRails(ctx context.Context, unit int, on bool) error {
+	rails := []struct {
+		name string
+		en   byte
+		good func(hardware.Power) bool
+	}{
+		{"9V", hardware.PowerEN9V, func(p hardware.Power) bool { return p.PG9V }},
+		{"12V", hardware.PowerEN12V, func(p hardware.Power) bool { return p.PG12V }},
+	}
+	if !on {
+		slices.Reverse(rails)
+	}
+	for _, rail := range rails {
+		val, err := c.hw.Read(ctx, unit, hardware.RegPower)
+		if err != nil {
+			return err
+		}
+		val &= hardware.PowerEN9V | hardware.PowerEN12V
+		if (val&rail.en != 0) == on {
+			continue
+		}
+		if on {
+			val |= rail.en
+		} else {
+			val &^= rail.en
+		}
+		if err := c.hw.Write(ctx, unit, hardware.RegPower, val); err != nil {
+			return err
+		}
+		if !on {
+			continue
+		}
+		deadline := time.Now().Add(railSettle)
+		for {
+			p, err := c.hw.ReadPower(ctx, unit)
+			if err != nil {
+				return err
+			}
+			if rail.good(p) {
+				break
+			}
+			if time.Now().After(deadline) {
+				return fmt.Errorf("%s rail not good %v after enabling it", rail.name, railSettle)
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// detectedUnits returns the units of the hardware profile, or, without
+// one, the driver's units as six-zone main and expansion units.
+func (c *Controller) detectedUnits() []hardware.UnitInfo {
+	if c.profile != nil {
+		return c.profile.Units
+	}
+	var units []hardware.UnitInfo
+	for _, u := range c.hw.Units() {
+		info := hardware.UnitInfo{
+			Index:     u,
+			Board:     hardware.BoardInfo{UnitType: hardware.UnitTypeExpansion},
+			ZoneBase:  u * hardware.ZonesPerUnit,
+			ZoneCount: hardware.ZonesPerUnit,
+		}
+		if u == 0 {
+			info.Board.UnitType, info.HasAnalog = hardware.UnitTypeMain, true
+		}
+		units = append(units, info)
+	}
+	return units
+}
+
+// unitBoard returns a unit's board identity, or nil without a hardware
+// profile, where it is not known.
+func (c *Controller) unitBoard(u hardware.UnitInfo) *models.UnitBoard {
+	if c.profile == nil {
+		return nil
+	}
+	return &models.UnitBoard{
+		Serial:      u.Board.Serial,
+		Type:        u.Board.UnitType.String(),
+		Rev:         u.Board.BoardRev,
+		Rev4Plus:    u.Rev4Plus,
+		EEPROMError: u.EEPROMError,
+	}
+}
+
+// GetUnits reads the live status of every detected unit.
+func (c *Controller) GetUnits(ctx context.Context) []models.UnitStatus {
+	units := []models.UnitStatus{}
+	for _, u := range c.detectedUnits() {
+		units = append(units, c.readUnit(ctx, u))
+	}
+	return units
+}
+
+// GetUnit reads the live status of one unit.
+func (c *Controller) GetUnit(ctx context.Context, idx int) (models.UnitStatus, *models.AppError) {
+	for _, u := range c.detectedUnits() {
+		if u.Index == idx {
+			return c.readUnit(ctx, u), nil
+		}
+	}
+	return models.UnitStatus{}, models.ErrNotFound(fmt.Sprintf("unit %d not found", idx))
+}
+
+// readUnit reads a unit's temperatures, power rails, fan and firmware, and
+// estimates its power draw.
+// Failed reads are listed in Errors rather than failing the whole status.
+func (c *Controller) readUnit(ctx context.Context, u hardware.UnitInfo) models.UnitStatus {
+	st := models.UnitStatus{
+		UnitSummary: models.UnitSummary{Unit: u.Index, ZoneBase: u.ZoneBase, Zones: u.ZoneCount, Board: c.unitBoard(u)},
+		Type:        u.Board.UnitType.String(),
+		I2CAddr:     int(u.I2CAddr),
+		HasAnalog:   u.HasAnalog,
+	}
+	if v, err := c.hw.ReadVersion(ctx, u.Index); err == nil {
+		st.Firmware = v.String()
+	} else {
+		st.Errors = append(st.Errors, "firmware: "+err.Error())
+	}
+	if t, err := c.hw.ReadTemps(ctx, u.Index); err == nil {
+		st.Temps = &models.UnitTemps{Time: c.now(), Amp1C: t.Amp1C, Amp2C: t.Amp2C, PSU1C: t.PSU1C, PSU2C: t.PSU2C}
+	} else {
+		st.Errors = append(st.Errors, "temps: "+err.Error())
+	}
+	if p, err := c.hw.ReadPower(ctx, u.Index); err == nil {
+		st.Power = &models.UnitPower{
+			PG9V: p.PG9V, EN9V: p.EN9V, PG12V: p.PG12V, EN12V: p.EN12V,
+			PG5VD: p.PG5VD, PG5VA: p.PG5VA, HV2Present: p.HV2Present,
+		}
+		if est, err := c.estimatePower(ctx, u, p); err == nil {
+			st.Estimate = est
+		} else {
+			st.Errors = append(st.Errors, "power estimate: "+err.Error())
+		}
+	} else {
+		st.Errors = append(st.Errors, "power: "+err.Error())
+	}
+	if f, err := c.hw.ReadFanStatus(ctx, u.Index); err == nil {
+		st.Fan = &models.UnitFan{Mode: hardware.FanMode(f.Ctrl).String(), On: f.On, OverTemp: f.OvrTmp, Failed: f.Fail}
+	} else {
+		st.Errors = append(st.Errors, "fan: "+err.Error())
+	}
+	return st
+}
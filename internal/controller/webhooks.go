@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// GetWebhooks returns the configured webhooks.
+func (c *Controller) GetWebhooks() []models.Webhook {
+	hooks := c.GetSettings().Webhooks
+	if hooks == nil {
+		hooks = []models.Webhook{}
+	}
+	return hooks
+}
+
+// CreateWebhook adds a webhook.
+func (c *Controller) CreateWebhook(ctx context.Context, req models.WebhookUpdate) ([]models.Webhook, *models.AppError) {
+	return c.updateWebhooks(func(s *models.State) error {
+		var w models.Webhook
+		for _, other := range s.Settings.Webhooks {
+			w.ID = max(w.ID, other.ID+1)
+		}
+		applyWebhookUpdate(&w, req)
+		if err := w.Validate(); err != nil {
+			return models.ErrBadRequest(err.Error())
+		}
+		s.Settings.Webhooks = append(s.Settings.Webhooks, w)
+		return nil
+	})
+}
+
+// SetWebhook updates a webhook.
+func (c *Controller) SetWebhook(ctx context.Context, id int, upd models.WebhookUpdate) ([]models.Webhook, *models.AppError) {
+	return c.updateWebhooks(func(s *models.State) error {
+		w := findWebhook(s, id)
+		if w == nil {
+			return models.ErrNotFound("webhook not found")
+		}
+		next := *w
+		applyWebhookUpdate(&next, upd)
+		if err := next.Validate(); err != nil {
+			return models.ErrBadRequest(err.Error())
+		}
+		*w = next
+		return nil
+	})
+}
+
+// DeleteWebhook removes a webhook. Deliveries already queued still run.
+func (c *Controller) DeleteWebhook(ctx context.Context, id int) ([]models.Webhook, *models.AppError) {
+	return c.updateWebhooks(func(s *models.State) error {
+		for i, w := range s.Settings.Webhooks {
+			if w.ID == id {
+				s.Settings.Webhooks = append(s.Settings.Webhooks[:i], s.Settings.Webhooks[i+1:]...)
+				return nil
+			}
+		}
+		return models.ErrNotFound("webhook not found")
+	})
+}
+
+// TestWebhook sends a ping event to one webhook, even a disabled one.
+// Delivery is asynchronous; its outcome is logged.
+func (c *Controller) TestWebhook(ctx context.Context, id int) *models.AppError {
+	c.mu.RLock()
+	w := findWebhook(&c.state, id)
+	c.mu.RUnlock()
+	if w == nil {
+		return models.ErrNotFound("webhook not found")
+	}
+	c.bus.Emit(models.Event{Type: models.EventWebhookPing, Time: c.now(), Data: models.WebhookPing{WebhookID: id}})
+	return nil
+}
+
+// updateWebhooks applies fn and returns the resulting webhooks.
+func (c *Controller) updateWebhooks(fn func(*models.State) error) ([]models.Webhook, *models.AppError) {
+	if _, err := c.apply(fn); err != nil {
+		if appErr, ok := err.(*models.AppError); ok {
+			return nil, appErr
+		}
+		return nil, models.ErrInternal(err.Error())
+	}
+	return c.GetWebhooks(), nil
+}
+
+func applyWebhookUpdate(w *models.Webhook, upd models.WebhookUpdate) {
+	if upd.Name != nil {
+		w.Name = *upd.Name
+	}
+	if upd.URL != nil {
+		w.URL = *upd.URL
+	}
+	if upd.Events != nil {
+		w.Events = upd.Events
+	}
+	if upd.Secret != nil {
+		w.Secret = *upd.Secret
+	}
+	if upd.Disabled != nil {
+		w.Disabled = *upd.Disabled
+	}
+}
+
+func findWebhook(s *models.State, id int) *models.Webhook {
+	for i := range s.Settings.Webhooks {
+		if s.Settings.Webhooks[i].ID == id {
+			return &s.Settings.Webhooks[i]
+		}
+	}
+	return nil
+}
@@ -3,7 +3,9 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/auth"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
@@ -31,8 +33,8 @@ func (c *Controller) GetZone(id int) (*models.Zone, *models.AppError) {
 
 // SetZone updates a zone by ID.
 func (c *Controller) SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError) {
-	if id < 0 || id >= models.MaxZones {
-		return models.State{}, models.ErrBadRequest(fmt.Sprintf("zone id must be 0-%d", models.MaxZones-1))
+	if limit := c.zoneLimit(); id < 0 || id >= limit {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("zone id must be 0-%d", limit-1))
 	}
 
 	state, err := c.apply(func(s *models.State) error {
@@ -40,6 +42,20 @@ func (c *Controller) SetZone(ctx context.Context, id int, upd models.ZoneUpdate)
 		if z == nil {
 			return models.ErrNotFound("zone not found")
 		}
+		if appErr := checkZoneScope(ctx, s, id); appErr != nil {
+			return appErr
+		}
+		if appErr := checkZoneUpdateScope(ctx, upd); appErr != nil {
+			return appErr
+		}
+		if p := bridgePrimary(s, z); p != nil && controlsPlayback(upd) {
+			return models.ErrConflict(fmt.Sprintf("zone %d is bridged with zone %d; control zone %d instead", id, p.ID, p.ID))
+		}
+		if upd.Name != nil {
+			if err := checkName("zone", *upd.Name, zoneNames(s, id)); err != nil {
+				return err
+			}
+		}
 		return applyZoneUpdate(ctx, c, s, z, upd)
 	})
 	if err != nil {
@@ -62,13 +78,27 @@ func (c *Controller) SetZones(ctx context.Context, req models.MultiZoneUpdate) (
 		}
 	}
 	c.mu.RUnlock()
+	if req.Update.Name != nil && len(req.ZoneIDs) > 1 {
+		return models.State{}, models.ErrBadRequest("zones must have unique names; rename them one at a time").WithField("name")
+	}
 
 	state, err := c.apply(func(s *models.State) error {
+		if appErr := checkZoneScope(ctx, s, req.ZoneIDs...); appErr != nil {
+			return appErr
+		}
+		if appErr := checkZoneUpdateScope(ctx, req.Update); appErr != nil {
+			return appErr
+		}
 		for _, id := range req.ZoneIDs {
 			z := findZone(s, id)
 			if z == nil {
 				return models.ErrNotFound(fmt.Sprintf("zone %d not found", id))
 			}
+			if req.Update.Name != nil {
+				if err := checkName("zone", *req.Update.Name, zoneNames(s, id)); err != nil {
+					return err
+				}
+			}
 			if err := applyZoneUpdate(ctx, c, s, z, req.Update); err != nil {
 				return err
 			}
@@ -89,6 +119,7 @@ func applyZoneUpdate(ctx context.Context, c *Controller, s *models.State, z *mod
 	oldVol := z.Vol
 	oldMute := z.Mute
 	oldSource := z.SourceID
+	oldBridged := z.Bridged
 
 	if upd.Name != nil {
 		z.Name = *upd.Name
@@ -96,60 +127,130 @@ func applyZoneUpdate(ctx context.Context, c *Controller, s *models.State, z *mod
 	if upd.Disabled != nil {
 		z.Disabled = *upd.Disabled
 	}
+	if upd.SourceID != nil && *upd.SourceID != z.SourceID && sourceLocked(ctx, z) {
+		return models.ErrForbidden(fmt.Sprintf("zone %d is locked; its source can't be changed", z.ID)).WithField("source_id")
+	}
 	if upd.SourceID != nil {
 		z.SourceID = *upd.SourceID
 	}
-	if upd.VolMin != nil {
-		z.VolMin = *upd.VolMin
+	if upd.VolMin != nil || upd.VolMax != nil {
+		lo, hi := z.VolMin, z.VolMax
+		if upd.VolMin != nil {
+			lo = *upd.VolMin
+		}
+		if upd.VolMax != nil {
+			hi = *upd.VolMax
+		}
+		if err := checkVolLimits(lo, hi, upd.VolMin != nil); err != nil {
+			return err
+		}
+		z.VolMin, z.VolMax = lo, hi
+	}
+	if upd.Amp != nil {
+		if err := upd.Amp.Validate(); err != nil {
+			return models.ErrBadRequest(err.Error())
+		}
+		z.Amp = nil
+		if !upd.Amp.IsDefault() {
+			amp := *upd.Amp
+			z.Amp = &amp
+		}
 	}
-	if upd.VolMax != nil {
-		z.VolMax = *upd.VolMax
+	if upd.Night != nil {
+		z.Night = nil
+		if !upd.Night.IsZero() {
+			if err := upd.Night.Validate(); err != nil {
+				return models.ErrBadRequest(err.Error())
+			}
+			night := *upd.Night
+			z.Night = &night
+		}
+	}
+
+	if upd.Lock != nil {
+		if !auth.AdminFrom(ctx) {
+			return models.ErrForbidden("only an administrator can lock or unlock a zone").WithField("lock")
+		}
+		z.Lock = nil
+		if upd.Lock.Enabled {
+			if err := upd.Lock.Validate(); err != nil {
+				return models.ErrBadRequest(err.Error()).WithField("lock")
+			}
+			lock := models.ZoneLock{Enabled: true}
+			if upd.Lock.VolMax != nil {
+				v := *upd.Lock.VolMax
+				lock.VolMax = &v
+			}
+			z.Lock = &lock
+		}
 	}
 
-	// Volume updates: vol_f takes precedence, then vol, then vol_delta_f
+	// Volume updates: vol_f takes precedence, then vol, then vol_delta, then vol_delta_f
 	if upd.VolF != nil {
-		z.Vol = models.VolFToDB(*upd.VolF)
+		z.Vol = z.VolFToDB(*upd.VolF)
 		z.VolF = *upd.VolF
 	} else if upd.Vol != nil {
 		z.Vol = *upd.Vol
-		z.VolF = models.DBToVolF(*upd.Vol)
+		z.VolF = z.DBToVolF(*upd.Vol)
+	} else if upd.VolDelta != nil {
+		z.Vol = z.Vol + *upd.VolDelta
+		z.VolF = z.DBToVolF(z.Vol)
 	} else if upd.VolDeltaF != nil {
 		// Apply relative delta: delta maps to a range within [VolMin, VolMax]
 		rangeDB := float64(z.VolMax - z.VolMin)
 		deltaDB := int(*upd.VolDeltaF * rangeDB)
 		z.Vol = z.Vol + deltaDB
-		z.VolF = models.DBToVolF(z.Vol)
+		z.VolF = z.DBToVolF(z.Vol)
 	}
 
-	// Clamp vol to zone limits
-	z.Vol = models.ClampVol(z.Vol, z.VolMin, z.VolMax)
-	z.VolF = models.DBToVolF(z.Vol)
+	// Clamp vol to zone limits, including a night mode or lock cap.
+	// Administrators override the lock, except when setting it.
+	overrideLock := auth.AdminFrom(ctx) && upd.Lock == nil
+	z.Vol = models.ClampVol(z.Vol, z.VolMin, setVolLimit(z, c.localNow(), overrideLock))
+	z.VolF = z.DBToVolF(z.Vol)
 
 	if upd.Mute != nil {
 		z.Mute = *upd.Mute
 	}
 
+	if upd.Bridged != nil && *upd.Bridged && !z.Bridged {
+		if err := c.validateBridge(s, z); err != nil {
+			return err
+		}
+	}
+	if upd.Bridged != nil {
+		z.Bridged = *upd.Bridged
+	}
+
+	// A bridge partner follows the zone it is bridged with, whatever the
+	// update (e.g. a preset or group) asked of it.
+	if p := bridgePrimary(s, z); p != nil {
+		mirrorBridge(z, *p)
+	}
+	partner := bridgePartner(s, z)
+	if partner != nil {
+		mirrorBridge(partner, *z)
+	}
+	rebridged := z.Bridged != oldBridged
+
 	// Push to hardware
 	unit := z.ID / 6
 	localZone := z.ID % 6
 
-	if z.SourceID != oldSource {
+	if z.SourceID != oldSource || rebridged {
 		// Rebuild zone sources for this unit
-		if err := pushZoneSources(ctx, c, s, unit); err != nil {
-			return err
-		}
+		pushZoneSources(c, s, unit)
 	}
 
-	if z.Vol != oldVol {
-		if err := c.hw.SetZoneVol(ctx, unit, localZone, z.Vol); err != nil {
-			return err
+	if z.Vol != oldVol || rebridged {
+		c.queueZoneVol(unit, localZone, z.Vol)
+		if partner != nil {
+			c.queueZoneVol(unit, partner.ID%6, partner.Vol)
 		}
 	}
 
-	if z.Mute != oldMute {
-		if err := pushZoneMutes(ctx, c, s, unit); err != nil {
-			return err
-		}
+	if z.Mute != oldMute || rebridged {
+		pushZoneMutes(c, s, unit)
 	}
 
 	// Update group aggregates
@@ -158,10 +259,40 @@ func applyZoneUpdate(ctx context.Context, c *Controller, s *models.State, z *mod
 	return nil
 }
 
-// pushZoneSources writes zone source assignments for a unit to hardware.
-func pushZoneSources(ctx context.Context, c *Controller, s *models.State, unit int) error {
+// sourceLocked reports whether z's lock keeps the request in ctx from
+// changing its source: a locked zone's source is left to administrators.
+func sourceLocked(ctx context.Context, z *models.Zone) bool {
+	return z.Lock != nil && !auth.AdminFrom(ctx)
+}
+
+// checkVolLimits validates a zone's volume range: both ends within
+// [MinVolDB, MaxVolDB] and vol_min below vol_max. minChanged says which
+// field to blame when they cross.
+func checkVolLimits(lo, hi int, minChanged bool) *models.AppError {
+	for _, l := range []struct {
+		field string
+		v     int
+	}{{"vol_min", lo}, {"vol_max", hi}} {
+		if l.v < models.MinVolDB || l.v > models.MaxVolDB {
+			return models.ErrBadRequest(fmt.Sprintf("%s must be between %d and %d dB", l.field, models.MinVolDB, models.MaxVolDB)).WithField(l.field)
+		}
+	}
+	if lo >= hi {
+		field := "vol_max"
+		if minChanged {
+			field = "vol_min"
+		}
+		return models.ErrBadRequest(fmt.Sprintf("vol_min (%d dB) must be below vol_max (%d dB)", lo, hi)).WithField(field)
+	}
+	return nil
+}
+
+// pushZoneSources queues zone source assignments for a unit to hardware.
+// Called from apply, so c.state is the state before the change.
+func pushZoneSources(c *Controller, s *models.State, unit int) {
 	baseZone := unit * 6
 	var sources [6]int
+	fade := zoneCrossfade(s)
 	for i := 0; i < 6; i++ {
 		zoneIdx := baseZone + i
 		if z := findZone(s, zoneIdx); z != nil {
@@ -170,22 +301,41 @@ func pushZoneSources(ctx context.Context, c *Controller, s *models.State, unit i
 				src = 0
 			}
 			sources[i] = src
+			if old := findZone(&c.state, zoneIdx); old != nil && old.SourceID != z.SourceID && !old.Mute && !z.Mute {
+				fade.out = append(fade.out, i)
+				fade.in = append(fade.in, i)
+			}
 		}
 	}
-	return c.hw.SetZoneSources(ctx, unit, sources)
+	c.queueZoneSources(unit, sources, fade)
 }
 
-// pushZoneMutes writes zone mute states for a unit to hardware.
-func pushZoneMutes(ctx context.Context, c *Controller, s *models.State, unit int) error {
+// pushZoneMutes queues zone mute states for a unit to hardware. Called
+// from apply, so c.state is the state before the change.
+func pushZoneMutes(c *Controller, s *models.State, unit int) {
 	baseZone := unit * 6
 	var mutes [6]bool
+	fade := zoneCrossfade(s)
 	for i := 0; i < 6; i++ {
 		zoneIdx := baseZone + i
 		if z := findZone(s, zoneIdx); z != nil {
 			mutes[i] = z.Mute
+			if old := findZone(&c.state, zoneIdx); old != nil && old.Mute != z.Mute {
+				if z.Mute {
+					fade.out = append(fade.out, i)
+				} else {
+					fade.in = append(fade.in, i)
+				}
+			}
 		} else {
 			mutes[i] = true
 		}
 	}
-	return c.hw.SetZoneMutes(ctx, unit, mutes)
+	c.queueZoneMutes(unit, mutes, fade)
+}
+
+// zoneCrossfade returns a crossfade of the configured duration, without
+// zones.
+func zoneCrossfade(s *models.State) crossfade {
+	return crossfade{duration: time.Duration(s.Settings.CrossfadeMS) * time.Millisecond}
 }
@@ -13,14 +13,16 @@ const subBufferSize = 8
 // Subscribers that are slow to consume events will have events dropped rather
 // than blocking publishers.
 type Bus struct {
-	mu   sync.Mutex
-	subs map[string]chan models.State
+	mu     sync.Mutex
+	subs   map[string]chan models.State
+	evSubs map[string]chan models.Event
 }
 
 // NewBus creates a new event bus.
 func NewBus() *Bus {
 	return &Bus{
-		subs: make(map[string]chan models.State),
+		subs:   make(map[string]chan models.State),
+		evSubs: make(map[string]chan models.Event),
 	}
 }
 
@@ -59,6 +61,40 @@ func (b *Bus) Publish(state models.State) {
 	}
 }
 
+// SubscribeEvents creates a subscription to events (see Emit) with the
+// given ID. Call UnsubscribeEvents when done.
+func (b *Bus) SubscribeEvents(id string) <-chan models.Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan models.Event, subBufferSize)
+	b.evSubs[id] = ch
+	return ch
+}
+
+// UnsubscribeEvents removes an event subscription and closes its channel.
+func (b *Bus) UnsubscribeEvents(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch, ok := b.evSubs[id]; ok {
+		delete(b.evSubs, id)
+		close(ch)
+	}
+}
+
+// Emit sends an event to all event subscribers. Like Publish, it drops the
+// event for subscribers whose channel is full.
+func (b *Bus) Emit(ev models.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.evSubs {
+		select {
+		case ch <- ev:
+		default:
+			// Drop if subscriber is slow
+		}
+	}
+}
+
 // SubscriberCount returns the current number of subscribers.
 func (b *Bus) SubscriberCount() int {
 	b.mu.Lock()
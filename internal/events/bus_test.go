@@ -83,3 +83,30 @@ func TestBusSubscriberCount(t *testing.T) {
 		t.Errorf("expected 1 subscriber, got %d", n)
 	}
 }
+
+func TestBusEmit(t *testing.T) {
+	bus := events.NewBus()
+	states := bus.Subscribe("state")
+	evs := bus.SubscribeEvents("ev")
+
+	bus.Emit(models.Event{Type: models.EventSourceAutoOff, Data: models.SourceAutoOff{SourceID: 2}})
+
+	select {
+	case ev := <-evs:
+		if ev.Type != models.EventSourceAutoOff {
+			t.Errorf("got event type %q", ev.Type)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("timed out waiting for event")
+	}
+	select {
+	case <-states:
+		t.Error("state subscriber received an event")
+	default:
+	}
+
+	bus.UnsubscribeEvents("ev")
+	if _, ok := <-evs; ok {
+		t.Error("expected closed event channel after UnsubscribeEvents")
+	}
+}
@@ -3,7 +3,10 @@
 // I2C driver and the mock driver.
 package hardware
 
-import "context"
+import (
+	"context"
+	"fmt"
+)
 
 // Register is an I2C register address.
 type Register = byte
@@ -28,6 +31,29 @@ type Power struct {
 	HV2Present bool
 }
 
+// Rail enable bits of RegPower, laid out as the firmware's PwrReg union in
+// fw/preamp/src/power.h (micro-nova/AmpliPi). Units that can switch their
+// rails (see HardwareProfile.CanSwitchRails) take writes of these bits
+// through the REG_POWER case of the write handler in
+// fw/preamp/src/ctrl_i2c.c; the others are read-only.
+const (
+	PowerEN9V  byte = 1 << 1
+	PowerEN12V byte = 1 << 3
+)
+
+// PowerFromReg decodes a RegPower value.
+func PowerFromReg(val byte) Power {
+	return Power{
+		PG9V:       val&(1<<0) != 0,
+		EN9V:       val&PowerEN9V != 0,
+		PG12V:      val&(1<<2) != 0,
+		EN12V:      val&PowerEN12V != 0,
+		PG5VD:      val&(1<<4) != 0,
+		PG5VA:      val&(1<<5) != 0,
+		HV2Present: val&(1<<6) != 0,
+	}
+}
+
 // FanStatus holds fan control state and status.
 type FanStatus struct {
 	Ctrl   int  // Fan control method (0=MAX6644, 1=PWM, 2=Linear, 3=Forced)
@@ -43,6 +69,11 @@ type Version struct {
 	GitHash [4]byte
 }
 
+// String formats the version as "Major.Minor-GitHash", e.g. "1.7-abc12345".
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d-%02x%02x%02x%02x", v.Major, v.Minor, v.GitHash[0], v.GitHash[1], v.GitHash[2], v.GitHash[3])
+}
+
 // LEDState holds the desired LED state.
 type LEDState struct {
 	Green bool
@@ -101,9 +132,17 @@ type Driver interface {
 	// SetLEDState sets the LED state when override is enabled.
 	SetLEDState(ctx context.Context, unit int, leds LEDState) error
 
+	// SetGPIO drives a Pi header pin (BCM name, e.g. "GPIO17") as an
+	// output, for amplifier triggers.
+	SetGPIO(ctx context.Context, pin string, high bool) error
+
 	// Units returns the list of detected unit indices (0 = master, 1+ = expanders).
 	Units() []int
 
+	// SetUnits replaces the units Units returns, for a hardware profile
+	// override that forces the unit count.
+	SetUnits(units []int)
+
 	// IsReal returns true for a real hardware driver, false for a mock.
 	IsReal() bool
 }
@@ -3,6 +3,7 @@
 package hardware
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"time"
@@ -103,3 +104,22 @@ func resetSTM32(bootloader bool) error {
 
 	return nil
 }
+
+// SetGPIO drives a header pin for amplifier triggers.
+func (d *I2CDriver) SetGPIO(ctx context.Context, pin string, high bool) error {
+	if _, err := host.Init(); err != nil {
+		return fmt.Errorf("gpio: host init failed: %w", err)
+	}
+	p := gpioreg.ByName(pin)
+	if p == nil {
+		return fmt.Errorf("gpio: failed to open %s", pin)
+	}
+	level := gpio.Low
+	if high {
+		level = gpio.High
+	}
+	if err := p.Out(level); err != nil {
+		return fmt.Errorf("gpio: failed to set %s: %w", pin, err)
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package hardware_test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/micro-nova/amplipi-go/internal/hardware"
@@ -336,6 +338,25 @@ func TestReadPower(t *testing.T) {
 	}
 }
 
+func TestMockPowerRails(t *testing.T) {
+	m := hardware.NewMock()
+	ctx := context.Background()
+
+	if err := m.Write(ctx, 0, hardware.RegPower, hardware.PowerEN9V); err != nil {
+		t.Fatalf("Write RegPower: %v", err)
+	}
+	power, err := m.ReadPower(ctx, 0)
+	if err != nil {
+		t.Fatalf("ReadPower: %v", err)
+	}
+	if !power.EN9V || !power.PG9V || power.EN12V || power.PG12V {
+		t.Errorf("after enabling only 9V: %+v", power)
+	}
+	if !power.PG5VD || !power.PG5VA {
+		t.Errorf("5V rails changed by an enable write: %+v", power)
+	}
+}
+
 func TestMockUnits(t *testing.T) {
 	m := hardware.NewMock()
 	units := m.Units()
@@ -357,6 +378,21 @@ func TestMockWithUnits(t *testing.T) {
 	}
 }
 
+func TestDetect_MockExpanders(t *testing.T) {
+	m := hardware.NewMockWithUnits([]int{0, 1, 2, 3, 4, 5, 6, 7})
+	p, err := hardware.Detect(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Units) != 8 || p.TotalZones != 48 || p.TotalSources != 4 {
+		t.Fatalf("units=%d zones=%d sources=%d, want 8/48/4", len(p.Units), p.TotalZones, p.TotalSources)
+	}
+	last := p.Units[7]
+	if last.Board.UnitType != hardware.UnitTypeExpansion || last.ZoneBase != 42 || last.I2CAddr != 0x40 {
+		t.Errorf("unit 7 = %+v", last)
+	}
+}
+
 func TestMockFailWrite(t *testing.T) {
 	m := hardware.NewMock()
 	ctx := context.Background()
@@ -435,6 +471,21 @@ func TestReadVersion(t *testing.T) {
 	}
 }
 
+func TestReadInputSignal(t *testing.T) {
+	m := hardware.NewMock()
+	ctx := context.Background()
+
+	_ = m.Write(ctx, 0, hardware.RegInputSig, 0b0101)
+	sig, err := hardware.ReadInputSignal(ctx, m, 0)
+	if err != nil || sig != [4]bool{true, false, true, false} {
+		t.Errorf("ReadInputSignal = %v, %v", sig, err)
+	}
+	_ = m.Write(ctx, 0, hardware.RegInputSig, 0xFF)
+	if _, err := hardware.ReadInputSignal(ctx, m, 0); err == nil {
+		t.Error("ReadInputSignal accepted bits above the four inputs")
+	}
+}
+
 func TestSetLEDOverride(t *testing.T) {
 	m := hardware.NewMock()
 	ctx := context.Background()
@@ -684,3 +735,118 @@ func TestMockMultiUnit(t *testing.T) {
 		t.Errorf("unit 1 RegMute = 0b%08b, want 0b00000010", unit1Mute)
 	}
 }
+
+func TestMockVersionPerUnit(t *testing.T) {
+	m := hardware.NewMockWithUnits([]int{0, 1})
+	ctx := context.Background()
+	if err := m.Write(ctx, 1, hardware.RegVersionMaj, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write(ctx, 1, hardware.RegGitHash0D, 0x01); err != nil {
+		t.Fatal(err)
+	}
+
+	for unit, want := range map[int]string{0: "1.0-deadbeef", 1: "2.0-deadbe01"} {
+		v, err := m.ReadVersion(ctx, unit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.String() != want {
+			t.Errorf("unit %d version = %s, want %s", unit, v, want)
+		}
+	}
+}
+
+func writeScenario(t *testing.T, js string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "scenario.json")
+	if err := os.WriteFile(path, []byte(js), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMockScenario(t *testing.T) {
+	m := hardware.NewMockWithUnits([]int{0, 1})
+	ctx := context.Background()
+	sc, err := hardware.LoadScenario(writeScenario(t, `{"name": "overheat", "steps": [
+		{"at": 0.1, "unit": 0, "rails": {"12v": false}, "fan_fail": true, "note": "fan dies"},
+		{"at": 0, "unit": 0, "amp1_c": 90, "ramp": 0.2},
+		{"at": 0.3, "unit": 1, "nack_rate": 1}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RunScenario(ctx, sc)
+
+	temps, err := m.ReadTemps(ctx, 0)
+	if err != nil || temps.Amp1C != 90 || temps.Amp2C != -999 {
+		t.Errorf("temps after ramp: %+v, %v", temps, err)
+	}
+	power, err := m.ReadPower(ctx, 0)
+	if err != nil || power.PG12V || !power.EN12V || !power.PG9V {
+		t.Errorf("power with 12V failed: %+v, %v", power, err)
+	}
+	if reg, _ := m.Read(ctx, 0, hardware.RegPower); reg&(1<<2) != 0 {
+		t.Errorf("RegPower = %#x, 12V still good", reg)
+	}
+	fan, err := m.ReadFanStatus(ctx, 0)
+	if err != nil || !fan.Fail || !fan.OvrTmp {
+		t.Errorf("fan status: %+v, %v; want failed and over temperature", fan, err)
+	}
+	for range 10 {
+		if _, err := m.Read(ctx, 1, hardware.RegMute); err == nil {
+			t.Fatal("unit 1 answered at nack_rate 1")
+		}
+	}
+	if _, err := m.ReadVersion(ctx, 0); err != nil {
+		t.Errorf("unit 0 NACKed: %v", err)
+	}
+
+	// Later steps clear faults; a temperature without a ramp is set at once.
+	sc, err = hardware.LoadScenario(writeScenario(t, `{"steps": [
+		{"unit": 0, "rails": {"12v": true}, "fan_fail": false, "amp1_c": 40},
+		{"unit": 1, "nack_rate": 0}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.RunScenario(ctx, sc)
+	power, _ = m.ReadPower(ctx, 0)
+	fan, _ = m.ReadFanStatus(ctx, 0)
+	temps, _ = m.ReadTemps(ctx, 0)
+	if !power.PG12V || fan.Fail || fan.OvrTmp || temps.Amp1C != 40 {
+		t.Errorf("after recovery: power %+v, fan %+v, temps %+v", power, fan, temps)
+	}
+	if _, err := m.Read(ctx, 1, hardware.RegMute); err != nil {
+		t.Errorf("unit 1 still NACKs: %v", err)
+	}
+}
+
+func TestLoadScenario_Examples(t *testing.T) {
+	paths, _ := filepath.Glob("../../scripts/scenarios/*.json")
+	if len(paths) == 0 {
+		t.Fatal("no example scenarios")
+	}
+	for _, path := range paths {
+		if _, err := hardware.LoadScenario(path); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestLoadScenario_Invalid(t *testing.T) {
+	for name, js := range map[string]string{
+		"no steps":     `{"steps": []}`,
+		"unknown key":  `{"steps": [{"at": 0, "amp_c": 50}]}`,
+		"unknown rail": `{"steps": [{"at": 0, "rails": {"24v": false}}]}`,
+		"hot":          `{"steps": [{"at": 0, "psu1_c": 200}]}`,
+		"nack rate":    `{"steps": [{"at": 0, "nack_rate": 2}]}`,
+		"unit":         `{"steps": [{"at": 0, "unit": 99, "fan_fail": true}]}`,
+		"negative at":  `{"steps": [{"at": -1, "fan_fail": true}]}`,
+	} {
+		if _, err := hardware.LoadScenario(writeScenario(t, js)); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+}
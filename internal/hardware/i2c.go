@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"sync"
 	"time"
 	"unsafe"
@@ -15,10 +16,6 @@ import (
 	"golang.org/x/time/rate"
 )
 
-// I2C device addresses for AmpliPi preamp units.
-// Unit 0 (master) = 0x08 (7-bit), unit 1 = 0x10, etc. (each +0x08).
-var devAddrs = []uint16{0x08, 0x10, 0x18, 0x20, 0x28, 0x30}
-
 const (
 	i2cDevPath   = "/dev/i2c-1"
 	i2cSlave     = 0x0703 // I2C_SLAVE ioctl
@@ -93,7 +90,8 @@ func (d *I2CDriver) Init(ctx context.Context) error {
 	d.fd = fd
 
 	var detected []int
-	for unit, addr := range devAddrs {
+	for unit := 0; unit < MaxUnits; unit++ {
+		addr := unitAddr(unit)
 		// Probe: try to read version register using I2C_RDWR (SMBus read_byte_data).
 		// Generates the REPEATED START the STM32 firmware requires.
 		_, err := d.readByteData(fd, addr, RegVersionMaj)
@@ -115,7 +113,7 @@ func (d *I2CDriver) Init(ctx context.Context) error {
 	// Enforce digital-only on expander units (they have no analog inputs).
 	for _, unit := range detected {
 		if unit > 0 {
-			addr := devAddrs[unit]
+			addr := unitAddr(unit)
 			_ = d.writeByteData(fd, addr, RegSrcAD, 0x0F) // all 4 sources digital
 		}
 	}
@@ -132,10 +130,10 @@ func (d *I2CDriver) Write(ctx context.Context, unit int, reg Register, val byte)
 	if d.fd < 0 {
 		return fmt.Errorf("i2c: driver not initialized")
 	}
-	if unit < 0 || unit >= len(devAddrs) {
+	if unit < 0 || unit >= MaxUnits {
 		return fmt.Errorf("i2c: invalid unit %d", unit)
 	}
-	addr := devAddrs[unit]
+	addr := unitAddr(unit)
 	return d.writeByteData(d.fd, addr, reg, val)
 }
 
@@ -148,10 +146,10 @@ func (d *I2CDriver) Read(ctx context.Context, unit int, reg Register) (byte, err
 	if d.fd < 0 {
 		return 0, fmt.Errorf("i2c: driver not initialized")
 	}
-	if unit < 0 || unit >= len(devAddrs) {
+	if unit < 0 || unit >= MaxUnits {
 		return 0, fmt.Errorf("i2c: invalid unit %d", unit)
 	}
-	addr := devAddrs[unit]
+	addr := unitAddr(unit)
 	return d.readByteData(d.fd, addr, reg)
 }
 
@@ -255,15 +253,7 @@ func (d *I2CDriver) ReadPower(ctx context.Context, unit int) (Power, error) {
 	if err != nil {
 		return Power{}, err
 	}
-	return Power{
-		PG9V:       val&(1<<0) != 0,
-		EN9V:       val&(1<<1) != 0,
-		PG12V:      val&(1<<2) != 0,
-		EN12V:      val&(1<<3) != 0,
-		PG5VD:      val&(1<<4) != 0,
-		PG5VA:      val&(1<<5) != 0,
-		HV2Present: val&(1<<6) != 0,
-	}, nil
+	return PowerFromReg(val), nil
 }
 
 func (d *I2CDriver) ReadFanStatus(ctx context.Context, unit int) (FanStatus, error) {
@@ -335,6 +325,12 @@ func (d *I2CDriver) Units() []int {
 	return result
 }
 
+func (d *I2CDriver) SetUnits(units []int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.units = slices.Clone(units)
+}
+
 func (d *I2CDriver) IsReal() bool { return true }
 
 // Close releases the I2C file descriptor.
@@ -2,6 +2,8 @@ package hardware
 
 import (
 	"context"
+	"math/rand/v2"
+	"slices"
 	"sync"
 	"time"
 )
@@ -13,6 +15,9 @@ type Mock struct {
 	units     []int
 	failWrite bool
 	failRead  bool
+	gpio      map[string]bool     // pin -> level last set
+	faults    map[int]*mockFaults // unit → faults set by a scenario
+	rand      *rand.Rand          // draws NACKs
 }
 
 // NewMock creates a new mock driver with unit 0 pre-initialized.
@@ -20,6 +25,7 @@ func NewMock() *Mock {
 	m := &Mock{
 		regs:  make(map[int]map[Register]byte),
 		units: []int{0},
+		rand:  rand.New(rand.NewPCG(1, 2)),
 	}
 	m.initUnit(0)
 	return m
@@ -30,6 +36,7 @@ func NewMockWithUnits(units []int) *Mock {
 	m := &Mock{
 		regs:  make(map[int]map[Register]byte),
 		units: units,
+		rand:  rand.New(rand.NewPCG(1, 2)),
 	}
 	for _, u := range units {
 		m.initUnit(u)
@@ -40,12 +47,17 @@ func NewMockWithUnits(units []int) *Mock {
 func (m *Mock) initUnit(unit int) {
 	regs := make(map[Register]byte)
 	// Default: all zones muted, all amps enabled, sources digital
-	regs[RegMute] = 0x3F   // all 6 zones muted
-	regs[RegAmpEn] = 0x3F  // all 6 zones amp enabled
-	regs[RegSrcAD] = 0x00  // all sources analog (0)
+	regs[RegMute] = 0x3F     // all 6 zones muted
+	regs[RegAmpEn] = 0x3F    // all 6 zones amp enabled
+	regs[RegSrcAD] = 0x00    // all sources analog (0)
+	regs[RegPower] = 0x3F    // 9V, 12V and 5V rails enabled and good
+	regs[RegHV1Voltage] = 96 // 24V amp supply
 	for i := byte(0); i < 6; i++ {
 		regs[RegVolZone1+i] = VolMuteReg // all zones at mute volume
 	}
+	// Firmware 1.0-deadbeef; tests write other versions per unit
+	regs[RegVersionMaj], regs[RegVersionMin] = 1, 0
+	regs[RegGitHash65], regs[RegGitHash43], regs[RegGitHash21], regs[RegGitHash0D] = 0xde, 0xad, 0xbe, 0xef
 	m.regs[unit] = regs
 }
 
@@ -75,9 +87,17 @@ func (m *Mock) Write(ctx context.Context, unit int, reg Register, val byte) erro
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	if _, ok := m.regs[unit]; !ok {
 		m.regs[unit] = make(map[Register]byte)
 	}
+	if reg == RegPower {
+		// Only the enables are writable; a rail is good once enabled.
+		en := val & (PowerEN9V | PowerEN12V)
+		val = m.regs[unit][reg]&^0x0F | en | en>>1
+	}
 	m.regs[unit][reg] = val
 	return nil
 }
@@ -90,12 +110,15 @@ func (m *Mock) Read(ctx context.Context, unit int, reg Register) (byte, error) {
 	if m.failRead {
 		return 0, ErrHardware("mock: read failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return 0, err
+	}
 	if regs, ok := m.regs[unit]; ok {
 		if val, ok := regs[reg]; ok {
-			return val, nil
+			return m.faultReg(unit, reg, val), nil
 		}
 	}
-	return 0, nil
+	return m.faultReg(unit, reg, 0), nil
 }
 
 func (m *Mock) SetSourceTypes(ctx context.Context, unit int, analog [4]bool) error {
@@ -105,6 +128,9 @@ func (m *Mock) SetSourceTypes(ctx context.Context, unit int, analog [4]bool) err
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	var val byte
 	for i, a := range analog {
 		if !a { // digital = bit set
@@ -123,6 +149,9 @@ func (m *Mock) SetZoneSources(ctx context.Context, unit int, sources [6]int) err
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	m.ensureUnit(unit)
 	m.regs[unit][RegZone321] = PackZone321(sources[0], sources[1], sources[2])
 	m.regs[unit][RegZone654] = PackZone654(sources[3], sources[4], sources[5])
@@ -136,6 +165,9 @@ func (m *Mock) SetZoneMutes(ctx context.Context, unit int, mutes [6]bool) error
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	m.ensureUnit(unit)
 	var val byte
 	for i, mu := range mutes {
@@ -154,6 +186,9 @@ func (m *Mock) SetAmpEnables(ctx context.Context, unit int, enables [6]bool) err
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	m.ensureUnit(unit)
 	var val byte
 	for i, en := range enables {
@@ -187,6 +222,9 @@ func (m *Mock) ReadTemps(ctx context.Context, unit int) (Temps, error) {
 	if m.failRead {
 		return Temps{}, ErrHardware("mock: read failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return Temps{}, err
+	}
 	regs := m.getOrInit(unit)
 	return Temps{
 		Amp1C: TempFromReg(regs[RegAmpTemp1]),
@@ -204,15 +242,10 @@ func (m *Mock) ReadPower(ctx context.Context, unit int) (Power, error) {
 	if m.failRead {
 		return Power{}, ErrHardware("mock: read failure configured")
 	}
-	// Return a reasonable default power state
-	return Power{
-		PG9V:  true,
-		EN9V:  true,
-		PG12V: true,
-		EN12V: true,
-		PG5VD: true,
-		PG5VA: true,
-	}, nil
+	if err := m.nack(unit); err != nil {
+		return Power{}, err
+	}
+	return PowerFromReg(m.faultReg(unit, RegPower, m.regs[unit][RegPower])), nil
 }
 
 func (m *Mock) ReadFanStatus(ctx context.Context, unit int) (FanStatus, error) {
@@ -222,7 +255,16 @@ func (m *Mock) ReadFanStatus(ctx context.Context, unit int) (FanStatus, error) {
 	if m.failRead {
 		return FanStatus{}, ErrHardware("mock: read failure configured")
 	}
-	return FanStatus{Ctrl: 0, On: false}, nil
+	if err := m.nack(unit); err != nil {
+		return FanStatus{}, err
+	}
+	val := m.faultReg(unit, RegFans, m.regs[unit][RegFans])
+	return FanStatus{
+		Ctrl:   int(val & 0x03),
+		On:     val&(1<<2) != 0,
+		OvrTmp: val&(1<<3) != 0,
+		Fail:   val&(1<<4) != 0,
+	}, nil
 }
 
 func (m *Mock) WriteRPiTemp(ctx context.Context, unit int, tempC float32) error {
@@ -232,6 +274,9 @@ func (m *Mock) WriteRPiTemp(ctx context.Context, unit int, tempC float32) error
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	m.ensureUnit(unit)
 	m.regs[unit][RegPiTemp] = TempToReg(tempC)
 	return nil
@@ -244,7 +289,15 @@ func (m *Mock) ReadVersion(ctx context.Context, unit int) (Version, error) {
 	if m.failRead {
 		return Version{}, ErrHardware("mock: read failure configured")
 	}
-	return Version{Major: 1, Minor: 0, GitHash: [4]byte{0xde, 0xad, 0xbe, 0xef}}, nil
+	if err := m.nack(unit); err != nil {
+		return Version{}, err
+	}
+	regs := m.getOrInit(unit)
+	return Version{
+		Major:   int(regs[RegVersionMaj]),
+		Minor:   int(regs[RegVersionMin]),
+		GitHash: [4]byte{regs[RegGitHash65], regs[RegGitHash43], regs[RegGitHash21], regs[RegGitHash0D]},
+	}, nil
 }
 
 func (m *Mock) SetLEDOverride(ctx context.Context, unit int, enable bool) error {
@@ -254,6 +307,9 @@ func (m *Mock) SetLEDOverride(ctx context.Context, unit int, enable bool) error
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	m.ensureUnit(unit)
 	if enable {
 		m.regs[unit][RegLEDCtrl] = 1
@@ -270,6 +326,9 @@ func (m *Mock) SetLEDState(ctx context.Context, unit int, leds LEDState) error {
 	if m.failWrite {
 		return ErrHardware("mock: write failure configured")
 	}
+	if err := m.nack(unit); err != nil {
+		return err
+	}
 	m.ensureUnit(unit)
 	var val byte
 	if leds.Green {
@@ -287,6 +346,27 @@ func (m *Mock) SetLEDState(ctx context.Context, unit int, leds LEDState) error {
 	return nil
 }
 
+func (m *Mock) SetGPIO(ctx context.Context, pin string, high bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.failWrite {
+		return ErrHardware("mock: write failure configured")
+	}
+	if m.gpio == nil {
+		m.gpio = make(map[string]bool)
+	}
+	m.gpio[pin] = high
+	return nil
+}
+
+// GPIO returns the level last set on pin and whether it was ever set.
+func (m *Mock) GPIO(pin string) (high, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	high, ok = m.gpio[pin]
+	return high, ok
+}
+
 func (m *Mock) Units() []int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -295,6 +375,19 @@ func (m *Mock) Units() []int {
 	return result
 }
 
+// SetUnits replaces the mock's units; added units start with the
+// power-on register defaults.
+func (m *Mock) SetUnits(units []int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range units {
+		if _, ok := m.regs[u]; !ok {
+			m.initUnit(u)
+		}
+	}
+	m.units = slices.Clone(units)
+}
+
 func (m *Mock) IsReal() bool {
 	return false
 }
@@ -0,0 +1,104 @@
+package hardware
+
+import (
+	"fmt"
+	"slices"
+)
+
+// ProfileOverride forces parts of the detected HardwareProfile, for units
+// whose EEPROMs are unprogrammed or whose detection misbehaves. Nil fields
+// keep what was detected.
+type ProfileOverride struct {
+	// Units forces the number of preamp units: detected units beyond it
+	// are dropped, and missing ones are added as expansion units.
+	Units *int `json:"units,omitempty"`
+	// AnalogSources false disables the analog (RCA and aux) inputs.
+	AnalogSources *bool `json:"analog_sources,omitempty"`
+	// UnavailableStreams are stream types that are marked unavailable
+	// even though their binaries are installed.
+	UnavailableStreams []string `json:"unavailable_streams,omitempty"`
+}
+
+// IsZero reports whether o overrides nothing.
+func (o ProfileOverride) IsZero() bool {
+	return o.Units == nil && o.AnalogSources == nil && len(o.UnavailableStreams) == 0
+}
+
+// Validate checks the unit count is within the chain's addressing limits.
+// Stream types are checked by the controller, which knows them.
+func (o ProfileOverride) Validate() error {
+	if o.Units != nil && (*o.Units < 1 || *o.Units > MaxUnits) {
+		return fmt.Errorf("units must be 1-%d", MaxUnits)
+	}
+	return nil
+}
+
+// ApplyOverride forces the unit count and analog inputs of o onto p and
+// keeps o as p.Override. The unit count is applied on top of detection:
+// added units are six-zone expanders without EEPROM data.
+func (p *HardwareProfile) ApplyOverride(o ProfileOverride) {
+	if o.IsZero() {
+		return
+	}
+	p.Override = &o
+	if o.Units != nil {
+		n := *o.Units
+		if n < len(p.Units) {
+			p.Units = p.Units[:n]
+		}
+		for idx := len(p.Units); idx < n; idx++ {
+			p.Units = append(p.Units, UnitInfo{
+				Index:       idx,
+				I2CAddr:     uint8(unitAddr(idx)),
+				Board:       BoardInfo{UnitType: UnitTypeExpansion, BoardRev: "Rev?.?"},
+				ZoneBase:    idx * ZonesPerUnit,
+				ZoneCount:   ZonesPerUnit,
+				EEPROMError: "added by the hardware profile override",
+			})
+		}
+		p.TotalZones, p.TotalSources, p.IsStreamer = 0, 0, false
+		for _, u := range p.Units {
+			p.TotalZones += u.ZoneCount
+			switch u.Board.UnitType {
+			case UnitTypeMain:
+				p.TotalSources = SourcesPerUnit
+			case UnitTypeStreamer:
+				p.IsStreamer = true
+			}
+		}
+	}
+	if p.AnalogDisabled() {
+		for i := range p.Units {
+			p.Units[i].HasAnalog = false
+		}
+	}
+}
+
+// UnitIndices returns the indices of p's units, for Driver.SetUnits once
+// an override has changed them.
+func (p *HardwareProfile) UnitIndices() []int {
+	idx := make([]int, len(p.Units))
+	for i, u := range p.Units {
+		idx[i] = u.Index
+	}
+	return idx
+}
+
+// AnalogDisabled reports whether the override disables the analog inputs.
+func (p *HardwareProfile) AnalogDisabled() bool {
+	return p.Override != nil && p.Override.AnalogSources != nil && !*p.Override.AnalogSources
+}
+
+// StreamDisabled reports whether the override marks streamType, or one of
+// aliases, unavailable.
+func (p *HardwareProfile) StreamDisabled(streamType string, aliases ...string) bool {
+	if p.Override == nil {
+		return false
+	}
+	for _, t := range p.Override.UnavailableStreams {
+		if t == streamType || slices.Contains(aliases, t) {
+			return true
+		}
+	}
+	return false
+}
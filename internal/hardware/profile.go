@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
-	"os/exec"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -65,15 +65,38 @@ type BoardInfo struct {
 	BoardRev string // e.g. "Rev4.A"
 }
 
+// Addressing limits of the preamp chain. These only bound what the bus can
+// address; the detected HardwareProfile gives the actual unit, zone and
+// source counts.
+//
+// Each unit takes the address of the one before it plus 0x08, starting at
+// 0x08 (see unitAddr). The I2C-bus specification (NXP UM10204, section
+// 3.1.12, table 4) reserves the 7-bit addresses 0x78-0x7F, so 0x70 is the
+// last one a unit can take: 14 units.
+const (
+	MaxUnits       = 14
+	ZonesPerUnit   = 6
+	SourcesPerUnit = 4 // sources on a main unit; expanders share them
+)
+
+// unitAddr returns the 7-bit I2C address of a preamp unit.
+// Unit 0 (master) = 0x08, unit 1 = 0x10, etc. (each +0x08).
+func unitAddr(unit int) uint16 {
+	return uint16(0x08 * (unit + 1))
+}
+
 // UnitInfo describes a single detected preamp unit (main or expander).
 type UnitInfo struct {
-	Index     int   // 0 = main unit, 1-5 = expanders
+	Index     int   // 0 = main unit, 1+ = expanders
 	I2CAddr   uint8 // 7-bit I2C address (0x08, 0x10, 0x18...)
 	Board     BoardInfo
 	ZoneBase  int  // first zone index on this unit (Index * 6)
-	ZoneCount int  // always 6
+	ZoneCount int  // 6, or 0 for streamer units
 	HasAnalog bool // false for expansion units (UnitTypeExpansion)
 	Rev4Plus  bool // true if EEPROM detected on unit's internal I2C bus
+	// EEPROMError says why Board was guessed from the unit's position in
+	// the chain; empty if it was read from the EEPROM.
+	EEPROMError string
 }
 
 // StreamCapability describes whether a stream type's required binary is available.
@@ -105,11 +128,12 @@ func (d DisplayType) String() string {
 }
 
 // HardwareProfile is populated once at boot by Detect() and
-// is then read-only for the lifetime of the process.
+// is then read-only for the lifetime of the process, except for Streams,
+// which RedetectStreams updates.
 type HardwareProfile struct {
-	// Units: index 0 is main, 1-5 are expanders in daisy-chain order.
+	// Units: index 0 is main, 1+ are expanders in daisy-chain order.
 	Units       []UnitInfo
-	TotalZones  int  // sum of ZoneCount across all units (6-36)
+	TotalZones  int  // sum of ZoneCount across all units
 	TotalSources int  // 4 if main unit present, 0 if streamer-only
 	IsStreamer  bool // true if UnitTypeStreamer detected
 
@@ -122,8 +146,11 @@ type HardwareProfile struct {
 	// Display hardware
 	Display DisplayType
 
-	// Stream binary availability
-	Streams []StreamCapability
+	// Stream binary availability. Guarded by streamsMu once the profile
+	// is in use: read it with StreamCapabilities.
+	Streams    []StreamCapability
+	streamsMu  sync.RWMutex
+	streamsOff bool // set by DisableStreams
 
 	// Firmware version on main unit
 	FirmwareVersion string // "Major.Minor-GitHash"
@@ -134,6 +161,20 @@ type HardwareProfile struct {
 	// Older hardware: [0] only
 	// Newer hardware with USB DAC: [0, 1, 2, 3]
 	AvailablePhysicalOutputs []int
+
+	// Override is the profile override applied after detection, or nil.
+	Override *ProfileOverride
+}
+
+// CanSwitchRails reports whether unit can switch its 9V and 12V rails off
+// and on through RegPower, which Rev4 and later amplifier units can.
+func (p *HardwareProfile) CanSwitchRails(unit int) bool {
+	for _, u := range p.Units {
+		if u.Index == unit {
+			return u.Rev4Plus && u.ZoneCount > 0
+		}
+	}
+	return false
 }
 
 // HasMainUnit returns true if the profile contains a main (AP1_S4Z6) unit.
@@ -146,6 +187,17 @@ func (p *HardwareProfile) HasMainUnit() bool {
 	return false
 }
 
+// CanBridge reports whether zone's channel pair can be bridged into one
+// higher-power output. Bridging needs an amplifier unit of Rev4 or later.
+func (p *HardwareProfile) CanBridge(zone int) bool {
+	for _, u := range p.Units {
+		if zone >= u.ZoneBase && zone < u.ZoneBase+u.ZoneCount {
+			return u.Rev4Plus
+		}
+	}
+	return false
+}
+
 // PrimaryUnitType returns the unit type of the first detected unit.
 func (p *HardwareProfile) PrimaryUnitType() UnitType {
 	if len(p.Units) == 0 {
@@ -155,12 +207,15 @@ func (p *HardwareProfile) PrimaryUnitType() UnitType {
 }
 
 // StreamAvailable returns true if the given stream type's binary is present.
-// RCA and Aux are always available (hardware passthrough).
+// RCA and Aux are always available (hardware passthrough) unless the
+// profile override disables the analog inputs.
 func (p *HardwareProfile) StreamAvailable(streamType string) bool {
 	// Hardware passthroughs are always available
 	if streamType == "rca" || streamType == "aux" {
-		return true
+		return !p.AnalogDisabled()
 	}
+	p.streamsMu.RLock()
+	defer p.streamsMu.RUnlock()
 	for _, s := range p.Streams {
 		if s.Type == streamType {
 			return s.Available
@@ -182,6 +237,8 @@ func (p *HardwareProfile) PhysicalOutputAvailable(physSrc int) bool {
 
 // AvailableStreamTypes returns a slice of stream types with binaries available on this hardware.
 func (p *HardwareProfile) AvailableStreamTypes() []string {
+	p.streamsMu.RLock()
+	defer p.streamsMu.RUnlock()
 	var types []string
 	for _, s := range p.Streams {
 		if s.Available {
@@ -195,8 +252,24 @@ func (p *HardwareProfile) AvailableStreamTypes() []string {
 // Must be called after Driver.Init() so unit detection is complete.
 func Detect(ctx context.Context, drv Driver) (*HardwareProfile, error) {
 	if !drv.IsReal() {
-		// Mock: return a sensible default profile for development
-		return MockProfile(), nil
+		// Mock: return a sensible default profile for development, with an
+		// expansion unit for every additional mock unit
+		p := MockProfile()
+		for _, idx := range drv.Units() {
+			if idx == 0 {
+				continue
+			}
+			p.Units = append(p.Units, UnitInfo{
+				Index:     idx,
+				I2CAddr:   uint8(unitAddr(idx)),
+				Board:     BoardInfo{UnitType: UnitTypeExpansion, BoardRev: "Rev4.A"},
+				ZoneBase:  idx * ZonesPerUnit,
+				ZoneCount: ZonesPerUnit,
+				Rev4Plus:  true,
+			})
+			p.TotalZones += ZonesPerUnit
+		}
+		return p, nil
 	}
 
 	p := &HardwareProfile{}
@@ -218,7 +291,7 @@ func Detect(ctx context.Context, drv Driver) (*HardwareProfile, error) {
 	// Sources: only main unit (UnitTypeMain) has analog/digital sources
 	for _, u := range p.Units {
 		if u.Board.UnitType == UnitTypeMain {
-			p.TotalSources = 4
+			p.TotalSources = SourcesPerUnit
 		}
 		if u.Board.UnitType == UnitTypeStreamer {
 			p.IsStreamer = true
@@ -237,17 +310,14 @@ func Detect(ctx context.Context, drv Driver) (*HardwareProfile, error) {
 
 	// Firmware version
 	if ver, err := drv.ReadVersion(ctx, 0); err == nil {
-		p.FirmwareVersion = fmt.Sprintf("%d.%d-%08x",
-			ver.Major, ver.Minor,
-			uint32(ver.GitHash[0])<<24|uint32(ver.GitHash[1])<<16|
-				uint32(ver.GitHash[2])<<8|uint32(ver.GitHash[3]))
+		p.FirmwareVersion = ver.String()
 	}
 
 	// Display detection
 	p.Display = detectDisplay()
 
 	// Stream capabilities
-	p.Streams = detectStreamCapabilities()
+	p.Streams = DetectStreamCapabilities()
 
 	// Physical output detection
 	p.AvailablePhysicalOutputs = detectPhysicalOutputs()
@@ -259,9 +329,9 @@ func Detect(ctx context.Context, drv Driver) (*HardwareProfile, error) {
 func detectUnit(ctx context.Context, drv Driver, idx int) (UnitInfo, error) {
 	info := UnitInfo{
 		Index:     idx,
-		I2CAddr:   uint8(0x08 + uint8(idx)*0x08),
-		ZoneBase:  idx * 6,
-		ZoneCount: 6,
+		I2CAddr:   uint8(unitAddr(idx)),
+		ZoneBase:  idx * ZonesPerUnit,
+		ZoneCount: ZonesPerUnit,
 	}
 
 	// Read EEPROM page 0 (board identity)
@@ -273,6 +343,7 @@ func detectUnit(ctx context.Context, drv Driver, idx int) (UnitInfo, error) {
 		// EEPROM read failed — safe fallback based on position in chain:
 		// Unit 0 is always the main unit (4 sources + 6 zones).
 		// Units 1+ are expanders (6 zones only, no analog sources).
+		info.EEPROMError = err.Error()
 		if idx == 0 {
 			slog.Warn("i2c: EEPROM unreadable on unit 0, assuming main unit (AP1_S4Z6)", "err", err)
 			info.Board = BoardInfo{UnitType: UnitTypeMain, BoardRev: "Rev?.?"}
@@ -286,6 +357,7 @@ func detectUnit(ctx context.Context, drv Driver, idx int) (UnitInfo, error) {
 		board, parseErr := ParseBoardInfo(data)
 		if parseErr != nil {
 			// Unprogrammed/invalid EEPROM — same position-based fallback
+			info.EEPROMError = parseErr.Error()
 			if idx == 0 {
 				info.Board = BoardInfo{UnitType: UnitTypeMain, BoardRev: "Rev?.?"}
 				info.HasAnalog = true
@@ -299,6 +371,10 @@ func detectUnit(ctx context.Context, drv Driver, idx int) (UnitInfo, error) {
 		}
 	}
 
+	if info.Board.UnitType == UnitTypeStreamer {
+		info.ZoneCount = 0 // no amplifiers
+	}
+
 	// Rev4Plus detection: bit 1 of REG_GIT_HASH_0_D (0xFF)
 	h0d, readErr := drv.Read(ctx, idx, RegGitHash0D)
 	if readErr == nil {
@@ -346,30 +422,14 @@ var streamBinaries = []struct {
 	{"aux", nil}, // always available (hardware passthrough)
 }
 
-// detectStreamCapabilities checks which stream types have their required binaries installed.
-func detectStreamCapabilities() []StreamCapability {
+// DetectStreamCapabilities checks which stream types have their required binaries installed.
+// Detect records the result at boot; diagnostics call it again to see the current state.
+func DetectStreamCapabilities() []StreamCapability {
 	caps := make([]StreamCapability, 0, len(streamBinaries))
 	for _, sb := range streamBinaries {
-		cap := StreamCapability{Type: sb.Type}
-		if sb.Bins == nil {
-			// Hardware passthrough — always available
-			cap.Available = true
-		} else {
-			for _, bin := range sb.Bins {
-				path, err := exec.LookPath(bin)
-				if err == nil {
-					cap.Available = true
-					cap.Binary = path
-					break
-				}
-			}
-			if !cap.Available {
-				cap.Reason = "binary not found"
-			}
-		}
-		caps = append(caps, cap)
+		caps = append(caps, lookBinaries(sb.Type, sb.Bins))
 	}
-	return caps
+	return append(caps, extraStreamCapabilities(false)...)
 }
 
 // detectPhysicalOutputs probes which ALSA physical output devices (ch0-ch3) exist.
@@ -419,6 +479,7 @@ func MockProfile() *HardwareProfile {
 			}(),
 		})
 	}
+	mockStreams = append(mockStreams, extraStreamCapabilities(true)...)
 
 	return &HardwareProfile{
 		Units: []UnitInfo{
@@ -2,6 +2,9 @@ package hardware_test
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 
 	"github.com/micro-nova/amplipi-go/internal/hardware"
@@ -147,6 +150,91 @@ func TestHardwareProfile_MultiUnit(t *testing.T) {
 	}
 }
 
+func TestApplyOverride(t *testing.T) {
+	units, analog := 3, false
+	p := hardware.MockProfile()
+	p.ApplyOverride(hardware.ProfileOverride{Units: &units, AnalogSources: &analog, UnavailableStreams: []string{"pandora"}})
+
+	if len(p.Units) != 3 || p.TotalZones != 18 || p.TotalSources != 4 {
+		t.Fatalf("units = %d, zones = %d, sources = %d; want 3, 18, 4", len(p.Units), p.TotalZones, p.TotalSources)
+	}
+	if u := p.Units[2]; u.ZoneBase != 12 || u.Board.UnitType != hardware.UnitTypeExpansion || u.EEPROMError == "" {
+		t.Errorf("added unit = %+v", u)
+	}
+	if p.Units[0].HasAnalog || p.StreamAvailable("rca") || p.StreamAvailable("aux") {
+		t.Error("analog inputs still available")
+	}
+	if !p.StreamDisabled("pandora") || p.StreamDisabled("airplay") {
+		t.Error("StreamDisabled does not follow the override")
+	}
+
+	// The driver takes the forced units.
+	mock := hardware.NewMock()
+	mock.SetUnits(p.UnitIndices())
+	if got := mock.Units(); !slices.Equal(got, []int{0, 1, 2}) {
+		t.Errorf("driver units = %v, want [0 1 2]", got)
+	}
+	if err := mock.SetZoneVol(context.Background(), 2, 0, -20); err != nil {
+		t.Errorf("write to a forced unit: %v", err)
+	}
+
+	// Fewer units than detected drops the rest.
+	units = 1
+	p.ApplyOverride(hardware.ProfileOverride{Units: &units})
+	if len(p.Units) != 1 || p.TotalZones != 6 {
+		t.Errorf("units = %d, zones = %d; want 1, 6", len(p.Units), p.TotalZones)
+	}
+	if mock.SetUnits(p.UnitIndices()); !slices.Equal(mock.Units(), []int{0}) {
+		t.Errorf("driver units = %v, want [0]", mock.Units())
+	}
+
+	for _, n := range []int{0, hardware.MaxUnits + 1} {
+		if err := (hardware.ProfileOverride{Units: &n}).Validate(); err == nil {
+			t.Errorf("Validate accepted %d units", n)
+		}
+	}
+}
+
+func TestDisableStreams(t *testing.T) {
+	p := hardware.MockProfile()
+	p.DisableStreams("audio stack not configured")
+	if p.StreamAvailable("pandora") || !p.StreamAvailable("rca") || !p.StreamAvailable("aux") {
+		t.Error("DisableStreams must disable every stream type but rca and aux")
+	}
+	for _, s := range p.Streams {
+		if s.Type == "pandora" && s.Reason != "audio stack not configured" {
+			t.Errorf("pandora reason = %q", s.Reason)
+		}
+	}
+}
+
+func TestRedetectStreams(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	p := &hardware.HardwareProfile{
+		Streams: []hardware.StreamCapability{
+			{Type: "pandora", Available: false, Reason: "binary not found"},
+		},
+	}
+	if p.RedetectStreams() {
+		t.Error("RedetectStreams reported a change with pianobar still missing")
+	}
+
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "pianobar"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin)
+	if !p.RedetectStreams() || !p.StreamAvailable("pandora") {
+		t.Error("pandora not available once pianobar is installed")
+	}
+
+	// Streams disabled for a missing audio stack stay disabled
+	p.DisableStreams("audio stack not configured")
+	if p.RedetectStreams() || p.StreamAvailable("pandora") {
+		t.Error("RedetectStreams re-enabled a disabled stream type")
+	}
+}
+
 func TestStreamAvailable_AlwaysAvailable(t *testing.T) {
 	// rca and aux always available even if not in Streams list
 	p := &hardware.HardwareProfile{Streams: []hardware.StreamCapability{}}
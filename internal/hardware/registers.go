@@ -13,7 +13,7 @@ const (
 	RegVolZone4   Register = 0x08
 	RegVolZone5   Register = 0x09
 	RegVolZone6   Register = 0x0A
-	RegPower      Register = 0x0B // Power rail status (read-only)
+	RegPower      Register = 0x0B // Power rail status (PwrReg); the rail enables are writable on Rev4+ units, see PowerEN9V
 	RegFans       Register = 0x0C // Fan control/status
 	RegLEDCtrl    Register = 0x0D // LED override enable
 	RegLEDVal     Register = 0x0E // LED state (zones[6:1], red, green)
@@ -27,7 +27,8 @@ const (
 	RegFanVolts   Register = 0x16 // Fan supply voltage UQ4.3
 	RegHV2Voltage Register = 0x17 // HV2 rail voltage UQ6.2
 	RegHV2Temp    Register = 0x18 // HV2 PSU temperature
-	// 0x19-0x1E reserved
+	RegInputSig   Register = 0x19 // Analog input signal detect (1 bit per RCA input, 1=signal), Rev4+ firmware only: the slot after REG_HV2_TEMP in ctrl_i2c.c's CmdReg, see ReadInputSignal
+	// 0x1A-0x1E reserved
 	RegEEPROMReq    Register = 0x1F // EEPROM control: [7:4]=page, [3:1]=addr, [0]=rd/wr_n
 	RegEEPROMData   Register = 0x20 // EEPROM data window (0x20-0x2F, 16 bytes)
 	RegEEPROMDataEnd Register = 0x2F
@@ -91,6 +92,20 @@ func TempToReg(tempC float32) byte {
 	return byte(v)
 }
 
+// FanDutyAuto written to RegFanDuty hands the fans back to the firmware's
+// own control.
+const FanDutyAuto byte = 0x00
+
+// FanDutyToReg encodes a fan duty (0-1) in the UQ1.7 format. Since 0 is
+// FanDutyAuto, a duty of 0 is written as the smallest step, which stops
+// the fans as well.
+func FanDutyToReg(duty float64) byte {
+	if duty >= 1 {
+		return 0x80
+	}
+	return byte(max(1, duty*128))
+}
+
 // VoltageFromReg decodes a voltage register value (UQ6.2 format, 0.25V resolution).
 func VoltageFromReg(reg byte) float32 {
 	return float32(reg) / 4.0
@@ -0,0 +1,287 @@
+package hardware
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+)
+
+// scenarioTick is how often a running scenario applies its steps and
+// moves its temperature ramps.
+const scenarioTick = 50 * time.Millisecond
+
+// roomTempC is where a ramp starts from a sensor that reads disconnected.
+const roomTempC = 25
+
+// mockOverTempC is the heatsink temperature at which the mock's firmware
+// reports over-temperature in RegFans.
+const mockOverTempC = 85
+
+// Scenario scripts hardware faults for the mock driver, so the thermal and
+// recovery code can be developed and demoed without hardware. It is read
+// from JSON with LoadScenario and played with Mock.RunScenario.
+type Scenario struct {
+	Name  string         `json:"name,omitempty"`
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep changes one unit's simulated hardware At seconds after the
+// scenario starts. Fields left out are left as they are.
+type ScenarioStep struct {
+	At   float64 `json:"at"`
+	Unit int     `json:"unit"`
+	Note string  `json:"note,omitempty"` // logged when the step is applied
+
+	// Temperatures in °C, reached over Ramp seconds from the reading when
+	// the step is applied. -999 disconnects a sensor and 999 shorts it,
+	// both at once.
+	Amp1C *float32 `json:"amp1_c,omitempty"`
+	Amp2C *float32 `json:"amp2_c,omitempty"`
+	PSU1C *float32 `json:"psu1_c,omitempty"`
+	PSU2C *float32 `json:"psu2_c,omitempty"`
+	Ramp  float64  `json:"ramp,omitempty"`
+
+	// Rails sets power good of the named rails (9v, 12v, 5vd, 5va): false
+	// fails an enabled rail, true restores it.
+	Rails map[string]bool `json:"rails,omitempty"`
+
+	// FanFail sets the fan failure flag of RegFans.
+	FanFail *bool `json:"fan_fail,omitempty"`
+
+	// NACKRate is the fraction of I2C transactions with the unit that are
+	// not acknowledged, 0 to 1; 1 takes the unit off the bus.
+	NACKRate *float64 `json:"nack_rate,omitempty"`
+}
+
+// scenarioRails are the power good bits of RegPower by rail name.
+var scenarioRails = map[string]byte{"9v": 1 << 0, "12v": 1 << 2, "5vd": 1 << 4, "5va": 1 << 5}
+
+// LoadScenario reads and validates a scenario file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var sc Scenario
+	if err := dec.Decode(&sc); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return &sc, nil
+}
+
+// Validate checks every step of the scenario.
+func (sc *Scenario) Validate() error {
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("no steps")
+	}
+	for i, st := range sc.Steps {
+		if st.At < 0 || st.Ramp < 0 {
+			return fmt.Errorf("step %d: at and ramp must not be negative", i)
+		}
+		if st.Unit < 0 || st.Unit >= MaxUnits {
+			return fmt.Errorf("step %d: unit %d out of range 0-%d", i, st.Unit, MaxUnits-1)
+		}
+		for _, t := range []*float32{st.Amp1C, st.Amp2C, st.PSU1C, st.PSU2C} {
+			if t != nil && *t != -999 && *t != 999 && (*t < 20 || *t > 147) {
+				return fmt.Errorf("step %d: temperature %g out of range 20-147 (or -999, 999)", i, *t)
+			}
+		}
+		for rail := range st.Rails {
+			if _, ok := scenarioRails[rail]; !ok {
+				return fmt.Errorf("step %d: unknown rail %q (want 9v, 12v, 5vd or 5va)", i, rail)
+			}
+		}
+		if st.NACKRate != nil && (*st.NACKRate < 0 || *st.NACKRate > 1) {
+			return fmt.Errorf("step %d: nack_rate %g out of range 0-1", i, *st.NACKRate)
+		}
+	}
+	return nil
+}
+
+// mockFaults are the faults a scenario has put on a unit.
+type mockFaults struct {
+	railsDown byte // power good bits of RegPower forced low
+	fanFail   bool
+	nackRate  float64
+}
+
+// tempRamp moves a temperature register from one value to another.
+type tempRamp struct {
+	unit       int
+	reg        Register
+	from, to   float32
+	start, end time.Duration
+}
+
+// RunScenario plays sc on the mock, applying each step at its time, and
+// returns once the last step and ramp are done or ctx is cancelled. The
+// faults stay in place afterwards.
+func (m *Mock) RunScenario(ctx context.Context, sc *Scenario) {
+	steps := slices.Clone(sc.Steps)
+	slices.SortStableFunc(steps, func(a, b ScenarioStep) int {
+		switch {
+		case a.At < b.At:
+			return -1
+		case a.At > b.At:
+			return 1
+		}
+		return 0
+	})
+	slog.Info("mock: running hardware scenario", "name", sc.Name, "steps", len(steps))
+
+	var ramps []tempRamp
+	start := time.Now()
+	ticker := time.NewTicker(scenarioTick)
+	defer ticker.Stop()
+	for {
+		elapsed := time.Since(start)
+		for len(steps) > 0 && secs(steps[0].At) <= elapsed {
+			ramps = append(ramps, m.applyStep(steps[0], elapsed)...)
+			steps = steps[1:]
+		}
+		ramps = m.moveRamps(ramps, elapsed)
+		if len(steps) == 0 && len(ramps) == 0 {
+			slog.Info("mock: hardware scenario done", "name", sc.Name)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// applyStep applies a scenario step at elapsed, returning the temperature
+// ramps it starts; a temperature set at once is a ramp that is already
+// over, so it ends any ramp of its register.
+func (m *Mock) applyStep(st ScenarioStep, elapsed time.Duration) []tempRamp {
+	slog.Info("mock: scenario step", "at", st.At, "unit", st.Unit, "note", st.Note)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	regs := m.getOrInit(st.Unit)
+	f := m.faultsOf(st.Unit)
+	for rail, good := range st.Rails {
+		if good {
+			f.railsDown &^= scenarioRails[rail]
+		} else {
+			f.railsDown |= scenarioRails[rail]
+		}
+	}
+	if st.FanFail != nil {
+		f.fanFail = *st.FanFail
+	}
+	if st.NACKRate != nil {
+		f.nackRate = *st.NACKRate
+	}
+
+	var ramps []tempRamp
+	for reg, t := range map[Register]*float32{
+		RegAmpTemp1: st.Amp1C, RegAmpTemp2: st.Amp2C, RegHV1Temp: st.PSU1C, RegHV2Temp: st.PSU2C,
+	} {
+		if t == nil {
+			continue
+		}
+		r := tempRamp{unit: st.Unit, reg: reg, from: *t, to: *t, start: elapsed, end: elapsed + secs(st.Ramp)}
+		if from := TempFromReg(regs[reg]); st.Ramp > 0 && *t != -999 && *t != 999 && from != 999 {
+			r.from = from
+			if from == -999 {
+				r.from = roomTempC
+			}
+		}
+		ramps = append(ramps, r)
+	}
+	return ramps
+}
+
+// moveRamps sets each ramp's register to its temperature at elapsed and
+// returns the ramps that have further to go. A later ramp of the same
+// register takes over from an earlier one.
+func (m *Mock) moveRamps(ramps []tempRamp, elapsed time.Duration) []tempRamp {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var next []tempRamp
+	for i, r := range ramps {
+		if slices.ContainsFunc(ramps[i+1:], func(o tempRamp) bool { return o.unit == r.unit && o.reg == r.reg }) {
+			continue
+		}
+		frac := float32(1)
+		if elapsed < r.end {
+			frac = float32(elapsed-r.start) / float32(r.end-r.start)
+		}
+		m.getOrInit(r.unit)[r.reg] = tempReg(r.from + (r.to-r.from)*frac)
+		if frac < 1 {
+			next = append(next, r)
+		}
+	}
+	return next
+}
+
+// tempReg encodes a temperature, with -999 and 999 as disconnected and
+// shorted.
+func tempReg(tempC float32) byte {
+	switch tempC {
+	case -999:
+		return 0x00
+	case 999:
+		return 0xFF
+	}
+	return max(1, TempToReg(tempC))
+}
+
+// faultsOf returns unit's faults. Must be called with m.mu held.
+func (m *Mock) faultsOf(unit int) *mockFaults {
+	if m.faults == nil {
+		m.faults = make(map[int]*mockFaults)
+	}
+	f, ok := m.faults[unit]
+	if !ok {
+		f = &mockFaults{}
+		m.faults[unit] = f
+	}
+	return f
+}
+
+// nack fails a transaction with unit at the unit's NACK rate. Must be
+// called with m.mu held.
+func (m *Mock) nack(unit int) error {
+	if f := m.faults[unit]; f != nil && f.nackRate > 0 && m.rand.Float64() < f.nackRate {
+		return ErrHardware(fmt.Sprintf("mock: unit %d did not acknowledge", unit))
+	}
+	return nil
+}
+
+// faultReg applies unit's faults to a register value read from it: failed
+// rails read not good and RegFans reports fan failure and the heatsinks
+// being over temperature. Must be called with m.mu held.
+func (m *Mock) faultReg(unit int, reg Register, val byte) byte {
+	f := m.faults[unit]
+	switch reg {
+	case RegPower:
+		if f != nil {
+			val &^= f.railsDown
+		}
+	case RegFans:
+		if f != nil && f.fanFail {
+			val |= 1 << 4
+		}
+		regs := m.regs[unit]
+		if hot := max(TempFromReg(regs[RegAmpTemp1]), TempFromReg(regs[RegAmpTemp2])); hot >= mockOverTempC && hot != 999 {
+			val |= 1 << 3
+		}
+	}
+	return val
+}
+
+// secs converts seconds to a duration.
+func secs(s float64) time.Duration { return time.Duration(s * float64(time.Second)) }
@@ -0,0 +1,27 @@
+package hardware
+
+import (
+	"context"
+	"fmt"
+)
+
+// ReadInputSignal reports which of a main unit's four RCA inputs currently
+// carry a signal. Only Rev4+ boards have the detector (UnitInfo.Rev4Plus);
+// older firmware reads back zero. Only the low four bits are defined, so a
+// value with any other bit set means the firmware doesn't implement the
+// register and is an error. The reading is instantaneous: callers debounce
+// it.
+func ReadInputSignal(ctx context.Context, drv Driver, unit int) ([4]bool, error) {
+	var sig [4]bool
+	val, err := drv.Read(ctx, unit, RegInputSig)
+	if err != nil {
+		return sig, err
+	}
+	if val&^0x0F != 0 {
+		return sig, fmt.Errorf("unit %d: input signal register reads %#02x, not an RCA signal bitmap", unit, val)
+	}
+	for i := range sig {
+		sig[i] = val&(1<<i) != 0
+	}
+	return sig, nil
+}
@@ -0,0 +1,139 @@
+package hardware
+
+import (
+	"os/exec"
+	"slices"
+	"sync"
+)
+
+// extraStreamType is a stream type registered with RegisterStreamType.
+type extraStreamType struct {
+	typ    string
+	bins   []string
+	detect func() StreamCapability
+}
+
+var (
+	extraStreamsMu sync.RWMutex
+	extraStreams   []extraStreamType
+)
+
+// RegisterStreamType adds a stream type not built into this package, e.g.
+// one a plugin provides, to DetectStreamCapabilities. Its capability is
+// detect's result if detect is set, else whether one of bins is in PATH;
+// with neither it is always available. Types must be registered before
+// Detect runs for HardwareProfile.Streams to include them.
+func RegisterStreamType(typ string, bins []string, detect func() StreamCapability) {
+	extraStreamsMu.Lock()
+	defer extraStreamsMu.Unlock()
+	extraStreams = append(extraStreams, extraStreamType{typ: typ, bins: bins, detect: detect})
+}
+
+// lookBinaries searches PATH for the first of bins, in order of
+// preference. A type without binaries is a hardware passthrough and always
+// available.
+func lookBinaries(typ string, bins []string) StreamCapability {
+	cap := StreamCapability{Type: typ}
+	if len(bins) == 0 {
+		cap.Available = true
+		return cap
+	}
+	for _, bin := range bins {
+		if path, err := exec.LookPath(bin); err == nil {
+			cap.Available = true
+			cap.Binary = path
+			return cap
+		}
+	}
+	cap.Reason = "binary not found"
+	return cap
+}
+
+// extraStreamCapabilities detects the registered stream types. The mock
+// reports them all available, as it does the built-in ones.
+func extraStreamCapabilities(mock bool) []StreamCapability {
+	extraStreamsMu.RLock()
+	defer extraStreamsMu.RUnlock()
+	caps := make([]StreamCapability, 0, len(extraStreams))
+	for _, st := range extraStreams {
+		switch {
+		case mock:
+			caps = append(caps, StreamCapability{Type: st.typ, Available: true})
+		case st.detect != nil:
+			cap := st.detect()
+			cap.Type = st.typ
+			caps = append(caps, cap)
+		default:
+			caps = append(caps, lookBinaries(st.typ, st.bins))
+		}
+	}
+	return caps
+}
+
+// detectStreamType detects the capability of one stream type, built in or
+// registered, and reports whether the type is known.
+func detectStreamType(typ string) (StreamCapability, bool) {
+	for _, sb := range streamBinaries {
+		if sb.Type == typ {
+			return lookBinaries(typ, sb.Bins), true
+		}
+	}
+	extraStreamsMu.RLock()
+	defer extraStreamsMu.RUnlock()
+	for _, st := range extraStreams {
+		if st.typ != typ {
+			continue
+		}
+		if st.detect == nil {
+			return lookBinaries(typ, st.bins), true
+		}
+		cap := st.detect()
+		cap.Type = typ
+		return cap, true
+	}
+	return StreamCapability{}, false
+}
+
+// StreamCapabilities returns a copy of p.Streams.
+func (p *HardwareProfile) StreamCapabilities() []StreamCapability {
+	p.streamsMu.RLock()
+	defer p.streamsMu.RUnlock()
+	return slices.Clone(p.Streams)
+}
+
+// RedetectStreams detects the stream types that were unavailable again,
+// e.g. after a missing binary has been installed, and reports whether any
+// became available. Types DisableStreams disabled stay unavailable.
+func (p *HardwareProfile) RedetectStreams() bool {
+	p.streamsMu.Lock()
+	defer p.streamsMu.Unlock()
+	if p.streamsOff {
+		return false
+	}
+	changed := false
+	for i := range p.Streams {
+		s := &p.Streams[i]
+		if s.Available {
+			continue
+		}
+		if cap, ok := detectStreamType(s.Type); ok && cap.Available {
+			*s = cap
+			changed = true
+		}
+	}
+	return changed
+}
+
+// DisableStreams marks every stream type except the hardware passthroughs
+// (RCA and aux) unavailable with reason, for when the audio stack streams
+// play through is missing.
+func (p *HardwareProfile) DisableStreams(reason string) {
+	p.streamsMu.Lock()
+	defer p.streamsMu.Unlock()
+	p.streamsOff = true
+	for i := range p.Streams {
+		if s := &p.Streams[i]; s.Type != "rca" && s.Type != "aux" {
+			s.Available, s.Reason = false, reason
+		}
+	}
+}
@@ -0,0 +1,260 @@
+// Package homeassistant serves the Home Assistant AmpliPi integration: a
+// discovery document listing one media_player entity per source, zone and
+// group, their states in Home Assistant terms, and media_player service
+// calls translated to controller actions, including announcements from the
+// tts service.
+package homeassistant
+
+import (
+	"fmt"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Entity kinds.
+const (
+	KindSource = "source"
+	KindZone   = "zone"
+	KindGroup  = "group"
+)
+
+// Media player states.
+const (
+	StatePlaying = "playing"
+	StatePaused  = "paused"
+	StateIdle    = "idle"
+	StateOff     = "off"
+)
+
+// sourceNone is the source_list entry that disconnects a source.
+const sourceNone = "None"
+
+// Discovery describes this unit and its entities for the integration's
+// config flow.
+type Discovery struct {
+	UniqueID     string   `json:"unique_id"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model"`
+	SWVersion    string   `json:"sw_version"`
+	API          string   `json:"api"`      // REST API root
+	Events       string   `json:"events"`   // SSE state updates
+	States       string   `json:"states"`   // entity states
+	Services     string   `json:"services"` // service calls: <services>/<unique_id>/<service>
+	Entities     []Entity `json:"entities"`
+}
+
+// Entity is one media_player entity.
+type Entity struct {
+	UniqueID string   `json:"unique_id"`
+	Platform string   `json:"platform"` // always "media_player"
+	Kind     string   `json:"kind"`     // "source" | "zone" | "group"
+	ID       int      `json:"id"`       // source, zone or group ID
+	Name     string   `json:"name"`
+	Resource string   `json:"resource"` // REST resource, e.g. /api/zones/3
+	Features []string `json:"supported_features"`
+}
+
+// EntityState is an entity's state and attributes in Home Assistant terms.
+type EntityState struct {
+	UniqueID   string     `json:"unique_id"`
+	State      string     `json:"state"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// Attributes are the media_player attributes of an entity.
+type Attributes struct {
+	VolumeLevel    *float64 `json:"volume_level,omitempty"`
+	IsVolumeMuted  *bool    `json:"is_volume_muted,omitempty"`
+	Source         string   `json:"source,omitempty"`
+	SourceList     []string `json:"source_list"`
+	MediaTitle     string   `json:"media_title,omitempty"`
+	MediaArtist    string   `json:"media_artist,omitempty"`
+	MediaAlbumName string   `json:"media_album_name,omitempty"`
+	EntityPicture  string   `json:"entity_picture,omitempty"`
+	StreamID       *int     `json:"stream_id,omitempty"` // stream playing, if any
+}
+
+var (
+	sourceFeatures = []string{"turn_off", "select_source", "play", "pause", "stop", "next_track", "previous_track", "play_media"}
+	zoneFeatures   = []string{"turn_on", "turn_off", "volume_set", "volume_mute", "volume_step", "select_source", "play_media"}
+)
+
+// UniqueID returns the unique ID of an entity.
+func UniqueID(unit, kind string, id int) string {
+	return fmt.Sprintf("%s_%s_%d", unit, kind, id)
+}
+
+// NewDiscovery builds the discovery document. unit is the unit's unique
+// ID (e.g. its hostname), which prefixes the entity IDs.
+func NewDiscovery(unit string, s *models.State) Discovery {
+	d := Discovery{
+		UniqueID:     unit,
+		Name:         "AmpliPi",
+		Manufacturer: "MicroNova",
+		Model:        "AmpliPi",
+		SWVersion:    s.Info.Version,
+		API:          "/api",
+		Events:       "/api/subscribe",
+		States:       "/api/ha/states",
+		Services:     "/api/ha/services",
+		Entities:     []Entity{},
+	}
+	if s.Info.Streamer {
+		d.Model = "AmpliPi Streamer"
+	}
+	for _, src := range s.Sources {
+		d.Entities = append(d.Entities, Entity{
+			UniqueID: UniqueID(unit, KindSource, src.ID), Platform: "media_player", Kind: KindSource,
+			ID: src.ID, Name: src.Name, Resource: fmt.Sprintf("/api/sources/%d", src.ID), Features: sourceFeatures,
+		})
+	}
+	for _, z := range s.Zones {
+		if z.Disabled {
+			continue
+		}
+		d.Entities = append(d.Entities, Entity{
+			UniqueID: UniqueID(unit, KindZone, z.ID), Platform: "media_player", Kind: KindZone,
+			ID: z.ID, Name: z.Name, Resource: fmt.Sprintf("/api/zones/%d", z.ID), Features: zoneFeatures,
+		})
+	}
+	for _, g := range s.Groups {
+		d.Entities = append(d.Entities, Entity{
+			UniqueID: UniqueID(unit, KindGroup, g.ID), Platform: "media_player", Kind: KindGroup,
+			ID: g.ID, Name: g.Name, Resource: fmt.Sprintf("/api/groups/%d", g.ID), Features: zoneFeatures,
+		})
+	}
+	return d
+}
+
+// States maps the system state onto the entities of NewDiscovery.
+func States(unit string, s *models.State) []EntityState {
+	var out []EntityState
+	for _, src := range s.Sources {
+		st := EntityState{UniqueID: UniqueID(unit, KindSource, src.ID), Attributes: Attributes{SourceList: streamList(s)}}
+		st.State = sourceState(s, &src)
+		if stream := sourceStream(s, &src); stream != nil {
+			st.Attributes.Source = stream.Name
+			setMedia(&st.Attributes, stream)
+		} else {
+			st.Attributes.Source = sourceNone
+		}
+		out = append(out, st)
+	}
+	for _, z := range s.Zones {
+		if z.Disabled {
+			continue
+		}
+		volF, mute := z.VolF, z.Mute
+		st := EntityState{
+			UniqueID:   UniqueID(unit, KindZone, z.ID),
+			Attributes: Attributes{VolumeLevel: &volF, IsVolumeMuted: &mute, SourceList: sourceList(s)},
+		}
+		st.State = zoneState(s, z.SourceID, z.Mute, &st.Attributes)
+		out = append(out, st)
+	}
+	for _, g := range s.Groups {
+		st := EntityState{UniqueID: UniqueID(unit, KindGroup, g.ID), Attributes: Attributes{SourceList: sourceList(s)}}
+		if g.VolF != nil {
+			v := *g.VolF
+			st.Attributes.VolumeLevel = &v
+		}
+		mute := g.Mute != nil && *g.Mute
+		st.Attributes.IsVolumeMuted = &mute
+		if g.SourceID != nil {
+			st.State = zoneState(s, *g.SourceID, mute, &st.Attributes)
+		} else {
+			st.State = StateIdle // members on different sources
+			if mute {
+				st.State = StateOff
+			}
+		}
+		out = append(out, st)
+	}
+	return out
+}
+
+// zoneState returns the state of a zone (or group) playing sourceID and
+// fills in the source and media attributes.
+func zoneState(s *models.State, sourceID int, mute bool, attrs *Attributes) string {
+	var src *models.Source
+	for i := range s.Sources {
+		if s.Sources[i].ID == sourceID {
+			src = &s.Sources[i]
+		}
+	}
+	if src == nil {
+		return StateOff
+	}
+	attrs.Source = src.Name
+	if stream := sourceStream(s, src); stream != nil {
+		setMedia(attrs, stream)
+	}
+	if mute {
+		return StateOff
+	}
+	return sourceState(s, src)
+}
+
+// sourceState returns "off" for a source without input, else the playback
+// state of its stream.
+func sourceState(s *models.State, src *models.Source) string {
+	if src.Input == "" {
+		return StateOff
+	}
+	stream := sourceStream(s, src)
+	if stream == nil {
+		return StateIdle // e.g. the local RCA input
+	}
+	switch stream.Info.State {
+	case "playing":
+		return StatePlaying
+	case "paused":
+		return StatePaused
+	}
+	return StateIdle
+}
+
+func setMedia(attrs *Attributes, stream *models.Stream) {
+	id := stream.ID
+	attrs.StreamID = &id
+	attrs.MediaTitle = stream.Info.Track
+	attrs.MediaArtist = stream.Info.Artist
+	attrs.MediaAlbumName = stream.Info.Album
+	attrs.EntityPicture = stream.Info.ImageURL
+}
+
+// sourceStream returns the stream a source's input selects, if any.
+func sourceStream(s *models.State, src *models.Source) *models.Stream {
+	var id int
+	if _, err := fmt.Sscanf(src.Input, "stream=%d", &id); err != nil {
+		return nil
+	}
+	for i := range s.Streams {
+		if s.Streams[i].ID == id {
+			return &s.Streams[i]
+		}
+	}
+	return nil
+}
+
+// sourceList is the source_list of zone and group entities.
+func sourceList(s *models.State) []string {
+	names := make([]string, 0, len(s.Sources))
+	for _, src := range s.Sources {
+		names = append(names, src.Name)
+	}
+	return names
+}
+
+// streamList is the source_list of source entities: the enabled streams,
+// then "None" to disconnect.
+func streamList(s *models.State) []string {
+	names := make([]string, 0, len(s.Streams)+1)
+	for _, st := range s.Streams {
+		if st.Disabled == nil || !*st.Disabled {
+			names = append(names, st.Name)
+		}
+	}
+	return append(names, sourceNone)
+}
@@ -0,0 +1,103 @@
+package homeassistant_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/homeassistant"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+const unit = "amplipi_test"
+
+func TestDiscovery(t *testing.T) {
+	s := models.DefaultState()
+	d := homeassistant.NewDiscovery(unit, &s)
+	kinds := make(map[string]int)
+	for _, e := range d.Entities {
+		kinds[e.Kind]++
+		if e.Platform != "media_player" {
+			t.Errorf("%s: platform %q", e.UniqueID, e.Platform)
+		}
+	}
+	if kinds[homeassistant.KindSource] != len(s.Sources) || kinds[homeassistant.KindZone] != len(s.Zones) {
+		t.Errorf("entities per kind = %v", kinds)
+	}
+	if got := homeassistant.UniqueID(unit, homeassistant.KindZone, 3); got != "amplipi_test_zone_3" {
+		t.Errorf("UniqueID = %q", got)
+	}
+}
+
+func TestStates(t *testing.T) {
+	s := models.DefaultState()
+	s.Streams = append(s.Streams, models.Stream{ID: 1001, Name: "Radio", Type: "internet_radio",
+		Info: models.StreamInfo{State: "playing", Track: "Song", Artist: "Band"}})
+	s.Sources[0].Input = "stream=1001"
+	s.Zones[0].SourceID, s.Zones[0].Mute, s.Zones[0].VolF = 0, false, 0.4
+	s.Zones[1].SourceID, s.Zones[1].Mute = 0, true
+
+	states := make(map[string]homeassistant.EntityState)
+	for _, st := range homeassistant.States(unit, &s) {
+		states[st.UniqueID] = st
+	}
+	src := states[homeassistant.UniqueID(unit, homeassistant.KindSource, 0)]
+	if src.State != homeassistant.StatePlaying || src.Attributes.Source != "Radio" || src.Attributes.MediaTitle != "Song" {
+		t.Errorf("source 0 = %+v", src)
+	}
+	if st := states[homeassistant.UniqueID(unit, homeassistant.KindSource, 1)]; st.State != homeassistant.StateOff {
+		t.Errorf("source without input = %q, want off", st.State)
+	}
+	z0 := states[homeassistant.UniqueID(unit, homeassistant.KindZone, 0)]
+	if z0.State != homeassistant.StatePlaying || *z0.Attributes.VolumeLevel != 0.4 || z0.Attributes.MediaArtist != "Band" {
+		t.Errorf("zone 0 = %+v", z0)
+	}
+	if st := states[homeassistant.UniqueID(unit, homeassistant.KindZone, 1)]; st.State != homeassistant.StateOff {
+		t.Errorf("muted zone = %q, want off", st.State)
+	}
+}
+
+func TestCall(t *testing.T) {
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), events.NewBus(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	zone2 := homeassistant.UniqueID(unit, homeassistant.KindZone, 2)
+
+	vol := 0.25
+	state, appErr := homeassistant.Call(ctx, ctrl, unit, zone2, "volume_set", homeassistant.ServiceData{VolumeLevel: &vol})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	if state.Zones[2].VolF != 0.25 {
+		t.Errorf("vol_f = %v, want 0.25", state.Zones[2].VolF)
+	}
+	state, _ = homeassistant.Call(ctx, ctrl, unit, zone2, "turn_on", homeassistant.ServiceData{})
+	if state.Zones[2].Mute {
+		t.Error("turn_on did not unmute")
+	}
+	state, appErr = homeassistant.Call(ctx, ctrl, unit, zone2, "select_source", homeassistant.ServiceData{Source: state.Sources[3].Name})
+	if appErr != nil || state.Zones[2].SourceID != 3 {
+		t.Errorf("select_source: %v, source_id = %d", appErr, state.Zones[2].SourceID)
+	}
+
+	for _, tc := range []struct {
+		entity, service string
+		data            homeassistant.ServiceData
+		status          int
+	}{
+		{"other_zone_2", "turn_on", homeassistant.ServiceData{}, 404},
+		{zone2, "volume_set", homeassistant.ServiceData{}, 400},
+		{zone2, "select_source", homeassistant.ServiceData{Source: "nope"}, 400},
+		{zone2, "play_media", homeassistant.ServiceData{MediaContentID: "media-source://tts"}, 400},
+		{homeassistant.UniqueID(unit, homeassistant.KindSource, 0), "volume_set", homeassistant.ServiceData{}, 400},
+	} {
+		if _, appErr := homeassistant.Call(ctx, ctrl, unit, tc.entity, tc.service, tc.data); appErr == nil || appErr.Status != tc.status {
+			t.Errorf("%s %s: %v, want status %d", tc.entity, tc.service, appErr, tc.status)
+		}
+	}
+}
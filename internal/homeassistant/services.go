@@ -0,0 +1,222 @@
+package homeassistant
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Controller is the part of the controller that service calls drive.
+type Controller interface {
+	State() models.State
+	SetSource(ctx context.Context, id int, upd models.SourceUpdate) (models.State, *models.AppError)
+	SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError)
+	SetGroup(ctx context.Context, id int, upd models.GroupUpdate) (models.State, *models.AppError)
+	ExecStreamCommand(ctx context.Context, id int, cmd string) (models.State, *models.AppError)
+	Announce(ctx context.Context, req models.AnnounceRequest) (models.State, *models.AppError)
+}
+
+// ServiceData is the data of a media_player service call, as Home
+// Assistant passes it.
+type ServiceData struct {
+	VolumeLevel      *float64 `json:"volume_level,omitempty"`
+	IsVolumeMuted    *bool    `json:"is_volume_muted,omitempty"`
+	Source           string   `json:"source,omitempty"`
+	MediaContentID   string   `json:"media_content_id,omitempty"`
+	MediaContentType string   `json:"media_content_type,omitempty"`
+}
+
+// streamCommands maps media_player services to stream commands.
+var streamCommands = map[string]string{
+	"media_play":           "play",
+	"media_pause":          "pause",
+	"media_stop":           "stop",
+	"media_next_track":     "next",
+	"media_previous_track": "prev",
+}
+
+// Call performs a media_player service on the entity uniqueID of unit.
+// play_media makes an announcement: this is how the tts service speaks on
+// AmpliPi zones, with the media URL Home Assistant resolved.
+func Call(ctx context.Context, ctrl Controller, unit, uniqueID, service string, data ServiceData) (models.State, *models.AppError) {
+	kind, id, ok := parseUniqueID(unit, uniqueID)
+	if !ok {
+		return models.State{}, models.ErrNotFound(fmt.Sprintf("unknown entity %q", uniqueID))
+	}
+	state := ctrl.State()
+
+	if cmd, ok := streamCommands[service]; ok {
+		streamID, appErr := entityStream(&state, kind, id)
+		if appErr != nil {
+			return models.State{}, appErr
+		}
+		return ctrl.ExecStreamCommand(ctx, streamID, cmd)
+	}
+
+	switch service {
+	case "play_media":
+		return playMedia(ctx, ctrl, &state, kind, id, data)
+	case "select_source":
+		return selectSource(ctx, ctrl, &state, kind, id, data.Source)
+	}
+
+	if kind == KindSource {
+		if service != "turn_off" {
+			return models.State{}, models.ErrBadRequest(fmt.Sprintf("%s is not supported on source entities", service))
+		}
+		none := ""
+		return ctrl.SetSource(ctx, id, models.SourceUpdate{Input: &none})
+	}
+
+	// Zones and groups take the same volume and mute updates.
+	var mute *bool
+	var volF, volDeltaF *float64
+	switch service {
+	case "turn_on", "turn_off":
+		m := service == "turn_off"
+		mute = &m
+	case "volume_mute":
+		if data.IsVolumeMuted == nil {
+			return models.State{}, models.ErrBadRequest("is_volume_muted is required")
+		}
+		mute = data.IsVolumeMuted
+	case "volume_set":
+		if data.VolumeLevel == nil || *data.VolumeLevel < 0 || *data.VolumeLevel > 1 {
+			return models.State{}, models.ErrBadRequest("volume_level must be between 0.0 and 1.0")
+		}
+		volF = data.VolumeLevel
+	case "volume_up", "volume_down":
+		d := models.DefaultVolStepF
+		if service == "volume_down" {
+			d = -d
+		}
+		volDeltaF = &d
+	default:
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("unsupported service %q", service))
+	}
+	if kind == KindGroup {
+		return ctrl.SetGroup(ctx, id, models.GroupUpdate{Mute: mute, VolF: volF, VolDeltaF: volDeltaF})
+	}
+	return ctrl.SetZone(ctx, id, models.ZoneUpdate{Mute: mute, VolF: volF, VolDeltaF: volDeltaF})
+}
+
+// selectSource connects a zone or group to the source named name, or a
+// source to the stream named name ("None" disconnects it).
+func selectSource(ctx context.Context, ctrl Controller, s *models.State, kind string, id int, name string) (models.State, *models.AppError) {
+	if kind == KindSource {
+		input := ""
+		if name != sourceNone {
+			stream := findByName(s.Streams, name, func(st models.Stream) string { return st.Name })
+			if stream == nil {
+				return models.State{}, models.ErrBadRequest(fmt.Sprintf("no stream named %q", name))
+			}
+			input = fmt.Sprintf("stream=%d", stream.ID)
+		}
+		return ctrl.SetSource(ctx, id, models.SourceUpdate{Input: &input})
+	}
+	src := findByName(s.Sources, name, func(src models.Source) string { return src.Name })
+	if src == nil {
+		return models.State{}, models.ErrBadRequest(fmt.Sprintf("no source named %q", name))
+	}
+	sourceID := src.ID
+	if kind == KindGroup {
+		return ctrl.SetGroup(ctx, id, models.GroupUpdate{SourceID: &sourceID})
+	}
+	return ctrl.SetZone(ctx, id, models.ZoneUpdate{SourceID: &sourceID})
+}
+
+// playMedia announces a media URL or library clip on a zone, a group, or the zones
+// playing a source.
+func playMedia(ctx context.Context, ctrl Controller, s *models.State, kind string, id int, data ServiceData) (models.State, *models.AppError) {
+	_, clip := models.ClipName(data.MediaContentID)
+	if !clip && !strings.HasPrefix(data.MediaContentID, "http://") && !strings.HasPrefix(data.MediaContentID, "https://") {
+		return models.State{}, models.ErrBadRequest("media_content_id must be an http(s) URL or clip:<name>")
+	}
+	req := models.AnnounceRequest{Media: data.MediaContentID}
+	switch kind {
+	case KindZone:
+		req.Zones = []int{id}
+	case KindGroup:
+		req.Groups = []int{id}
+	case KindSource:
+		for _, z := range s.Zones {
+			if z.SourceID == id && !z.Mute && !z.Disabled {
+				req.Zones = append(req.Zones, z.ID)
+			}
+		}
+		if len(req.Zones) == 0 {
+			return models.State{}, models.ErrBadRequest("no zones are playing this source")
+		}
+	}
+	return ctrl.Announce(ctx, req)
+}
+
+// entityStream returns the stream an entity is playing.
+func entityStream(s *models.State, kind string, id int) (int, *models.AppError) {
+	sourceID := id
+	switch kind {
+	case KindZone:
+		z := findByID(s.Zones, id, func(z models.Zone) int { return z.ID })
+		if z == nil {
+			return 0, models.ErrNotFound("zone not found")
+		}
+		sourceID = z.SourceID
+	case KindGroup:
+		g := findByID(s.Groups, id, func(g models.Group) int { return g.ID })
+		if g == nil || g.SourceID == nil {
+			return 0, models.ErrBadRequest("group members are not on one source")
+		}
+		sourceID = *g.SourceID
+	}
+	src := findByID(s.Sources, sourceID, func(src models.Source) int { return src.ID })
+	if src == nil {
+		return 0, models.ErrNotFound("source not found")
+	}
+	stream := sourceStream(s, src)
+	if stream == nil {
+		return 0, models.ErrBadRequest("no stream is playing")
+	}
+	return stream.ID, nil
+}
+
+// parseUniqueID splits "<unit>_<kind>_<id>".
+func parseUniqueID(unit, uniqueID string) (kind string, id int, ok bool) {
+	rest, ok := strings.CutPrefix(uniqueID, unit+"_")
+	if !ok {
+		return "", 0, false
+	}
+	kind, idStr, ok := strings.Cut(rest, "_")
+	if !ok {
+		return "", 0, false
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		return "", 0, false
+	}
+	switch kind {
+	case KindSource, KindZone, KindGroup:
+		return kind, id, true
+	}
+	return "", 0, false
+}
+
+func findByName[T any](items []T, name string, nameOf func(T) string) *T {
+	for i := range items {
+		if nameOf(items[i]) == name {
+			return &items[i]
+		}
+	}
+	return nil
+}
+
+func findByID[T any](items []T, id int, idOf func(T) int) *T {
+	for i := range items {
+		if idOf(items[i]) == id {
+			return &items[i]
+		}
+	}
+	return nil
+}
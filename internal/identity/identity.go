@@ -3,6 +3,8 @@ package identity
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -29,6 +31,33 @@ func GetHostname() string {
 	return h
 }
 
+// unitIDFile holds the unit ID in the config dir.
+const unitIDFile = "unit-id"
+
+// UnitID returns the unit's ID, which integrations such as Home Assistant
+// key their entities by. It is read from configDir, or created there on
+// first use from the hostname, so entities made before it was kept keep
+// their IDs and renaming the unit doesn't change it.
+func UnitID(configDir string) (string, error) {
+	path := filepath.Join(configDir, unitIDFile)
+	data, err := os.ReadFile(path)
+	if err == nil {
+		id := strings.TrimSpace(string(data))
+		if id == "" {
+			return "", fmt.Errorf("unit id %s is empty", path)
+		}
+		return id, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+	id := "amplipi_" + GetHostname()
+	if err := os.WriteFile(path, []byte(id+"\n"), 0644); err != nil {
+		return "", fmt.Errorf("saving the unit id: %w", err)
+	}
+	return id, nil
+}
+
 // GetVersion reads the version from ~/.config/amplipi/metadata.json.
 // Falls back to DefaultVersion if the file is missing or unreadable.
 func GetVersion() string {
@@ -88,3 +88,24 @@ func TestIsUpdateMode_WithFlag(t *testing.T) {
 		t.Error("IsUpdateMode() = false; want true when flag file exists")
 	}
 }
+
+func TestUnitID(t *testing.T) {
+	dir := t.TempDir()
+	first, err := identity.UnitID(dir)
+	if err != nil || first != "amplipi_"+identity.GetHostname() {
+		t.Fatalf("UnitID = %q, %v; want one from the hostname", first, err)
+	}
+	// Kept in the config dir, so a later rename doesn't change it.
+	if err := os.WriteFile(filepath.Join(dir, "unit-id"), []byte("amplipi_kitchen\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := identity.UnitID(dir); err != nil || id != "amplipi_kitchen" {
+		t.Errorf("UnitID after restart = %q, %v; want amplipi_kitchen", id, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "unit-id"), []byte("\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := identity.UnitID(dir); err == nil {
+		t.Error("UnitID accepted an empty file")
+	}
+}
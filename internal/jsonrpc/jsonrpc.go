@@ -0,0 +1,382 @@
+// Package jsonrpc serves a persistent TCP control channel for control
+// processors such as Control4 and Crestron, whose drivers prefer one
+// connection to polling the REST API. Clients send JSON-RPC 2.0 requests,
+// one per line, and get replies and event notifications on the same
+// socket:
+//
+//	-> {"jsonrpc":"2.0","id":1,"method":"set_zone","params":{"id":2,"vol":-30}}
+//	<- {"jsonrpc":"2.0","id":1,"result":{...state...}}
+//	<- {"jsonrpc":"2.0","method":"event","params":{"type":"zone_changed",...}}
+//
+// The methods mirror the REST API; see methods. Every event of
+// /api/subscribe is sent as an "event" notification, and after
+// subscribe {"state":true} every new state as a "state" notification.
+// Unless the unit has no passwords set, a client must first send auth
+// {"key":"..."} with an API key. A key restricted to some zones and groups
+// may only read and control them, as over HTTP.
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// maxLine bounds a request.
+const maxLine = 1 << 20
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParse          = -32700
+	codeInvalidRequest = -32600
+	codeNoMethod       = -32601
+	codeInvalidParams  = -32602
+	codeServer         = -32000 // an API error; data is the REST error body
+	codeUnauthorized   = -32001
+)
+
+// Controller is the part of the controller the methods call.
+type Controller interface {
+	State() models.State
+	StreamerMode() bool
+	SetSource(ctx context.Context, id int, upd models.SourceUpdate) (models.State, *models.AppError)
+	SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError)
+	SetZones(ctx context.Context, req models.MultiZoneUpdate) (models.State, *models.AppError)
+	SetGroup(ctx context.Context, id int, upd models.GroupUpdate) (models.State, *models.AppError)
+	ExecStreamCommand(ctx context.Context, id int, cmd string) (models.State, *models.AppError)
+	LoadPresetWithReport(ctx context.Context, id int) (models.State, models.PresetReport, *models.AppError)
+	Announce(ctx context.Context, req models.AnnounceRequest) (models.State, *models.AppError)
+}
+
+// EventBus delivers the states and events pushed to clients.
+type EventBus interface {
+	Subscribe(id string) <-chan models.State
+	Unsubscribe(id string)
+	SubscribeEvents(id string) <-chan models.Event
+	UnsubscribeEvents(id string)
+}
+
+// Auth checks API keys.
+type Auth interface {
+	IsOpenMode() bool
+	KeyAccess(key string) (admin bool, sc *auth.Scope, ok bool)
+}
+
+// Server answers JSON-RPC clients.
+type Server struct {
+	ctrl Controller
+	bus  EventBus
+	auth Auth
+}
+
+// New creates a server.
+func New(ctrl Controller, bus EventBus, auth Auth) *Server {
+	return &Server{ctrl: ctrl, bus: bus, auth: auth}
+}
+
+// Serve accepts clients on ln until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("jsonrpc: accept failed", "err", err)
+			}
+			return
+		}
+		go s.serveConn(ctx, nc)
+	}
+}
+
+// request is a JSON-RPC request or notification (no id).
+type request struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type notification struct {
+	Version string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *rpcError) Error() string { return e.Message }
+
+// conn is a connected client.
+type conn struct {
+	nc     net.Conn
+	wmu    sync.Mutex             // serializes writes of replies and notifications
+	key    atomic.Pointer[string] // API key signed in with; nil before auth
+	states atomic.Bool            // send state notifications
+}
+
+func (c *conn) send(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		slog.Error("jsonrpc: encode failed", "err", err)
+		return
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, _ = c.nc.Write(append(data, '\n'))
+}
+
+func (s *Server) serveConn(ctx context.Context, nc net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer nc.Close()
+	remote := nc.RemoteAddr().String()
+	slog.Info("jsonrpc: client connected", "remote", remote)
+	defer slog.Info("jsonrpc: client disconnected", "remote", remote)
+
+	c := &conn{nc: nc}
+	// Subscribed before the first request, so its changes are pushed.
+	id := "jsonrpc-" + uuid.New().String()
+	states := s.bus.Subscribe(id)
+	defer s.bus.Unsubscribe(id)
+	evs := s.bus.SubscribeEvents(id)
+	defer s.bus.UnsubscribeEvents(id)
+	go s.push(ctx, c, states, evs)
+
+	sc := bufio.NewScanner(nc)
+	sc.Buffer(make([]byte, 0, 4096), maxLine)
+	for sc.Scan() {
+		line := sc.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var req request
+		if err := json.Unmarshal(line, &req); err != nil {
+			c.send(response{Version: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: codeParse, Message: "parse error: " + err.Error()}})
+			continue
+		}
+		result, err := s.call(ctx, c, req)
+		if req.ID == nil {
+			continue // a notification: no reply
+		}
+		resp := response{Version: "2.0", ID: req.ID, Result: result}
+		if err != nil {
+			var re *rpcError
+			if !errors.As(err, &re) {
+				re = &rpcError{Code: codeServer, Message: err.Error()}
+			}
+			resp.Result, resp.Error = nil, re
+		}
+		c.send(resp)
+	}
+	if err := sc.Err(); err != nil && ctx.Err() == nil {
+		slog.Warn("jsonrpc: read failed", "remote", remote, "err", err)
+	}
+}
+
+// push sends the client events, and states if it subscribed to them,
+// until ctx is cancelled.
+func (s *Server) push(ctx context.Context, c *conn, states <-chan models.State, evs <-chan models.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case st, ok := <-states:
+			if !ok {
+				return
+			}
+			if _, ok := auth.ContextForKey(ctx, s.auth, c.key.Load()); ok && c.states.Load() {
+				c.send(notification{Version: "2.0", Method: "state", Params: st})
+			}
+		case ev, ok := <-evs:
+			if !ok {
+				return
+			}
+			if _, ok := auth.ContextForKey(ctx, s.auth, c.key.Load()); ok {
+				c.send(notification{Version: "2.0", Method: "event", Params: ev})
+			}
+		}
+	}
+}
+
+// call runs a request's method.
+func (s *Server) call(ctx context.Context, c *conn, req request) (interface{}, error) {
+	if req.Version != "2.0" || req.Method == "" {
+		return nil, &rpcError{Code: codeInvalidRequest, Message: `invalid request: want "jsonrpc":"2.0" and a method`}
+	}
+	if req.Method == "auth" {
+		var p struct {
+			Key string `json:"key"`
+		}
+		if err := decodeParams(req.Params, &p); err != nil {
+			return nil, err
+		}
+		if s.auth != nil && !s.auth.IsOpenMode() {
+			if _, _, ok := s.auth.KeyAccess(p.Key); !ok {
+				return nil, &rpcError{Code: codeUnauthorized, Message: "invalid API key"}
+			}
+		}
+		c.key.Store(&p.Key)
+		return map[string]bool{"ok": true}, nil
+	}
+	ctx, ok := auth.ContextForKey(ctx, s.auth, c.key.Load())
+	if !ok {
+		return nil, &rpcError{Code: codeUnauthorized, Message: "authentication required: send auth with an API key first"}
+	}
+	m, ok := methods[req.Method]
+	if !ok {
+		return nil, &rpcError{Code: codeNoMethod, Message: "method not found: " + req.Method}
+	}
+	if m.zones && s.ctrl.StreamerMode() {
+		return nil, apiError(models.ErrNotFound("zones and groups are not available on a streamer unit"))
+	}
+	if auth.ScopeFrom(ctx) != nil && !m.scoped {
+		return nil, apiError(models.ErrForbidden("this key may only control its zones and groups"))
+	}
+	return m.run(ctx, s, c, req.Params)
+}
+
+// method is an RPC method.
+type method struct {
+	zones  bool // not available on streamer units
+	scoped bool // allowed to keys restricted to a scope; the controller checks the targets
+	run    func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error)
+}
+
+// methods maps method names to controller operations. Updates take the
+// JSON of the REST request with the ID added, e.g. set_zone {"id":2,
+// "vol_f":0.5} for PATCH /api/zones/2 {"vol_f":0.5}, and return the
+// state.
+var methods = map[string]method{
+	"get_state": {scoped: true, run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		return s.ctrl.State(), nil
+	}},
+	"subscribe": {scoped: true, run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			State bool `json:"state"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		c.states.Store(p.State)
+		return map[string]bool{"state": p.State}, nil
+	}},
+	"set_source": {run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var upd models.SourceUpdate
+		if err := decodeParams(params, &upd); err != nil {
+			return nil, err
+		}
+		if upd.ID == nil {
+			return nil, missingID
+		}
+		return stateResult(s.ctrl.SetSource(ctx, *upd.ID, upd))
+	}},
+	"set_zone": {zones: true, scoped: true, run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var upd models.ZoneUpdate
+		if err := decodeParams(params, &upd); err != nil {
+			return nil, err
+		}
+		if upd.ID == nil {
+			return nil, missingID
+		}
+		return stateResult(s.ctrl.SetZone(ctx, *upd.ID, upd))
+	}},
+	"set_zones": {zones: true, scoped: true, run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var req models.MultiZoneUpdate
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		return stateResult(s.ctrl.SetZones(ctx, req))
+	}},
+	"set_group": {zones: true, scoped: true, run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var upd models.GroupUpdate
+		if err := decodeParams(params, &upd); err != nil {
+			return nil, err
+		}
+		if upd.ID == nil {
+			return nil, missingID
+		}
+		return stateResult(s.ctrl.SetGroup(ctx, *upd.ID, upd))
+	}},
+	"stream_cmd": {scoped: true, run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			ID  *int   `json:"id"`
+			Cmd string `json:"cmd"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.ID == nil {
+			return nil, missingID
+		}
+		return stateResult(s.ctrl.ExecStreamCommand(ctx, *p.ID, p.Cmd))
+	}},
+	"load_preset": {run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var p struct {
+			ID *int `json:"id"`
+		}
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		if p.ID == nil {
+			return nil, missingID
+		}
+		state, _, appErr := s.ctrl.LoadPresetWithReport(ctx, *p.ID)
+		return stateResult(state, appErr)
+	}},
+	"announce": {run: func(ctx context.Context, s *Server, c *conn, params json.RawMessage) (interface{}, error) {
+		var req models.AnnounceRequest
+		if err := decodeParams(params, &req); err != nil {
+			return nil, err
+		}
+		return stateResult(s.ctrl.Announce(ctx, req))
+	}},
+}
+
+var missingID = &rpcError{Code: codeInvalidParams, Message: "invalid params: id is required"}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if len(params) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: codeInvalidParams, Message: "invalid params: " + err.Error()}
+	}
+	return nil
+}
+
+func stateResult(state models.State, appErr *models.AppError) (interface{}, error) {
+	if appErr != nil {
+		return nil, apiError(appErr)
+	}
+	return state, nil
+}
+
+// apiError returns an API error as a JSON-RPC error whose data is the
+// error body the REST API would send, e.g. {"error":"NOT_FOUND", ...}.
+func apiError(appErr *models.AppError) *rpcError {
+	return &rpcError{Code: codeServer, Message: appErr.Message, Data: appErr}
+}
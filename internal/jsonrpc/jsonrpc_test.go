@@ -0,0 +1,253 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// keyAuth accepts its key as an administrator's and "scoped-" plus its
+// key as a key restricted to zone 0; "" is open mode. The key can be
+// changed while clients are connected, as when a password is set.
+type keyAuth struct {
+	mu  sync.Mutex
+	key string
+}
+
+func newKeyAuth(key string) *keyAuth { return &keyAuth{key: key} }
+
+func (k *keyAuth) set(key string) {
+	k.mu.Lock()
+	k.key = key
+	k.mu.Unlock()
+}
+
+func (k *keyAuth) IsOpenMode() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.key == ""
+}
+
+func (k *keyAuth) KeyAccess(key string) (bool, *auth.Scope, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	switch key {
+	case k.key:
+		return true, nil, true
+	case "scoped-" + k.key:
+		return false, &auth.Scope{Zones: []int{0}}, true
+	}
+	return false, nil, false
+}
+
+// client is a connection to a test server.
+type client struct {
+	t    *testing.T
+	conn net.Conn
+	sc   *bufio.Scanner
+}
+
+func newTestClient(t *testing.T, auth Auth) (*client, *controller.Controller) {
+	t.Helper()
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go New(ctrl, bus, auth).Serve(ctx, ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, conn: conn, sc: bufio.NewScanner(conn)}, ctrl
+}
+
+func (c *client) send(line string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\n")); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next returns the next message, or fails after a second.
+func (c *client) next() map[string]json.RawMessage {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	if !c.sc.Scan() {
+		c.t.Fatalf("no message: %v", c.sc.Err())
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(c.sc.Bytes(), &msg); err != nil {
+		c.t.Fatal(err)
+	}
+	return msg
+}
+
+// reply returns the reply to request id, skipping notifications.
+func (c *client) reply(id string) map[string]json.RawMessage {
+	c.t.Helper()
+	for {
+		msg := c.next()
+		if string(msg["id"]) == id {
+			return msg
+		}
+	}
+}
+
+func errorCode(t *testing.T, msg map[string]json.RawMessage) int {
+	t.Helper()
+	var e rpcError
+	if err := json.Unmarshal(msg["error"], &e); err != nil {
+		t.Fatalf("no error in %s", msg)
+	}
+	return e.Code
+}
+
+func TestCalls(t *testing.T) {
+	c, ctrl := newTestClient(t, newKeyAuth(""))
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"set_zone","params":{"id":0,"vol":-30,"mute":false}}`)
+	var state models.State
+	if err := json.Unmarshal(c.reply("1")["result"], &state); err != nil {
+		t.Fatal(err)
+	}
+	if z := ctrl.State().Zones[0]; z.Vol != -30 || z.Mute || state.Zones[0].Vol != -30 {
+		t.Errorf("zone 0 = vol %d mute %v, want -30 unmuted", z.Vol, z.Mute)
+	}
+
+	// The change is pushed as an event.
+	for {
+		msg := c.next()
+		if string(msg["method"]) == `"event"` {
+			var ev models.Event
+			if err := json.Unmarshal(msg["params"], &ev); err != nil {
+				t.Fatal(err)
+			}
+			if ev.Type != models.EventZoneChanged {
+				t.Errorf("event %q, want zone_changed", ev.Type)
+			}
+			break
+		}
+	}
+
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"set_zone","params":{"id":99,"vol":-30}}`)
+	if code := errorCode(t, c.reply("2")); code != codeServer {
+		t.Errorf("missing zone: code %d, want %d", code, codeServer)
+	}
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"set_zone","params":{"vol":-30}}`)
+	if code := errorCode(t, c.reply("3")); code != codeInvalidParams {
+		t.Errorf("no id: code %d, want %d", code, codeInvalidParams)
+	}
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"nope"}`)
+	if code := errorCode(t, c.reply("4")); code != codeNoMethod {
+		t.Errorf("unknown method: code %d, want %d", code, codeNoMethod)
+	}
+	c.send(`{not json`)
+	if code := errorCode(t, c.reply("null")); code != codeParse {
+		t.Errorf("bad JSON: code %d, want %d", code, codeParse)
+	}
+
+	// States are pushed after subscribing to them.
+	c.send(`{"jsonrpc":"2.0","id":5,"method":"subscribe","params":{"state":true}}`)
+	c.reply("5")
+	c.send(`{"jsonrpc":"2.0","method":"set_zone","params":{"id":1,"vol":-25}}`)
+	for {
+		msg := c.next()
+		if string(msg["method"]) != `"state"` {
+			continue
+		}
+		if err := json.Unmarshal(msg["params"], &state); err != nil {
+			t.Fatal(err)
+		}
+		if state.Zones[1].Vol == -25 {
+			break
+		}
+	}
+}
+
+func TestAuth(t *testing.T) {
+	c, _ := newTestClient(t, newKeyAuth("s3cret"))
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"get_state"}`)
+	if code := errorCode(t, c.reply("1")); code != codeUnauthorized {
+		t.Errorf("before auth: code %d, want %d", code, codeUnauthorized)
+	}
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"auth","params":{"key":"wrong"}}`)
+	if code := errorCode(t, c.reply("2")); code != codeUnauthorized {
+		t.Errorf("wrong key: code %d, want %d", code, codeUnauthorized)
+	}
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"auth","params":{"key":"s3cret"}}`)
+	c.reply("3")
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"get_state"}`)
+	if msg := c.reply("4"); msg["error"] != nil || msg["result"] == nil {
+		t.Errorf("after auth: %s", msg)
+	}
+}
+
+func TestPasswordLocksOpenSession(t *testing.T) {
+	a := newKeyAuth("")
+	c, _ := newTestClient(t, a)
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"get_state"}`)
+	if msg := c.reply("1"); msg["error"] != nil {
+		t.Fatalf("open mode: %s", msg)
+	}
+	a.set("s3cret")
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"get_state"}`)
+	if code := errorCode(t, c.reply("2")); code != codeUnauthorized {
+		t.Errorf("after setting a password: code %d, want %d", code, codeUnauthorized)
+	}
+	c.send(`{"jsonrpc":"2.0","id":3,"method":"auth","params":{"key":"s3cret"}}`)
+	c.reply("3")
+	c.send(`{"jsonrpc":"2.0","id":4,"method":"get_state"}`)
+	if msg := c.reply("4"); msg["error"] != nil {
+		t.Errorf("after auth: %s", msg)
+	}
+}
+
+func TestScopedKey(t *testing.T) {
+	c, _ := newTestClient(t, newKeyAuth("s3cret"))
+
+	c.send(`{"jsonrpc":"2.0","id":1,"method":"auth","params":{"key":"scoped-s3cret"}}`)
+	c.reply("1")
+	c.send(`{"jsonrpc":"2.0","id":2,"method":"set_zone","params":{"id":0,"vol":-30}}`)
+	if msg := c.reply("2"); msg["error"] != nil {
+		t.Errorf("own zone: %s", msg)
+	}
+	for i, call := range []string{
+		`"set_zone","params":{"id":1,"vol":-30}`,
+		`"set_zone","params":{"id":0,"name":"Mine"}`,
+		`"set_source","params":{"id":0,"input":"local"}`,
+		`"load_preset","params":{"id":0}`,
+		`"announce","params":{"media":"http://x/a.mp3"}`,
+	} {
+		id := fmt.Sprint(i + 3)
+		c.send(`{"jsonrpc":"2.0","id":` + id + `,"method":` + call + `}`)
+		var e struct {
+			Data models.AppError `json:"data"`
+		}
+		if err := json.Unmarshal(c.reply(id)["error"], &e); err != nil || e.Data.Code != "FORBIDDEN" {
+			t.Errorf("%s: error %+v, %v, want FORBIDDEN", call, e, err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+// Package keypad is a serial gateway for RS-485 wall keypads in commercial
+// installs. Keypads send one ASCII message per button press or rotary
+// detent; the gateway reads them from a spare Pi UART and applies the zone
+// or group action mapped to each message in the "keypad" setting.
+// Unmapped messages are logged, so codes can be learned by pressing
+// buttons while watching the log.
+package keypad
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/models"
+	"go.bug.st/serial"
+)
+
+// reopenDelay is the wait before reopening a port that failed or closed.
+const reopenDelay = 5 * time.Second
+
+// maxMessageLen bounds a message, so line noise without terminators is
+// discarded instead of buffered.
+const maxMessageLen = 64
+
+// Controller is the part of the controller keypads drive.
+type Controller interface {
+	State() models.State
+	SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError)
+	SetGroup(ctx context.Context, id int, upd models.GroupUpdate) (models.State, *models.AppError)
+}
+
+// EventBus delivers state updates, from which the gateway follows its
+// setting.
+type EventBus interface {
+	Subscribe(id string) <-chan models.State
+	Unsubscribe(id string)
+}
+
+// port identifies an open serial line.
+type port struct {
+	device string
+	baud   int
+}
+
+// Gateway reads keypad messages while enabled and applies their mappings.
+type Gateway struct {
+	ctrl Controller
+	open func(device string, baud int) (io.ReadCloser, error) // replaced in tests
+
+	mu   sync.Mutex
+	port port               // zero while closed
+	stop context.CancelFunc // stops the reader; nil while closed
+}
+
+// New creates a gateway reading from the serial port named in settings.
+func New(ctrl Controller) *Gateway {
+	return &Gateway{ctrl: ctrl, open: openSerial}
+}
+
+func openSerial(device string, baud int) (io.ReadCloser, error) {
+	return serial.Open(device, &serial.Mode{
+		BaudRate: baud,
+		DataBits: 8,
+		Parity:   serial.NoParity,
+		StopBits: serial.OneStopBit,
+	})
+}
+
+// Run follows the keypad setting, (re)opening the serial port when it is
+// enabled or its device or baud rate change. Blocks until ctx is
+// cancelled.
+func (g *Gateway) Run(ctx context.Context, bus EventBus) {
+	id := "keypad-" + uuid.New().String()
+	ch := bus.Subscribe(id)
+	defer bus.Unsubscribe(id)
+
+	g.configure(ctx, g.ctrl.State().Settings.Keypad)
+	for {
+		select {
+		case <-ctx.Done():
+			g.configure(ctx, models.KeypadSettings{})
+			return
+		case s, ok := <-ch:
+			if !ok {
+				return
+			}
+			g.configure(ctx, s.Settings.Keypad)
+		}
+	}
+}
+
+// configure starts, stops or restarts the reader to match k.
+func (g *Gateway) configure(ctx context.Context, k models.KeypadSettings) {
+	var want port
+	if k.Enabled {
+		want = port{device: k.Device, baud: k.Baud}
+		if want.device == "" {
+			want.device = models.DefaultKeypadDevice
+		}
+		if want.baud == 0 {
+			want.baud = models.DefaultKeypadBaud
+		}
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if want == g.port {
+		return
+	}
+	if g.stop != nil {
+		g.stop()
+		g.stop = nil
+		slog.Info("keypad gateway stopped", "device", g.port.device)
+	}
+	g.port = want
+	if want == (port{}) {
+		return
+	}
+	readCtx, cancel := context.WithCancel(ctx)
+	g.stop = cancel
+	go g.read(readCtx, want)
+	slog.Info("keypad gateway started", "device", want.device, "baud", want.baud)
+}
+
+// read handles messages from p until ctx is cancelled, reopening the port
+// after errors (e.g. a USB adapter being replugged).
+func (g *Gateway) read(ctx context.Context, p port) {
+	for {
+		rc, err := g.open(p.device, p.baud)
+		if err != nil {
+			slog.Warn("keypad gateway: open failed", "device", p.device, "err", err)
+		} else {
+			// Closing the port unblocks a pending read on cancellation.
+			done := make(chan struct{})
+			go func() {
+				select {
+				case <-ctx.Done():
+				case <-done:
+				}
+				rc.Close()
+			}()
+			err = g.readMessages(ctx, rc)
+			close(done)
+			if ctx.Err() == nil {
+				slog.Warn("keypad gateway: read failed", "device", p.device, "err", err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(reopenDelay):
+		}
+	}
+}
+
+// readMessages splits r into CR- or LF-terminated messages and handles
+// each one.
+func (g *Gateway) readMessages(ctx context.Context, r io.Reader) error {
+	br := bufio.NewReader(r)
+	var msg []byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				return fmt.Errorf("port closed")
+			}
+			return err
+		}
+		if b != '\r' && b != '\n' {
+			if len(msg) < maxMessageLen {
+				msg = append(msg, b)
+			}
+			continue
+		}
+		if m := strings.TrimSpace(string(msg)); m != "" {
+			if err := g.Handle(ctx, m); err != nil {
+				slog.Warn("keypad gateway: action failed", "message", m, "err", err)
+			}
+		}
+		msg = msg[:0]
+	}
+}
+
+// Handle applies the mapping of one keypad message. Unmapped messages are
+// logged and ignored.
+func (g *Gateway) Handle(ctx context.Context, msg string) error {
+	state := g.ctrl.State()
+	var m *models.KeypadMapping
+	for i := range state.Settings.Keypad.Mappings {
+		if state.Settings.Keypad.Mappings[i].Message == msg {
+			m = &state.Settings.Keypad.Mappings[i]
+			break
+		}
+	}
+	if m == nil {
+		slog.Info("keypad gateway: unmapped message", "message", msg)
+		return nil
+	}
+
+	var appErr *models.AppError
+	switch {
+	case m.ZoneID != nil:
+		var upd models.ZoneUpdate
+		var mute bool
+		for _, z := range state.Zones {
+			if z.ID == *m.ZoneID {
+				mute = z.Mute
+			}
+		}
+		upd.Mute, upd.VolF, upd.VolDeltaF, upd.SourceID = action(m, mute)
+		_, appErr = g.ctrl.SetZone(ctx, *m.ZoneID, upd)
+	case m.GroupID != nil:
+		var upd models.GroupUpdate
+		var mute bool
+		for _, gr := range state.Groups {
+			if gr.ID == *m.GroupID && gr.Mute != nil {
+				mute = *gr.Mute
+			}
+		}
+		upd.Mute, upd.VolF, upd.VolDeltaF, upd.SourceID = action(m, mute)
+		_, appErr = g.ctrl.SetGroup(ctx, *m.GroupID, upd)
+	}
+	if appErr != nil {
+		return appErr
+	}
+	return nil
+}
+
+// action returns the update fields for a mapping's action; muted is the
+// target's current mute, which mute_toggle inverts.
+func action(m *models.KeypadMapping, muted bool) (mute *bool, volF, volDeltaF *float64, sourceID *int) {
+	step := models.DefaultVolStepF
+	if m.Value != nil {
+		step = *m.Value
+	}
+	switch m.Action {
+	case models.KeypadVolUp:
+		volDeltaF = &step
+	case models.KeypadVolDown:
+		step = -step
+		volDeltaF = &step
+	case models.KeypadVolSet:
+		volF = m.Value
+	case models.KeypadMute, models.KeypadUnmute, models.KeypadMuteToggle:
+		on := m.Action == models.KeypadMute || (m.Action == models.KeypadMuteToggle && !muted)
+		mute = &on
+	case models.KeypadSource:
+		id := m.SourceID()
+		sourceID = &id
+	}
+	return mute, volF, volDeltaF, sourceID
+}
@@ -0,0 +1,155 @@
+package keypad
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func intPtr(i int) *int           { return &i }
+func floatPtr(f float64) *float64 { return &f }
+
+func newTestGateway(t *testing.T, mappings ...models.KeypadMapping) (*Gateway, *controller.Controller, *events.Bus) {
+	t.Helper()
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, appErr := ctrl.SetSettings(context.Background(), models.SettingsUpdate{
+		Keypad: &models.KeypadSettings{Mappings: mappings},
+	}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	return New(ctrl), ctrl, bus
+}
+
+func TestHandle(t *testing.T) {
+	ctx := context.Background()
+	g, ctrl, _ := newTestGateway(t,
+		models.KeypadMapping{Message: "K1B1", ZoneID: intPtr(0), Action: models.KeypadMuteToggle},
+		models.KeypadMapping{Message: "K1R+", ZoneID: intPtr(0), Action: models.KeypadVolUp, Value: floatPtr(0.1)},
+		models.KeypadMapping{Message: "K1B2", ZoneID: intPtr(0), Action: models.KeypadSource, Value: floatPtr(2)},
+		models.KeypadMapping{Message: "K1B3", ZoneID: intPtr(0), Action: models.KeypadVolSet, Value: floatPtr(0.5)},
+	)
+	muted := ctrl.State().Zones[0].Mute
+
+	for _, msg := range []string{"K1B1", "K1B3", "K1R+", "K1B2", "unknown"} {
+		if err := g.Handle(ctx, msg); err != nil {
+			t.Fatalf("Handle(%q): %v", msg, err)
+		}
+	}
+	z := ctrl.State().Zones[0]
+	if z.Mute == muted {
+		t.Error("mute_toggle did not toggle zone 0")
+	}
+	if z.VolF < 0.59 || z.VolF > 0.61 {
+		t.Errorf("vol_f = %v, want 0.6 after vol_set 0.5 and vol_up 0.1", z.VolF)
+	}
+	if z.SourceID != 2 {
+		t.Errorf("source_id = %d, want 2", z.SourceID)
+	}
+}
+
+func TestSourceValueRounds(t *testing.T) {
+	g, ctrl, _ := newTestGateway(t,
+		models.KeypadMapping{Message: "S", ZoneID: intPtr(0), Action: models.KeypadSource, Value: floatPtr(1.9999)},
+	)
+	if err := g.Handle(context.Background(), "S"); err != nil {
+		t.Fatal(err)
+	}
+	if id := ctrl.State().Zones[0].SourceID; id != 2 {
+		t.Errorf("source_id = %d, want 2", id)
+	}
+}
+
+func TestDeleteGroupRemovesMappings(t *testing.T) {
+	ctx := context.Background()
+	_, ctrl, _ := newTestGateway(t)
+	name := "Bar"
+	state, appErr := ctrl.CreateGroup(ctx, models.GroupUpdate{Name: &name, ZoneIDs: []int{0, 1}})
+	if appErr != nil {
+		t.Fatal(appErr)
+	}
+	gid := state.Groups[len(state.Groups)-1].ID
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{Keypad: &models.KeypadSettings{Mappings: []models.KeypadMapping{
+		{Message: "G", GroupID: intPtr(gid), Action: models.KeypadMute},
+		{Message: "Z", ZoneID: intPtr(0), Action: models.KeypadMute},
+	}}}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if _, appErr := ctrl.DeleteGroup(ctx, gid); appErr != nil {
+		t.Fatal(appErr)
+	}
+	m := ctrl.GetSettings().Keypad.Mappings
+	if len(m) != 1 || m[0].Message != "Z" {
+		t.Errorf("mappings after deleting group %d = %+v, want only Z", gid, m)
+	}
+}
+
+func TestSettingsValidation(t *testing.T) {
+	_, ctrl, _ := newTestGateway(t)
+	for _, m := range []models.KeypadMapping{
+		{Message: "", ZoneID: intPtr(0), Action: models.KeypadMute},
+		{Message: "A", Action: models.KeypadMute},
+		{Message: "A", ZoneID: intPtr(0), GroupID: intPtr(0), Action: models.KeypadMute},
+		{Message: "A", ZoneID: intPtr(99), Action: models.KeypadMute},
+		{Message: "A", ZoneID: intPtr(0), Action: "dance"},
+		{Message: "A", ZoneID: intPtr(0), Action: models.KeypadVolSet},
+		{Message: "A", ZoneID: intPtr(0), Action: models.KeypadSource, Value: floatPtr(9)},
+	} {
+		_, appErr := ctrl.SetSettings(context.Background(), models.SettingsUpdate{
+			Keypad: &models.KeypadSettings{Mappings: []models.KeypadMapping{m}},
+		})
+		if appErr == nil || appErr.Status != 400 {
+			t.Errorf("mapping %+v: err = %v, want 400", m, appErr)
+		}
+	}
+}
+
+func TestRunReadsPort(t *testing.T) {
+	g, ctrl, bus := newTestGateway(t,
+		models.KeypadMapping{Message: "UP", ZoneID: intPtr(1), Action: models.KeypadVolUp, Value: floatPtr(0.25)},
+	)
+	before := ctrl.State().Zones[1].VolF
+
+	r, w := io.Pipe()
+	opened := make(chan string, 1)
+	g.open = func(device string, baud int) (io.ReadCloser, error) {
+		opened <- device
+		return r, nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go g.Run(ctx, bus)
+
+	k := ctrl.GetSettings().Keypad
+	k.Enabled = true
+	if _, appErr := ctrl.SetSettings(ctx, models.SettingsUpdate{Keypad: &k}); appErr != nil {
+		t.Fatal(appErr)
+	}
+	select {
+	case dev := <-opened:
+		if dev != models.DefaultKeypadDevice {
+			t.Errorf("opened %q, want %q", dev, models.DefaultKeypadDevice)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("port not opened after enabling the gateway")
+	}
+
+	go w.Write([]byte("noise\r\nUP\r"))
+	deadline := time.Now().Add(2 * time.Second)
+	for ctrl.State().Zones[1].VolF == before {
+		if time.Now().After(deadline) {
+			t.Fatal("UP message was not applied")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,249 @@
+// Package logs keeps recent daemon log records in memory so they can be
+// viewed and tailed over the API without shell access.
+package logs
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSize is the number of records kept by the daemon's buffer.
+const DefaultSize = 5000
+
+const subBufferSize = 64
+
+// modulePrefix is stripped from function names to find a record's subsystem.
+const modulePrefix = "github.com/micro-nova/amplipi-go/internal/"
+
+// Entry is one buffered log record.
+type Entry struct {
+	Seq       uint64                 `json:"seq"`
+	Time      time.Time              `json:"time"`
+	Level     string                 `json:"level"`
+	Subsystem string                 `json:"subsystem"`
+	Message   string                 `json:"msg"`
+	Attrs     map[string]interface{} `json:"attrs,omitempty"`
+
+	level slog.Level
+}
+
+// Filter selects buffered entries.
+type Filter struct {
+	Level      slog.Level // minimum level
+	Since      time.Time  // zero for no lower bound
+	Subsystems []string   // empty for all
+	Limit      int        // most recent entries only; 0 for all
+}
+
+// Match reports whether e passes the level, time and subsystem filters.
+func (f Filter) Match(e Entry) bool {
+	if e.level < f.Level {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if len(f.Subsystems) == 0 {
+		return true
+	}
+	for _, s := range f.Subsystems {
+		if s == e.Subsystem {
+			return true
+		}
+	}
+	return false
+}
+
+// Buffer is a fixed-size ring of recent log records with live subscribers.
+// Like the event bus, slow subscribers have records dropped rather than
+// blocking the logger.
+type Buffer struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // index of the oldest entry once the ring is full
+	seq     uint64
+	subs    map[string]chan Entry
+}
+
+// NewBuffer creates a buffer holding the last size records.
+func NewBuffer(size int) *Buffer {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Buffer{
+		entries: make([]Entry, 0, size),
+		subs:    make(map[string]chan Entry),
+	}
+}
+
+// Handler returns a slog.Handler that records into b and passes every
+// record on to next.
+func (b *Buffer) Handler(next slog.Handler) slog.Handler {
+	return &handler{buf: b, next: next}
+}
+
+// Entries returns the buffered entries matching f, oldest first.
+func (b *Buffer) Entries(f Filter) []Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := make([]Entry, 0)
+	for i := range b.entries {
+		e := b.entries[(b.next+i)%len(b.entries)]
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out
+}
+
+// Subscribe creates a subscription that receives every new record.
+// Call Unsubscribe when done.
+func (b *Buffer) Subscribe(id string) <-chan Entry {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ch := make(chan Entry, subBufferSize)
+	b.subs[id] = ch
+	return ch
+}
+
+// Unsubscribe removes a subscription and closes its channel.
+func (b *Buffer) Unsubscribe(id string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if ch, ok := b.subs[id]; ok {
+		delete(b.subs, id)
+		close(ch)
+	}
+}
+
+func (b *Buffer) add(e Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.seq++
+	e.Seq = b.seq
+	if len(b.entries) < cap(b.entries) {
+		b.entries = append(b.entries, e)
+	} else {
+		b.entries[b.next] = e
+		b.next = (b.next + 1) % len(b.entries)
+	}
+	for _, ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// Drop if subscriber is slow
+		}
+	}
+}
+
+// handler tees records into a Buffer.
+type handler struct {
+	buf   *Buffer
+	next  slog.Handler
+	attrs []slog.Attr // from WithAttrs, keys already group-qualified
+	group string      // current group prefix, e.g. "req."
+}
+
+func (h *handler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *handler) Handle(ctx context.Context, r slog.Record) error {
+	e := Entry{Time: r.Time, Level: r.Level.String(), Message: r.Message, level: r.Level}
+	attrs := make(map[string]interface{})
+	for _, a := range h.attrs {
+		addAttr(attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		addAttr(attrs, h.group, a)
+		return true
+	})
+	// An explicit "subsystem" attribute overrides the calling package.
+	if s, ok := attrs["subsystem"].(string); ok {
+		e.Subsystem = s
+		delete(attrs, "subsystem")
+	} else {
+		e.Subsystem = subsystemFor(r.PC)
+	}
+	if len(attrs) > 0 {
+		e.Attrs = attrs
+	}
+	h.buf.add(e)
+	return h.next.Handle(ctx, r)
+}
+
+func (h *handler) WithAttrs(as []slog.Attr) slog.Handler {
+	attrs := append([]slog.Attr{}, h.attrs...)
+	for _, a := range as {
+		a.Key = h.group + a.Key
+		attrs = append(attrs, a)
+	}
+	return &handler{buf: h.buf, next: h.next.WithAttrs(as), attrs: attrs, group: h.group}
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &handler{buf: h.buf, next: h.next.WithGroup(name), attrs: h.attrs, group: h.group + name + "."}
+}
+
+// addAttr flattens a into m under prefix, converting values that do not
+// marshal usefully (errors, durations) to strings.
+func addAttr(m map[string]interface{}, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		p := prefix
+		if a.Key != "" {
+			p += a.Key + "."
+		}
+		for _, ga := range v.Group() {
+			addAttr(m, p, ga)
+		}
+		return
+	}
+	if a.Key == "" {
+		return
+	}
+	switch v.Kind() {
+	case slog.KindDuration, slog.KindTime:
+		m[prefix+a.Key] = v.String()
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			m[prefix+a.Key] = err.Error()
+		} else {
+			m[prefix+a.Key] = v.Any()
+		}
+	default:
+		m[prefix+a.Key] = v.Any()
+	}
+}
+
+// subsystemFor names the package that logged at pc: "streams",
+// "hardware", "api", ... or "main" for the daemon itself.
+func subsystemFor(pc uintptr) string {
+	if pc == 0 {
+		return ""
+	}
+	f, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	fn := f.Function
+	if strings.HasPrefix(fn, "main.") {
+		return "main"
+	}
+	i := strings.Index(fn, modulePrefix)
+	if i < 0 {
+		return ""
+	}
+	pkg := fn[i+len(modulePrefix):]
+	if j := strings.IndexAny(pkg, "./"); j >= 0 {
+		pkg = pkg[:j]
+	}
+	return pkg
+}
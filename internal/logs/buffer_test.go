@@ -0,0 +1,79 @@
+package logs_test
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/logs"
+)
+
+func newLogger(buf *logs.Buffer) *slog.Logger {
+	next := slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelDebug})
+	return slog.New(buf.Handler(next))
+}
+
+func TestBufferRing(t *testing.T) {
+	buf := logs.NewBuffer(3)
+	log := newLogger(buf)
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		log.Info(msg)
+	}
+	got := buf.Entries(logs.Filter{Level: slog.LevelDebug})
+	if len(got) != 3 || got[0].Message != "b" || got[2].Message != "d" {
+		t.Fatalf("entries = %+v", got)
+	}
+	if got[2].Seq != 4 {
+		t.Errorf("seq = %d, want 4", got[2].Seq)
+	}
+	if got := buf.Entries(logs.Filter{Limit: 1}); len(got) != 1 || got[0].Message != "d" {
+		t.Errorf("limit 1 = %+v", got)
+	}
+}
+
+func TestBufferFilter(t *testing.T) {
+	buf := logs.NewBuffer(10)
+	log := newLogger(buf)
+	log.Debug("noise")
+	log.Warn("careful", "err", errors.New("boom"), slog.Group("req", "id", 7))
+	log.With("subsystem", "streams").Error("stream failed")
+
+	if got := buf.Entries(logs.Filter{Level: slog.LevelWarn}); len(got) != 2 {
+		t.Fatalf("warn+ = %d entries, want 2", len(got))
+	}
+	warn := buf.Entries(logs.Filter{Level: slog.LevelWarn})[0]
+	if warn.Subsystem != "logs_test" {
+		t.Errorf("subsystem = %q, want calling package", warn.Subsystem)
+	}
+	if warn.Attrs["err"] != "boom" || warn.Attrs["req.id"] != int64(7) {
+		t.Errorf("attrs = %v", warn.Attrs)
+	}
+
+	got := buf.Entries(logs.Filter{Level: slog.LevelDebug, Subsystems: []string{"streams"}})
+	if len(got) != 1 || got[0].Message != "stream failed" || got[0].Attrs != nil {
+		t.Errorf("streams = %+v", got)
+	}
+	if got := buf.Entries(logs.Filter{Level: slog.LevelDebug, Since: time.Now().Add(time.Minute)}); len(got) != 0 {
+		t.Errorf("since future = %d entries", len(got))
+	}
+}
+
+func TestBufferSubscribe(t *testing.T) {
+	buf := logs.NewBuffer(10)
+	ch := buf.Subscribe("t")
+	newLogger(buf).Info("hello")
+	select {
+	case e := <-ch:
+		if e.Message != "hello" {
+			t.Errorf("msg = %q", e.Message)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for entry")
+	}
+	buf.Unsubscribe("t")
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed after unsubscribe")
+	}
+}
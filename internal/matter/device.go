@@ -0,0 +1,66 @@
+package matter
+
+import (
+	"math"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// DeviceTypeSpeaker is the Matter device type of a zone: an on/off and
+// level-controlled speaker.
+const DeviceTypeSpeaker = 0x0022
+
+// Cluster IDs served on each zone endpoint.
+const (
+	ClusterOnOff        = 0x0006
+	ClusterLevelControl = 0x0008
+)
+
+// Endpoint is a zone as a Matter speaker. Endpoint 0 is the root node, so
+// zone endpoints are numbered from 1 (zone ID plus one). OnOff maps to
+// unmuted and Level (1-254) to the zone's volume fraction.
+type Endpoint struct {
+	Endpoint   int      `json:"endpoint"`
+	ZoneID     int      `json:"zone_id"`
+	Name       string   `json:"name"`
+	DeviceType uint16   `json:"device_type"`
+	Clusters   []uint16 `json:"clusters"`
+	OnOff      bool     `json:"on_off"`
+	Level      int      `json:"level"`
+}
+
+// Endpoints returns the speaker endpoint of every enabled zone.
+func Endpoints(zones []models.Zone) []Endpoint {
+	eps := make([]Endpoint, 0, len(zones))
+	for _, z := range zones {
+		if z.Disabled {
+			continue
+		}
+		eps = append(eps, Endpoint{
+			Endpoint:   z.ID + 1,
+			ZoneID:     z.ID,
+			Name:       z.Name,
+			DeviceType: DeviceTypeSpeaker,
+			Clusters:   []uint16{ClusterOnOff, ClusterLevelControl},
+			OnOff:      !z.Mute,
+			Level:      VolFToLevel(z.VolF),
+		})
+	}
+	return eps
+}
+
+// ZoneID returns the zone served on an endpoint, or false for the root
+// endpoint.
+func ZoneID(endpoint int) (int, bool) {
+	return endpoint - 1, endpoint > 0
+}
+
+// VolFToLevel maps a volume fraction onto a LevelControl level, 1-254.
+func VolFToLevel(v float64) int {
+	return max(1, min(254, int(math.Round(v*254))))
+}
+
+// LevelToVolF maps a LevelControl level onto a volume fraction.
+func LevelToVolF(level int) float64 {
+	return float64(max(0, min(254, level))) / 254
+}
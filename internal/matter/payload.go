@@ -0,0 +1,244 @@
+// Package matter describes AmpliPi zones as Matter speaker devices and
+// generates the onboarding payloads (QR code and manual pairing code) that
+// Apple Home, Google Home and other Matter controllers scan to commission
+// them.
+package matter
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Test vendor and product IDs from the Matter specification. Certified
+// products need IDs assigned by the CSA.
+const (
+	TestVendorID  = 0xFFF1
+	TestProductID = 0x8000
+)
+
+// Discovery capabilities of the onboarding payload.
+const (
+	DiscoverySoftAP    = 1 << 0
+	DiscoveryBLE       = 1 << 1
+	DiscoveryOnNetwork = 1 << 2
+)
+
+// MaxDiscriminator is the largest 12-bit discriminator.
+const MaxDiscriminator = 0xFFF
+
+// maxPasscode is the largest valid setup passcode.
+const maxPasscode = 99999998
+
+// Payload is the information a controller needs to find and commission a
+// device.
+type Payload struct {
+	VendorID      uint16
+	ProductID     uint16
+	Discovery     uint8  // Discovery* flags
+	Discriminator uint16 // 12 bits
+	Passcode      uint32 // 27 bits, see ValidPasscode
+}
+
+// ValidPasscode reports whether p may be used as a setup passcode: in
+// 1-99999998 and not one of the trivial codes the specification forbids.
+func ValidPasscode(p uint32) bool {
+	if p == 0 || p > maxPasscode || p == 12345678 || p == 87654321 {
+		return false
+	}
+	for d := uint32(1); d <= 9; d++ {
+		if p == d*11111111 {
+			return false
+		}
+	}
+	return true
+}
+
+// NewCredentials returns a random discriminator and valid passcode.
+func NewCredentials() (discriminator uint16, passcode uint32, err error) {
+	d, err := rand.Int(rand.Reader, big.NewInt(MaxDiscriminator+1))
+	if err != nil {
+		return 0, 0, err
+	}
+	for {
+		p, err := rand.Int(rand.Reader, big.NewInt(maxPasscode))
+		if err != nil {
+			return 0, 0, err
+		}
+		if pc := uint32(p.Int64()) + 1; ValidPasscode(pc) {
+			return uint16(d.Int64()), pc, nil
+		}
+	}
+}
+
+// Validate checks the field ranges.
+func (p Payload) Validate() error {
+	if p.Discriminator > MaxDiscriminator {
+		return fmt.Errorf("discriminator %d exceeds 12 bits", p.Discriminator)
+	}
+	if !ValidPasscode(p.Passcode) {
+		return fmt.Errorf("invalid setup passcode")
+	}
+	return nil
+}
+
+// QRCode returns the "MT:" string encoded in the device's QR code.
+func (p Payload) QRCode() string {
+	// 88 bits, packed least significant bit first: version (3), vendor
+	// ID (16), product ID (16), commissioning flow (2, standard),
+	// discovery capabilities (8), discriminator (12), passcode (27) and
+	// padding (4).
+	var bits bitWriter
+	bits.write(0, 3)
+	bits.write(uint64(p.VendorID), 16)
+	bits.write(uint64(p.ProductID), 16)
+	bits.write(0, 2)
+	bits.write(uint64(p.Discovery), 8)
+	bits.write(uint64(p.Discriminator), 12)
+	bits.write(uint64(p.Passcode), 27)
+	bits.write(0, 4)
+	return "MT:" + base38(bits.buf)
+}
+
+// ManualCode returns the 11-digit manual pairing code, for controllers
+// without a camera.
+func (p Payload) ManualCode() string {
+	short := uint32(p.Discriminator >> 8) // upper 4 bits of the discriminator
+	digits := fmt.Sprintf("%01d%05d%04d",
+		short>>2,
+		(short&0x3)<<14|p.Passcode&0x3FFF,
+		p.Passcode>>14)
+	return digits + string(rune('0'+verhoeff(digits)))
+}
+
+// FormatManualCode groups a manual pairing code as printed on labels,
+// e.g. "3497-011-2332".
+func FormatManualCode(code string) string {
+	if len(code) != 11 {
+		return code
+	}
+	return code[:4] + "-" + code[4:7] + "-" + code[7:]
+}
+
+type bitWriter struct {
+	buf []byte
+	n   int // bits written
+}
+
+func (w *bitWriter) write(v uint64, bits int) {
+	for i := 0; i < bits; i++ {
+		if w.n%8 == 0 {
+			w.buf = append(w.buf, 0)
+		}
+		if v&(1<<i) != 0 {
+			w.buf[w.n/8] |= 1 << (w.n % 8)
+		}
+		w.n++
+	}
+}
+
+const base38Chars = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ-."
+
+// base38 encodes bytes as the Matter specification does: each 3-byte
+// little-endian chunk becomes 5 characters, a trailing 2 bytes 4 and a
+// trailing byte 2.
+func base38(data []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(data); i += 3 {
+		chunk := data[i:min(i+3, len(data))]
+		var v uint32
+		for j, b := range chunk {
+			v |= uint32(b) << (8 * j)
+		}
+		n := []int{0, 2, 4, 5}[len(chunk)]
+		for j := 0; j < n; j++ {
+			sb.WriteByte(base38Chars[v%38])
+			v /= 38
+		}
+	}
+	return sb.String()
+}
+
+// Verhoeff check digit tables.
+var (
+	verhoeffD = [10][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 2, 3, 4, 0, 6, 7, 8, 9, 5},
+		{2, 3, 4, 0, 1, 7, 8, 9, 5, 6},
+		{3, 4, 0, 1, 2, 8, 9, 5, 6, 7},
+		{4, 0, 1, 2, 3, 9, 5, 6, 7, 8},
+		{5, 9, 8, 7, 6, 0, 4, 3, 2, 1},
+		{6, 5, 9, 8, 7, 1, 0, 4, 3, 2},
+		{7, 6, 5, 9, 8, 2, 1, 0, 4, 3},
+		{8, 7, 6, 5, 9, 3, 2, 1, 0, 4},
+		{9, 8, 7, 6, 5, 4, 3, 2, 1, 0},
+	}
+	verhoeffP = [8][10]int{
+		{0, 1, 2, 3, 4, 5, 6, 7, 8, 9},
+		{1, 5, 7, 6, 2, 8, 3, 0, 9, 4},
+		{5, 8, 0, 3, 7, 9, 6, 1, 4, 2},
+		{8, 9, 1, 6, 0, 4, 3, 5, 2, 7},
+		{9, 4, 5, 3, 1, 2, 6, 8, 7, 0},
+		{4, 2, 8, 6, 5, 7, 3, 9, 0, 1},
+		{2, 7, 9, 3, 8, 0, 6, 4, 1, 5},
+		{7, 0, 4, 6, 9, 1, 3, 2, 5, 8},
+	}
+	verhoeffInv = [10]int{0, 4, 3, 2, 1, 5, 6, 7, 8, 9}
+)
+
+// verhoeff returns the Verhoeff check digit of a string of digits.
+func verhoeff(digits string) int {
+	c := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[len(digits)-1-i] - '0')
+		c = verhoeffD[c][verhoeffP[(i+1)%8][d]]
+	}
+	return verhoeffInv[c]
+}
+
+// Status is the commissioning information of the device and its zone
+// endpoints.
+type Status struct {
+	// Stack reports whether a Matter protocol stack is running. This build
+	// does not include one: the payloads and endpoint map are ready for
+	// it, but controllers cannot complete commissioning yet.
+	Stack bool `json:"stack"`
+	// Commissionable reports whether a controller can commission the
+	// device now: it has credentials and a stack to commission with.
+	Commissionable bool       `json:"commissionable"`
+	VendorID       uint16     `json:"vendor_id"`
+	ProductID      uint16     `json:"product_id"`
+	Discriminator  uint16     `json:"discriminator"`
+	QRCode         string     `json:"qr_code,omitempty"`
+	ManualCode     string     `json:"manual_code,omitempty"`
+	Endpoints      []Endpoint `json:"endpoints"`
+}
+
+// NewStatus describes the device from its pairing settings and zones.
+// The codes are filled in once credentials exist (see NewCredentials), but
+// without a stack the device is not commissionable.
+func NewStatus(settings models.MatterSettings, zones []models.Zone) Status {
+	st := Status{
+		VendorID:  TestVendorID,
+		ProductID: TestProductID,
+		Endpoints: Endpoints(zones),
+	}
+	p := Payload{
+		VendorID:      TestVendorID,
+		ProductID:     TestProductID,
+		Discovery:     DiscoveryOnNetwork,
+		Discriminator: uint16(settings.Discriminator),
+		Passcode:      uint32(settings.Passcode),
+	}
+	if settings.Passcode == 0 || p.Validate() != nil {
+		return st
+	}
+	st.Commissionable = st.Stack
+	st.Discriminator = p.Discriminator
+	st.QRCode = p.QRCode()
+	st.ManualCode = FormatManualCode(p.ManualCode())
+	return st
+}
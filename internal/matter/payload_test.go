@@ -0,0 +1,44 @@
+package matter_test
+
+import (
+	"testing"
+
+	"github.com/micro-nova/amplipi-go/internal/matter"
+)
+
+// The reference values of the Matter SDK's example devices.
+func TestPayload_Reference(t *testing.T) {
+	p := matter.Payload{
+		VendorID:      matter.TestVendorID,
+		ProductID:     0x8001,
+		Discovery:     matter.DiscoveryOnNetwork,
+		Discriminator: 3840,
+		Passcode:      20202021,
+	}
+	if got := p.QRCode(); got != "MT:-24J0AFN00KA0648G00" {
+		t.Errorf("QRCode = %q", got)
+	}
+	if got := p.ManualCode(); got != "34970112332" {
+		t.Errorf("ManualCode = %q", got)
+	}
+	if got := matter.FormatManualCode(p.ManualCode()); got != "3497-011-2332" {
+		t.Errorf("FormatManualCode = %q", got)
+	}
+}
+
+func TestPasscodes(t *testing.T) {
+	for _, p := range []uint32{0, 11111111, 12345678, 87654321, 99999999, 100000000} {
+		if matter.ValidPasscode(p) {
+			t.Errorf("ValidPasscode(%d) = true", p)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		d, p, err := matter.NewCredentials()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (matter.Payload{Discriminator: d, Passcode: p}).Validate(); err != nil {
+			t.Errorf("NewCredentials() = %d, %d: %v", d, p, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Zone amplifier power modes.
+const (
+	AmpAlways = "always" // amp enabled whenever the zone is enabled (default)
+	AmpAuto   = "auto"   // amp enabled while the zone is in use, off after IdleTimeout
+)
+
+// DefaultAmpIdleTimeout is how long an idle zone's amp stays on in auto mode.
+const DefaultAmpIdleTimeout = 5 * time.Minute
+
+// DefaultUnitIdleMinutes is how long a unit stays powered once nothing it
+// plays is active.
+const DefaultUnitIdleMinutes = 10
+
+// UnitPowerSettings power down whole amplifier units while idle: once no
+// unmuted zone on a unit has been fed by an active source for IdleMinutes,
+// its amps are turned off and, on units that can, its 9V and 12V rails.
+// The unit powers back up as soon as a stream starts playing into one of
+// its unmuted zones, or a zone on it is unmuted on an active source.
+type UnitPowerSettings struct {
+	Enabled     bool `json:"enabled"`
+	IdleMinutes int  `json:"idle_minutes,omitempty"` // 0 = DefaultUnitIdleMinutes
+}
+
+// Idle returns how long a unit stays powered after it goes idle.
+func (u UnitPowerSettings) Idle() time.Duration {
+	if u.IdleMinutes > 0 {
+		return time.Duration(u.IdleMinutes) * time.Minute
+	}
+	return DefaultUnitIdleMinutes * time.Minute
+}
+
+// AmpPower controls when a zone's amplifier is enabled. A zone is in use
+// when it is unmuted and its source has an input. Between OffFrom and OffTo
+// (local "HH:MM", may wrap past midnight) the amp is only on while the zone
+// is in use, whatever the mode.
+type AmpPower struct {
+	Mode        string `json:"mode,omitempty"`         // "always" | "auto"
+	IdleTimeout int    `json:"idle_timeout,omitempty"` // seconds; 0 = DefaultAmpIdleTimeout
+	OffFrom     string `json:"off_from,omitempty"`     // start of off hours, "HH:MM"
+	OffTo       string `json:"off_to,omitempty"`       // end of off hours, "HH:MM"
+}
+
+// IsDefault reports whether a leaves the amp always on.
+func (a AmpPower) IsDefault() bool {
+	return (a.Mode == "" || a.Mode == AmpAlways) && a.IdleTimeout == 0 && a.OffFrom == "" && a.OffTo == ""
+}
+
+// Validate checks the mode, timeout and off hours.
+func (a AmpPower) Validate() error {
+	switch a.Mode {
+	case "", AmpAlways, AmpAuto:
+	default:
+		return fmt.Errorf("amp mode must be %q or %q", AmpAlways, AmpAuto)
+	}
+	if a.IdleTimeout < 0 {
+		return fmt.Errorf("amp idle_timeout must not be negative")
+	}
+	if (a.OffFrom == "") != (a.OffTo == "") {
+		return fmt.Errorf("amp off_from and off_to must be set together")
+	}
+	for _, t := range []string{a.OffFrom, a.OffTo} {
+		if _, err := parseClock(t); t != "" && err != nil {
+			return fmt.Errorf("amp off hours: %q is not HH:MM", t)
+		}
+	}
+	return nil
+}
+
+// Idle returns how long the amp stays on after the zone stops being used.
+func (a AmpPower) Idle() time.Duration {
+	if a.IdleTimeout > 0 {
+		return time.Duration(a.IdleTimeout) * time.Second
+	}
+	return DefaultAmpIdleTimeout
+}
+
+// InOffHours reports whether t falls within the off hours.
+func (a AmpPower) InOffHours(t time.Time) bool {
+	return inWindow(a.OffFrom, a.OffTo, t)
+}
+
+// inWindow reports whether t falls between the local times from and to
+// ("HH:MM"), which may wrap past midnight.
+func inWindow(fromHHMM, toHHMM string, t time.Time) bool {
+	from, err1 := parseClock(fromHHMM)
+	to, err2 := parseClock(toHHMM)
+	if fromHHMM == "" || err1 != nil || err2 != nil || from == to {
+		return false
+	}
+	now := t.Hour()*60 + t.Minute()
+	if from < to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to // wraps past midnight
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
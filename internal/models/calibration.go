@@ -0,0 +1,85 @@
+package models
+
+import "math"
+
+// ZoneCalibration is a zone's loudness, measured with pink noise played at
+// Vol. Zones whose speakers are louder for the same volume get a negative
+// OffsetDB so that a given vol_f is equally loud in every calibrated zone.
+type ZoneCalibration struct {
+	Vol      int     `json:"vol"`       // zone volume (dB) the noise was measured at
+	SPL      float64 `json:"spl"`       // measured sound pressure level, dB SPL
+	OffsetDB int     `json:"offset_db"` // computed; added to the volume vol_f maps to
+}
+
+// Calibration noise defaults and limits.
+const (
+	DefaultCalibrationVol     = -30 // dB
+	DefaultCalibrationSeconds = 15
+	MaxCalibrationSeconds     = 60
+)
+
+// CalibrationNoise is the optional POST body for playing pink noise
+// through a zone to measure it.
+type CalibrationNoise struct {
+	Vol      *int `json:"vol,omitempty"`       // zone volume in dB (default DefaultCalibrationVol)
+	Seconds  *int `json:"seconds,omitempty"`   // default DefaultCalibrationSeconds
+	SourceID *int `json:"source_id,omitempty"` // source to borrow (default 3)
+}
+
+// CalibrationMeasurement is the POST body recording the SPL measured in a
+// zone while its calibration noise played.
+type CalibrationMeasurement struct {
+	SPL float64 `json:"spl"`
+	Vol *int    `json:"vol,omitempty"` // volume the noise played at (default DefaultCalibrationVol)
+}
+
+// CalOffset returns the dB the zone's calibration adds to volumes set by
+// vol_f.
+func (z *Zone) CalOffset() int {
+	if z.Calibration == nil {
+		return 0
+	}
+	return z.Calibration.OffsetDB
+}
+
+// VolFToDB converts vol_f to the zone's volume in dB, calibration applied.
+func (z *Zone) VolFToDB(f float64) int {
+	return max(VolFToDB(f)+z.CalOffset(), MinVolDB)
+}
+
+// DBToVolF converts a volume in dB to the zone's vol_f, calibration
+// applied. The lowest volume is always vol_f 0.
+func (z *Zone) DBToVolF(db int) float64 {
+	if db <= MinVolDB {
+		return 0
+	}
+	return DBToVolF(db - z.CalOffset())
+}
+
+// CalibrateZones sets the offsets of the calibrated zones in s so that all
+// of them match the quietest, and returns the IDs of the zones whose offset
+// changed.
+func CalibrateZones(s *State) []int {
+	// Loudness at 0 dB, extrapolated from each measurement.
+	quietest, found := 0.0, false
+	for _, z := range s.Zones {
+		if c := z.Calibration; c != nil {
+			if l := c.SPL - float64(c.Vol); !found || l < quietest {
+				quietest, found = l, true
+			}
+		}
+	}
+	var changed []int
+	for i := range s.Zones {
+		c := s.Zones[i].Calibration
+		if c == nil {
+			continue
+		}
+		offset := int(math.Round(quietest - (c.SPL - float64(c.Vol))))
+		if offset != c.OffsetDB {
+			c.OffsetDB = offset
+			changed = append(changed, s.Zones[i].ID)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,18 @@
+package models
+
+// CastOutput routes a source to Google Cast devices. The devices pull the
+// source as an MP3 stream from this unit.
+type CastOutput struct {
+	Enabled bool     `json:"enabled"`
+	Devices []string `json:"devices"`          // Cast device IDs
+	Volume  *int     `json:"volume,omitempty"` // device volume 0-100; nil leaves it unchanged
+}
+
+// CastDevice is a Google Cast receiver discovered on the LAN.
+type CastDevice struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Model    string `json:"model"`
+	Addr     string `json:"addr"`
+	SourceID *int   `json:"source_id,omitempty"` // source currently routed to it
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ClipPrefix marks announcement media naming a clip in the library rather
+// than a URL or file, e.g. "clip:doorbell".
+const ClipPrefix = "clip:"
+
+// Clip is a short audio file in the announcement clip library.
+type Clip struct {
+	Name     string    `json:"name"`
+	Format   string    `json:"format"` // file extension, e.g. "mp3"
+	Size     int64     `json:"size"`   // bytes
+	Modified time.Time `json:"modified"`
+}
+
+// ClipLibrary lists the clips and how much of the quota they use.
+type ClipLibrary struct {
+	Clips    []Clip `json:"clips"`
+	Used     int64  `json:"used"`      // bytes of all clips
+	Quota    int64  `json:"quota"`     // bytes all clips may use
+	MaxClip  int64  `json:"max_clip"`  // bytes one clip may use
+	MaxClips int    `json:"max_clips"` // number of clips
+}
+
+// ClipName returns the clip media names and whether it names one.
+func ClipName(media string) (string, bool) {
+	return strings.CutPrefix(media, ClipPrefix)
+}
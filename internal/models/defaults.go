@@ -82,6 +82,15 @@ const (
 	LastPresetID     = 9999
 )
 
+// FirstReservedPresetID is the lowest of the preset IDs kept for system
+// presets: party restore (9997), the announcement (9998) and its restore
+// point (9999), then Mute All.
+const FirstReservedPresetID = 9997
+
+// ReservedPresetID reports whether id is kept for a system preset, so
+// never given to a preset the user creates or imports.
+func ReservedPresetID(id int) bool { return id >= FirstReservedPresetID }
+
 // VolFToDB converts a float volume [0.0, 1.0] to dB [-80, 0].
 func VolFToDB(f float64) int {
 	if f < 0.0 {
@@ -129,7 +138,8 @@ func DefaultStateFromProfile(p *hardware.HardwareProfile) State {
 func defaultStateForProfile(p *hardware.HardwareProfile) State {
 	var state State
 
-	// Sources: only present if main unit detected
+	// Sources: present if main unit detected; streamer units get one per
+	// physical output (DAC) instead
 	if p.TotalSources > 0 {
 		for i := 0; i < p.TotalSources; i++ {
 			state.Sources = append(state.Sources, Source{
@@ -138,6 +148,16 @@ func defaultStateForProfile(p *hardware.HardwareProfile) State {
 				Input: "",
 			})
 		}
+	} else if p.IsStreamer {
+		for _, out := range p.AvailablePhysicalOutputs {
+			state.Sources = append(state.Sources, Source{
+				ID:   out,
+				Name: fmt.Sprintf("Output %d", out+1),
+			})
+		}
+	}
+	if state.Sources == nil {
+		state.Sources = []Source{}
 	}
 
 	// Zones: one per detected unit × 6 (skip streamer units — no zones)
@@ -160,6 +180,9 @@ func defaultStateForProfile(p *hardware.HardwareProfile) State {
 			})
 		}
 	}
+	if state.Zones == nil {
+		state.Zones = []Zone{}
+	}
 
 	// Default streams: RCA inputs for main unit, none for streamer-only
 	if p.TotalSources > 0 {
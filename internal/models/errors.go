@@ -1,15 +1,33 @@
 package models
 
+import "strings"
+
 // AppError is a structured application error with HTTP status code.
+// Field names the request field the error is about, and Fields lists
+// each problem when a request has several, so forms can show them next
+// to their fields.
 type AppError struct {
-	Code    string `json:"error"`
+	Code    string       `json:"error"`
+	Message string       `json:"message"`
+	Field   string       `json:"field,omitempty"`
+	Fields  []FieldError `json:"fields,omitempty"`
+	Status  int          `json:"-"`
+}
+
+// FieldError is a problem with one field, e.g. "zones[2].id".
+type FieldError struct {
+	Field   string `json:"field"`
 	Message string `json:"message"`
-	Field   string `json:"field,omitempty"`
-	Status  int    `json:"-"`
 }
 
 func (e *AppError) Error() string { return e.Message }
 
+// WithField sets the field the error is about and returns e.
+func (e *AppError) WithField(field string) *AppError {
+	e.Field = field
+	return e
+}
+
 // Error constructors.
 var (
 	ErrNotFound = func(msg string) *AppError {
@@ -22,7 +40,27 @@ var (
 	ErrInternal     = func(msg string) *AppError {
 		return &AppError{Code: "INTERNAL", Message: msg, Status: 500}
 	}
+	ErrForbidden = func(msg string) *AppError {
+		return &AppError{Code: "FORBIDDEN", Message: msg, Status: 403}
+	}
+	ErrTooLarge = func(msg string) *AppError {
+		return &AppError{Code: "TOO_LARGE", Message: msg, Status: 413}
+	}
+	ErrTooManyRequests = func(msg string) *AppError {
+		return &AppError{Code: "TOO_MANY_REQUESTS", Message: msg, Status: 429}
+	}
 	ErrConflict = func(msg string) *AppError {
 		return &AppError{Code: "CONFLICT", Message: msg, Status: 409}
 	}
+	ErrBadGateway = func(msg string) *AppError {
+		return &AppError{Code: "BAD_GATEWAY", Message: msg, Status: 502}
+	}
+	// ErrInvalidFields reports several field errors at once.
+	ErrInvalidFields = func(fields []FieldError) *AppError {
+		msgs := make([]string, len(fields))
+		for i, f := range fields {
+			msgs[i] = f.Field + ": " + f.Message
+		}
+		return &AppError{Code: "BAD_REQUEST", Message: strings.Join(msgs, "; "), Fields: fields, Status: 400}
+	}
 )
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// Event types emitted on the event bus.
+const (
+	EventSourceAutoOff     = "source_auto_off"    // a source was turned off by its idle policy
+	EventZoneChanged       = "zone_changed"       // zones' settings (volume, mute, source, ...) changed
+	EventStreamStarted     = "stream_started"     // a stream started playing
+	EventStreamUnavailable = "stream_unavailable" // a stream cannot run on this hardware
+	EventOverTemp          = "over_temp"          // a preamp unit reported over-temperature
+	EventStreamSilent      = "stream_silent"      // a stream reporting playing has output silence for minutes
+	EventUpdateAvailable   = "update_available"   // a newer AmpliPi release was published
+	EventPresetLoaded      = "preset_loaded"      // a preset was loaded; says what was skipped
+	EventHostnameChanged   = "hostname_changed"   // the unit's hostname or mDNS name changed
+	EventWebhookPing       = "ping"               // test delivery to one webhook
+)
+
+// EventTypes are the event types webhooks can subscribe to.
+var EventTypes = []string{
+	EventSourceAutoOff, EventZoneChanged, EventStreamStarted, EventStreamUnavailable, EventStreamSilent, EventOverTemp,
+	EventUpdateAvailable, EventPresetLoaded, EventHostnameChanged,
+}
+
+// Event is a notable occurrence delivered to event subscribers alongside
+// state updates, e.g. over SSE as "event: <type>".
+type Event struct {
+	Type string      `json:"type"`
+	Time time.Time   `json:"time"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// SourceAutoOff is the data of a source_auto_off event.
+type SourceAutoOff struct {
+	SourceID    int `json:"source_id"`
+	StreamID    int `json:"stream_id"`
+	IdleMinutes int `json:"idle_minutes"`
+}
+
+// ZoneChanged is the data of a zone_changed event: the zones as they are
+// after the change.
+type ZoneChanged struct {
+	Zones []Zone `json:"zones"`
+}
+
+// StreamStarted is the data of a stream_started event.
+type StreamStarted struct {
+	StreamID int        `json:"stream_id"`
+	Name     string     `json:"name"`
+	Type     string     `json:"type"`
+	Info     StreamInfo `json:"info"`
+}
+
+// StreamUnavailable is the data of a stream_unavailable event.
+type StreamUnavailable struct {
+	StreamID int    `json:"stream_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Reason   string `json:"reason"`
+}
+
+// StreamSilent is the data of a stream_silent event.
+type StreamSilent struct {
+	StreamID  int     `json:"stream_id"`
+	SourceID  int     `json:"source_id"`
+	Name      string  `json:"name"`
+	Type      string  `json:"type"`
+	Minutes   float64 `json:"minutes"`   // how long it has been silent
+	PeakDB    float64 `json:"peak_db"`   // last peak level measured, dBFS; -96 for digital silence
+	Restarted bool    `json:"restarted"` // the watchdog restarted the stream
+}
+
+// OverTemp is the data of an over_temp event.
+type OverTemp struct {
+	Unit int     `json:"unit"`
+	MaxC float32 `json:"max_c"` // hottest heatsink or PSU reading, °C
+}
+
+// UpdateAvailable is the data of an update_available event.
+type UpdateAvailable struct {
+	Current string `json:"current"`
+	Latest  string `json:"latest"`
+}
+
+// WebhookPing is the data of a ping event, sent only to the webhook being
+// tested.
+type WebhookPing struct {
+	WebhookID int `json:"webhook_id"`
+}
@@ -0,0 +1,101 @@
+package models
+
+import "fmt"
+
+// FanPoint is one point of a fan curve: the duty the fans run at once the
+// hottest amp heatsink or power supply of their unit reaches TempC.
+type FanPoint struct {
+	TempC float32 `json:"temp_c"`
+	Duty  float64 `json:"duty"` // 0-1
+}
+
+// FanCurve maps temperature to fan duty for PWM and linear fan control,
+// replacing the firmware's own thresholds. Between points the duty is
+// interpolated; below the first and above the last it is held.
+type FanCurve []FanPoint
+
+// MaxFanPoints bounds the points of a fan curve.
+const MaxFanPoints = 8
+
+// Fan curve temperatures are limited to what the temperature registers
+// can report.
+const (
+	MinFanCurveTempC = 20
+	MaxFanCurveTempC = 147
+)
+
+// FanOverTempC is the heatsink temperature at which the firmware reports
+// over-temperature and shuts the amps down. A fan curve must reach full
+// duty by then.
+const FanOverTempC = 85
+
+// Validate checks that the curve has 2 to MaxFanPoints points, that
+// temperatures rise from point to point, that the duty never falls and that
+// the last point runs the fans at full duty by FanOverTempC.
+func (fc FanCurve) Validate() error {
+	if len(fc) < 2 || len(fc) > MaxFanPoints {
+		return fmt.Errorf("fan curve needs 2-%d points", MaxFanPoints)
+	}
+	if last := fc[len(fc)-1]; last.Duty != 1 || last.TempC > FanOverTempC {
+		return fmt.Errorf("fan curve point %d: the last point must have duty 1 at or below %d°C, the over-temperature threshold", len(fc)-1, FanOverTempC)
+	}
+	for i, p := range fc {
+		if p.TempC < MinFanCurveTempC || p.TempC > MaxFanCurveTempC {
+			return fmt.Errorf("fan curve point %d: temp_c must be %d-%d", i, MinFanCurveTempC, MaxFanCurveTempC)
+		}
+		if p.Duty < 0 || p.Duty > 1 {
+			return fmt.Errorf("fan curve point %d: duty must be 0-1", i)
+		}
+		if i == 0 {
+			continue
+		}
+		if p.TempC <= fc[i-1].TempC {
+			return fmt.Errorf("fan curve point %d: temp_c must be above the previous point's", i)
+		}
+		if p.Duty < fc[i-1].Duty {
+			return fmt.Errorf("fan curve point %d: duty must not be below the previous point's", i)
+		}
+	}
+	return nil
+}
+
+// Duty returns the fan duty at tempC.
+func (fc FanCurve) Duty(tempC float32) float64 {
+	if len(fc) == 0 {
+		return 0
+	}
+	if tempC <= fc[0].TempC {
+		return fc[0].Duty
+	}
+	for i := 1; i < len(fc); i++ {
+		lo, hi := fc[i-1], fc[i]
+		if tempC <= hi.TempC {
+			return lo.Duty + (hi.Duty-lo.Duty)*float64(tempC-lo.TempC)/float64(hi.TempC-lo.TempC)
+		}
+	}
+	return fc[len(fc)-1].Duty
+}
+
+// FanStatus is GET /api/hardware/fans: the fan control of the power board
+// and the curve driving the fans, if any.
+type FanStatus struct {
+	Mode   string        `json:"mode"`   // "pwm", "linear", "external", "forced" or "" without a hardware profile
+	Custom bool          `json:"custom"` // Curve drives the fans; false leaves them to the firmware
+	Curve  FanCurve      `json:"curve"`
+	Units  []UnitFanDuty `json:"units"`
+}
+
+// UnitFanDuty is the temperature a unit's fans follow and the duty last
+// written for it.
+type UnitFanDuty struct {
+	Unit  int      `json:"unit"`
+	TempC float32  `json:"temp_c"`         // hottest amp heatsink or power supply
+	Duty  *float64 `json:"duty,omitempty"` // nil while the firmware controls the fans
+	Error string   `json:"error,omitempty"`
+}
+
+// FanCurveUpdate is the PATCH /api/hardware/fans body. An empty curve hands
+// the fans back to the firmware.
+type FanCurveUpdate struct {
+	Curve FanCurve `json:"curve"`
+}
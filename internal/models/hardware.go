@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+)
+
+// HardwareError is a hardware write that failed after the change that
+// caused it was accepted. It stays in Info until a later write to the same
+// register succeeds.
+type HardwareError struct {
+	Time     time.Time `json:"time"`
+	Unit     int       `json:"unit"`
+	Register string    `json:"register"` // e.g. "zone 3 volume", "amp enables"
+	Error    string    `json:"error"`
+}
+
+// ProfileOverrideStatus is the hardware profile override saved in the
+// config directory, which is applied at the next start, and the one
+// applied to the running profile.
+type ProfileOverrideStatus struct {
+	Saved           hardware.ProfileOverride `json:"saved"`
+	Active          hardware.ProfileOverride `json:"active"`
+	RestartRequired bool                     `json:"restart_required"` // saved differs from active
+}
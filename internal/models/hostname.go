@@ -0,0 +1,43 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxHostnameLength is the longest hostname, as a single DNS label.
+const MaxHostnameLength = 63
+
+// HostnameUpdate is the body of PATCH /api/system/hostname. Nil fields are
+// left unchanged.
+type HostnameUpdate struct {
+	Hostname     *string `json:"hostname,omitempty"`      // e.g. "amplipi-upstairs", reachable as amplipi-upstairs.local
+	FriendlyName *string `json:"friendly_name,omitempty"` // mDNS instance name, e.g. "AmpliPi Upstairs"; "" uses the hostname
+}
+
+// HostnameChanged is the data of a hostname_changed event.
+type HostnameChanged struct {
+	Hostname     string `json:"hostname"`
+	FriendlyName string `json:"friendly_name"`
+}
+
+// ValidateHostname checks that name is usable as the unit's hostname: one
+// lowercase DNS label of letters, digits and hyphens, not starting or
+// ending with a hyphen.
+func ValidateHostname(name string) error {
+	if name == "" {
+		return errors.New("hostname is required")
+	}
+	if len(name) > MaxHostnameLength {
+		return fmt.Errorf("hostname is %d characters long; the limit is %d", len(name), MaxHostnameLength)
+	}
+	for _, r := range name {
+		if !('a' <= r && r <= 'z' || '0' <= r && r <= '9' || r == '-') {
+			return fmt.Errorf("hostname may only contain lowercase letters, digits and hyphens, not %q", r)
+		}
+	}
+	if name[0] == '-' || name[len(name)-1] == '-' {
+		return errors.New("hostname may not start or end with a hyphen")
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"fmt"
+	"math"
+)
+
+// Keypad actions.
+const (
+	KeypadVolUp      = "vol_up"   // Value: step as a fraction of the volume range, default DefaultVolStepF
+	KeypadVolDown    = "vol_down" // Value: as vol_up
+	KeypadVolSet     = "vol_set"  // Value: vol_f
+	KeypadMute       = "mute"
+	KeypadUnmute     = "unmute"
+	KeypadMuteToggle = "mute_toggle"
+	KeypadSource     = "source" // Value: source ID
+)
+
+// DefaultKeypadDevice is the Pi UART the RS-485 transceiver is wired to
+// (UART2; /dev/serial0 talks to the preamp).
+const DefaultKeypadDevice = "/dev/ttyAMA1"
+
+// DefaultKeypadBaud is the keypad line speed when none is set.
+const DefaultKeypadBaud = 9600
+
+// KeypadSettings configure the serial gateway for RS-485 wall keypads.
+// Keypads send one ASCII message per button press or rotary detent,
+// terminated by CR or LF; each message is looked up in Mappings.
+type KeypadSettings struct {
+	Enabled  bool            `json:"enabled"`
+	Device   string          `json:"device,omitempty"` // default DefaultKeypadDevice
+	Baud     int             `json:"baud,omitempty"`   // default DefaultKeypadBaud
+	Mappings []KeypadMapping `json:"mappings,omitempty"`
+}
+
+// KeypadMapping maps a keypad message to an action on a zone or group,
+// e.g. {"message": "K1B1", "zone_id": 3, "action": "mute_toggle"}.
+type KeypadMapping struct {
+	Message string   `json:"message"`
+	ZoneID  *int     `json:"zone_id,omitempty"`
+	GroupID *int     `json:"group_id,omitempty"`
+	Action  string   `json:"action"`
+	Value   *float64 `json:"value,omitempty"`
+}
+
+// SourceID returns the source a "source" mapping selects: Value rounded to
+// the nearest integer.
+func (m KeypadMapping) SourceID() int {
+	return int(math.Round(*m.Value))
+}
+
+// clone returns a copy of m that shares no pointers with it.
+func (m KeypadMapping) clone() KeypadMapping {
+	if m.ZoneID != nil {
+		v := *m.ZoneID
+		m.ZoneID = &v
+	}
+	if m.GroupID != nil {
+		v := *m.GroupID
+		m.GroupID = &v
+	}
+	if m.Value != nil {
+		v := *m.Value
+		m.Value = &v
+	}
+	return m
+}
+
+// Validate checks a mapping's message, target and action. Whether the zone,
+// group or source exists is checked by the controller.
+func (m KeypadMapping) Validate() error {
+	if m.Message == "" {
+		return fmt.Errorf("keypad mapping: message is required")
+	}
+	if (m.ZoneID == nil) == (m.GroupID == nil) {
+		return fmt.Errorf("keypad mapping %q: set exactly one of zone_id and group_id", m.Message)
+	}
+	switch m.Action {
+	case KeypadVolUp, KeypadVolDown:
+		if m.Value != nil && (*m.Value <= 0 || *m.Value > 1) {
+			return fmt.Errorf("keypad mapping %q: step must be in (0, 1]", m.Message)
+		}
+	case KeypadVolSet:
+		if m.Value == nil || *m.Value < 0 || *m.Value > 1 {
+			return fmt.Errorf("keypad mapping %q: vol_set needs a value in [0, 1]", m.Message)
+		}
+	case KeypadSource:
+		if m.Value == nil {
+			return fmt.Errorf("keypad mapping %q: source needs a source ID value", m.Message)
+		}
+	case KeypadMute, KeypadUnmute, KeypadMuteToggle:
+	default:
+		return fmt.Errorf("keypad mapping %q: unknown action %q", m.Message, m.Action)
+	}
+	return nil
+}
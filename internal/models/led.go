@@ -0,0 +1,67 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// LEDs is the front-panel LED state of one preamp unit. The firmware drives
+// the LEDs itself unless Override is set.
+type LEDs struct {
+	Unit     int     `json:"unit"`
+	Override bool    `json:"override"`
+	Green    bool    `json:"green"`
+	Red      bool    `json:"red"`
+	Zones    [6]bool `json:"zones"`
+	Pattern  string  `json:"pattern,omitempty"` // running pattern, e.g. "identify"
+	Auto     bool    `json:"auto,omitempty"`    // driven by the LED settings, see LEDSettings
+}
+
+// LEDUpdate is the PATCH body for a unit's LEDs. Setting any LED turns the
+// override on unless Override is given.
+type LEDUpdate struct {
+	Override *bool   `json:"override,omitempty"`
+	Green    *bool   `json:"green,omitempty"`
+	Red      *bool   `json:"red,omitempty"`
+	Zones    []*bool `json:"zones,omitempty"` // up to 6 entries; null leaves a zone unchanged
+}
+
+// LEDIdentify is the POST body for blinking LEDs to locate a zone or unit.
+// Give either Zone (blinks that zone's LED) or Unit (blinks all of its LEDs).
+type LEDIdentify struct {
+	Zone     *int `json:"zone,omitempty"`
+	Unit     *int `json:"unit,omitempty"`
+	Duration int  `json:"duration,omitempty"` // seconds; 0 = 10
+}
+
+// LEDSettings drive the front-panel LEDs from the controller instead of
+// the firmware. LEDs set through PATCH /api/hardware/leds/{unit} take
+// precedence until their override is turned off.
+type LEDSettings struct {
+	// ZoneActivity lights a zone's LED while it is unmuted and its source
+	// is playing.
+	ZoneActivity bool `json:"zone_activity"`
+
+	// OffFrom and OffTo turn all LEDs off during these local hours
+	// ("HH:MM"), which may wrap past midnight, e.g. in a bedroom.
+	OffFrom string `json:"off_from,omitempty"`
+	OffTo   string `json:"off_to,omitempty"`
+}
+
+// Validate checks the off hours.
+func (l LEDSettings) Validate() error {
+	if (l.OffFrom == "") != (l.OffTo == "") {
+		return fmt.Errorf("leds off_from and off_to must be set together")
+	}
+	for _, t := range []string{l.OffFrom, l.OffTo} {
+		if _, err := parseClock(t); t != "" && err != nil {
+			return fmt.Errorf("leds off hours: %q is not HH:MM", t)
+		}
+	}
+	return nil
+}
+
+// InOffHours reports whether t falls within the off hours.
+func (l LEDSettings) InOffHours(t time.Time) bool {
+	return inWindow(l.OffFrom, l.OffTo, t)
+}
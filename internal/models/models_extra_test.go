@@ -2,7 +2,9 @@ package models_test
 
 import (
 	"encoding/json"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
@@ -43,6 +45,7 @@ func TestAppError_ErrorConstructors(t *testing.T) {
 		{"BadRequest", models.ErrBadRequest("bad request"), 400, "BAD_REQUEST"},
 		{"Internal", models.ErrInternal("internal error"), 500, "INTERNAL"},
 		{"Conflict", models.ErrConflict("conflict"), 409, "CONFLICT"},
+		{"BadGateway", models.ErrBadGateway("bad gateway"), 502, "BAD_GATEWAY"},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -393,3 +396,237 @@ func TestStreamConstants(t *testing.T) {
 		}
 	}
 }
+
+func TestRTPOutput_DefaultsAndValidate(t *testing.T) {
+	cfg := models.RTPOutput{Enabled: true}.WithDefaults(2)
+	if cfg.Address != "239.69.0.3" || cfg.Port != models.DefaultRTPPort || cfg.Format != "L24" {
+		t.Errorf("WithDefaults = %+v", cfg)
+	}
+	if appErr := cfg.Validate(); appErr != nil {
+		t.Errorf("defaulted config invalid: %v", appErr)
+	}
+
+	bad := cfg
+	bad.Address = "not-an-ip"
+	if bad.Validate() == nil {
+		t.Error("expected error for bad address")
+	}
+	bad = cfg
+	bad.SampleRate = 22050
+	if bad.Validate() == nil {
+		t.Error("expected error for unsupported sample rate")
+	}
+	bad = cfg
+	bad.Format = "opus"
+	if bad.Validate() == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestDeepCopy_SourceRTP(t *testing.T) {
+	s := models.DefaultState()
+	s.Sources[0].RTP = &models.RTPOutput{Enabled: true}
+	cp := s.DeepCopy()
+	cp.Sources[0].RTP.Enabled = false
+	if !s.Sources[0].RTP.Enabled {
+		t.Error("DeepCopy shares Source.RTP with the original")
+	}
+}
+
+func TestAnnounceOutput_DefaultLatency(t *testing.T) {
+	override := 350
+	for _, tc := range []struct {
+		out  models.AnnounceOutput
+		want int
+	}{
+		{models.AnnounceOutput{Type: "cast"}, models.DefaultCastLatencyMS},
+		{models.AnnounceOutput{Type: "snapcast"}, models.DefaultSnapcastLatencyMS},
+		{models.AnnounceOutput{Type: "airplay"}, models.DefaultAirPlayLatencyMS},
+		{models.AnnounceOutput{Type: "airplay", LatencyMS: &override}, 350},
+	} {
+		if got := tc.out.Latency(); got != tc.want {
+			t.Errorf("%+v latency = %d, want %d", tc.out, got, tc.want)
+		}
+	}
+}
+
+func TestAudioProcessing_Matrix(t *testing.T) {
+	for _, tc := range []struct {
+		p    models.AudioProcessing
+		want [2][2]float64
+	}{
+		{models.AudioProcessing{}, [2][2]float64{{1, 0}, {0, 1}}},
+		{models.AudioProcessing{Mono: true}, [2][2]float64{{0.5, 0.5}, {0.5, 0.5}}},
+		{models.AudioProcessing{Swap: true}, [2][2]float64{{0, 1}, {1, 0}}},
+		{models.AudioProcessing{Balance: 50}, [2][2]float64{{0.5, 0}, {0, 1}}},
+		{models.AudioProcessing{Swap: true, Balance: -100}, [2][2]float64{{0, 0}, {1, 0}}},
+	} {
+		if got := tc.p.Matrix(); got != tc.want {
+			t.Errorf("%+v matrix = %v, want %v", tc.p, got, tc.want)
+		}
+	}
+	if err := (models.AudioProcessing{Balance: 101}).Validate(); err == nil {
+		t.Error("balance 101 accepted")
+	}
+}
+
+func TestDeepCopy_SourceProcessing(t *testing.T) {
+	s := models.DefaultState()
+	s.Sources[0].Processing = &models.AudioProcessing{Mono: true}
+	cp := s.DeepCopy()
+	cp.Sources[0].Processing.Mono = false
+	if !s.Sources[0].Processing.Mono {
+		t.Error("DeepCopy shares Source.Processing with the original")
+	}
+}
+
+func TestAmpPower_OffHours(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		t, _ := time.Parse("15:04", hhmm)
+		return t
+	}
+	night := models.AmpPower{OffFrom: "22:30", OffTo: "07:00"}
+	for clock, want := range map[string]bool{"22:29": false, "22:30": true, "03:00": true, "06:59": true, "07:00": false, "12:00": false} {
+		if got := night.InOffHours(at(clock)); got != want {
+			t.Errorf("InOffHours(%s) = %v, want %v", clock, got, want)
+		}
+	}
+	day := models.AmpPower{OffFrom: "09:00", OffTo: "17:00"}
+	if !day.InOffHours(at("12:00")) || day.InOffHours(at("18:00")) {
+		t.Error("daytime off hours wrong")
+	}
+	if err := (models.AmpPower{Mode: "sometimes"}).Validate(); err == nil {
+		t.Error("invalid mode accepted")
+	}
+	if err := (models.AmpPower{OffFrom: "25:00", OffTo: "07:00"}).Validate(); err == nil {
+		t.Error("invalid clock accepted")
+	}
+	if !(models.AmpPower{Mode: models.AmpAlways}).IsDefault() || (models.AmpPower{Mode: models.AmpAuto}).IsDefault() {
+		t.Error("IsDefault wrong")
+	}
+}
+
+func TestNightMode(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		t, _ := time.Parse("15:04", hhmm)
+		return t
+	}
+	n := models.NightMode{From: "21:00", To: "07:00", VolMax: -40}
+	if err := n.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !n.Active(at("23:00")) || n.Active(at("12:00")) {
+		t.Error("Active wrong")
+	}
+	for _, bad := range []models.NightMode{
+		{From: "21:00", VolMax: -40},
+		{From: "21:00", To: "7pm", VolMax: -40},
+		{From: "21:00", To: "07:00", VolMax: 5},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+	if !(models.NightMode{}).IsZero() {
+		t.Error("IsZero wrong")
+	}
+}
+
+func TestDeepCopy_Settings(t *testing.T) {
+	s := models.DefaultState()
+	s.Settings.Bridge.Enabled = true
+	s.Settings.SourceIdle = []models.SourceIdlePolicy{{SourceID: 1, Minutes: 10}}
+	cp := s.DeepCopy()
+	if !cp.Settings.Bridge.Enabled {
+		t.Error("DeepCopy dropped the bridge setting")
+	}
+	cp.Settings.SourceIdle[0].Minutes = 20
+	if s.Settings.SourceIdle[0].Minutes != 10 {
+		t.Error("DeepCopy shares Settings.SourceIdle with the original")
+	}
+
+	zone, value := 3, 0.5
+	s.Settings.Keypad.Mappings = []models.KeypadMapping{{Message: "K1", ZoneID: &zone, Action: models.KeypadVolSet, Value: &value}}
+	cp = s.DeepCopy()
+	*cp.Settings.Keypad.Mappings[0].ZoneID = 4
+	*cp.Settings.Keypad.Mappings[0].Value = 1
+	if zone != 3 || value != 0.5 {
+		t.Error("DeepCopy shares keypad mapping pointers with the original")
+	}
+}
+
+func TestFanCurve(t *testing.T) {
+	curve := models.FanCurve{{TempC: 40, Duty: 0.2}, {TempC: 60, Duty: 0.6}, {TempC: 80, Duty: 1}}
+	if err := curve.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		tempC float32
+		want  float64
+	}{{25, 0.2}, {40, 0.2}, {50, 0.4}, {70, 0.8}, {80, 1}, {95, 1}} {
+		if got := curve.Duty(tc.tempC); got < tc.want-1e-9 || got > tc.want+1e-9 {
+			t.Errorf("Duty(%v) = %v, want %v", tc.tempC, got, tc.want)
+		}
+	}
+
+	for name, bad := range map[string]models.FanCurve{
+		"one point":     {{TempC: 40, Duty: 1}},
+		"too cold":      {{TempC: 10, Duty: 0.2}, {TempC: 60, Duty: 1}},
+		"duty above 1":  {{TempC: 40, Duty: 0.2}, {TempC: 60, Duty: 1.5}},
+		"temps fall":    {{TempC: 60, Duty: 0.2}, {TempC: 40, Duty: 1}},
+		"duty falls":    {{TempC: 40, Duty: 0.8}, {TempC: 60, Duty: 0.4}, {TempC: 80, Duty: 1}},
+		"repeated temp": {{TempC: 40, Duty: 0.2}, {TempC: 40, Duty: 1}},
+		"never full":    {{TempC: 40, Duty: 0.2}, {TempC: 80, Duty: 0.9}},
+		"full too late": {{TempC: 40, Duty: 0.2}, {TempC: 90, Duty: 1}},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil", name)
+		}
+	}
+}
+
+func TestStreamSchema_Validate(t *testing.T) {
+	sc := models.FindStreamSchema("internet_radio")
+	if sc == nil || sc.Type != models.StreamTypeInternetRadio {
+		t.Fatalf("FindStreamSchema(internet_radio) = %+v", sc)
+	}
+	if err := sc.Validate(map[string]interface{}{"url": "http://example.com/stream"}); err != nil {
+		t.Errorf("valid config rejected: %v", err)
+	}
+	err := sc.Validate(map[string]interface{}{"ulr": "http://example.com/stream", "logo": 3})
+	if err == nil {
+		t.Fatal("typo accepted")
+	}
+	for _, want := range []string{`unknown key "ulr"`, `"logo" must be a string`, `missing required key "url"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	if err := sc.ValidatePatch(map[string]interface{}{"logo": "http://example.com/logo.png", "old": nil}); err != nil {
+		t.Errorf("patch rejected: %v", err)
+	}
+	err = sc.ValidatePatch(map[string]interface{}{"url": nil, "ulr": "x", "logo": ""})
+	if err == nil {
+		t.Fatal("bad patch accepted")
+	}
+	for _, want := range []string{`required key "url" can't be deleted`, `unknown key "ulr"`} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	rca := models.FindStreamSchema(models.StreamTypeRCA)
+	if err := rca.Validate(map[string]interface{}{"index": float64(2)}); err != nil {
+		t.Errorf("JSON number index rejected: %v", err)
+	}
+	if err := rca.Validate(map[string]interface{}{"index": 1.5}); err == nil {
+		t.Error("fractional index accepted")
+	}
+	if cfg := models.FindStreamSchema("file_player").ApplyDefaults(nil); cfg["shuffle"] != false || cfg["repeat"] != false {
+		t.Errorf("ApplyDefaults = %v", cfg)
+	}
+	if models.FindStreamSchema("gramophone") != nil {
+		t.Error("unknown type has a schema")
+	}
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxNameLength is the longest zone, group, preset or stream name, in
+// characters.
+const MaxNameLength = 64
+
+// ValidateName checks that name is usable as a zone, group, preset or
+// stream name: not blank, at most MaxNameLength characters and free of
+// control characters such as newlines.
+func ValidateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("name is required")
+	}
+	if !utf8.ValidString(name) {
+		return errors.New("name is not valid UTF-8")
+	}
+	if n := utf8.RuneCountInString(name); n > MaxNameLength {
+		return fmt.Errorf("name is %d characters long; the limit is %d", n, MaxNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("name contains control character %U", r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// NightMode caps a zone's volume during quiet hours: between From and To
+// (local "HH:MM", may wrap past midnight) the zone plays no louder than
+// VolMax, e.g. a child's room limited to -40 dB after 21:00.
+type NightMode struct {
+	From   string `json:"from,omitempty"`
+	To     string `json:"to,omitempty"`
+	VolMax int    `json:"vol_max"` // dB
+}
+
+// IsZero reports whether n sets no quiet hours.
+func (n NightMode) IsZero() bool {
+	return n.From == "" && n.To == ""
+}
+
+// Validate checks the quiet hours and volume cap.
+func (n NightMode) Validate() error {
+	if n.From == "" || n.To == "" {
+		return fmt.Errorf("night from and to must be set together")
+	}
+	for _, t := range []string{n.From, n.To} {
+		if _, err := parseClock(t); err != nil {
+			return fmt.Errorf("night hours: %q is not HH:MM", t)
+		}
+	}
+	if n.VolMax < MinVolDB || n.VolMax > MaxVolDB {
+		return fmt.Errorf("night vol_max must be between %d and %d", MinVolDB, MaxVolDB)
+	}
+	return nil
+}
+
+// Active reports whether t falls within the quiet hours.
+func (n NightMode) Active(t time.Time) bool {
+	return inWindow(n.From, n.To, t)
+}
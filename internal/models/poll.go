@@ -0,0 +1,19 @@
+package models
+
+// StatePoll answers GET /api/poll: the sections of the state that changed
+// since the client's revision, for clients that poll rather than keep an
+// SSE connection open. Sections left out are unchanged; Rev is the
+// revision to poll with next.
+type StatePoll struct {
+	Rev      int64     `json:"rev"`
+	Sources  []Source  `json:"sources,omitzero"`
+	Zones    []Zone    `json:"zones,omitzero"`
+	Groups   []Group   `json:"groups,omitzero"`
+	Streams  []Stream  `json:"streams,omitzero"`
+	Presets  []Preset  `json:"presets,omitzero"`
+	Info     *Info     `json:"info,omitempty"`
+	Settings *Settings `json:"settings,omitempty"`
+}
+
+// MaxPollWait is the longest a poll waits for a change, in seconds.
+const MaxPollWait = 30
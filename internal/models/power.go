@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Power actions of POST /api/reboot and POST /api/shutdown.
+const (
+	PowerReboot   = "reboot"
+	PowerShutdown = "shutdown"
+)
+
+// PowerRequest is the body of POST /api/reboot and POST /api/shutdown.
+type PowerRequest struct {
+	Confirm string `json:"confirm,omitempty"` // token from a previous unconfirmed request
+}
+
+// PowerResponse answers a power request: a token to confirm it with, or
+// that the unit is going down.
+type PowerResponse struct {
+	Action  string     `json:"action"`
+	Status  string     `json:"status"`            // "confirm", "rebooting" or "shutting_down"
+	Confirm string     `json:"confirm,omitempty"` // send back to go ahead
+	Expires *time.Time `json:"expires,omitempty"` // when the token stops working
+}
@@ -0,0 +1,27 @@
+package models
+
+// Preset item statuses.
+const (
+	PresetItemApplied = "applied"
+	PresetItemSkipped = "skipped"
+)
+
+// PresetReport says which parts of a preset were applied when it was
+// loaded and why the rest were skipped, e.g. a source whose stream is not
+// available. It is the data of a preset_loaded event.
+type PresetReport struct {
+	PresetID int          `json:"preset_id"`
+	Name     string       `json:"name"`
+	Applied  int          `json:"applied"`
+	Skipped  int          `json:"skipped"`
+	Items    []PresetItem `json:"items"`
+}
+
+// PresetItem is the outcome of one source, zone or group update or
+// command of a preset.
+type PresetItem struct {
+	Kind   string `json:"kind"`         // "source" | "zone" | "group" | "command"
+	ID     *int   `json:"id,omitempty"` // nil if the update has none; a command's index
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"` // why it was skipped
+}
@@ -0,0 +1,49 @@
+package models
+
+import "fmt"
+
+// AudioProcessing adjusts a source's channels on its way to the preamp,
+// e.g. for rooms with a single ceiling speaker. It applies to streams,
+// which are routed through ALSA; analog RCA inputs are unaffected.
+type AudioProcessing struct {
+	Mono    bool `json:"mono"`    // sum left and right onto both channels
+	Swap    bool `json:"swap"`    // exchange left and right
+	Balance int  `json:"balance"` // -100 (left only) to 100 (right only); 0 = centered
+}
+
+// IsDefault reports whether p leaves the audio unchanged.
+func (p AudioProcessing) IsDefault() bool {
+	return p == AudioProcessing{}
+}
+
+// Validate checks the balance range.
+func (p AudioProcessing) Validate() error {
+	if p.Balance < -100 || p.Balance > 100 {
+		return fmt.Errorf("balance must be between -100 and 100")
+	}
+	return nil
+}
+
+// Matrix returns the gain of each input channel in each output channel:
+// m[in][out], with 0 = left and 1 = right.
+func (p AudioProcessing) Matrix() [2][2]float64 {
+	m := [2][2]float64{{1, 0}, {0, 1}}
+	switch {
+	case p.Mono:
+		m = [2][2]float64{{0.5, 0.5}, {0.5, 0.5}}
+	case p.Swap:
+		m = [2][2]float64{{0, 1}, {1, 0}}
+	}
+	// Balance attenuates the opposite side linearly.
+	left, right := 1.0, 1.0
+	if p.Balance > 0 {
+		left = 1 - float64(p.Balance)/100
+	} else if p.Balance < 0 {
+		right = 1 + float64(p.Balance)/100
+	}
+	for in := range m {
+		m[in][0] *= left
+		m[in][1] *= right
+	}
+	return m
+}
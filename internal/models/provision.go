@@ -0,0 +1,13 @@
+package models
+
+// Provision is an installer's template for a unit's initial configuration,
+// applied on first boot (from provision.json in the config directory), by
+// factory reset, or with POST /api/provision. Sources and zones are
+// updated by ID; groups and streams are matched by name, so applying a
+// template twice changes nothing.
+type Provision struct {
+	Sources []SourceUpdate `json:"sources,omitempty"` // id is required
+	Zones   []ZoneUpdate   `json:"zones,omitempty"`   // id is required
+	Groups  []GroupUpdate  `json:"groups,omitempty"`  // name is required
+	Streams []StreamCreate `json:"streams,omitempty"`
+}
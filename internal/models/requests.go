@@ -2,23 +2,36 @@ package models
 
 // SourceUpdate is the PATCH body for updating a source.
 type SourceUpdate struct {
-	ID    *int    `json:"id,omitempty"`
-	Name  *string `json:"name,omitempty"`
-	Input *string `json:"input,omitempty"`
+	ID       *int            `json:"id,omitempty"`
+	Name     *string         `json:"name,omitempty"`
+	Input    *string         `json:"input,omitempty"`
+	RTP      *RTPOutput      `json:"rtp,omitempty"`
+	Snapcast *SnapcastOutput `json:"snapcast,omitempty"`
+	Cast     *CastOutput     `json:"cast,omitempty"`
+
+	Processing *AudioProcessing `json:"processing,omitempty"`
+
+	RCALabel *string `json:"rca_label,omitempty"` // renames the source's RCA jack
 }
 
 // ZoneUpdate is the PATCH body for updating a zone.
 type ZoneUpdate struct {
-	ID       *int     `json:"id,omitempty"`
-	Name     *string  `json:"name,omitempty"`
-	SourceID *int     `json:"source_id,omitempty"`
-	Mute     *bool    `json:"mute,omitempty"`
-	Vol      *int     `json:"vol,omitempty"`
-	VolF     *float64 `json:"vol_f,omitempty"`
+	ID        *int     `json:"id,omitempty"`
+	Name      *string  `json:"name,omitempty"`
+	SourceID  *int     `json:"source_id,omitempty"`
+	Mute      *bool    `json:"mute,omitempty"`
+	Vol       *int     `json:"vol,omitempty"`
+	VolF      *float64 `json:"vol_f,omitempty"`
 	VolDeltaF *float64 `json:"vol_delta_f,omitempty"`
-	VolMin   *int     `json:"vol_min,omitempty"`
-	VolMax   *int     `json:"vol_max,omitempty"`
-	Disabled *bool    `json:"disabled,omitempty"`
+	VolDelta  *int     `json:"vol_delta,omitempty"` // relative change in dB
+	VolMin    *int     `json:"vol_min,omitempty"`
+	VolMax    *int     `json:"vol_max,omitempty"`
+	Disabled  *bool    `json:"disabled,omitempty"`
+
+	Amp     *AmpPower  `json:"amp,omitempty"`
+	Night   *NightMode `json:"night,omitempty"` // {} clears the quiet hours
+	Bridged *bool      `json:"bridged,omitempty"`
+	Lock    *ZoneLock  `json:"lock,omitempty"` // administrators only; {"enabled":false} unlocks
 }
 
 // MultiZoneUpdate is the PATCH body for bulk zone updates.
@@ -29,13 +42,28 @@ type MultiZoneUpdate struct {
 
 // GroupUpdate is the PATCH body for updating a group.
 type GroupUpdate struct {
-	ID       *int     `json:"id,omitempty"`
-	Name     *string  `json:"name,omitempty"`
-	ZoneIDs  []int    `json:"zones,omitempty"`
-	SourceID *int     `json:"source_id,omitempty"`
-	Vol      *int     `json:"vol_delta,omitempty"`
-	VolF     *float64 `json:"vol_f,omitempty"`
-	Mute     *bool    `json:"mute,omitempty"`
+	ID             *int     `json:"id,omitempty"`
+	Name           *string  `json:"name,omitempty"`
+	ZoneIDs        []int    `json:"zones,omitempty"`
+	SourceID       *int     `json:"source_id,omitempty"`
+	Vol            *int     `json:"vol_delta,omitempty"`
+	VolF           *float64 `json:"vol_f,omitempty"`
+	VolDeltaF      *float64 `json:"vol_delta_f,omitempty"` // relative change as a fraction of each zone's range
+	Mute           *bool    `json:"mute,omitempty"`
+	GroupIDs       []int    `json:"groups,omitempty"`        // member groups; [] removes them
+	ExcludeZoneIDs []int    `json:"exclude_zones,omitempty"` // zones left out; [] includes all again
+}
+
+// DefaultVolStepF is the vol_up/vol_down step when none is given: 5% of
+// the zone's volume range.
+const DefaultVolStepF = 0.05
+
+// VolumeStep is the optional POST body for the vol_up and vol_down
+// endpoints. Vol is a step in dB and takes precedence over VolF, a step as
+// a fraction of the zone's range. An empty body uses DefaultVolStepF.
+type VolumeStep struct {
+	Vol  *int     `json:"vol,omitempty"`
+	VolF *float64 `json:"vol_f,omitempty"`
 }
 
 // StreamCreate is the POST body for creating a stream.
@@ -51,6 +79,18 @@ type StreamUpdate struct {
 	Config map[string]interface{} `json:"config,omitempty"`
 }
 
+// AudioOutputUpdate is the POST/PATCH body for creating or editing a
+// physical output. ID is required on create and ignored on PATCH.
+type AudioOutputUpdate struct {
+	ID       *int    `json:"id,omitempty"`
+	Name     *string `json:"name,omitempty"`
+	Card     *string `json:"card,omitempty"`
+	Device   *int    `json:"device,omitempty"`
+	Channels *int    `json:"channels,omitempty"`
+	Left     *int    `json:"left,omitempty"`
+	Right    *int    `json:"right,omitempty"`
+}
+
 // PresetCreate is the POST body for creating a preset.
 type PresetCreate struct {
 	Name     string       `json:"name"`
@@ -68,10 +108,104 @@ type PresetUpdate struct {
 // AnnounceRequest is the POST body for making a PA announcement.
 // Compatible with Python's models.Announcement.
 type AnnounceRequest struct {
-	Media    string   `json:"media"`              // URL to media file
-	Vol      *int     `json:"vol,omitempty"`      // Absolute volume in dB (overrides vol_f)
-	VolF     *float64 `json:"vol_f,omitempty"`    // Relative volume 0.0-1.0 (default 0.5)
+	Media    string   `json:"media"`               // URL to media file
+	Vol      *int     `json:"vol,omitempty"`       // Absolute volume in dB (overrides vol_f)
+	VolF     *float64 `json:"vol_f,omitempty"`     // Relative volume 0.0-1.0 (default 0.5)
 	SourceID *int     `json:"source_id,omitempty"` // Source to use (default 3)
-	Zones    []int    `json:"zones,omitempty"`    // Target zone IDs (if empty, uses all enabled)
-	Groups   []int    `json:"groups,omitempty"`   // Target group IDs (if empty, uses all enabled)
+	Zones    []int    `json:"zones,omitempty"`     // Target zone IDs (if empty, uses all enabled)
+	Groups   []int    `json:"groups,omitempty"`    // Target group IDs (if empty, uses all enabled)
+	Cast     []string `json:"cast,omitempty"`      // Google Cast device IDs to also play the announcement on
+
+	// Outputs are network speakers that also play the announcement. Each
+	// starts early by its latency so the chime is heard in sync everywhere.
+	Outputs       []AnnounceOutput `json:"outputs,omitempty"`
+	ZoneLatencyMS *int             `json:"zone_latency_ms,omitempty"` // wired zone start-up delay (default 0)
+
+	// Mode "duck" leaves zones playing music on their source, turns the
+	// music down by DuckDB and mixes the announcement on top. Zones with
+	// nothing playing are taken over as in the default mode, "takeover".
+	Mode   string `json:"mode,omitempty"`    // "takeover" (default) | "duck"
+	DuckDB *int   `json:"duck_db,omitempty"` // music attenuation in duck mode (default 20)
+}
+
+// Announcement modes.
+const (
+	AnnounceTakeover = "takeover" // target zones switch to the announcement source
+	AnnounceDuck     = "duck"     // music is turned down and the announcement mixed in
+)
+
+// DefaultDuckDB is how far duck mode turns the music down, in dB.
+const DefaultDuckDB = 20
+
+// Default playback latencies of announcement outputs, in milliseconds.
+const (
+	DefaultCastLatencyMS     = 2000 // Cast receivers buffer about two seconds of HTTP audio
+	DefaultSnapcastLatencyMS = 1000 // the managed snapserver's buffer
+	DefaultAirPlayLatencyMS  = 2000 // requested from the receiver by raop_play
+)
+
+// AnnounceOutput is a network speaker included in an announcement.
+type AnnounceOutput struct {
+	Type      string `json:"type"`                 // "cast" | "snapcast" | "airplay"
+	ID        string `json:"id"`                   // Cast device ID, Snapcast client ID, or AirPlay "host[:port]"
+	LatencyMS *int   `json:"latency_ms,omitempty"` // overrides the default for Type
+}
+
+// Latency returns the output's playback latency in milliseconds.
+func (o AnnounceOutput) Latency() int {
+	if o.LatencyMS != nil {
+		return *o.LatencyMS
+	}
+	switch o.Type {
+	case "cast":
+		return DefaultCastLatencyMS
+	case "snapcast":
+		return DefaultSnapcastLatencyMS
+	case "airplay":
+		return DefaultAirPlayLatencyMS
+	}
+	return 0
+}
+
+// Zone identify modes.
+const (
+	IdentifyTone  = "tone"  // left, right, then both channels
+	IdentifyVoice = "voice" // the zone name, spoken by espeak-ng
+)
+
+// Identify volume limits: quiet enough to be safe on any speaker.
+const (
+	DefaultIdentifyVolF = 0.3
+	MaxIdentifyVolF     = 0.5
+)
+
+// ZoneIdentify is the optional POST body for identifying a zone.
+type ZoneIdentify struct {
+	Mode     string   `json:"mode,omitempty"`      // "tone" (default) | "voice"
+	VolF     *float64 `json:"vol_f,omitempty"`     // default DefaultIdentifyVolF, at most MaxIdentifyVolF
+	SourceID *int     `json:"source_id,omitempty"` // source to borrow (default 3)
+}
+
+// Speaker test signals.
+const (
+	SpeakerTestChannels = "channels" // left, right, then both channels
+	SpeakerTestSweep    = "sweep"    // logarithmic sine sweep on both channels
+)
+
+// SpeakerTest is the optional POST body for the speaker test diagnostics.
+type SpeakerTest struct {
+	Zones    []int    `json:"zones,omitempty"`     // default: all enabled zones
+	Tests    []string `json:"tests,omitempty"`     // default: channels and sweep
+	VolF     *float64 `json:"vol_f,omitempty"`     // default DefaultIdentifyVolF, at most MaxIdentifyVolF
+	SourceID *int     `json:"source_id,omitempty"` // source to borrow (default 3)
+}
+
+// PartyRequest is the POST body for party mode: every selected zone plays
+// one source. The previous routing is restored by DELETE /api/party.
+type PartyRequest struct {
+	SourceID int      `json:"source_id"`
+	Zones    []int    `json:"zones,omitempty"`  // default: all enabled zones
+	Groups   []int    `json:"groups,omitempty"` // zones of these groups are added to Zones
+	Vol      *int     `json:"vol,omitempty"`    // absolute volume in dB (overrides vol_f)
+	VolF     *float64 `json:"vol_f,omitempty"`  // volume 0.0-1.0; default: keep each zone's volume
 }
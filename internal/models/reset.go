@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Factory reset scopes, from least to most thorough.
+const (
+	ResetAudio  = "audio"  // sources, zones, groups and presets
+	ResetConfig = "config" // the whole saved config, streams and their pairings included
+	ResetFull   = "full"   // the config and users.json: every password and app key
+)
+
+// FactoryResetRequest is the optional body of POST /api/factory_reset.
+type FactoryResetRequest struct {
+	Scope   string `json:"scope,omitempty"`   // default ResetConfig
+	Confirm string `json:"confirm,omitempty"` // token from a previous unconfirmed request
+}
+
+// FactoryResetResponse answers a factory reset request: a token to confirm
+// it with, or the state after the reset.
+type FactoryResetResponse struct {
+	Scope   string     `json:"scope"`
+	Status  string     `json:"status"`            // "confirm" or "reset"
+	Confirm string     `json:"confirm,omitempty"` // send back to go ahead
+	Expires *time.Time `json:"expires,omitempty"` // when the token stops working
+	State   *State     `json:"state,omitempty"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Revision is a change to the routing or volumes of sources, zones or
+// groups that can be undone, e.g. a group's volume set to max by mistake.
+type Revision struct {
+	ID      int       `json:"id"`
+	Time    time.Time `json:"time"`
+	Changes []string  `json:"changes"` // what changed, e.g. "zone 3", "group 1"
+}
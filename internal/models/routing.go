@@ -0,0 +1,42 @@
+package models
+
+// AudioRouting is the audio path of every source as it is running, checked
+// against the state: which stream feeds each source, the vsrc and ALSA
+// devices carrying it and the physical output it plays to. Problems says
+// why a source routed to a stream would play nothing.
+type AudioRouting struct {
+	VSRCs      int           `json:"vsrcs"`      // virtual source slots in the audio layout
+	VSRCsUsed  int           `json:"vsrcs_used"` // slots allocated to streams
+	Sources    []SourceRoute `json:"sources"`
+	Streams    []StreamRoute `json:"streams"` // streams the stream manager runs
+	Consistent bool          `json:"consistent"`
+	Problems   []string      `json:"problems"`
+}
+
+// SourceRoute is the audio path of one source.
+type SourceRoute struct {
+	ID       int    `json:"id"`
+	Input    string `json:"input"`
+	StreamID *int   `json:"stream_id,omitempty"` // stream selected by Input
+	Analog   bool   `json:"analog"`              // preamp source set to its analog input
+	VSRC     *int   `json:"vsrc,omitempty"`      // vsrc of the stream playing, nil = none
+	Device   string `json:"device,omitempty"`    // ALSA PCM the stream plays into, e.g. "lb0c"
+	Capture  string `json:"capture,omitempty"`   // ALSA PCM alsaloop reads, e.g. "lb0p"
+	Output   string `json:"output,omitempty"`    // physical ALSA PCM alsaloop writes, e.g. "ch2"
+	Card     string `json:"card,omitempty"`      // ALSA card of Output
+	Present  bool   `json:"present"`             // Output's card is plugged in
+	Zones    []int  `json:"zones"`               // zones playing the source
+}
+
+// StreamRoute is a stream as the stream manager has it.
+type StreamRoute struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Active  bool   `json:"active"`            // activated (process running or ready)
+	VSRC    int    `json:"vsrc"`              // -1 = none allocated
+	Source  int    `json:"source"`            // source connected to, -1 = none
+	Device  string `json:"device,omitempty"`  // ALSA PCM the stream plays into
+	Capture string `json:"capture,omitempty"` // ALSA PCM alsaloop reads
+	Output  string `json:"output,omitempty"`  // physical ALSA PCM alsaloop writes
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"fmt"
+	"net"
+)
+
+// RTP output defaults. L24 at 48 kHz with 1 ms packets is the AES67 baseline
+// profile; receivers that only handle "simple" RTP can use L16.
+const (
+	DefaultRTPPort       = 5004
+	DefaultRTPSampleRate = 48000
+	DefaultRTPLatencyMS  = 20
+	DefaultRTPFormat     = "L24"
+	DefaultRTPTTL        = 16
+)
+
+// RTPOutput configures multicasting a source's audio as an RTP stream.
+// Compatible with AES67 receivers when Format is "L24" and SampleRate is 48000.
+type RTPOutput struct {
+	Enabled    bool   `json:"enabled"`
+	Address    string `json:"address,omitempty"`     // destination; default 239.69.0.{sid+1}
+	Port       int    `json:"port,omitempty"`        // UDP port; default 5004
+	SampleRate int    `json:"sample_rate,omitempty"` // 44100, 48000 or 96000; default 48000
+	LatencyMS  int    `json:"latency_ms,omitempty"`  // capture buffer; default 20
+	Format     string `json:"format,omitempty"`      // "L24" (default) or "L16"
+	TTL        int    `json:"ttl,omitempty"`         // multicast TTL; default 16
+}
+
+// WithDefaults returns a copy of r with unset fields filled in for source sid.
+func (r RTPOutput) WithDefaults(sid int) RTPOutput {
+	if r.Address == "" {
+		r.Address = fmt.Sprintf("239.69.0.%d", sid+1)
+	}
+	if r.Port == 0 {
+		r.Port = DefaultRTPPort
+	}
+	if r.SampleRate == 0 {
+		r.SampleRate = DefaultRTPSampleRate
+	}
+	if r.LatencyMS == 0 {
+		r.LatencyMS = DefaultRTPLatencyMS
+	}
+	if r.Format == "" {
+		r.Format = DefaultRTPFormat
+	}
+	if r.TTL == 0 {
+		r.TTL = DefaultRTPTTL
+	}
+	return r
+}
+
+// Validate checks a defaulted RTP configuration.
+func (r RTPOutput) Validate() *AppError {
+	if net.ParseIP(r.Address) == nil {
+		return ErrBadRequest(fmt.Sprintf("rtp address %q is not an IP address", r.Address))
+	}
+	if r.Port < 1 || r.Port > 65535 {
+		return ErrBadRequest("rtp port must be 1-65535")
+	}
+	switch r.SampleRate {
+	case 44100, 48000, 96000:
+	default:
+		return ErrBadRequest("rtp sample_rate must be 44100, 48000 or 96000")
+	}
+	if r.LatencyMS < 1 || r.LatencyMS > 1000 {
+		return ErrBadRequest("rtp latency_ms must be 1-1000")
+	}
+	if r.Format != "L16" && r.Format != "L24" {
+		return ErrBadRequest(`rtp format must be "L16" or "L24"`)
+	}
+	if r.TTL < 1 || r.TTL > 255 {
+		return ErrBadRequest("rtp ttl must be 1-255")
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package models
+
+// Secrets are the write-only values of a State. They are left out of the
+// State's JSON so the API never serves them, and are persisted on their
+// own, readable only by the daemon.
+type Secrets struct {
+	Webhooks       map[int]string `json:"webhooks,omitempty"`        // signing secret by webhook ID
+	MatterPasscode int            `json:"matter_passcode,omitempty"` // Matter setup passcode
+}
+
+// Secrets returns the write-only values of s.
+func (s *State) Secrets() Secrets {
+	sec := Secrets{MatterPasscode: s.Settings.Matter.Passcode}
+	for _, w := range s.Settings.Webhooks {
+		if w.Secret != "" {
+			if sec.Webhooks == nil {
+				sec.Webhooks = make(map[int]string)
+			}
+			sec.Webhooks[w.ID] = w.Secret
+		}
+	}
+	return sec
+}
+
+// SetSecrets restores write-only values returned by Secrets.
+func (s *State) SetSecrets(sec Secrets) {
+	s.Settings.Matter.Passcode = sec.MatterPasscode
+	for i := range s.Settings.Webhooks {
+		s.Settings.Webhooks[i].Secret = sec.Webhooks[s.Settings.Webhooks[i].ID]
+	}
+}
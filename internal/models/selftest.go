@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Self-test check results.
+const (
+	SelfTestPass = "pass"
+	SelfTestFail = "fail"
+	SelfTestSkip = "skip" // not applicable here, e.g. ALSA cards on mock hardware
+)
+
+// SelfTest is the result of the hardware self-test run at startup, kept so
+// support can see at once what failed on a misbehaving unit.
+type SelfTest struct {
+	Time     time.Time       `json:"time"`
+	Duration float64         `json:"duration"` // seconds
+	Passed   bool            `json:"passed"`   // no check failed
+	Failed   int             `json:"failed"`   // checks that failed
+	Checks   []SelfTestCheck `json:"checks"`
+}
+
+// SelfTestCheck is one check of the self-test.
+type SelfTestCheck struct {
+	Check  string `json:"check"`            // "i2c", "temperature", "power", "loopback" or "stream"
+	Target string `json:"target"`           // what was checked, e.g. "unit 1", "Loopback2", "spotify"
+	Result string `json:"result"`           // SelfTestPass, SelfTestFail or SelfTestSkip
+	Detail string `json:"detail,omitempty"` // what was found, or why it failed
+}
@@ -0,0 +1,105 @@
+package models
+
+// Settings are system-wide options edited through /api/settings.
+type Settings struct {
+	// FriendlyName is the name the unit is advertised under over mDNS,
+	// set through PATCH /api/system/hostname. Empty uses the hostname.
+	FriendlyName string `json:"friendly_name,omitempty"`
+
+	// SourceIdle turns sources off when their stream has been stopped or
+	// paused for a while.
+	SourceIdle []SourceIdlePolicy `json:"source_idle,omitempty"`
+
+	// Bridge exposes zones as smart-home lights for voice assistants.
+	Bridge BridgeSettings `json:"bridge"`
+
+	// Matter holds the pairing credentials of the Matter speaker device.
+	Matter MatterSettings `json:"matter"`
+
+	// Keypad maps RS-485 wall keypad messages to zone and group actions.
+	Keypad KeypadSettings `json:"keypad"`
+
+	// LEDs drive the front-panel LEDs from zone activity.
+	LEDs LEDSettings `json:"leds"`
+
+	// UnitPower powers down amplifier units while nothing plays on them.
+	UnitPower UnitPowerSettings `json:"unit_power"`
+
+	// FanCurve drives the fans of PWM and linear fan control, edited
+	// through /api/hardware/fans. Empty leaves them to the firmware.
+	FanCurve FanCurve `json:"fan_curve,omitempty"`
+
+	// SilenceWatchdog reports, and can restart, streams that play silence.
+	SilenceWatchdog SilenceWatchdog `json:"silence_watchdog"`
+
+	// Triggers are GPIO amplifier triggers, edited through
+	// /api/hardware/triggers.
+	Triggers []Trigger `json:"triggers,omitempty"`
+
+	// Webhooks receive events as HTTP POSTs, edited through
+	// /api/webhooks.
+	Webhooks []Webhook `json:"webhooks,omitempty"`
+
+	// CrossfadeMS ramps a zone's volume down and back up over this long
+	// when its source changes, and down or up when it is muted or
+	// unmuted, so the switch does not pop. 0 switches at once.
+	CrossfadeMS int `json:"crossfade_ms,omitempty"`
+
+	// SoftStartMS ramps unmuted zones up from silence over this long when
+	// the daemon starts or their amp is enabled, and down before the amps
+	// are turned off at shutdown. 0 switches at once.
+	SoftStartMS int `json:"soft_start_ms,omitempty"`
+
+	// VolumeUnits is the units clients should show volumes in, and the
+	// units of the volume field of zone endpoints without ?units=. Empty
+	// leaves zone responses as they are.
+	VolumeUnits string `json:"volume_units,omitempty"`
+}
+
+// Volume units for Settings.VolumeUnits and the units query parameter.
+const (
+	VolumeUnitsDB      = "db"      // attenuation in dB, as vol
+	VolumeUnitsPercent = "percent" // 0-100, as vol_f
+)
+
+// MaxCrossfadeMS bounds Settings.CrossfadeMS and Settings.SoftStartMS.
+const MaxCrossfadeMS = 5000
+
+// SourceIdlePolicy disconnects a source's stream, mutes the zones playing
+// it and frees its virtual source once the stream has been stopped or
+// paused for Minutes. Minutes of 0 disables the policy.
+type SourceIdlePolicy struct {
+	SourceID int `json:"source_id"`
+	Minutes  int `json:"minutes"`
+}
+
+// BridgeSettings control the local smart-home bridge, which emulates a
+// Philips Hue bridge so Alexa and other assistants can turn zones on and
+// off (unmute/mute) and set their volume (brightness) without a cloud
+// skill.
+type BridgeSettings struct {
+	Enabled bool `json:"enabled"`
+}
+
+// MatterSettings are the setup credentials encoded in the Matter QR code.
+// They are generated by POST /api/matter/commissioning rather than edited;
+// a zero passcode means none have been generated. The passcode is
+// write-only, persisted with the other Secrets.
+type MatterSettings struct {
+	Discriminator int `json:"discriminator"`
+	Passcode      int `json:"-"`
+}
+
+// SettingsUpdate is the PATCH body for /api/settings. Absent fields are
+// left unchanged; an empty source_idle list clears every policy.
+type SettingsUpdate struct {
+	SourceIdle      []SourceIdlePolicy `json:"source_idle"`
+	Bridge          *BridgeSettings    `json:"bridge,omitempty"`
+	Keypad          *KeypadSettings    `json:"keypad,omitempty"`
+	LEDs            *LEDSettings       `json:"leds,omitempty"`
+	UnitPower       *UnitPowerSettings `json:"unit_power,omitempty"`
+	SilenceWatchdog *SilenceWatchdog   `json:"silence_watchdog,omitempty"`
+	CrossfadeMS     *int               `json:"crossfade_ms,omitempty"`
+	SoftStartMS     *int               `json:"soft_start_ms,omitempty"`
+	VolumeUnits     *string            `json:"volume_units,omitempty"` // "" clears it
+}
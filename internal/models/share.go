@@ -0,0 +1,31 @@
+package models
+
+// NetworkShare is an SMB or NFS share mounted into the media library. The
+// password is never returned.
+type NetworkShare struct {
+	ID          int    `json:"id"`
+	Name        string `json:"name"`   // folder in the media library
+	Type        string `json:"type"`   // "smb" or "nfs"
+	Server      string `json:"server"` // host name or address
+	Path        string `json:"path"`   // SMB share name or NFS export path
+	Username    string `json:"username,omitempty"`
+	HasPassword bool   `json:"has_password"`
+	Options     string `json:"options,omitempty"`
+	Disabled    bool   `json:"disabled"`
+	MountPoint  string `json:"mount_point"`
+	Mounted     bool   `json:"mounted"`
+	Error       string `json:"error,omitempty"` // last mount failure
+}
+
+// NetworkShareUpdate is the POST/PATCH body for creating or editing a
+// network share. Name, type, server and path are required on create.
+type NetworkShareUpdate struct {
+	Name     *string `json:"name,omitempty"`
+	Type     *string `json:"type,omitempty"`
+	Server   *string `json:"server,omitempty"`
+	Path     *string `json:"path,omitempty"`
+	Username *string `json:"username,omitempty"`
+	Password *string `json:"password,omitempty"`
+	Options  *string `json:"options,omitempty"`
+	Disabled *bool   `json:"disabled,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// SilenceWatchdog catches streams that hang while claiming to play, e.g. a
+// pianobar or librespot that lost its connection: when a source's stream
+// reports "playing" but its audio stays below SilenceThresholdDB for
+// Minutes, a stream_silent event is emitted and, with Restart, the
+// stream's process is restarted.
+type SilenceWatchdog struct {
+	Enabled bool `json:"enabled"`
+	Minutes int  `json:"minutes,omitempty"` // 0 = DefaultSilenceMinutes
+	Restart bool `json:"restart,omitempty"`
+}
+
+// DefaultSilenceMinutes is how long a playing stream may be silent before
+// the watchdog reports it.
+const DefaultSilenceMinutes = 3
+
+// SilenceThresholdDB is the peak level, in dBFS, below which a source
+// counts as silent. Quiet passages of music stay well above it.
+const SilenceThresholdDB = -70
+
+// Timeout returns how long a playing stream may be silent.
+func (w SilenceWatchdog) Timeout() time.Duration {
+	if w.Minutes > 0 {
+		return time.Duration(w.Minutes) * time.Minute
+	}
+	return DefaultSilenceMinutes * time.Minute
+}
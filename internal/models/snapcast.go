@@ -0,0 +1,58 @@
+package models
+
+// SnapcastOutput configures feeding a source into the managed snapserver so
+// Snapcast clients can play it in sync with the wired zones.
+type SnapcastOutput struct {
+	Enabled bool   `json:"enabled"`
+	Codec   string `json:"codec,omitempty"` // "flac" (default), "pcm", "opus" or "ogg"
+}
+
+// SnapcastStatus is the state of the managed snapserver.
+type SnapcastStatus struct {
+	Groups  []SnapGroup  `json:"groups"`
+	Streams []SnapStream `json:"streams"`
+}
+
+// SnapGroup is a set of Snapcast clients playing the same stream.
+type SnapGroup struct {
+	ID       string       `json:"id"`
+	Name     string       `json:"name"`
+	StreamID string       `json:"stream_id"`
+	SourceID *int         `json:"source_id,omitempty"` // set if the stream is an AmpliPi source
+	Muted    bool         `json:"muted"`
+	Clients  []SnapClient `json:"clients"`
+}
+
+// SnapClient is a Snapcast playback device.
+type SnapClient struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Host      string `json:"host"`
+	Connected bool   `json:"connected"`
+	Latency   int    `json:"latency"` // additional playout delay in ms
+	Volume    int    `json:"volume"`  // 0-100
+	Muted     bool   `json:"muted"`
+}
+
+// SnapStream is an audio stream served by snapserver.
+type SnapStream struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"` // "idle" | "playing" | "unknown"
+	SourceID *int   `json:"source_id,omitempty"`
+}
+
+// SnapClientUpdate is the PATCH body for a Snapcast client.
+type SnapClientUpdate struct {
+	Name    *string `json:"name,omitempty"`
+	Latency *int    `json:"latency,omitempty"`
+	Volume  *int    `json:"volume,omitempty"`
+	Mute    *bool   `json:"mute,omitempty"`
+}
+
+// SnapGroupUpdate is the PATCH body for a Snapcast group.
+type SnapGroupUpdate struct {
+	Name     *string  `json:"name,omitempty"`
+	Clients  []string `json:"clients,omitempty"`
+	SourceID *int     `json:"source_id,omitempty"`
+	Mute     *bool    `json:"mute,omitempty"`
+}
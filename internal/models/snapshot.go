@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// Snapshot is a saved copy of the routing and volumes of every source,
+// zone and group, for automations to put back later, e.g. after a "movie
+// mode". Snapshots are kept in memory only.
+type Snapshot struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
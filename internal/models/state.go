@@ -2,47 +2,152 @@
 // JSON field names match the Python implementation exactly for wire compatibility.
 package models
 
+import "github.com/micro-nova/amplipi-go/internal/hardware"
+
 // Source represents one of the 4 audio inputs. Each can have a stream connected.
 type Source struct {
-	ID    int    `json:"id"`
-	Name  string `json:"name"`
-	Input string `json:"input"` // "" | "local" | "stream=<id>" | "RCA" | "aux"
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Input    string          `json:"input"`              // "" | "local" | "stream=<id>" | "RCA" | "aux"
+	RTP      *RTPOutput      `json:"rtp,omitempty"`      // optional network (RTP/AES67) output
+	Snapcast *SnapcastOutput `json:"snapcast,omitempty"` // optional feed into the managed snapserver
+	Cast     *CastOutput     `json:"cast,omitempty"`     // optional Google Cast devices playing this source
+
+	Processing *AudioProcessing `json:"processing,omitempty"` // mono downmix, channel swap, balance
+
+	// RCALabel names the source's RCA jack, e.g. "Turntable". It is the
+	// name of the jack's RCA stream, so it is saved with the streams and
+	// kept apart from the source name; empty on units without RCA inputs.
+	RCALabel string `json:"rca_label,omitempty"`
+
+	// Zones are the enabled zones routed to the source and Listeners how
+	// many of them are unmuted. Computed by the controller on every change.
+	Zones     []int `json:"zones"`
+	Listeners int   `json:"listeners"`
 }
 
-// Zone represents one of up to 36 amplified outputs.
+// Zone represents one amplified output; each preamp unit drives six.
 type Zone struct {
 	ID       int     `json:"id"`
 	Name     string  `json:"name"`
 	SourceID int     `json:"source_id"`
 	Mute     bool    `json:"mute"`
-	Vol      int     `json:"vol"`     // dB attenuation, range [-80, 0]
-	VolF     float64 `json:"vol_f"`   // Volume as float [0.0, 1.0]
-	VolMin   int     `json:"vol_min"` // default -80
-	VolMax   int     `json:"vol_max"` // default 0
+	Vol      int     `json:"vol"`      // dB attenuation, range [-80, 0]
+	VolF     float64 `json:"vol_f"`    // Volume as float [0.0, 1.0]
+	VolMin   int     `json:"vol_min"`  // default -80
+	VolMax   int     `json:"vol_max"`  // default 0
 	Disabled bool    `json:"disabled"` // hardware not present
+
+	Amp *AmpPower `json:"amp,omitempty"` // amplifier power mode; nil = always on
+
+	Night    *NightMode `json:"night,omitempty"`     // quiet hours volume cap; nil = none
+	VolLimit *int       `json:"vol_limit,omitempty"` // cap in force now, from night mode or the lock
+
+	// Lock is the zone's guest mode; nil when unlocked. UIs grey out the
+	// source and the volume above the cap of a locked zone.
+	Lock *ZoneLock `json:"lock,omitempty"`
+
+	// Bridged drives this zone's channel and the next one (its partner) as
+	// one bridged, higher-power output. Only the first zone of a channel
+	// pair (1+2, 3+4, 5+6 on each unit) can be bridged; the partner then
+	// mirrors this zone and cannot be controlled on its own.
+	Bridged bool `json:"bridged,omitempty"`
+
+	// Calibration evens out loudness between zones; see ZoneCalibration.
+	Calibration *ZoneCalibration `json:"calibration,omitempty"`
 }
 
 // Group is a named collection of zones controlled together.
 type Group struct {
-	ID       int     `json:"id"`
-	Name     string  `json:"name"`
-	ZoneIDs  []int   `json:"zones"`
-	SourceID *int    `json:"source_id,omitempty"` // nullable
-	Vol      *int    `json:"vol_delta,omitempty"` // nullable — average vol delta from zone base
-	VolF     *float64 `json:"vol_f,omitempty"`    // nullable — average vol as float
-	Mute     *bool   `json:"mute,omitempty"`      // nullable
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	ZoneIDs  []int    `json:"zones"`
+	SourceID *int     `json:"source_id,omitempty"` // nullable
+	Vol      *int     `json:"vol_delta,omitempty"` // nullable — average vol delta from zone base
+	VolF     *float64 `json:"vol_f,omitempty"`     // nullable — average vol as float
+	Mute     *bool    `json:"mute,omitempty"`      // nullable
+
+	// GroupIDs are member groups whose zones belong to this group too,
+	// e.g. "Downstairs" = "Kitchen" + "Living Room". ExcludeZoneIDs are
+	// left out of the zones so gathered, e.g. "Whole house" but the
+	// nursery.
+	GroupIDs       []int `json:"groups,omitempty"`
+	ExcludeZoneIDs []int `json:"exclude_zones,omitempty"`
 }
 
 // StreamInfo is the runtime status of a stream (what it's playing, album art URL, etc.)
 type StreamInfo struct {
 	Name     string `json:"name"`
-	State    string `json:"state"` // "playing" | "paused" | "stopped" | "disconnected" | "loading"
+	State    string `json:"state"` // "playing" | "paused" | "stopped" | "disconnected" | "loading" | "unavailable"
 	Track    string `json:"track,omitempty"`
 	Artist   string `json:"artist,omitempty"`
 	Album    string `json:"album,omitempty"`
 	Station  string `json:"station,omitempty"`
 	ImageURL string `json:"img_url,omitempty"`
 	Rating   *int   `json:"rating,omitempty"`
+	// Reason says why a stream is unavailable, e.g. its binary is missing.
+	Reason string `json:"reason,omitempty"`
+	// Supervisor is the state of the stream's main process, for streams
+	// that run one (pianobar, go-librespot, shairport-sync, ...).
+	Supervisor *SupervisorStatus `json:"supervisor,omitempty"`
+	// Pairing is the zeroconf pairing of streams that users select from
+	// an app rather than log in to (Spotify Connect).
+	Pairing *PairingStatus `json:"pairing,omitempty"`
+	// AirPlayActive is true while an AirPlay sender (e.g. an iPhone) is
+	// connected and driving the stream; AirPlay describes the session.
+	AirPlayActive bool            `json:"airplay_active,omitempty"`
+	AirPlay       *AirPlaySession `json:"airplay,omitempty"`
+	// Bluetooth is the phone connected to a Bluetooth stream, if any.
+	Bluetooth *BluetoothDevice `json:"bluetooth,omitempty"`
+	// Stale marks metadata restored from before a restart, kept until the
+	// stream reports metadata of its own.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// BluetoothDevice is a device connected to a Bluetooth stream.
+type BluetoothDevice struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// AirPlaySession is the sender playing to an AirPlay stream, as reported
+// on shairport-sync's metadata channel.
+type AirPlaySession struct {
+	Client     string `json:"client,omitempty"` // sender's device name
+	ClientIP   string `json:"client_ip,omitempty"`
+	DACPID     string `json:"dacp_id,omitempty"`     // identifies the sender's session
+	StreamType string `json:"stream_type,omitempty"` // "Realtime" or "Buffered" (AirPlay 2)
+	// Group lists the other AirPlay streams the same sender is playing to,
+	// i.e. the AmpliPi members of an AirPlay 2 multi-room group.
+	Group []int `json:"group,omitempty"`
+}
+
+// Pairing states.
+const (
+	PairingWaiting = "waiting" // no account yet; pick the device in the app
+	PairingPaired  = "paired"
+)
+
+// PairingStatus reports whether a stream has an account's credentials.
+type PairingStatus struct {
+	State string `json:"state"`
+	User  string `json:"user,omitempty"` // the paired account
+}
+
+// Supervisor states.
+const (
+	SupervisorRunning    = "running"
+	SupervisorRestarting = "restarting" // exited; waiting to be restarted
+	SupervisorFailed     = "failed"     // gave up; see Reason
+	SupervisorStopped    = "stopped"
+)
+
+// SupervisorStatus is the health of a supervised stream process.
+type SupervisorStatus struct {
+	Process  string `json:"process"`
+	State    string `json:"state"`
+	Reason   string `json:"reason,omitempty"` // why the process last exited or failed
+	Restarts int    `json:"restarts"`         // restarts since the stream was activated
 }
 
 // Stream is a configured audio source (Pandora, AirPlay, etc.)
@@ -55,6 +160,8 @@ type Stream struct {
 	// Flat stream-type-specific fields for JSON compatibility with Python
 	Disabled  *bool `json:"disabled,omitempty"`
 	Browsable *bool `json:"browsable,omitempty"`
+	// Active reports whether an RCA input has signal (Rev4+ hardware only)
+	Active *bool `json:"active,omitempty"`
 }
 
 // Preset is a saved system state snapshot.
@@ -81,42 +188,148 @@ type Command struct {
 
 // Info is the system information response.
 type Info struct {
-	Version  string `json:"version"`
-	UnitID   int    `json:"unit_id,omitempty"`
-	IsUpdate bool   `json:"is_update,omitempty"`
-	Offline  bool   `json:"offline"`
+	Version      string `json:"version"`
+	UnitID       int    `json:"unit_id,omitempty"`
+	Serial       string `json:"serial,omitempty"`        // main unit serial number, from its EEPROM
+	Hostname     string `json:"hostname,omitempty"`      // OS hostname, reachable as <hostname>.local
+	FriendlyName string `json:"friendly_name,omitempty"` // mDNS instance name
+	IsUpdate     bool   `json:"is_update,omitempty"`
+	Offline      bool   `json:"offline"`
 	// Hardware info (populated at boot from detected hardware profile)
-	Units           int      `json:"units,omitempty"`            // total detected preamp units
-	Zones           int      `json:"zones,omitempty"`            // total zone count across all units
-	FirmwareVersion string   `json:"firmware_version,omitempty"` // e.g. "1.7-abc12345"
-	FanMode         string   `json:"fan_mode,omitempty"`         // "pwm", "linear", "external", "forced"
-	AvailableStreams []string `json:"available_streams,omitempty"` // stream types with binaries present
+	Units            int           `json:"units,omitempty"`             // total detected preamp units
+	Zones            int           `json:"zones,omitempty"`             // total zone count across all units
+	FirmwareVersion  string        `json:"firmware_version,omitempty"`  // e.g. "1.7-abc12345"
+	FanMode          string        `json:"fan_mode,omitempty"`          // "pwm", "linear", "external", "forced"
+	AvailableStreams []string      `json:"available_streams,omitempty"` // stream types with binaries present
+	Streamer         bool          `json:"streamer,omitempty"`          // streamer-only unit: no zones or groups
+	UnitDetails      []UnitSummary `json:"unit_details,omitempty"`      // per unit: zones, firmware and temperatures
+	// Override applied to the detected hardware profile; the fields above
+	// show its effect
+	ProfileOverride *hardware.ProfileOverride `json:"profile_override,omitempty"`
+	// Result of the hardware self-test run at startup
+	SelfTest *SelfTest `json:"self_test,omitempty"`
+	// Hardware writes that are currently failing; cleared at startup
+	HardwareErrors []HardwareError `json:"hardware_errors,omitempty"`
+}
+
+// AudioOutput is a physical ALSA output (pcm "ch{ID}") that source {ID}
+// plays to. On streamer-only units these are typically USB DACs.
+type AudioOutput struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name,omitempty"`
+	Card     string `json:"card"`     // ALSA card ID
+	Device   int    `json:"device"`   // ALSA device on the card
+	Channels int    `json:"channels"` // total channels on the card
+	Left     int    `json:"left"`     // card channel carrying the left signal
+	Right    int    `json:"right"`    // card channel carrying the right signal
+	PCM      string `json:"pcm"`      // ALSA PCM alsaloop writes to
+	Present  bool   `json:"present"`  // card is currently plugged in
+	USB      bool   `json:"usb"`      // card is a USB audio device
+}
+
+// AudioCard is an ALSA sound card currently registered with the kernel.
+type AudioCard struct {
+	Index int    `json:"index"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	USB   bool   `json:"usb"`
 }
 
 // State is the complete system state returned by GET /api.
 // Corresponds to Python's models.Status.
 type State struct {
-	Sources []Source `json:"sources"`
-	Zones   []Zone   `json:"zones"`
-	Groups  []Group  `json:"groups"`
-	Streams []Stream `json:"streams"`
-	Presets []Preset `json:"presets"`
-	Info    Info     `json:"info"`
+	Sources  []Source `json:"sources"`
+	Zones    []Zone   `json:"zones"`
+	Groups   []Group  `json:"groups"`
+	Streams  []Stream `json:"streams"`
+	Presets  []Preset `json:"presets"`
+	Info     Info     `json:"info"`
+	Settings Settings `json:"settings"`
 }
 
 // deepCopy returns a deep copy of the state.
 func (s State) DeepCopy() State {
 	next := State{
-		Info: s.Info,
+		Info:     s.Info,
+		Settings: s.Settings,
+	}
+	next.Settings.SourceIdle = append([]SourceIdlePolicy(nil), s.Settings.SourceIdle...)
+	next.Settings.Keypad.Mappings = nil
+	for _, m := range s.Settings.Keypad.Mappings {
+		next.Settings.Keypad.Mappings = append(next.Settings.Keypad.Mappings, m.clone())
+	}
+	next.Settings.FanCurve = append(FanCurve(nil), s.Settings.FanCurve...)
+	next.Settings.Triggers = nil
+	for _, t := range s.Settings.Triggers {
+		t.Zones = append([]int(nil), t.Zones...)
+		t.Sources = append([]int(nil), t.Sources...)
+		next.Settings.Triggers = append(next.Settings.Triggers, t)
+	}
+	next.Settings.Webhooks = nil
+	for _, w := range s.Settings.Webhooks {
+		w.Events = append([]string(nil), w.Events...)
+		next.Settings.Webhooks = append(next.Settings.Webhooks, w)
 	}
 
 	// Copy sources
 	next.Sources = make([]Source, len(s.Sources))
 	copy(next.Sources, s.Sources)
+	for i := range next.Sources {
+		if next.Sources[i].RTP != nil {
+			rtp := *next.Sources[i].RTP
+			next.Sources[i].RTP = &rtp
+		}
+		if next.Sources[i].Snapcast != nil {
+			snap := *next.Sources[i].Snapcast
+			next.Sources[i].Snapcast = &snap
+		}
+		if src := next.Sources[i].Cast; src != nil {
+			cast := *src
+			cast.Devices = append([]string(nil), src.Devices...)
+			if src.Volume != nil {
+				vol := *src.Volume
+				cast.Volume = &vol
+			}
+			next.Sources[i].Cast = &cast
+		}
+		if next.Sources[i].Processing != nil {
+			proc := *next.Sources[i].Processing
+			next.Sources[i].Processing = &proc
+		}
+		if zones := next.Sources[i].Zones; zones != nil {
+			next.Sources[i].Zones = append(make([]int, 0, len(zones)), zones...)
+		}
+	}
 
 	// Copy zones
 	next.Zones = make([]Zone, len(s.Zones))
 	copy(next.Zones, s.Zones)
+	for i := range next.Zones {
+		if next.Zones[i].Amp != nil {
+			amp := *next.Zones[i].Amp
+			next.Zones[i].Amp = &amp
+		}
+		if next.Zones[i].Night != nil {
+			night := *next.Zones[i].Night
+			next.Zones[i].Night = &night
+		}
+		if next.Zones[i].VolLimit != nil {
+			v := *next.Zones[i].VolLimit
+			next.Zones[i].VolLimit = &v
+		}
+		if next.Zones[i].Calibration != nil {
+			cal := *next.Zones[i].Calibration
+			next.Zones[i].Calibration = &cal
+		}
+		if l := next.Zones[i].Lock; l != nil {
+			lock := *l
+			if l.VolMax != nil {
+				v := *l.VolMax
+				lock.VolMax = &v
+			}
+			next.Zones[i].Lock = &lock
+		}
+	}
 
 	// Copy groups (need deep copy of ZoneIDs slice)
 	next.Groups = make([]Group, len(s.Groups))
@@ -126,6 +339,14 @@ func (s State) DeepCopy() State {
 			ng.ZoneIDs = make([]int, len(g.ZoneIDs))
 			copy(ng.ZoneIDs, g.ZoneIDs)
 		}
+		if g.GroupIDs != nil {
+			ng.GroupIDs = make([]int, len(g.GroupIDs))
+			copy(ng.GroupIDs, g.GroupIDs)
+		}
+		if g.ExcludeZoneIDs != nil {
+			ng.ExcludeZoneIDs = make([]int, len(g.ExcludeZoneIDs))
+			copy(ng.ExcludeZoneIDs, g.ExcludeZoneIDs)
+		}
 		if g.SourceID != nil {
 			v := *g.SourceID
 			ng.SourceID = &v
@@ -163,6 +384,10 @@ func (s State) DeepCopy() State {
 			v := *st.Browsable
 			ns.Browsable = &v
 		}
+		if st.Active != nil {
+			v := *st.Active
+			ns.Active = &v
+		}
 		next.Streams[i] = ns
 	}
 
@@ -188,8 +413,14 @@ func (s State) DeepCopy() State {
 const (
 	SourceDisconnected = -1 // No source connection
 	ZoneOff            = -2 // Zone is off (for HA integration)
-	MaxSources         = 4
-	MaxZones           = 36
+
+	// Deprecated: the source limit follows the detected hardware; see
+	// hardware.HardwareProfile. MaxSources is the count of a single main unit.
+	MaxSources = 4
+	// Deprecated: the zone limit follows the detected hardware; see
+	// hardware.HardwareProfile. MaxZones is the count of a main unit and five
+	// expanders, the most the preamp was first sold with.
+	MaxZones = 36
 
 	MinVolDB = -80
 	MaxVolDB = 0
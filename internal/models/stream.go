@@ -13,6 +13,21 @@ type BrowseResponse struct {
 	Items []BrowsableItem `json:"items"`
 }
 
+// StreamQueue is the play queue of a file player stream.
+type StreamQueue struct {
+	Tracks   []string `json:"tracks"`   // paths relative to the media directory, or URLs
+	Position int      `json:"position"` // index of the current track; -1 when stopped at the end
+	Shuffle  bool     `json:"shuffle"`
+	Repeat   bool     `json:"repeat"`
+}
+
+// ProcessLog is the recent output (stdout and stderr) of one process run
+// by a stream, e.g. pianobar or go-librespot.
+type ProcessLog struct {
+	Process string   `json:"process"`
+	Lines   []string `json:"lines"`
+}
+
 // StreamCommand represents a command to send to a stream.
 type StreamCommand struct {
 	Command string `json:"cmd"`
@@ -31,6 +46,7 @@ const (
 	StreamTypeRCA           = "rca"
 	StreamTypeAux           = "aux"
 	StreamTypeFileplayer    = "fileplayer"
+	StreamTypePlexamp       = "plexamp"
 )
 
 // Special stream IDs from Python defaults.
@@ -52,6 +68,16 @@ func (s *Stream) ConfigString(key string) string {
 	return v
 }
 
+// ConfigBool extracts a bool config field safely.
+// Returns false if the key is missing or not a boolean.
+func (s *Stream) ConfigBool(key string) bool {
+	if s.Config == nil {
+		return false
+	}
+	v, _ := s.Config[key].(bool)
+	return v
+}
+
 // ConfigInt extracts an int config field safely.
 // Returns def if the key is missing or not an integer.
 func (s *Stream) ConfigInt(key string, def int) int {
@@ -0,0 +1,238 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Config field types.
+const (
+	FieldString = "string"
+	FieldBool   = "bool"
+	FieldInt    = "int"
+)
+
+// ConfigField describes one key of a stream's config.
+type ConfigField struct {
+	Key         string      `json:"key"`
+	Type        string      `json:"type"` // "string" | "bool" | "int"
+	Required    bool        `json:"required,omitempty"`
+	Default     interface{} `json:"default,omitempty"` // set on create when the key is absent
+	Secret      bool        `json:"secret,omitempty"`  // e.g. a password; redacted from diagnostics
+	Description string      `json:"description"`
+}
+
+// StreamSchema describes the config accepted by a stream type.
+type StreamSchema struct {
+	Type      string        `json:"type"`
+	Aliases   []string      `json:"aliases,omitempty"` // other accepted type names
+	Name      string        `json:"name"`
+	Fields    []ConfigField `json:"fields"`
+	Available bool          `json:"available"` // its binaries are installed; filled in by the controller
+}
+
+// StreamSchemas are the config schemas of all stream types.
+var StreamSchemas = []StreamSchema{
+	{Type: StreamTypeRCA, Name: "RCA input", Fields: []ConfigField{
+		{Key: "index", Type: FieldInt, Required: true, Description: "RCA input (0-3) the stream plays"},
+		{Key: "auto_switch", Type: FieldBool, Default: false, Description: "Switch the source to this input while it has signal (Rev4+)"},
+	}},
+	{Type: StreamTypeAux, Name: "Aux input", Fields: []ConfigField{}},
+	{Type: StreamTypePandora, Name: "Pandora", Fields: []ConfigField{
+		{Key: "user", Type: FieldString, Required: true, Description: "Pandora account email"},
+		{Key: "password", Type: FieldString, Required: true, Secret: true, Description: "Pandora account password"},
+		{Key: "station", Type: FieldString, Description: "Station ID to start playing"},
+	}},
+	{Type: StreamTypeAirPlay, Name: "AirPlay", Fields: []ConfigField{
+		{Key: "device_name", Type: FieldString, Description: "Name shown in the AirPlay menu; defaults to the stream name"},
+		{Key: "password", Type: FieldString, Secret: true, Description: "Password senders must enter; not supported by AirPlay 2 builds of shairport-sync"},
+	}},
+	{Type: StreamTypeSpotify, Aliases: []string{"spotify_connect"}, Name: "Spotify Connect", Fields: []ConfigField{}},
+	{Type: StreamTypeInternetRadio, Aliases: []string{"internet_radio"}, Name: "Internet radio", Fields: []ConfigField{
+		{Key: "url", Type: FieldString, Required: true, Description: "Stream or playlist URL"},
+		{Key: "logo", Type: FieldString, Description: "Station logo URL"},
+	}},
+	{Type: StreamTypeFileplayer, Aliases: []string{"file_player"}, Name: "File player", Fields: []ConfigField{
+		{Key: "path", Type: FieldString, Description: "File, directory or URL to play; relative paths are under the media directory"},
+		{Key: "shuffle", Type: FieldBool, Default: false, Description: "Play the queue in random order"},
+		{Key: "repeat", Type: FieldBool, Default: false, Description: "Restart the queue when it ends"},
+		{Key: "temporary", Type: FieldBool, Description: "Removed once played, as for announcements"},
+	}},
+	{Type: StreamTypeDLNA, Name: "DLNA renderer", Fields: []ConfigField{}},
+	{Type: StreamTypeLMS, Name: "Logitech Media Server", Fields: []ConfigField{
+		{Key: "server", Type: FieldString, Description: "Server host; discovered on the LAN when empty"},
+	}},
+	{Type: StreamTypeFMRadio, Aliases: []string{"fm_radio"}, Name: "FM radio", Fields: []ConfigField{
+		{Key: "freq", Type: FieldString, Required: true, Description: "Frequency in MHz, e.g. \"96.5\""},
+		{Key: "logo", Type: FieldString, Description: "Station logo URL"},
+	}},
+	{Type: StreamTypeBluetooth, Name: "Bluetooth", Fields: []ConfigField{
+		{Key: "device_name", Type: FieldString, Description: "Name phones see when pairing; defaults to the stream name"},
+		{Key: "adapter", Type: FieldString, Default: "hci0", Description: "Bluetooth adapter; give each Bluetooth stream its own to tell them apart"},
+	}},
+	{Type: StreamTypePlexamp, Name: "Plexamp", Fields: []ConfigField{}},
+}
+
+// FindStreamSchema returns the schema for a stream type or one of its
+// aliases, or nil if the type is unknown.
+func FindStreamSchema(typ string) *StreamSchema {
+	for i, sc := range StreamSchemas {
+		if sc.Type == typ || slices.Contains(sc.Aliases, typ) {
+			return &StreamSchemas[i]
+		}
+	}
+	return nil
+}
+
+// RegisterStreamSchema adds the schema of a stream type not built in, e.g.
+// one a plugin provides. It fails if the type or one of its aliases is
+// already known. Not safe for concurrent use: register from init.
+func RegisterStreamSchema(sc StreamSchema) error {
+	if sc.Type == "" {
+		return fmt.Errorf("stream type has no name")
+	}
+	for _, typ := range append([]string{sc.Type}, sc.Aliases...) {
+		if FindStreamSchema(typ) != nil {
+			return fmt.Errorf("stream type %q already registered", typ)
+		}
+	}
+	if sc.Fields == nil {
+		sc.Fields = []ConfigField{}
+	}
+	StreamSchemas = append(StreamSchemas, sc)
+	return nil
+}
+
+// Field returns the field with the given key, or nil.
+func (sc *StreamSchema) Field(key string) *ConfigField {
+	for i := range sc.Fields {
+		if sc.Fields[i].Key == key {
+			return &sc.Fields[i]
+		}
+	}
+	return nil
+}
+
+// ApplyDefaults sets absent keys that have a default, allocating cfg if
+// needed, and returns it.
+func (sc *StreamSchema) ApplyDefaults(cfg map[string]interface{}) map[string]interface{} {
+	for _, f := range sc.Fields {
+		if f.Default == nil {
+			continue
+		}
+		if _, ok := cfg[f.Key]; !ok {
+			if cfg == nil {
+				cfg = make(map[string]interface{})
+			}
+			cfg[f.Key] = f.Default
+		}
+	}
+	return cfg
+}
+
+// Validate checks cfg against the schema and returns an error listing
+// every unknown key, value of the wrong type and missing required key.
+func (sc *StreamSchema) Validate(cfg map[string]interface{}) error {
+	var problems []string
+	for _, k := range sortedKeys(cfg) {
+		if p := sc.checkKey(k, cfg[k]); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	for _, f := range sc.Fields {
+		if !f.Required {
+			continue
+		}
+		if v, ok := cfg[f.Key]; !ok || v == "" {
+			problems = append(problems, fmt.Sprintf("missing required key %q", f.Key))
+		}
+	}
+	return sc.invalid(problems)
+}
+
+// ValidatePatch checks the keys a config update sets, leaving the keys it
+// doesn't touch alone, so a stale key in a stored config doesn't block
+// later edits. A nil value deletes its key; required keys can't be deleted
+// or emptied.
+func (sc *StreamSchema) ValidatePatch(patch map[string]interface{}) error {
+	var problems []string
+	for _, k := range sortedKeys(patch) {
+		v := patch[k]
+		f := sc.Field(k)
+		switch {
+		case v == nil && f != nil && f.Required:
+			problems = append(problems, fmt.Sprintf("required key %q can't be deleted", k))
+		case v == nil:
+			// deleting is fine, even a key the schema doesn't know
+		case f != nil && f.Required && v == "":
+			problems = append(problems, fmt.Sprintf("missing required key %q", k))
+		default:
+			if p := sc.checkKey(k, v); p != "" {
+				problems = append(problems, p)
+			}
+		}
+	}
+	return sc.invalid(problems)
+}
+
+// checkKey returns what is wrong with key k set to v, or "".
+func (sc *StreamSchema) checkKey(k string, v interface{}) string {
+	f := sc.Field(k)
+	if f == nil {
+		return fmt.Sprintf("unknown key %q", k)
+	}
+	if !f.accepts(v) {
+		return fmt.Sprintf("%q must be %s", k, article(f.Type))
+	}
+	return ""
+}
+
+func (sc *StreamSchema) invalid(problems []string) error {
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid %s config: %s", sc.Type, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func sortedKeys(cfg map[string]interface{}) []string {
+	keys := make([]string, 0, len(cfg))
+	for k := range cfg {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// accepts reports whether v has the field's type. JSON numbers decode as
+// float64, so whole floats are accepted for ints.
+func (f *ConfigField) accepts(v interface{}) bool {
+	switch f.Type {
+	case FieldString:
+		_, ok := v.(string)
+		return ok
+	case FieldBool:
+		_, ok := v.(bool)
+		return ok
+	case FieldInt:
+		switch n := v.(type) {
+		case int:
+			return true
+		case float64:
+			return n == math.Trunc(n)
+		}
+	}
+	return false
+}
+
+func article(typ string) string {
+	switch typ {
+	case FieldInt:
+		return "an integer"
+	case FieldBool:
+		return "a boolean"
+	}
+	return "a " + typ
+}
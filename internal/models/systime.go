@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// TimeStatus is the unit's clock as reported by GET /api/system/time.
+type TimeStatus struct {
+	Time      time.Time `json:"time"`       // the unit's clock, in its timezone
+	Timezone  string    `json:"timezone"`   // IANA name, e.g. "America/Chicago"
+	UTCOffset string    `json:"utc_offset"` // e.g. "-05:00"
+	NTP       bool      `json:"ntp"`        // the clock is kept in sync over NTP
+	Synced    bool      `json:"synced"`     // the clock has been synchronized; false usually means a wrong clock after boot
+	RTC       bool      `json:"rtc"`        // a hardware clock keeps time while powered off
+}
+
+// TimeUpdate is the body of PATCH /api/system/time. Nil fields are left
+// unchanged.
+type TimeUpdate struct {
+	Timezone *string `json:"timezone,omitempty"` // IANA name, see GET /api/system/timezones
+	NTP      *bool   `json:"ntp,omitempty"`
+}
@@ -0,0 +1,23 @@
+package models
+
+// Import modes.
+const (
+	ImportMerge   = "merge"   // update items of the same name, add the rest
+	ImportReplace = "replace" // replace all user-created items of each kind given
+)
+
+// ConfigExport is a partial configuration: a unit's user-created streams
+// and presets, to share or move to another unit without its whole
+// house.json.
+type ConfigExport struct {
+	Streams []Stream `json:"streams,omitempty"`
+	Presets []Preset `json:"presets,omitempty"`
+}
+
+// ConfigImport loads a ConfigExport. Kinds left out are not touched, so a
+// preset pack can be imported without affecting streams.
+type ConfigImport struct {
+	Mode    string   `json:"mode,omitempty"` // ImportMerge (default) or ImportReplace
+	Streams []Stream `json:"streams,omitempty"`
+	Presets []Preset `json:"presets,omitempty"`
+}
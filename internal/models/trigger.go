@@ -0,0 +1,78 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Trigger is a GPIO output that emulates a 12V amplifier trigger: it is
+// asserted while any of its zones is playing or any of its sources feeds a
+// playing zone, e.g. to switch on an external amp or subwoofer.
+type Trigger struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Pin       string  `json:"pin"` // BCM name, e.g. "GPIO22"
+	ActiveLow bool    `json:"active_low,omitempty"`
+	Zones     []int   `json:"zones,omitempty"`
+	Sources   []int   `json:"sources,omitempty"`
+	Delay     float64 `json:"delay"` // seconds of demand before asserting
+	Hold      float64 `json:"hold"`  // seconds asserted after demand ends
+}
+
+// TriggerStatus is a trigger and whether its output is asserted.
+type TriggerStatus struct {
+	Trigger
+	Active bool `json:"active"`
+}
+
+// TriggerUpdate is the POST and PATCH body for triggers. Absent fields are
+// left unchanged; name and pin are required on create.
+type TriggerUpdate struct {
+	Name      *string  `json:"name,omitempty"`
+	Pin       *string  `json:"pin,omitempty"`
+	ActiveLow *bool    `json:"active_low,omitempty"`
+	Zones     []int    `json:"zones,omitempty"`
+	Sources   []int    `json:"sources,omitempty"`
+	Delay     *float64 `json:"delay,omitempty"`
+	Hold      *float64 `json:"hold,omitempty"`
+}
+
+// reservedPins drive the preamp and front panel, or identify the HAT, and
+// cannot be triggers.
+var reservedPins = map[string]bool{
+	// HAT ID EEPROM
+	"GPIO0": true, "GPIO1": true,
+	// I2C
+	"GPIO2": true, "GPIO3": true,
+	// Preamp reset and boot
+	"GPIO4": true, "GPIO5": true,
+	// SPI0 to the display, and its backlight
+	"GPIO7": true, "GPIO8": true, "GPIO9": true, "GPIO10": true, "GPIO11": true, "GPIO12": true,
+	// Preamp UART
+	"GPIO14": true, "GPIO15": true,
+	// Display data/command: e-ink, TFT
+	"GPIO17": true, "GPIO24": true,
+}
+
+var triggerPin = regexp.MustCompile(`^GPIO([0-9]|1[0-9]|2[0-7])$`)
+
+// Validate checks the pin, timings and that the trigger watches something.
+// Whether its zones and sources exist is checked by the controller.
+func (t Trigger) Validate() error {
+	if t.Name == "" {
+		return fmt.Errorf("trigger name is required")
+	}
+	if !triggerPin.MatchString(t.Pin) {
+		return fmt.Errorf("trigger pin %q is not a header GPIO (GPIO0-GPIO27)", t.Pin)
+	}
+	if reservedPins[t.Pin] {
+		return fmt.Errorf("trigger pin %s is used by the preamp or display", t.Pin)
+	}
+	if t.Delay < 0 || t.Hold < 0 {
+		return fmt.Errorf("trigger delay and hold must not be negative")
+	}
+	if len(t.Zones) == 0 && len(t.Sources) == 0 {
+		return fmt.Errorf("trigger needs at least one zone or source")
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// UnitSummary is one preamp unit in Info: the main unit or an expander.
+type UnitSummary struct {
+	Unit     int        `json:"unit"`               // 0 = main unit, 1+ = expanders in chain order
+	ZoneBase int        `json:"zone_base"`          // ID of the unit's first zone
+	Zones    int        `json:"zones"`              // zones on the unit
+	Firmware string     `json:"firmware,omitempty"` // e.g. "1.7-abc12345"; empty if unreadable
+	Temps    *UnitTemps `json:"temps,omitempty"`    // last health reading
+	Board    *UnitBoard `json:"board,omitempty"`    // from the unit's EEPROM; nil without hardware detection
+}
+
+// UnitBoard is a unit's board identity, read from its EEPROM at startup.
+type UnitBoard struct {
+	Serial      uint32 `json:"serial"`
+	Type        string `json:"type"`                   // "main", "expansion", "streamer" or "unknown"
+	Rev         string `json:"rev"`                    // e.g. "Rev4.A"; "Rev?.?" if unreadable
+	Rev4Plus    bool   `json:"rev4_plus"`              // Rev4 or later: RCA signal detection, LED control, ...
+	EEPROMError string `json:"eeprom_error,omitempty"` // why type and rev were guessed from the unit's position
+}
+
+// UnitTemps are a unit's temperatures, °C.
+type UnitTemps struct {
+	Time  time.Time `json:"time"`   // when they were read
+	Amp1C float32   `json:"amp1_c"` // zones 1-3 heatsink
+	Amp2C float32   `json:"amp2_c"` // zones 4-6 heatsink
+	PSU1C float32   `json:"psu1_c"` // high-voltage supply 1
+	PSU2C float32   `json:"psu2_c"` // high-voltage supply 2
+}
+
+// UnitStatus is a preamp unit's live status, read on request by
+// GET /api/hardware/units.
+type UnitStatus struct {
+	UnitSummary
+	Type      string         `json:"type"`               // "main", "expansion", "streamer" or "unknown"
+	I2CAddr   int            `json:"i2c_addr,omitempty"` // 7-bit preamp address, e.g. 0x10 for the first expander
+	HasAnalog bool           `json:"has_analog"`         // has analog source inputs
+	Power     *UnitPower     `json:"power,omitempty"`
+	Fan       *UnitFan       `json:"fan,omitempty"`
+	Estimate  *PowerEstimate `json:"power_estimate,omitempty"`
+	Errors    []string       `json:"errors,omitempty"` // reads that failed
+}
+
+// PowerEstimate is a unit's estimated power draw, worked out from its rail
+// voltages, amp enables and the volumes of the zones playing. It is meant
+// for energy use and supply headroom, not as a measurement.
+type PowerEstimate struct {
+	Watts        float64 `json:"watts"`
+	HV1Volts     float32 `json:"hv1_volts"`           // amp supply, zones 1-3 (all zones without HV2)
+	HV2Volts     float32 `json:"hv2_volts,omitempty"` // second amp supply, zones 4-6, if present
+	AmpsEnabled  int     `json:"amps_enabled"`
+	ZonesPlaying int     `json:"zones_playing"` // enabled, unmuted zones on a source that may be playing
+}
+
+// Health is the live status of every unit with their total estimated
+// power draw, for GET /api/health.
+type Health struct {
+	Time  time.Time    `json:"time"`
+	Watts float64      `json:"watts"`
+	Units []UnitStatus `json:"units"`
+}
+
+// UnitPower is the state of a unit's power rails.
+type UnitPower struct {
+	PG9V       bool `json:"pg_9v"`  // 9V rail power good
+	EN9V       bool `json:"en_9v"`  // 9V rail enabled
+	PG12V      bool `json:"pg_12v"` // 12V rail power good
+	EN12V      bool `json:"en_12v"` // 12V rail enabled
+	PG5VD      bool `json:"pg_5vd"` // digital 5V power good
+	PG5VA      bool `json:"pg_5va"` // analog 5V power good
+	HV2Present bool `json:"hv2_present"`
+}
+
+// UnitFan is a unit's fan control state.
+type UnitFan struct {
+	Mode     string `json:"mode"` // "external", "pwm", "linear" or "forced"
+	On       bool   `json:"on"`
+	OverTemp bool   `json:"over_temp"`
+	Failed   bool   `json:"failed"`
+}
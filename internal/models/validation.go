@@ -0,0 +1,9 @@
+package models
+
+// ConfigValidation is the result of checking a config without loading it:
+// the state loading it would produce and warnings about what was fixed up
+// or will not work on this unit.
+type ConfigValidation struct {
+	State    State    `json:"state"`
+	Warnings []string `json:"warnings"`
+}
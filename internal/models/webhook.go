@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+)
+
+// Webhook is an outbound HTTP endpoint that events are POSTed to, e.g. to
+// drive a home automation system without polling.
+type Webhook struct {
+	ID       int      `json:"id"`
+	Name     string   `json:"name"`
+	URL      string   `json:"url"`
+	Events   []string `json:"events,omitempty"` // event types to deliver; empty for all
+	Secret   string   `json:"-"`                // HMAC-SHA256 signing key; empty sends unsigned. Write-only, see Secrets
+	Disabled bool     `json:"disabled,omitempty"`
+}
+
+// MarshalJSON adds has_secret, telling clients whether deliveries are
+// signed without revealing the secret.
+func (w Webhook) MarshalJSON() ([]byte, error) {
+	type webhook Webhook
+	return json.Marshal(struct {
+		webhook
+		HasSecret bool `json:"has_secret"`
+	}{webhook(w), w.Secret != ""})
+}
+
+// Wants reports whether the webhook delivers events of type typ. Pings
+// are addressed to a single webhook and are not filtered here.
+func (w Webhook) Wants(typ string) bool {
+	return !w.Disabled && (len(w.Events) == 0 || slices.Contains(w.Events, typ))
+}
+
+// WebhookUpdate is the POST and PATCH body for webhooks. Absent fields are
+// left unchanged; name and url are required on create.
+type WebhookUpdate struct {
+	Name     *string  `json:"name,omitempty"`
+	URL      *string  `json:"url,omitempty"`
+	Events   []string `json:"events,omitempty"`
+	Secret   *string  `json:"secret,omitempty"`
+	Disabled *bool    `json:"disabled,omitempty"`
+}
+
+// Validate checks the URL and event types.
+func (w Webhook) Validate() error {
+	if w.Name == "" {
+		return fmt.Errorf("webhook name is required")
+	}
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook url %q is not an http or https URL", w.URL)
+	}
+	for _, e := range w.Events {
+		if !slices.Contains(EventTypes, e) {
+			return fmt.Errorf("unknown event type %q", e)
+		}
+	}
+	return nil
+}
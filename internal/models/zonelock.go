@@ -0,0 +1,19 @@
+package models
+
+import "fmt"
+
+// ZoneLock puts a zone in guest mode, e.g. in a rental or a child's room:
+// its source can't be changed and its volume is capped at VolMax, except
+// by an administrator.
+type ZoneLock struct {
+	Enabled bool `json:"enabled"`
+	VolMax  *int `json:"vol_max,omitempty"` // dB; nil leaves the volume uncapped
+}
+
+// Validate checks the volume cap.
+func (l ZoneLock) Validate() error {
+	if l.VolMax != nil && (*l.VolMax < MinVolDB || *l.VolMax > MaxVolDB) {
+		return fmt.Errorf("lock vol_max must be between %d and %d", MinVolDB, MaxVolDB)
+	}
+	return nil
+}
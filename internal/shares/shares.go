@@ -0,0 +1,521 @@
+// Package shares mounts SMB and NFS network shares into the music library
+// so the file player can play from a NAS. Share definitions are persisted to
+// shares.json in the config directory; each share is mounted read-only at
+// <media dir>/<name>.
+package shares
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName is the share configuration file in the config directory.
+const FileName = "shares.json"
+
+// Share types.
+const (
+	TypeSMB = "smb"
+	TypeNFS = "nfs"
+)
+
+// ProcMountsPath lists the mounted filesystems. Replaced in tests.
+var ProcMountsPath = "/proc/mounts"
+
+// Helper mounts and unmounts shares. It is root-owned and only mounts
+// under the media directory, read-only, with the options in
+// allowedOptions; see scripts/configs/amplipi-mount.
+var Helper = "/usr/local/sbin/amplipi-mount"
+
+// Sudo is prepended to Helper; the daemon runs unprivileged and is allowed
+// only the helper through sudoers. Empty runs it directly.
+var Sudo = "sudo"
+
+// mountFlags end every mount's options so nothing before them can
+// override them.
+const mountFlags = "ro,nosuid,nodev,noexec"
+
+// allowedOptions are the mount options a share may add, by name, with the
+// values each takes. The helper enforces the same list.
+var allowedOptions = map[string]*regexp.Regexp{
+	"vers":    regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`),
+	"nfsvers": regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`),
+	"port":    regexp.MustCompile(`^[0-9]{1,7}$`),
+	"timeo":   regexp.MustCompile(`^[0-9]{1,7}$`),
+	"retrans": regexp.MustCompile(`^[0-9]{1,7}$`),
+	"rsize":   regexp.MustCompile(`^[0-9]{1,7}$`),
+	"wsize":   regexp.MustCompile(`^[0-9]{1,7}$`),
+	"sec":     regexp.MustCompile(`^(none|ntlm|ntlmv2|ntlmssp|krb5|krb5i|sys)$`),
+	"proto":   regexp.MustCompile(`^(tcp|udp|tcp6|udp6)$`),
+	"domain":  regexp.MustCompile(`^[A-Za-z0-9.-]{1,64}$`),
+	"soft":    nil,
+	"hard":    nil,
+	"nolock":  nil,
+}
+
+const commandTimeout = 30 * time.Second
+
+// ErrNotFound is returned for an unknown share ID.
+var ErrNotFound = errors.New("share not found")
+
+// Share is a configured network share.
+type Share struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`               // folder in the media directory
+	Type     string `json:"type"`               // "smb" or "nfs"
+	Server   string `json:"server"`             // host name or address
+	Path     string `json:"path"`               // SMB share name or NFS export path
+	Username string `json:"username,omitempty"` // SMB only; empty = guest
+	Password string `json:"password,omitempty"` // SMB only
+	Options  string `json:"options,omitempty"`  // extra mount options from allowedOptions, comma separated
+	Disabled bool   `json:"disabled,omitempty"` // configured but not mounted
+}
+
+// Status is a Share plus its mount state.
+type Status struct {
+	Share
+	MountPoint string `json:"mount_point"`
+	Mounted    bool   `json:"mounted"`
+	Error      string `json:"error,omitempty"` // last mount failure
+}
+
+var (
+	validName   = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9 ._-]*$`)
+	validServer = regexp.MustCompile(`^[A-Za-z0-9\[][A-Za-z0-9.:\[\]-]*$`)
+)
+
+// checkOptions rejects mount options not in allowedOptions, e.g. rw,
+// suid or exec.
+func checkOptions(options string) error {
+	if options == "" {
+		return nil
+	}
+	for _, opt := range strings.Split(options, ",") {
+		name, value, hasValue := strings.Cut(opt, "=")
+		re, ok := allowedOptions[name]
+		if !ok || hasValue != (re != nil) || (re != nil && !re.MatchString(value)) {
+			return fmt.Errorf("share option %q is not allowed", opt)
+		}
+	}
+	return nil
+}
+
+// Check validates the share definition.
+func (s Share) Check() error {
+	if !validName.MatchString(s.Name) || len(s.Name) > 64 {
+		return fmt.Errorf("share name %q must be 1-64 letters, digits, spaces, '.', '_' or '-'", s.Name)
+	}
+	if !validServer.MatchString(s.Server) {
+		return fmt.Errorf("share server %q is invalid", s.Server)
+	}
+	if err := checkOptions(s.Options); err != nil {
+		return err
+	}
+	switch s.Type {
+	case TypeSMB:
+		if s.Path == "" || strings.Contains(s.Path, "..") || strings.ContainsAny(s.Path, ",\n") {
+			return fmt.Errorf("SMB share name %q is invalid", s.Path)
+		}
+		if strings.ContainsAny(s.Username+s.Password, "\n") {
+			return errors.New("SMB credentials must not contain newlines")
+		}
+	case TypeNFS:
+		if !strings.HasPrefix(s.Path, "/") || strings.Contains(s.Path, "..") || strings.ContainsAny(s.Path, ", \n") {
+			return fmt.Errorf("NFS export path %q must be absolute", s.Path)
+		}
+		if s.Username != "" || s.Password != "" {
+			return errors.New("NFS shares do not take credentials")
+		}
+	default:
+		return fmt.Errorf("share type %q must be smb or nfs", s.Type)
+	}
+	return nil
+}
+
+// Manager owns the configured shares and their mounts. All methods are
+// safe for concurrent use. Mounting can take the command timeout, so it
+// runs under opMu, which serializes changes, and never under mu, which
+// guards the shares and errors for List.
+type Manager struct {
+	opMu      sync.Mutex // held across a change and its mount commands
+	mu        sync.Mutex
+	configDir string
+	root      func() string // directory shares are mounted under
+	shares    []Share
+	errs      map[int]string
+}
+
+// NewManager loads shares.json from configDir. root returns the directory
+// shares are mounted under (the media directory).
+func NewManager(configDir string, root func() string) (*Manager, error) {
+	m := &Manager{configDir: configDir, root: root, errs: make(map[int]string)}
+	data, err := os.ReadFile(filepath.Join(configDir, FileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &m.shares); err != nil {
+		return nil, fmt.Errorf("%s: %w", FileName, err)
+	}
+	return m, nil
+}
+
+// List returns all shares with their mount state.
+func (m *Manager) List() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mounts := readMounts()
+	result := make([]Status, 0, len(m.shares))
+	for _, s := range m.shares {
+		dir := m.mountPoint(s)
+		result = append(result, Status{
+			Share:      s,
+			MountPoint: dir,
+			Mounted:    mounts[dir],
+			Error:      m.errs[s.ID],
+		})
+	}
+	return result
+}
+
+// Get returns the share with the given ID.
+func (m *Manager) Get(id int) (Share, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(id)
+	if i < 0 {
+		return Share{}, ErrNotFound
+	}
+	return m.shares[i], nil
+}
+
+// Create adds a share, assigning its ID, and mounts it unless disabled.
+// The share is kept even if mounting fails; the failure shows in List.
+func (m *Manager) Create(ctx context.Context, s Share) (Share, error) {
+	if err := s.Check(); err != nil {
+		return Share{}, err
+	}
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	m.mu.Lock()
+	if err := m.checkNameLocked(s); err != nil {
+		m.mu.Unlock()
+		return Share{}, err
+	}
+	s.ID = 0
+	for _, existing := range m.shares {
+		s.ID = max(s.ID, existing.ID+1)
+	}
+	next := append(slices.Clone(m.shares), s)
+	err := m.saveLocked(next)
+	if err == nil {
+		m.shares = next
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return Share{}, err
+	}
+	if !s.Disabled {
+		m.mountShare(ctx, s)
+	}
+	return s, nil
+}
+
+// Update replaces the share with s.ID, remounting it if it was mounted.
+func (m *Manager) Update(ctx context.Context, s Share) error {
+	if err := s.Check(); err != nil {
+		return err
+	}
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	m.mu.Lock()
+	old, err := m.getLocked(s.ID)
+	if err == nil {
+		err = m.checkNameLocked(s)
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := m.unmountShare(ctx, old); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	next := slices.Clone(m.shares)
+	next[m.index(s.ID)] = s
+	err = m.saveLocked(next)
+	if err == nil {
+		m.shares = next
+	}
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if !s.Disabled {
+		m.mountShare(ctx, s)
+	}
+	return nil
+}
+
+// Delete unmounts and removes a share.
+func (m *Manager) Delete(ctx context.Context, id int) error {
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	s, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := m.unmountShare(ctx, s); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i := m.index(id)
+	next := slices.Delete(slices.Clone(m.shares), i, i+1)
+	if err := m.saveLocked(next); err != nil {
+		return err
+	}
+	m.shares = next
+	delete(m.errs, id)
+	_ = os.Remove(m.credentialsPath(id))
+	return nil
+}
+
+// Mount mounts a share (again), e.g. after the NAS was unreachable.
+func (m *Manager) Mount(ctx context.Context, id int) error {
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	s, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	return m.mountShare(ctx, s)
+}
+
+// Unmount unmounts a share without removing it.
+func (m *Manager) Unmount(ctx context.Context, id int) error {
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	s, err := m.Get(id)
+	if err != nil {
+		return err
+	}
+	return m.unmountShare(ctx, s)
+}
+
+// MountAll mounts every enabled share that is not already mounted. Called
+// at startup; failures are logged and reported through List.
+func (m *Manager) MountAll(ctx context.Context) {
+	m.opMu.Lock()
+	defer m.opMu.Unlock()
+	m.mu.Lock()
+	shares := slices.Clone(m.shares)
+	m.mu.Unlock()
+	for _, s := range shares {
+		if !s.Disabled {
+			m.mountShare(ctx, s)
+		}
+	}
+}
+
+func (m *Manager) index(id int) int {
+	return slices.IndexFunc(m.shares, func(s Share) bool { return s.ID == id })
+}
+
+func (m *Manager) getLocked(id int) (Share, error) {
+	i := m.index(id)
+	if i < 0 {
+		return Share{}, ErrNotFound
+	}
+	return m.shares[i], nil
+}
+
+// checkNameLocked rejects a name already used by another share.
+func (m *Manager) checkNameLocked(s Share) error {
+	for _, existing := range m.shares {
+		if existing.ID != s.ID && strings.EqualFold(existing.Name, s.Name) {
+			return fmt.Errorf("share name %q is already in use", s.Name)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) mountPoint(s Share) string {
+	return filepath.Join(m.root(), s.Name)
+}
+
+func (m *Manager) credentialsPath(id int) string {
+	return filepath.Join(m.configDir, "shares", fmt.Sprintf("%d.cred", id))
+}
+
+// setError records the outcome of mounting share id.
+func (m *Manager) setError(id int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err == nil {
+		delete(m.errs, id)
+	} else {
+		m.errs[id] = err.Error()
+	}
+}
+
+// mountShare mounts s if it is not mounted yet, recording the outcome.
+// Must be called with opMu held.
+func (m *Manager) mountShare(ctx context.Context, s Share) error {
+	dir := m.mountPoint(s)
+	if readMounts()[dir] {
+		m.setError(s.ID, nil)
+		return nil
+	}
+	err := m.mount(ctx, s, dir)
+	m.setError(s.ID, err)
+	if err != nil {
+		slog.Warn("shares: mount failed", "name", s.Name, "server", s.Server, "err", err)
+		return err
+	}
+	slog.Info("shares: mounted", "name", s.Name, "server", s.Server, "path", dir)
+	return nil
+}
+
+func (m *Manager) mount(ctx context.Context, s Share, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	var source string
+	var opts []string
+	switch s.Type {
+	case TypeSMB:
+		source = "//" + s.Server + "/" + strings.Trim(s.Path, "/")
+		if s.Username == "" {
+			opts = append(opts, "guest")
+		} else {
+			cred, err := m.writeCredentials(s)
+			if err != nil {
+				return err
+			}
+			opts = append(opts, "credentials="+cred)
+		}
+		// Files appear owned by the daemon's user.
+		opts = append(opts, "uid="+strconv.Itoa(os.Getuid()), "gid="+strconv.Itoa(os.Getgid()), "iocharset=utf8")
+	case TypeNFS:
+		source = s.Server + ":" + s.Path
+		opts = append(opts, "soft", "timeo=100")
+	}
+	if s.Options != "" {
+		opts = append(opts, s.Options)
+	}
+	opts = append(opts, mountFlags)
+	fstype := map[string]string{TypeSMB: "cifs", TypeNFS: "nfs"}[s.Type]
+	return runHelper(ctx, "mount", fstype, source, dir, strings.Join(opts, ","))
+}
+
+// writeCredentials writes an SMB credentials file readable only by the
+// daemon's user (and root, which runs mount), keeping the password off the
+// command line.
+func (m *Manager) writeCredentials(s Share) (string, error) {
+	path := m.credentialsPath(s.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return "", err
+	}
+	data := fmt.Sprintf("username=%s\npassword=%s\n", s.Username, s.Password)
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// unmountShare unmounts s if it is mounted. Must be called with opMu held.
+func (m *Manager) unmountShare(ctx context.Context, s Share) error {
+	m.setError(s.ID, nil)
+	dir := m.mountPoint(s)
+	if !readMounts()[dir] {
+		return nil
+	}
+	if err := runHelper(ctx, "umount", dir); err != nil {
+		// A busy mount (the file player has a file open) is detached lazily.
+		if err := runHelper(ctx, "umount", "-l", dir); err != nil {
+			return err
+		}
+	}
+	slog.Info("shares: unmounted", "name", s.Name, "path", dir)
+	_ = os.Remove(dir) // only succeeds once empty
+	return nil
+}
+
+// saveLocked writes shares to shares.json atomically. The file holds SMB
+// passwords, so it is only readable by the daemon's user.
+func (m *Manager) saveLocked(shares []Share) error {
+	if shares == nil {
+		shares = []Share{}
+	}
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.configDir, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(m.configDir, FileName)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.Join(err, os.Remove(tmp))
+	}
+	return nil
+}
+
+// readMounts returns the set of mount points in ProcMountsPath.
+func readMounts() map[string]bool {
+	mounts := make(map[string]bool)
+	data, err := os.ReadFile(ProcMountsPath)
+	if err != nil {
+		return mounts
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		// Spaces and other special characters are octal-escaped.
+		dir, err := strconv.Unquote(`"` + strings.ReplaceAll(fields[1], `"`, `\"`) + `"`)
+		if err != nil {
+			dir = fields[1]
+		}
+		mounts[dir] = true
+	}
+	return mounts
+}
+
+// runHelper runs Helper through Sudo.
+func runHelper(ctx context.Context, args ...string) error {
+	if Sudo != "" {
+		return runCommand(ctx, Sudo, append([]string{"-n", Helper}, args...)...)
+	}
+	return runCommand(ctx, Helper, args...)
+}
+
+// runCommand executes a command. Replaced in tests.
+var runCommand = func(ctx context.Context, name string, args ...string) error {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, name, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", strings.Join(append([]string{name}, args...), " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
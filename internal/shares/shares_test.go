@@ -0,0 +1,225 @@
+package shares
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fakeMount replaces the mount helper with one that records its arguments
+// and maintains a fake /proc/mounts.
+func fakeMount(t *testing.T) *[][]string {
+	t.Helper()
+	mounts := filepath.Join(t.TempDir(), "mounts")
+	if err := os.WriteFile(mounts, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	origRun, origMounts, origSudo := runCommand, ProcMountsPath, Sudo
+	ProcMountsPath, Sudo = mounts, ""
+	runCommand = func(_ context.Context, name string, args ...string) error {
+		if name != Helper {
+			t.Errorf("ran %s, want %s", name, Helper)
+		}
+		got = append(got, args)
+		data, _ := os.ReadFile(mounts)
+		dir := args[len(args)-1]
+		if args[0] == "mount" {
+			dir = args[3]
+		}
+		dir = strings.ReplaceAll(dir, " ", `\040`)
+		switch args[0] {
+		case "mount":
+			data = append(data, []byte("server:/x "+dir+" nfs ro 0 0\n")...)
+		case "umount":
+			data = []byte(strings.ReplaceAll(string(data), "server:/x "+dir+" nfs ro 0 0\n", ""))
+		}
+		return os.WriteFile(mounts, data, 0644)
+	}
+	t.Cleanup(func() { runCommand, ProcMountsPath, Sudo = origRun, origMounts, origSudo })
+	return &got
+}
+
+func TestShareCheck(t *testing.T) {
+	valid := []Share{
+		{Name: "NAS Music", Type: TypeSMB, Server: "nas.local", Path: "music"},
+		{Name: "nfs", Type: TypeNFS, Server: "10.0.0.2", Path: "/export/music", Options: "vers=4"},
+	}
+	for _, s := range valid {
+		if err := s.Check(); err != nil {
+			t.Errorf("%+v: %v", s, err)
+		}
+	}
+	invalid := []Share{
+		{Name: "../etc", Type: TypeSMB, Server: "nas", Path: "music"},
+		{Name: "", Type: TypeSMB, Server: "nas", Path: "music"},
+		{Name: "a", Type: "ftp", Server: "nas", Path: "music"},
+		{Name: "a", Type: TypeSMB, Server: "", Path: "music"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: ""},
+		{Name: "a", Type: TypeNFS, Server: "nas", Path: "relative"},
+		{Name: "a", Type: TypeNFS, Server: "nas", Path: "/x", Username: "bob"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m", Options: "ro exec"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m", Options: "rw"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m", Options: "vers=3.0,suid"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m", Options: "credentials=/root/.cred"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m", Options: "soft=1"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m,rw"},
+		{Name: "a", Type: TypeNFS, Server: "-onas", Path: "/x"},
+		{Name: "a", Type: TypeSMB, Server: "nas", Path: "m", Password: "x\nusername=root"},
+	}
+	for _, s := range invalid {
+		if err := s.Check(); err == nil {
+			t.Errorf("%+v: expected error", s)
+		}
+	}
+}
+
+func TestManagerMountsAndPersists(t *testing.T) {
+	got := fakeMount(t)
+	cfg, media := t.TempDir(), t.TempDir()
+	m, err := NewManager(cfg, func() string { return media })
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	smb, err := m.Create(ctx, Share{Name: "NAS Music", Type: TypeSMB, Server: "nas", Path: "music", Username: "bob", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nfs, err := m.Create(ctx, Share{Name: "Archive", Type: TypeNFS, Server: "10.0.0.2", Path: "/export"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if smb.ID != 0 || nfs.ID != 1 {
+		t.Errorf("ids = %d, %d", smb.ID, nfs.ID)
+	}
+	if _, err := m.Create(ctx, Share{Name: "archive", Type: TypeNFS, Server: "x", Path: "/y"}); err == nil {
+		t.Error("duplicate name should be rejected")
+	}
+
+	if len(*got) != 2 {
+		t.Fatalf("commands = %v", *got)
+	}
+	mountSMB := strings.Join((*got)[0], " ")
+	cred := filepath.Join(cfg, "shares", "0.cred")
+	if !strings.HasPrefix(mountSMB, "mount cifs //nas/music "+filepath.Join(media, "NAS Music")+" credentials="+cred+",") ||
+		!strings.HasSuffix(mountSMB, ","+mountFlags) {
+		t.Errorf("smb mount = %q", mountSMB)
+	}
+	if strings.Contains(mountSMB, "secret") {
+		t.Error("password leaked onto the command line")
+	}
+	if data, _ := os.ReadFile(cred); string(data) != "username=bob\npassword=secret\n" {
+		t.Errorf("credentials = %q", data)
+	}
+	if want := []string{"mount", "nfs", "10.0.0.2:/export", filepath.Join(media, "Archive"), "soft,timeo=100,ro,nosuid,nodev,noexec"}; !slices.Equal((*got)[1], want) {
+		t.Errorf("nfs mount = %v", (*got)[1])
+	}
+
+	list := m.List()
+	if len(list) != 2 || !list[0].Mounted || !list[1].Mounted {
+		t.Fatalf("list = %+v", list)
+	}
+
+	// Mounting an already mounted share is a no-op.
+	*got = nil
+	if err := m.Mount(ctx, 1); err != nil || len(*got) != 0 {
+		t.Errorf("remount: err=%v commands=%v", err, *got)
+	}
+
+	// Config file is private and reloads.
+	info, err := os.Stat(filepath.Join(cfg, FileName))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("shares.json: %v %v", info, err)
+	}
+	reloaded, err := NewManager(cfg, func() string { return media })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s, err := reloaded.Get(0); err != nil || s.Password != "secret" {
+		t.Errorf("reloaded share = %+v, %v", s, err)
+	}
+
+	if err := m.Delete(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal((*got)[0], []string{"umount", filepath.Join(media, "NAS Music")}) {
+		t.Errorf("delete commands = %v", *got)
+	}
+	if _, err := os.Stat(cred); !os.IsNotExist(err) {
+		t.Error("credentials file not removed")
+	}
+	if err := m.Delete(ctx, 0); err != ErrNotFound {
+		t.Errorf("second delete err = %v", err)
+	}
+
+	var saved []Share
+	data, _ := os.ReadFile(filepath.Join(cfg, FileName))
+	if err := json.Unmarshal(data, &saved); err != nil || len(saved) != 1 || saved[0].Name != "Archive" {
+		t.Errorf("saved = %s", data)
+	}
+}
+
+func TestManagerMountFailureKeepsShare(t *testing.T) {
+	fakeMount(t)
+	runCommand = func(context.Context, string, ...string) error {
+		return os.ErrPermission
+	}
+	media := t.TempDir()
+	m, err := NewManager(t.TempDir(), func() string { return media })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Create(context.Background(), Share{Name: "nas", Type: TypeSMB, Server: "nas", Path: "music"}); err != nil {
+		t.Fatal(err)
+	}
+	list := m.List()
+	if len(list) != 1 || list[0].Mounted || list[0].Error == "" {
+		t.Errorf("list = %+v", list)
+	}
+}
+
+func TestManagerListDuringMount(t *testing.T) {
+	fakeMount(t)
+	started, release := make(chan struct{}), make(chan struct{})
+	runCommand = func(context.Context, string, ...string) error {
+		close(started)
+		<-release
+		return nil
+	}
+	media := t.TempDir()
+	m, err := NewManager(t.TempDir(), func() string { return media })
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := m.Create(context.Background(), Share{Name: "nas", Type: TypeSMB, Server: "nas", Path: "music"})
+		done <- err
+	}()
+	<-started
+	if list := m.List(); len(list) != 1 || list[0].Mounted {
+		t.Errorf("list during mount = %+v", list)
+	}
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadMountsUnescapes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mounts")
+	os.WriteFile(path, []byte("//nas/m /home/pi/Music/NAS\\040Music cifs ro 0 0\n"), 0644)
+	orig := ProcMountsPath
+	ProcMountsPath = path
+	t.Cleanup(func() { ProcMountsPath = orig })
+
+	if !readMounts()["/home/pi/Music/NAS Music"] {
+		t.Errorf("mounts = %v", readMounts())
+	}
+}
@@ -0,0 +1,230 @@
+// Package snapcast controls a local snapserver over its JSON-RPC API so
+// Snapcast clients around the house can be grouped onto AmpliPi sources
+// and have their latency and volume adjusted.
+package snapcast
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// DefaultURL is the JSON-RPC endpoint of the managed snapserver.
+const DefaultURL = "http://127.0.0.1:1780/jsonrpc"
+
+// AnnounceStreamID is the snapserver stream announcements are played on.
+const AnnounceStreamID = "amplipi-announce"
+
+// BufferMS is the end-to-end buffer the managed snapserver is configured
+// with: audio written to a stream is heard on clients this much later.
+const BufferMS = 1000
+
+// streamPrefix prefixes the snapserver stream ID of each AmpliPi source.
+const streamPrefix = "amplipi-src"
+
+// SourceStreamID returns the snapserver stream ID used for source sid.
+func SourceStreamID(sid int) string {
+	return streamPrefix + strconv.Itoa(sid)
+}
+
+// StreamSource returns the source ID for a snapserver stream ID created by
+// AmpliPi, or false for streams configured outside AmpliPi.
+func StreamSource(streamID string) (int, bool) {
+	if !strings.HasPrefix(streamID, streamPrefix) {
+		return 0, false
+	}
+	sid, err := strconv.Atoi(strings.TrimPrefix(streamID, streamPrefix))
+	if err != nil {
+		return 0, false
+	}
+	return sid, true
+}
+
+// Client is a snapserver JSON-RPC client. Safe for concurrent use.
+type Client struct {
+	url  string
+	http *http.Client
+	seq  atomic.Int64
+}
+
+// NewClient creates a client for the JSON-RPC endpoint at url.
+func NewClient(url string) *Client {
+	return &Client{
+		url:  url,
+		http: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type rpcRequest struct {
+	ID      int64       `json:"id"`
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// call performs one JSON-RPC request and decodes the result into out (if non-nil).
+func (c *Client) call(ctx context.Context, method string, params, out interface{}) error {
+	body, err := json.Marshal(rpcRequest{ID: c.seq.Add(1), JSONRPC: "2.0", Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("snapcast: %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("snapcast: %s: HTTP %d", method, resp.StatusCode)
+	}
+	var rpc rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rpc); err != nil {
+		return fmt.Errorf("snapcast: %s: decode: %w", method, err)
+	}
+	if rpc.Error != nil {
+		return fmt.Errorf("snapcast: %s: %s (%d)", method, rpc.Error.Message, rpc.Error.Code)
+	}
+	if out != nil {
+		return json.Unmarshal(rpc.Result, out)
+	}
+	return nil
+}
+
+// serverStatus mirrors the subset of Server.GetStatus we use.
+type serverStatus struct {
+	Server struct {
+		Groups []struct {
+			ID       string `json:"id"`
+			Name     string `json:"name"`
+			StreamID string `json:"stream_id"`
+			Muted    bool   `json:"muted"`
+			Clients  []struct {
+				ID        string `json:"id"`
+				Connected bool   `json:"connected"`
+				Host      struct {
+					Name string `json:"name"`
+					IP   string `json:"ip"`
+				} `json:"host"`
+				Config struct {
+					Name    string `json:"name"`
+					Latency int    `json:"latency"`
+					Volume  struct {
+						Percent int  `json:"percent"`
+						Muted   bool `json:"muted"`
+					} `json:"volume"`
+				} `json:"config"`
+			} `json:"clients"`
+		} `json:"groups"`
+		Streams []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"streams"`
+	} `json:"server"`
+}
+
+// Status returns the server's groups, clients and streams.
+func (c *Client) Status(ctx context.Context) (*models.SnapcastStatus, error) {
+	var raw serverStatus
+	if err := c.call(ctx, "Server.GetStatus", nil, &raw); err != nil {
+		return nil, err
+	}
+	status := &models.SnapcastStatus{
+		Groups:  []models.SnapGroup{},
+		Streams: []models.SnapStream{},
+	}
+	for _, g := range raw.Server.Groups {
+		group := models.SnapGroup{
+			ID:       g.ID,
+			Name:     g.Name,
+			StreamID: g.StreamID,
+			Muted:    g.Muted,
+			Clients:  []models.SnapClient{},
+		}
+		if sid, ok := StreamSource(g.StreamID); ok {
+			group.SourceID = &sid
+		}
+		for _, cl := range g.Clients {
+			name := cl.Config.Name
+			if name == "" {
+				name = cl.Host.Name
+			}
+			group.Clients = append(group.Clients, models.SnapClient{
+				ID:        cl.ID,
+				Name:      name,
+				Host:      cl.Host.IP,
+				Connected: cl.Connected,
+				Latency:   cl.Config.Latency,
+				Volume:    cl.Config.Volume.Percent,
+				Muted:     cl.Config.Volume.Muted,
+			})
+		}
+		status.Groups = append(status.Groups, group)
+	}
+	for _, s := range raw.Server.Streams {
+		stream := models.SnapStream{ID: s.ID, Status: s.Status}
+		if sid, ok := StreamSource(s.ID); ok {
+			stream.SourceID = &sid
+		}
+		status.Streams = append(status.Streams, stream)
+	}
+	return status, nil
+}
+
+// SetClientLatency sets a client's additional playout latency in ms.
+func (c *Client) SetClientLatency(ctx context.Context, id string, latencyMS int) error {
+	return c.call(ctx, "Client.SetLatency", map[string]interface{}{"id": id, "latency": latencyMS}, nil)
+}
+
+// SetClientName sets a client's display name.
+func (c *Client) SetClientName(ctx context.Context, id, name string) error {
+	return c.call(ctx, "Client.SetName", map[string]interface{}{"id": id, "name": name}, nil)
+}
+
+// SetClientVolume sets a client's volume (0-100) and mute.
+func (c *Client) SetClientVolume(ctx context.Context, id string, percent int, muted bool) error {
+	return c.call(ctx, "Client.SetVolume", map[string]interface{}{
+		"id":     id,
+		"volume": map[string]interface{}{"percent": percent, "muted": muted},
+	}, nil)
+}
+
+// SetGroupClients replaces a group's members. Clients are moved out of
+// their previous groups by the server.
+func (c *Client) SetGroupClients(ctx context.Context, id string, clients []string) error {
+	return c.call(ctx, "Group.SetClients", map[string]interface{}{"id": id, "clients": clients}, nil)
+}
+
+// SetGroupStream switches the stream a group plays.
+func (c *Client) SetGroupStream(ctx context.Context, id, streamID string) error {
+	return c.call(ctx, "Group.SetStream", map[string]interface{}{"id": id, "stream_id": streamID}, nil)
+}
+
+// SetGroupMute mutes or unmutes a whole group.
+func (c *Client) SetGroupMute(ctx context.Context, id string, mute bool) error {
+	return c.call(ctx, "Group.SetMute", map[string]interface{}{"id": id, "mute": mute}, nil)
+}
+
+// SetGroupName sets a group's display name.
+func (c *Client) SetGroupName(ctx context.Context, id, name string) error {
+	return c.call(ctx, "Group.SetName", map[string]interface{}{"id": id, "name": name}, nil)
+}
@@ -0,0 +1,95 @@
+package snapcast
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeServer answers Server.GetStatus with a fixed status and records
+// every other call.
+func fakeServer(t *testing.T, calls *[]rpcRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("decode request: %v", err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if req.Method == "Server.GetStatus" {
+			_, _ = w.Write([]byte(`{"id":1,"jsonrpc":"2.0","result":{"server":{
+				"groups":[{"id":"g1","name":"","stream_id":"amplipi-src2","muted":false,"clients":[
+					{"id":"c1","connected":true,"host":{"name":"kitchen-pi","ip":"192.168.1.20"},
+					 "config":{"name":"","latency":40,"volume":{"percent":75,"muted":false}}}]}],
+				"streams":[{"id":"amplipi-src2","status":"playing"},{"id":"default","status":"idle"}]}}}`))
+			return
+		}
+		*calls = append(*calls, req)
+		if req.Method == "Client.SetLatency" {
+			_, _ = w.Write([]byte(`{"id":1,"jsonrpc":"2.0","error":{"code":-32603,"message":"boom"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":1,"jsonrpc":"2.0","result":{}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestStreamIDs(t *testing.T) {
+	if got := SourceStreamID(3); got != "amplipi-src3" {
+		t.Errorf("SourceStreamID(3) = %q", got)
+	}
+	if sid, ok := StreamSource("amplipi-src3"); !ok || sid != 3 {
+		t.Errorf("StreamSource = %d, %v", sid, ok)
+	}
+	if _, ok := StreamSource("default"); ok {
+		t.Error("StreamSource(default) should not map to a source")
+	}
+}
+
+func TestStatus(t *testing.T) {
+	var calls []rpcRequest
+	c := NewClient(fakeServer(t, &calls).URL)
+
+	status, err := c.Status(context.Background())
+	if err != nil {
+		t.Fatalf("Status: %v", err)
+	}
+	if len(status.Groups) != 1 || len(status.Groups[0].Clients) != 1 {
+		t.Fatalf("Status = %+v", status)
+	}
+	g := status.Groups[0]
+	if g.SourceID == nil || *g.SourceID != 2 {
+		t.Errorf("group source = %v, want 2", g.SourceID)
+	}
+	cl := g.Clients[0]
+	if cl.Name != "kitchen-pi" || cl.Host != "192.168.1.20" || cl.Latency != 40 || cl.Volume != 75 {
+		t.Errorf("client = %+v", cl)
+	}
+	if len(status.Streams) != 2 || status.Streams[1].SourceID != nil {
+		t.Errorf("streams = %+v", status.Streams)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	var calls []rpcRequest
+	c := NewClient(fakeServer(t, &calls).URL)
+	ctx := context.Background()
+
+	if err := c.SetGroupStream(ctx, "g1", SourceStreamID(1)); err != nil {
+		t.Fatalf("SetGroupStream: %v", err)
+	}
+	if err := c.SetClientLatency(ctx, "c1", 100); err == nil {
+		t.Error("expected RPC error to be returned")
+	}
+	if len(calls) != 2 || calls[0].Method != "Group.SetStream" || calls[0].JSONRPC != "2.0" {
+		t.Errorf("calls = %+v", calls)
+	}
+	params := calls[0].Params.(map[string]interface{})
+	if params["stream_id"] != "amplipi-src1" {
+		t.Errorf("params = %v", params)
+	}
+}
@@ -2,9 +2,17 @@ package streams
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
+	"os"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
@@ -15,18 +23,32 @@ import (
 const shairportConfTemplate = `general = {
     name = "%s";
     port = %d;
-    udp_port_base = %d;
+    udp_port_base = %d;%s
 };
 alsa = {
     output_device = "%s";
 };
+metadata = {
+    enabled = "yes";
+    include_cover_art = "no";
+    pipe_name = "%s";
+};
 `
 
 // AirPlayStream plays AirPlay audio via shairport-sync.
 // Persistent — shairport-sync must advertise on the network continuously.
+// The sender's session, playback state and track come from shairport-sync's
+// metadata pipe.
 type AirPlayStream struct {
 	SubprocStream
-	name string
+	name       string
+	deviceName string // advertised instead of name if set
+	password   string // asked of senders if set
+
+	monCancel context.CancelFunc
+	monWg     sync.WaitGroup
+
+	onChange func(info models.StreamInfo)
 }
 
 // NewAirPlayStream creates a new AirPlay stream.
@@ -34,6 +56,37 @@ func NewAirPlayStream(name string) *AirPlayStream {
 	return &AirPlayStream{name: name}
 }
 
+// AirPlayDeviceName returns the name an AirPlay stream is advertised
+// under: its device_name config, or the stream's name.
+func AirPlayDeviceName(stream models.Stream) string {
+	if name := stream.ConfigString("device_name"); name != "" {
+		return name
+	}
+	return stream.Name
+}
+
+// advertisedName is the name senders see in their AirPlay menu.
+func (s *AirPlayStream) advertisedName() string {
+	if s.deviceName != "" {
+		return s.deviceName
+	}
+	return s.name
+}
+
+// reconfigure takes a renamed stream's name, device name and password; a
+// running shairport-sync must restart to advertise them.
+func (s *AirPlayStream) reconfigure(stream models.Stream) bool {
+	name, deviceName, password := stream.Name, stream.ConfigString("device_name"), stream.ConfigString("password")
+	changed := AirPlayDeviceName(stream) != s.advertisedName() || password != s.password
+	s.name, s.deviceName, s.password = name, deviceName, password
+	return changed
+}
+
+// libconfigString quotes s for a shairport-sync config string.
+func libconfigString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
 // Activate writes the shairport-sync config and starts the process.
 func (s *AirPlayStream) Activate(ctx context.Context, vsrc int, configDir string) error {
 	slog.Info("airplay: activating", "name", s.name)
@@ -44,13 +97,21 @@ func (s *AirPlayStream) Activate(ctx context.Context, vsrc int, configDir string
 	}
 
 	confPath := dir + "/shairport.conf"
+	pipePath := dir + "/shairport-metadata"
+	if err := makeFIFO(pipePath); err != nil {
+		return fmt.Errorf("airplay: metadata pipe: %w", err)
+	}
 
 	// Port allocation: base 5100, 100 per vsrc
 	port := 5100 + 100*vsrc
 	udpBase := 6101 + 100*vsrc
 	device := VirtualOutputDevice(vsrc)
 
-	cfgContent := fmt.Sprintf(shairportConfTemplate, s.name, port, udpBase, device)
+	password := ""
+	if s.password != "" {
+		password = fmt.Sprintf("\n    password = \"%s\";", libconfigString(s.password))
+	}
+	cfgContent := fmt.Sprintf(shairportConfTemplate, libconfigString(s.advertisedName()), port, udpBase, password, device, pipePath)
 	if err := writeFileAtomic(confPath, []byte(cfgContent)); err != nil {
 		return fmt.Errorf("airplay: write shairport.conf: %w", err)
 	}
@@ -66,11 +127,23 @@ func (s *AirPlayStream) Activate(ctx context.Context, vsrc int, configDir string
 		State: "connected",
 	})
 
-	return s.activateBase(ctx, vsrc, dir)
+	if err := s.activateBase(ctx, vsrc, dir); err != nil {
+		return err
+	}
+
+	monCtx, monCancel := context.WithCancel(context.Background())
+	s.monCancel = monCancel
+	s.monWg.Add(1)
+	go s.readMetadata(monCtx, pipePath)
+	return nil
 }
 
 func (s *AirPlayStream) Deactivate(ctx context.Context) error {
 	slog.Info("airplay: deactivating", "name", s.name)
+	if s.monCancel != nil {
+		s.monCancel()
+	}
+	s.monWg.Wait()
 	return s.deactivateBase(ctx)
 }
 
@@ -95,3 +168,156 @@ func (s *AirPlayStream) Info() models.StreamInfo {
 
 func (s *AirPlayStream) IsPersistent() bool { return true }
 func (s *AirPlayStream) Type() string        { return "airplay" }
+
+// makeFIFO creates the named pipe shairport-sync writes metadata to,
+// replacing anything else at path.
+func makeFIFO(path string) error {
+	if fi, err := os.Stat(path); err == nil {
+		if fi.Mode()&fs.ModeNamedPipe != 0 {
+			return nil
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return syscall.Mkfifo(path, 0600)
+}
+
+// readMetadata follows the metadata pipe until ctx is cancelled. The pipe
+// is opened read-write so it stays open while shairport-sync restarts.
+func (s *AirPlayStream) readMetadata(ctx context.Context, path string) {
+	defer s.monWg.Done()
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		slog.Warn("airplay: open metadata pipe failed", "name", s.name, "err", err)
+		return
+	}
+	go func() {
+		<-ctx.Done()
+		f.Close()
+	}()
+
+	var meta airplayMeta
+	dec := xml.NewDecoder(f)
+	for {
+		var item metaItem
+		if err := dec.Decode(&item); err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("airplay: metadata read failed", "name", s.name, "err", err)
+			}
+			return
+		}
+		if !meta.apply(item) {
+			continue
+		}
+		s.mu.Lock()
+		meta.fill(&s.info)
+		s.mu.Unlock()
+		if s.onChange != nil {
+			s.onChange(s.getInfo())
+		}
+	}
+}
+
+// metaItem is one item on shairport-sync's metadata pipe. Type and code
+// are four-character codes in hex, e.g. 73736e63 ("ssnc").
+type metaItem struct {
+	Type string `xml:"type"`
+	Code string `xml:"code"`
+	Data string `xml:"data"` // base64
+}
+
+func (it metaItem) codes() (typ, code string) {
+	t, _ := hex.DecodeString(strings.TrimSpace(it.Type))
+	c, _ := hex.DecodeString(strings.TrimSpace(it.Code))
+	return string(t), string(c)
+}
+
+func (it metaItem) value() string {
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(it.Data), ""))
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// airplayMeta accumulates the session and track reported on the metadata
+// pipe.
+type airplayMeta struct {
+	active  bool
+	state   string
+	session models.AirPlaySession
+	track   models.StreamInfo // Track, Artist, Album
+
+	pending models.StreamInfo // track fields of a metadata bundle being received
+}
+
+// apply updates the metadata from one item and reports whether the
+// stream info should be republished. Track fields arrive in bundles and
+// are published when the bundle ends.
+func (m *airplayMeta) apply(it metaItem) bool {
+	typ, code := it.codes()
+	if typ == "core" {
+		switch code {
+		case "minm":
+			m.pending.Track = it.value()
+		case "asar":
+			m.pending.Artist = it.value()
+		case "asal":
+			m.pending.Album = it.value()
+		}
+		return false
+	}
+	if typ != "ssnc" {
+		return false
+	}
+	switch code {
+	case "abeg": // a sender took over the stream
+		m.active = true
+	case "aend": // the sender disconnected
+		*m = airplayMeta{}
+	case "pbeg", "prsm":
+		m.active = true
+		m.state = "playing"
+	case "pfls":
+		m.state = "paused"
+	case "pend":
+		m.state = ""
+	case "snam":
+		m.session.Client = it.value()
+	case "clip":
+		m.session.ClientIP = it.value()
+	case "daid":
+		m.session.DACPID = it.value()
+	case "styp":
+		m.session.StreamType = it.value()
+	case "mdst":
+		m.pending = models.StreamInfo{}
+		return false
+	case "mden":
+		m.track = m.pending
+	default:
+		return false
+	}
+	return true
+}
+
+// fill sets the session, state and track fields of info.
+func (m *airplayMeta) fill(info *models.StreamInfo) {
+	info.AirPlayActive = m.active
+	info.AirPlay = nil
+	info.State = "connected"
+	info.Track, info.Artist, info.Album = "", "", ""
+	if !m.active {
+		return
+	}
+	session := m.session
+	info.AirPlay = &session
+	if m.state != "" {
+		info.State = m.state
+	}
+	info.Track, info.Artist, info.Album = m.track.Track, m.track.Artist, m.track.Album
+}
@@ -0,0 +1,45 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/grandcat/zeroconf"
+)
+
+// AirPlayNamesOnNetwork browses the LAN for AirPlay receivers, including
+// this unit's own, for timeout and returns the names they advertise.
+func AirPlayNamesOnNetwork(ctx context.Context, timeout time.Duration) ([]string, error) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("airplay: mDNS resolver: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	entries := make(chan *zeroconf.ServiceEntry)
+	var names []string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for e := range entries {
+			names = append(names, raopName(e.Instance))
+		}
+	}()
+	// Every AirPlay receiver, AirPlay 2 ones included, advertises RAOP.
+	if err := resolver.Browse(ctx, "_raop._tcp", "local.", entries); err != nil {
+		return nil, fmt.Errorf("airplay: browse: %w", err)
+	}
+	<-done
+	return names, nil
+}
+
+// raopName returns the receiver name of a RAOP instance, "<MAC>@<name>".
+func raopName(instance string) string {
+	if _, name, ok := strings.Cut(instance, "@"); ok {
+		return name
+	}
+	return instance
+}
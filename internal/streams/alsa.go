@@ -1,33 +1,108 @@
 package streams
 
 import (
+	"bytes"
 	"context"
+	"fmt"
 	"log/slog"
 	"os/exec"
+	"slices"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
 // availablePhysicalOutputs stores which physical DAC outputs (ch0-ch3) exist.
-// Set by the stream manager during initialization.
-var availablePhysicalOutputs = []int{0} // default: ch0 only (HiFiBerry)
+// Set during initialization and updated when USB DACs are hotplugged.
+var (
+	physOutputsMu            sync.RWMutex
+	availablePhysicalOutputs = []int{0} // default: ch0 only (HiFiBerry)
+)
 
 // SetAvailablePhysicalOutputs configures which physical DAC outputs (ch0-ch3) are available.
-// Called by the stream manager during initialization with data from the hardware profile.
+// Called during initialization with data from the hardware profile, and again
+// whenever the set of present ALSA cards changes.
 func SetAvailablePhysicalOutputs(outputs []int) {
-	availablePhysicalOutputs = make([]int, len(outputs))
-	copy(availablePhysicalOutputs, outputs)
-	slog.Info("alsaloop: available physical outputs configured", "outputs", availablePhysicalOutputs)
+	physOutputsMu.Lock()
+	availablePhysicalOutputs = slices.Clone(outputs)
+	physOutputsMu.Unlock()
+	slog.Info("alsaloop: available physical outputs configured", "outputs", outputs)
 }
 
 // isPhysicalOutputAvailable checks if a physical source has a corresponding ALSA device.
 func isPhysicalOutputAvailable(physSrc int) bool {
-	for _, available := range availablePhysicalOutputs {
-		if available == physSrc {
-			return true
+	physOutputsMu.RLock()
+	defer physOutputsMu.RUnlock()
+	return slices.Contains(availablePhysicalOutputs, physSrc)
+}
+
+// physicalOutputFor returns the physical output alsaloop plays source
+// physSrc to: its own, or ch0 if that doesn't exist (v1 hardware behavior,
+// where the HiFiBerry DAC's dmix mixes every stream).
+func physicalOutputFor(physSrc int) int {
+	if !isPhysicalOutputAvailable(physSrc) {
+		return 0
+	}
+	return physSrc
+}
+
+// sourceProcessing holds each source's channel processing, applied by the
+// alsaloop feeding it. Updated by Manager.Sync.
+var (
+	processingMu     sync.RWMutex
+	sourceProcessing = map[int]models.AudioProcessing{}
+)
+
+// setSourceProcessing records the processing of every source and returns
+// the IDs of the sources whose processing changed.
+func setSourceProcessing(sources []models.Source) map[int]bool {
+	next := make(map[int]models.AudioProcessing)
+	for _, src := range sources {
+		if src.Processing != nil && !src.Processing.IsDefault() {
+			next[src.ID] = *src.Processing
+		}
+	}
+	processingMu.Lock()
+	defer processingMu.Unlock()
+	changed := make(map[int]bool)
+	for id, p := range next {
+		if sourceProcessing[id] != p {
+			changed[id] = true
+		}
+	}
+	for id := range sourceProcessing {
+		if _, ok := next[id]; !ok {
+			changed[id] = true
 		}
 	}
-	return false
+	sourceProcessing = next
+	return changed
+}
+
+// processingFor returns the channel processing of source physSrc.
+func processingFor(physSrc int) models.AudioProcessing {
+	processingMu.RLock()
+	defer processingMu.RUnlock()
+	return sourceProcessing[physSrc]
+}
+
+// setDuckGain sets the duck control of physical output physOut to gain
+// (0-1) with amixer. A variable so tests can replace it.
+var setDuckGain = func(ctx context.Context, physOut int, gain float64) error {
+	card, control := audioLayout.Load().DuckControl(physOut)
+	if card == "" {
+		return fmt.Errorf("output %s isn't in the audio layout", PhysicalOutputDevice(physOut))
+	}
+	out, err := exec.CommandContext(ctx, findBinary("amixer"), "-q", "-c", card,
+		"cset", "name="+control, strconv.Itoa(audio.DuckLevel(gain))).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("set %s on %s: %w: %s", control, card, err, bytes.TrimSpace(out))
+	}
+	return nil
 }
 
 // ALSALoop supervises an alsaloop process that bridges vsrc → physSrc.
@@ -42,12 +117,10 @@ type ALSALoop struct {
 // On v1 hardware (without USB DAC), falls back to ch0 for all sources,
 // allowing ALSA's dmix to mix multiple streams together.
 func NewALSALoop(vsrc, physSrc int) (*ALSALoop, error) {
-	// Fall back to ch0 if requested physical output doesn't exist (v1 hardware behavior)
-	actualPhysSrc := physSrc
-	if !isPhysicalOutputAvailable(physSrc) {
+	actualPhysSrc := physicalOutputFor(physSrc)
+	if actualPhysSrc != physSrc {
 		slog.Warn("alsaloop: physical output not available, falling back to ch0",
-			"requested", physSrc, "available", availablePhysicalOutputs)
-		actualPhysSrc = 0 // Fall back to HiFiBerry DAC (uses dmix for multiple streams)
+			"requested", physSrc)
 	}
 
 	a := &ALSALoop{
@@ -55,7 +128,13 @@ func NewALSALoop(vsrc, physSrc int) (*ALSALoop, error) {
 		physSrc: actualPhysSrc,
 	}
 	capture := VirtualCaptureDevice(vsrc)
-	playback := PhysicalOutputDevice(actualPhysSrc)
+	// Through the output's duck stage, so announcements can turn the music
+	// down without restarting the loop.
+	playback := audioLayout.Load().DuckOutputDevice(actualPhysSrc)
+	if p := processingFor(physSrc); !p.IsDefault() {
+		// Mono/swap/balance need the generated asound.conf's mixer PCM.
+		playback = audioLayout.Load().RoutedOutputDevice(actualPhysSrc, p.Matrix())
+	}
 
 	a.sup = NewSupervisor("alsaloop", func() *exec.Cmd {
 		cmd := exec.Command(findBinary("alsaloop"),
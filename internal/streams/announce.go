@@ -0,0 +1,123 @@
+package streams
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// airplayRate is the sample rate raop_play expects on stdin.
+const airplayRate = 44100
+
+// decodePCMArgs returns ffmpeg arguments decoding media to raw 16-bit stereo
+// PCM at rate, scaled by gain (0.0-1.0), written to out.
+func decodePCMArgs(media string, rate int, gain float64, out string) []string {
+	return []string{
+		"-hide_banner", "-loglevel", "error",
+		"-i", media,
+		"-af", "volume=" + strconv.FormatFloat(gain, 'f', 2, 64),
+		"-f", "s16le", "-ac", "2", "-ar", strconv.Itoa(rate),
+		"-y", out,
+	}
+}
+
+// SnapcastAnnounce plays media on snapserver's announcement stream and
+// returns once it has been fed in full. Clients grouped onto that stream
+// hear it snapcast.BufferMS after it is written.
+func (m *Manager) SnapcastAnnounce(ctx context.Context, media string, gain float64) error {
+	m.mu.Lock()
+	running := m.snap.running()
+	pipe := announcePipe(m.snap.dir)
+	m.mu.Unlock()
+	if !running {
+		return errors.New("snapserver is not running")
+	}
+	cmd := exec.CommandContext(ctx, findBinary("ffmpeg"),
+		decodePCMArgs(media, audioLayout.Load().SampleRate, gain, pipe)...)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if out, err := cmd.CombinedOutput(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("snapcast announce: %w: %s", err, out)
+	}
+	return nil
+}
+
+// MixAnnounce plays media on source sid's output, mixed with the music of
+// the stream connected to it, and returns once it has finished. Physical
+// outputs are shared through a dmix, so the announcement and the source's
+// alsaloop play into the same output at once.
+func (m *Manager) MixAnnounce(ctx context.Context, sid int, media string, gain float64) error {
+	physSrc := sid
+	if !isPhysicalOutputAvailable(physSrc) {
+		physSrc = 0 // as NewALSALoop falls back
+	}
+	cmd := exec.CommandContext(ctx, findBinary("ffmpeg"),
+		"-hide_banner", "-loglevel", "error",
+		"-i", media,
+		"-af", "volume="+strconv.FormatFloat(gain, 'f', 2, 64),
+		"-ac", "2", "-ar", strconv.Itoa(audioLayout.Load().SampleRate),
+		"-f", "alsa", PhysicalOutputDevice(physSrc),
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if out, err := cmd.CombinedOutput(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("mix announce on source %d: %w: %s", sid, err, out)
+	}
+	return nil
+}
+
+// AirPlayAnnounce plays media on the AirPlay receiver at addr ("host" or
+// "host:port") with raop_play, returning when playback ends. latency is the
+// receiver buffer requested from the device; volume is 0-100.
+func AirPlayAnnounce(ctx context.Context, addr, media string, volume int, latency time.Duration) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, "5000"
+	}
+	decode := exec.CommandContext(ctx, findBinary("ffmpeg"), decodePCMArgs(media, airplayRate, 1, "pipe:1")...)
+	decode.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	play := exec.CommandContext(ctx, findBinary("raop_play"),
+		"-p", port,
+		"-v", strconv.Itoa(volume),
+		"-l", strconv.Itoa(int(latency.Seconds()*airplayRate)),
+		host, "-",
+	)
+	play.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("airplay announce: %w", err)
+	}
+	decode.Stdout = w
+	play.Stdin = r
+	startErr := decode.Start()
+	if startErr == nil {
+		if startErr = play.Start(); startErr != nil {
+			_ = decode.Process.Kill()
+			_ = decode.Wait()
+		}
+	}
+	// The children hold their own ends; closing ours lets each see EOF or
+	// EPIPE when the other exits.
+	r.Close()
+	w.Close()
+	if startErr != nil {
+		return fmt.Errorf("airplay announce: %w", startErr)
+	}
+	playErr := play.Wait()
+	decodeErr := decode.Wait()
+	if ctx.Err() != nil {
+		return nil
+	}
+	if playErr != nil {
+		return fmt.Errorf("airplay announce to %s: %w", addr, playErr)
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("airplay announce: ffmpeg: %w", decodeErr)
+	}
+	return nil
+}
@@ -71,9 +71,12 @@ type SubprocStream struct {
 	loop      *ALSALoop
 	vsrc      int
 	configDir string
+	logDir    string // process output logs; empty discards output
+	onStatus  func() // called when the supervisor's status changes
 
-	mu   sync.RWMutex
-	info models.StreamInfo
+	mu        sync.RWMutex
+	info      models.StreamInfo
+	supStatus *models.SupervisorStatus // nil while not activated
 }
 
 // activateBase starts the ALSA loop for a connected stream and
@@ -83,6 +86,15 @@ func (ss *SubprocStream) activateBase(ctx context.Context, vsrc int, configDir s
 	ss.vsrc = vsrc
 	ss.configDir = configDir
 	if ss.sup != nil {
+		ss.sup.setLogDir(ss.logDir)
+		ss.sup.setStatusHook(func(st models.SupervisorStatus) {
+			ss.mu.Lock()
+			ss.supStatus = &st
+			ss.mu.Unlock()
+			if ss.onStatus != nil {
+				ss.onStatus()
+			}
+		})
 		if err := ss.sup.Start(ctx); err != nil {
 			return fmt.Errorf("supervisor start: %w", err)
 		}
@@ -90,6 +102,18 @@ func (ss *SubprocStream) activateBase(ctx context.Context, vsrc int, configDir s
 	return nil
 }
 
+// setLogDir sets where the stream's processes write their output.
+// Called by the Manager before Activate.
+func (ss *SubprocStream) setLogDir(dir string) {
+	ss.logDir = dir
+}
+
+// setStatusHook sets the function called when the stream's process
+// starts, exits or fails. Called by the Manager before Activate.
+func (ss *SubprocStream) setStatusHook(fn func()) {
+	ss.onStatus = fn
+}
+
 // deactivateBase stops the subprocess and the loop.
 func (ss *SubprocStream) deactivateBase(ctx context.Context) error {
 	if ss.loop != nil {
@@ -104,6 +128,13 @@ func (ss *SubprocStream) deactivateBase(ctx context.Context) error {
 		}
 		ss.sup = nil
 	}
+	ss.mu.Lock()
+	hadStatus := ss.supStatus != nil
+	ss.supStatus = nil
+	ss.mu.Unlock()
+	if hadStatus && ss.onStatus != nil {
+		ss.onStatus()
+	}
 	return nil
 }
 
@@ -116,6 +147,7 @@ func (ss *SubprocStream) connectBase(ctx context.Context, physSrc int) error {
 	if err != nil {
 		return fmt.Errorf("alsaloop creation failed: %w", err)
 	}
+	loop.sup.setLogDir(ss.logDir)
 	ss.loop = loop
 	return ss.loop.Start(ctx)
 }
@@ -138,8 +170,17 @@ func (ss *SubprocStream) setInfo(info models.StreamInfo) {
 }
 
 // getInfo returns the current stream info thread-safely.
+// A failed process marks the stream unavailable.
 func (ss *SubprocStream) getInfo() models.StreamInfo {
 	ss.mu.RLock()
 	defer ss.mu.RUnlock()
-	return ss.info
+	info := ss.info
+	if ss.supStatus != nil {
+		st := *ss.supStatus
+		info.Supervisor = &st
+		if st.State == models.SupervisorFailed {
+			info.State = "unavailable"
+		}
+	}
+	return info
 }
@@ -2,27 +2,55 @@ package streams
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
+// busctl runs systemd's busctl to talk to BlueZ over D-Bus. Replaced in
+// tests.
+var busctl = func(ctx context.Context, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, "busctl", args...).Output()
+}
+
+// bluezPollInterval is how often the connected device and its AVRCP
+// player are read from BlueZ.
+const bluezPollInterval = 2 * time.Second
+
 // BluetoothStream receives audio from a Bluetooth A2DP source via bluealsa.
 // Persistent — must remain discoverable and connected between source switches.
+// The connected phone, its AVRCP track metadata and playback controls are
+// reached through BlueZ's D-Bus API.
 type BluetoothStream struct {
 	SubprocStream
-	name string
+	name       string
+	deviceName string // advertised adapter name; the stream name when empty
+	adapter    string // e.g. "hci0"
+
+	player string // D-Bus path of the connected device's AVRCP player
+
+	monCancel context.CancelFunc
+	monWg     sync.WaitGroup
+
+	onChange func(info models.StreamInfo)
 }
 
 // NewBluetoothStream creates a new Bluetooth stream.
 func NewBluetoothStream(name string) *BluetoothStream {
-	return &BluetoothStream{name: name}
+	return &BluetoothStream{name: name, adapter: "hci0"}
 }
 
-// Activate starts bluealsa-aplay which forwards Bluetooth A2DP audio to ALSA.
+// Activate names the adapter and starts bluealsa-aplay which forwards
+// Bluetooth A2DP audio to ALSA.
 func (s *BluetoothStream) Activate(ctx context.Context, vsrc int, configDir string) error {
 	slog.Info("bluetooth: activating", "name", s.name)
 
@@ -31,11 +59,23 @@ func (s *BluetoothStream) Activate(ctx context.Context, vsrc int, configDir stri
 		return fmt.Errorf("bluetooth activate: %w", err)
 	}
 
+	alias := s.deviceName
+	if alias == "" {
+		alias = s.name
+	}
+	if _, err := busctl(ctx, "set-property", "org.bluez", s.adapterPath(), "org.bluez.Adapter1", "Alias", "s", alias); err != nil {
+		// Audio still works under the adapter's previous name
+		slog.Warn("bluetooth: could not set adapter name", "adapter", s.adapter, "alias", alias, "err", err)
+	}
+
 	device := VirtualOutputDevice(vsrc)
 
 	s.sup = NewSupervisor("bluetooth/"+s.name, func() *exec.Cmd {
+		// Only the stream's adapter, so a unit with several adapters
+		// doesn't play every phone into this stream.
 		cmd := exec.Command(findBinary("bluealsa-aplay"),
 			"--profile=a2dp",
+			"-i", s.adapter,
 			"-D", "bluealsa",
 			device,
 		)
@@ -48,11 +88,23 @@ func (s *BluetoothStream) Activate(ctx context.Context, vsrc int, configDir stri
 		State: "stopped",
 	})
 
-	return s.activateBase(ctx, vsrc, dir)
+	if err := s.activateBase(ctx, vsrc, dir); err != nil {
+		return err
+	}
+
+	monCtx, monCancel := context.WithCancel(context.Background())
+	s.monCancel = monCancel
+	s.monWg.Add(1)
+	go s.pollBlueZ(monCtx)
+	return nil
 }
 
 func (s *BluetoothStream) Deactivate(ctx context.Context) error {
 	slog.Info("bluetooth: deactivating", "name", s.name)
+	if s.monCancel != nil {
+		s.monCancel()
+	}
+	s.monWg.Wait()
 	return s.deactivateBase(ctx)
 }
 
@@ -64,10 +116,33 @@ func (s *BluetoothStream) Disconnect(ctx context.Context) error {
 	return s.disconnectBase(ctx)
 }
 
-// SendCmd handles Bluetooth playback controls.
-// AVRCP command relay is not implemented in v1.
-func (s *BluetoothStream) SendCmd(_ context.Context, cmd string) error {
-	slog.Debug("bluetooth: command (not implemented in v1)", "name", s.name, "cmd", cmd)
+// SendCmd relays play, pause, next and prev to the connected phone over
+// AVRCP. Without a connected phone commands are ignored.
+func (s *BluetoothStream) SendCmd(ctx context.Context, cmd string) error {
+	var method string
+	switch cmd {
+	case "play":
+		method = "Play"
+	case "pause":
+		method = "Pause"
+	case "next":
+		method = "Next"
+	case "prev":
+		method = "Previous"
+	default:
+		slog.Debug("bluetooth: unknown command", "cmd", cmd)
+		return nil
+	}
+	s.mu.RLock()
+	player := s.player
+	s.mu.RUnlock()
+	if player == "" {
+		slog.Debug("bluetooth: no connected device with media controls", "name", s.name, "cmd", cmd)
+		return nil
+	}
+	if _, err := busctl(ctx, "call", "org.bluez", player, "org.bluez.MediaPlayer1", method); err != nil {
+		return fmt.Errorf("bluetooth: command %s: %w", cmd, err)
+	}
 	return nil
 }
 
@@ -76,4 +151,134 @@ func (s *BluetoothStream) Info() models.StreamInfo {
 }
 
 func (s *BluetoothStream) IsPersistent() bool { return true }
-func (s *BluetoothStream) Type() string        { return "bluetooth" }
+func (s *BluetoothStream) Type() string       { return "bluetooth" }
+
+func (s *BluetoothStream) adapterPath() string {
+	return "/org/bluez/" + s.adapter
+}
+
+// pollBlueZ periodically reads the connected device and its player.
+func (s *BluetoothStream) pollBlueZ(ctx context.Context) {
+	defer s.monWg.Done()
+
+	ticker := time.NewTicker(bluezPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// refresh reads BlueZ's objects and publishes the stream info if it
+// changed.
+func (s *BluetoothStream) refresh(ctx context.Context) {
+	out, err := busctl(ctx, "--json=short", "call", "org.bluez", "/",
+		"org.freedesktop.DBus.ObjectManager", "GetManagedObjects")
+	if err != nil {
+		slog.Debug("bluetooth: read BlueZ objects failed", "err", err)
+		return
+	}
+	objs, err := parseManagedObjects(out)
+	if err != nil {
+		slog.Debug("bluetooth: parse BlueZ objects failed", "err", err)
+		return
+	}
+	dev, player := connectedDevice(objs, s.adapterPath())
+
+	info := models.StreamInfo{Name: s.name, State: "stopped"}
+	if dev != nil {
+		info.Bluetooth = dev
+		info.State = "connected"
+	}
+	if player != "" {
+		p := objs[player]["org.bluez.MediaPlayer1"]
+		if status := propString(p["Status"]); status == "playing" || status == "paused" {
+			info.State = status
+		}
+		if track, ok := p["Track"].Data.(map[string]interface{}); ok {
+			info.Track = variantString(track["Title"])
+			info.Artist = variantString(track["Artist"])
+			info.Album = variantString(track["Album"])
+		}
+	}
+
+	s.mu.Lock()
+	s.player = player
+	unchanged := reflect.DeepEqual(s.info, info)
+	if !unchanged {
+		s.info = info
+	}
+	s.mu.Unlock()
+	if !unchanged && s.onChange != nil {
+		s.onChange(s.getInfo())
+	}
+}
+
+// dbusValue is a D-Bus variant as printed by busctl --json.
+type dbusValue struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+// bluezObjects maps object path → interface → property.
+type bluezObjects map[string]map[string]map[string]dbusValue
+
+// parseManagedObjects decodes busctl's JSON output of
+// ObjectManager.GetManagedObjects.
+func parseManagedObjects(out []byte) (bluezObjects, error) {
+	var reply struct {
+		Data []bluezObjects `json:"data"`
+	}
+	if err := json.Unmarshal(out, &reply); err != nil {
+		return nil, err
+	}
+	if len(reply.Data) == 0 {
+		return nil, fmt.Errorf("empty reply")
+	}
+	return reply.Data[0], nil
+}
+
+// connectedDevice returns the first connected device on the adapter and
+// the path of its AVRCP player, if it has one.
+func connectedDevice(objs bluezObjects, adapter string) (*models.BluetoothDevice, string) {
+	paths := make([]string, 0, len(objs))
+	for path := range objs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		d, ok := objs[path]["org.bluez.Device1"]
+		if !ok || !strings.HasPrefix(path, adapter+"/") || d["Connected"].Data != true {
+			continue
+		}
+		dev := &models.BluetoothDevice{Name: propString(d["Alias"]), Address: propString(d["Address"])}
+		if dev.Name == "" {
+			dev.Name = propString(d["Name"])
+		}
+		for _, p := range paths {
+			if _, ok := objs[p]["org.bluez.MediaPlayer1"]; ok && strings.HasPrefix(p, path+"/") {
+				return dev, p
+			}
+		}
+		return dev, ""
+	}
+	return nil, ""
+}
+
+func propString(v dbusValue) string {
+	s, _ := v.Data.(string)
+	return s
+}
+
+// variantString returns the string in a nested variant, e.g. a field of
+// MediaPlayer1.Track.
+func variantString(v interface{}) string {
+	m, _ := v.(map[string]interface{})
+	s, _ := m["data"].(string)
+	return s
+}
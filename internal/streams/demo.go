@@ -0,0 +1,229 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// DemoTone is the demo audio setting that plays a tone per source.
+const DemoTone = "tone"
+
+// demoAudio is "" for real streams, DemoTone, or a sample file looped by
+// every stream.
+var demoAudio atomic.Value // string
+
+// demoDevice is the ALSA device demo audio plays to.
+const demoDevice = "default"
+
+// demoTones are the tone frequencies of sources 0-3 in Hz, an A major
+// chord so several sources playing at once still sound tolerable.
+var demoTones = []float64{220, 277.18, 329.63, 440}
+
+// demoTrackLength is how long each demo track lasts.
+var demoTrackLength = 30 * time.Second
+
+// SetDemoAudio makes every stream created from now on a demo stream
+// playing audio to the ALSA default device: DemoTone for a tone per
+// source, or the path of a sample to loop. "" goes back to real streams.
+// For demo installs and UI development with mock hardware.
+func SetDemoAudio(audio string) {
+	demoAudio.Store(audio)
+	if audio != "" {
+		slog.Info("streams: demo audio configured", "audio", audio)
+	}
+}
+
+// DemoAudio returns the demo audio setting ("" if off).
+func DemoAudio() string {
+	a, _ := demoAudio.Load().(string)
+	return a
+}
+
+// DemoStream stands in for a stream of any type: it plays demo audio
+// while connected and moves through numbered tracks, answering play,
+// pause, stop, next and prev like a real player.
+type DemoStream struct {
+	SubprocStream
+	name    string
+	typ     string
+	audio   string
+	physSrc int
+
+	dmu     sync.Mutex
+	track   int
+	state   string // playing, paused or stopped
+	started time.Time
+
+	onChange  func(models.StreamInfo)
+	monCancel context.CancelFunc
+	monWg     sync.WaitGroup
+}
+
+// NewDemoStream creates a demo stream standing in for stream, playing
+// audio (DemoTone or a sample path).
+func NewDemoStream(stream models.Stream, audio string) *DemoStream {
+	return &DemoStream{
+		name: stream.Name, typ: stream.Type, audio: audio, physSrc: -1,
+		track: 1, state: "playing",
+	}
+}
+
+// Activate starts the track progression.
+func (s *DemoStream) Activate(ctx context.Context, vsrc int, configDir string) error {
+	slog.Info("demo: activating", "name", s.name, "type", s.typ)
+	s.vsrc, s.configDir = vsrc, configDir
+	s.dmu.Lock()
+	s.started = time.Now()
+	s.dmu.Unlock()
+	s.publish()
+
+	monCtx, monCancel := context.WithCancel(context.Background())
+	s.monCancel = monCancel
+	s.monWg.Add(1)
+	go s.progress(monCtx)
+	return nil
+}
+
+// Deactivate stops the track progression and the player.
+func (s *DemoStream) Deactivate(ctx context.Context) error {
+	slog.Info("demo: deactivating", "name", s.name)
+	if s.monCancel != nil {
+		s.monCancel()
+	}
+	s.monWg.Wait()
+	s.setInfo(models.StreamInfo{Name: s.name, State: "stopped"})
+	return s.deactivateBase(ctx)
+}
+
+// Connect starts playing to the demo device, unless paused or stopped.
+func (s *DemoStream) Connect(ctx context.Context, physSrc int) error {
+	s.physSrc = physSrc
+	return s.startPlayer(ctx)
+}
+
+// Disconnect stops the player.
+func (s *DemoStream) Disconnect(ctx context.Context) error {
+	s.physSrc = -1
+	return s.deactivateBase(ctx)
+}
+
+// SendCmd handles play, pause, stop, next and prev.
+func (s *DemoStream) SendCmd(ctx context.Context, cmd string) error {
+	s.dmu.Lock()
+	state := s.state
+	switch cmd {
+	case "play":
+		s.state = "playing"
+	case "pause":
+		s.state = "paused"
+	case "stop":
+		s.state, s.track = "stopped", 1
+	case "next":
+		s.track++
+		s.started = time.Now()
+	case "prev":
+		s.track = max(1, s.track-1)
+		s.started = time.Now()
+	default:
+		s.dmu.Unlock()
+		slog.Debug("demo: unknown command", "cmd", cmd)
+		return nil
+	}
+	changed := s.state != state
+	s.dmu.Unlock()
+
+	var err error
+	if changed {
+		if cmd == "play" {
+			err = s.startPlayer(ctx)
+		} else {
+			err = s.deactivateBase(ctx)
+		}
+	}
+	s.publish()
+	return err
+}
+
+// startPlayer runs ffmpeg playing the demo audio to the demo device while
+// the stream is connected and playing.
+func (s *DemoStream) startPlayer(ctx context.Context) error {
+	s.dmu.Lock()
+	playing := s.state == "playing"
+	s.dmu.Unlock()
+	if s.physSrc < 0 || !playing || s.sup != nil {
+		return nil
+	}
+	args := []string{"-hide_banner", "-loglevel", "error"}
+	if s.audio == DemoTone {
+		freq := demoTones[s.physSrc%len(demoTones)]
+		args = append(args, "-f", "lavfi", "-i", "sine=frequency="+strconv.FormatFloat(freq, 'f', -1, 64))
+	} else {
+		args = append(args, "-stream_loop", "-1", "-i", s.audio)
+	}
+	args = append(args, "-ac", "2", "-f", "alsa", demoDevice)
+	s.sup = NewSupervisor(fmt.Sprintf("demo/%s", s.name), func() *exec.Cmd {
+		cmd := exec.Command(findBinary("ffmpeg"), args...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		return cmd
+	})
+	return s.activateBase(ctx, s.vsrc, s.configDir)
+}
+
+// progress moves to the next track every demoTrackLength of playing.
+func (s *DemoStream) progress(ctx context.Context) {
+	defer s.monWg.Done()
+	ticker := time.NewTicker(min(time.Second, demoTrackLength))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.dmu.Lock()
+			next := s.state == "playing" && now.Sub(s.started) >= demoTrackLength
+			if next {
+				s.track++
+				s.started = now
+			} else if s.state != "playing" {
+				s.started = s.started.Add(min(time.Second, demoTrackLength)) // paused time doesn't count
+			}
+			s.dmu.Unlock()
+			if next {
+				s.publish()
+			}
+		}
+	}
+}
+
+// publish updates the stream info and reports it.
+func (s *DemoStream) publish() {
+	s.dmu.Lock()
+	info := models.StreamInfo{
+		Name:   s.name,
+		State:  s.state,
+		Track:  fmt.Sprintf("Demo Track %d", s.track),
+		Artist: "AmpliPi Demo",
+		Album:  s.typ,
+	}
+	s.dmu.Unlock()
+	s.setInfo(info)
+	if s.onChange != nil {
+		s.onChange(s.getInfo())
+	}
+}
+
+func (s *DemoStream) Info() models.StreamInfo {
+	return s.getInfo()
+}
+
+func (s *DemoStream) IsPersistent() bool { return false }
+func (s *DemoStream) Type() string       { return s.typ }
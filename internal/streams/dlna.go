@@ -2,9 +2,12 @@ package streams
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"strings"
+	"sync"
 	"syscall"
 
 	"github.com/google/uuid"
@@ -14,9 +17,16 @@ import (
 
 // DLNAStream is a DLNA/UPnP audio renderer using gmrender-resurrect.
 // Persistent — must advertise on the network continuously.
+// It can also browse the media servers on the LAN and play their tracks
+// on its own renderer.
 type DLNAStream struct {
 	SubprocStream
 	name string
+
+	mu        sync.Mutex
+	uuid      string                 // renderer UDN while active
+	transport string                 // renderer AVTransport control URL, found on first use
+	servers   map[string]*upnpDevice // media servers by UDN, from the last discovery
 }
 
 // NewDLNAStream creates a new DLNA stream.
@@ -35,6 +45,9 @@ func (s *DLNAStream) Activate(ctx context.Context, vsrc int, configDir string) e
 
 	// Generate a stable UUID from the stream name + vsrc for identity persistence
 	deviceUUID := uuid.NewString()
+	s.mu.Lock()
+	s.uuid, s.transport = deviceUUID, ""
+	s.mu.Unlock()
 	name := s.name
 	device := VirtualOutputDevice(vsrc)
 
@@ -59,6 +72,9 @@ func (s *DLNAStream) Activate(ctx context.Context, vsrc int, configDir string) e
 
 func (s *DLNAStream) Deactivate(ctx context.Context) error {
 	slog.Info("dlna: deactivating", "name", s.name)
+	s.mu.Lock()
+	s.uuid, s.transport = "", ""
+	s.mu.Unlock()
 	return s.deactivateBase(ctx)
 }
 
@@ -70,13 +86,32 @@ func (s *DLNAStream) Disconnect(ctx context.Context) error {
 	return s.disconnectBase(ctx)
 }
 
-// SendCmd handles DLNA playback controls.
-// Metadata and command relay are not implemented in v1.
-func (s *DLNAStream) SendCmd(_ context.Context, cmd string) error {
-	slog.Debug("dlna: command (not implemented in v1)", "name", s.name, "cmd", cmd)
+// SendCmd handles DLNA playback controls on the local renderer: play,
+// pause, stop, and play=<id> for tracks returned by Browse.
+func (s *DLNAStream) SendCmd(ctx context.Context, cmd string) error {
+	switch {
+	case strings.HasPrefix(cmd, "play="):
+		return s.playItem(ctx, strings.TrimPrefix(cmd, "play="))
+	case cmd == "play":
+		return ignoreInactive(s.transportAction(ctx, "Play", soapArg{"Speed", "1"}))
+	case cmd == "pause":
+		return ignoreInactive(s.transportAction(ctx, "Pause"))
+	case cmd == "stop":
+		return ignoreInactive(s.transportAction(ctx, "Stop"))
+	}
+	slog.Debug("dlna: unknown command", "name", s.name, "cmd", cmd)
 	return nil
 }
 
+// ignoreInactive drops ErrNotActive: playback controls on a stream that
+// is not running have nothing to act on.
+func ignoreInactive(err error) error {
+	if errors.Is(err, ErrNotActive) {
+		return nil
+	}
+	return err
+}
+
 func (s *DLNAStream) Info() models.StreamInfo {
 	return s.getInfo()
 }
@@ -0,0 +1,185 @@
+package streams
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// ssdpWait is how long discovery waits for devices to answer.
+const ssdpWait = 2 * time.Second
+
+// dlnaItemID builds a browse ID from a server UDN and a ContentDirectory
+// object ID. Object IDs may contain any character, so they are encoded to
+// keep browse paths URL-safe.
+func dlnaItemID(server, object string) string {
+	return server + "/" + base64.RawURLEncoding.EncodeToString([]byte(object))
+}
+
+// parseDLNAItemID splits a browse path into server UDN and object ID; a
+// bare server is its root container "0".
+func parseDLNAItemID(path string) (server, object string, err error) {
+	server, enc, _ := strings.Cut(strings.Trim(path, "/"), "/")
+	if enc == "" {
+		return server, "0", nil
+	}
+	obj, err := base64.RawURLEncoding.DecodeString(enc)
+	if err != nil {
+		return "", "", fs.ErrNotExist
+	}
+	return server, string(obj), nil
+}
+
+// discoverServers searches the LAN for media servers with a ContentDirectory.
+func (s *DLNAStream) discoverServers(ctx context.Context) map[string]*upnpDevice {
+	locations, err := ssdpSearch(ctx, upnpMediaServer, ssdpWait)
+	if err != nil {
+		slog.Warn("dlna: media server discovery failed", "err", err)
+	}
+	servers := make(map[string]*upnpDevice)
+	for _, loc := range locations {
+		dev, err := fetchUPnPDevice(ctx, loc)
+		if err != nil {
+			slog.Debug("dlna: skipping device", "location", loc, "err", err)
+			continue
+		}
+		if dev.UDN != "" && dev.Services[upnpContentDirectory] != "" {
+			servers[dev.UDN] = dev
+		}
+	}
+	s.mu.Lock()
+	s.servers = servers
+	s.mu.Unlock()
+	return servers
+}
+
+// server returns a media server by UDN, rediscovering if it is unknown.
+func (s *DLNAStream) server(ctx context.Context, udn string) (*upnpDevice, error) {
+	s.mu.Lock()
+	dev := s.servers[udn]
+	s.mu.Unlock()
+	if dev == nil {
+		dev = s.discoverServers(ctx)[udn]
+	}
+	if dev == nil {
+		return nil, fs.ErrNotExist
+	}
+	return dev, nil
+}
+
+// Browse lists the media servers on the LAN (path ""), or the folders and
+// tracks of a server container. Item IDs are "<server UDN>/<object>".
+func (s *DLNAStream) Browse(ctx context.Context, path string) ([]models.BrowsableItem, error) {
+	items := []models.BrowsableItem{}
+	if strings.Trim(path, "/") == "" {
+		for udn, dev := range s.discoverServers(ctx) {
+			items = append(items, models.BrowsableItem{ID: udn, Name: dev.FriendlyName, Type: "folder"})
+		}
+		slices.SortFunc(items, func(a, b models.BrowsableItem) int { return strings.Compare(a.Name, b.Name) })
+		return items, nil
+	}
+
+	udn, object, err := parseDLNAItemID(path)
+	if err != nil {
+		return nil, err
+	}
+	dev, err := s.server(ctx, udn)
+	if err != nil {
+		return nil, err
+	}
+	objs, _, err := contentDirectoryBrowse(ctx, dev.Services[upnpContentDirectory], object, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range objs {
+		item := models.BrowsableItem{ID: dlnaItemID(udn, o.ID), Name: o.Title, Thumbnail: o.AlbumArt}
+		switch {
+		case o.Container && strings.HasPrefix(o.Class, "object.container.album"):
+			item.Type = "album"
+		case o.Container && strings.HasPrefix(o.Class, "object.container.playlistContainer"):
+			item.Type = "playlist"
+		case o.Container:
+			item.Type = "folder"
+		case strings.HasPrefix(o.Class, "object.item.audioItem") && o.Res != "":
+			item.Type = "track"
+		default:
+			continue // video, images
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// playItem plays a track from a media server on this stream's renderer.
+func (s *DLNAStream) playItem(ctx context.Context, id string) error {
+	udn, object, err := parseDLNAItemID(id)
+	if err != nil || object == "0" {
+		return fmt.Errorf("dlna: cannot play %q", id)
+	}
+	dev, err := s.server(ctx, udn)
+	if err != nil {
+		return fmt.Errorf("dlna: media server %s not found", udn)
+	}
+	objs, didl, err := contentDirectoryBrowse(ctx, dev.Services[upnpContentDirectory], object, true)
+	if err != nil {
+		return err
+	}
+	if len(objs) != 1 || objs[0].Container || objs[0].Res == "" {
+		return fmt.Errorf("dlna: %q is not a track", id)
+	}
+	if err := s.transportAction(ctx, "SetAVTransportURI",
+		soapArg{"CurrentURI", objs[0].Res},
+		soapArg{"CurrentURIMetaData", didl},
+	); err != nil {
+		return err
+	}
+	return s.transportAction(ctx, "Play", soapArg{"Speed", "1"})
+}
+
+// transportAction invokes an AVTransport action on the local renderer.
+func (s *DLNAStream) transportAction(ctx context.Context, action string, args ...soapArg) error {
+	url, err := s.transportURL(ctx)
+	if err != nil {
+		return err
+	}
+	args = append([]soapArg{{"InstanceID", "0"}}, args...)
+	_, err = soapCall(ctx, url, upnpAVTransport, action, args...)
+	return err
+}
+
+// transportURL finds the AVTransport control URL of the local renderer
+// by searching for its UDN.
+func (s *DLNAStream) transportURL(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	id, url := s.uuid, s.transport
+	s.mu.Unlock()
+	if url != "" {
+		return url, nil
+	}
+	if id == "" {
+		return "", ErrNotActive
+	}
+	locations, err := ssdpSearch(ctx, "uuid:"+id, ssdpWait)
+	if err != nil {
+		return "", err
+	}
+	for _, loc := range locations {
+		dev, err := fetchUPnPDevice(ctx, loc)
+		if err != nil || dev.UDN != id || dev.Services[upnpAVTransport] == "" {
+			continue
+		}
+		s.mu.Lock()
+		s.transport = dev.Services[upnpAVTransport]
+		s.mu.Unlock()
+		return dev.Services[upnpAVTransport], nil
+	}
+	return "", errors.New("dlna: renderer not found on the network")
+}
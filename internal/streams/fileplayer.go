@@ -5,17 +5,35 @@ import (
 	"fmt"
 	"log/slog"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
-// FilePlayerStream plays a local file or directory via VLC.
-// Non-persistent — only needed when actively playing.
+// FilePlayerStream plays local files, directories, m3u playlists or URLs
+// through VLC, one track at a time from a queue that can be edited while
+// playing. Non-persistent — only needed when actively playing.
 type FilePlayerStream struct {
 	SubprocStream
-	name string
-	path string
+	name     string
+	path     string // initial queue: file, directory, playlist or URL
+	onChange func(info models.StreamInfo)
+
+	qmu     sync.Mutex
+	queue   playQueue
+	stopped bool      // stop command, or every track failed to play
+	paused  bool      // current VLC is SIGSTOPped
+	player  *exec.Cmd // VLC playing the current track; nil between tracks
+	skip    bool      // player was killed by a command that already moved the queue
+	kill    context.CancelFunc
+	wake    chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
 }
 
 // NewFilePlayerStream creates a new file player stream.
@@ -23,10 +41,12 @@ func NewFilePlayerStream(name, path string) *FilePlayerStream {
 	return &FilePlayerStream{
 		name: name,
 		path: path,
+		wake: make(chan struct{}, 1),
 	}
 }
 
-// Activate creates the config dir and starts VLC.
+// Activate creates the config dir and starts playing the queue, loading it
+// from the configured path if it is empty.
 func (s *FilePlayerStream) Activate(ctx context.Context, vsrc int, configDir string) error {
 	slog.Info("file_player: activating", "name", s.name, "path", s.path)
 
@@ -35,27 +55,167 @@ func (s *FilePlayerStream) Activate(ctx context.Context, vsrc int, configDir str
 		return fmt.Errorf("file_player activate: %w", err)
 	}
 
-	device := VirtualOutputDevice(vsrc)
-	path := s.path
+	s.qmu.Lock()
+	if len(s.queue.tracks) == 0 && s.path != "" {
+		tracks, err := resolveTracks(s.path)
+		if err != nil {
+			s.qmu.Unlock()
+			return fmt.Errorf("file_player activate: %w", err)
+		}
+		s.queue.set(tracks)
+	}
+	s.stopped, s.paused = false, false
+	runCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.qmu.Unlock()
+
+	go s.run(runCtx, VirtualOutputDevice(vsrc))
+	return s.activateBase(ctx, vsrc, dir)
+}
+
+// run plays the queue until ctx is cancelled, idling when it is stopped
+// or finished until a command wakes it.
+func (s *FilePlayerStream) run(ctx context.Context, device string) {
+	defer close(s.done)
+	quickExits := 0
+	for {
+		s.qmu.Lock()
+		track, ok := s.queue.current()
+		ok = ok && !s.stopped
+		// kill is installed before VLC starts so a command arriving in
+		// between still cancels this track.
+		playCtx, kill := context.WithCancel(ctx)
+		if ok {
+			s.kill = kill
+		}
+		s.qmu.Unlock()
 
-	s.sup = NewSupervisor("file_player/"+s.name, func() *exec.Cmd {
-		cmd := exec.Command(findBinary("vlc"),
+		if !ok {
+			kill()
+			s.publish("stopped", "")
+			select {
+			case <-ctx.Done():
+				return
+			case <-s.wake:
+				continue
+			}
+		}
+
+		s.publish("playing", track)
+		cmd := exec.CommandContext(playCtx, findBinary("vlc"),
 			"--intf", "dummy",
 			"--aout", "alsa",
 			"--alsa-audio-device", device,
 			"--no-video",
-			path,
+			"--play-and-exit",
+			track,
 		)
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
-		return cmd
-	})
+		start := time.Now()
+		err := cmd.Start()
+		if err == nil {
+			s.qmu.Lock()
+			s.player = cmd
+			s.qmu.Unlock()
+			err = cmd.Wait()
+		}
+		kill()
+		if ctx.Err() != nil {
+			return
+		}
 
-	s.setInfo(models.StreamInfo{Name: s.name, State: "playing"})
-	return s.activateBase(ctx, vsrc, dir)
+		s.qmu.Lock()
+		s.player, s.kill = nil, nil
+		s.paused = false
+		skipped := s.skip
+		s.skip = false
+		if !skipped {
+			// Guard against spinning through a queue of unplayable tracks.
+			if time.Since(start) < time.Second {
+				quickExits++
+			} else {
+				quickExits = 0
+			}
+			if quickExits > len(s.queue.tracks) {
+				slog.Warn("file_player: tracks failing to play, stopping", "name", s.name, "err", err)
+				s.stopped = true
+				quickExits = 0
+			} else {
+				s.queue.next() // at the end the queue reports finished and the loop idles
+			}
+		}
+		s.qmu.Unlock()
+	}
+}
+
+// publish updates the stream info and reports changes.
+func (s *FilePlayerStream) publish(state, track string) {
+	info := models.StreamInfo{Name: s.name, State: state}
+	if track != "" {
+		info.Track = trackTitle(track)
+		if !isURL(track) {
+			info.Album = filepath.Base(filepath.Dir(track))
+		}
+	}
+	prev := s.getInfo()
+	s.setInfo(info)
+	if s.onChange != nil && (prev.State != info.State || prev.Track != info.Track) {
+		s.onChange(info)
+	}
+}
+
+// setState changes only the playback state of the stream info.
+func (s *FilePlayerStream) setState(state string) {
+	info := s.getInfo()
+	info.State = state
+	s.setInfo(info)
+	if s.onChange != nil {
+		s.onChange(info)
+	}
+}
+
+// trackTitle is the display name of a track: its file name without extension.
+func trackTitle(track string) string {
+	if isURL(track) {
+		return track
+	}
+	base := filepath.Base(track)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// interrupt ends the current track so the player loop picks up a queue
+// change. Must be called with s.qmu held.
+func (s *FilePlayerStream) interrupt() {
+	if s.kill != nil {
+		s.skip = true
+		s.kill()
+	}
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// signalPlayer sends sig to the VLC process group. Must be called with s.qmu held.
+func (s *FilePlayerStream) signalPlayer(sig syscall.Signal) {
+	if s.player != nil && s.player.Process != nil {
+		_ = syscall.Kill(-s.player.Process.Pid, sig)
+	}
 }
 
 func (s *FilePlayerStream) Deactivate(ctx context.Context) error {
 	slog.Info("file_player: deactivating", "name", s.name)
+	s.qmu.Lock()
+	cancel, done := s.cancel, s.done
+	s.cancel = nil
+	s.signalPlayer(syscall.SIGCONT) // a stopped process cannot act on the kill
+	s.qmu.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	s.setInfo(models.StreamInfo{Name: s.name, State: "stopped"})
 	return s.deactivateBase(ctx)
 }
 
@@ -67,11 +227,168 @@ func (s *FilePlayerStream) Disconnect(ctx context.Context) error {
 	return s.disconnectBase(ctx)
 }
 
+// SendCmd controls playback and edits the queue:
+//
+//	play, pause, stop, next, prev
+//	load=<path>        replace the queue with a file, directory, playlist or URL
+//	play=<path>        same as load, for browsed items
+//	add=<path>         append to the queue
+//	jump=<n>           play queue entry n
+//	remove=<n>         remove queue entry n
+//	move=<from>,<to>   reorder the queue
+//	clear              empty the queue
+//	shuffle=on|off, repeat=on|off
+//
+// Paths are relative to the media directory unless absolute or a URL.
 func (s *FilePlayerStream) SendCmd(_ context.Context, cmd string) error {
-	slog.Debug("file_player: command ignored", "name", s.name, "cmd", cmd)
+	name, arg, _ := strings.Cut(cmd, "=")
+	if name == "play" && arg != "" {
+		name = "load"
+	}
+
+	// Resolve paths before taking the lock: directories can be large.
+	var tracks []string
+	if name == "load" || name == "add" {
+		var err error
+		if tracks, err = resolveTracks(arg); err != nil {
+			return fmt.Errorf("file_player %s: %w", name, err)
+		}
+	}
+
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	q := &s.queue
+	switch name {
+	case "play":
+		if s.paused {
+			s.signalPlayer(syscall.SIGCONT)
+			s.paused = false
+			s.setState("playing")
+			return nil
+		}
+		if s.kill == nil {
+			// Idle: start again, from the top if the queue had finished.
+			q.restart()
+			s.stopped = false
+			s.interrupt()
+		}
+	case "pause":
+		if s.player != nil && !s.paused {
+			s.signalPlayer(syscall.SIGSTOP)
+			s.paused = true
+			s.setState("paused")
+		}
+	case "stop":
+		s.stopped = true
+		s.signalPlayer(syscall.SIGCONT)
+		s.paused = false
+		s.interrupt()
+	case "next":
+		q.next()
+		s.signalPlayer(syscall.SIGCONT)
+		s.interrupt()
+	case "prev":
+		q.prev()
+		s.stopped = false
+		s.signalPlayer(syscall.SIGCONT)
+		s.interrupt()
+	case "load":
+		q.set(tracks)
+		s.stopped = false
+		s.signalPlayer(syscall.SIGCONT)
+		s.interrupt()
+	case "add":
+		// Playback continues with the new tracks if the queue was empty
+		// or had finished, unless it was stopped.
+		q.add(tracks...)
+		if s.player == nil {
+			select {
+			case s.wake <- struct{}{}:
+			default:
+			}
+		}
+	case "jump", "remove":
+		n, err := strconv.Atoi(arg)
+		if err != nil {
+			return fmt.Errorf("file_player %s: invalid index %q", name, arg)
+		}
+		if name == "jump" {
+			if err := q.jump(n); err != nil {
+				return err
+			}
+			s.stopped = false
+			s.signalPlayer(syscall.SIGCONT)
+			s.interrupt()
+			return nil
+		}
+		wasCurrent, err := q.remove(n)
+		if err != nil {
+			return err
+		}
+		if wasCurrent {
+			s.signalPlayer(syscall.SIGCONT)
+			s.interrupt()
+		}
+	case "move":
+		from, to, ok := strings.Cut(arg, ",")
+		f, err1 := strconv.Atoi(from)
+		t, err2 := strconv.Atoi(to)
+		if !ok || err1 != nil || err2 != nil {
+			return fmt.Errorf("file_player move: expected move=<from>,<to>")
+		}
+		return q.move(f, t)
+	case "clear":
+		q.set(nil)
+		s.signalPlayer(syscall.SIGCONT)
+		s.interrupt()
+	case "shuffle", "repeat":
+		on, err := parseOnOff(arg)
+		if err != nil {
+			return fmt.Errorf("file_player %s: %w", name, err)
+		}
+		if name == "shuffle" {
+			q.setShuffle(on)
+		} else {
+			q.repeat = on
+		}
+	default:
+		slog.Debug("file_player: command ignored", "name", s.name, "cmd", cmd)
+	}
 	return nil
 }
 
+// parseOnOff parses a command toggle argument.
+func parseOnOff(arg string) (bool, error) {
+	switch arg {
+	case "on", "true", "1":
+		return true, nil
+	case "off", "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected on or off, got %q", arg)
+}
+
+// Queue returns the current queue for the API.
+func (s *FilePlayerStream) Queue() models.StreamQueue {
+	s.qmu.Lock()
+	defer s.qmu.Unlock()
+	tracks := make([]string, len(s.queue.tracks))
+	for i, t := range s.queue.tracks {
+		tracks[i] = relMediaPath(t)
+	}
+	return models.StreamQueue{
+		Tracks:   tracks,
+		Position: s.queue.currentIndex(),
+		Shuffle:  s.queue.shuffle,
+		Repeat:   s.queue.repeat,
+	}
+}
+
+// Browse lists a folder of the media directory.
+func (s *FilePlayerStream) Browse(_ context.Context, path string) ([]models.BrowsableItem, error) {
+	return browseMedia(path)
+}
+
 func (s *FilePlayerStream) Info() models.StreamInfo {
 	return s.getInfo()
 }
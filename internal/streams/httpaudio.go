@@ -0,0 +1,27 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"syscall"
+)
+
+// EncodeMP3 captures an ALSA device and writes it to w as an endless MP3
+// stream until ctx is cancelled or w fails. Used to serve sources to
+// network players (Google Cast) that pull media over HTTP.
+func EncodeMP3(ctx context.Context, device string, w io.Writer) error {
+	cmd := exec.CommandContext(ctx, findBinary("ffmpeg"),
+		"-hide_banner", "-loglevel", "error",
+		"-f", "alsa", "-i", device,
+		"-ac", "2", "-c:a", "libmp3lame", "-b:a", "192k",
+		"-f", "mp3", "pipe:1",
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Stdout = w
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("ffmpeg mp3 encode of %s: %w", device, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package streams
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// PeakLevel captures d of an ALSA device and returns its peak level in
+// dBFS, -Inf for digital silence.
+func PeakLevel(ctx context.Context, device string, d time.Duration) (float64, error) {
+	cmd := exec.CommandContext(ctx, findBinary("ffmpeg"),
+		"-hide_banner", "-loglevel", "error",
+		"-f", "alsa", "-i", device,
+		"-t", strconv.FormatFloat(d.Seconds(), 'f', 3, 64),
+		"-ac", "2", "-f", "s16le", "pipe:1",
+	)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return 0, err
+	}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("ffmpeg capture of %s: %w", device, err)
+	}
+	peak, readErr := peakS16(out)
+	if err := cmd.Wait(); err != nil {
+		return 0, fmt.Errorf("ffmpeg capture of %s: %w", device, err)
+	}
+	if readErr != nil {
+		return 0, readErr
+	}
+	return peak, nil
+}
+
+// peakS16 returns the peak level of signed 16-bit little-endian samples in
+// dBFS, -Inf if every sample is zero.
+func peakS16(r io.Reader) (float64, error) {
+	br := bufio.NewReader(r)
+	var peak int32
+	buf := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return 0, err
+		}
+		v := int32(int16(binary.LittleEndian.Uint16(buf)))
+		peak = max(peak, v, -v)
+	}
+	if peak == 0 {
+		return math.Inf(-1), nil
+	}
+	return 20 * math.Log10(float64(peak)/32768), nil
+}
+
+// SourceLevel measures the peak level of source sid's audio over d, in
+// dBFS. Returns ErrNotActive if no stream with a vsrc is connected to it.
+func (m *Manager) SourceLevel(ctx context.Context, sid int, d time.Duration) (float64, error) {
+	device, ok := m.SourceCaptureDevice(sid)
+	if !ok {
+		return 0, ErrNotActive
+	}
+	return PeakLevel(ctx, device, d)
+}
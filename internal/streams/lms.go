@@ -26,6 +26,7 @@ type LMSStream struct {
 	name   string
 	server string // LMS server IP, empty = auto-discover
 
+	srvMu     sync.Mutex
 	lmsServer string // resolved server (possibly discovered)
 
 	monCancel context.CancelFunc
@@ -61,10 +62,13 @@ func (s *LMSStream) Activate(ctx context.Context, vsrc int, configDir string) er
 	}
 
 	// Determine server (may be auto-discovered at runtime by squeezelite)
-	s.lmsServer = s.server
-	if s.lmsServer == "" {
-		s.lmsServer = discoverLMSServer()
+	srv := s.server
+	if srv == "" {
+		srv = discoverLMSServer()
 	}
+	s.srvMu.Lock()
+	s.lmsServer = srv
+	s.srvMu.Unlock()
 
 	mac := lmsMACAddress(s.name)
 	device := VirtualOutputDevice(vsrc)
@@ -121,9 +125,25 @@ func (s *LMSStream) Disconnect(ctx context.Context) error {
 	return s.disconnectBase(ctx)
 }
 
-func (s *LMSStream) SendCmd(_ context.Context, cmd string) error {
-	slog.Debug("lms: command (not implemented)", "name", s.name, "cmd", cmd)
-	return nil
+// SendCmd controls the player through the LMS server: play, pause, stop,
+// next, prev, and play=<id> for items returned by Browse.
+func (s *LMSStream) SendCmd(ctx context.Context, cmd string) error {
+	lmsCmd, err := lmsCommand(cmd)
+	if err != nil {
+		return err
+	}
+	if lmsCmd == nil {
+		slog.Debug("lms: unknown command", "name", s.name, "cmd", cmd)
+		return nil
+	}
+	server, err := s.lmsServerAddr()
+	if err != nil {
+		if strings.HasPrefix(cmd, "play=") {
+			return err
+		}
+		return ignoreInactive(err)
+	}
+	return lmsRequest(ctx, server, lmsMACAddress(s.name), nil, lmsCmd...)
 }
 
 func (s *LMSStream) Info() models.StreamInfo {
@@ -178,7 +198,7 @@ func (s *LMSStream) pollMetadata(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			srv := s.lmsServer
+			srv, _ := s.lmsServerAddr()
 			if srv == "" {
 				continue
 			}
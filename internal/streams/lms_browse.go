@@ -0,0 +1,279 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// lmsBrowseLimit caps the number of items returned per LMS menu.
+const lmsBrowseLimit = 500
+
+// lmsMenus are the top-level LMS library menus.
+var lmsMenus = []models.BrowsableItem{
+	{ID: "artists", Name: "Artists", Type: "folder"},
+	{ID: "albums", Name: "Albums", Type: "folder"},
+	{ID: "genres", Name: "Genres", Type: "folder"},
+	{ID: "playlists", Name: "Playlists", Type: "folder"},
+	{ID: "favorites", Name: "Favorites", Type: "folder"},
+}
+
+// lmsRequest sends a command to the LMS JSON-RPC API on behalf of player
+// and decodes the result into out (which may be nil).
+func lmsRequest(ctx context.Context, server, player string, out any, cmd ...string) error {
+	body, err := json.Marshal(map[string]any{
+		"id":     1,
+		"method": "slim.request",
+		"params": []any{player, cmd},
+	})
+	if err != nil {
+		return err
+	}
+	url := fmt.Sprintf("http://%s/jsonrpc.js", lmsHostPort(server))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if err != nil {
+		return fmt.Errorf("lms: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("lms: %s: %s", strings.Join(cmd, " "), resp.Status)
+	}
+	var envelope struct {
+		Result json.RawMessage `json:"result"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return fmt.Errorf("lms: %s: %w", strings.Join(cmd, " "), err)
+	}
+	if out == nil || len(envelope.Result) == 0 {
+		return nil
+	}
+	return json.Unmarshal(envelope.Result, out)
+}
+
+// lmsID is an LMS object ID, which the server sends as a number or a string.
+type lmsID string
+
+func (id *lmsID) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*id = ""
+		return nil
+	}
+	*id = lmsID(strings.Trim(string(data), `"`))
+	return nil
+}
+
+func (id lmsID) String() string { return string(id) }
+
+// lmsHostPort adds the default LMS web port to server if it has none.
+func lmsHostPort(server string) string {
+	if strings.Contains(server, ":") {
+		return server
+	}
+	return server + ":9000"
+}
+
+// lmsServerAddr returns the configured or discovered LMS server.
+func (s *LMSStream) lmsServerAddr() (string, error) {
+	s.srvMu.Lock()
+	defer s.srvMu.Unlock()
+	if s.lmsServer != "" {
+		return s.lmsServer, nil
+	}
+	if s.server != "" {
+		return s.server, nil
+	}
+	return "", ErrNotActive
+}
+
+// Browse navigates the LMS library: artists, albums, genres, playlists
+// and favorites. Item IDs are "<menu>/<LMS id>".
+func (s *LMSStream) Browse(ctx context.Context, path string) ([]models.BrowsableItem, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return append([]models.BrowsableItem(nil), lmsMenus...), nil
+	}
+	server, err := s.lmsServerAddr()
+	if err != nil {
+		return nil, err
+	}
+	player := lmsMACAddress(s.name)
+	menu, id, _ := strings.Cut(path, "/")
+	page := []string{"0", fmt.Sprint(lmsBrowseLimit)}
+
+	switch {
+	case menu == "artists" && id == "":
+		var res struct {
+			Loop []struct {
+				ID     lmsID  `json:"id"`
+				Artist string `json:"artist"`
+			} `json:"artists_loop"`
+		}
+		if err := lmsRequest(ctx, server, player, &res, append([]string{"artists"}, page...)...); err != nil {
+			return nil, err
+		}
+		items := []models.BrowsableItem{}
+		for _, a := range res.Loop {
+			items = append(items, models.BrowsableItem{ID: "artists/" + a.ID.String(), Name: a.Artist, Type: "folder"})
+		}
+		return items, nil
+
+	case menu == "albums" && id == "", menu == "artists", menu == "genres" && id != "":
+		cmd := append([]string{"albums"}, page...)
+		cmd = append(cmd, "tags:aj")
+		switch menu {
+		case "artists":
+			cmd = append(cmd, "artist_id:"+id)
+		case "genres":
+			cmd = append(cmd, "genre_id:"+id)
+		}
+		var res struct {
+			Loop []struct {
+				ID      lmsID  `json:"id"`
+				Album   string `json:"album"`
+				Artwork lmsID  `json:"artwork_track_id"`
+			} `json:"albums_loop"`
+		}
+		if err := lmsRequest(ctx, server, player, &res, cmd...); err != nil {
+			return nil, err
+		}
+		items := []models.BrowsableItem{}
+		for _, a := range res.Loop {
+			item := models.BrowsableItem{ID: "albums/" + a.ID.String(), Name: a.Album, Type: "album"}
+			if a.Artwork != "" {
+				item.Thumbnail = fmt.Sprintf("http://%s/music/%s/cover.jpg", lmsHostPort(server), a.Artwork.String())
+			}
+			items = append(items, item)
+		}
+		return items, nil
+
+	case menu == "albums", menu == "playlists" && id != "":
+		cmd := append([]string{"titles"}, page...)
+		cmd = append(cmd, "album_id:"+id, "sort:tracknum")
+		loop := "titles_loop"
+		if menu == "playlists" {
+			cmd = append([]string{"playlists", "tracks"}, page...)
+			cmd = append(cmd, "playlist_id:"+id)
+			loop = "playlisttracks_loop"
+		}
+		var res map[string]json.RawMessage
+		if err := lmsRequest(ctx, server, player, &res, cmd...); err != nil {
+			return nil, err
+		}
+		var tracks []struct {
+			ID    lmsID  `json:"id"`
+			Title string `json:"title"`
+		}
+		if raw, ok := res[loop]; ok {
+			if err := json.Unmarshal(raw, &tracks); err != nil {
+				return nil, err
+			}
+		}
+		items := []models.BrowsableItem{}
+		for _, t := range tracks {
+			items = append(items, models.BrowsableItem{ID: "tracks/" + t.ID.String(), Name: t.Title, Type: "track"})
+		}
+		return items, nil
+
+	case menu == "genres":
+		var res struct {
+			Loop []struct {
+				ID    lmsID  `json:"id"`
+				Genre string `json:"genre"`
+			} `json:"genres_loop"`
+		}
+		if err := lmsRequest(ctx, server, player, &res, append([]string{"genres"}, page...)...); err != nil {
+			return nil, err
+		}
+		items := []models.BrowsableItem{}
+		for _, g := range res.Loop {
+			items = append(items, models.BrowsableItem{ID: "genres/" + g.ID.String(), Name: g.Genre, Type: "folder"})
+		}
+		return items, nil
+
+	case menu == "playlists":
+		var res struct {
+			Loop []struct {
+				ID       lmsID  `json:"id"`
+				Playlist string `json:"playlist"`
+			} `json:"playlists_loop"`
+		}
+		if err := lmsRequest(ctx, server, player, &res, append([]string{"playlists"}, page...)...); err != nil {
+			return nil, err
+		}
+		items := []models.BrowsableItem{}
+		for _, p := range res.Loop {
+			items = append(items, models.BrowsableItem{ID: "playlists/" + p.ID.String(), Name: p.Playlist, Type: "playlist"})
+		}
+		return items, nil
+
+	case menu == "favorites":
+		cmd := append([]string{"favorites", "items"}, page...)
+		if id != "" {
+			cmd = append(cmd, "item_id:"+id)
+		}
+		var res struct {
+			Loop []struct {
+				ID       lmsID  `json:"id"`
+				Name     string `json:"name"`
+				HasItems int    `json:"hasitems"`
+				IsAudio  int    `json:"isaudio"`
+			} `json:"loop_loop"`
+		}
+		if err := lmsRequest(ctx, server, player, &res, cmd...); err != nil {
+			return nil, err
+		}
+		items := []models.BrowsableItem{}
+		for _, f := range res.Loop {
+			item := models.BrowsableItem{ID: "favorites/" + f.ID.String(), Name: f.Name, Type: "folder"}
+			if f.HasItems == 0 && f.IsAudio != 0 {
+				item.Type = "station"
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, fs.ErrNotExist
+}
+
+// lmsCommand translates a stream command into an LMS player command.
+func lmsCommand(cmd string) ([]string, error) {
+	if item, ok := strings.CutPrefix(cmd, "play="); ok {
+		menu, id, _ := strings.Cut(strings.Trim(item, "/"), "/")
+		if id == "" {
+			return nil, fmt.Errorf("lms: cannot play %q", item)
+		}
+		switch menu {
+		case "favorites":
+			return []string{"favorites", "playlist", "play", "item_id:" + id}, nil
+		case "artists", "albums", "genres", "playlists", "tracks":
+			key := strings.TrimSuffix(menu, "s") + "_id:" + id
+			return []string{"playlistcontrol", "cmd:load", key}, nil
+		}
+		return nil, fmt.Errorf("lms: cannot play %q", item)
+	}
+	switch cmd {
+	case "play":
+		return []string{"play"}, nil
+	case "pause":
+		return []string{"pause", "1"}, nil
+	case "stop":
+		return []string{"stop"}, nil
+	case "next":
+		return []string{"playlist", "index", "+1"}, nil
+	case "prev":
+		return []string{"playlist", "index", "-1"}, nil
+	}
+	return nil, nil
+}
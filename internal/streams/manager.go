@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -19,6 +20,9 @@ type Manager struct {
 	mu        sync.Mutex
 	streams   map[int]*StreamState // stream model ID → state
 	vsources  *VSRCAllocator
+	rtp       map[int]*RTPSender  // source ID → network output
+	retries   map[int]*retryState // stream model ID → recovery backoff
+	snap      *SnapServer
 	configDir string // ~/.config/amplipi/srcs/
 	onChange  func(streamID int, info models.StreamInfo)
 }
@@ -33,6 +37,9 @@ func NewManager(configDir string, onChange func(int, models.StreamInfo)) *Manage
 	return &Manager{
 		streams:   make(map[int]*StreamState),
 		vsources:  NewVSRCAllocator(),
+		rtp:       make(map[int]*RTPSender),
+		retries:   make(map[int]*retryState),
+		snap:      NewSnapServer(filepath.Join(configDir, "snapserver")),
 		configDir: configDir,
 		onChange:  onChange,
 	}
@@ -62,28 +69,49 @@ func (m *Manager) Sync(ctx context.Context, modelStreams []models.Stream, source
 		desiredIDs[s.ID] = s
 	}
 
-	// Step 1: Remove streams that are no longer in the model
+	// Sources whose channel processing changed need their alsaloop restarted.
+	reprocess := setSourceProcessing(sources)
+
+	// Step 1: Tear down streams that are no longer in the model and
+	// disconnect streams leaving (or being restarted on) their source, so
+	// their vsrcs and outputs are free before anything is connected.
+	var removed, leaving []*StreamState
 	for id, state := range m.streams {
 		if _, desired := desiredIDs[id]; !desired {
-			slog.Info("stream manager: removing stream", "id", id)
-			if state.PhysSrc >= 0 {
-				if err := state.Streamer.Disconnect(ctx); err != nil {
-					slog.Warn("stream manager: disconnect error on removal", "id", id, "err", err)
-				}
-			}
-			if state.Active {
-				if err := state.Streamer.Deactivate(ctx); err != nil {
-					slog.Warn("stream manager: deactivate error on removal", "id", id, "err", err)
-				}
-				if state.VSRC >= 0 {
-					m.vsources.Free(state.VSRC)
-				}
-			}
+			removed = append(removed, state)
 			delete(m.streams, id)
+			delete(m.retries, id)
+			continue
+		}
+		physSrc, connect := streamToPhysSrc[id]
+		if state.PhysSrc >= 0 && (!connect || state.PhysSrc != physSrc || reprocess[physSrc]) {
+			leaving = append(leaving, state)
+		}
+	}
+	eachStream(removed, func(state *StreamState) { m.removeStream(ctx, state) })
+	eachStream(leaving, func(state *StreamState) {
+		_, connect := streamToPhysSrc[state.StreamID]
+		m.disconnectStream(ctx, state, !connect)
+	})
+
+	// Hand existing streams their new name and config; running ones whose
+	// process config changed are restarted once the rest is in place.
+	var reconfigured []*StreamState
+	for id, state := range m.streams {
+		rc, ok := state.Streamer.(reconfigurable)
+		if !ok {
+			continue
+		}
+		state.mu.Lock()
+		restart := rc.reconfigure(desiredIDs[id]) && state.Active
+		state.mu.Unlock()
+		if restart {
+			reconfigured = append(reconfigured, state)
 		}
 	}
 
 	// Step 2: Add new streams from model
+	added := make(map[int]bool)
 	for id, stream := range desiredIDs {
 		if _, exists := m.streams[id]; !exists {
 			slog.Info("stream manager: adding new stream", "id", id, "type", stream.Type, "name", stream.Name)
@@ -92,87 +120,335 @@ func (m *Manager) Sync(ctx context.Context, modelStreams []models.Stream, source
 				slog.Error("stream manager: could not create streamer", "id", id, "type", stream.Type, "err", err)
 				continue
 			}
-			state := &StreamState{
+			if sv, ok := streamer.(supervised); ok {
+				sv.setLogDir(m.streamLogDir(id))
+				if m.onChange != nil {
+					sv.setStatusHook(func() { m.onChange(id, streamer.Info()) })
+				}
+			}
+			if pr, ok := streamer.(pairable); ok {
+				pr.setDataDir(m.streamDataDir(id))
+			}
+			if sp, ok := streamer.(*SpotifyStream); ok && m.onChange != nil {
+				// Report metadata and pairing changes, with the process status
+				sp.onChange = func(models.StreamInfo) { m.onChange(id, streamer.Info()) }
+			}
+			if bt, ok := streamer.(*BluetoothStream); ok && m.onChange != nil {
+				// Report the connected phone and its AVRCP metadata
+				bt.onChange = func(info models.StreamInfo) { m.onChange(id, info) }
+			}
+			if ap, ok := streamer.(*AirPlayStream); ok && m.onChange != nil {
+				// Report the sender's session and track
+				ap.onChange = func(info models.StreamInfo) { m.onChange(id, info) }
+			}
+			if fp, ok := streamer.(*FilePlayerStream); ok && m.onChange != nil {
+				// Report track changes and the end of the queue so the API
+				// and announcements see the playback state.
+				fp.onChange = func(info models.StreamInfo) { m.onChange(id, info) }
+			}
+			if ds, ok := streamer.(*DemoStream); ok && m.onChange != nil {
+				// Report the demo's track changes and playback state
+				ds.onChange = func(info models.StreamInfo) { m.onChange(id, info) }
+			}
+			if ps, ok := streamer.(*PluginStream); ok && m.onChange != nil {
+				// Report the metadata the plugin sends
+				ps.onChange = func(info models.StreamInfo) { m.onChange(id, info) }
+			}
+			m.streams[id] = &StreamState{
 				Streamer: streamer,
 				StreamID: id,
+				Name:     stream.Name,
 				VSRC:     -1,
 				PhysSrc:  -1,
 				Active:   false,
 			}
-			m.streams[id] = state
-
-			// Activate persistent streams immediately
-			if streamer.IsPersistent() {
-				if err := m.activateStream(ctx, state, stream.Name); err != nil {
-					slog.Error("stream manager: failed to activate persistent stream", "id", id, "err", err)
-					// Surface the error to the API so the stream shows a clear state
-					if m.onChange != nil {
-						m.onChange(id, models.StreamInfo{
-							Name:  stream.Name,
-							State: "unavailable",
-							Track: err.Error(),
-						})
-					}
-				}
-			}
+			added[id] = true
 		}
 	}
 
-	// Step 3: Reconcile connections for all streams
+	// Step 3: Activate new persistent streams and connect streams to their
+	// sources. Activation is the slow part (writing configs, starting
+	// processes), so streams are brought up concurrently.
+	var pending []*StreamState
 	for id, state := range m.streams {
-		desiredPhysSrc, shouldConnect := streamToPhysSrc[id]
-
-		if shouldConnect && state.PhysSrc != desiredPhysSrc {
-			// Need to connect (or reconnect to different physSrc)
-			if state.PhysSrc >= 0 {
-				if err := state.Streamer.Disconnect(ctx); err != nil {
-					slog.Warn("stream manager: disconnect error before reconnect", "id", id, "err", err)
+		state.Name = desiredIDs[id].Name
+		physSrc, connect := streamToPhysSrc[id]
+		if (connect && state.PhysSrc != physSrc) || (added[id] && state.Streamer.IsPersistent()) {
+			pending = append(pending, state)
+		}
+	}
+	eachStream(pending, func(state *StreamState) {
+		id := state.StreamID
+		if added[id] && state.Streamer.IsPersistent() {
+			if err := m.activateStream(ctx, state, state.Name); err != nil {
+				slog.Error("stream manager: failed to activate persistent stream", "id", id, "err", err)
+				// Surface the error to the API so the stream shows a clear state
+				if m.onChange != nil {
+					m.onChange(id, models.StreamInfo{
+						Name:  state.Name,
+						State: "unavailable",
+						Track: err.Error(),
+					})
 				}
-				state.PhysSrc = -1
+				return
 			}
-
-			// Activate if not yet active
-			if !state.Active {
-				if err := m.activateStream(ctx, state, desiredIDs[id].Name); err != nil {
-					slog.Error("stream manager: failed to activate stream for connect", "id", id, "err", err)
-					continue
-				}
+		}
+		physSrc, connect := streamToPhysSrc[id]
+		if !connect {
+			return
+		}
+		if !state.Active {
+			if err := m.activateStream(ctx, state, state.Name); err != nil {
+				slog.Error("stream manager: failed to activate stream for connect", "id", id, "err", err)
+				return
 			}
+		}
+		slog.Info("stream manager: connecting stream", "id", id, "physSrc", physSrc)
+		if err := state.Streamer.Connect(ctx, physSrc); err != nil {
+			slog.Warn("stream manager: connect error", "id", id, "physSrc", physSrc, "err", err)
+		} else {
+			state.PhysSrc = physSrc
+		}
+	})
+
+	// Restart running streams whose process config changed, keeping their
+	// source
+	for _, state := range reconfigured {
+		slog.Info("stream manager: restarting reconfigured stream", "id", state.StreamID)
+		delete(m.retries, state.StreamID)
+		if err := m.restartStream(ctx, state); err != nil {
+			slog.Warn("stream manager: restart after reconfigure failed", "id", state.StreamID, "err", err)
+		}
+	}
 
-			slog.Info("stream manager: connecting stream", "id", id, "physSrc", desiredPhysSrc)
-			if err := state.Streamer.Connect(ctx, desiredPhysSrc); err != nil {
-				slog.Warn("stream manager: connect error", "id", id, "physSrc", desiredPhysSrc, "err", err)
-			} else {
-				state.PhysSrc = desiredPhysSrc
-			}
+	// Step 4: Reconcile network outputs (RTP, Snapcast) with the new routing
+	m.syncRTP(ctx, sources)
+	m.syncSnapcast(ctx, sources)
+
+	return nil
+}
+
+// eachStream runs fn for every state concurrently and waits for all of
+// them. fn runs with the stream's lock held, so calls into one Streamer
+// never overlap; the vsrc allocator has its own lock.
+func eachStream(states []*StreamState, fn func(*StreamState)) {
+	var wg sync.WaitGroup
+	for _, state := range states {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			state.mu.Lock()
+			defer state.mu.Unlock()
+			fn(state)
+		}()
+	}
+	wg.Wait()
+}
+
+// removeStream tears down a stream deleted from the model, its logs and
+// its data (e.g. pairing credentials).
+func (m *Manager) removeStream(ctx context.Context, state *StreamState) {
+	slog.Info("stream manager: removing stream", "id", state.StreamID)
+	m.teardownStream(ctx, state, "removal")
+	if err := os.RemoveAll(m.streamLogDir(state.StreamID)); err != nil {
+		slog.Warn("stream manager: could not remove stream logs", "id", state.StreamID, "err", err)
+	}
+	if err := os.RemoveAll(m.streamDataDir(state.StreamID)); err != nil {
+		slog.Warn("stream manager: could not remove stream data", "id", state.StreamID, "err", err)
+	}
+}
+
+// streamDataDir returns the directory a stream keeps data in across
+// activations, keyed by stream ID like its logs.
+func (m *Manager) streamDataDir(streamID int) string {
+	return filepath.Join(m.configDir, "data", strconv.Itoa(streamID))
+}
+
+// ResetPairing forgets the credentials a stream obtained by zeroconf
+// pairing and restarts it if it is running, so it can be paired with
+// another account. Returns ErrNotPairable for other stream types.
+func (m *Manager) ResetPairing(ctx context.Context, streamID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.streams[streamID]
+	if !ok {
+		return fmt.Errorf("stream %d not found", streamID)
+	}
+	pr, ok := state.Streamer.(pairable)
+	if !ok {
+		return ErrNotPairable
+	}
+	state.mu.Lock()
+	err := pr.resetPairing()
+	active := state.Active
+	state.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	slog.Info("stream manager: pairing reset", "id", streamID)
+	if !active {
+		return nil
+	}
+	delete(m.retries, streamID)
+	return m.restartStream(ctx, state)
+}
 
-		} else if !shouldConnect && state.PhysSrc >= 0 {
-			// Need to disconnect
-			slog.Info("stream manager: disconnecting stream", "id", id)
-			if err := state.Streamer.Disconnect(ctx); err != nil {
-				slog.Warn("stream manager: disconnect error", "id", id, "err", err)
+// teardownStream disconnects and deactivates a stream, freeing its vsrc.
+// Must be called with the stream's lock held.
+func (m *Manager) teardownStream(ctx context.Context, state *StreamState, during string) {
+	if state.PhysSrc >= 0 {
+		if err := state.Streamer.Disconnect(ctx); err != nil {
+			slog.Warn("stream manager: disconnect error", "id", state.StreamID, "during", during, "err", err)
+		}
+		state.PhysSrc = -1
+	}
+	if state.Active {
+		if err := state.Streamer.Deactivate(ctx); err != nil {
+			slog.Warn("stream manager: deactivate error", "id", state.StreamID, "during", during, "err", err)
+		}
+		if state.VSRC >= 0 {
+			m.vsources.Free(state.VSRC)
+			state.VSRC = -1
+		}
+		state.Active = false
+	}
+}
+
+// disconnectStream disconnects a stream from its source. With deactivate,
+// non-persistent streams are also stopped since nothing is listening.
+// Must be called with the stream's lock held.
+func (m *Manager) disconnectStream(ctx context.Context, state *StreamState, deactivate bool) {
+	slog.Info("stream manager: disconnecting stream", "id", state.StreamID)
+	if err := state.Streamer.Disconnect(ctx); err != nil {
+		slog.Warn("stream manager: disconnect error", "id", state.StreamID, "err", err)
+	}
+	state.PhysSrc = -1
+	if deactivate && !state.Streamer.IsPersistent() {
+		m.teardownStream(ctx, state, "disconnect")
+	}
+}
+
+// syncRTP starts, restarts or stops per-source RTP senders so every source
+// with an enabled RTP output multicasts the vsrc of the stream feeding it.
+// Sources fed by streams without a vsrc (RCA, aux) cannot be multicast.
+// Must be called with m.mu held.
+func (m *Manager) syncRTP(ctx context.Context, sources []models.Source) {
+	desired := make(map[int]bool)
+	for _, src := range sources {
+		if src.RTP == nil || !src.RTP.Enabled {
+			continue
+		}
+		vsrc := m.sourceVSRC(src.ID)
+		if vsrc < 0 {
+			continue
+		}
+		desired[src.ID] = true
+		cfg := src.RTP.WithDefaults(src.ID)
+		if sender, ok := m.rtp[src.ID]; ok {
+			if sender.matches(vsrc, cfg) {
+				continue
+			}
+			if err := sender.Stop(); err != nil {
+				slog.Warn("stream manager: rtp stop error", "source", src.ID, "err", err)
 			}
-			state.PhysSrc = -1
+		}
+		sender := NewRTPSender(src.ID, vsrc, cfg)
+		if err := sender.Start(ctx); err != nil {
+			slog.Warn("stream manager: rtp start error", "source", src.ID, "err", err)
+			delete(m.rtp, src.ID)
+			continue
+		}
+		m.rtp[src.ID] = sender
+	}
 
-			// Deactivate non-persistent streams when disconnected
-			if !state.Streamer.IsPersistent() && state.Active {
-				if err := state.Streamer.Deactivate(ctx); err != nil {
-					slog.Warn("stream manager: deactivate error", "id", id, "err", err)
-				}
-				if state.VSRC >= 0 {
-					m.vsources.Free(state.VSRC)
-					state.VSRC = -1
-				}
-				state.Active = false
+	for id, sender := range m.rtp {
+		if desired[id] {
+			continue
+		}
+		if err := sender.Stop(); err != nil {
+			slog.Warn("stream manager: rtp stop error", "source", id, "err", err)
+		}
+		delete(m.rtp, id)
+	}
+}
+
+// syncSnapcast restarts the managed snapserver when the set of sources with
+// Snapcast output enabled, or the vsrcs feeding them, changes.
+// Must be called with m.mu held.
+func (m *Manager) syncSnapcast(ctx context.Context, sources []models.Source) {
+	desired := make(map[int]snapSource)
+	for _, src := range sources {
+		if src.Snapcast == nil || !src.Snapcast.Enabled {
+			continue
+		}
+		if vsrc := m.sourceVSRC(src.ID); vsrc >= 0 {
+			desired[src.ID] = snapSource{vsrc: vsrc, codec: src.Snapcast.Codec}
+		}
+	}
+	if err := m.snap.update(ctx, desired); err != nil {
+		slog.Warn("stream manager: snapserver update error", "err", err)
+	}
+}
+
+// SourceCaptureDevice returns the ALSA capture device carrying source sid's
+// audio, or false if no stream with a vsrc is connected to it.
+func (m *Manager) SourceCaptureDevice(sid int) (string, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	vsrc := m.sourceVSRC(sid)
+	if vsrc < 0 {
+		return "", false
+	}
+	return VirtualCaptureDevice(vsrc), true
+}
+
+// Routes returns each stream as the manager has it: its vsrc, the source
+// it is connected to and the ALSA devices its audio passes through,
+// ordered by stream ID.
+func (m *Manager) Routes() []models.StreamRoute {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	routes := make([]models.StreamRoute, 0, len(m.streams))
+	for id, state := range m.streams {
+		r := models.StreamRoute{
+			ID:     id,
+			Name:   state.Name,
+			Type:   state.Streamer.Type(),
+			Active: state.Active,
+			VSRC:   state.VSRC,
+			Source: state.PhysSrc,
+		}
+		if state.VSRC >= 0 {
+			r.Device = VirtualOutputDevice(state.VSRC)
+			r.Capture = VirtualCaptureDevice(state.VSRC)
+			if state.PhysSrc >= 0 {
+				r.Output = PhysicalOutputDevice(physicalOutputFor(state.PhysSrc))
 			}
 		}
+		routes = append(routes, r)
 	}
+	slices.SortFunc(routes, func(a, b models.StreamRoute) int { return a.ID - b.ID })
+	return routes
+}
 
-	return nil
+// VSRCs returns the number of vsrc slots streams can be given.
+func (m *Manager) VSRCs() int {
+	return m.vsources.Size()
+}
+
+// sourceVSRC returns the vsrc of the stream connected to source sid, or -1
+// if nothing with a vsrc is connected. Must be called with m.mu held.
+func (m *Manager) sourceVSRC(sid int) int {
+	for _, state := range m.streams {
+		if state.PhysSrc == sid && state.VSRC >= 0 {
+			return state.VSRC
+		}
+	}
+	return -1
 }
 
 // activateStream allocates a vsrc (if needed) and calls Activate on the streamer.
-// Must be called with m.mu held.
+// Must be called with the stream's lock held.
 func (m *Manager) activateStream(ctx context.Context, state *StreamState, name string) error {
 	if state.Active {
 		return nil
@@ -228,9 +504,41 @@ func (m *Manager) SendCmd(ctx context.Context, streamID int, cmd string) error {
 	if !ok {
 		return fmt.Errorf("stream %d not found", streamID)
 	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
 	return state.Streamer.SendCmd(ctx, cmd)
 }
 
+// Browse lists the content of a browsable stream at path.
+func (m *Manager) Browse(ctx context.Context, streamID int, path string) ([]models.BrowsableItem, error) {
+	m.mu.Lock()
+	state, ok := m.streams[streamID]
+	m.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("stream %d not found", streamID)
+	}
+	b, ok := state.Streamer.(Browsable)
+	if !ok {
+		return nil, ErrNotBrowsable
+	}
+	return b.Browse(ctx, path)
+}
+
+// Queue returns the play queue of a file player stream.
+func (m *Manager) Queue(streamID int) (models.StreamQueue, error) {
+	m.mu.Lock()
+	state, ok := m.streams[streamID]
+	m.mu.Unlock()
+	if !ok {
+		return models.StreamQueue{}, fmt.Errorf("stream %d not found", streamID)
+	}
+	fp, ok := state.Streamer.(*FilePlayerStream)
+	if !ok {
+		return models.StreamQueue{}, ErrNoQueue
+	}
+	return fp.Queue(), nil
+}
+
 // Info returns the current StreamInfo for a stream, or nil if not found.
 func (m *Manager) Info(streamID int) *models.StreamInfo {
 	m.mu.Lock()
@@ -243,33 +551,114 @@ func (m *Manager) Info(streamID int) *models.StreamInfo {
 	return &info
 }
 
+// ReportStatus calls onChange with the current info of every stream that
+// runs a supervised process, so status changes that happened before the
+// callback's receiver was ready are not lost.
+func (m *Manager) ReportStatus() {
+	if m.onChange == nil {
+		return
+	}
+	m.mu.Lock()
+	infos := make(map[int]models.StreamInfo)
+	for id, state := range m.streams {
+		if info := state.Streamer.Info(); info.Supervisor != nil {
+			infos[id] = info
+		}
+	}
+	m.mu.Unlock()
+	for id, info := range infos {
+		m.onChange(id, info)
+	}
+}
+
+// ReconnectOutputs makes available the physical outputs present, unless
+// it is nil, and reconnects the connected streams affected by the change so
+// their alsaloop re-resolves the output: those whose source now plays to a
+// different output and those playing to an output in changed. Called after
+// a USB DAC is plugged in or removed, or after the output layout is edited.
+func (m *Manager) ReconnectOutputs(ctx context.Context, available, changed []int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	before := make(map[int]int)
+	for _, state := range m.streams {
+		if state.PhysSrc >= 0 {
+			before[state.PhysSrc] = physicalOutputFor(state.PhysSrc)
+		}
+	}
+	if available != nil {
+		SetAvailablePhysicalOutputs(available)
+	}
+	m.reconnect(ctx, func(physSrc int) bool {
+		out := physicalOutputFor(physSrc)
+		return out != before[physSrc] || slices.Contains(changed, out)
+	})
+}
+
+// DuckSource turns the music on source sid down to gain (0-1), or back up
+// with 1, through the duck stage of the output its alsaloop plays to; the
+// loop keeps running and announcements mixed into the output are not
+// turned down. Sources that share ch0 on units without the USB DAC are
+// ducked together.
+func (m *Manager) DuckSource(ctx context.Context, sid int, gain float64) {
+	if err := setDuckGain(ctx, physicalOutputFor(sid), gain); err != nil {
+		slog.Warn("stream manager: duck failed", "source", sid, "gain", gain, "err", err)
+	}
+}
+
+// reconnect disconnects and reconnects the connected streams whose source
+// matches. Must be called with m.mu held.
+func (m *Manager) reconnect(ctx context.Context, match func(physSrc int) bool) {
+	var connected []*StreamState
+	for _, state := range m.streams {
+		if state.PhysSrc >= 0 && match(state.PhysSrc) {
+			connected = append(connected, state)
+		}
+	}
+	eachStream(connected, func(state *StreamState) {
+		id, physSrc := state.StreamID, state.PhysSrc
+		if err := state.Streamer.Disconnect(ctx); err != nil {
+			slog.Warn("stream manager: disconnect error on reconnect", "id", id, "err", err)
+		}
+		state.PhysSrc = -1
+		if err := state.Streamer.Connect(ctx, physSrc); err != nil {
+			slog.Warn("stream manager: reconnect error", "id", id, "physSrc", physSrc, "err", err)
+			return
+		}
+		state.PhysSrc = physSrc
+	})
+}
+
 // Shutdown deactivates all streams cleanly.
 func (m *Manager) Shutdown(ctx context.Context) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	slog.Info("stream manager: shutting down", "count", len(m.streams))
-	for id, state := range m.streams {
-		if state.PhysSrc >= 0 {
-			if err := state.Streamer.Disconnect(ctx); err != nil {
-				slog.Warn("stream manager: disconnect error on shutdown", "id", id, "err", err)
-			}
-		}
-		if state.Active {
-			if err := state.Streamer.Deactivate(ctx); err != nil {
-				slog.Warn("stream manager: deactivate error on shutdown", "id", id, "err", err)
-			}
-			if state.VSRC >= 0 {
-				m.vsources.Free(state.VSRC)
-			}
+	for id, sender := range m.rtp {
+		if err := sender.Stop(); err != nil {
+			slog.Warn("stream manager: rtp stop error on shutdown", "source", id, "err", err)
 		}
+		delete(m.rtp, id)
+	}
+	if err := m.snap.Stop(); err != nil {
+		slog.Warn("stream manager: snapserver stop error on shutdown", "err", err)
+	}
+	states := make([]*StreamState, 0, len(m.streams))
+	for id, state := range m.streams {
+		states = append(states, state)
 		delete(m.streams, id)
 	}
+	eachStream(states, func(state *StreamState) { m.teardownStream(ctx, state, "shutdown") })
 	return nil
 }
 
 // NewStreamer creates the correct Streamer implementation for a stream model.
+// Types added with RegisterStreamerType are created by their constructor.
 func NewStreamer(stream models.Stream) (Streamer, error) {
+	if audio := DemoAudio(); audio != "" {
+		return NewDemoStream(stream, audio), nil
+	}
 	name := stream.Name
 
 	switch stream.Type {
@@ -286,7 +675,10 @@ func NewStreamer(stream models.Stream) (Streamer, error) {
 		return NewPandoraStream(name, username, password, station, nil), nil
 
 	case "airplay":
-		return NewAirPlayStream(name), nil
+		s := NewAirPlayStream(name)
+		s.deviceName = stream.ConfigString("device_name")
+		s.password = stream.ConfigString("password")
+		return s, nil
 
 	case "spotify_connect", "spotify":
 		return NewSpotifyStream(name, nil), nil
@@ -297,7 +689,10 @@ func NewStreamer(stream models.Stream) (Streamer, error) {
 
 	case "file_player", "fileplayer":
 		path := stream.ConfigString("path")
-		return NewFilePlayerStream(name, path), nil
+		s := NewFilePlayerStream(name, path)
+		s.queue.shuffle = stream.ConfigBool("shuffle")
+		s.queue.repeat = stream.ConfigBool("repeat")
+		return s, nil
 
 	case "dlna":
 		return NewDLNAStream(name), nil
@@ -311,12 +706,20 @@ func NewStreamer(stream models.Stream) (Streamer, error) {
 		return NewFMRadioStream(name, freq), nil
 
 	case "bluetooth":
-		return NewBluetoothStream(name), nil
+		s := NewBluetoothStream(name)
+		s.deviceName = stream.ConfigString("device_name")
+		if adapter := stream.ConfigString("adapter"); adapter != "" {
+			s.adapter = adapter
+		}
+		return s, nil
 
 	case "plexamp":
 		return NewPlexampStream(name), nil
 
 	default:
+		if s, ok, err := registeredStreamer(stream); ok {
+			return s, err
+		}
 		return nil, fmt.Errorf("unknown stream type: %q", stream.Type)
 	}
 }
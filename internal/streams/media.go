@@ -0,0 +1,272 @@
+package streams
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// ErrInvalidMediaPath is returned for browse/queue paths that leave the media directory.
+var ErrInvalidMediaPath = errors.New("path is outside the media directory")
+
+// mediaDir is the root of the local music library the file player browses
+// and resolves relative paths against.
+var mediaDir atomic.Value // string
+
+// SetMediaDir configures the local music library root; "" unsets it.
+func SetMediaDir(dir string) {
+	if dir == "" {
+		mediaDir.Store("")
+		return
+	}
+	mediaDir.Store(filepath.Clean(dir))
+	slog.Info("streams: media directory configured", "path", dir)
+}
+
+// MediaDir returns the local music library root ("" if unset).
+func MediaDir() string {
+	dir, _ := mediaDir.Load().(string)
+	return dir
+}
+
+// audioExts are the file extensions the file player treats as tracks.
+var audioExts = []string{".mp3", ".flac", ".wav", ".ogg", ".oga", ".opus", ".m4a", ".aac", ".wma", ".aiff", ".aif", ".alac"}
+
+func isAudioFile(name string) bool {
+	return slices.Contains(audioExts, strings.ToLower(filepath.Ext(name)))
+}
+
+func isPlaylist(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	return ext == ".m3u" || ext == ".m3u8"
+}
+
+func isURL(path string) bool {
+	return strings.Contains(path, "://")
+}
+
+// isFileURL reports whether a URL names a local file, which would bypass
+// the media directory.
+func isFileURL(path string) bool {
+	return strings.HasPrefix(strings.ToLower(path), "file:")
+}
+
+// mediaPath resolves a path relative to the media directory, refusing
+// anything that would escape it.
+func mediaPath(rel string) (string, error) {
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		if part == ".." {
+			return "", ErrInvalidMediaPath
+		}
+	}
+	return confine(filepath.Join(MediaDir(), rel))
+}
+
+// confine returns abs cleaned if it lies inside the media directory, both
+// as written and with symlinks resolved, and ErrInvalidMediaPath if not.
+// A path that doesn't exist yet is checked as written.
+func confine(abs string) (string, error) {
+	root := MediaDir()
+	if root == "" {
+		return "", errors.New("no media directory configured")
+	}
+	abs = filepath.Clean(abs)
+	if !within(root, abs) {
+		return "", ErrInvalidMediaPath
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if errors.Is(err, os.ErrNotExist) {
+		return abs, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if !within(realRoot, real) {
+		return "", ErrInvalidMediaPath
+	}
+	return abs, nil
+}
+
+// within reports whether path is root or below it.
+func within(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// relMediaPath returns abs relative to the media directory, or abs unchanged
+// if it lies outside it.
+func relMediaPath(abs string) string {
+	root := MediaDir()
+	if root == "" || isURL(abs) {
+		return abs
+	}
+	rel, err := filepath.Rel(root, abs)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return abs
+	}
+	return rel
+}
+
+// browseMedia lists the folders, playlists and tracks in a media directory
+// folder. Item IDs are paths relative to the media directory.
+func browseMedia(rel string) ([]models.BrowsableItem, error) {
+	dir, err := mediaPath(rel)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	items := []models.BrowsableItem{}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		item := models.BrowsableItem{ID: filepath.Join(strings.Trim(rel, "/"), name), Name: name}
+		switch {
+		case e.IsDir():
+			item.Type = "folder"
+		case isPlaylist(name):
+			item.Type = "playlist"
+		case isAudioFile(name):
+			item.Type = "track"
+		default:
+			continue
+		}
+		items = append(items, item)
+	}
+	// Folders first, then by name
+	slices.SortStableFunc(items, func(a, b models.BrowsableItem) int {
+		if (a.Type == "folder") != (b.Type == "folder") {
+			if a.Type == "folder" {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	return items, nil
+}
+
+// resolveTracks expands a file player path into playable tracks. path may
+// be a URL, an absolute path, or a path relative to the media directory,
+// and may name a single file, an m3u playlist, or a directory (all audio
+// files beneath it, sorted). Files must be inside the media directory once
+// symlinks are resolved; tracks escaping it are skipped.
+func resolveTracks(path string) ([]string, error) {
+	if path == "" {
+		return nil, errors.New("path is required")
+	}
+	if isFileURL(path) {
+		return nil, ErrInvalidMediaPath
+	}
+	if isURL(path) {
+		return []string{path}, nil
+	}
+	var abs string
+	var err error
+	if filepath.IsAbs(path) {
+		abs, err = confine(path)
+	} else {
+		abs, err = mediaPath(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case info.IsDir():
+		var tracks []string
+		err := filepath.WalkDir(abs, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() && p != abs && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			if d.IsDir() || !isAudioFile(d.Name()) {
+				return nil
+			}
+			if d.Type()&os.ModeSymlink != 0 {
+				if _, err := confine(p); err != nil {
+					slog.Warn("file_player: skipping track outside the media directory", "path", p)
+					return nil
+				}
+			}
+			tracks = append(tracks, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if len(tracks) == 0 {
+			return nil, fmt.Errorf("no audio files in %s", path)
+		}
+		return tracks, nil
+	case isPlaylist(abs):
+		return readPlaylist(abs)
+	default:
+		return []string{abs}, nil
+	}
+}
+
+// readPlaylist reads an m3u/m3u8 playlist. Relative entries are resolved
+// against the playlist's directory; entries outside the media directory
+// are skipped.
+func readPlaylist(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tracks []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = filepath.FromSlash(strings.ReplaceAll(line, `\`, "/"))
+		if !isURL(line) && !filepath.IsAbs(line) {
+			line = filepath.Join(filepath.Dir(path), line)
+		}
+		if isFileURL(line) {
+			slog.Warn("file_player: skipping playlist entry outside the media directory", "playlist", path, "entry", line)
+			continue
+		}
+		if !isURL(line) {
+			abs, err := confine(line)
+			if err != nil {
+				slog.Warn("file_player: skipping playlist entry outside the media directory", "playlist", path, "entry", line)
+				continue
+			}
+			line = abs
+		}
+		tracks = append(tracks, line)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, fmt.Errorf("playlist %s is empty", filepath.Base(path))
+	}
+	return tracks, nil
+}
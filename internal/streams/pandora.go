@@ -2,11 +2,14 @@ package streams
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -19,12 +22,20 @@ import (
 // current song metadata to the currentSong file.
 const eventcmdContent = `#!/bin/bash
 # Minimal pianobar event handler for AmpliPi
-SONGFILE="$(dirname "$0")/currentSong"
+# pianobar passes the event details on stdin as key=value lines.
+DIR="$(dirname "$0")"
+while IFS='=' read -r key value; do
+    [[ "$key" =~ ^[A-Za-z0-9]+$ ]] && printf -v "$key" '%s' "$value"
+done
 case "$1" in
     songstart)
-        echo "${title},,,${artist},,,${album},,,${coverArt},,,${rating},,,${stationName}" > "$SONGFILE"
+        echo "${title},,,${artist},,,${album},,,${coverArt},,,${rating},,,${stationName}" > "$DIR/currentSong"
         ;;
     usergetstations)
+        for ((i = 0; i < stationCount; i++)); do
+            v="station$i"
+            echo "${!v}"
+        done > "$DIR/stations.tmp" && mv "$DIR/stations.tmp" "$DIR/stations"
         ;;
 esac
 exit 0
@@ -49,6 +60,7 @@ type PandoraStream struct {
 
 	fifoPath        string
 	currentSongPath string
+	stationsPath    string // station list written by eventcmd, in pianobar's order
 
 	monCancel context.CancelFunc
 	monWg     sync.WaitGroup
@@ -80,6 +92,7 @@ func (s *PandoraStream) Activate(ctx context.Context, vsrc int, configDir string
 	eventcmdPath := filepath.Join(pianobarDir, "eventcmd.sh")
 	fifoPath := filepath.Join(pianobarDir, "ctl")
 	currentSongPath := filepath.Join(pianobarDir, "currentSong")
+	stationsPath := filepath.Join(pianobarDir, "stations")
 	audioDevice := VirtualOutputDevice(vsrc)
 
 	// Write eventcmd.sh
@@ -117,6 +130,7 @@ func (s *PandoraStream) Activate(ctx context.Context, vsrc int, configDir string
 
 	s.fifoPath = fifoPath
 	s.currentSongPath = currentSongPath
+	s.stationsPath = stationsPath
 
 	// Start supervisor for pianobar
 	// Pianobar uses HOME to find its config; we set HOME to configDir's parent
@@ -197,8 +211,8 @@ func (s *PandoraStream) SendCmd(_ context.Context, cmd string) error {
 		fifoCmd = "-\n"
 	case cmd == "shelve":
 		fifoCmd = "t\n"
-	case strings.HasPrefix(cmd, "station="):
-		id := strings.TrimPrefix(cmd, "station=")
+	case strings.HasPrefix(cmd, "station="), strings.HasPrefix(cmd, "play="):
+		_, id, _ := strings.Cut(cmd, "=")
 		fifoCmd = "s\n" + id + "\n"
 	default:
 		slog.Debug("pandora: unknown command", "cmd", cmd)
@@ -207,6 +221,32 @@ func (s *PandoraStream) SendCmd(_ context.Context, cmd string) error {
 	return s.writeToFIFO(fifoCmd)
 }
 
+// Browse lists the user's stations. Item IDs are pianobar station numbers,
+// played with the station=<id> (or play=<id>) command.
+func (s *PandoraStream) Browse(_ context.Context, path string) ([]models.BrowsableItem, error) {
+	if path != "" {
+		return nil, fs.ErrNotExist
+	}
+	if s.stationsPath == "" {
+		return nil, ErrNotActive
+	}
+	items := []models.BrowsableItem{}
+	data, err := os.ReadFile(s.stationsPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return items, nil // not logged in yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range strings.Split(strings.TrimRight(string(data), "\n"), "\n") {
+		if name == "" {
+			continue
+		}
+		items = append(items, models.BrowsableItem{ID: strconv.Itoa(i), Name: name, Type: "station"})
+	}
+	return items, nil
+}
+
 func (s *PandoraStream) Info() models.StreamInfo {
 	return s.getInfo()
 }
@@ -0,0 +1,217 @@
+package streams
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+)
+
+// playQueue is the file player's track list and play position. With shuffle
+// on, tracks are played in a random order; the list itself keeps the order
+// they were added in. Not safe for concurrent use.
+type playQueue struct {
+	tracks  []string
+	order   []int // play order: indexes into tracks
+	pos     int   // index into order of the current track; len(order) = finished
+	shuffle bool
+	repeat  bool
+}
+
+// current returns the track to play, or false if the queue is empty or finished.
+func (q *playQueue) current() (string, bool) {
+	if q.pos < 0 || q.pos >= len(q.order) {
+		return "", false
+	}
+	return q.tracks[q.order[q.pos]], true
+}
+
+// currentIndex returns the index in tracks of the current track, or -1.
+func (q *playQueue) currentIndex() int {
+	if q.pos < 0 || q.pos >= len(q.order) {
+		return -1
+	}
+	return q.order[q.pos]
+}
+
+// reorder rebuilds the play order, keeping track cur (-1 for none) current.
+func (q *playQueue) reorder(cur int) {
+	q.order = make([]int, len(q.tracks))
+	for i := range q.order {
+		q.order[i] = i
+	}
+	if q.shuffle {
+		rand.Shuffle(len(q.order), func(i, j int) { q.order[i], q.order[j] = q.order[j], q.order[i] })
+		if cur >= 0 {
+			i := slices.Index(q.order, cur)
+			q.order[0], q.order[i] = q.order[i], q.order[0]
+		}
+		q.pos = 0
+		return
+	}
+	if cur >= 0 {
+		q.pos = cur
+	} else {
+		q.pos = min(q.pos, len(q.order))
+	}
+}
+
+// set replaces the queue and starts from its first track.
+func (q *playQueue) set(tracks []string) {
+	q.tracks = slices.Clone(tracks)
+	q.pos = 0
+	q.reorder(-1)
+}
+
+// add appends tracks without changing what is playing.
+func (q *playQueue) add(tracks ...string) {
+	cur := q.currentIndex()
+	finished := q.pos >= len(q.order)
+	q.tracks = append(q.tracks, tracks...)
+	if q.shuffle {
+		// New tracks join the not-yet-played part of the order.
+		added := make([]int, len(tracks))
+		for i := range added {
+			added[i] = len(q.tracks) - len(tracks) + i
+		}
+		rand.Shuffle(len(added), func(i, j int) { added[i], added[j] = added[j], added[i] })
+		q.order = append(q.order, added...)
+		return
+	}
+	q.reorder(cur)
+	if finished {
+		// Continue with the first added track.
+		q.pos = len(q.tracks) - len(tracks)
+	}
+}
+
+// next advances to the next track. It returns false when the end of the
+// queue is reached and repeat is off.
+func (q *playQueue) next() bool {
+	if len(q.order) == 0 {
+		return false
+	}
+	q.pos++
+	if q.pos < len(q.order) {
+		return true
+	}
+	if !q.repeat {
+		q.pos = len(q.order)
+		return false
+	}
+	if q.shuffle {
+		q.reorder(-1)
+	}
+	q.pos = 0
+	return true
+}
+
+// prev steps back one track, wrapping to the end when repeat is on.
+func (q *playQueue) prev() {
+	switch {
+	case q.pos > 0:
+		q.pos--
+	case q.repeat && len(q.order) > 0:
+		q.pos = len(q.order) - 1
+	}
+}
+
+// restart rewinds a finished queue to its first track.
+func (q *playQueue) restart() {
+	if q.pos >= len(q.order) {
+		q.pos = 0
+	}
+}
+
+// jump makes track i current.
+func (q *playQueue) jump(i int) error {
+	if i < 0 || i >= len(q.tracks) {
+		return fmt.Errorf("queue index %d out of range", i)
+	}
+	q.pos = slices.Index(q.order, i)
+	return nil
+}
+
+// remove deletes track i. It reports whether i was the current track.
+func (q *playQueue) remove(i int) (bool, error) {
+	if i < 0 || i >= len(q.tracks) {
+		return false, fmt.Errorf("queue index %d out of range", i)
+	}
+	cur := q.currentIndex()
+	q.tracks = slices.Delete(q.tracks, i, i+1)
+	if cur == i {
+		// The track after it becomes current.
+		p := q.pos
+		q.order = slices.DeleteFunc(q.order, func(t int) bool { return t == i })
+		for k := range q.order {
+			if q.order[k] > i {
+				q.order[k]--
+			}
+		}
+		q.pos = min(p, len(q.order))
+		return true, nil
+	}
+	if cur > i {
+		cur--
+	}
+	if q.shuffle {
+		q.order = slices.DeleteFunc(q.order, func(t int) bool { return t == i })
+		for k := range q.order {
+			if q.order[k] > i {
+				q.order[k]--
+			}
+		}
+		if cur >= 0 {
+			q.pos = slices.Index(q.order, cur)
+		}
+		return false, nil
+	}
+	q.reorder(cur)
+	return false, nil
+}
+
+// move moves track from to position to.
+func (q *playQueue) move(from, to int) error {
+	if from < 0 || from >= len(q.tracks) || to < 0 || to >= len(q.tracks) {
+		return fmt.Errorf("queue index out of range")
+	}
+	cur := q.currentIndex()
+	t := q.tracks[from]
+	q.tracks = slices.Insert(slices.Delete(q.tracks, from, from+1), to, t)
+	// Map old track indexes to new ones.
+	remap := func(i int) int {
+		switch {
+		case i == from:
+			return to
+		case from < to && i > from && i <= to:
+			return i - 1
+		case from > to && i >= to && i < from:
+			return i + 1
+		}
+		return i
+	}
+	if q.shuffle {
+		for k := range q.order {
+			q.order[k] = remap(q.order[k])
+		}
+		return nil
+	}
+	if cur >= 0 {
+		cur = remap(cur)
+	}
+	q.reorder(cur)
+	return nil
+}
+
+// setShuffle switches shuffle on or off, keeping the current track.
+func (q *playQueue) setShuffle(on bool) {
+	if q.shuffle == on {
+		return
+	}
+	cur := q.currentIndex()
+	finished := len(q.order) > 0 && q.pos >= len(q.order)
+	q.shuffle = on
+	q.reorder(cur)
+	if finished {
+		q.pos = len(q.order)
+	}
+}
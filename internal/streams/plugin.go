@@ -0,0 +1,418 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// External stream plugins wrap a player not built into AmpliPi as a
+// stream. A plugin is a manifest, <name>.json in the plugin directory,
+// naming an executable that the Manager runs and supervises like any other
+// stream process while the stream is active.
+//
+// The executable speaks JSON over stdio, one message per line. AmpliPi
+// sends requests {"id":N,"method":M,"params":{...}} on its stdin:
+//
+//	activate   {"name", "vsrc", "device", "config_dir", "config"}: start
+//	           playing into the ALSA device; sent first to every process
+//	connect    {"source"}: the stream now plays on that source
+//	disconnect {}: the stream was taken off its source
+//	cmd        {"cmd"}: a control command, e.g. "play" or "next"
+//	deactivate {}: the stream is being stopped; the process is signalled
+//	           after the reply
+//
+// and the plugin answers each on stdout with {"id":N}, or {"id":N,"error":
+// "..."} if it failed. At any time the plugin may send {"method":"info",
+// "params":{...}} with the stream's metadata, in the fields of GET
+// /api/streams (state, artist, track, album, image_url, ...). Stderr goes
+// to the stream's process log.
+
+// pluginTimeout is how long a plugin has to answer a request.
+const pluginTimeout = 5 * time.Second
+
+// pluginMaxLine is the longest message read from a plugin; longer lines
+// are skipped.
+const pluginMaxLine = 1 << 20
+
+// pluginReplyID finds the request ID at the start of a line that is not a
+// valid message, so only that request fails.
+var pluginReplyID = regexp.MustCompile(`^\s*\{\s*"id"\s*:\s*(\d+)`)
+
+// PluginManifest describes an external stream plugin.
+type PluginManifest struct {
+	Type       string               `json:"type"`              // stream type, e.g. "roon"
+	Aliases    []string             `json:"aliases,omitempty"` // other accepted type names
+	Name       string               `json:"name"`              // shown in the app, e.g. "Roon Bridge"
+	Exec       string               `json:"exec"`              // executable, relative to the manifest's directory
+	Args       []string             `json:"args,omitempty"`
+	Persistent bool                 `json:"persistent"` // keep running while not on a source, e.g. to stay discoverable
+	Fields     []models.ConfigField `json:"fields,omitempty"`
+}
+
+// LoadPlugins registers the stream plugins whose manifests are in dir and
+// returns their types. A missing dir has none. Bad manifests are skipped
+// and reported in the error; the others are still registered.
+func LoadPlugins(dir string) ([]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var types []string
+	var errs []error
+	for _, path := range paths {
+		m, err := readPluginManifest(path)
+		if err == nil {
+			err = RegisterStreamerType(StreamerType{
+				Schema:   models.StreamSchema{Type: m.Type, Aliases: m.Aliases, Name: m.Name, Fields: m.Fields},
+				New:      func(stream models.Stream) (Streamer, error) { return NewPluginStream(m, stream), nil },
+				Binaries: []string{m.Exec},
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.Base(path), err))
+			continue
+		}
+		types = append(types, m.Type)
+	}
+	return types, errors.Join(errs...)
+}
+
+func readPluginManifest(path string) (*PluginManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m PluginManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	if m.Type == "" || m.Exec == "" {
+		return nil, errors.New("manifest needs a type and an exec")
+	}
+	if m.Name == "" {
+		m.Name = m.Type
+	}
+	if !filepath.IsAbs(m.Exec) {
+		m.Exec = filepath.Join(filepath.Dir(path), m.Exec)
+	}
+	return &m, nil
+}
+
+// pluginRequest is a request to a plugin.
+type pluginRequest struct {
+	ID     int         `json:"id"`
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+}
+
+// pluginMessage is a line from a plugin: a reply to a request, or an info
+// notification.
+type pluginMessage struct {
+	ID     int             `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Error  string          `json:"error"`
+}
+
+// PluginStream is a stream run by an external plugin process.
+type PluginStream struct {
+	SubprocStream
+	manifest *PluginManifest
+	name     string
+	config   map[string]interface{}
+	onChange func(models.StreamInfo)
+
+	pmu      sync.Mutex
+	stdin    io.WriteCloser // of the running process; nil while none is
+	readDone chan struct{}  // closed when the running process's output is read
+	nextID   int
+	pending  map[int]chan error // reply by request ID
+	physSrc  int                // -1 while not connected
+}
+
+// NewPluginStream creates a stream of a plugin's type.
+func NewPluginStream(m *PluginManifest, stream models.Stream) *PluginStream {
+	return &PluginStream{
+		manifest: m,
+		name:     stream.Name,
+		config:   stream.Config,
+		pending:  make(map[int]chan error),
+		physSrc:  -1,
+	}
+}
+
+// Activate starts the plugin process and tells it where to play.
+func (s *PluginStream) Activate(ctx context.Context, vsrc int, configDir string) error {
+	slog.Info("plugin: activating", "name", s.name, "type", s.manifest.Type)
+	dir, err := buildConfigDir(configDir, vsrc)
+	if err != nil {
+		return fmt.Errorf("plugin activate: %w", err)
+	}
+	activate := map[string]interface{}{
+		"name":       s.name,
+		"vsrc":       vsrc,
+		"device":     VirtualOutputDevice(vsrc),
+		"config_dir": dir,
+		"config":     s.config,
+	}
+	s.setInfo(models.StreamInfo{Name: s.name, State: "stopped"})
+
+	s.sup = NewSupervisor("plugin/"+s.name, func() *exec.Cmd {
+		cmd := exec.Command(s.manifest.Exec, s.manifest.Args...)
+		cmd.Dir = dir
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			slog.Error("plugin: stdin pipe", "name", s.name, "err", err)
+			return nil
+		}
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			slog.Error("plugin: stdout pipe", "name", s.name, "err", err)
+			return nil
+		}
+		// Every process, restarts included, is told to activate and where
+		// it is connected; the requests wait in the pipe until it reads them.
+		done := make(chan struct{})
+		s.pmu.Lock()
+		s.stdin, s.readDone = stdin, done
+		s.notifyLocked("activate", activate)
+		if s.physSrc >= 0 {
+			s.notifyLocked("connect", map[string]int{"source": s.physSrc})
+		}
+		s.pmu.Unlock()
+		go func() {
+			defer close(done)
+			s.readMessages(stdout, stdin)
+		}()
+		return cmd
+	})
+	// The process's stdout must be read to the end before it is waited
+	// for, which closes the pipe.
+	s.sup.setWaitHook(func() {
+		s.pmu.Lock()
+		done := s.readDone
+		s.pmu.Unlock()
+		<-done
+	})
+	return s.activateBase(ctx, vsrc, dir)
+}
+
+// Deactivate asks the plugin to stop, then stops its process.
+func (s *PluginStream) Deactivate(ctx context.Context) error {
+	slog.Info("plugin: deactivating", "name", s.name)
+	if err := s.call(ctx, "deactivate", struct{}{}); err != nil && !errors.Is(err, ErrNotActive) {
+		slog.Warn("plugin: deactivate", "name", s.name, "err", err)
+	}
+	err := s.deactivateBase(ctx)
+	s.pmu.Lock()
+	s.stdin = nil
+	s.pmu.Unlock()
+	return err
+}
+
+func (s *PluginStream) Connect(ctx context.Context, physSrc int) error {
+	if err := s.connectBase(ctx, physSrc); err != nil {
+		return err
+	}
+	s.pmu.Lock()
+	s.physSrc = physSrc
+	s.pmu.Unlock()
+	return ignoreInactive(s.call(ctx, "connect", map[string]int{"source": physSrc}))
+}
+
+func (s *PluginStream) Disconnect(ctx context.Context) error {
+	s.pmu.Lock()
+	s.physSrc = -1
+	s.pmu.Unlock()
+	if err := ignoreInactive(s.call(ctx, "disconnect", struct{}{})); err != nil {
+		slog.Warn("plugin: disconnect", "name", s.name, "err", err)
+	}
+	return s.disconnectBase(ctx)
+}
+
+// SendCmd passes the command to the plugin and returns its error, if any.
+func (s *PluginStream) SendCmd(ctx context.Context, cmd string) error {
+	return s.call(ctx, "cmd", map[string]string{"cmd": cmd})
+}
+
+func (s *PluginStream) Info() models.StreamInfo { return s.getInfo() }
+func (s *PluginStream) IsPersistent() bool      { return s.manifest.Persistent }
+func (s *PluginStream) Type() string            { return s.manifest.Type }
+
+// call sends a request and waits for the plugin's reply.
+func (s *PluginStream) call(ctx context.Context, method string, params interface{}) error {
+	s.pmu.Lock()
+	if s.stdin == nil {
+		s.pmu.Unlock()
+		return ErrNotActive
+	}
+	id, reply := s.sendLocked(method, params)
+	s.pmu.Unlock()
+	defer func() {
+		s.pmu.Lock()
+		delete(s.pending, id)
+		s.pmu.Unlock()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, pluginTimeout)
+	defer cancel()
+	select {
+	case err := <-reply:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("plugin %s: no reply to %s", s.manifest.Type, method)
+	}
+}
+
+// sendLocked writes a request and returns its ID and the channel its reply
+// is delivered on. A failed write is delivered as the reply. Must be
+// called with pmu held.
+func (s *PluginStream) sendLocked(method string, params interface{}) (int, chan error) {
+	s.nextID++
+	id := s.nextID
+	reply := make(chan error, 1)
+	s.pending[id] = reply
+	line, err := json.Marshal(pluginRequest{ID: id, Method: method, Params: params})
+	if err == nil {
+		_, err = s.stdin.Write(append(line, '\n'))
+	}
+	if err != nil {
+		reply <- fmt.Errorf("plugin %s: %s: %w", s.manifest.Type, method, err)
+	}
+	return id, reply
+}
+
+// notifyLocked sends a request nobody waits for; its failure is logged.
+// Must be called with pmu held.
+func (s *PluginStream) notifyLocked(method string, params interface{}) {
+	id, reply := s.sendLocked(method, params)
+	delete(s.pending, id)
+	select {
+	case err := <-reply:
+		slog.Warn("plugin: request failed", "name", s.name, "method", method, "err", err)
+	default:
+	}
+}
+
+// readMessages handles a plugin process's output until it exits: replies
+// go to their callers, info updates the stream's metadata. A line that is
+// not a valid message fails only the request it answers, if any.
+func (s *PluginStream) readMessages(stdout io.Reader, stdin io.WriteCloser) {
+	r := bufio.NewReader(stdout)
+	for {
+		line, long, err := readPluginLine(r)
+		if len(line) > 0 || long {
+			s.handleLine(line, long)
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	// The process exited: nothing will answer its outstanding requests.
+	s.pmu.Lock()
+	if s.stdin == stdin {
+		s.stdin = nil
+	}
+	for id, reply := range s.pending {
+		reply <- fmt.Errorf("plugin %s exited", s.manifest.Type)
+		delete(s.pending, id)
+	}
+	s.pmu.Unlock()
+}
+
+// readPluginLine reads a line of up to pluginMaxLine bytes. Of a longer
+// line only the start is returned, with long set.
+func readPluginLine(r *bufio.Reader) (line []byte, long bool, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > pluginMaxLine {
+			long = true
+		}
+		if !long || len(line) == 0 {
+			line = append(line, chunk...)
+		}
+		if err != bufio.ErrBufferFull {
+			return bytes.TrimSpace(line), long, err
+		}
+	}
+}
+
+// handleLine handles one line from a plugin; long says it was cut short.
+func (s *PluginStream) handleLine(line []byte, long bool) {
+	var msg pluginMessage
+	err := errors.New("message too long")
+	if !long {
+		err = json.Unmarshal(line, &msg)
+	}
+	if err != nil {
+		slog.Warn("plugin: bad message", "name", s.name, "line", string(line[:min(len(line), 200)]), "err", err)
+		if m := pluginReplyID.FindSubmatch(line); m != nil {
+			if id, convErr := strconv.Atoi(string(m[1])); convErr == nil {
+				s.deliver(id, "bad reply: "+err.Error())
+			}
+		}
+		return
+	}
+	switch {
+	case msg.Method == "info":
+		s.updateInfo(msg.Params)
+	case msg.Method != "":
+		slog.Debug("plugin: unknown message", "name", s.name, "method", msg.Method)
+	default:
+		s.deliver(msg.ID, msg.Error)
+	}
+}
+
+// deliver passes a reply to the request waiting for it. Failures of
+// requests nobody waits for, e.g. activate, are logged.
+func (s *PluginStream) deliver(id int, errMsg string) {
+	s.pmu.Lock()
+	reply, ok := s.pending[id]
+	delete(s.pending, id)
+	s.pmu.Unlock()
+	var err error
+	if errMsg != "" {
+		err = fmt.Errorf("plugin %s: %s", s.manifest.Type, errMsg)
+	}
+	if !ok {
+		if err != nil {
+			slog.Warn("plugin: request failed", "name", s.name, "id", id, "err", err)
+		}
+		return
+	}
+	reply <- err
+}
+
+func (s *PluginStream) updateInfo(params json.RawMessage) {
+	var info models.StreamInfo
+	if err := json.Unmarshal(params, &info); err != nil {
+		slog.Warn("plugin: bad info", "name", s.name, "err", err)
+		return
+	}
+	if info.Name == "" {
+		info.Name = s.name
+	}
+	info.Supervisor = nil
+	s.setInfo(info)
+	if s.onChange != nil {
+		s.onChange(s.getInfo())
+	}
+}
@@ -0,0 +1,117 @@
+package streams
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// probeTimeout bounds the whole of a media check.
+const probeTimeout = 10 * time.Second
+
+// probeClient checks media URLs. Redirects are followed as the players
+// would.
+var probeClient = &http.Client{Timeout: probeTimeout}
+
+// ProbeMedia checks that media, a URL or local file, can be played before
+// an announcement commits to it: the URL must answer and not serve a web
+// page or image, the file must exist, and ffprobe, when installed, must
+// find an audio stream in it. The error says why it can't be played.
+func ProbeMedia(ctx context.Context, media string) error {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	if isURL(media) {
+		if err := probeURL(ctx, media); err != nil {
+			return err
+		}
+	} else {
+		fi, err := os.Stat(media)
+		switch {
+		case os.IsNotExist(err):
+			return fmt.Errorf("%s does not exist", media)
+		case err != nil:
+			return fmt.Errorf("%s: %w", media, err)
+		case !fi.Mode().IsRegular():
+			return fmt.Errorf("%s is not a file", media)
+		}
+	}
+	return ffprobe(ctx, media)
+}
+
+// probeURL checks that an http(s) URL answers with something playable,
+// asking with HEAD, or GET from servers that don't allow HEAD.
+func probeURL(ctx context.Context, media string) error {
+	u, err := url.Parse(media)
+	if err != nil {
+		return fmt.Errorf("invalid URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q (want http or https)", u.Scheme)
+	}
+	resp, err := probeRequest(ctx, http.MethodHead, media)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = probeRequest(ctx, http.MethodGet, media)
+	}
+	if err != nil {
+		return fmt.Errorf("%s is unreachable: %w", u.Host, err)
+	}
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("%s answered %s", media, resp.Status)
+	}
+	if ctype, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type")); err == nil {
+		if strings.HasPrefix(ctype, "text/html") || strings.HasPrefix(ctype, "image/") || ctype == "application/json" {
+			return fmt.Errorf("%s is %s, not audio", media, ctype)
+		}
+	}
+	return nil
+}
+
+// probeRequest sends a request for url and closes the response body
+// unread: only the status and headers are wanted.
+func probeRequest(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := probeClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// ffprobe checks that media has an audio stream ffmpeg can decode. Without
+// ffprobe installed the check is skipped.
+func ffprobe(ctx context.Context, media string) error {
+	bin, err := exec.LookPath(findBinary("ffprobe"))
+	if err != nil {
+		return nil
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, bin, "-v", "error", "-select_streams", "a",
+		"-show_entries", "stream=codec_name", "-of", "csv=p=0", media)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s took too long to probe", media)
+		}
+		msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n")
+		if msg == "" {
+			msg = err.Error()
+		}
+		return fmt.Errorf("%s is not playable: %s", media, msg)
+	}
+	if strings.TrimSpace(stdout.String()) == "" {
+		return fmt.Errorf("%s has no audio stream", media)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package streams
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// procLogMaxSize is the size at which a process log is rotated to <name>.1.
+const procLogMaxSize = 256 << 10
+
+// DefaultLogLines is how many lines Logs returns per process by default.
+const DefaultLogLines = 200
+
+// procLog is an append-only process output file that keeps one rotated
+// generation, so a chatty or crash-looping binary cannot fill the disk.
+// Supervisors point the process's stdout and stderr at it.
+type procLog struct {
+	mu   sync.Mutex
+	path string
+	f    *os.File
+	size int64
+}
+
+// openProcLog opens (or creates) the log file at path for appending.
+func openProcLog(path string) (*procLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &procLog{path: path, f: f, size: info.Size()}, nil
+}
+
+func (l *procLog) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.size+int64(len(p)) > procLogMaxSize && l.size > 0 {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+	return n, err
+}
+
+// Printf writes a timestamped supervisor note, e.g. process start and exit.
+func (l *procLog) Printf(format string, args ...interface{}) {
+	_, _ = fmt.Fprintf(l, "--- %s %s ---\n", time.Now().Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+func (l *procLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// rotate moves the current file to <path>.1 and starts a new one.
+// Must be called with l.mu held.
+func (l *procLog) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(l.path, l.path+".1"); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	l.f, l.size = f, 0
+	return nil
+}
+
+// streamLogDir returns the directory holding a stream's process logs.
+// It is keyed by stream ID rather than vsrc so logs follow the stream
+// across restarts.
+func (m *Manager) streamLogDir(streamID int) string {
+	return filepath.Join(m.configDir, "logs", strconv.Itoa(streamID))
+}
+
+// Logs returns the last lines of output of each process a stream has run,
+// e.g. go-librespot and alsaloop for Spotify. A stream that never started
+// a process has no logs.
+func (m *Manager) Logs(streamID, lines int) ([]models.ProcessLog, error) {
+	if lines <= 0 {
+		lines = DefaultLogLines
+	}
+	dir := m.streamLogDir(streamID)
+	paths, err := filepath.Glob(filepath.Join(dir, "*.log"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	logs := make([]models.ProcessLog, 0, len(paths))
+	for _, p := range paths {
+		tail, err := tailProcLog(p, lines)
+		if err != nil {
+			return nil, err
+		}
+		logs = append(logs, models.ProcessLog{
+			Process: strings.TrimSuffix(filepath.Base(p), ".log"),
+			Lines:   tail,
+		})
+	}
+	return logs, nil
+}
+
+// tailProcLog returns the last n lines of a process log, reaching into the
+// rotated generation when the current file is short.
+func tailProcLog(path string, n int) ([]string, error) {
+	var lines []string
+	for _, p := range []string{path + ".1", path} {
+		f, err := os.Open(p)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		sc := bufio.NewScanner(f)
+		sc.Buffer(make([]byte, 64<<10), procLogMaxSize)
+		for sc.Scan() {
+			lines = append(lines, sc.Text())
+			if len(lines) > 2*n {
+				lines = append(lines[:0], lines[len(lines)-n:]...)
+			}
+		}
+		f.Close()
+		if err := sc.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, nil
+}
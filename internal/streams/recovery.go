@@ -0,0 +1,145 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Retry delays for failed persistent streams: the first retry comes after
+// recoveryMinBackoff, doubling up to recoveryMaxBackoff.
+// A retried stream that has not failed for recoveryHealthy is healthy
+// again and its backoff is reset.
+const (
+	recoveryMinBackoff = time.Minute
+	recoveryMaxBackoff = time.Hour
+	recoveryHealthy    = 10 * time.Minute
+)
+
+// retryState is the recovery backoff of one failed stream.
+type retryState struct {
+	attempts int
+	next     time.Time
+	upSince  time.Time // when it was last seen running after failing; zero while failed
+}
+
+// RunRecovery re-activates failed persistent streams every interval, with
+// exponential backoff per stream, so a supervisor that gave up (e.g. the
+// network was down when go-librespot started) does not leave the stream
+// dead until the daemon restarts. Blocks until ctx is cancelled.
+func (m *Manager) RunRecovery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.recoverFailed(ctx, time.Now())
+		}
+	}
+}
+
+// recoverFailed restarts the failed persistent streams whose retry is due.
+func (m *Manager) recoverFailed(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for id, state := range m.streams {
+		r, ok := m.retries[id]
+		if !streamFailed(state) {
+			// A retry often runs for a while before failing again, so
+			// the backoff is only reset once it has stayed up.
+			if ok && r.upSince.IsZero() {
+				r.upSince = now
+			} else if ok && now.Sub(r.upSince) >= recoveryHealthy {
+				delete(m.retries, id)
+			}
+			continue
+		}
+		if !ok {
+			// First noticed: wait a backoff period before retrying.
+			m.retries[id] = &retryState{next: now.Add(recoveryMinBackoff)}
+			continue
+		}
+		r.upSince = time.Time{}
+		if now.Before(r.next) {
+			continue
+		}
+		r.attempts++
+		r.next = now.Add(recoveryBackoff(r.attempts))
+		slog.Info("stream manager: retrying failed stream", "id", id, "name", state.Name, "attempt", r.attempts)
+		if err := m.restartStream(ctx, state); err != nil {
+			slog.Warn("stream manager: retry failed", "id", id, "err", err)
+		}
+	}
+}
+
+// recoveryBackoff is the delay before retry attempt n+1.
+func recoveryBackoff(attempts int) time.Duration {
+	d := recoveryMinBackoff
+	for i := 0; i < attempts && d < recoveryMaxBackoff; i++ {
+		d *= 2
+	}
+	return minDuration(d, recoveryMaxBackoff)
+}
+
+// streamFailed reports whether a persistent stream failed to activate or
+// its supervisor gave up.
+func streamFailed(state *StreamState) bool {
+	if !state.Streamer.IsPersistent() {
+		return false
+	}
+	if !state.Active {
+		return true
+	}
+	sup := state.Streamer.Info().Supervisor
+	return sup != nil && sup.State == models.SupervisorFailed
+}
+
+// Restart deactivates and re-activates a stream, reconnecting it to its
+// source, and resets its automatic retry backoff. Inactive persistent
+// streams are activated; other inactive streams return ErrNotActive.
+func (m *Manager) Restart(ctx context.Context, streamID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, ok := m.streams[streamID]
+	if !ok {
+		return fmt.Errorf("stream %d not found", streamID)
+	}
+	if !state.Active && !state.Streamer.IsPersistent() {
+		return ErrNotActive
+	}
+	delete(m.retries, streamID)
+	return m.restartStream(ctx, state)
+}
+
+// restartStream cycles a stream through Deactivate and Activate and
+// reports its new info. Must be called with m.mu held; takes the
+// stream's lock.
+func (m *Manager) restartStream(ctx context.Context, state *StreamState) error {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	physSrc := state.PhysSrc
+	m.teardownStream(ctx, state, "restart")
+
+	if err := m.activateStream(ctx, state, state.Name); err != nil {
+		if m.onChange != nil {
+			m.onChange(state.StreamID, models.StreamInfo{Name: state.Name, State: "unavailable", Track: err.Error()})
+		}
+		return err
+	}
+	if m.onChange != nil {
+		m.onChange(state.StreamID, state.Streamer.Info())
+	}
+	if physSrc >= 0 {
+		if err := state.Streamer.Connect(ctx, physSrc); err != nil {
+			return fmt.Errorf("reconnect to source %d: %w", physSrc, err)
+		}
+		state.PhysSrc = physSrc
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package streams
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// StreamerType describes a stream type added without changing
+// NewStreamer, e.g. by a build bundling Roon Bridge or Tidal Connect.
+type StreamerType struct {
+	// Schema names the type and its aliases and describes its config, for
+	// GET /api/streams/types and config validation.
+	Schema models.StreamSchema
+
+	// New creates the Streamer for a stream of this type.
+	New func(stream models.Stream) (Streamer, error)
+
+	// Binaries are searched for in PATH, in order of preference, to tell
+	// whether the type can run on this unit. Without binaries it always can.
+	Binaries []string
+
+	// Detect, if set, replaces the PATH search, e.g. to check for a
+	// device or a running service.
+	Detect func() hardware.StreamCapability
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*StreamerType{} // by type and alias
+)
+
+// RegisterStreamerType adds a stream type. Register from init, before the
+// hardware profile is detected, so the type's capability is part of it. It
+// fails if the type or an alias clashes with a known one.
+func RegisterStreamerType(t StreamerType) error {
+	if t.New == nil {
+		return fmt.Errorf("stream type %q: no constructor", t.Schema.Type)
+	}
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if err := models.RegisterStreamSchema(t.Schema); err != nil {
+		return err
+	}
+	hardware.RegisterStreamType(t.Schema.Type, t.Binaries, t.Detect)
+	for _, typ := range append([]string{t.Schema.Type}, t.Schema.Aliases...) {
+		registry[typ] = &t
+	}
+	return nil
+}
+
+// registeredStreamer creates a Streamer of a registered type, or reports
+// that the type is not registered.
+func registeredStreamer(stream models.Stream) (Streamer, bool, error) {
+	registryMu.RLock()
+	t := registry[stream.Type]
+	registryMu.RUnlock()
+	if t == nil {
+		return nil, false, nil
+	}
+	s, err := t.New(stream)
+	return s, true, err
+}
@@ -0,0 +1,111 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// rtpPayloadType is the dynamic RTP payload type used for L16/L24 audio.
+const rtpPayloadType = 96
+
+// rtpPacketTime is the packet duration; 1 ms is the AES67 default.
+const rtpPacketTime = time.Millisecond
+
+// RTPSender multicasts a vsrc's audio as RTP using GStreamer.
+// It reads the same loopback capture as the source's alsaloop, which
+// requires the dsnoop-backed lb{N}p devices from the generated asound.conf.
+type RTPSender struct {
+	sourceID int
+	vsrc     int
+	cfg      models.RTPOutput
+	sup      *Supervisor
+}
+
+// NewRTPSender creates a sender for source sourceID reading from vsrc.
+// cfg must already have defaults applied.
+func NewRTPSender(sourceID, vsrc int, cfg models.RTPOutput) *RTPSender {
+	r := &RTPSender{sourceID: sourceID, vsrc: vsrc, cfg: cfg}
+	args := rtpPipeline(VirtualCaptureDevice(vsrc), cfg)
+	r.sup = NewSupervisor(fmt.Sprintf("rtp-src%d", sourceID), func() *exec.Cmd {
+		cmd := exec.Command(findBinary("gst-launch-1.0"), args...)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		return cmd
+	})
+	return r
+}
+
+// Start begins the sender's supervisor goroutine.
+func (r *RTPSender) Start(ctx context.Context) error {
+	slog.Info("rtp: starting", "source", r.sourceID, "vsrc", r.vsrc,
+		"dest", net.JoinHostPort(r.cfg.Address, fmt.Sprint(r.cfg.Port)), "format", r.cfg.Format)
+	return r.sup.Start(ctx)
+}
+
+// Stop terminates the sender and waits for the goroutine to exit.
+func (r *RTPSender) Stop() error {
+	slog.Info("rtp: stopping", "source", r.sourceID)
+	return r.sup.Stop()
+}
+
+// matches reports whether the sender already runs the given route and config.
+func (r *RTPSender) matches(vsrc int, cfg models.RTPOutput) bool {
+	return r.vsrc == vsrc && r.cfg == cfg
+}
+
+// rtpPipeline builds the gst-launch-1.0 arguments for an RTP sender.
+func rtpPipeline(capture string, cfg models.RTPOutput) []string {
+	depth, pay := 24, "rtpL24pay"
+	if cfg.Format == "L16" {
+		depth, pay = 16, "rtpL16pay"
+	}
+	ptime := rtpPacketTime.Nanoseconds()
+	return []string{"-q",
+		"alsasrc", "device=" + capture,
+		fmt.Sprintf("buffer-time=%d", cfg.LatencyMS*1000),
+		fmt.Sprintf("latency-time=%d", rtpPacketTime.Microseconds()),
+		"!", "audioconvert",
+		"!", "audioresample",
+		"!", fmt.Sprintf("audio/x-raw,format=S%dBE,rate=%d,channels=2", depth, cfg.SampleRate),
+		"!", pay, fmt.Sprintf("pt=%d", rtpPayloadType),
+		fmt.Sprintf("min-ptime=%d", ptime), fmt.Sprintf("max-ptime=%d", ptime),
+		"!", "udpsink", "host=" + cfg.Address, fmt.Sprintf("port=%d", cfg.Port),
+		fmt.Sprintf("ttl-mc=%d", cfg.TTL), "auto-multicast=true", "sync=false", "async=false",
+	}
+}
+
+// RTPSessionSDP returns an SDP description receivers can use to subscribe
+// to a source's RTP stream. origin is the sender's unicast address.
+func RTPSessionSDP(name, origin string, cfg models.RTPOutput) string {
+	ipVer := "IP4"
+	if ip := net.ParseIP(cfg.Address); ip != nil && ip.To4() == nil {
+		ipVer = "IP6"
+	}
+	originVer := "IP4"
+	if ip := net.ParseIP(origin); ip != nil && ip.To4() == nil {
+		originVer = "IP6"
+	}
+	conn := cfg.Address
+	if ipVer == "IP4" && net.ParseIP(cfg.Address).IsMulticast() {
+		conn = fmt.Sprintf("%s/%d", cfg.Address, cfg.TTL)
+	}
+
+	var b strings.Builder
+	b.WriteString("v=0\r\n")
+	fmt.Fprintf(&b, "o=- %d 0 IN %s %s\r\n", cfg.Port, originVer, origin)
+	fmt.Fprintf(&b, "s=%s\r\n", name)
+	fmt.Fprintf(&b, "c=IN %s %s\r\n", ipVer, conn)
+	b.WriteString("t=0 0\r\n")
+	fmt.Fprintf(&b, "m=audio %d RTP/AVP %d\r\n", cfg.Port, rtpPayloadType)
+	fmt.Fprintf(&b, "a=rtpmap:%d %s/%d/2\r\n", rtpPayloadType, cfg.Format, cfg.SampleRate)
+	fmt.Fprintf(&b, "a=ptime:%g\r\n", float64(rtpPacketTime)/float64(time.Millisecond))
+	b.WriteString("a=recvonly\r\n")
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package streams
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/micro-nova/amplipi-go/internal/snapcast"
+)
+
+// snapSource is one AmpliPi source served by snapserver.
+type snapSource struct {
+	vsrc  int
+	codec string
+}
+
+// SnapServer runs a managed snapserver whose streams are the sources with
+// Snapcast output enabled. Like RTPSender it reads the dsnoop-backed
+// lb{N}p captures, so it plays alongside the wired zones.
+type SnapServer struct {
+	dir     string
+	sources map[int]snapSource // source ID → feed; empty when stopped
+	sup     *Supervisor
+}
+
+// NewSnapServer creates a stopped SnapServer keeping its config and state in dir.
+func NewSnapServer(dir string) *SnapServer {
+	return &SnapServer{dir: dir, sources: map[int]snapSource{}}
+}
+
+// update reconfigures snapserver for the given sources. snapserver reads its
+// streams only at startup, so any change restarts it; clients reconnect on
+// their own within a few seconds.
+func (s *SnapServer) update(ctx context.Context, sources map[int]snapSource) error {
+	if maps.Equal(s.sources, sources) {
+		return nil
+	}
+	if err := s.Stop(); err != nil {
+		return err
+	}
+	if len(sources) == 0 {
+		return nil
+	}
+
+	conf := filepath.Join(s.dir, "snapserver.conf")
+	if err := writeFileAtomic(conf, []byte(snapserverConf(s.dir, sources))); err != nil {
+		return fmt.Errorf("snapserver config: %w", err)
+	}
+	s.sources = sources
+	s.sup = NewSupervisor("snapserver", func() *exec.Cmd {
+		cmd := exec.Command(findBinary("snapserver"), "-c", conf)
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		return cmd
+	})
+	slog.Info("snapserver: starting", "sources", slices.Sorted(maps.Keys(sources)))
+	return s.sup.Start(ctx)
+}
+
+// announcePipe is the FIFO snapserver reads announcement audio from.
+func announcePipe(dir string) string {
+	return filepath.Join(dir, "announce.fifo")
+}
+
+// running reports whether snapserver has been started.
+func (s *SnapServer) running() bool {
+	return s.sup != nil
+}
+
+// Stop terminates snapserver if it is running.
+func (s *SnapServer) Stop() error {
+	s.sources = map[int]snapSource{}
+	if s.sup == nil {
+		return nil
+	}
+	slog.Info("snapserver: stopping")
+	err := s.sup.Stop()
+	s.sup = nil
+	return err
+}
+
+// snapserverConf renders a snapserver.conf serving each source as an ALSA stream.
+func snapserverConf(dir string, sources map[int]snapSource) string {
+	var b strings.Builder
+	b.WriteString("# snapserver configuration — generated by amplipi, do not edit by hand\n\n")
+	fmt.Fprintf(&b, "[server]\ndatadir = %s\n\n", dir)
+	b.WriteString("[http]\nenabled = true\nport = 1780\n\n")
+	b.WriteString("[tcp]\nenabled = true\nport = 1705\n\n")
+	fmt.Fprintf(&b, "[stream]\nport = 1704\nbuffer = %d\n", snapcast.BufferMS)
+	rate := audioLayout.Load().SampleRate
+	for _, sid := range slices.Sorted(maps.Keys(sources)) {
+		src := sources[sid]
+		codec := src.codec
+		if codec == "" {
+			codec = "flac"
+		}
+		fmt.Fprintf(&b, "source = alsa:///?name=%s&device=%s&sampleformat=%d:16:2&codec=%s\n",
+			snapcast.SourceStreamID(sid), VirtualCaptureDevice(src.vsrc), rate, codec)
+	}
+	// Announcements are fed as raw PCM into a pipe so they can start ahead
+	// of the wired zones by the buffer length.
+	fmt.Fprintf(&b, "source = pipe://%s?name=%s&mode=create&sampleformat=%d:16:2&codec=flac\n",
+		announcePipe(dir), snapcast.AnnounceStreamID, rate)
+	return b.String()
+}
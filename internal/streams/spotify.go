@@ -3,11 +3,16 @@ package streams
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"log/slog"
 	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"syscall"
@@ -26,15 +31,28 @@ server:
   port: %d
 credentials:
   type: zeroconf
+  zeroconf:
+    persist_credentials: true
 `
 
+// librespotStateFile is where go-librespot keeps the credentials obtained
+// by zeroconf pairing, next to its config.
+const librespotStateFile = "state.json"
+
+// spotifyPollInterval is how often go-librespot's status is polled, and
+// how long it is given to start before the first poll.
+var spotifyPollInterval = 5 * time.Second
+
 // SpotifyStream plays Spotify Connect audio via go-librespot.
 // Persistent — go-librespot advertises on the network continuously.
+// Users pair with it by picking the device in the Spotify app (zeroconf);
+// the credentials are kept in dataDir so they survive restarts.
 type SpotifyStream struct {
 	SubprocStream
 
 	name    string
-	apiPort int // 3678 + vsrc
+	apiPort int    // 3678 + vsrc
+	dataDir string // go-librespot's config dir; the vsrc dir when unset
 
 	monCancel context.CancelFunc
 	monWg     sync.WaitGroup
@@ -63,11 +81,14 @@ func (s *SpotifyStream) Activate(ctx context.Context, vsrc int, configDir string
 	device := VirtualOutputDevice(vsrc)
 	cfgContent := fmt.Sprintf(goLibrespotConfig, s.name, device, s.apiPort)
 
-	if err := writeFileAtomic(dir+"/config.yml", []byte(cfgContent)); err != nil {
+	cfgDir := dir
+	if s.dataDir != "" {
+		cfgDir = s.dataDir
+	}
+	if err := writeFileAtomic(filepath.Join(cfgDir, "config.yml"), []byte(cfgContent)); err != nil {
 		return fmt.Errorf("spotify_connect: write config.yml: %w", err)
 	}
 
-	cfgDir := dir
 	s.sup = NewSupervisor("spotify_connect/"+s.name, func() *exec.Cmd {
 		cmd := exec.Command(findBinary("go-librespot"), "--config_dir", cfgDir)
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
@@ -75,8 +96,9 @@ func (s *SpotifyStream) Activate(ctx context.Context, vsrc int, configDir string
 	})
 
 	s.setInfo(models.StreamInfo{
-		Name:  s.name,
-		State: "stopped",
+		Name:    s.name,
+		State:   "stopped",
+		Pairing: storedPairing(cfgDir),
 	})
 
 	if err := s.activateBase(ctx, vsrc, dir); err != nil {
@@ -153,8 +175,37 @@ func (s *SpotifyStream) Info() models.StreamInfo {
 func (s *SpotifyStream) IsPersistent() bool { return true }
 func (s *SpotifyStream) Type() string        { return "spotify_connect" }
 
+func (s *SpotifyStream) setDataDir(dir string) { s.dataDir = dir }
+
+// resetPairing deletes the stored credentials.
+func (s *SpotifyStream) resetPairing() error {
+	if s.dataDir == "" {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(s.dataDir, librespotStateFile)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("spotify_connect: reset pairing: %w", err)
+	}
+	return nil
+}
+
+// storedPairing reports the account whose credentials go-librespot has
+// stored in dir, if any.
+func storedPairing(dir string) *models.PairingStatus {
+	var st struct {
+		Credentials struct {
+			Username string `json:"username"`
+		} `json:"credentials"`
+	}
+	data, err := os.ReadFile(filepath.Join(dir, librespotStateFile))
+	if err != nil || json.Unmarshal(data, &st) != nil || st.Credentials.Username == "" {
+		return &models.PairingStatus{State: models.PairingWaiting}
+	}
+	return &models.PairingStatus{State: models.PairingPaired, User: st.Credentials.Username}
+}
+
 // spotifyStatus is the JSON response from go-librespot's /status endpoint.
 type spotifyStatus struct {
+	Username    string `json:"username"` // empty until an account connects
 	PlayerState struct {
 		IsPlaying bool `json:"is_playing"`
 		IsPaused  bool `json:"is_paused"`
@@ -177,10 +228,10 @@ func (s *SpotifyStream) pollMetadata(ctx context.Context) {
 	select {
 	case <-ctx.Done():
 		return
-	case <-time.After(5 * time.Second):
+	case <-time.After(spotifyPollInterval):
 	}
 
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := time.NewTicker(spotifyPollInterval)
 	defer ticker.Stop()
 
 	for {
@@ -192,6 +243,12 @@ func (s *SpotifyStream) pollMetadata(ctx context.Context) {
 			if info == nil {
 				continue
 			}
+			s.mu.RLock()
+			unchanged := reflect.DeepEqual(s.info, *info)
+			s.mu.RUnlock()
+			if unchanged {
+				continue
+			}
 			s.setInfo(*info)
 			slog.Debug("spotify_connect: metadata updated",
 				"track", info.Track, "artist", info.Artist, "state", info.State)
@@ -217,6 +274,15 @@ func (s *SpotifyStream) fetchStatus(ctx context.Context) *models.StreamInfo {
 	}
 	defer resp.Body.Close()
 
+	// go-librespot has no session until a user picks the device in the app
+	if resp.StatusCode == http.StatusNoContent {
+		return &models.StreamInfo{
+			Name:    s.name,
+			State:   "stopped",
+			Pairing: &models.PairingStatus{State: models.PairingWaiting},
+		}
+	}
+
 	var status spotifyStatus
 	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
 		return nil
@@ -234,6 +300,11 @@ func (s *SpotifyStream) fetchStatus(ctx context.Context) *models.StreamInfo {
 		artist = strings.Join(status.Track.ArtistNames, ", ")
 	}
 
+	pairing := &models.PairingStatus{State: models.PairingWaiting}
+	if status.Username != "" {
+		pairing = &models.PairingStatus{State: models.PairingPaired, User: status.Username}
+	}
+
 	return &models.StreamInfo{
 		Name:     s.name,
 		State:    state,
@@ -241,5 +312,6 @@ func (s *SpotifyStream) fetchStatus(ctx context.Context) *models.StreamInfo {
 		Artist:   artist,
 		Album:    status.Track.AlbumName,
 		ImageURL: status.Track.AlbumCover,
+		Pairing:  pairing,
 	}
 }
@@ -6,6 +6,7 @@ package streams
 import (
 	"context"
 	"errors"
+	"sync"
 
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
@@ -13,6 +14,16 @@ import (
 // ErrNotSupported is returned by stream types that are not yet implemented.
 var ErrNotSupported = errors.New("stream type not supported")
 
+// ErrNotBrowsable is returned when browsing a stream type without browsable content.
+var ErrNotBrowsable = errors.New("stream is not browsable")
+
+// ErrNotActive is returned when browsing a stream whose content is only
+// known once it is running.
+var ErrNotActive = errors.New("stream is not active")
+
+// ErrNoQueue is returned when asking a stream without a play queue for one.
+var ErrNoQueue = errors.New("stream has no play queue")
+
 // Streamer is the interface every stream type must implement.
 // Implementations are NOT required to be thread-safe internally;
 // the Manager serializes calls to each Streamer.
@@ -50,10 +61,54 @@ type Streamer interface {
 	Type() string
 }
 
+// ErrNotPairable is returned when resetting the pairing of a stream type
+// that is not paired from an app.
+var ErrNotPairable = errors.New("stream has no pairing")
+
+// pairable is implemented by streams that keep credentials obtained by
+// zeroconf pairing in a data directory of their own, keyed by stream ID so
+// the pairing survives the stream moving to another vsrc.
+type pairable interface {
+	setDataDir(dir string)
+	// resetPairing forgets the stored credentials. The Manager restarts
+	// an active stream afterwards so it advertises as unpaired.
+	resetPairing() error
+}
+
+// Browsable is implemented by streams whose content can be navigated,
+// e.g. the file player's media directory, Pandora stations, LMS library
+// menus or DLNA servers. Item IDs are paths that can be browsed further
+// (folders) or played with the stream's play=<id> command.
+type Browsable interface {
+	// Browse lists the folders and playable items at path ("" for the top level).
+	Browse(ctx context.Context, path string) ([]models.BrowsableItem, error)
+}
+
+// reconfigurable is implemented by streams whose running process is
+// configured from the stream's name or config, e.g. the name AirPlay
+// advertises.
+type reconfigurable interface {
+	// reconfigure takes the stream's current name and config and reports
+	// whether a running stream must restart to apply them.
+	reconfigure(stream models.Stream) bool
+}
+
+// supervised is implemented by streams that run supervised processes,
+// whose output is captured to log files and whose health is reported.
+type supervised interface {
+	setLogDir(dir string)
+	setStatusHook(fn func())
+}
+
 // StreamState tracks a Streamer's runtime state within the Manager.
+// mu serializes calls into the Streamer; Sync holds the locks of several
+// streams at once to bring them up concurrently.
 type StreamState struct {
+	mu sync.Mutex
+
 	Streamer Streamer
 	StreamID int
+	Name     string
 	VSRC     int // -1 if not activated
 	PhysSrc  int // -1 if not connected
 	Active   bool
@@ -2,29 +2,46 @@ package streams
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/micro-nova/amplipi-go/internal/audio"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
 	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
 // ─── VSRCAllocator ──────────────────────────────────────────────────────────
 
+// defaultVSRCs is the number of vsrcs in audio.DefaultLayout.
+const defaultVSRCs = 12
+
 func TestVSRCAllocator(t *testing.T) {
 	a := NewVSRCAllocator()
 
 	// Alloc all 12 slots
-	for i := 0; i < MaxVSRC; i++ {
+	for i := 0; i < defaultVSRCs; i++ {
 		vsrc, err := a.Alloc()
 		if err != nil {
 			t.Fatalf("Alloc() #%d failed: %v", i, err)
 		}
-		if vsrc < 0 || vsrc >= MaxVSRC {
+		if vsrc < 0 || vsrc >= defaultVSRCs {
 			t.Fatalf("Alloc() returned out-of-range vsrc %d", vsrc)
 		}
 	}
@@ -46,11 +63,33 @@ func TestVSRCAllocator(t *testing.T) {
 	}
 }
 
+func TestVSRCAllocator_SizedByLayout(t *testing.T) {
+	defer SetAudioLayout(audio.DefaultLayout())
+	l := audio.DefaultLayout()
+	for i := len(l.Loopbacks); i < 10; i++ {
+		l.Loopbacks = append(l.Loopbacks, fmt.Sprintf("Loopback%d", i))
+	}
+	SetAudioLayout(l)
+
+	a := NewVSRCAllocator()
+	if a.Size() != 20 {
+		t.Fatalf("Size() = %d, want 20", a.Size())
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := a.Alloc(); err != nil {
+			t.Fatalf("Alloc() #%d failed: %v", i, err)
+		}
+	}
+	if _, err := a.Alloc(); err != ErrNoVSRC {
+		t.Fatalf("expected ErrNoVSRC, got %v", err)
+	}
+}
+
 func TestVSRCAllocator_FreeOutOfRange(t *testing.T) {
 	a := NewVSRCAllocator()
 	// Should not panic
 	a.Free(-1)
-	a.Free(MaxVSRC)
+	a.Free(defaultVSRCs)
 	a.Free(100)
 }
 
@@ -129,6 +168,136 @@ func TestNewStreamer_UnknownType(t *testing.T) {
 	}
 }
 
+func TestRegisterStreamerType(t *testing.T) {
+	bridge := StreamerType{
+		Schema: models.StreamSchema{Type: "test_bridge", Aliases: []string{"testbridge"}, Name: "Test bridge", Fields: []models.ConfigField{
+			{Key: "zone_name", Type: models.FieldString, Description: "Name shown in the app"},
+		}},
+		New: func(stream models.Stream) (Streamer, error) {
+			return NewAuxStream(stream.Name), nil
+		},
+		Binaries: []string{"sh"},
+	}
+	if err := RegisterStreamerType(bridge); err != nil {
+		t.Fatal(err)
+	}
+
+	streamer, err := NewStreamer(models.Stream{ID: 1000, Name: "Bridge", Type: "testbridge"})
+	if err != nil || streamer == nil {
+		t.Fatalf("NewStreamer(registered alias) = %v, %v", streamer, err)
+	}
+	if sc := models.FindStreamSchema("test_bridge"); sc == nil || sc.Field("zone_name") == nil {
+		t.Errorf("schema not registered: %+v", sc)
+	}
+	found := false
+	for _, cap := range hardware.DetectStreamCapabilities() {
+		if cap.Type == "test_bridge" {
+			found = true
+			if !cap.Available || cap.Binary == "" {
+				t.Errorf("capability = %+v, want sh found", cap)
+			}
+		}
+	}
+	if !found {
+		t.Error("registered type missing from stream capabilities")
+	}
+	if !hardware.MockProfile().StreamAvailable("test_bridge") {
+		t.Error("registered type unavailable in the mock profile")
+	}
+
+	// Types and aliases must not clash with known ones.
+	if err := RegisterStreamerType(bridge); err == nil {
+		t.Error("duplicate type registered")
+	}
+	bridge.Schema = models.StreamSchema{Type: "roon", Aliases: []string{"spotify_connect"}}
+	if err := RegisterStreamerType(bridge); err == nil {
+		t.Error("type aliasing a built-in registered")
+	}
+	if err := RegisterStreamerType(StreamerType{Schema: models.StreamSchema{Type: "no_constructor"}}); err == nil {
+		t.Error("type without a constructor registered")
+	}
+}
+
+// testPlugin answers every request, fails the "fail" command and reports
+// a track once activated.
+const testPlugin = `#!/bin/sh
+while read -r line; do
+	id=$(echo "$line" | sed 's/.*"id":\([0-9]*\).*/\1/')
+	case "$line" in
+	*'"method":"activate"'*) echo '{"method":"info","params":{"state":"playing","track":"Plugin song"}}'; echo "{\"id\":$id}" ;;
+	*'"cmd":"fail"'*) echo "{\"id\":$id,\"error\":\"no such command\"}" ;;
+	*'"cmd":"garble"'*) echo "{\"id\":$id,\"error\":" ;;
+	*'"cmd":"flood"'*) printf '{"id":%s,"pad":"' "$id"; head -c 1100000 /dev/zero | tr '\0' x; echo '"}' ;;
+	*) echo "{\"id\":$id}" ;;
+	esac
+done
+`
+
+func TestPluginStream(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "player.sh"), []byte(testPlugin), 0755)
+	os.WriteFile(filepath.Join(dir, "test_plugin.json"), []byte(`{"type":"test_plugin","name":"Test player","exec":"player.sh","fields":[{"key":"room","type":"string"}]}`), 0644)
+	os.WriteFile(filepath.Join(dir, "broken.json"), []byte(`{"name":"No type"}`), 0644)
+
+	types, err := LoadPlugins(dir)
+	if len(types) != 1 || types[0] != "test_plugin" {
+		t.Fatalf("LoadPlugins = %v", types)
+	}
+	if err == nil || !strings.Contains(err.Error(), "broken.json") {
+		t.Errorf("LoadPlugins error = %v, want the broken manifest", err)
+	}
+	if sc := models.FindStreamSchema("test_plugin"); sc == nil || sc.Validate(map[string]interface{}{"room": "den"}) != nil {
+		t.Errorf("plugin schema = %+v", sc)
+	}
+
+	streamer, err := NewStreamer(models.Stream{ID: 1001, Name: "Den player", Type: "test_plugin", Config: map[string]interface{}{"room": "den"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := streamer.(*PluginStream)
+	changed := make(chan models.StreamInfo, 4)
+	ps.onChange = func(info models.StreamInfo) { changed <- info }
+	ctx := context.Background()
+	if err := ps.Activate(ctx, 0, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer ps.Deactivate(ctx)
+
+	select {
+	case info := <-changed:
+		if info.Track != "Plugin song" || info.State != "playing" || info.Name != "Den player" {
+			t.Errorf("info = %+v", info)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no info from plugin")
+	}
+	if err := ps.SendCmd(ctx, "play"); err != nil {
+		t.Errorf("play: %v", err)
+	}
+	if err := ps.SendCmd(ctx, "fail"); err == nil || !strings.Contains(err.Error(), "no such command") {
+		t.Errorf("fail: %v, want the plugin's error", err)
+	}
+	// Bad replies fail only their own request; the plugin is still heard.
+	for _, cmd := range []string{"garble", "flood"} {
+		if err := ps.SendCmd(ctx, cmd); err == nil || !strings.Contains(err.Error(), "bad reply") {
+			t.Errorf("%s: %v, want a bad reply error", cmd, err)
+		}
+		if err := ps.SendCmd(ctx, "play"); err != nil {
+			t.Errorf("play after %s: %v", cmd, err)
+		}
+	}
+
+	if err := ps.Deactivate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.SendCmd(ctx, "play"); !errors.Is(err, ErrNotActive) {
+		t.Errorf("after deactivate: %v, want ErrNotActive", err)
+	}
+}
+
 // ─── RCAStream ───────────────────────────────────────────────────────────────
 
 func TestRCAStream(t *testing.T) {
@@ -310,6 +479,45 @@ func TestManagerSync_Connect(t *testing.T) {
 	}
 }
 
+func TestManagerRoutes(t *testing.T) {
+	music := t.TempDir()
+	if err := os.WriteFile(filepath.Join(music, "a.mp3"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	useMediaDir(t, music)
+	m := NewManager(t.TempDir(), nil)
+	ctx := context.Background()
+	modelStreams := []models.Stream{
+		{ID: 10, Name: "AUX", Type: "aux"},
+		{ID: 200, Name: "Music", Type: "file_player", Config: map[string]interface{}{"path": music}},
+		{ID: 300, Name: "Idle", Type: "file_player", Config: map[string]interface{}{"path": music}},
+	}
+	sources := []models.Source{{ID: 1, Input: "stream=10"}, {ID: 2, Input: "stream=200"}}
+	if err := m.Sync(ctx, modelStreams, sources); err != nil {
+		t.Fatalf("Sync() error: %v", err)
+	}
+	t.Cleanup(func() { m.Sync(ctx, nil, nil) })
+
+	want := []models.StreamRoute{
+		{ID: 10, Name: "AUX", Type: "aux", Active: true, VSRC: -1, Source: 1},
+		// Only ch0 is available by default, so source 2 plays to it.
+		{ID: 200, Name: "Music", Type: "file_player", Active: true, VSRC: 0, Source: 2, Device: "lb0c", Capture: "lb0p", Output: "ch0"},
+		{ID: 300, Name: "Idle", Type: "file_player", VSRC: -1, Source: -1},
+	}
+	if got := m.Routes(); !slices.Equal(got, want) {
+		t.Errorf("Routes() = %+v\nwant %+v", got, want)
+	}
+	if m.VSRCs() != audio.DefaultLayout().VSRCCount() {
+		t.Errorf("VSRCs() = %d", m.VSRCs())
+	}
+
+	SetAvailablePhysicalOutputs([]int{0, 2})
+	t.Cleanup(func() { SetAvailablePhysicalOutputs([]int{0}) })
+	if got := m.Routes()[1].Output; got != "ch2" {
+		t.Errorf("output with ch2 available = %q, want ch2", got)
+	}
+}
+
 func TestManagerSendCmd_Unknown(t *testing.T) {
 	dir := t.TempDir()
 	m := NewManager(dir, nil)
@@ -463,6 +671,72 @@ func TestSupervisor_FastFailGivesUp(t *testing.T) {
 	}
 }
 
+func TestSupervisor_CapturesOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+
+	dir := t.TempDir()
+	m := NewManager(dir, nil)
+	sup := NewSupervisor("test-output", func() *exec.Cmd {
+		return exec.Command("sh", "-c", "echo ready; echo 'auth failed' >&2; exit 3")
+	})
+	sup.maxFails = 1
+	sup.setLogDir(m.streamLogDir(7))
+
+	if err := sup.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	// maxFails 1: the supervisor gives up after the first fast exit.
+	select {
+	case <-sup.doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not give up")
+	}
+
+	logs, err := m.Logs(7, 0)
+	if err != nil {
+		t.Fatalf("Logs: %v", err)
+	}
+	if len(logs) != 1 || logs[0].Process != "sh" {
+		t.Fatalf("logs = %+v", logs)
+	}
+	text := strings.Join(logs[0].Lines, "\n")
+	for _, want := range []string{"starting sh -c", "ready", "auth failed", "exit status 3"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("log missing %q:\n%s", want, text)
+		}
+	}
+	if got, _ := m.Logs(7, 1); len(got[0].Lines) != 1 {
+		t.Errorf("lines=1 returned %d lines", len(got[0].Lines))
+	}
+	if got, _ := m.Logs(8, 0); len(got) != 0 {
+		t.Errorf("unknown stream logs = %+v", got)
+	}
+}
+
+func TestProcLogRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "proc.log")
+	l, err := openProcLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := strings.Repeat("x", 1023) + "\n"
+	for i := 0; i < procLogMaxSize/1024+10; i++ {
+		if _, err := l.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	l.Close()
+	if info, err := os.Stat(path + ".1"); err != nil || info.Size() > procLogMaxSize {
+		t.Fatalf("rotated file: %v %v", info, err)
+	}
+	lines, err := tailProcLog(path, 20)
+	if err != nil || len(lines) != 20 {
+		t.Errorf("tail = %d lines, %v", len(lines), err)
+	}
+}
+
 // ─── Pandora parsing ─────────────────────────────────────────────────────────
 
 func TestParsePianobarCurrentSong(t *testing.T) {
@@ -775,6 +1049,66 @@ func TestSubprocStream_DisconnectBase_Nil(t *testing.T) {
 	}
 }
 
+func TestSubprocStream_SupervisorStatus(t *testing.T) {
+	s := newSubprocTestStream()
+	s.setInfo(models.StreamInfo{Name: "Test", State: "stopped"})
+	statusCh := make(chan models.StreamInfo, 16)
+	s.setStatusHook(func() { statusCh <- s.getInfo() })
+	s.sup = NewSupervisor("test-missing", func() *exec.Cmd {
+		return exec.Command("/nonexistent/amplipi-test-binary")
+	})
+
+	if err := s.activateBase(context.Background(), 0, t.TempDir()); err != nil {
+		t.Fatalf("activateBase: %v", err)
+	}
+	select {
+	case info := <-statusCh:
+		if info.State != "unavailable" || info.Supervisor == nil ||
+			info.Supervisor.State != models.SupervisorFailed || info.Supervisor.Reason != "binary not found" ||
+			info.Supervisor.Process != "amplipi-test-binary" {
+			t.Errorf("info = %+v, supervisor = %+v", info, info.Supervisor)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no status change reported")
+	}
+
+	if err := s.deactivateBase(context.Background()); err != nil {
+		t.Fatalf("deactivateBase: %v", err)
+	}
+	if info := s.getInfo(); info.Supervisor != nil || info.State != "stopped" {
+		t.Errorf("after deactivate: %+v", info)
+	}
+}
+
+func TestSupervisor_StatusRestarts(t *testing.T) {
+	if _, err := exec.LookPath("false"); err != nil {
+		t.Skip("false not available")
+	}
+
+	sup := NewSupervisor("test-restarts", func() *exec.Cmd {
+		return exec.Command("false")
+	})
+	sup.maxFails = 3
+	sup.backoff = 10 * time.Millisecond
+	sup.maxBackoff = 10 * time.Millisecond
+	if err := sup.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error: %v", err)
+	}
+	select {
+	case <-sup.doneCh:
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not give up")
+	}
+
+	st := sup.Status()
+	if st.State != models.SupervisorFailed || st.Restarts != 2 || st.Process != "false" {
+		t.Errorf("status = %+v", st)
+	}
+	if !strings.Contains(st.Reason, "exit status 1") {
+		t.Errorf("reason = %q, want last exit error", st.Reason)
+	}
+}
+
 // ─── InternetRadioStream (without activation) ─────────────────────────────────
 
 func TestInternetRadioStream_Basics(t *testing.T) {
@@ -822,6 +1156,108 @@ func TestAirPlayStream_Basics(t *testing.T) {
 	_ = s.Info()
 }
 
+func TestAirPlayStream_DeviceName(t *testing.T) {
+	stream := models.Stream{ID: 1, Name: "Kitchen", Type: "airplay", Config: map[string]interface{}{
+		"device_name": `Kitchen "Main"`, "password": "secret",
+	}}
+	streamer, err := NewStreamer(stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := streamer.(*AirPlayStream)
+	dir := t.TempDir()
+	if err := s.Activate(context.Background(), 0, dir); err != nil {
+		t.Fatal(err)
+	}
+	conf, _ := os.ReadFile(filepath.Join(dir, "v0", "shairport.conf"))
+	s.Deactivate(context.Background())
+	if !strings.Contains(string(conf), `name = "Kitchen \"Main\"";`) || !strings.Contains(string(conf), `password = "secret";`) {
+		t.Errorf("shairport.conf =\n%s", conf)
+	}
+
+	// Renaming the stream keeps the advertised device name; changing the
+	// device name or password needs a restart.
+	stream.Name = "Kitchen AirPlay"
+	if s.reconfigure(stream) {
+		t.Error("restart for a stream rename that keeps the device name")
+	}
+	stream.Config = map[string]interface{}{"device_name": `Kitchen "Main"`, "password": "other"}
+	if !s.reconfigure(stream) {
+		t.Error("no restart for a new password")
+	}
+	stream.Config = nil
+	if !s.reconfigure(stream) || s.advertisedName() != "Kitchen AirPlay" {
+		t.Errorf("without a device name advertised as %q", s.advertisedName())
+	}
+	if raopName("AA11BB22CC33@Living Room") != "Living Room" {
+		t.Error("RAOP instance name not parsed")
+	}
+}
+
+func TestAirPlayStream_Metadata(t *testing.T) {
+	item := func(typ, code, value string) string {
+		x := fmt.Sprintf("<item><type>%x</type><code>%x</code><length>%d</length>", typ, code, len(value))
+		if value != "" {
+			x += "\n<data encoding=\"base64\">\n" + base64.StdEncoding.EncodeToString([]byte(value)) + "</data>"
+		}
+		return x + "</item>\n"
+	}
+
+	pipe := filepath.Join(t.TempDir(), "shairport-metadata")
+	if err := makeFIFO(pipe); err != nil {
+		t.Fatal(err)
+	}
+	infos := make(chan models.StreamInfo, 16)
+	s := NewAirPlayStream("My AirPlay")
+	s.onChange = func(info models.StreamInfo) { infos <- info }
+	ctx, cancel := context.WithCancel(context.Background())
+	s.monWg.Add(1)
+	go s.readMetadata(ctx, pipe)
+	defer func() {
+		cancel()
+		s.monWg.Wait()
+	}()
+
+	w, err := os.OpenFile(pipe, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	next := func() models.StreamInfo {
+		t.Helper()
+		select {
+		case info := <-infos:
+			return info
+		case <-time.After(2 * time.Second):
+			t.Fatal("no stream info published")
+		}
+		return models.StreamInfo{}
+	}
+
+	fmt.Fprint(w, item("ssnc", "abeg", "")+item("ssnc", "snam", "Alice's iPhone")+item("ssnc", "daid", "D1A2")+
+		item("ssnc", "pbeg", "")+item("ssnc", "mdst", "")+item("core", "minm", "Blue in Green")+
+		item("core", "asar", "Miles Davis")+item("ssnc", "mden", ""))
+	var info models.StreamInfo
+	for i := 0; i < 5; i++ { // abeg, snam, daid, pbeg, mden
+		info = next()
+	}
+	if !info.AirPlayActive || info.State != "playing" || info.Track != "Blue in Green" || info.Artist != "Miles Davis" {
+		t.Errorf("while playing: %+v", info)
+	}
+	if info.AirPlay == nil || info.AirPlay.Client != "Alice's iPhone" || info.AirPlay.DACPID != "D1A2" {
+		t.Errorf("session = %+v", info.AirPlay)
+	}
+
+	fmt.Fprint(w, item("ssnc", "pfls", ""))
+	if info := next(); info.State != "paused" {
+		t.Errorf("after flush: state %q", info.State)
+	}
+	fmt.Fprint(w, item("ssnc", "aend", ""))
+	if info := next(); info.AirPlayActive || info.AirPlay != nil || info.Track != "" || info.State != "connected" {
+		t.Errorf("after the sender left: %+v", info)
+	}
+}
+
 // ─── BluetoothStream (without activation) ────────────────────────────────────
 
 func TestBluetoothStream_Basics(t *testing.T) {
@@ -843,6 +1279,80 @@ func TestBluetoothStream_Basics(t *testing.T) {
 	}
 }
 
+func TestBluetoothStream_BlueZ(t *testing.T) {
+	// busctl --json=short output of GetManagedObjects with a phone
+	// connected to hci1 and another device paired but disconnected.
+	const objects = `{"type":"a{oa{sa{sv}}}","data":[{
+		"/org/bluez/hci1":{"org.bluez.Adapter1":{"Alias":{"type":"s","data":"Patio"}}},
+		"/org/bluez/hci1/dev_AA_BB":{"org.bluez.Device1":{"Address":{"type":"s","data":"AA:BB"},"Alias":{"type":"s","data":"Alice's Pixel"},"Connected":{"type":"b","data":true}}},
+		"/org/bluez/hci1/dev_AA_BB/player0":{"org.bluez.MediaPlayer1":{"Status":{"type":"s","data":"playing"},
+			"Track":{"type":"a{sv}","data":{"Title":{"type":"s","data":"So What"},"Artist":{"type":"s","data":"Miles Davis"},"Album":{"type":"s","data":"Kind of Blue"}}}}},
+		"/org/bluez/hci1/dev_CC_DD":{"org.bluez.Device1":{"Address":{"type":"s","data":"CC:DD"},"Alias":{"type":"s","data":"Tablet"},"Connected":{"type":"b","data":false}}}
+	}]}`
+	var calls [][]string
+	old := busctl
+	busctl = func(_ context.Context, args ...string) ([]byte, error) {
+		calls = append(calls, args)
+		if slices.Contains(args, "GetManagedObjects") {
+			return []byte(objects), nil
+		}
+		return nil, nil
+	}
+	defer func() { busctl = old }()
+
+	var published []models.StreamInfo
+	s := NewBluetoothStream("Bluetooth")
+	s.adapter = "hci1"
+	s.onChange = func(info models.StreamInfo) { published = append(published, info) }
+	s.refresh(context.Background())
+	s.refresh(context.Background())
+	if len(published) != 1 {
+		t.Fatalf("published %d infos, want 1 (the second refresh changed nothing)", len(published))
+	}
+	info := published[0]
+	if info.State != "playing" || info.Track != "So What" || info.Artist != "Miles Davis" || info.Album != "Kind of Blue" {
+		t.Errorf("info = %+v", info)
+	}
+	if info.Bluetooth == nil || info.Bluetooth.Name != "Alice's Pixel" || info.Bluetooth.Address != "AA:BB" {
+		t.Errorf("device = %+v", info.Bluetooth)
+	}
+
+	calls = nil
+	if err := s.SendCmd(context.Background(), "next"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"call", "org.bluez", "/org/bluez/hci1/dev_AA_BB/player0", "org.bluez.MediaPlayer1", "Next"}
+	if len(calls) != 1 || !slices.Equal(calls[0], want) {
+		t.Errorf("busctl calls = %v, want %v", calls, want)
+	}
+
+	// Another adapter sees no device.
+	s.adapter = "hci0"
+	s.refresh(context.Background())
+	if info := s.Info(); info.State != "stopped" || info.Bluetooth != nil || info.Track != "" {
+		t.Errorf("hci0 info = %+v", info)
+	}
+}
+
+func TestBluetoothStream_Adapter(t *testing.T) {
+	useFakeBinaries(t)
+	old := busctl
+	busctl = func(context.Context, ...string) ([]byte, error) { return nil, nil }
+	defer func() { busctl = old }()
+
+	ctx := context.Background()
+	s := NewBluetoothStream("Bluetooth")
+	s.adapter = "hci1"
+	if err := s.Activate(ctx, 0, t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Deactivate(ctx)
+	args := s.sup.buildCmd().Args
+	if i := slices.Index(args, "-i"); i < 0 || args[i+1] != "hci1" {
+		t.Errorf("bluealsa-aplay args = %v, want -i hci1", args)
+	}
+}
+
 // ─── DLNAStream (without activation) ─────────────────────────────────────────
 
 func TestDLNAStream_Basics(t *testing.T) {
@@ -900,6 +1410,56 @@ func TestSpotifyStream_Basics(t *testing.T) {
 	}
 }
 
+func TestSpotifyStream_Pairing(t *testing.T) {
+	dir := t.TempDir()
+	if p := storedPairing(dir); p.State != models.PairingWaiting {
+		t.Errorf("no credentials: pairing = %+v", p)
+	}
+	state := `{"device_id":"abc","credentials":{"username":"alice","data":"c2VjcmV0"}}`
+	if err := os.WriteFile(filepath.Join(dir, librespotStateFile), []byte(state), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if p := storedPairing(dir); p.State != models.PairingPaired || p.User != "alice" {
+		t.Errorf("stored credentials: pairing = %+v", p)
+	}
+
+	// The running process reports the paired account in its status.
+	var paired bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !paired {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		fmt.Fprint(w, `{"username":"bob","stopped":true}`)
+	}))
+	defer srv.Close()
+	s := NewSpotifyStream("My Spotify", nil)
+	s.apiPort = srv.Listener.Addr().(*net.TCPAddr).Port
+	if info := s.fetchStatus(context.Background()); info == nil || info.Pairing.State != models.PairingWaiting {
+		t.Errorf("unpaired status: %+v", info)
+	}
+	paired = true
+	if info := s.fetchStatus(context.Background()); info == nil || info.Pairing.User != "bob" {
+		t.Errorf("paired status: %+v", info)
+	}
+
+	// Resetting deletes the stored credentials, even while inactive.
+	m := NewManager(t.TempDir(), nil)
+	sp := NewSpotifyStream("My Spotify", nil)
+	sp.setDataDir(dir)
+	m.streams[1] = &StreamState{Streamer: sp, StreamID: 1, Name: "My Spotify", VSRC: -1, PhysSrc: -1}
+	m.streams[2] = &StreamState{Streamer: &fakeStreamer{}, StreamID: 2, Name: "fake", VSRC: -1, PhysSrc: -1}
+	if err := m.ResetPairing(context.Background(), 1); err != nil {
+		t.Fatalf("ResetPairing: %v", err)
+	}
+	if p := storedPairing(dir); p.State != models.PairingWaiting {
+		t.Errorf("after reset: pairing = %+v", p)
+	}
+	if err := m.ResetPairing(context.Background(), 2); !errors.Is(err, ErrNotPairable) {
+		t.Errorf("ResetPairing of a fake stream: %v", err)
+	}
+}
+
 // ─── PandoraStream (without activation) ──────────────────────────────────────
 
 func TestPandoraStream_Basics(t *testing.T) {
@@ -1077,11 +1637,11 @@ func TestActivateBase_WithSupervisor(t *testing.T) {
 
 func TestVSRCAllocator_Concurrent(t *testing.T) {
 	a := NewVSRCAllocator()
-	results := make(chan int, MaxVSRC)
-	errors := make(chan error, MaxVSRC*2)
+	results := make(chan int, defaultVSRCs)
+	errors := make(chan error, defaultVSRCs*2)
 
 	// Race to allocate all slots
-	for i := 0; i < MaxVSRC+2; i++ {
+	for i := 0; i < defaultVSRCs+2; i++ {
 		go func() {
 			vsrc, err := a.Alloc()
 			if err != nil {
@@ -1105,13 +1665,13 @@ func TestVSRCAllocator_Concurrent(t *testing.T) {
 		case <-timeout:
 			goto done
 		}
-		if len(allocated)+len(errs) == MaxVSRC+2 {
+		if len(allocated)+len(errs) == defaultVSRCs+2 {
 			goto done
 		}
 	}
 done:
-	if len(allocated) != MaxVSRC {
-		t.Errorf("expected %d successful allocs, got %d", MaxVSRC, len(allocated))
+	if len(allocated) != defaultVSRCs {
+		t.Errorf("expected %d successful allocs, got %d", defaultVSRCs, len(allocated))
 	}
 	if len(errs) != 2 {
 		t.Errorf("expected 2 ErrNoVSRC errors, got %d", len(errs))
@@ -1218,3 +1778,1053 @@ func TestManager_SendCmdOnRCA(t *testing.T) {
 // ─── Helper to silence unused import warning ─────────────────────────────────
 
 var _ = fmt.Sprintf
+
+func TestRTPPipeline(t *testing.T) {
+	cfg := models.RTPOutput{Enabled: true}.WithDefaults(0)
+	args := strings.Join(rtpPipeline("lb0p", cfg), " ")
+	for _, want := range []string{
+		"alsasrc device=lb0p buffer-time=20000",
+		"audio/x-raw,format=S24BE,rate=48000,channels=2",
+		"rtpL24pay pt=96",
+		"udpsink host=239.69.0.1 port=5004 ttl-mc=16",
+	} {
+		if !strings.Contains(args, want) {
+			t.Errorf("pipeline missing %q:\n%s", want, args)
+		}
+	}
+
+	cfg.Format = "L16"
+	args = strings.Join(rtpPipeline("lb0p", cfg), " ")
+	if !strings.Contains(args, "rtpL16pay") || !strings.Contains(args, "format=S16BE") {
+		t.Errorf("L16 pipeline wrong:\n%s", args)
+	}
+}
+
+func TestRTPSessionSDP(t *testing.T) {
+	cfg := models.RTPOutput{Enabled: true}.WithDefaults(0)
+	sdp := RTPSessionSDP("Kitchen", "192.168.1.10", cfg)
+	for _, want := range []string{
+		"o=- 5004 0 IN IP4 192.168.1.10\r\n",
+		"s=Kitchen\r\n",
+		"c=IN IP4 239.69.0.1/16\r\n",
+		"m=audio 5004 RTP/AVP 96\r\n",
+		"a=rtpmap:96 L24/48000/2\r\n",
+		"a=ptime:1\r\n",
+	} {
+		if !strings.Contains(sdp, want) {
+			t.Errorf("SDP missing %q:\n%s", want, sdp)
+		}
+	}
+}
+
+func TestSnapserverConf(t *testing.T) {
+	conf := snapserverConf("/tmp/snap", map[int]snapSource{
+		2: {vsrc: 5, codec: "opus"},
+		0: {vsrc: 1},
+	})
+	for _, want := range []string{
+		"datadir = /tmp/snap\n",
+		"source = alsa:///?name=amplipi-src0&device=lb1p&sampleformat=48000:16:2&codec=flac\n",
+		"source = alsa:///?name=amplipi-src2&device=lb5p&sampleformat=48000:16:2&codec=opus\n",
+		"buffer = 1000\n",
+		"source = pipe:///tmp/snap/announce.fifo?name=amplipi-announce&mode=create&sampleformat=48000:16:2&codec=flac\n",
+	} {
+		if !strings.Contains(conf, want) {
+			t.Errorf("snapserver.conf missing %q:\n%s", want, conf)
+		}
+	}
+	if strings.Index(conf, "amplipi-src0") > strings.Index(conf, "amplipi-src2") {
+		t.Error("sources should be ordered by source ID")
+	}
+}
+
+func TestDecodePCMArgs(t *testing.T) {
+	args := strings.Join(decodePCMArgs("http://x/chime.mp3", 44100, 0.5, "pipe:1"), " ")
+	for _, want := range []string{"-i http://x/chime.mp3", "-af volume=0.50", "-f s16le -ac 2 -ar 44100", "-y pipe:1"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args %q missing %q", args, want)
+		}
+	}
+}
+
+// ─── File player queue and media library ─────────────────────────────────────
+
+func TestPlayQueue_Order(t *testing.T) {
+	var q playQueue
+	q.set([]string{"a", "b", "c"})
+
+	var played []string
+	for {
+		track, ok := q.current()
+		if !ok {
+			break
+		}
+		played = append(played, track)
+		q.next()
+	}
+	if strings.Join(played, "") != "abc" {
+		t.Errorf("played %v, want a b c", played)
+	}
+
+	// Adding to a finished queue continues with the new track.
+	q.add("d")
+	if track, _ := q.current(); track != "d" {
+		t.Errorf("after add to finished queue current = %q, want d", track)
+	}
+
+	q.repeat = true
+	if !q.next() {
+		t.Fatal("next with repeat should wrap")
+	}
+	if track, _ := q.current(); track != "a" {
+		t.Errorf("after wrap current = %q, want a", track)
+	}
+	q.prev()
+	if track, _ := q.current(); track != "d" {
+		t.Errorf("prev with repeat at start = %q, want d", track)
+	}
+}
+
+func TestPlayQueue_EditKeepsCurrent(t *testing.T) {
+	var q playQueue
+	q.set([]string{"a", "b", "c", "d"})
+	_ = q.jump(2) // c
+
+	if err := q.move(2, 0); err != nil {
+		t.Fatal(err)
+	}
+	if track, _ := q.current(); track != "c" || q.currentIndex() != 0 {
+		t.Errorf("after move current = %q at %d, want c at 0", track, q.currentIndex())
+	}
+	wasCurrent, err := q.remove(1) // a
+	if err != nil || wasCurrent {
+		t.Fatalf("remove(1) = %v, %v", wasCurrent, err)
+	}
+	if track, _ := q.current(); track != "c" {
+		t.Errorf("after removing another track current = %q, want c", track)
+	}
+	wasCurrent, _ = q.remove(0) // c, the current track
+	if !wasCurrent {
+		t.Error("remove of current track not reported")
+	}
+	if track, _ := q.current(); track != "b" {
+		t.Errorf("after removing current, current = %q, want b", track)
+	}
+	if _, err := q.remove(5); err == nil {
+		t.Error("remove out of range should fail")
+	}
+
+	q.set([]string{"1", "2", "3", "4", "5", "6"})
+	_ = q.jump(3)
+	q.setShuffle(true)
+	if track, _ := q.current(); track != "4" {
+		t.Errorf("shuffle changed current to %q, want 4", track)
+	}
+	seen := map[string]bool{}
+	for i := 0; i < 6; i++ {
+		track, ok := q.current()
+		if !ok {
+			t.Fatalf("shuffled queue ended after %d tracks", i)
+		}
+		seen[track] = true
+		q.next()
+	}
+	if len(seen) != 6 {
+		t.Errorf("shuffle played %d distinct tracks, want 6", len(seen))
+	}
+}
+
+func setupMediaDir(t *testing.T) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, f := range []string{"Albums/One/01 Intro.mp3", "Albums/One/02 Song.flac", "Albums/One/cover.jpg", "Albums/.hidden/x.mp3", "loose.wav"} {
+		p := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	playlist := "#EXTM3U\n#EXTINF:1,Intro\nAlbums/One/01 Intro.mp3\n\nhttp://radio.example/stream\n"
+	if err := os.WriteFile(filepath.Join(root, "mix.m3u"), []byte(playlist), 0644); err != nil {
+		t.Fatal(err)
+	}
+	useMediaDir(t, root)
+	return root
+}
+
+// useMediaDir sets the media directory for the duration of the test.
+func useMediaDir(t *testing.T, dir string) {
+	t.Helper()
+	prev := MediaDir()
+	SetMediaDir(dir)
+	t.Cleanup(func() { mediaDir.Store(prev) })
+}
+
+func TestBrowseMedia(t *testing.T) {
+	setupMediaDir(t)
+
+	items, err := browseMedia("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, it := range items {
+		got = append(got, it.Type+":"+it.ID)
+	}
+	want := []string{"folder:Albums", "track:loose.wav", "playlist:mix.m3u"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("browse root = %v, want %v", got, want)
+	}
+
+	items, err = browseMedia("Albums/One")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].ID != "Albums/One/01 Intro.mp3" {
+		t.Errorf("browse Albums/One = %+v", items)
+	}
+
+	if _, err := browseMedia("../etc"); !errors.Is(err, ErrInvalidMediaPath) {
+		t.Errorf("browse ../etc error = %v, want ErrInvalidMediaPath", err)
+	}
+	if _, err := browseMedia("missing"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("browse missing error = %v, want not exist", err)
+	}
+}
+
+func TestResolveTracks(t *testing.T) {
+	root := setupMediaDir(t)
+
+	tracks, err := resolveTracks("Albums")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0] != filepath.Join(root, "Albums/One/01 Intro.mp3") {
+		t.Errorf("resolve dir = %v", tracks)
+	}
+
+	tracks, err = resolveTracks("mix.m3u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tracks) != 2 || tracks[0] != filepath.Join(root, "Albums/One/01 Intro.mp3") || tracks[1] != "http://radio.example/stream" {
+		t.Errorf("resolve playlist = %v", tracks)
+	}
+
+	if tracks, _ := resolveTracks("http://x/a.mp3"); len(tracks) != 1 {
+		t.Errorf("resolve URL = %v", tracks)
+	}
+	if _, err := resolveTracks("Albums/../../secret"); !errors.Is(err, ErrInvalidMediaPath) {
+		t.Errorf("resolve escaping path error = %v", err)
+	}
+
+	// Absolute paths, file URLs and symlinks must stay inside the media dir.
+	outside := t.TempDir()
+	secret := filepath.Join(outside, "secret.mp3")
+	if err := os.WriteFile(secret, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "Linked")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(secret, filepath.Join(root, "Albums/One/03 Link.mp3")); err != nil {
+		t.Fatal(err)
+	}
+	bad := "/etc/passwd\n../" + filepath.Base(outside) + "/secret.mp3\nfile://" + secret + "\nAlbums/One/02 Song.flac\n"
+	if err := os.WriteFile(filepath.Join(root, "bad.m3u"), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/", "/etc/passwd", secret, root + "/../" + filepath.Base(outside), "Linked", "Linked/secret.mp3", "file://" + secret} {
+		if _, err := resolveTracks(path); !errors.Is(err, ErrInvalidMediaPath) {
+			t.Errorf("resolve %q error = %v, want ErrInvalidMediaPath", path, err)
+		}
+	}
+	if tracks, err := resolveTracks(filepath.Join(root, "loose.wav")); err != nil || len(tracks) != 1 {
+		t.Errorf("resolve absolute path inside = %v, %v", tracks, err)
+	}
+	if tracks, err := resolveTracks("Albums"); err != nil || len(tracks) != 2 {
+		t.Errorf("resolve dir with escaping symlink = %v, %v", tracks, err)
+	}
+	tracks, err = resolveTracks("bad.m3u")
+	if err != nil || len(tracks) != 1 || tracks[0] != filepath.Join(root, "Albums/One/02 Song.flac") {
+		t.Errorf("resolve playlist with escaping entries = %v, %v", tracks, err)
+	}
+}
+
+func TestFilePlayerStream_QueueCommands(t *testing.T) {
+	setupMediaDir(t)
+	ctx := context.Background()
+	s := NewFilePlayerStream("Music", "")
+
+	for _, cmd := range []string{"load=Albums", "add=loose.wav", "repeat=on", "jump=1", "move=2,0"} {
+		if err := s.SendCmd(ctx, cmd); err != nil {
+			t.Fatalf("SendCmd(%q): %v", cmd, err)
+		}
+	}
+	q := s.Queue()
+	want := []string{"loose.wav", "Albums/One/01 Intro.mp3", "Albums/One/02 Song.flac"}
+	if strings.Join(q.Tracks, "|") != strings.Join(want, "|") {
+		t.Errorf("tracks = %v, want %v", q.Tracks, want)
+	}
+	if q.Position != 2 || !q.Repeat || q.Shuffle {
+		t.Errorf("queue = %+v, want position 2 with repeat", q)
+	}
+
+	for _, bad := range []string{"jump=9", "remove=x", "move=1", "shuffle=maybe", "load=../x"} {
+		if err := s.SendCmd(ctx, bad); err == nil {
+			t.Errorf("SendCmd(%q) should fail", bad)
+		}
+	}
+	if err := s.SendCmd(ctx, "clear"); err != nil {
+		t.Fatal(err)
+	}
+	if q := s.Queue(); len(q.Tracks) != 0 || q.Position != -1 {
+		t.Errorf("after clear queue = %+v", q)
+	}
+}
+
+func TestManager_BrowseAndQueue(t *testing.T) {
+	setupMediaDir(t)
+	m := NewManager(t.TempDir(), nil)
+	ctx := context.Background()
+	modelStreams := []models.Stream{
+		{ID: 1, Name: "Files", Type: "file_player", Config: map[string]interface{}{"path": "Albums", "shuffle": true}},
+		{ID: 2, Name: "Input 1", Type: "rca"},
+	}
+	if err := m.Sync(ctx, modelStreams, []models.Source{{ID: 0}}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	items, err := m.Browse(ctx, 1, "Albums")
+	if err != nil || len(items) != 1 || items[0].Type != "folder" {
+		t.Errorf("Browse = %+v, %v", items, err)
+	}
+	if _, err := m.Browse(ctx, 2, ""); !errors.Is(err, ErrNotBrowsable) {
+		t.Errorf("Browse rca error = %v, want ErrNotBrowsable", err)
+	}
+	q, err := m.Queue(1)
+	if err != nil || !q.Shuffle {
+		t.Errorf("Queue = %+v, %v; want shuffle from config", q, err)
+	}
+	if _, err := m.Queue(2); !errors.Is(err, ErrNoQueue) {
+		t.Errorf("Queue rca error = %v, want ErrNoQueue", err)
+	}
+}
+
+// ─── Browsing Pandora / LMS / DLNA ──────────────────────────────────────────
+
+func TestPandoraStream_BrowseStations(t *testing.T) {
+	s := NewPandoraStream("Pandora", "u", "p", "", nil)
+	if _, err := s.Browse(context.Background(), ""); !errors.Is(err, ErrNotActive) {
+		t.Errorf("inactive browse err = %v, want ErrNotActive", err)
+	}
+
+	s.stationsPath = filepath.Join(t.TempDir(), "stations")
+	items, err := s.Browse(context.Background(), "")
+	if err != nil || len(items) != 0 {
+		t.Fatalf("before login: items=%v err=%v", items, err)
+	}
+	os.WriteFile(s.stationsPath, []byte("QuickMix\nJazz Radio\n"), 0644)
+	items, err = s.Browse(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []models.BrowsableItem{
+		{ID: "0", Name: "QuickMix", Type: "station"},
+		{ID: "1", Name: "Jazz Radio", Type: "station"},
+	}
+	if fmt.Sprint(items) != fmt.Sprint(want) {
+		t.Errorf("items = %v, want %v", items, want)
+	}
+	if _, err := s.Browse(context.Background(), "0"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("sub-path err = %v, want ErrNotExist", err)
+	}
+}
+
+func TestLMSStream_Browse(t *testing.T) {
+	var got [][]string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var cmd []string
+		json.Unmarshal(req.Params[1], &cmd)
+		got = append(got, cmd)
+		switch cmd[0] {
+		case "albums":
+			fmt.Fprint(w, `{"result":{"albums_loop":[{"id":7,"album":"Kind of Blue","artwork_track_id":"a1b2"}]}}`)
+		case "titles":
+			fmt.Fprint(w, `{"result":{"titles_loop":[{"id":"70","title":"So What"}]}}`)
+		case "favorites":
+			fmt.Fprint(w, `{"result":{"loop_loop":[{"id":"f1.0","name":"Radio","isaudio":1,"hasitems":0},{"id":"f1.1","name":"More","isaudio":0,"hasitems":1}]}}`)
+		default:
+			fmt.Fprint(w, `{"result":{}}`)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	s := NewLMSStream("Kitchen", host, nil)
+	ctx := context.Background()
+
+	items, err := s.Browse(ctx, "")
+	if err != nil || len(items) != len(lmsMenus) {
+		t.Fatalf("top level: %v %v", items, err)
+	}
+
+	items, err = s.Browse(ctx, "artists/3")
+	if err != nil || len(items) != 1 {
+		t.Fatalf("artist albums: %v %v", items, err)
+	}
+	if items[0].ID != "albums/7" || items[0].Type != "album" || items[0].Thumbnail != "http://"+host+"/music/a1b2/cover.jpg" {
+		t.Errorf("album item = %+v", items[0])
+	}
+	if !slices.Contains(got[0], "artist_id:3") {
+		t.Errorf("albums query = %v", got[0])
+	}
+
+	items, err = s.Browse(ctx, "albums/7")
+	if err != nil || len(items) != 1 || items[0].ID != "tracks/70" || items[0].Type != "track" {
+		t.Errorf("album tracks: %v %v", items, err)
+	}
+
+	items, err = s.Browse(ctx, "favorites")
+	if err != nil || len(items) != 2 || items[0].Type != "station" || items[1].Type != "folder" || items[1].ID != "favorites/f1.1" {
+		t.Errorf("favorites: %v %v", items, err)
+	}
+
+	if _, err := s.Browse(ctx, "bogus"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unknown menu err = %v", err)
+	}
+
+	got = nil
+	if err := s.SendCmd(ctx, "play=albums/7"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendCmd(ctx, "next"); err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"playlistcontrol", "cmd:load", "album_id:7"}, {"playlist", "index", "+1"}}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("commands = %v, want %v", got, want)
+	}
+	if err := s.SendCmd(ctx, "play=albums"); err == nil {
+		t.Error("play of a menu should fail")
+	}
+}
+
+func TestParseDIDL(t *testing.T) {
+	doc := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">
+<container id="64$1" parentID="64"><dc:title>Albums</dc:title><upnp:class>object.container.storageFolder</upnp:class></container>
+<item id="64$2" parentID="64"><dc:title>Track &amp; One</dc:title><upnp:class>object.item.audioItem.musicTrack</upnp:class><upnp:albumArtURI>http://nas/art.jpg</upnp:albumArtURI><res protocolInfo="http-get:*:audio/mpeg:*">http://nas/t1.mp3</res></item>
+</DIDL-Lite>`
+	objs, err := parseDIDL(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []didlObject{
+		{ID: "64$1", Title: "Albums", Container: true, Class: "object.container.storageFolder"},
+		{ID: "64$2", Title: "Track & One", Class: "object.item.audioItem.musicTrack", Res: "http://nas/t1.mp3", AlbumArt: "http://nas/art.jpg"},
+	}
+	if !slices.Equal(objs, want) {
+		t.Errorf("objects = %+v", objs)
+	}
+}
+
+func TestDLNAStream_BrowseAndPlay(t *testing.T) {
+	didl := `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:upnp="urn:schemas-upnp-org:metadata-1-0/upnp/">` +
+		`<container id="1/music"><dc:title>Music</dc:title><upnp:class>object.container</upnp:class></container>` +
+		`<item id="1/a.mp3"><dc:title>A</dc:title><upnp:class>object.item.audioItem.musicTrack</upnp:class><res>http://nas/a.mp3</res></item>` +
+		`<item id="1/v.mp4"><dc:title>V</dc:title><upnp:class>object.item.videoItem</upnp:class><res>http://nas/v.mp4</res></item>` +
+		`</DIDL-Lite>`
+	var actions []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/server.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<root><device><UDN>uuid:nas-1</UDN><friendlyName>NAS</friendlyName><serviceList>
+<service><serviceType>urn:schemas-upnp-org:service:ContentDirectory:1</serviceType><controlURL>/cd</controlURL></service>
+</serviceList></device></root>`)
+	})
+	mux.HandleFunc("/renderer.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<root><device><UDN>uuid:renderer-1</UDN><friendlyName>AmpliPi</friendlyName><serviceList>
+<service><serviceType>urn:schemas-upnp-org:service:AVTransport:1</serviceType><controlURL>/upnp/control/rendertransport1</controlURL></service>
+</serviceList></device></root>`)
+	})
+	mux.HandleFunc("/cd", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		doc := didl
+		if strings.Contains(string(body), "BrowseMetadata") {
+			doc = `<DIDL-Lite xmlns="urn:schemas-upnp-org:metadata-1-0/DIDL-Lite/" xmlns:dc="http://purl.org/dc/elements/1.1/"><item id="1/a.mp3"><dc:title>A</dc:title><res>http://nas/a.mp3</res></item></DIDL-Lite>`
+		}
+		fmt.Fprintf(w, `<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><u:BrowseResponse xmlns:u="urn:schemas-upnp-org:service:ContentDirectory:1"><Result>%s</Result></u:BrowseResponse></s:Body></s:Envelope>`, html.EscapeString(doc))
+	})
+	mux.HandleFunc("/upnp/control/rendertransport1", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		action := r.Header.Get("SOAPAction")
+		if strings.Contains(action, "SetAVTransportURI") && !strings.Contains(string(body), "<CurrentURI>http://nas/a.mp3</CurrentURI>") {
+			t.Errorf("SetAVTransportURI body = %s", body)
+		}
+		actions = append(actions, action)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	orig := ssdpSearch
+	ssdpSearch = func(_ context.Context, target string, _ time.Duration) ([]string, error) {
+		if target == upnpMediaServer {
+			return []string{srv.URL + "/server.xml", srv.URL + "/renderer.xml"}, nil
+		}
+		return []string{srv.URL + "/renderer.xml"}, nil
+	}
+	t.Cleanup(func() { ssdpSearch = orig })
+
+	s := NewDLNAStream("AmpliPi")
+	ctx := context.Background()
+
+	items, err := s.Browse(ctx, "")
+	if err != nil || len(items) != 1 || items[0].ID != "nas-1" || items[0].Name != "NAS" {
+		t.Fatalf("servers: %v %v", items, err)
+	}
+	items, err = s.Browse(ctx, "nas-1")
+	if err != nil || len(items) != 2 {
+		t.Fatalf("root: %v %v", items, err)
+	}
+	if items[0].Type != "folder" || items[1].Type != "track" || items[1].ID != dlnaItemID("nas-1", "1/a.mp3") {
+		t.Errorf("root items = %+v", items)
+	}
+	sub, err := s.Browse(ctx, items[0].ID)
+	if err != nil || len(sub) != 2 {
+		t.Errorf("folder: %v %v", sub, err)
+	}
+	if _, err := s.Browse(ctx, "unknown-server"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("unknown server err = %v", err)
+	}
+
+	if err := s.SendCmd(ctx, "play="+items[1].ID); !errors.Is(err, ErrNotActive) {
+		t.Errorf("play while inactive err = %v", err)
+	}
+	s.uuid = "renderer-1"
+	if err := s.SendCmd(ctx, "play="+items[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`"urn:schemas-upnp-org:service:AVTransport:1#SetAVTransportURI"`,
+		`"urn:schemas-upnp-org:service:AVTransport:1#Play"`,
+	}
+	if !slices.Equal(actions, want) {
+		t.Errorf("actions = %v", actions)
+	}
+}
+
+func TestSetSourceProcessing_ReportsChanges(t *testing.T) {
+	t.Cleanup(func() { setSourceProcessing(nil) })
+	mono := &models.AudioProcessing{Mono: true}
+	sources := []models.Source{{ID: 0, Processing: mono}, {ID: 1}}
+
+	if changed := setSourceProcessing(sources); !changed[0] || changed[1] {
+		t.Errorf("first update changed = %v", changed)
+	}
+	if changed := setSourceProcessing(sources); len(changed) != 0 {
+		t.Errorf("repeat update changed = %v", changed)
+	}
+	if !processingFor(0).Mono || !processingFor(1).IsDefault() {
+		t.Error("processing not recorded")
+	}
+	sources[0].Processing = &models.AudioProcessing{}
+	if changed := setSourceProcessing(sources); !changed[0] {
+		t.Errorf("reset changed = %v", changed)
+	}
+}
+
+func TestDuckSource(t *testing.T) {
+	useFakeBinaries(t)
+	log := filepath.Join(t.TempDir(), "amixer.log")
+	t.Setenv("FAKEBIN_AMIXER_LOG", log)
+
+	a, err := NewALSALoop(0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := a.sup.buildCmd().Args
+	if got := args[slices.Index(args, "-P")+1]; got != "ch0_duck" {
+		t.Errorf("alsaloop playback = %q, want the duck stage", got)
+	}
+
+	SetAvailablePhysicalOutputs([]int{0, 1, 2, 3})
+	t.Cleanup(func() { SetAvailablePhysicalOutputs([]int{0}) })
+	m := NewManager(t.TempDir(), nil)
+	ctx := context.Background()
+	m.DuckSource(ctx, 2, 0.1)
+	m.DuckSource(ctx, 2, 1)
+	out, err := os.ReadFile(log)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "-q -c cmedia8chint cset name=Ch2 Duck 80\n-q -c cmedia8chint cset name=Ch2 Duck 120\n"
+	if string(out) != want {
+		t.Errorf("amixer calls:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+// ─── Recovery ────────────────────────────────────────────────────────────────
+
+// fakeStreamer is a persistent streamer whose supervisor state is set by
+// the test.
+type fakeStreamer struct {
+	activations int
+	connects    int
+	failed      bool
+}
+
+func (f *fakeStreamer) Activate(ctx context.Context, vsrc int, configDir string) error {
+	f.activations++
+	f.failed = false
+	return nil
+}
+func (f *fakeStreamer) Deactivate(ctx context.Context) error           { return nil }
+func (f *fakeStreamer) Connect(ctx context.Context, physSrc int) error { f.connects++; return nil }
+func (f *fakeStreamer) Disconnect(ctx context.Context) error           { return nil }
+func (f *fakeStreamer) SendCmd(ctx context.Context, cmd string) error  { return nil }
+func (f *fakeStreamer) IsPersistent() bool                             { return true }
+func (f *fakeStreamer) Type() string                                   { return "fake" }
+func (f *fakeStreamer) Info() models.StreamInfo {
+	state := models.SupervisorRunning
+	if f.failed {
+		state = models.SupervisorFailed
+	}
+	return models.StreamInfo{Supervisor: &models.SupervisorStatus{State: state}}
+}
+
+func TestManager_ReconnectOutputs(t *testing.T) {
+	SetAvailablePhysicalOutputs([]int{0, 1})
+	t.Cleanup(func() { SetAvailablePhysicalOutputs([]int{0}) })
+	m := NewManager(t.TempDir(), nil)
+	fakes := make([]*fakeStreamer, 3)
+	for i := range fakes {
+		fakes[i] = &fakeStreamer{}
+		m.streams[i] = &StreamState{Streamer: fakes[i], StreamID: i, Name: "fake", VSRC: -1, PhysSrc: i, Active: true}
+	}
+	connects := func() []int {
+		n := make([]int, len(fakes))
+		for i, f := range fakes {
+			n[i], f.connects = f.connects, 0
+		}
+		return n
+	}
+	ctx := context.Background()
+
+	// Editing ch1 only reconnects the stream playing to it.
+	m.ReconnectOutputs(ctx, []int{0, 1}, []int{1})
+	if got := connects(); !slices.Equal(got, []int{0, 1, 0}) {
+		t.Errorf("after editing ch1: connects = %v", got)
+	}
+	// Plugging in ch2 moves source 2 off ch0.
+	m.ReconnectOutputs(ctx, []int{0, 1, 2}, []int{2})
+	if got := connects(); !slices.Equal(got, []int{0, 0, 1}) {
+		t.Errorf("after plugging in ch2: connects = %v", got)
+	}
+	// Unplugging ch1 moves source 1 to ch0.
+	m.ReconnectOutputs(ctx, []int{0, 2}, []int{1})
+	if got := connects(); !slices.Equal(got, []int{0, 1, 0}) {
+		t.Errorf("after unplugging ch1: connects = %v", got)
+	}
+	// In mock mode the available outputs are left alone.
+	m.ReconnectOutputs(ctx, nil, nil)
+	if got := connects(); !slices.Equal(got, []int{0, 0, 0}) || !isPhysicalOutputAvailable(2) {
+		t.Errorf("with no changes: connects = %v", got)
+	}
+}
+
+func TestManager_RecoverFailed(t *testing.T) {
+	m := NewManager(t.TempDir(), nil)
+	f := &fakeStreamer{}
+	m.streams[1] = &StreamState{Streamer: f, StreamID: 1, Name: "fake", VSRC: -1, PhysSrc: 2, Active: true}
+	ctx := context.Background()
+	now := time.Now()
+
+	m.recoverFailed(ctx, now)
+	if len(m.retries) != 0 {
+		t.Fatal("healthy stream scheduled for retry")
+	}
+
+	f.failed = true
+	m.recoverFailed(ctx, now) // noticed; first retry after the minimum backoff
+	m.recoverFailed(ctx, now.Add(recoveryMinBackoff-time.Second))
+	if f.activations != 0 {
+		t.Fatalf("retried before backoff: %d activations", f.activations)
+	}
+	m.recoverFailed(ctx, now.Add(recoveryMinBackoff))
+	if f.activations != 1 || m.streams[1].PhysSrc != 2 || !m.streams[1].Active {
+		t.Fatalf("after retry: activations %d, state %+v", f.activations, m.streams[1])
+	}
+
+	// Running for a while and failing again: the next retry waits twice
+	// as long.
+	m.recoverFailed(ctx, now.Add(recoveryMinBackoff+time.Second))
+	f.failed = true
+	m.recoverFailed(ctx, now.Add(recoveryMinBackoff+2*time.Second))
+	m.recoverFailed(ctx, now.Add(recoveryMinBackoff+recoveryBackoff(1)-time.Second))
+	if f.activations != 1 {
+		t.Fatal("second retry came too early")
+	}
+	m.recoverFailed(ctx, now.Add(recoveryMinBackoff+recoveryBackoff(1)))
+	if f.activations != 2 {
+		t.Fatal("second retry did not happen")
+	}
+	if recoveryBackoff(1) != 2*recoveryMinBackoff || recoveryBackoff(20) != recoveryMaxBackoff {
+		t.Errorf("backoff(1) = %v, backoff(20) = %v", recoveryBackoff(1), recoveryBackoff(20))
+	}
+
+	// Staying up resets the backoff.
+	later := now.Add(recoveryMinBackoff + recoveryBackoff(1) + time.Second)
+	m.recoverFailed(ctx, later)
+	if m.retries[1] == nil {
+		t.Fatal("backoff reset as soon as the stream was up")
+	}
+	m.recoverFailed(ctx, later.Add(recoveryHealthy))
+	if m.retries[1] != nil {
+		t.Errorf("backoff kept after the stream stayed up: %+v", m.retries[1])
+	}
+
+	// Manual restart clears the backoff.
+	if err := m.Restart(ctx, 1); err != nil {
+		t.Fatalf("Restart: %v", err)
+	}
+	if f.activations != 3 || m.retries[1] != nil {
+		t.Errorf("after Restart: activations %d, retry %+v", f.activations, m.retries[1])
+	}
+	if err := m.Restart(ctx, 9); err == nil {
+		t.Error("Restart of unknown stream succeeded")
+	}
+}
+
+// ─── Concurrent Sync ─────────────────────────────────────────────────────────
+
+// slowStreamer takes delay to activate, like a stream writing its config
+// and starting a process, and records how many activations overlap.
+type slowStreamer struct {
+	delay     time.Duration
+	inFlight  *atomic.Int32
+	maxFlight *atomic.Int32
+}
+
+func (s *slowStreamer) Activate(ctx context.Context, vsrc int, configDir string) error {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		m := s.maxFlight.Load()
+		if n <= m || s.maxFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+	time.Sleep(s.delay)
+	return nil
+}
+func (s *slowStreamer) Deactivate(ctx context.Context) error           { return nil }
+func (s *slowStreamer) Connect(ctx context.Context, physSrc int) error { return nil }
+func (s *slowStreamer) Disconnect(ctx context.Context) error           { return nil }
+func (s *slowStreamer) SendCmd(ctx context.Context, cmd string) error  { return nil }
+func (s *slowStreamer) Info() models.StreamInfo                        { return models.StreamInfo{} }
+func (s *slowStreamer) IsPersistent() bool                             { return false }
+func (s *slowStreamer) Type() string                                   { return "slow" }
+
+// newSlowManager returns a manager with n slow streams (IDs 1..n) plus the
+// model streams and sources that connect stream i to source i-1.
+func newSlowManager(dir string, n int, delay time.Duration) (*Manager, []models.Stream, []models.Source, *atomic.Int32) {
+	m := NewManager(dir, nil)
+	var inFlight, maxFlight atomic.Int32
+	var model []models.Stream
+	var sources []models.Source
+	for i := 1; i <= n; i++ {
+		m.streams[i] = &StreamState{
+			Streamer: &slowStreamer{delay: delay, inFlight: &inFlight, maxFlight: &maxFlight},
+			StreamID: i, VSRC: -1, PhysSrc: -1,
+		}
+		model = append(model, models.Stream{ID: i, Name: fmt.Sprintf("slow %d", i), Type: "slow"})
+		sources = append(sources, models.Source{ID: i - 1, Input: fmt.Sprintf("stream=%d", i)})
+	}
+	return m, model, sources, &maxFlight
+}
+
+func TestManagerSync_ConnectsConcurrently(t *testing.T) {
+	m, model, sources, maxFlight := newSlowManager(t.TempDir(), 4, 50*time.Millisecond)
+	ctx := context.Background()
+
+	if err := m.Sync(ctx, model, sources); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := maxFlight.Load(); got < 2 {
+		t.Errorf("at most %d activation(s) in flight, want concurrent activation", got)
+	}
+	vsrcs := make(map[int]bool)
+	for id, state := range m.streams {
+		if !state.Active || state.PhysSrc != id-1 || state.VSRC < 0 || vsrcs[state.VSRC] {
+			t.Errorf("stream %d: %+v", id, state)
+		}
+		vsrcs[state.VSRC] = true
+	}
+
+	// Disconnecting deactivates the non-persistent streams and frees their vsrcs.
+	if err := m.Sync(ctx, model, nil); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	for id, state := range m.streams {
+		if state.Active || state.PhysSrc != -1 || state.VSRC != -1 {
+			t.Errorf("stream %d after disconnect: %+v", id, state)
+		}
+	}
+	if v, err := m.vsources.Alloc(); err != nil || v != 0 {
+		t.Errorf("vsrcs not freed: Alloc() = %d, %v", v, err)
+	}
+}
+
+// BenchmarkManagerSync_PresetLoad measures connecting four streams that
+// each take 20ms to start, as when loading a preset, then stopping them.
+func BenchmarkManagerSync_PresetLoad(b *testing.B) {
+	m, model, sources, _ := newSlowManager(b.TempDir(), 4, 20*time.Millisecond)
+	ctx := context.Background()
+	for b.Loop() {
+		_ = m.Sync(ctx, model, sources)
+		_ = m.Sync(ctx, model, nil)
+	}
+}
+
+// ─── Level metering ──────────────────────────────────────────────────────────
+
+func TestPeakS16(t *testing.T) {
+	samples := func(vals ...int16) io.Reader {
+		var b strings.Builder
+		for _, v := range vals {
+			b.WriteByte(byte(uint16(v)))
+			b.WriteByte(byte(uint16(v) >> 8))
+		}
+		return strings.NewReader(b.String())
+	}
+
+	if got, err := peakS16(samples(0, 0, 0, 0)); err != nil || !math.IsInf(got, -1) {
+		t.Errorf("silence: %v, %v; want -Inf", got, err)
+	}
+	if got, err := peakS16(samples(100, -16384, 2000, 0)); err != nil || math.Abs(got-(-6.02)) > 0.01 {
+		t.Errorf("half scale: %v, %v; want -6.02 dBFS", got, err)
+	}
+	if got, err := peakS16(samples(-32768)); err != nil || got != 0 {
+		t.Errorf("full scale: %v, %v; want 0 dBFS", got, err)
+	}
+}
+
+// ─── End to end with fake binaries ───────────────────────────────────────────
+
+// useFakeBinaries builds testdata/fakebin and has streams run it in place
+// of the real binaries for the rest of the test.
+func useFakeBinaries(t *testing.T) {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the fake binaries")
+	}
+	gobin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go not in PATH")
+	}
+	dir := t.TempDir()
+	if out, err := exec.Command(gobin, "build", "-o", filepath.Join(dir, FakeBinary), "./testdata/fakebin").CombinedOutput(); err != nil {
+		t.Fatalf("building fakebin: %v: %s", err, out)
+	}
+	t.Setenv("PATH", os.Getenv("PATH"))
+	t.Setenv("FAKEBIN_PLAY_SECONDS", "0.2")
+	if err := UseTestBinaries(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitInfo polls stream id's info until ok accepts it.
+func waitInfo(t *testing.T, m *Manager, id int, what string, ok func(models.StreamInfo) bool) models.StreamInfo {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		info := m.Info(id)
+		if info != nil && ok(*info) {
+			return *info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("stream %d: no %s; info %+v", id, what, info)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestFakeBinaries_EndToEnd(t *testing.T) {
+	useFakeBinaries(t)
+	defer func(d time.Duration) { spotifyPollInterval = d }(spotifyPollInterval)
+	spotifyPollInterval = 100 * time.Millisecond
+
+	music := t.TempDir()
+	for _, name := range []string{"a.mp3", "b.mp3"} {
+		if err := os.WriteFile(filepath.Join(music, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	useMediaDir(t, music)
+
+	m := NewManager(t.TempDir(), nil)
+	ctx := context.Background()
+	modelStreams := []models.Stream{
+		{ID: 1000, Name: "Pandora", Type: "pandora",
+			Config: map[string]interface{}{"user": "fake@example.com", "password": "secret", "station": "1"}},
+		{ID: 1001, Name: "Radio", Type: "internet_radio",
+			Config: map[string]interface{}{"url": "http://radio.example.com/stream"}},
+		{ID: 1002, Name: "Spotify", Type: "spotify_connect"},
+		{ID: 1003, Name: "Files", Type: "file_player",
+			Config: map[string]interface{}{"path": music}},
+	}
+	sources := []models.Source{
+		{ID: 0, Input: "stream=1000"}, {ID: 1, Input: "stream=1001"},
+		{ID: 2, Input: "stream=1002"}, {ID: 3, Input: "stream=1003"},
+	}
+	if err := m.Sync(ctx, modelStreams, sources); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	t.Cleanup(func() { m.Sync(ctx, nil, nil) })
+
+	waitInfo(t, m, 1001, "running vlc", func(i models.StreamInfo) bool {
+		return i.Supervisor != nil && i.Supervisor.State == "running"
+	})
+
+	// The file player plays its directory through, one vlc per track.
+	waitInfo(t, m, 1003, "second track", func(i models.StreamInfo) bool { return i.Track == "b" })
+	waitInfo(t, m, 1003, "end of queue", func(i models.StreamInfo) bool { return i.State == "stopped" })
+
+	// Pandora: the song pianobar reports at start, then on the initial
+	// station command and a skip.
+	waitInfo(t, m, 1000, "station song", func(i models.StreamInfo) bool {
+		return i.Track == "Fake Song 1" && i.Station == "Test Station"
+	})
+	if err := m.SendCmd(ctx, 1000, "next"); err != nil {
+		t.Fatalf("pandora next: %v", err)
+	}
+	waitInfo(t, m, 1000, "next song", func(i models.StreamInfo) bool { return i.Track == "Fake Song 2" })
+	stations, err := m.Browse(ctx, 1000, "")
+	if err != nil || len(stations) != 2 || stations[1].Name != "Test Station" {
+		t.Errorf("pandora stations: %+v, %v", stations, err)
+	}
+
+	// Spotify: go-librespot's status, and a skip through its player API.
+	info := waitInfo(t, m, 1002, "track", func(i models.StreamInfo) bool { return i.Track == "Fake Track 1" })
+	if info.State != "playing" || info.Pairing == nil || info.Pairing.User != "fake-user" {
+		t.Errorf("spotify info: %+v", info)
+	}
+	if err := m.SendCmd(ctx, 1002, "next"); err != nil {
+		t.Fatalf("spotify next: %v", err)
+	}
+	waitInfo(t, m, 1002, "next track", func(i models.StreamInfo) bool { return i.Track == "Fake Track 2" })
+
+	// Announcements and metering go through ffmpeg and raop_play.
+	if err := m.MixAnnounce(ctx, 0, "/announce.mp3", 0.5); err != nil {
+		t.Errorf("MixAnnounce: %v", err)
+	}
+	if err := AirPlayAnnounce(ctx, "192.0.2.1", "/announce.mp3", 50, time.Second); err != nil {
+		t.Errorf("AirPlayAnnounce: %v", err)
+	}
+	peak, err := m.SourceLevel(ctx, 1, 100*time.Millisecond)
+	if err != nil || math.Abs(peak-(-20)) > 0.1 {
+		t.Errorf("SourceLevel = %v, %v; want -20 dBFS", peak, err)
+	}
+}
+
+func TestProbeMedia(t *testing.T) {
+	useFakeBinaries(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chime.mp3":
+			w.Header().Set("Content-Type", "audio/mpeg")
+		case "/nohead.mp3":
+			if r.Method == http.MethodHead {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			w.Header().Set("Content-Type", "audio/mpeg")
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	for _, name := range []string{"chime.wav", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx := context.Background()
+	for _, media := range []string{srv.URL + "/chime.mp3", srv.URL + "/nohead.mp3", filepath.Join(dir, "chime.wav")} {
+		if err := ProbeMedia(ctx, media); err != nil {
+			t.Errorf("ProbeMedia(%s): %v", media, err)
+		}
+	}
+	for media, reason := range map[string]string{
+		srv.URL + "/missing.mp3":          "404",
+		srv.URL + "/page":                 "text/html",
+		"ftp://example.com/chime.mp3":     "unsupported URL scheme",
+		"http://127.0.0.1:1/chime.mp3":    "unreachable",
+		filepath.Join(dir, "missing.mp3"): "does not exist",
+		dir:                               "not a file",
+		filepath.Join(dir, "notes.txt"):   "Invalid data",
+	} {
+		if err := ProbeMedia(ctx, media); err == nil || !strings.Contains(err.Error(), reason) {
+			t.Errorf("ProbeMedia(%s) = %v, want error with %q", media, err, reason)
+		}
+	}
+}
+
+func TestDemoStreams(t *testing.T) {
+	useFakeBinaries(t)
+	t.Setenv("FAKEBIN_PLAY_SECONDS", "60")
+	SetDemoAudio(DemoTone)
+	t.Cleanup(func() { SetDemoAudio("") })
+	defer func(d time.Duration) { demoTrackLength = d }(demoTrackLength)
+	demoTrackLength = 300 * time.Millisecond
+
+	var reports atomic.Int32
+	m := NewManager(t.TempDir(), func(id int, info models.StreamInfo) { reports.Add(1) })
+	ctx := context.Background()
+	modelStreams := []models.Stream{{ID: 1100, Name: "Pandora", Type: "pandora"}}
+	if err := m.Sync(ctx, modelStreams, []models.Source{{ID: 2, Input: "stream=1100"}}); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	t.Cleanup(func() { m.Sync(ctx, nil, nil) })
+
+	info := waitInfo(t, m, 1100, "demo player", func(i models.StreamInfo) bool {
+		return i.Supervisor != nil && i.Supervisor.State == "running"
+	})
+	if info.State != "playing" || info.Album != "pandora" || !strings.HasPrefix(info.Track, "Demo Track") {
+		t.Errorf("demo info: %+v", info)
+	}
+	waitInfo(t, m, 1100, "next track", func(i models.StreamInfo) bool { return i.Track == "Demo Track 2" })
+
+	if err := m.SendCmd(ctx, 1100, "pause"); err != nil {
+		t.Fatal(err)
+	}
+	if info := m.Info(1100); info.State != "paused" || info.Supervisor != nil {
+		t.Errorf("paused: %+v", info)
+	}
+	time.Sleep(2 * demoTrackLength)
+	if err := m.SendCmd(ctx, 1100, "next"); err != nil {
+		t.Fatal(err)
+	}
+	if info := m.Info(1100); info.Track != "Demo Track 3" {
+		t.Errorf("next while paused: %+v; paused time counted as playing", info)
+	}
+	if err := m.SendCmd(ctx, 1100, "play"); err != nil {
+		t.Fatal(err)
+	}
+	waitInfo(t, m, 1100, "demo player again", func(i models.StreamInfo) bool {
+		return i.State == "playing" && i.Supervisor != nil && i.Supervisor.State == "running"
+	})
+	if reports.Load() == 0 {
+		t.Error("no info reported")
+	}
+}
@@ -3,12 +3,16 @@ package streams
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
 	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/models"
 )
 
 const (
@@ -30,14 +34,28 @@ type Supervisor struct {
 	fastFailSec float64
 	maxBackoff  time.Duration
 
+	// logDir receives <binary>.log with the process's stdout and stderr;
+	// empty discards output.
+	logDir string
+
+	// onStatus, if set, is called without mu held whenever the process
+	// starts, exits or is given up on.
+	onStatus func(models.SupervisorStatus)
+
+	// beforeWait, if set, is called once the process has started and must
+	// return before it is waited for, e.g. when reads from a pipe of the
+	// process complete.
+	beforeWait func()
+
 	// Internal state (protected by mu)
-	mu           sync.Mutex
-	currentPID   int
-	backoff      time.Duration
-	failCount    int
-	stopCh       chan struct{}
-	doneCh       chan struct{}
-	running      bool
+	mu         sync.Mutex
+	status     models.SupervisorStatus
+	currentPID int
+	backoff    time.Duration
+	failCount  int
+	stopCh     chan struct{}
+	doneCh     chan struct{}
+	running    bool
 }
 
 // NewSupervisor creates a Supervisor with sensible defaults.
@@ -65,6 +83,7 @@ func (s *Supervisor) Start(ctx context.Context) error {
 	s.doneCh = make(chan struct{})
 	s.failCount = 0
 	s.backoff = 500 * time.Millisecond
+	s.status = models.SupervisorStatus{State: models.SupervisorStopped}
 	s.running = true
 	go s.supervise(ctx)
 	return nil
@@ -92,6 +111,46 @@ func (s *Supervisor) Stop() error {
 	return nil
 }
 
+// setLogDir captures the process's output into logDir from the next Start.
+func (s *Supervisor) setLogDir(dir string) {
+	s.mu.Lock()
+	s.logDir = dir
+	s.mu.Unlock()
+}
+
+// setWaitHook sets the function called before each process is waited for.
+func (s *Supervisor) setWaitHook(fn func()) {
+	s.mu.Lock()
+	s.beforeWait = fn
+	s.mu.Unlock()
+}
+
+// setStatusHook sets the function called on every status change.
+func (s *Supervisor) setStatusHook(fn func(models.SupervisorStatus)) {
+	s.mu.Lock()
+	s.onStatus = fn
+	s.mu.Unlock()
+}
+
+// Status returns the state of the supervised process.
+func (s *Supervisor) Status() models.SupervisorStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.status
+}
+
+// setStatus records the process state and reports it to onStatus.
+func (s *Supervisor) setStatus(state, reason string) {
+	s.mu.Lock()
+	s.status.State = state
+	s.status.Reason = reason
+	st, hook := s.status, s.onStatus
+	s.mu.Unlock()
+	if hook != nil {
+		hook(st)
+	}
+}
+
 // Pid returns the current process PID, or 0 if not running.
 func (s *Supervisor) Pid() int {
 	s.mu.Lock()
@@ -102,15 +161,24 @@ func (s *Supervisor) Pid() int {
 // supervise runs in a goroutine. It starts the process, waits for it to exit,
 // then decides whether to restart.
 func (s *Supervisor) supervise(ctx context.Context) {
+	var out *procLog
 	defer func() {
+		if out != nil {
+			out.Close()
+		}
 		s.mu.Lock()
 		s.running = false
 		s.currentPID = 0
+		if s.status.State != models.SupervisorFailed {
+			s.status.State = models.SupervisorStopped
+		}
 		doneCh := s.doneCh
 		s.mu.Unlock()
 		close(doneCh)
 	}()
 
+	lastReason := ""
+	started := false
 	for {
 		// Check if we should stop
 		select {
@@ -125,7 +193,9 @@ func (s *Supervisor) supervise(ctx context.Context) {
 		s.mu.Lock()
 		if s.failCount >= s.maxFails {
 			slog.Error("supervisor giving up after too many fast-fails", "name", s.name, "fails", s.failCount)
+			fails := s.failCount
 			s.mu.Unlock()
+			s.setStatus(models.SupervisorFailed, fmt.Sprintf("gave up after %d quick failures; last: %s", fails, lastReason))
 			return
 		}
 		s.mu.Unlock()
@@ -134,20 +204,46 @@ func (s *Supervisor) supervise(ctx context.Context) {
 		cmd := s.buildCmd()
 		if cmd == nil {
 			slog.Error("supervisor: buildCmd returned nil", "name", s.name)
+			s.setStatus(models.SupervisorFailed, "no command to run")
 			return
 		}
+		s.mu.Lock()
+		s.status.Process = filepath.Base(cmd.Path)
+		s.mu.Unlock()
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		if out == nil {
+			out = s.openLog(cmd)
+		}
+		if out != nil {
+			if cmd.Stdout == nil {
+				cmd.Stdout = out
+			}
+			if cmd.Stderr == nil {
+				cmd.Stderr = out
+			}
+			// Don't let a grandchild holding the pipe open block Wait.
+			if cmd.WaitDelay == 0 {
+				cmd.WaitDelay = sigtermTimeout
+			}
+			out.Printf("starting %s", strings.Join(cmd.Args, " "))
+		}
 
 		startTime := time.Now()
 		slog.Info("supervisor: starting process", "name", s.name, "cmd", cmd.Path)
 
 		if err := cmd.Start(); err != nil {
+			if out != nil {
+				out.Printf("failed to start: %v", err)
+			}
 			// Binary not found is permanent — no point retrying
 			if errors.Is(err, exec.ErrNotFound) || isNotFoundError(err) {
 				slog.Error("supervisor: binary not found, giving up", "name", s.name, "cmd", cmd.Path, "err", err)
+				s.setStatus(models.SupervisorFailed, "binary not found")
 				return
 			}
 			slog.Error("supervisor: failed to start process", "name", s.name, "err", err)
+			lastReason = "failed to start: " + err.Error()
+			s.setStatus(models.SupervisorRestarting, lastReason)
 			// Count as a fast-fail
 			s.mu.Lock()
 			s.failCount++
@@ -162,13 +258,24 @@ func (s *Supervisor) supervise(ctx context.Context) {
 		pid := cmd.Process.Pid
 		s.mu.Lock()
 		s.currentPID = pid
+		if started {
+			s.status.Restarts++
+		}
 		s.mu.Unlock()
+		started = true
+		s.setStatus(models.SupervisorRunning, "")
 
 		slog.Info("supervisor: process running", "name", s.name, "pid", pid)
 
 		// Wait for process to exit in a goroutine so we can also watch stopCh/ctx
 		exitCh := make(chan error, 1)
+		s.mu.Lock()
+		beforeWait := s.beforeWait
+		s.mu.Unlock()
 		go func() {
+			if beforeWait != nil {
+				beforeWait()
+			}
 			exitCh <- cmd.Wait()
 		}()
 
@@ -187,6 +294,19 @@ func (s *Supervisor) supervise(ctx context.Context) {
 
 		elapsed := time.Since(startTime)
 		slog.Info("supervisor: process exited", "name", s.name, "pid", pid, "elapsed", elapsed, "err", exitErr)
+		if out != nil {
+			if exitErr != nil {
+				out.Printf("exited after %s: %v", elapsed.Round(time.Millisecond), exitErr)
+			} else {
+				out.Printf("exited after %s", elapsed.Round(time.Millisecond))
+			}
+		}
+
+		lastReason = "exited"
+		if exitErr != nil {
+			lastReason = "exited: " + exitErr.Error()
+		}
+		s.setStatus(models.SupervisorRestarting, lastReason)
 
 		s.mu.Lock()
 		s.currentPID = 0
@@ -213,6 +333,23 @@ func (s *Supervisor) supervise(ctx context.Context) {
 	}
 }
 
+// openLog opens <logDir>/<binary>.log for cmd, or returns nil if output
+// is not captured.
+func (s *Supervisor) openLog(cmd *exec.Cmd) *procLog {
+	s.mu.Lock()
+	dir := s.logDir
+	s.mu.Unlock()
+	if dir == "" {
+		return nil
+	}
+	l, err := openProcLog(filepath.Join(dir, filepath.Base(cmd.Path)+".log"))
+	if err != nil {
+		slog.Warn("supervisor: cannot open process log", "name", s.name, "err", err)
+		return nil
+	}
+	return l
+}
+
 // killProcess sends SIGTERM to the process group, waits sigtermTimeout,
 // then escalates to SIGKILL.
 func (s *Supervisor) killProcess(pid int) {
@@ -0,0 +1,47 @@
+package streams
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// FakeBinary is the multi-call stand-in for the binaries streams run,
+// built from testdata/fakebin.
+const FakeBinary = "fakebin"
+
+// TestBinaries are the binaries FakeBinary stands in for.
+var TestBinaries = []string{
+	"pianobar", "vlc", "cvlc", "go-librespot", "alsaloop", "ffmpeg", "ffprobe",
+	"aplay", "raop_play", "amixer", "bluealsa-aplay", "shairport-sync", "squeezelite", "gmrender-resurrect",
+}
+
+// UseTestBinaries makes streams run the fake binaries in dir instead of
+// the real ones: each of TestBinaries missing from dir is linked to dir's
+// FakeBinary, and dir is put first on PATH, so stub executables dropped
+// into dir take precedence too. For tests and development without the
+// real binaries.
+func UseTestBinaries(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	fake := filepath.Join(dir, FakeBinary)
+	if !fileExists(fake) {
+		return fmt.Errorf("test binaries: no %s in %s (go build -o %s ./internal/streams/testdata/fakebin)", FakeBinary, dir, fake)
+	}
+	for _, name := range TestBinaries {
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("test binaries: %w", err)
+		}
+		if err := os.Symlink(FakeBinary, link); err != nil {
+			return fmt.Errorf("test binaries: %w", err)
+		}
+	}
+	return os.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
@@ -0,0 +1,312 @@
+// Command fakebin stands in for the binaries AmpliPi streams run, so the
+// stream manager can be exercised without them. It acts as the binary it
+// is invoked as, through a symlink named after it (see
+// streams.UseTestBinaries):
+//
+//   - pianobar reports two stations and a song through its event command,
+//     and a new song on the n and s control FIFO commands.
+//   - vlc plays its media for FAKEBIN_PLAY_SECONDS (default 1) with
+//     --play-and-exit, and until killed otherwise.
+//   - go-librespot serves /status and /player/* on the port of its
+//     config.yml, playing a fake track.
+//   - ffmpeg writes a -20 dBFS tone for raw PCM output, plays for
+//     FAKEBIN_PLAY_SECONDS to ALSA, and idles until killed for MP3.
+//   - ffprobe reports the audio codec of files named .mp3, .wav or
+//     .flac and of URLs, and fails on anything else.
+//   - aplay and raop_play read their input to the end.
+//   - amixer appends its arguments as a line to $FAKEBIN_AMIXER_LOG.
+//   - anything else, e.g. alsaloop or shairport-sync, idles until killed.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+func main() {
+	name := filepath.Base(os.Args[0])
+	args := os.Args[1:]
+	var err error
+	switch name {
+	case "pianobar":
+		err = pianobar()
+	case "vlc", "cvlc":
+		vlc(args)
+	case "go-librespot":
+		err = librespot(args)
+	case "ffmpeg":
+		err = ffmpeg(args)
+	case "ffprobe":
+		err = ffprobe(args)
+	case "aplay", "raop_play":
+		_, err = io.Copy(io.Discard, os.Stdin)
+	case "amixer":
+		err = amixer(args)
+	default:
+		waitForSignal()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s (fake): %v\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// waitForSignal blocks until the process is told to stop.
+func waitForSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM, syscall.SIGINT)
+	<-sig
+}
+
+// amixer records its arguments in $FAKEBIN_AMIXER_LOG, if set.
+func amixer(args []string) error {
+	path := os.Getenv("FAKEBIN_AMIXER_LOG")
+	if path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintln(f, strings.Join(args, " "))
+	return err
+}
+
+// playTime is how long media takes to play.
+func playTime() time.Duration {
+	if s, err := strconv.ParseFloat(os.Getenv("FAKEBIN_PLAY_SECONDS"), 64); err == nil {
+		return time.Duration(s * float64(time.Second))
+	}
+	return time.Second
+}
+
+// flagValue returns the argument following the last flag, or "". The
+// last counts since ffmpeg's output options follow its input options.
+func flagValue(args []string, flag string) string {
+	v := ""
+	for i, a := range args {
+		if a == flag && i+1 < len(args) {
+			v = args[i+1]
+		}
+	}
+	return v
+}
+
+// pianobar reads the event command and control FIFO from the config in
+// $HOME/.config/pianobar.
+func pianobar() error {
+	cfg, err := os.ReadFile(filepath.Join(os.Getenv("HOME"), ".config", "pianobar", "config"))
+	if err != nil {
+		return err
+	}
+	settings := map[string]string{}
+	for _, line := range strings.Split(string(cfg), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			settings[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	event := func(typ string, kv ...string) {
+		cmd := exec.Command(settings["event_command"], typ)
+		cmd.Stdin = strings.NewReader(strings.Join(kv, "\n") + "\n")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Fprintf(os.Stderr, "pianobar (fake): event %s: %v: %s\n", typ, err, out)
+		}
+	}
+	stations := []string{"Fake Radio", "Test Station"}
+	station, song := 0, 1
+	songstart := func() {
+		event("songstart",
+			"title=Fake Song "+strconv.Itoa(song), "artist=Fake Artist", "album=Fake Album",
+			"coverArt=", "rating=0", "stationName="+stations[station])
+	}
+	event("usergetstations", "stationCount=2", "station0="+stations[0], "station1="+stations[1])
+	songstart()
+
+	// Opened read-write so writers never see it without a reader.
+	fifo, err := os.OpenFile(settings["fifo"], os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	go func() {
+		lines := bufio.NewScanner(fifo)
+		for lines.Scan() {
+			switch lines.Text() {
+			case "n":
+				song++
+				songstart()
+			case "s":
+				if !lines.Scan() {
+					return
+				}
+				if n, err := strconv.Atoi(lines.Text()); err == nil && n >= 0 && n < len(stations) {
+					station, song = n, 1
+					songstart()
+				}
+			case "q":
+				os.Exit(0)
+			}
+		}
+	}()
+	waitForSignal()
+	return nil
+}
+
+// vlc plays the media given last.
+func vlc(args []string) {
+	for _, a := range args {
+		if a == "--play-and-exit" {
+			time.Sleep(playTime())
+			return
+		}
+	}
+	waitForSignal()
+}
+
+// librespotStatus is the part of go-librespot's /status AmpliPi reads.
+type librespotStatus struct {
+	Username    string `json:"username"`
+	PlayerState struct {
+		IsPlaying bool `json:"is_playing"`
+		IsPaused  bool `json:"is_paused"`
+	} `json:"player_state"`
+	Track struct {
+		Name        string   `json:"name"`
+		AlbumName   string   `json:"album_name"`
+		ArtistNames []string `json:"artist_names"`
+	} `json:"track"`
+	Stopped bool `json:"stopped"`
+	Paused  bool `json:"paused"`
+}
+
+// librespot serves the player API on the port of --config_dir's config.
+func librespot(args []string) error {
+	cfg, err := os.ReadFile(filepath.Join(flagValue(args, "--config_dir"), "config.yml"))
+	if err != nil {
+		return err
+	}
+	port := ""
+	for _, line := range strings.Split(string(cfg), "\n") {
+		if v, ok := strings.CutPrefix(strings.TrimSpace(line), "port:"); ok {
+			port = strings.TrimSpace(v)
+		}
+	}
+	if port == "" {
+		return fmt.Errorf("no server port in config.yml")
+	}
+
+	var mu sync.Mutex
+	track, paused := 1, false
+	http.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var st librespotStatus
+		st.Username = "fake-user"
+		st.PlayerState.IsPlaying, st.PlayerState.IsPaused, st.Paused = !paused, paused, paused
+		st.Track.Name = "Fake Track " + strconv.Itoa(track)
+		st.Track.AlbumName = "Fake Album"
+		st.Track.ArtistNames = []string{"Fake Artist"}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(st)
+	})
+	player := func(fn func()) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			fn()
+			mu.Unlock()
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+	http.HandleFunc("POST /player/pause", player(func() { paused = true }))
+	http.HandleFunc("POST /player/resume", player(func() { paused = false }))
+	http.HandleFunc("POST /player/next", player(func() { track++ }))
+	http.HandleFunc("POST /player/prev", player(func() { track = max(1, track-1) }))
+
+	ln, err := net.Listen("tcp", "localhost:"+port)
+	if err != nil {
+		return err
+	}
+	go http.Serve(ln, nil)
+	waitForSignal()
+	return nil
+}
+
+// ffmpeg produces its output, by output format: a tone for raw PCM, to
+// stdout or a file, played time for ALSA.
+func ffmpeg(args []string) error {
+	switch flagValue(args, "-f") {
+	case "mp3":
+		waitForSignal()
+		return nil
+	case "alsa":
+		time.Sleep(playTime())
+		return nil
+	}
+
+	seconds := playTime().Seconds()
+	if t, err := strconv.ParseFloat(flagValue(args, "-t"), 64); err == nil {
+		seconds = t
+	}
+	rate := 48000
+	if r, err := strconv.Atoi(flagValue(args, "-ar")); err == nil {
+		rate = r
+	}
+	w := io.Writer(os.Stdout)
+	if out := args[len(args)-1]; out != "pipe:1" && out != "-" {
+		f, err := os.Create(out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	frame := make([]byte, 4)
+	for i := range int(seconds * float64(rate)) {
+		v := uint16(int16(0.1 * 32767 * math.Sin(2*math.Pi*440*float64(i)/float64(rate))))
+		binary.LittleEndian.PutUint16(frame, v)
+		binary.LittleEndian.PutUint16(frame[2:], v)
+		if _, err := bw.Write(frame); err != nil {
+			return nil // the reader went away
+		}
+	}
+	return nil
+}
+
+// ffprobe prints the codec of the audio stream of its input, the last
+// argument, going by its extension.
+func ffprobe(args []string) error {
+	media := args[len(args)-1]
+	if strings.Contains(media, "://") {
+		fmt.Println("mp3")
+		return nil
+	}
+	if _, err := os.Stat(media); err != nil {
+		return fmt.Errorf("%s: No such file or directory", media)
+	}
+	switch ext := strings.ToLower(filepath.Ext(media)); ext {
+	case ".mp3", ".flac":
+		fmt.Println(ext[1:])
+	case ".wav":
+		fmt.Println("pcm_s16le")
+	default:
+		return fmt.Errorf("%s: Invalid data found when processing input", media)
+	}
+	return nil
+}
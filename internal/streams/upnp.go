@@ -0,0 +1,242 @@
+package streams
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// UPnP service types used by the DLNA stream.
+const (
+	upnpMediaServer      = "urn:schemas-upnp-org:device:MediaServer:1"
+	upnpContentDirectory = "urn:schemas-upnp-org:service:ContentDirectory:1"
+	upnpAVTransport      = "urn:schemas-upnp-org:service:AVTransport:1"
+)
+
+var upnpClient = &http.Client{Timeout: 5 * time.Second}
+
+// ssdpSearch multicasts an SSDP M-SEARCH for target and returns the
+// description URLs of the devices that answer within wait. Replaced in tests.
+var ssdpSearch = func(ctx context.Context, target string, wait time.Duration) ([]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	msg := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		fmt.Sprintf("MX: %d\r\n", max(1, int(wait/time.Second))) +
+		"ST: " + target + "\r\n\r\n"
+	dst := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	if _, err := conn.WriteTo([]byte(msg), dst); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(wait)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetReadDeadline(deadline)
+
+	var locations []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			break // deadline reached
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		loc := resp.Header.Get("Location")
+		resp.Body.Close()
+		if loc != "" && !seen[loc] {
+			seen[loc] = true
+			locations = append(locations, loc)
+		}
+	}
+	return locations, nil
+}
+
+// upnpDevice is the part of a UPnP device description the DLNA stream uses.
+type upnpDevice struct {
+	UDN          string
+	FriendlyName string
+	Services     map[string]string // service type -> absolute control URL
+}
+
+// fetchUPnPDevice reads the device description at location.
+func fetchUPnPDevice(ctx context.Context, location string) (*upnpDevice, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := upnpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upnp: %s: %s", location, resp.Status)
+	}
+
+	var desc struct {
+		URLBase string `xml:"URLBase"`
+		Device  struct {
+			UDN          string `xml:"UDN"`
+			FriendlyName string `xml:"friendlyName"`
+			Services     []struct {
+				Type       string `xml:"serviceType"`
+				ControlURL string `xml:"controlURL"`
+			} `xml:"serviceList>service"`
+		} `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return nil, fmt.Errorf("upnp: %s: %w", location, err)
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if desc.URLBase != "" {
+		if b, err := url.Parse(desc.URLBase); err == nil {
+			base = b
+		}
+	}
+	dev := &upnpDevice{
+		UDN:          strings.TrimPrefix(strings.TrimSpace(desc.Device.UDN), "uuid:"),
+		FriendlyName: strings.TrimSpace(desc.Device.FriendlyName),
+		Services:     make(map[string]string),
+	}
+	for _, svc := range desc.Device.Services {
+		ref, err := url.Parse(strings.TrimSpace(svc.ControlURL))
+		if err != nil {
+			continue
+		}
+		dev.Services[strings.TrimSpace(svc.Type)] = base.ResolveReference(ref).String()
+	}
+	return dev, nil
+}
+
+// soapArg is an action argument; order matters to some devices.
+type soapArg struct{ Name, Value string }
+
+// soapCall invokes a UPnP action and returns the raw response body.
+func soapCall(ctx context.Context, controlURL, service, action string, args ...soapArg) ([]byte, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, service)
+	for _, a := range args {
+		fmt.Fprintf(&body, "<%s>%s</%s>", a.Name, html.EscapeString(a.Value), a.Name)
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body.String()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	// Set directly: some renderers only accept the header with this casing.
+	req.Header["SOAPAction"] = []string{fmt.Sprintf(`"%s#%s"`, service, action)}
+	resp, err := upnpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upnp %s: %s", action, resp.Status)
+	}
+	return data, nil
+}
+
+// didlObject is a container or item from a DIDL-Lite browse result.
+type didlObject struct {
+	ID        string
+	Title     string
+	Container bool
+	Class     string
+	Res       string // first resource URL (items only)
+	AlbumArt  string
+}
+
+// contentDirectoryBrowse runs ContentDirectory Browse on objectID. With
+// metadata true it returns the object itself, otherwise its children.
+// It also returns the raw DIDL-Lite document.
+func contentDirectoryBrowse(ctx context.Context, controlURL, objectID string, metadata bool) ([]didlObject, string, error) {
+	flag := "BrowseDirectChildren"
+	if metadata {
+		flag = "BrowseMetadata"
+	}
+	data, err := soapCall(ctx, controlURL, upnpContentDirectory, "Browse",
+		soapArg{"ObjectID", objectID},
+		soapArg{"BrowseFlag", flag},
+		soapArg{"Filter", "*"},
+		soapArg{"StartingIndex", "0"},
+		soapArg{"RequestedCount", "500"},
+		soapArg{"SortCriteria", ""},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+	var env struct {
+		Result string `xml:"Body>BrowseResponse>Result"`
+	}
+	if err := xml.Unmarshal(data, &env); err != nil {
+		return nil, "", fmt.Errorf("upnp Browse: %w", err)
+	}
+	objs, err := parseDIDL(env.Result)
+	return objs, env.Result, err
+}
+
+// parseDIDL parses a DIDL-Lite document.
+func parseDIDL(doc string) ([]didlObject, error) {
+	type entry struct {
+		ID       string `xml:"id,attr"`
+		Title    string `xml:"title"`
+		Class    string `xml:"class"`
+		AlbumArt string `xml:"albumArtURI"`
+		Res      []struct {
+			URL string `xml:",chardata"`
+		} `xml:"res"`
+	}
+	var didl struct {
+		Containers []entry `xml:"container"`
+		Items      []entry `xml:"item"`
+	}
+	if strings.TrimSpace(doc) == "" {
+		return nil, nil
+	}
+	if err := xml.Unmarshal([]byte(doc), &didl); err != nil {
+		return nil, fmt.Errorf("DIDL-Lite: %w", err)
+	}
+	var objs []didlObject
+	for _, c := range didl.Containers {
+		objs = append(objs, didlObject{ID: c.ID, Title: c.Title, Container: true, Class: c.Class, AlbumArt: c.AlbumArt})
+	}
+	for _, it := range didl.Items {
+		o := didlObject{ID: it.ID, Title: it.Title, Class: it.Class, AlbumArt: it.AlbumArt}
+		if len(it.Res) > 0 {
+			o.Res = strings.TrimSpace(it.Res[0].URL)
+		}
+		objs = append(objs, o)
+	}
+	return objs, nil
+}
@@ -2,12 +2,27 @@ package streams
 
 import (
 	"errors"
-	"fmt"
+	"log/slog"
 	"sync"
+	"sync/atomic"
+
+	"github.com/micro-nova/amplipi-go/internal/audio"
 )
 
-// MaxVSRC is the number of ALSA loopback virtual source slots.
-const MaxVSRC = 12
+// audioLayout is the ALSA topology used for device naming and vsrc capacity.
+// Set by main during initialization and replaced when outputs are
+// reconfigured; defaults to the reference layout.
+var audioLayout atomic.Pointer[audio.Layout]
+
+func init() { audioLayout.Store(audio.DefaultLayout()) }
+
+// SetAudioLayout configures the ALSA topology. Must be called before the
+// Manager is created so the vsrc pool is sized correctly; later calls only
+// affect device naming for newly connected streams.
+func SetAudioLayout(l *audio.Layout) {
+	audioLayout.Store(l)
+	slog.Info("streams: audio layout configured", "vsrcs", l.VSRCCount(), "outputs", len(l.Outputs))
+}
 
 // ErrNoVSRC is returned when no virtual source slots are available.
 var ErrNoVSRC = errors.New("no virtual source slots available")
@@ -15,19 +30,25 @@ var ErrNoVSRC = errors.New("no virtual source slots available")
 // VSRCAllocator manages a pool of ALSA loopback virtual source indices.
 type VSRCAllocator struct {
 	mu   sync.Mutex
-	used [MaxVSRC]bool
+	used []bool // one per slot provided by the audio layout
 }
 
-// NewVSRCAllocator creates a new VSRCAllocator with all slots free.
+// NewVSRCAllocator creates a new VSRCAllocator with all slots free,
+// sized to the configured audio layout.
 func NewVSRCAllocator() *VSRCAllocator {
-	return &VSRCAllocator{}
+	return &VSRCAllocator{used: make([]bool, audioLayout.Load().VSRCCount())}
+}
+
+// Size returns the number of vsrc slots in the pool.
+func (v *VSRCAllocator) Size() int {
+	return len(v.used)
 }
 
 // Alloc returns the next free vsrc index, or ErrNoVSRC if all are taken.
 func (v *VSRCAllocator) Alloc() (int, error) {
 	v.mu.Lock()
 	defer v.mu.Unlock()
-	for i := 0; i < MaxVSRC; i++ {
+	for i := range v.used {
 		if !v.used[i] {
 			v.used[i] = true
 			return i, nil
@@ -38,7 +59,7 @@ func (v *VSRCAllocator) Alloc() (int, error) {
 
 // Free releases a vsrc index back to the pool.
 func (v *VSRCAllocator) Free(vsrc int) {
-	if vsrc < 0 || vsrc >= MaxVSRC {
+	if vsrc < 0 || vsrc >= len(v.used) {
 		return
 	}
 	v.mu.Lock()
@@ -49,17 +70,17 @@ func (v *VSRCAllocator) Free(vsrc int) {
 // VirtualCaptureDevice returns the ALSA PCM name for reading from this vsrc.
 // Format: "lb{vsrc}p" — the playback side of the loopback (what the stream outputs).
 func VirtualCaptureDevice(vsrc int) string {
-	return fmt.Sprintf("lb%dp", vsrc)
+	return audioLayout.Load().VirtualCaptureDevice(vsrc)
 }
 
 // VirtualOutputDevice returns the ALSA PCM name for writing to this vsrc.
 // Format: "lb{vsrc}c" — the capture side of the loopback (where stream audio enters).
 func VirtualOutputDevice(vsrc int) string {
-	return fmt.Sprintf("lb%dc", vsrc)
+	return audioLayout.Load().VirtualOutputDevice(vsrc)
 }
 
 // PhysicalOutputDevice returns the ALSA PCM name for a physical source.
 // Format: "ch{physSrc}" — ch0=HifiBerry, ch1-ch3=USB DAC channels.
 func PhysicalOutputDevice(physSrc int) string {
-	return fmt.Sprintf("ch%d", physSrc)
+	return audioLayout.Load().PhysicalOutputDevice(physSrc)
 }
@@ -0,0 +1,502 @@
+// Package telnet serves a line-based control protocol for AV control
+// systems that integrate by sending ASCII strings over TCP, e.g. from a
+// Crestron or Extron processor or a telnet session. Each command is a
+// line of words, case-insensitive, ended by CR, LF or both:
+//
+//	ZONE <id> VOL <dB>             set the volume, e.g. ZONE 3 VOL -40
+//	ZONE <id> LEVEL <0-100>        set the volume in percent of its range
+//	ZONE <id> VOL UP|DOWN [<pct>]  step the volume, by 5% unless given
+//	ZONE <id> MUTE ON|OFF|TOGGLE
+//	ZONE <id> SOURCE <source id>
+//	ZONE <id> [STATUS]             report the zone
+//	GROUP <id> ...                 as for ZONE
+//	SOURCE <id> INPUT <input>      e.g. SOURCE 0 INPUT stream=1000
+//	STREAM <id> <command>          e.g. STREAM 1000 NEXT
+//	PRESET <id> [LOAD]             load a preset
+//	FEEDBACK ON|OFF                report zones as they change
+//	AUTH <API key>                 needed first unless no password is set
+//	HELP
+//	QUIT
+//
+// Zone and group commands are answered with the resulting status,
+//
+//	ZONE 3 VOL -40 LEVEL 50 MUTE OFF SOURCE 1
+//
+// other commands with OK, and failures with ERR and the reason. With
+// FEEDBACK ON, the status of every zone that changes, for whatever
+// reason, is sent as it changes. A key restricted to some zones and
+// groups may only use ZONE, GROUP and STREAM on them, as over HTTP.
+package telnet
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// maxLine bounds a command.
+const maxLine = 4096
+
+// help is the reply to HELP.
+const help = `ZONE <id> VOL <dB> | LEVEL <0-100> | VOL UP|DOWN [<pct>] | MUTE ON|OFF|TOGGLE | SOURCE <id> | STATUS
+GROUP <id> (as for ZONE)
+SOURCE <id> INPUT <input>
+STREAM <id> <command>
+PRESET <id> [LOAD]
+FEEDBACK ON|OFF
+AUTH <API key>
+QUIT`
+
+// Controller is the part of the controller the commands drive.
+type Controller interface {
+	State() models.State
+	StreamerMode() bool
+	SetSource(ctx context.Context, id int, upd models.SourceUpdate) (models.State, *models.AppError)
+	SetZone(ctx context.Context, id int, upd models.ZoneUpdate) (models.State, *models.AppError)
+	SetGroup(ctx context.Context, id int, upd models.GroupUpdate) (models.State, *models.AppError)
+	ExecStreamCommand(ctx context.Context, id int, cmd string) (models.State, *models.AppError)
+	LoadPresetWithReport(ctx context.Context, id int) (models.State, models.PresetReport, *models.AppError)
+}
+
+// EventBus delivers the zone changes sent as feedback.
+type EventBus interface {
+	SubscribeEvents(id string) <-chan models.Event
+	UnsubscribeEvents(id string)
+}
+
+// Auth checks API keys.
+type Auth interface {
+	IsOpenMode() bool
+	KeyAccess(key string) (admin bool, sc *auth.Scope, ok bool)
+}
+
+// Server answers control clients.
+type Server struct {
+	ctrl Controller
+	bus  EventBus
+	auth Auth
+}
+
+// New creates a server.
+func New(ctrl Controller, bus EventBus, auth Auth) *Server {
+	return &Server{ctrl: ctrl, bus: bus, auth: auth}
+}
+
+// Serve accepts clients on ln until ctx is cancelled.
+func (s *Server) Serve(ctx context.Context, ln net.Listener) {
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+	for {
+		nc, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Error("telnet: accept failed", "err", err)
+			}
+			return
+		}
+		go s.serveConn(ctx, nc)
+	}
+}
+
+// conn is a connected client.
+type conn struct {
+	nc       net.Conn
+	wmu      sync.Mutex             // serializes replies and feedback
+	key      atomic.Pointer[string] // API key signed in with; nil before AUTH
+	feedback atomic.Bool
+}
+
+func (c *conn) send(line string) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	_, _ = c.nc.Write([]byte(line + "\r\n"))
+}
+
+// errQuit ends the session.
+var errQuit = errors.New("quit")
+
+func (s *Server) serveConn(ctx context.Context, nc net.Conn) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer nc.Close()
+	remote := nc.RemoteAddr().String()
+	slog.Info("telnet: client connected", "remote", remote)
+	defer slog.Info("telnet: client disconnected", "remote", remote)
+
+	c := &conn{nc: nc}
+	id := "telnet-" + uuid.New().String()
+	evs := s.bus.SubscribeEvents(id)
+	defer s.bus.UnsubscribeEvents(id)
+	go s.sendFeedback(ctx, c, evs)
+
+	sc := bufio.NewScanner(nc)
+	sc.Buffer(make([]byte, 0, 256), maxLine)
+	sc.Split(scanLines)
+	for sc.Scan() {
+		line := strings.TrimSpace(printable(sc.Text()))
+		if line == "" {
+			continue
+		}
+		reply, err := s.handle(ctx, c, line)
+		if err == errQuit {
+			c.send("BYE")
+			return
+		}
+		if err != nil {
+			reply = "ERR " + err.Error()
+		}
+		c.send(reply)
+	}
+	if err := sc.Err(); err != nil && ctx.Err() == nil {
+		slog.Warn("telnet: read failed", "remote", remote, "err", err)
+	}
+}
+
+// scanLines splits at CR, LF or CRLF.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\r' || b == '\n' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// printable drops control characters and bytes outside ASCII, such as the
+// option negotiation a telnet client sends when it connects.
+func printable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r < 0x20 && r != '\t') || r >= 0x7f {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// sendFeedback sends the status of changed zones to clients that turned
+// feedback on, until ctx is cancelled.
+func (s *Server) sendFeedback(ctx context.Context, c *conn, evs <-chan models.Event) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-evs:
+			if !ok {
+				return
+			}
+			zc, isZones := ev.Data.(models.ZoneChanged)
+			if !isZones || !c.feedback.Load() {
+				continue
+			}
+			if _, ok := auth.ContextForKey(ctx, s.auth, c.key.Load()); !ok {
+				continue
+			}
+			for _, z := range zc.Zones {
+				c.send(zoneStatus(&z))
+			}
+		}
+	}
+}
+
+// handle runs one command and returns its reply.
+func (s *Server) handle(ctx context.Context, c *conn, line string) (string, error) {
+	words := strings.Fields(line)
+	cmd := strings.ToUpper(words[0])
+	args := words[1:]
+
+	switch cmd {
+	case "QUIT", "EXIT":
+		return "", errQuit
+	case "HELP", "?":
+		return help, nil
+	case "AUTH":
+		if len(args) != 1 {
+			return "", fmt.Errorf("usage: AUTH <API key>")
+		}
+		if s.auth != nil && !s.auth.IsOpenMode() {
+			if _, _, ok := s.auth.KeyAccess(args[0]); !ok {
+				return "", fmt.Errorf("invalid API key")
+			}
+		}
+		key := args[0]
+		c.key.Store(&key)
+		return "OK", nil
+	}
+	ctx, ok := auth.ContextForKey(ctx, s.auth, c.key.Load())
+	if !ok {
+		return "", fmt.Errorf("authentication required: AUTH <API key>")
+	}
+	if auth.ScopeFrom(ctx) != nil && (cmd == "SOURCE" || cmd == "PRESET") {
+		return "", fmt.Errorf("this key may only control its zones and groups")
+	}
+
+	switch cmd {
+	case "FEEDBACK":
+		on, err := onOff(args, 0)
+		if err != nil {
+			return "", err
+		}
+		c.feedback.Store(on)
+		return "OK", nil
+	case "ZONE", "GROUP":
+		if s.ctrl.StreamerMode() {
+			return "", fmt.Errorf("zones and groups are not available on a streamer unit")
+		}
+		id, err := idArg(args)
+		if err != nil {
+			return "", err
+		}
+		if cmd == "ZONE" {
+			return s.zone(ctx, id, args[1:])
+		}
+		return s.group(ctx, id, args[1:])
+	case "SOURCE":
+		id, err := idArg(args)
+		if err != nil {
+			return "", err
+		}
+		if len(args) != 3 || !strings.EqualFold(args[1], "INPUT") {
+			return "", fmt.Errorf("usage: SOURCE <id> INPUT <input>")
+		}
+		input := args[2]
+		_, appErr := s.ctrl.SetSource(ctx, id, models.SourceUpdate{Input: &input})
+		return okOr(appErr)
+	case "STREAM":
+		id, err := idArg(args)
+		if err != nil {
+			return "", err
+		}
+		if len(args) != 2 {
+			return "", fmt.Errorf("usage: STREAM <id> <command>")
+		}
+		_, appErr := s.ctrl.ExecStreamCommand(ctx, id, strings.ToLower(args[1]))
+		return okOr(appErr)
+	case "PRESET":
+		id, err := idArg(args)
+		if err != nil {
+			return "", err
+		}
+		if len(args) > 2 || len(args) == 2 && !strings.EqualFold(args[1], "LOAD") {
+			return "", fmt.Errorf("usage: PRESET <id> [LOAD]")
+		}
+		_, _, appErr := s.ctrl.LoadPresetWithReport(ctx, id)
+		return okOr(appErr)
+	}
+	return "", fmt.Errorf("unknown command %s; try HELP", words[0])
+}
+
+// zone runs a ZONE command.
+func (s *Server) zone(ctx context.Context, id int, args []string) (string, error) {
+	var upd models.ZoneUpdate
+	if len(args) == 0 || len(args) == 1 && strings.EqualFold(args[0], "STATUS") {
+		for _, z := range s.ctrl.State().Zones {
+			if z.ID == id {
+				return zoneStatus(&z), nil
+			}
+		}
+		return "", fmt.Errorf("zone %d not found", id)
+	}
+	var toggle bool
+	if err := parseSetting(args, &upd.Vol, &upd.VolF, &upd.VolDeltaF, &upd.Mute, &toggle, &upd.SourceID); err != nil {
+		return "", err
+	}
+	if toggle {
+		for _, z := range s.ctrl.State().Zones {
+			if z.ID == id {
+				mute := !z.Mute
+				upd.Mute = &mute
+			}
+		}
+	}
+	state, appErr := s.ctrl.SetZone(ctx, id, upd)
+	if appErr != nil {
+		return "", appErr
+	}
+	for _, z := range state.Zones {
+		if z.ID == id {
+			return zoneStatus(&z), nil
+		}
+	}
+	return "OK", nil
+}
+
+// group runs a GROUP command.
+func (s *Server) group(ctx context.Context, id int, args []string) (string, error) {
+	find := func(state models.State) *models.Group {
+		for i := range state.Groups {
+			if state.Groups[i].ID == id {
+				return &state.Groups[i]
+			}
+		}
+		return nil
+	}
+	if len(args) == 0 || len(args) == 1 && strings.EqualFold(args[0], "STATUS") {
+		g := find(s.ctrl.State())
+		if g == nil {
+			return "", fmt.Errorf("group %d not found", id)
+		}
+		return groupStatus(g), nil
+	}
+	var upd models.GroupUpdate
+	var toggle bool
+	if err := parseSetting(args, &upd.Vol, &upd.VolF, &upd.VolDeltaF, &upd.Mute, &toggle, &upd.SourceID); err != nil {
+		return "", err
+	}
+	if toggle {
+		mute := true
+		if g := find(s.ctrl.State()); g != nil && g.Mute != nil {
+			mute = !*g.Mute
+		}
+		upd.Mute = &mute
+	}
+	state, appErr := s.ctrl.SetGroup(ctx, id, upd)
+	if appErr != nil {
+		return "", appErr
+	}
+	if g := find(state); g != nil {
+		return groupStatus(g), nil
+	}
+	return "OK", nil
+}
+
+// parseSetting parses the setting of a ZONE or GROUP command into the
+// update field it sets.
+func parseSetting(args []string, vol **int, volF, volDeltaF **float64, mute **bool, toggle *bool, source **int) error {
+	what := strings.ToUpper(args[0])
+	if len(args) < 2 {
+		return fmt.Errorf("%s needs a value", what)
+	}
+	val := strings.ToUpper(args[1])
+	switch {
+	case what == "VOL" && (val == "UP" || val == "DOWN"):
+		step := models.DefaultVolStepF * 100
+		if len(args) == 3 {
+			var err error
+			if step, err = strconv.ParseFloat(args[2], 64); err != nil || math.IsNaN(step) || step <= 0 || step > 100 {
+				return fmt.Errorf("step %q is not a percentage", args[2])
+			}
+		} else if len(args) > 3 {
+			return fmt.Errorf("usage: VOL UP|DOWN [<pct>]")
+		}
+		if val == "DOWN" {
+			step = -step
+		}
+		delta := step / 100
+		*volDeltaF = &delta
+		return nil
+	case len(args) != 2:
+		return fmt.Errorf("usage: %s <value>", what)
+	case what == "VOL":
+		v, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("volume %q is not a number of dB", args[1])
+		}
+		*vol = &v
+	case what == "LEVEL":
+		pct, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || math.IsNaN(pct) || pct < 0 || pct > 100 {
+			return fmt.Errorf("level %q is not 0-100", args[1])
+		}
+		f := pct / 100
+		*volF = &f
+	case what == "MUTE":
+		if val == "TOGGLE" {
+			*toggle = true
+			return nil
+		}
+		on, err := onOff(args, 1)
+		if err != nil {
+			return err
+		}
+		*mute = &on
+	case what == "SOURCE":
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("source %q is not an ID", args[1])
+		}
+		*source = &id
+	default:
+		return fmt.Errorf("unknown setting %s; try HELP", args[0])
+	}
+	return nil
+}
+
+func idArg(args []string) (int, error) {
+	if len(args) == 0 {
+		return 0, fmt.Errorf("an ID is required")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, fmt.Errorf("%q is not an ID", args[0])
+	}
+	return id, nil
+}
+
+// onOff parses args[i] as ON or OFF.
+func onOff(args []string, i int) (bool, error) {
+	if len(args) == i+1 {
+		switch strings.ToUpper(args[i]) {
+		case "ON":
+			return true, nil
+		case "OFF":
+			return false, nil
+		}
+	}
+	return false, fmt.Errorf("want ON or OFF")
+}
+
+func okOr(appErr *models.AppError) (string, error) {
+	if appErr != nil {
+		return "", appErr
+	}
+	return "OK", nil
+}
+
+func zoneStatus(z *models.Zone) string {
+	return fmt.Sprintf("ZONE %d VOL %d LEVEL %d MUTE %s SOURCE %d", z.ID, z.Vol, level(z.VolF), onOffWord(z.Mute), z.SourceID)
+}
+
+// groupStatus reports the group's settings; those its zones differ in
+// are left out.
+func groupStatus(g *models.Group) string {
+	s := fmt.Sprintf("GROUP %d", g.ID)
+	if g.Vol != nil {
+		s += fmt.Sprintf(" VOL %d", *g.Vol)
+	}
+	if g.VolF != nil {
+		s += fmt.Sprintf(" LEVEL %d", level(*g.VolF))
+	}
+	if g.Mute != nil {
+		s += " MUTE " + onOffWord(*g.Mute)
+	}
+	if g.SourceID != nil {
+		s += fmt.Sprintf(" SOURCE %d", *g.SourceID)
+	}
+	return s
+}
+
+func level(volF float64) int { return int(math.Round(volF * 100)) }
+
+func onOffWord(on bool) string {
+	if on {
+		return "ON"
+	}
+	return "OFF"
+}
@@ -0,0 +1,211 @@
+package telnet
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/auth"
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+// Users of the test users.json: an administrator with a password and a
+// tablet restricted to zone 0.
+const (
+	adminKey  = "s3cret"
+	tabletKey = "tablet-key"
+	usersJSON = `{
+  "admin": {"type": "user", "access_key": "s3cret", "password_hash": "x"},
+  "tablet": {"type": "user", "access_key": "tablet-key", "scope": {"zones": [0], "groups": []}}
+}`
+)
+
+// newAuth returns an auth service over a config dir with users, or in
+// open mode if users is "".
+func newAuth(t *testing.T, users string) (*auth.Service, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if users != "" {
+		if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(users), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	svc, err := auth.NewService(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(svc.Close)
+	return svc, dir
+}
+
+// telnetClient is a line protocol client of a test server.
+type telnetClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialServer starts a server on a mock controller and connects to it.
+func dialServer(t *testing.T, auth Auth) (*telnetClient, *controller.Controller) {
+	t.Helper()
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go New(ctrl, bus, auth).Serve(ctx, ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &telnetClient{t: t, conn: conn, r: bufio.NewReader(conn)}, ctrl
+}
+
+// cmd sends a command and returns the first line of the reply.
+func (c *telnetClient) cmd(line string) string {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+	return c.line()
+}
+
+func (c *telnetClient) line() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	s, err := c.r.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("no reply: %v", err)
+	}
+	return strings.TrimRight(s, "\r\n")
+}
+
+func TestCommands(t *testing.T) {
+	svc, _ := newAuth(t, "")
+	c, ctrl := dialServer(t, svc)
+
+	for _, tc := range []struct{ cmd, want string }{
+		{"ZONE 0 VOL -40", "ZONE 0 VOL -40 "},
+		{"zone 0 mute off", " MUTE OFF "},
+		{"ZONE 0 MUTE TOGGLE", " MUTE ON "},
+		{"ZONE 0 SOURCE 2", " SOURCE 2"},
+		{"ZONE 0 LEVEL 50", " LEVEL 50 "},
+		{"ZONE 0 VOL UP 10", " LEVEL 60 "},
+		{"ZONE 0", "ZONE 0 VOL "},
+		{"ZONE 99 VOL -40", "ERR zone"},
+		{"ZONE 80", "ERR zone 80 not found"},
+		{"ZONE 0 LEVEL 150", "ERR level"},
+		{"ZONE 0 LEVEL NaN", "ERR level"},
+		{"ZONE 0 LEVEL Inf", "ERR level"},
+		{"ZONE 0 VOL UP NaN", "ERR step"},
+		{"ZONE 0 BASS 3", "ERR unknown setting"},
+		{"SOURCE 1 INPUT local", "OK"},
+		{"PRESET 12345", "ERR "},
+		{"DANCE", "ERR unknown command"},
+	} {
+		if got := c.cmd(tc.cmd); !strings.Contains(got, tc.want) {
+			t.Errorf("%s = %q, want it to contain %q", tc.cmd, got, tc.want)
+		}
+	}
+	if z := ctrl.State().Zones[0]; !z.Mute || z.SourceID != 2 {
+		t.Errorf("zone 0 = mute %v source %d, want muted on source 2", z.Mute, z.SourceID)
+	}
+	if got := ctrl.State().Sources[1].Input; got != "local" {
+		t.Errorf("source 1 input = %q, want local", got)
+	}
+
+	// Feedback reports zones changed by others.
+	if got := c.cmd("FEEDBACK ON"); got != "OK" {
+		t.Fatalf("FEEDBACK ON = %q", got)
+	}
+	if _, appErr := ctrl.SetZone(context.Background(), 1, zoneVol(-25)); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if got := c.line(); !strings.HasPrefix(got, "ZONE 1 VOL -25 ") {
+		t.Errorf("feedback = %q, want zone 1 at -25", got)
+	}
+
+	if got := c.cmd("QUIT"); got != "BYE" {
+		t.Errorf("QUIT = %q", got)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	svc, _ := newAuth(t, usersJSON)
+	c, _ := dialServer(t, svc)
+
+	if got := c.cmd("ZONE 0"); !strings.HasPrefix(got, "ERR authentication required") {
+		t.Errorf("before AUTH: %q", got)
+	}
+	if got := c.cmd("AUTH wrong"); got != "ERR invalid API key" {
+		t.Errorf("AUTH wrong = %q", got)
+	}
+	// Telnet option negotiation is ignored.
+	if got := c.cmd("\xff\xfd\x03AUTH s3cret"); got != "OK" {
+		t.Errorf("AUTH s3cret = %q", got)
+	}
+	if got := c.cmd("ZONE 0"); !strings.HasPrefix(got, "ZONE 0 VOL") {
+		t.Errorf("after AUTH: %q", got)
+	}
+}
+
+func TestScopedKey(t *testing.T) {
+	svc, _ := newAuth(t, usersJSON)
+	c, _ := dialServer(t, svc)
+
+	if got := c.cmd("AUTH " + tabletKey); got != "OK" {
+		t.Fatalf("AUTH = %q", got)
+	}
+	if got := c.cmd("ZONE 0 VOL -30"); !strings.HasPrefix(got, "ZONE 0 VOL -30") {
+		t.Errorf("own zone: %q", got)
+	}
+	for _, cmd := range []string{"ZONE 1 VOL -30", "SOURCE 0 INPUT local", "PRESET 0"} {
+		if got := c.cmd(cmd); !strings.HasPrefix(got, "ERR this key may") {
+			t.Errorf("%s = %q, want ERR", cmd, got)
+		}
+	}
+}
+
+func TestPasswordLocksOpenSession(t *testing.T) {
+	svc, dir := newAuth(t, "")
+	c, _ := dialServer(t, svc)
+
+	if got := c.cmd("ZONE 0"); !strings.HasPrefix(got, "ZONE 0 VOL") {
+		t.Fatalf("open mode: %q", got)
+	}
+	// A password is set while the session is open.
+	if err := os.WriteFile(filepath.Join(dir, "users.json"), []byte(usersJSON), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if got := c.cmd("ZONE 0"); !strings.HasPrefix(got, "ERR authentication required") {
+		t.Errorf("after setting a password: %q", got)
+	}
+	if got := c.cmd("AUTH " + adminKey); got != "OK" {
+		t.Fatalf("AUTH = %q", got)
+	}
+	if got := c.cmd("ZONE 0"); !strings.HasPrefix(got, "ZONE 0 VOL") {
+		t.Errorf("after AUTH: %q", got)
+	}
+}
+
+func zoneVol(v int) models.ZoneUpdate { return models.ZoneUpdate{Vol: &v} }
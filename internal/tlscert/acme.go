@@ -0,0 +1,303 @@
+package tlscert
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// LetsEncrypt is the directory URL of Let's Encrypt's production CA.
+const LetsEncrypt = acme.LetsEncryptURL
+
+// dnsCheckInterval is how often a DNS-validated certificate is checked
+// for renewal; failed attempts are retried after dnsRetryInterval.
+const (
+	dnsCheckInterval = 12 * time.Hour
+	dnsRetryInterval = time.Hour
+)
+
+// ACMEOptions configure certificates from an ACME CA.
+type ACMEOptions struct {
+	Domains   []string // public hostnames the certificate is for
+	Email     string   // contact for expiry notices; optional
+	Directory string   // CA directory URL; Let's Encrypt when empty
+	CacheDir  string   // account key and certificates
+
+	// DNSHook, when set, proves control of the domains with DNS-01
+	// challenges: it is run as "DNSHook present <fqdn> <value>" to
+	// create the _acme-challenge TXT record and "DNSHook cleanup <fqdn>
+	// <value>" to remove it. Without it, HTTP-01 challenges are answered
+	// by HTTPHandler on port 80 and TLS-ALPN-01 by the HTTPS listener.
+	DNSHook string
+}
+
+// ACME obtains and renews certificates from an ACME CA. Hosts it has no
+// certificate for, e.g. the unit's LAN IP, get the fallback certificate.
+type ACME struct {
+	opts     ACMEOptions
+	fallback *tls.Certificate
+	mgr      *autocert.Manager // HTTP-01 and TLS-ALPN-01; nil with a DNS hook
+
+	mu   sync.RWMutex
+	cert *tls.Certificate // issued by DNS-01
+}
+
+// NewACME creates an ACME certificate source. With a DNS hook, Run must
+// be called to issue and renew the certificate.
+func NewACME(opts ACMEOptions, fallback *tls.Certificate) (*ACME, error) {
+	if len(opts.Domains) == 0 {
+		return nil, errors.New("acme: no domains")
+	}
+	if opts.Directory == "" {
+		opts.Directory = LetsEncrypt
+	}
+	domains := make([]string, len(opts.Domains))
+	for i, d := range opts.Domains {
+		domains[i] = strings.ToLower(d)
+	}
+	opts.Domains = domains
+	a := &ACME{opts: opts, fallback: fallback}
+	if opts.DNSHook == "" {
+		a.mgr = &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(opts.Domains...),
+			Cache:      autocert.DirCache(opts.CacheDir),
+			Email:      opts.Email,
+			Client:     &acme.Client{DirectoryURL: opts.Directory},
+		}
+		return a, nil
+	}
+	if cert, err := tls.LoadX509KeyPair(a.dnsFile("cert.pem"), a.dnsFile("key.pem")); err == nil {
+		a.cert = &cert
+	}
+	return a, nil
+}
+
+// TLSConfig returns the HTTPS listener's config.
+func (a *ACME) TLSConfig() *tls.Config {
+	if a.mgr != nil {
+		cfg := a.mgr.TLSConfig()
+		cfg.GetCertificate = a.GetCertificate
+		return cfg
+	}
+	return &tls.Config{GetCertificate: a.GetCertificate}
+}
+
+// GetCertificate returns the certificate for the requested host: the
+// ACME one for its domains, the fallback otherwise.
+func (a *ACME) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	if !slices.Contains(a.opts.Domains, strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))) {
+		return a.fallbackCert()
+	}
+	if a.mgr != nil {
+		return a.mgr.GetCertificate(hello)
+	}
+	a.mu.RLock()
+	cert := a.cert
+	a.mu.RUnlock()
+	if cert == nil {
+		return a.fallbackCert()
+	}
+	return cert, nil
+}
+
+func (a *ACME) fallbackCert() (*tls.Certificate, error) {
+	if a.fallback == nil {
+		return nil, errors.New("acme: no certificate for this host")
+	}
+	return a.fallback, nil
+}
+
+// HTTPHandler answers HTTP-01 challenges and passes other requests to
+// fallback. With a DNS hook it returns fallback unchanged.
+func (a *ACME) HTTPHandler(fallback http.Handler) http.Handler {
+	if a.mgr == nil {
+		return fallback
+	}
+	return a.mgr.HTTPHandler(fallback)
+}
+
+// Run issues the DNS-validated certificate and renews it before it
+// expires, until ctx is cancelled. Without a DNS hook autocert renews on
+// its own and Run returns at once.
+func (a *ACME) Run(ctx context.Context) {
+	if a.mgr != nil {
+		return
+	}
+	for {
+		wait := dnsCheckInterval
+		a.mu.RLock()
+		due := a.cert == nil || !fresh(a.cert.Leaf)
+		a.mu.RUnlock()
+		if due {
+			if err := a.issueDNS(ctx); err != nil {
+				slog.Warn("acme: certificate not issued", "domains", a.opts.Domains, "err", err)
+				wait = dnsRetryInterval
+			} else {
+				slog.Info("acme: certificate issued", "domains", a.opts.Domains)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// issueDNS orders a certificate for the domains, proving control of each
+// with a DNS-01 challenge, and saves it to the cache directory.
+func (a *ACME) issueDNS(ctx context.Context) error {
+	key, err := a.accountKey()
+	if err != nil {
+		return fmt.Errorf("account key: %w", err)
+	}
+	client := &acme.Client{Key: key, DirectoryURL: a.opts.Directory}
+	acct := &acme.Account{}
+	if a.opts.Email != "" {
+		acct.Contact = []string{"mailto:" + a.opts.Email}
+	}
+	if _, err := client.Register(ctx, acct, acme.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("register account: %w", err)
+	}
+
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(a.opts.Domains...))
+	if err != nil {
+		return fmt.Errorf("order: %w", err)
+	}
+	for _, u := range order.AuthzURLs {
+		if err := a.authorizeDNS(ctx, client, u); err != nil {
+			return err
+		}
+	}
+	if order, err = client.WaitOrder(ctx, order.URI); err != nil {
+		return fmt.Errorf("order: %w", err)
+	}
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: a.opts.Domains[0]},
+		DNSNames: a.opts.Domains,
+	}, certKey)
+	if err != nil {
+		return err
+	}
+	chain, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return fmt.Errorf("finalize: %w", err)
+	}
+	if err := writeKeyPair(a.dnsFile("cert.pem"), a.dnsFile("key.pem"), chain, certKey); err != nil {
+		return fmt.Errorf("save certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(a.dnsFile("cert.pem"), a.dnsFile("key.pem"))
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.cert = &cert
+	a.mu.Unlock()
+	return nil
+}
+
+// authorizeDNS completes the DNS-01 challenge of one authorization,
+// removing the TXT record again afterwards.
+func (a *ACME) authorizeDNS(ctx context.Context, client *acme.Client, url string) error {
+	z, err := client.GetAuthorization(ctx, url)
+	if err != nil {
+		return fmt.Errorf("authorization: %w", err)
+	}
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == "dns-01" {
+			chal = c
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", z.Identifier.Value)
+	}
+	value, err := client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return err
+	}
+	fqdn := "_acme-challenge." + strings.TrimPrefix(z.Identifier.Value, "*.") + "."
+	if err := a.runHook(ctx, "present", fqdn, value); err != nil {
+		return err
+	}
+	defer func() {
+		if err := a.runHook(context.Background(), "cleanup", fqdn, value); err != nil {
+			slog.Warn("acme: DNS record not removed", "fqdn", fqdn, "err", err)
+		}
+	}()
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accept challenge for %s: %w", z.Identifier.Value, err)
+	}
+	if _, err := client.WaitAuthorization(ctx, z.URI); err != nil {
+		return fmt.Errorf("authorize %s: %w", z.Identifier.Value, err)
+	}
+	return nil
+}
+
+// runHook runs the DNS hook with action, fqdn and the TXT record value.
+func (a *ACME) runHook(ctx context.Context, action, fqdn, value string) error {
+	out, err := exec.CommandContext(ctx, a.opts.DNSHook, action, fqdn, value).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("dns hook %s %s: %w: %s", action, fqdn, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// accountKey loads the ACME account key from the cache directory,
+// generating it on first use.
+func (a *ACME) accountKey() (crypto.Signer, error) {
+	file := a.dnsFile("account-key.pem")
+	if data, err := os.ReadFile(file); err == nil {
+		if block, _ := pem.Decode(data); block != nil {
+			return x509.ParseECPrivateKey(block.Bytes)
+		}
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(a.opts.CacheDir, 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (a *ACME) dnsFile(name string) string {
+	return filepath.Join(a.opts.CacheDir, "dns-"+name)
+}
@@ -0,0 +1,178 @@
+// Package tlscert provides the certificates of the daemon's HTTPS
+// listener: a self-signed certificate generated once and kept in the
+// config directory, a certificate and key supplied by the user, or one
+// issued by an ACME CA such as Let's Encrypt for units with a public
+// hostname.
+package tlscert
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	selfSignedValidity = 10 * 365 * 24 * time.Hour
+	renewBefore        = 30 * 24 * time.Hour // renew certificates expiring sooner
+)
+
+// now is replaced in tests.
+var now = time.Now
+
+// SelfSigned returns the self-signed certificate kept in dir for hosts,
+// generating it when there is none, it is about to expire or it does not
+// cover all of hosts, e.g. after the hostname changed. Browsers warn about
+// it until the user trusts it.
+func SelfSigned(dir string, hosts []string) (*tls.Certificate, error) {
+	certFile := filepath.Join(dir, "selfsigned.pem")
+	keyFile := filepath.Join(dir, "selfsigned-key.pem")
+	if cert, err := tls.LoadX509KeyPair(certFile, keyFile); err == nil && covers(cert.Leaf, hosts) && fresh(cert.Leaf) {
+		return &cert, nil
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"AmpliPi"}, CommonName: hosts[0]},
+		NotBefore:             now().Add(-time.Hour),
+		NotAfter:              now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKeyPair(certFile, keyFile, [][]byte{der}, key); err != nil {
+		return nil, fmt.Errorf("save self-signed certificate: %w", err)
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &cert, nil
+}
+
+// DefaultHosts are the names a unit is reached by on the LAN.
+func DefaultHosts(hostname string) []string {
+	if hostname == "" {
+		hostname = "amplipi"
+	}
+	return []string{hostname, hostname + ".local", "localhost", "127.0.0.1", "::1"}
+}
+
+// covers reports whether leaf is valid for all of hosts.
+func covers(leaf *x509.Certificate, hosts []string) bool {
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			if !slices.ContainsFunc(leaf.IPAddresses, ip.Equal) {
+				return false
+			}
+		} else if !slices.Contains(leaf.DNSNames, h) {
+			return false
+		}
+	}
+	return true
+}
+
+// fresh reports whether leaf is not due for renewal.
+func fresh(leaf *x509.Certificate) bool {
+	return leaf != nil && now().Add(renewBefore).Before(leaf.NotAfter)
+}
+
+// writeKeyPair saves a certificate chain and its key as PEM, the key
+// readable by the daemon's user only.
+func writeKeyPair(certFile, keyFile string, chain [][]byte, key *ecdsa.PrivateKey) error {
+	if err := os.MkdirAll(filepath.Dir(certFile), 0755); err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return err
+	}
+	var certPEM []byte
+	for _, der := range chain {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	return os.WriteFile(certFile, certPEM, 0644)
+}
+
+// Files serves a certificate and key supplied by the user, reloading them
+// when either file changes so renewals by an external tool such as
+// certbot take effect without a restart.
+type Files struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// FromFiles loads a PEM certificate chain and its key.
+func FromFiles(certFile, keyFile string) (*Files, error) {
+	f := &Files{certFile: certFile, keyFile: keyFile}
+	if _, err := f.GetCertificate(nil); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// GetCertificate returns the certificate, reloading it if the files
+// changed. If a reload fails the previous certificate is kept.
+func (f *Files) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	mod := f.lastModified()
+	if f.cert != nil && !mod.After(f.modTime) {
+		return f.cert, nil
+	}
+	cert, err := tls.LoadX509KeyPair(f.certFile, f.keyFile)
+	if err != nil {
+		if f.cert != nil {
+			return f.cert, nil
+		}
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+	f.cert, f.modTime = &cert, mod
+	return f.cert, nil
+}
+
+func (f *Files) lastModified() time.Time {
+	var latest time.Time
+	for _, name := range []string{f.certFile, f.keyFile} {
+		if fi, err := os.Stat(name); err == nil && fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
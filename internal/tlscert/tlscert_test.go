@@ -0,0 +1,152 @@
+package tlscert
+
+import (
+	"context"
+	"crypto/tls"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	hosts := DefaultHosts("amplipi")
+
+	cert, err := SelfSigned(dir, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := cert.Leaf
+	if err := leaf.VerifyHostname("amplipi.local"); err != nil {
+		t.Errorf("amplipi.local: %v", err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Errorf("127.0.0.1: %v", err)
+	}
+	if fi, err := os.Stat(filepath.Join(dir, "selfsigned-key.pem")); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("key file: %v, %v", fi, err)
+	}
+
+	// Kept across restarts, so browsers that trust it keep trusting it.
+	again, err := SelfSigned(dir, hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Leaf.SerialNumber.Cmp(leaf.SerialNumber) != 0 {
+		t.Error("certificate regenerated although still valid")
+	}
+
+	// A new hostname needs a new certificate.
+	renamed, err := SelfSigned(dir, DefaultHosts("kitchen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := renamed.Leaf.VerifyHostname("kitchen.local"); err != nil {
+		t.Errorf("after rename: %v", err)
+	}
+
+	// So does one about to expire.
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(selfSignedValidity - renewBefore/2) }
+	renewed, err := SelfSigned(dir, DefaultHosts("kitchen"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if renewed.Leaf.SerialNumber.Cmp(renamed.Leaf.SerialNumber) == 0 {
+		t.Error("expiring certificate not renewed")
+	}
+}
+
+func TestFromFiles_Reloads(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := SelfSigned(dir, []string{"one.example"}); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := filepath.Join(dir, "selfsigned.pem"), filepath.Join(dir, "selfsigned-key.pem")
+
+	if _, err := FromFiles(filepath.Join(dir, "missing.pem"), keyFile); err == nil {
+		t.Error("missing certificate accepted")
+	}
+	f, err := FromFiles(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := f.GetCertificate(nil)
+	if cert.Leaf.DNSNames[0] != "one.example" {
+		t.Fatalf("names = %v", cert.Leaf.DNSNames)
+	}
+
+	// An external renewal replaces both files.
+	if _, err := SelfSigned(dir, []string{"two.example"}); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	cert, _ = f.GetCertificate(nil)
+	if cert.Leaf.DNSNames[0] != "two.example" {
+		t.Errorf("after renewal names = %v", cert.Leaf.DNSNames)
+	}
+
+	// A broken file keeps the previous certificate.
+	os.WriteFile(certFile, []byte("garbage"), 0644)
+	later = later.Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	if cert, err := f.GetCertificate(nil); err != nil || cert.Leaf.DNSNames[0] != "two.example" {
+		t.Errorf("after broken renewal: %v, %v", cert, err)
+	}
+}
+
+func TestACME_Fallback(t *testing.T) {
+	dir := t.TempDir()
+	self, err := SelfSigned(dir, DefaultHosts("amplipi"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewACME(ACMEOptions{}, self); err == nil {
+		t.Error("no domains accepted")
+	}
+
+	// A certificate issued before a restart is used for the ACME domain;
+	// LAN names get the self-signed one.
+	cacheDir := filepath.Join(dir, "acme")
+	if _, err := SelfSigned(dir, []string{"amp.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	os.MkdirAll(cacheDir, 0700)
+	os.Rename(filepath.Join(dir, "selfsigned.pem"), filepath.Join(cacheDir, "dns-cert.pem"))
+	os.Rename(filepath.Join(dir, "selfsigned-key.pem"), filepath.Join(cacheDir, "dns-key.pem"))
+
+	a, err := NewACME(ACMEOptions{Domains: []string{"Amp.Example.com"}, CacheDir: cacheDir, DNSHook: "/bin/false"}, self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := a.TLSConfig()
+	for host, want := range map[string]string{"amp.example.com": "amp.example.com", "amplipi.local": "amplipi"} {
+		cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: host})
+		if err != nil {
+			t.Fatalf("%s: %v", host, err)
+		}
+		if cert.Leaf.DNSNames[0] != want {
+			t.Errorf("%s: certificate for %v", host, cert.Leaf.DNSNames)
+		}
+	}
+}
+
+func TestACME_DNSHookFailure(t *testing.T) {
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "hook.sh")
+	os.WriteFile(hook, []byte("#!/bin/sh\necho \"no API token\" >&2\nexit 1\n"), 0755)
+	a, err := NewACME(ACMEOptions{Domains: []string{"amp.example.com"}, CacheDir: dir, DNSHook: hook}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.runHook(context.Background(), "present", "_acme-challenge.amp.example.com.", "abc")
+	if err == nil || !strings.Contains(err.Error(), "no API token") {
+		t.Errorf("err = %v, want the hook's output", err)
+	}
+	if _, err := a.GetCertificate(&tls.ClientHelloInfo{ServerName: "amp.example.com"}); err == nil {
+		t.Error("certificate returned before one was issued and without a fallback")
+	}
+}
@@ -0,0 +1,230 @@
+// Package webhooks delivers events to the user's webhooks: each event on
+// the bus is POSTed as JSON to every enabled webhook subscribed to its
+// type, signed with the webhook's secret and retried with backoff when
+// the receiver is unreachable or failing. Deliveries never go to loopback
+// or link-local addresses, such as the AmpliPi's own API or a cloud
+// metadata service, and redirects are not followed.
+//
+// Requests carry these headers:
+//
+//	X-AmpliPi-Event          the event type, e.g. "zone_changed"
+//	X-AmpliPi-Delivery       a unique ID, the same across retries
+//	X-AmpliPi-Signature-256  "sha256=" + hex HMAC-SHA256 of the body,
+//	                         when the webhook has a secret
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+const (
+	workers        = 4                // concurrent deliveries
+	queueSize      = 64               // deliveries waiting for a worker; more are dropped
+	attemptTimeout = 10 * time.Second // bounds one POST
+)
+
+// allowedIP reports whether deliveries may connect to ip. Replaced in
+// tests, whose receivers listen on loopback.
+var allowedIP = func(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() && !ip.IsUnspecified()
+}
+
+// Controller is the part of the controller the dispatcher reads webhooks
+// from.
+type Controller interface {
+	GetWebhooks() []models.Webhook
+}
+
+// EventBus delivers the events to send.
+type EventBus interface {
+	SubscribeEvents(id string) <-chan models.Event
+	UnsubscribeEvents(id string)
+}
+
+// delivery is one event on its way to one webhook.
+type delivery struct {
+	hook models.Webhook
+	id   string
+	typ  string
+	body []byte
+}
+
+// Dispatcher posts events to webhooks.
+type Dispatcher struct {
+	ctrl    Controller
+	client  *http.Client
+	backoff []time.Duration // delays before each retry; replaced in tests
+	queue   chan delivery
+}
+
+// New creates a dispatcher delivering to the webhooks in settings.
+func New(ctrl Controller) *Dispatcher {
+	// Checking the address being dialed, rather than the URL, also covers
+	// host names that resolve to a refused address.
+	dialer := &net.Dialer{
+		Timeout: attemptTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !allowedIP(ip) {
+				return fmt.Errorf("webhook: %s is a loopback or link-local address", host)
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &Dispatcher{
+		ctrl: ctrl,
+		client: &http.Client{
+			Timeout:   attemptTimeout,
+			Transport: transport,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		backoff: []time.Duration{5 * time.Second, 30 * time.Second, 2 * time.Minute},
+		queue:   make(chan delivery, queueSize),
+	}
+}
+
+// Run delivers events until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context, bus EventBus) {
+	id := "webhooks-" + uuid.New().String()
+	evs := bus.SubscribeEvents(id)
+	defer bus.UnsubscribeEvents(id)
+
+	for i := 0; i < workers; i++ {
+		go d.work(ctx)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev, ok := <-evs:
+			if !ok {
+				return
+			}
+			d.Dispatch(ev)
+		}
+	}
+}
+
+// Dispatch queues ev for every webhook that wants it. A ping goes only to
+// the webhook it names.
+func (d *Dispatcher) Dispatch(ev models.Event) {
+	var hooks []models.Webhook
+	for _, w := range d.ctrl.GetWebhooks() {
+		if ping, ok := ev.Data.(models.WebhookPing); ok {
+			if w.ID == ping.WebhookID {
+				hooks = append(hooks, w)
+			}
+		} else if w.Wants(ev.Type) {
+			hooks = append(hooks, w)
+		}
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	body, err := json.Marshal(ev)
+	if err != nil {
+		slog.Warn("webhook: encode event failed", "type", ev.Type, "err", err)
+		return
+	}
+	for _, w := range hooks {
+		dl := delivery{hook: w, id: uuid.New().String(), typ: ev.Type, body: body}
+		select {
+		case d.queue <- dl:
+		default:
+			slog.Warn("webhook: queue full, dropping delivery", "webhook", w.Name, "type", ev.Type)
+		}
+	}
+}
+
+func (d *Dispatcher) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case dl := <-d.queue:
+			d.deliver(ctx, dl)
+		}
+	}
+}
+
+// deliver posts dl, retrying after each backoff delay while the failure
+// may be transient (network errors, 429 and 5xx responses).
+func (d *Dispatcher) deliver(ctx context.Context, dl delivery) {
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(ctx, dl)
+		if err == nil {
+			slog.Debug("webhook delivered", "webhook", dl.hook.Name, "type", dl.typ, "delivery", dl.id)
+			return
+		}
+		if !retry || attempt >= len(d.backoff) {
+			slog.Warn("webhook delivery failed", "webhook", dl.hook.Name, "type", dl.typ,
+				"delivery", dl.id, "attempts", attempt+1, "err", err)
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(d.backoff[attempt]):
+		}
+	}
+}
+
+// post makes one delivery attempt and reports whether a failure is worth
+// retrying.
+func (d *Dispatcher) post(ctx context.Context, dl delivery) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, dl.hook.URL, bytes.NewReader(dl.body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "AmpliPi-Webhook")
+	req.Header.Set("X-AmpliPi-Event", dl.typ)
+	req.Header.Set("X-AmpliPi-Delivery", dl.id)
+	if dl.hook.Secret != "" {
+		req.Header.Set("X-AmpliPi-Signature-256", Sign(dl.hook.Secret, dl.body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("receiver returned %s", resp.Status)
+	}
+	return false, fmt.Errorf("receiver returned %s", resp.Status)
+}
+
+// Sign returns the X-AmpliPi-Signature-256 header value for body, which
+// receivers recompute with the shared secret to authenticate deliveries.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
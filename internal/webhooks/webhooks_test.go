@@ -0,0 +1,201 @@
+package webhooks
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/micro-nova/amplipi-go/internal/config"
+	"github.com/micro-nova/amplipi-go/internal/controller"
+	"github.com/micro-nova/amplipi-go/internal/events"
+	"github.com/micro-nova/amplipi-go/internal/hardware"
+	"github.com/micro-nova/amplipi-go/internal/models"
+)
+
+func strPtr(s string) *string { return &s }
+
+// received is one request seen by the test receiver.
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+// newReceiver returns a server that answers with the given statuses in
+// turn (200 once they run out) and a channel of the requests it got.
+func newReceiver(t *testing.T, statuses ...int) (*httptest.Server, <-chan received) {
+	t.Helper()
+	ch := make(chan received, 16)
+	var n atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ch <- received{header: r.Header, body: body}
+		if i := int(n.Add(1)) - 1; i < len(statuses) {
+			w.WriteHeader(statuses[i])
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, ch
+}
+
+// allowLoopback lets deliveries reach the test receivers.
+func allowLoopback(t *testing.T) {
+	t.Helper()
+	orig := allowedIP
+	allowedIP = func(net.IP) bool { return true }
+	t.Cleanup(func() { allowedIP = orig })
+}
+
+func newTestDispatcher(t *testing.T, hooks ...models.WebhookUpdate) (*Dispatcher, *controller.Controller, *events.Bus) {
+	t.Helper()
+	allowLoopback(t)
+	bus := events.NewBus()
+	ctrl, err := controller.New(hardware.NewMock(), nil, config.NewMemStore(), bus, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, h := range hooks {
+		if _, appErr := ctrl.CreateWebhook(context.Background(), h); appErr != nil {
+			t.Fatal(appErr)
+		}
+	}
+	d := New(ctrl)
+	d.backoff = []time.Duration{time.Millisecond, time.Millisecond}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go d.Run(ctx, bus)
+	// Run subscribes asynchronously; wait until events reach it.
+	time.Sleep(20 * time.Millisecond)
+	return d, ctrl, bus
+}
+
+func wait(t *testing.T, ch <-chan received) received {
+	t.Helper()
+	select {
+	case r := <-ch:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("no delivery")
+	}
+	return received{}
+}
+
+func TestDeliverSigned(t *testing.T) {
+	srv, got := newReceiver(t)
+	_, ctrl, _ := newTestDispatcher(t, models.WebhookUpdate{
+		Name: strPtr("ha"), URL: strPtr(srv.URL), Secret: strPtr("s3cret"),
+		Events: []string{models.EventZoneChanged},
+	})
+
+	vol := 0.3
+	ctrl.SetZone(context.Background(), 2, models.ZoneUpdate{VolF: &vol})
+	r := wait(t, got)
+	if ev := r.header.Get("X-AmpliPi-Event"); ev != models.EventZoneChanged {
+		t.Errorf("X-AmpliPi-Event = %q", ev)
+	}
+	if sig := r.header.Get("X-AmpliPi-Signature-256"); sig != Sign("s3cret", r.body) {
+		t.Errorf("signature %q does not match the body", sig)
+	}
+	if r.header.Get("X-AmpliPi-Delivery") == "" {
+		t.Error("missing X-AmpliPi-Delivery")
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	srv, got := newReceiver(t, http.StatusServiceUnavailable, http.StatusInternalServerError)
+	_, ctrl, _ := newTestDispatcher(t, models.WebhookUpdate{Name: strPtr("flaky"), URL: strPtr(srv.URL)})
+
+	mute := false // zones start muted
+	ctrl.SetZone(context.Background(), 0, models.ZoneUpdate{Mute: &mute})
+	first := wait(t, got)
+	wait(t, got)
+	third := wait(t, got)
+	if first.header.Get("X-AmpliPi-Delivery") != third.header.Get("X-AmpliPi-Delivery") {
+		t.Error("retry changed the delivery ID")
+	}
+	if first.header.Get("X-AmpliPi-Signature-256") != "" {
+		t.Error("unsigned webhook got a signature")
+	}
+	select {
+	case <-got:
+		t.Error("delivered again after success")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeliverNoRetryOnClientError(t *testing.T) {
+	srv, got := newReceiver(t, http.StatusNotFound)
+	_, ctrl, _ := newTestDispatcher(t, models.WebhookUpdate{Name: strPtr("gone"), URL: strPtr(srv.URL)})
+
+	mute := false // zones start muted
+	ctrl.SetZone(context.Background(), 0, models.ZoneUpdate{Mute: &mute})
+	wait(t, got)
+	select {
+	case <-got:
+		t.Error("retried after a 404")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestPingAndFilter(t *testing.T) {
+	srvA, gotA := newReceiver(t)
+	srvB, gotB := newReceiver(t)
+	_, ctrl, _ := newTestDispatcher(t,
+		models.WebhookUpdate{Name: strPtr("a"), URL: strPtr(srvA.URL), Events: []string{models.EventOverTemp}},
+		models.WebhookUpdate{Name: strPtr("b"), URL: strPtr(srvB.URL), Events: []string{models.EventOverTemp}},
+	)
+
+	// Zone changes are filtered out; the ping goes to b only.
+	mute := false // zones start muted
+	ctrl.SetZone(context.Background(), 0, models.ZoneUpdate{Mute: &mute})
+	if appErr := ctrl.TestWebhook(context.Background(), 1); appErr != nil {
+		t.Fatal(appErr)
+	}
+	if r := wait(t, gotB); r.header.Get("X-AmpliPi-Event") != models.EventWebhookPing {
+		t.Errorf("b got %q, want ping", r.header.Get("X-AmpliPi-Event"))
+	}
+	select {
+	case r := <-gotA:
+		t.Errorf("a got %q", r.header.Get("X-AmpliPi-Event"))
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestDeliverRefusesLoopback(t *testing.T) {
+	srv, got := newReceiver(t)
+	d := New(nil)
+	for _, url := range []string{srv.URL, "http://localhost:" + srv.URL[strings.LastIndex(srv.URL, ":")+1:], "http://169.254.169.254/latest"} {
+		dl := delivery{hook: models.Webhook{Name: "local", URL: url}, id: "1", typ: models.EventWebhookPing, body: []byte("{}")}
+		if _, err := d.post(context.Background(), dl); err == nil || !strings.Contains(err.Error(), "loopback or link-local") {
+			t.Errorf("post to %s: err = %v", url, err)
+		}
+	}
+	select {
+	case <-got:
+		t.Error("delivered to a loopback address")
+	default:
+	}
+}
+
+func TestDeliverDoesNotFollowRedirects(t *testing.T) {
+	allowLoopback(t)
+	target, got := newReceiver(t)
+	redirect := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	t.Cleanup(redirect.Close)
+
+	d := New(nil)
+	dl := delivery{hook: models.Webhook{Name: "moved", URL: redirect.URL}, id: "1", typ: models.EventWebhookPing, body: []byte("{}")}
+	if retry, err := d.post(context.Background(), dl); err == nil || retry {
+		t.Errorf("post to a redirect: retry=%v err=%v", retry, err)
+	}
+	select {
+	case <-got:
+		t.Error("followed the redirect")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
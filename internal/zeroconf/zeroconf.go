@@ -6,14 +6,23 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
+	"slices"
+	"sync"
 
 	"github.com/grandcat/zeroconf"
 )
 
 // Service manages mDNS service registration.
 type Service struct {
-	name   string // instance name / hostname, e.g. "amplipi"
-	port   int
+	port    int
+	ifaces  []net.Interface // nil: all multicast interfaces
+	ips     []string        // nil: each interface's own addresses
+	renamed chan struct{}   // signals Start to re-register
+
+	mu     sync.Mutex // guards the fields below
+	name   string     // instance name, e.g. "AmpliPi Upstairs"
+	host   string     // hostname, e.g. "amplipi"
 	server *zeroconf.Server
 }
 
@@ -21,45 +30,144 @@ type Service struct {
 // name should be the hostname (e.g. "amplipi").
 func New(name string, port int) *Service {
 	return &Service{
-		name: name,
-		port: port,
+		name:    name,
+		host:    name,
+		port:    port,
+		renamed: make(chan struct{}, 1),
+	}
+}
+
+// Rename changes the hostname and instance name the service is advertised
+// under, re-registering it if it is running. An empty instance uses the
+// hostname.
+func (s *Service) Rename(host, instance string) {
+	if instance == "" {
+		instance = host
+	}
+	s.mu.Lock()
+	changed := s.host != host || s.name != instance
+	s.host, s.name = host, instance
+	s.mu.Unlock()
+	if changed {
+		select {
+		case s.renamed <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// SetHosts limits the advertisement to the listen hosts the API is served
+// on. A wildcard host ("", "0.0.0.0" or "::") advertises on every
+// interface, each answering with its own addresses; specific IPs are
+// advertised only on the interfaces that have them.
+func (s *Service) SetHosts(hosts []string) error {
+	s.ifaces, s.ips = nil, nil
+	all, err := net.Interfaces()
+	if err != nil {
+		return err
+	}
+	var ifaces []net.Interface
+	var ips []string
+	for _, h := range hosts {
+		ip := net.ParseIP(h)
+		if h == "" || ip != nil && ip.IsUnspecified() {
+			return nil
+		}
+		if ip == nil {
+			return fmt.Errorf("zeroconf: listen host %q is not an IP address", h)
+		}
+		iface := interfaceWith(all, ip)
+		if iface == nil {
+			return fmt.Errorf("zeroconf: no interface has address %s", ip)
+		}
+		ips = append(ips, ip.String())
+		if !slices.ContainsFunc(ifaces, func(i net.Interface) bool { return i.Index == iface.Index }) {
+			ifaces = append(ifaces, *iface)
+		}
+	}
+	s.ifaces, s.ips = ifaces, ips
+	return nil
+}
+
+// interfaceWith returns the interface that has ip, or nil.
+func interfaceWith(ifaces []net.Interface, ip net.IP) *net.Interface {
+	for i := range ifaces {
+		addrs, err := ifaces[i].Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if n, ok := a.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return &ifaces[i]
+			}
+		}
 	}
+	return nil
 }
 
 // Start registers the mDNS service and blocks until ctx is cancelled, at which
-// point it shuts down the server cleanly.
+// point it shuts down the server cleanly. The service is re-registered when
+// it is renamed.
 func (s *Service) Start(ctx context.Context) error {
-	txt := []string{"version=0.5.0-go", "model=AmpliPi"}
+	select {
+	case <-s.renamed: // renamed before it was registered
+	default:
+	}
+	for {
+		server, err := s.register()
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			server.Shutdown()
+			slog.Info("zeroconf: mDNS service unregistered")
+			return nil
+		case <-s.renamed:
+			server.Shutdown()
+		}
+	}
+}
+
+// register advertises the service under its current names.
+func (s *Service) register() (*zeroconf.Server, error) {
+	// pair: where mobile apps request a key, see POST /api/pair
+	txt := []string{"version=0.5.0-go", "model=AmpliPi", "pair=/api/pair"}
 
-	server, err := zeroconf.Register(
-		s.name,     // instance name
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Without IPs each interface answers with its own addresses, rather
+	// than every interface with all of the host's.
+	server, err := zeroconf.RegisterProxy(
+		s.name,       // instance name
 		"_http._tcp", // service type
 		"local.",     // domain
 		s.port,       // port
+		s.host,       // host name, as <host>.local
+		s.ips,        // addresses; nil means each interface's own
 		txt,          // TXT records
-		nil,          // ifaces — nil means all interfaces
+		s.ifaces,     // ifaces — nil means all interfaces
 	)
 	if err != nil {
-		return fmt.Errorf("zeroconf register: %w", err)
+		return nil, fmt.Errorf("zeroconf register: %w", err)
 	}
 	s.server = server
 	slog.Info("zeroconf: registered mDNS service",
 		"name", s.name,
+		"host", s.host,
 		"port", s.port,
+		"ips", s.ips,
 		"txt", txt,
 	)
-
-	<-ctx.Done()
-
-	server.Shutdown()
-	slog.Info("zeroconf: mDNS service unregistered")
-	return nil
+	return server, nil
 }
 
 // UpdateTXT updates the TXT records for the registered service.
 // Note: grandcat/zeroconf v1.0.0 does not expose a SetText method; to update
 // TXT records the server must be restarted. This is a best-effort operation.
 func (s *Service) UpdateTXT(records []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	if s.server == nil {
 		return fmt.Errorf("zeroconf: server not started")
 	}
@@ -49,3 +49,19 @@ func TestUpdateTXT_BeforeStart(t *testing.T) {
 		t.Error("UpdateTXT before Start should return an error")
 	}
 }
+
+// TestSetHosts checks which listen hosts can be advertised.
+func TestSetHosts(t *testing.T) {
+	svc := zeroconf.New("amplipi-test", 18080)
+	for _, hosts := range [][]string{{""}, {"0.0.0.0"}, {"::"}, {"127.0.0.1", "::"}, {"127.0.0.1"}} {
+		if err := svc.SetHosts(hosts); err != nil {
+			t.Errorf("SetHosts(%q): %v", hosts, err)
+		}
+	}
+	if err := svc.SetHosts([]string{"192.0.2.77"}); err == nil {
+		t.Error("SetHosts with an address no interface has: no error")
+	}
+	if err := svc.SetHosts([]string{"amplipi.local"}); err == nil {
+		t.Error("SetHosts with a host name: no error")
+	}
+}
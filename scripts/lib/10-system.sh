@@ -55,9 +55,49 @@ else
     record_done "timezone"
 fi
 
+# ── Network share mount helper ───────────────────────────────────────────────
+# The daemon may mount shares only through this root-owned helper, which
+# mounts read-only under MEDIA_DIR with an allowlist of options.
+_mount_helper="/usr/local/sbin/amplipi-mount"
+_mount_helper_src="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)/../configs/amplipi-mount"
+_shares_conf="/etc/amplipi/shares.conf"
+_shares_conf_content="MEDIA_DIR=${MEDIA_DIR}
+CRED_DIR=${CONFIG_DIR}/shares"
+
+if cmp -s "$_mount_helper_src" "$_mount_helper" && \
+   [[ "$(cat "$_shares_conf" 2>/dev/null)" == "$_shares_conf_content" ]]; then
+    skip "share mount helper (${_mount_helper})"
+    record_skip "share mount helper"
+else
+    step "Installing ${_mount_helper}"
+    install -o root -g root -m 0755 "$_mount_helper_src" "$_mount_helper"
+    install -d -o root -g root -m 0755 /etc/amplipi
+    echo "$_shares_conf_content" > "$_shares_conf"
+    chown root:root "$_shares_conf"
+    chmod 0644 "$_shares_conf"
+    log "share mount helper installed (media dir ${MEDIA_DIR})"
+    record_done "share mount helper"
+fi
+
+# ── Hostname helper ──────────────────────────────────────────────────────────
+# PATCH /api/system/hostname renames the unit through this root-owned
+# helper, which validates the name before touching hostnamectl and /etc/hosts.
+_hostname_helper="/usr/local/sbin/amplipi-hostname"
+_hostname_helper_src="$(cd "$(dirname "${BASH_SOURCE[0]}")" && pwd)/../configs/amplipi-hostname"
+
+if cmp -s "$_hostname_helper_src" "$_hostname_helper"; then
+    skip "hostname helper (${_hostname_helper})"
+    record_skip "hostname helper"
+else
+    step "Installing ${_hostname_helper}"
+    install -o root -g root -m 0755 "$_hostname_helper_src" "$_hostname_helper"
+    log "hostname helper installed"
+    record_done "hostname helper"
+fi
+
 # ── Passwordless sudo for pi user (amplipi systemd commands) ─────────────────
 _sudoers_file="/etc/sudoers.d/amplipi"
-_sudoers_content="# AmpliPi: allow pi user to manage amplipi systemd services without password
+_sudoers_content="# AmpliPi: allow pi user to manage amplipi systemd services, reboot and power off, mount network shares, rename the unit and set the clock without password
 pi ALL=(ALL) NOPASSWD: /bin/systemctl start amplipi
 pi ALL=(ALL) NOPASSWD: /bin/systemctl stop amplipi
 pi ALL=(ALL) NOPASSWD: /bin/systemctl restart amplipi
@@ -65,7 +105,11 @@ pi ALL=(ALL) NOPASSWD: /bin/systemctl status amplipi
 pi ALL=(ALL) NOPASSWD: /bin/systemctl start amplipi-update
 pi ALL=(ALL) NOPASSWD: /bin/systemctl stop amplipi-update
 pi ALL=(ALL) NOPASSWD: /bin/systemctl restart amplipi-update
-pi ALL=(ALL) NOPASSWD: /bin/systemctl status amplipi-update"
+pi ALL=(ALL) NOPASSWD: /bin/systemctl status amplipi-update
+pi ALL=(root) NOPASSWD: /bin/systemctl reboot, /bin/systemctl poweroff
+pi ALL=(root) NOPASSWD: /usr/local/sbin/amplipi-mount
+pi ALL=(root) NOPASSWD: /usr/local/sbin/amplipi-hostname
+pi ALL=(root) NOPASSWD: /usr/bin/timedatectl set-timezone *, /usr/bin/timedatectl set-ntp true, /usr/bin/timedatectl set-ntp false"
 
 if [[ -f "$_sudoers_file" ]] && [[ "$(cat "$_sudoers_file")" == "$_sudoers_content" ]]; then
     skip "sudoers (${_sudoers_file})"
@@ -181,6 +181,46 @@ pcm.ch3 {
     slave.channels      2
 }
 
+# ── Duck stages — music plays through these ──────────────────────────────────
+# amplipi turns the music down under announcements mixed straight into chN
+# with amixer cset name='ChN Duck'.
+pcm.ch0_duck {
+    type            softvol
+    slave.pcm       "ch0"
+    control.name    "Ch0 Duck"
+    control.card    sndrpihifiberry
+    min_dB          -60.0
+    max_dB          0.0
+    resolution      121
+}
+pcm.ch1_duck {
+    type            softvol
+    slave.pcm       "ch1"
+    control.name    "Ch1 Duck"
+    control.card    cmedia8chint
+    min_dB          -60.0
+    max_dB          0.0
+    resolution      121
+}
+pcm.ch2_duck {
+    type            softvol
+    slave.pcm       "ch2"
+    control.name    "Ch2 Duck"
+    control.card    cmedia8chint
+    min_dB          -60.0
+    max_dB          0.0
+    resolution      121
+}
+pcm.ch3_duck {
+    type            softvol
+    slave.pcm       "ch3"
+    control.name    "Ch3 Duck"
+    control.card    cmedia8chint
+    min_dB          -60.0
+    max_dB          0.0
+    resolution      121
+}
+
 # ── Loopback sources — 6 cards (Loopback .. Loopback_5) ─────────────────────
 # Each card has 2 devices: device 0 (write side) and device 1 (read side).
 # Streams write to lbNp (plug, forces 48kHz S16_LE).
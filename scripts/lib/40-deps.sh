@@ -40,7 +40,15 @@ _deps=(
     xxd                     # hex dump tool (required by shairport-sync AirPlay 2 build)
     libgcrypt20-dev         # libgcrypt (required by shairport-sync AirPlay 2 build)
 
+    # ── Network shares ───────────────────────────────────────────────────────
+    cifs-utils              # mount.cifs for SMB shares in the media library
+    nfs-common              # mount.nfs for NFS shares in the media library
+
+    # ── Speech ──────────────────────────────────────────────────────────────
+    espeak-ng               # spoken zone names for POST /api/zones/{id}/identify
+
     # ── FFmpeg ──────────────────────────────────────────────────────────────
+    ffmpeg                  # MP3 encoding of sources for Google Cast output
     libavutil-dev           # FFmpeg utils (squeezelite --FFMPEG=1)
     libavcodec-dev          # FFmpeg codecs
     libavformat-dev         # FFmpeg formats
@@ -56,7 +64,10 @@ _deps=(
     libglib2.0-dev          # GLib (bluez-alsa, gmrender-resurrect)
     libdbus-1-dev           # D-Bus (bluez-alsa)
 
-    # ── GStreamer (gmrender-resurrect) ───────────────────────────────────────
+    # ── Snapcast (synced satellite speakers) ─────────────────────────────────
+    snapserver              # managed by amplipi for sources with snapcast output
+
+    # ── GStreamer (gmrender-resurrect, RTP output) ───────────────────────────
     gstreamer1.0-tools
     gstreamer1.0-plugins-base
     gstreamer1.0-plugins-good
@@ -104,4 +115,7 @@ _deps=(
 
 apt-get install -y --no-install-recommends "${_deps[@]}"
 log "all build dependencies installed"
+
+# amplipi runs its own snapserver; the packaged service would hold its ports
+systemctl disable --now snapserver.service 2>/dev/null || true
 record_done "build dependencies (${#_deps[@]} packages)"
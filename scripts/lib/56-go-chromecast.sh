@@ -0,0 +1,61 @@
+#!/usr/bin/env bash
+# 56-go-chromecast.sh — Install go-chromecast (Google Cast output control)
+# Sourced by setup.sh. Requires common.sh to be sourced first.
+#
+# Repo: vishen/go-chromecast
+# Downloads pre-built binary for the correct arch — no build required.
+
+set -euo pipefail
+
+step "56 · go-chromecast (Google Cast output)"
+
+_name="go-chromecast"
+_repo="vishen/go-chromecast"
+_bin="$INSTALL_PREFIX/bin/go-chromecast"
+
+# ── Map arch to asset name ────────────────────────────────────────────────────
+case "$ARCH" in
+    aarch64) _asset_suffix="linux_arm64" ;;
+    armv7l)  _asset_suffix="linux_armv7" ;;
+    *)
+        warn "go-chromecast: unsupported arch '$ARCH' — skipping"
+        record_skip "go-chromecast (unsupported arch)"
+        return 0
+        ;;
+esac
+
+# ── Get latest release info ───────────────────────────────────────────────────
+_latest_tag="$(latest_github_tag "$_repo")"
+log "Latest upstream tag: $_latest_tag"
+
+_installed_ver="$(read_installed_version "$_name")"
+
+if [[ -n "$_installed_ver" ]] && [[ "$_installed_ver" == "$_latest_tag" ]] && \
+   [[ -x "$_bin" ]]; then
+    skip "go-chromecast $_installed_ver already installed"
+    record_skip "go-chromecast"
+else
+    log "Installing go-chromecast ${_latest_tag} (installed: '${_installed_ver:-none}')"
+
+    # Asset naming: go-chromecast_0.3.1_linux_arm64.tar.gz (version without "v")
+    _tarball_url="https://github.com/${_repo}/releases/download/${_latest_tag}/go-chromecast_${_latest_tag#v}_${_asset_suffix}.tar.gz"
+
+    _tmp_dir="$BUILD_DIR/go-chromecast-download"
+    mkdir -p "$_tmp_dir"
+
+    if ! curl -fsSL -o "$_tmp_dir/go-chromecast.tar.gz" "$_tarball_url"; then
+        error "go-chromecast: could not download $_tarball_url"
+        exit 1
+    fi
+    tar -xzf "$_tmp_dir/go-chromecast.tar.gz" -C "$_tmp_dir"
+    _extracted_bin="$(find "$_tmp_dir" -name "go-chromecast" -type f | head -1)"
+    if [[ -z "$_extracted_bin" ]]; then
+        error "go-chromecast binary not found in tarball"
+        exit 1
+    fi
+    install -m 0755 "$_extracted_bin" "$_bin"
+
+    log "go-chromecast installed to $_bin"
+    write_installed_version "$_name" "$_latest_tag"
+    record_done "go-chromecast ${_latest_tag}"
+fi
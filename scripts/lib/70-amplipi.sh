@@ -3,7 +3,7 @@
 # Sourced by setup.sh. Requires common.sh to be sourced first.
 #
 # - Installs Go if missing
-# - Builds cmd/amplipi/... and cmd/amplipi-update/... (if present)
+# - Builds cmd/amplipi/..., cmd/amplipi-cli/... and cmd/amplipi-update/... (if present)
 # - Installs systemd unit files from scripts/configs/
 # - Enables (but does NOT start) amplipi.service and amplipi-update.service
 
@@ -99,6 +99,13 @@ cd "$_repo_root"
 log "amplipi installed to $_bin_dst"
 record_done "amplipi binary"
 
+# ── Build amplipi-cli ─────────────────────────────────────────────────────────
+step "Building amplipi-cli binary"
+_cli_bin_dst="$INSTALL_PREFIX/bin/amplipi-cli"
+"$_go_bin" build -ldflags="-s -w" -o "$_cli_bin_dst" ./cmd/amplipi-cli/...
+log "amplipi-cli installed to $_cli_bin_dst"
+record_done "amplipi-cli binary"
+
 # ── Build amplipi-update (if present) ────────────────────────────────────────
 if [[ -d "$_repo_root/cmd/amplipi-update" ]] && [[ -n "$(find "$_repo_root/cmd/amplipi-update" -name '*.go' -print -quit)" ]]; then
     step "Building amplipi-update binary"
@@ -42,6 +42,9 @@ else
 fi
 CONFIG_DIR="${AMPLIPI_CONFIG_DIR:-${_INVOKING_HOME}/.config/amplipi}"
 
+# Music library network shares are mounted into; must match the daemon's --media-dir
+MEDIA_DIR="${AMPLIPI_MEDIA_DIR:-${_INVOKING_HOME}/Music}"
+
 # Version store directory (root-owned, used to track installed binary versions)
 VERSION_DIR="/etc/amplipi/versions"
 
@@ -208,9 +208,14 @@ export const api = {
 		return request('/info');
 	},
 
-	factoryReset(): Promise<State> {
-		return request<State>('/factory_reset', {
-			method: 'POST'
+	// Without confirm, returns a token to send back within 30 seconds.
+	factoryReset(
+		scope: 'audio' | 'config' | 'full' = 'config',
+		confirm?: string
+	): Promise<{ scope: string; status: string; confirm?: string; expires?: string; state?: State }> {
+		return request('/factory_reset', {
+			method: 'POST',
+			body: JSON.stringify({ scope, confirm })
 		});
 	}
 };